@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"cicd-agent/common"
+	"cicd-agent/config"
+
+	"github.com/gorilla/websocket"
+)
+
+// agentClient agent接口客户端
+type agentClient struct {
+	server     string
+	token      string
+	signKey    string
+	hasConfig  bool // 已加载agent配置，可按配置加密请求体和签名回调
+	httpClient *http.Client
+	tlsConfig  *tls.Config
+}
+
+// apiResponse 接口统一响应（中间件拒绝时可能只有error字段）
+type apiResponse struct {
+	Code  int             `json:"code"`
+	Msg   string          `json:"msg"`
+	Error string          `json:"error"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// newAgentClient 创建客户端
+func newAgentClient(server, token, signKey string, hasConfig, insecure bool, timeout time.Duration) *agentClient {
+	tlsConfig := &tls.Config{InsecureSkipVerify: insecure}
+	return &agentClient{
+		server:    strings.TrimSuffix(server, "/"),
+		token:     token,
+		signKey:   signKey,
+		hasConfig: hasConfig,
+		httpClient: &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment},
+		},
+		tlsConfig: tlsConfig,
+	}
+}
+
+// get 调用GET接口，返回data
+func (c *agentClient) get(path string) (json.RawMessage, error) {
+	resp, err := c.do(http.MethodGet, path, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// post 调用POST接口，返回响应
+func (c *agentClient) post(path string, body interface{}) (*apiResponse, error) {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+	return c.do(http.MethodPost, path, data, nil)
+}
+
+// postEncrypted 调用接受EncryptedRequest的接口：配置要求加密时加密请求体，开启回调签名时附加签名头
+func (c *agentClient) postEncrypted(path string, body interface{}, sign bool) (*apiResponse, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	if c.hasConfig && config.AppConfig.GetRequestEncryption() != config.RequestEncryptionOff {
+		encrypted, err := common.CompressAndEncrypt(data)
+		if err != nil {
+			return nil, fmt.Errorf("加密请求体失败: %v", err)
+		}
+		data, _ = json.Marshal(map[string]string{"data": encrypted})
+	}
+
+	headers := map[string]string{}
+	if sign && c.hasConfig && config.AppConfig.Callback.Signature.Enable {
+		secret, ok := config.AppConfig.GetCallbackSecret(c.signKey)
+		if !ok {
+			return nil, fmt.Errorf("配置中没有回调签名密钥: %s", c.signKey)
+		}
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		nonce := common.NewRequestID()
+		headers[common.SignatureHeader] = "sha256=" + common.ComputeSignature(secret, timestamp, nonce, data)
+		headers[common.SignatureTimestampHeader] = timestamp
+		headers[common.SignatureNonceHeader] = nonce
+		headers[common.SignatureKeyHeader] = c.signKey
+	}
+	return c.do(http.MethodPost, path, data, headers)
+}
+
+// do 发送请求，非2xx时返回接口给出的错误信息
+func (c *agentClient) do(method, path string, body []byte, headers map[string]string) (*apiResponse, error) {
+	req, err := http.NewRequest(method, c.server+"/api/v1"+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求agent失败: %v", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %v", err)
+	}
+
+	var result apiResponse
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("解析响应失败（状态码 %d）: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg := result.Msg
+		if msg == "" {
+			msg = result.Error
+		}
+		return nil, fmt.Errorf("状态码 %d: %s", resp.StatusCode, msg)
+	}
+	return &result, nil
+}
+
+// streamLogs 通过WebSocket日志接口输出任务日志，连接关闭时返回
+func (c *agentClient) streamLogs(taskID, step string, output io.Writer) error {
+	data, err := c.get("/task/" + url.PathEscape(taskID) + "/log-token?step=" + url.QueryEscape(step))
+	if err != nil {
+		return err
+	}
+	var token struct {
+		Data string `json:"data"`
+	}
+	if err := json.Unmarshal(data, &token); err != nil {
+		return fmt.Errorf("解析日志查看参数失败: %v", err)
+	}
+
+	wsURL := strings.Replace(c.server, "http", "ws", 1) + "/api/v1/ws/task/logs?data=" + url.QueryEscape(token.Data)
+	header := http.Header{}
+	if c.token != "" {
+		header.Set("Authorization", "Bearer "+c.token)
+	}
+	dialer := websocket.Dialer{TLSClientConfig: c.tlsConfig, HandshakeTimeout: c.httpClient.Timeout, Proxy: http.ProxyFromEnvironment}
+	conn, resp, err := dialer.Dial(wsURL, header)
+	if err != nil {
+		if resp != nil {
+			return fmt.Errorf("连接日志接口失败: 状态码 %d", resp.StatusCode)
+		}
+		return fmt.Errorf("连接日志接口失败: %v", err)
+	}
+	defer conn.Close()
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				return nil
+			}
+			return fmt.Errorf("读取日志失败: %v", err)
+		}
+		text := string(message)
+		if !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+		fmt.Fprint(output, text)
+	}
+}
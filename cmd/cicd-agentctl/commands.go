@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+
+	"cicd-agent/config"
+)
+
+// taskInfo 任务列表项（与/api/v1/tasks一致）
+type taskInfo struct {
+	TaskID           string `json:"task_id"`
+	Project          string `json:"project"`
+	Tag              string `json:"tag"`
+	Status           string `json:"status"`
+	StartedAt        string `json:"started_at"`
+	DeployAt         string `json:"deploy_at"`
+	Reason           string `json:"reason"`
+	Position         int    `json:"position"`
+	AwaitingApproval bool   `json:"awaiting_approval"`
+}
+
+// stepRecord 步骤时间线中的步骤（与/api/v1/task/:id/steps一致）
+type stepRecord struct {
+	Step       int    `json:"step"`
+	Type       string `json:"type"`
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Message    string `json:"message"`
+	StartedAt  string `json:"started_at"`
+	FinishedAt string `json:"finished_at"`
+}
+
+// runTasks 列出任务
+func runTasks(cli *agentClient, args []string) error {
+	flags := flag.NewFlagSet("tasks", flag.ExitOnError)
+	project := flags.String("project", "", "只列出该项目的任务")
+	asJSON := flags.Bool("json", false, "输出原始JSON")
+	flags.Parse(args)
+
+	path := "/tasks"
+	if *project != "" {
+		path += "?project=" + url.QueryEscape(*project)
+	}
+	data, err := cli.get(path)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(data)
+	}
+
+	var result struct {
+		Tasks []taskInfo `json:"tasks"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("解析任务列表失败: %v", err)
+	}
+	if len(result.Tasks) == 0 {
+		fmt.Println("没有执行中的任务")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "任务ID\t项目\t标签\t状态\t开始/计划时间\t备注")
+	for _, task := range result.Tasks {
+		status, note := task.Status, task.Reason
+		if task.AwaitingApproval {
+			status = "approval"
+		}
+		if task.Position > 0 {
+			note = fmt.Sprintf("排队第%d位", task.Position)
+		}
+		startedAt := task.StartedAt
+		if startedAt == "" {
+			startedAt = task.DeployAt
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", task.TaskID, task.Project, task.Tag, status, startedAt, note)
+	}
+	return w.Flush()
+}
+
+// runSteps 查看步骤时间线
+func runSteps(cli *agentClient, args []string) error {
+	flags := flag.NewFlagSet("steps", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "输出原始JSON")
+	flags.Parse(args)
+	taskID, err := taskIDArg(flags)
+	if err != nil {
+		return err
+	}
+
+	data, err := cli.get("/task/" + url.PathEscape(taskID) + "/steps")
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(data)
+	}
+
+	var result struct {
+		Task struct {
+			Project    string `json:"project"`
+			Tag        string `json:"tag"`
+			Status     string `json:"status"`
+			StartedAt  string `json:"started_at"`
+			FinishedAt string `json:"finished_at"`
+		} `json:"task"`
+		Running          bool         `json:"running"`
+		Paused           bool         `json:"paused"`
+		AwaitingApproval bool         `json:"awaiting_approval"`
+		Steps            []stepRecord `json:"steps"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("解析步骤时间线失败: %v", err)
+	}
+	status := result.Task.Status
+	switch {
+	case result.AwaitingApproval:
+		status = "等待审批"
+	case result.Paused:
+		status = "已暂停"
+	case result.Running:
+		status = "执行中"
+	}
+	fmt.Printf("任务 %s  项目=%s 标签=%s 状态=%s 开始=%s 结束=%s\n\n",
+		taskID, result.Task.Project, result.Task.Tag, status, result.Task.StartedAt, result.Task.FinishedAt)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "步骤\t名称\t状态\t开始\t结束\t信息")
+	for _, step := range result.Steps {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", step.Step, step.Name, step.Status, step.StartedAt, step.FinishedAt, step.Message)
+	}
+	return w.Flush()
+}
+
+// runLogs 跟踪任务日志
+func runLogs(cli *agentClient, args []string) error {
+	flags := flag.NewFlagSet("logs", flag.ExitOnError)
+	step := flags.String("step", "console", "步骤类型，console为完整日志")
+	flags.Parse(args)
+	taskID, err := taskIDArg(flags)
+	if err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cli.streamLogs(taskID, *step, os.Stdout) }()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	select {
+	case err := <-done:
+		return err
+	case <-interrupt:
+		return nil
+	}
+}
+
+// runDeploy 使用已构建的镜像标签触发部署
+func runDeploy(cli *agentClient, args []string) error {
+	flags := flag.NewFlagSet("deploy", flag.ExitOnError)
+	project := flags.String("project", "", "项目名（必填）")
+	tag := flags.String("tag", "", "镜像标签（必填）")
+	deployType := flags.String("type", "", "项目类型 double/single/web，默认按agent配置判断")
+	taskID := flags.String("task-id", "", "任务ID，默认由agent生成")
+	deployAt := flags.String("at", "", "计划部署时间（RFC3339或2006-01-02 15:04:05），默认立即部署")
+	priority := flags.String("priority", "", "优先级 normal/high")
+	flags.Parse(args)
+	if *project == "" || *tag == "" {
+		return fmt.Errorf("必须指定-project和-tag")
+	}
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	resp, err := cli.postEncrypted("/callback", map[string]string{
+		"project":     *project,
+		"type":        *deployType,
+		"status":      "success",
+		"tag":         *tag,
+		"task_id":     *taskID,
+		"create_time": now,
+		"finished_at": now,
+		"deploy_at":   *deployAt,
+		"priority":    *priority,
+	}, true)
+	if err != nil {
+		return err
+	}
+	return printResponse(resp)
+}
+
+// runCancel 取消任务
+func runCancel(cli *agentClient, args []string) error {
+	taskID, err := positionalTaskID("cancel", args)
+	if err != nil {
+		return err
+	}
+	resp, err := cli.postEncrypted("/task/cancel", map[string]string{"id": taskID}, false)
+	if err != nil {
+		return err
+	}
+	return printResponse(resp)
+}
+
+// runRollback 回滚任务
+func runRollback(cli *agentClient, args []string) error {
+	return postTaskAction(cli, "rollback", args)
+}
+
+// runApprove 批准流量切换
+func runApprove(cli *agentClient, args []string) error {
+	return postTaskAction(cli, "approve", args)
+}
+
+// runReject 拒绝流量切换
+func runReject(cli *agentClient, args []string) error {
+	return postTaskAction(cli, "reject", args)
+}
+
+// runValidateConfig 解析并校验agent配置文件
+func runValidateConfig(_ *agentClient, args []string) error {
+	path := ""
+	if len(args) > 0 {
+		path = args[0]
+	}
+	if _, err := config.LoadConfig(path); err != nil {
+		return err
+	}
+	fmt.Println("配置文件校验通过")
+	return nil
+}
+
+// postTaskAction 调用 POST /task/:id/<action>
+func postTaskAction(cli *agentClient, action string, args []string) error {
+	taskID, err := positionalTaskID(action, args)
+	if err != nil {
+		return err
+	}
+	resp, err := cli.post("/task/"+url.PathEscape(taskID)+"/"+action, nil)
+	if err != nil {
+		return err
+	}
+	return printResponse(resp)
+}
+
+// positionalTaskID 解析只有任务ID参数的命令
+func positionalTaskID(name string, args []string) (string, error) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Parse(args)
+	return taskIDArg(flags)
+}
+
+// taskIDArg 取第一个位置参数作为任务ID
+func taskIDArg(flags *flag.FlagSet) (string, error) {
+	if flags.NArg() == 0 || flags.Arg(0) == "" {
+		return "", fmt.Errorf("缺少任务ID")
+	}
+	return flags.Arg(0), nil
+}
+
+// printResponse 输出接口返回的信息和数据
+func printResponse(resp *apiResponse) error {
+	fmt.Println(resp.Msg)
+	if len(resp.Data) > 0 && string(resp.Data) != "null" {
+		return printJSON(resp.Data)
+	}
+	return nil
+}
+
+// printJSON 格式化输出JSON
+func printJSON(data json.RawMessage) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	encoded, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(encoded))
+	return nil
+}
@@ -0,0 +1,100 @@
+// cicd-agentctl 命令行工具：调用agent接口查看任务、跟踪日志、触发部署、取消、回滚，以及校验配置文件
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"cicd-agent/common"
+	"cicd-agent/config"
+)
+
+// command 子命令
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(cli *agentClient, args []string) error
+}
+
+var commands = []command{
+	{"tasks", "tasks [-project 项目] [-json]", "列出执行中、排队中和计划中的任务", runTasks},
+	{"steps", "steps [-json] <任务ID>", "查看任务的步骤时间线", runSteps},
+	{"logs", "logs [-step console] <任务ID>", "跟踪任务日志（WebSocket），Ctrl+C退出", runLogs},
+	{"deploy", "deploy -project 项目 -tag 标签 [-type double|single|web] [-at 时间] [-priority high]", "使用已构建的镜像标签触发部署（回调接口）", runDeploy},
+	{"cancel", "cancel <任务ID>", "取消执行中或计划中的任务", runCancel},
+	{"rollback", "rollback <任务ID>", "回滚到该任务之前最近一次成功的版本", runRollback},
+	{"approve", "approve <任务ID>", "批准任务等待中的流量切换", runApprove},
+	{"reject", "reject <任务ID>", "拒绝任务等待中的流量切换", runReject},
+	{"validate-config", "validate-config [配置文件]", "解析并校验agent配置文件（默认使用-config）", runValidateConfig},
+}
+
+func main() {
+	flags := flag.NewFlagSet("cicd-agentctl", flag.ExitOnError)
+	server := flags.String("server", envOrDefault("CICD_AGENT_URL", "http://127.0.0.1:8080"), "agent地址（环境变量CICD_AGENT_URL）")
+	token := flags.String("token", os.Getenv("CICD_AGENT_TOKEN"), "API Key或JWT，agent开启auth时需要（环境变量CICD_AGENT_TOKEN）")
+	configPath := flags.String("config", os.Getenv("CICD_AGENT_CONFIG"), "agent配置文件：需要加密请求体或回调签名时据此加密和签名（环境变量CICD_AGENT_CONFIG）")
+	signKey := flags.String("sign-key", "default", "回调签名使用的密钥ID（callback.signature.secrets）")
+	insecure := flags.Bool("insecure", false, "不校验agent的HTTPS证书")
+	timeout := flags.Duration("timeout", 30*time.Second, "单次请求超时")
+	flags.Usage = func() { printUsage(flags) }
+	flags.Parse(os.Args[1:])
+
+	if flags.NArg() == 0 {
+		printUsage(flags)
+		os.Exit(2)
+	}
+	name, args := flags.Arg(0), flags.Args()[1:]
+
+	common.InitLogger()
+	if *configPath != "" && name != "validate-config" {
+		if _, err := config.LoadConfig(*configPath); err != nil {
+			fatal(err)
+		}
+	}
+
+	cli := newAgentClient(*server, *token, *signKey, *configPath != "", *insecure, *timeout)
+	for _, cmd := range commands {
+		if cmd.name == name {
+			if name == "validate-config" && len(args) == 0 && *configPath != "" {
+				args = []string{*configPath}
+			}
+			if err := cmd.run(cli, args); err != nil {
+				fatal(err)
+			}
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "未知命令: %s\n\n", name)
+	printUsage(flags)
+	os.Exit(2)
+}
+
+// printUsage 输出用法
+func printUsage(flags *flag.FlagSet) {
+	fmt.Fprintln(os.Stderr, "用法: cicd-agentctl [全局参数] <命令> [参数]")
+	fmt.Fprintln(os.Stderr, "\n命令:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-18s %s\n", cmd.name, cmd.summary)
+		fmt.Fprintf(os.Stderr, "  %-18s   %s\n", "", cmd.usage)
+	}
+	fmt.Fprintln(os.Stderr, "\n全局参数:")
+	flags.PrintDefaults()
+}
+
+// envOrDefault 读取环境变量，为空时使用默认值
+func envOrDefault(name, defaultValue string) string {
+	if value := strings.TrimSpace(os.Getenv(name)); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// fatal 输出错误并退出
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "错误:", err)
+	os.Exit(1)
+}
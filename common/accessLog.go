@@ -0,0 +1,98 @@
+package common
+
+import (
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"cicd-agent/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// accessLogQueryKeys 访问日志中原样记录值的查询参数，其余参数（如加密的data、令牌）的值记录为***
+var accessLogQueryKeys = map[string]bool{
+	"entry":       true,
+	"format":      true,
+	"ip":          true,
+	"lastEventId": true,
+	"limit":       true,
+	"list":        true,
+	"page":        true,
+	"path":        true,
+	"project":     true,
+	"q":           true,
+	"since":       true,
+	"size":        true,
+	"status":      true,
+	"step":        true,
+	"task_id":     true,
+}
+
+// redactAccessLogQuery 按白名单脱敏查询参数，按参数名排序输出
+func redactAccessLogQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		for _, value := range query[key] {
+			if accessLogQueryKeys[key] {
+				value = url.QueryEscape(value)
+			} else {
+				value = "***"
+			}
+			parts = append(parts, url.QueryEscape(key)+"="+value)
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// AccessLogMiddleware HTTP访问日志中间件（替代gin.Logger，经AppLogger输出）
+// 按配置跳过健康检查等路径、按比例采样；5xx响应不受采样影响始终记录
+func AccessLogMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+
+		c.Next()
+
+		if config.AppConfig.ShouldSkipAccessLog(path) {
+			return
+		}
+
+		status := c.Writer.Status()
+		if status < http.StatusInternalServerError {
+			if rate := config.AppConfig.GetAccessLogSampleRate(); rate < 1 && rand.Float64() >= rate {
+				return
+			}
+		}
+
+		level := "INFO"
+		if status >= http.StatusInternalServerError {
+			level = "ERROR"
+		} else if status >= http.StatusBadRequest {
+			level = "WARNING"
+		}
+
+		fields := map[string]interface{}{
+			"method":     c.Request.Method,
+			"path":       path,
+			"status":     status,
+			"latency_ms": time.Since(start).Milliseconds(),
+			"client_ip":  getClientIP(c),
+			"size":       c.Writer.Size(),
+		}
+		if query := redactAccessLogQuery(c.Request.URL.Query()); query != "" && len(query) <= 256 {
+			fields["query"] = query
+		}
+
+		RequestLogger(c).Fields(level, "HTTP请求", fields)
+	}
+}
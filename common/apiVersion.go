@@ -0,0 +1,64 @@
+package common
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// APIVersionHeader API版本协商头：请求中指定期望版本，响应中返回实际使用的版本
+	APIVersionHeader = "X-API-Version"
+	// CurrentAPIVersion 当前默认API版本
+	CurrentAPIVersion = "1"
+	// apiVersionKey gin上下文中保存API版本的键
+	apiVersionKey = "api_version"
+)
+
+// supportedAPIVersions 支持的API版本
+var supportedAPIVersions = []string{"1"}
+
+// APIVersionMiddleware API版本协商中间件
+// 未指定版本时使用当前默认版本；指定了不支持的版本时返回400及支持的版本列表
+func APIVersionMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		version := strings.TrimPrefix(strings.TrimSpace(c.GetHeader(APIVersionHeader)), "v")
+		if version == "" {
+			version = CurrentAPIVersion
+		}
+
+		if !isSupportedAPIVersion(version) {
+			c.Header(APIVersionHeader, CurrentAPIVersion)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":      400,
+				"msg":       "不支持的API版本: " + version,
+				"supported": supportedAPIVersions,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set(apiVersionKey, version)
+		c.Header(APIVersionHeader, version)
+		c.Next()
+	}
+}
+
+// GetAPIVersion 获取当前请求协商后的API版本
+func GetAPIVersion(c *gin.Context) string {
+	if version := c.GetString(apiVersionKey); version != "" {
+		return version
+	}
+	return CurrentAPIVersion
+}
+
+// isSupportedAPIVersion 判断版本是否受支持
+func isSupportedAPIVersion(version string) bool {
+	for _, supported := range supportedAPIVersions {
+		if supported == version {
+			return true
+		}
+	}
+	return false
+}
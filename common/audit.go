@@ -0,0 +1,234 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"cicd-agent/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	auditMaxPayloadSize  = 1024 // 请求摘要最大长度
+	auditMaxFieldSize    = 128  // 单个字段值最大长度
+	auditMaxResponseSize = 256  // 响应摘要最大长度
+)
+
+// auditSensitiveKeys 请求中需要脱敏的字段关键字（webhook地址中带有令牌）
+var auditSensitiveKeys = []string{"feishu", "webhook", "token", "secret", "password", "key"}
+
+// AuditEntry 审计记录
+type AuditEntry struct {
+	Time      string `json:"time"`
+	RequestID string `json:"request_id,omitempty"`
+	Method    string `json:"method"`
+	Path      string `json:"path"`
+	ClientIP  string `json:"client_ip"`
+	Subject   string `json:"subject,omitempty"` // 令牌认证通过的调用方
+	Status    int    `json:"status"`
+	Outcome   string `json:"outcome"` // success/failure
+	LatencyMs int64  `json:"latency_ms"`
+	Payload   string `json:"payload,omitempty"`
+	Response  string `json:"response,omitempty"`
+}
+
+// AuditQuery 审计记录查询条件
+type AuditQuery struct {
+	Since    time.Time
+	Path     string
+	ClientIP string
+	Limit    int
+}
+
+// auditWriteMu 保证审计记录逐条完整追加
+var auditWriteMu sync.Mutex
+
+// auditResponseWriter 记录响应内容前缀的ResponseWriter
+type auditResponseWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+// Write 写入响应的同时保留前若干字节
+func (w *auditResponseWriter) Write(data []byte) (int, error) {
+	if remain := auditMaxResponseSize - w.body.Len(); remain > 0 {
+		if len(data) < remain {
+			remain = len(data)
+		}
+		w.body.Write(data[:remain])
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// AuditMiddleware 审计中间件，记录变更类接口的调用方、请求摘要和处理结果
+func AuditMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		var body []byte
+		if c.Request.Body != nil {
+			body, _ = io.ReadAll(c.Request.Body)
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		writer := &auditResponseWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		c.Next()
+
+		status := c.Writer.Status()
+		outcome := "success"
+		if status >= http.StatusBadRequest {
+			outcome = "failure"
+		}
+
+		WriteAuditEntry(AuditEntry{
+			Time:      start.Format(time.RFC3339),
+			RequestID: GetRequestID(c),
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.Path,
+			ClientIP:  getClientIP(c),
+			Subject:   GetAuthSubject(c),
+			Status:    status,
+			Outcome:   outcome,
+			LatencyMs: time.Since(start).Milliseconds(),
+			Payload:   summarizeAuditPayload(body),
+			Response:  writer.body.String(),
+		})
+	}
+}
+
+// WriteAuditEntry 追加一条审计记录（JSON Lines格式）
+func WriteAuditEntry(entry AuditEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		AppLogger.Error("序列化审计记录失败:", err)
+		return
+	}
+
+	auditFile := config.AppConfig.GetAuditFile()
+
+	auditWriteMu.Lock()
+	defer auditWriteMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(auditFile), 0755); err != nil {
+		AppLogger.Error("创建审计日志目录失败:", err)
+		return
+	}
+	file, err := os.OpenFile(auditFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		AppLogger.Error("打开审计日志文件失败:", err)
+		return
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		AppLogger.Error("写入审计日志失败:", err)
+	}
+}
+
+// QueryAuditEntries 查询审计记录，按时间从新到旧返回
+func QueryAuditEntries(query AuditQuery) ([]AuditEntry, error) {
+	file, err := os.Open(config.AppConfig.GetAuditFile())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []AuditEntry{}, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if !query.Since.IsZero() {
+			if t, err := time.Parse(time.RFC3339, entry.Time); err == nil && t.Before(query.Since) {
+				continue
+			}
+		}
+		if query.Path != "" && entry.Path != query.Path {
+			continue
+		}
+		if query.ClientIP != "" && entry.ClientIP != query.ClientIP {
+			continue
+		}
+		entries = append(entries, entry)
+		// 只保留最新的limit条
+		if query.Limit > 0 && len(entries) > query.Limit {
+			entries = entries[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// 倒序，最新的在前
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	if entries == nil {
+		entries = []AuditEntry{}
+	}
+	return entries, nil
+}
+
+// summarizeAuditPayload 生成请求摘要：JSON对象逐字段截断并脱敏，其他内容直接截断
+func summarizeAuditPayload(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return truncateString(string(body), auditMaxPayloadSize)
+	}
+
+	for key, value := range fields {
+		if isSensitiveAuditKey(key) {
+			if value != nil && value != "" {
+				fields[key] = "***"
+			}
+			continue
+		}
+		if text, ok := value.(string); ok {
+			fields[key] = truncateString(text, auditMaxFieldSize)
+		}
+	}
+
+	data, _ := json.Marshal(fields)
+	return truncateString(string(data), auditMaxPayloadSize)
+}
+
+// isSensitiveAuditKey 判断字段是否需要脱敏
+func isSensitiveAuditKey(key string) bool {
+	lower := strings.ToLower(key)
+	for _, keyword := range auditSensitiveKeys {
+		if strings.Contains(lower, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// truncateString 按字符截断字符串
+func truncateString(s string, maxLen int) string {
+	runes := []rune(s)
+	if len(runes) <= maxLen {
+		return s
+	}
+	return string(runes[:maxLen]) + "..."
+}
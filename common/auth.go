@@ -0,0 +1,217 @@
+package common
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"cicd-agent/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 接口权限范围
+const (
+	ScopeDeploy = "deploy" // 触发部署：/update、/callback
+	ScopeCancel = "cancel" // 取消任务
+	ScopeLogs   = "logs"   // 日志查看与检索
+	ScopeAdmin  = "admin"  // 管理接口，拥有全部权限
+)
+
+// authSubjectKey gin上下文中保存调用方身份的键
+const authSubjectKey = "auth_subject"
+
+// logTicketRequiredKey gin上下文中标记日志查看请求未携带凭证、需以一次性加密参数作为凭证的键
+const logTicketRequiredKey = "log_ticket_required"
+
+// authPrincipal 认证通过的调用方
+type authPrincipal struct {
+	Subject string
+	Scopes  []string
+}
+
+// hasScope 是否拥有指定权限（admin拥有全部权限）
+func (p *authPrincipal) hasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireScope 令牌认证中间件，auth.enable开启时要求请求携带具有指定权限的API Key或JWT
+// 凭证只通过 Authorization: Bearer、X-API-Key 头传递，不接受查询参数（避免凭证写入访问日志和代理日志）
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !config.AppConfig.Auth.Enable {
+			c.Next()
+			return
+		}
+
+		principal, err := authenticateRequest(c)
+		if err != nil {
+			RequestLogger(c).Warning("接口认证失败:", err, " IP:", getClientIP(c))
+			c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "认证失败"})
+			c.Abort()
+			return
+		}
+		if !principal.hasScope(scope) {
+			RequestLogger(c).Warning(fmt.Sprintf("调用方 %s 缺少权限 %s", principal.Subject, scope))
+			c.JSON(http.StatusForbidden, gin.H{"code": 403, "msg": "权限不足"})
+			c.Abort()
+			return
+		}
+
+		c.Set(authSubjectKey, principal.Subject)
+		c.Next()
+	}
+}
+
+// RequireScopeOrLogTicket 日志查看（WebSocket/SSE）认证中间件：携带凭证时与RequireScope相同；
+// 浏览器无法为WebSocket设置请求头，未携带凭证时要求加密参数带有nonce和时间戳（由已认证的log-token接口签发，一次性且短期有效）
+func RequireScopeOrLogTicket(scope string) gin.HandlerFunc {
+	requireScope := RequireScope(scope)
+	return func(c *gin.Context) {
+		if config.AppConfig.Auth.Enable && extractToken(c) == "" {
+			c.Set(logTicketRequiredKey, true)
+			c.Next()
+			return
+		}
+		requireScope(c)
+	}
+}
+
+// GetAuthSubject 获取认证通过的调用方名称
+func GetAuthSubject(c *gin.Context) string {
+	return c.GetString(authSubjectKey)
+}
+
+// hasAdminCredential 请求是否携带admin权限的凭证（供管理接口与IP白名单二选一）
+func hasAdminCredential(c *gin.Context) bool {
+	if !config.AppConfig.Auth.Enable {
+		return false
+	}
+	principal, err := authenticateRequest(c)
+	if err != nil || !principal.hasScope(ScopeAdmin) {
+		return false
+	}
+	c.Set(authSubjectKey, principal.Subject)
+	return true
+}
+
+// extractToken 从请求中提取凭证
+func extractToken(c *gin.Context) string {
+	if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	return c.GetHeader("X-API-Key")
+}
+
+// authenticateRequest 校验请求凭证：含两个'.'的按JWT处理，否则按API Key处理
+func authenticateRequest(c *gin.Context) (*authPrincipal, error) {
+	token := extractToken(c)
+	if token == "" {
+		return nil, fmt.Errorf("缺少认证凭证")
+	}
+	if strings.Count(token, ".") == 2 {
+		return verifyJWT(token)
+	}
+	return verifyAPIKey(token)
+}
+
+// verifyAPIKey 校验静态API Key
+func verifyAPIKey(token string) (*authPrincipal, error) {
+	for _, apiKey := range config.AppConfig.Auth.APIKeys {
+		if apiKey.Key != "" && subtle.ConstantTimeCompare([]byte(token), []byte(apiKey.Key)) == 1 {
+			return &authPrincipal{Subject: apiKey.Name, Scopes: apiKey.Scopes}, nil
+		}
+	}
+	return nil, fmt.Errorf("无效的API Key")
+}
+
+// jwtClaims 支持的JWT声明
+type jwtClaims struct {
+	Subject   string          `json:"sub"`
+	Issuer    string          `json:"iss"`
+	ExpiresAt int64           `json:"exp"`
+	NotBefore int64           `json:"nbf"`
+	Scope     json.RawMessage `json:"scope"`  // 空格分隔的字符串或字符串数组
+	Scopes    []string        `json:"scopes"` // 字符串数组
+}
+
+// verifyJWT 校验HS256签名的JWT
+func verifyJWT(token string) (*authPrincipal, error) {
+	jwtConfig := config.AppConfig.Auth.JWT
+	if jwtConfig.Secret == "" {
+		return nil, fmt.Errorf("未配置JWT密钥")
+	}
+
+	parts := strings.Split(token, ".")
+	headerData, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("JWT头解码失败: %v", err)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(headerData, &header); err != nil {
+		return nil, fmt.Errorf("JWT头解析失败: %v", err)
+	}
+	if header.Alg != "HS256" {
+		return nil, fmt.Errorf("不支持的JWT算法: %s", header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("JWT签名解码失败: %v", err)
+	}
+	mac := hmac.New(sha256.New, []byte(jwtConfig.Secret))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, fmt.Errorf("JWT签名无效")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("JWT载荷解码失败: %v", err)
+	}
+	var claims jwtClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("JWT载荷解析失败: %v", err)
+	}
+
+	now := time.Now().Unix()
+	if claims.ExpiresAt == 0 || now >= claims.ExpiresAt {
+		return nil, fmt.Errorf("JWT已过期")
+	}
+	if claims.NotBefore != 0 && now < claims.NotBefore {
+		return nil, fmt.Errorf("JWT尚未生效")
+	}
+	if jwtConfig.Issuer != "" && claims.Issuer != jwtConfig.Issuer {
+		return nil, fmt.Errorf("JWT签发者不匹配: %s", claims.Issuer)
+	}
+
+	scopes := append([]string(nil), claims.Scopes...)
+	if len(claims.Scope) > 0 {
+		var scopeString string
+		var scopeList []string
+		if err := json.Unmarshal(claims.Scope, &scopeString); err == nil {
+			scopes = append(scopes, strings.Fields(scopeString)...)
+		} else if err := json.Unmarshal(claims.Scope, &scopeList); err == nil {
+			scopes = append(scopes, scopeList...)
+		}
+	}
+
+	subject := claims.Subject
+	if subject == "" {
+		subject = "jwt"
+	}
+	return &authPrincipal{Subject: subject, Scopes: scopes}, nil
+}
@@ -0,0 +1,98 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"cicd-agent/config"
+)
+
+// bandwidthShaper 镜像传输限速：第一个传输开始时在网卡上添加tc规则，最后一个传输结束时移除
+// 出方向（推送）使用tbf整形，入方向（拉取）使用ingress policing丢弃超速流量
+type bandwidthShaper struct {
+	mu     sync.Mutex
+	users  int
+	device string // 已添加规则的网卡，为空表示未生效
+}
+
+var transferShaper = &bandwidthShaper{}
+
+// AcquireBandwidthLimit 镜像传输开始前调用，启用配置的带宽限制，返回释放函数
+// tc规则添加失败只记录警告，不影响部署
+func AcquireBandwidthLimit(ctx context.Context, taskLogger *TaskLogger, stepType string) func() {
+	if !config.AppConfig.BandwidthLimitEnabled() {
+		return func() {}
+	}
+
+	transferShaper.mu.Lock()
+	transferShaper.users++
+	if transferShaper.device == "" {
+		transfer := config.AppConfig.Deployment.Transfer
+		if err := applyBandwidthLimit(ctx, transfer.Interface, transfer.BandwidthLimit, config.AppConfig.GetBandwidthBurst()); err != nil {
+			AppLogger.Warning("启用镜像传输限速失败:", err)
+			taskLogger.WriteStep(stepType, "WARNING", fmt.Sprintf("启用镜像传输限速失败，将不限速传输: %v", err))
+		} else {
+			transferShaper.device = transfer.Interface
+			AppLogger.Info(fmt.Sprintf("已启用镜像传输限速: 网卡=%s, 速率=%s", transfer.Interface, transfer.BandwidthLimit))
+		}
+	}
+	if transferShaper.device != "" {
+		taskLogger.WriteStep(stepType, "INFO", fmt.Sprintf("镜像传输限速: %s", config.AppConfig.Deployment.Transfer.BandwidthLimit))
+	}
+	transferShaper.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(transferShaper.release)
+	}
+}
+
+// release 减少引用，最后一个传输结束时移除限速规则
+func (s *bandwidthShaper) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users--
+	if s.users > 0 || s.device == "" {
+		return
+	}
+	if err := removeBandwidthLimit(context.Background(), s.device); err != nil {
+		AppLogger.Warning("移除镜像传输限速失败:", err)
+	} else {
+		AppLogger.Info(fmt.Sprintf("已移除镜像传输限速: 网卡=%s", s.device))
+	}
+	s.device = ""
+}
+
+// applyBandwidthLimit 添加出入方向的tc限速规则
+func applyBandwidthLimit(ctx context.Context, device, rate, burst string) error {
+	commands := []Command{
+		NewCommand("tc", "qdisc", "replace", "dev", device, "root", "tbf", "rate", rate, "burst", burst, "latency", "400ms"),
+		NewCommand("tc", "qdisc", "replace", "dev", device, "handle", "ffff:", "ingress"),
+		NewCommand("tc", "filter", "replace", "dev", device, "parent", "ffff:", "protocol", "ip", "prio", "1",
+			"u32", "match", "u32", "0", "0", "police", "rate", rate, "burst", burst, "drop", "flowid", ":1"),
+	}
+	for _, cmd := range commands {
+		if output, err := RunCommand(ctx, cmd); err != nil {
+			// 部分规则已添加时回滚，避免网卡残留限速
+			removeBandwidthLimit(context.Background(), device)
+			return fmt.Errorf("%s 执行失败: %v, 输出: %s", cmd.String(), err, string(output))
+		}
+	}
+	return nil
+}
+
+// removeBandwidthLimit 删除限速规则
+func removeBandwidthLimit(ctx context.Context, device string) error {
+	var lastErr error
+	for _, cmd := range []Command{
+		NewCommand("tc", "qdisc", "del", "dev", device, "root"),
+		NewCommand("tc", "qdisc", "del", "dev", device, "ingress"),
+	} {
+		if output, err := RunCommand(ctx, cmd); err != nil {
+			lastErr = fmt.Errorf("%s 执行失败: %v, 输出: %s", cmd.String(), err, string(output))
+		}
+	}
+	return lastErr
+}
@@ -5,31 +5,74 @@ import (
 	"sync"
 )
 
+// taskContext 执行中任务的上下文
+type taskContext struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
 // 任务取消管理器
 var (
 	taskCtxMu  sync.Mutex
-	taskCtxMap = make(map[string]context.CancelFunc)
+	taskCtxMap = make(map[string]taskContext)
 )
 
 // CreateTaskContext 为任务创建可取消上下文
 func CreateTaskContext(taskID string) (context.Context, context.CancelFunc) {
 	ctx, cancel := context.WithCancel(context.Background())
 	taskCtxMu.Lock()
-	taskCtxMap[taskID] = cancel
+	taskCtxMap[taskID] = taskContext{ctx: ctx, cancel: cancel}
 	taskCtxMu.Unlock()
 	return ctx, cancel
 }
 
-// CancelTask 取消指定任务
-func CancelTask(taskID string) bool {
+// TryCreateTaskContext 任务没有上下文时为其创建可取消上下文，已存在（任务执行中）时返回false
+// 检查和创建在同一把锁内完成，并发请求中只有一个能取得任务
+func TryCreateTaskContext(taskID string) (context.Context, context.CancelFunc, bool) {
+	taskCtxMu.Lock()
+	defer taskCtxMu.Unlock()
+	if _, ok := taskCtxMap[taskID]; ok {
+		return nil, nil, false
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	taskCtxMap[taskID] = taskContext{ctx: ctx, cancel: cancel}
+	return ctx, cancel, true
+}
+
+// TaskContext 获取任务的上下文，任务未在执行时返回context.Background()
+// 任务执行期间发出的网络请求应使用它，取消任务时请求随之中止
+func TaskContext(taskID string) context.Context {
 	taskCtxMu.Lock()
 	defer taskCtxMu.Unlock()
-	if cancel, ok := taskCtxMap[taskID]; ok {
-		cancel()
-		delete(taskCtxMap, taskID)
-		return true
+	if task, ok := taskCtxMap[taskID]; ok {
+		return task.ctx
 	}
-	return false
+	return context.Background()
+}
+
+// notifyContext 任务通知使用的上下文：任务执行中使用任务上下文，取消任务时中止正在发送的请求；
+// 任务已取消或已结束时返回context.Background()，保证取消、失败等最终状态仍能发出
+func notifyContext(taskID string) context.Context {
+	ctx := TaskContext(taskID)
+	if ctx.Err() != nil {
+		return context.Background()
+	}
+	return ctx
+}
+
+// CancelTask 取消指定任务，同时解除任务的暂停状态
+// 上下文保留到任务执行CleanupTask为止：取消后任务仍在恢复部署目录、回滚配置，期间仍视为执行中
+func CancelTask(taskID string) bool {
+	taskCtxMu.Lock()
+	task, ok := taskCtxMap[taskID]
+	if ok {
+		task.cancel()
+	}
+	taskCtxMu.Unlock()
+	if ok {
+		clearTaskPause(taskID)
+	}
+	return ok
 }
 
 // CleanupTask 在任务完成后清理
@@ -37,4 +80,33 @@ func CleanupTask(taskID string) {
 	taskCtxMu.Lock()
 	delete(taskCtxMap, taskID)
 	taskCtxMu.Unlock()
+
+	stepStartTimes.clear(taskID)
+	clearTaskPause(taskID)
+}
+
+// IsTaskRunning 判断任务是否仍在执行
+func IsTaskRunning(taskID string) bool {
+	taskCtxMu.Lock()
+	defer taskCtxMu.Unlock()
+	_, ok := taskCtxMap[taskID]
+	return ok
+}
+
+// RunningTaskIDs 获取正在执行的任务ID
+func RunningTaskIDs() []string {
+	taskCtxMu.Lock()
+	defer taskCtxMu.Unlock()
+	ids := make([]string, 0, len(taskCtxMap))
+	for taskID := range taskCtxMap {
+		ids = append(ids, taskID)
+	}
+	return ids
+}
+
+// RunningTaskCount 获取正在执行的任务数
+func RunningTaskCount() int {
+	taskCtxMu.Lock()
+	defer taskCtxMu.Unlock()
+	return len(taskCtxMap)
 }
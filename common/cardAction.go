@@ -0,0 +1,325 @@
+package common
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"cicd-agent/config"
+)
+
+// 飞书卡片按钮动作
+const (
+	CardActionApprove  = "approve"  // 批准流量切换
+	CardActionReject   = "reject"   // 拒绝流量切换
+	CardActionRollback = "rollback" // 回滚到上一个成功的版本
+	CardActionRetry    = "retry"    // 重新执行任务
+)
+
+// CardAction 卡片按钮携带的动作，签名后写入按钮value，点击时原样回传
+type CardAction struct {
+	Action  string
+	TaskID  string
+	Project string
+	Expires int64 // 按钮过期时间（Unix秒）
+}
+
+// CardActionHandler 卡片动作处理函数，返回展示给操作人的提示
+type CardActionHandler func(action CardAction, operator string) (string, error)
+
+// cardActionHandlers 动作 -> 处理函数（回滚、重试由任务中心注册）
+var cardActionHandlers sync.Map
+
+// RegisterCardActionHandler 注册卡片动作处理函数
+func RegisterCardActionHandler(action string, handler CardActionHandler) {
+	cardActionHandlers.Store(action, handler)
+}
+
+// usedCardActions 已执行的卡片按钮：签名 -> 按钮过期时间，同一按钮（含重复点击和重放的回调）只能执行一次
+var usedCardActions = struct {
+	mu   sync.Mutex
+	sigs map[string]int64
+}{sigs: make(map[string]int64)}
+
+// claimCardAction 登记按钮已执行，已登记过时返回false；顺带清理已过期的记录
+func claimCardAction(sig string, expires int64) bool {
+	now := time.Now().Unix()
+	usedCardActions.mu.Lock()
+	defer usedCardActions.mu.Unlock()
+	for used, usedExpires := range usedCardActions.sigs {
+		if now > usedExpires {
+			delete(usedCardActions.sigs, used)
+		}
+	}
+	if _, ok := usedCardActions.sigs[sig]; ok {
+		return false
+	}
+	usedCardActions.sigs[sig] = expires
+	return true
+}
+
+// releaseCardAction 按钮对应的操作未能执行时撤销登记，操作人可再次点击
+func releaseCardAction(sig string) {
+	usedCardActions.mu.Lock()
+	defer usedCardActions.mu.Unlock()
+	delete(usedCardActions.sigs, sig)
+}
+
+// cardActionValue 生成签名后的按钮value
+func cardActionValue(action, taskID, project string) map[string]string {
+	a := CardAction{
+		Action:  action,
+		TaskID:  taskID,
+		Project: project,
+		Expires: time.Now().Add(config.AppConfig.GetCardActionTokenTTL()).Unix(),
+	}
+	return map[string]string{
+		"action":  a.Action,
+		"task_id": a.TaskID,
+		"project": a.Project,
+		"expires": strconv.FormatInt(a.Expires, 10),
+		"sig":     signCardAction(a),
+	}
+}
+
+// signCardAction 计算按钮签名：HMAC-SHA256(secret, 动作|任务ID|项目|过期时间)
+func signCardAction(a CardAction) string {
+	mac := hmac.New(sha256.New, []byte(config.AppConfig.Notification.CardActions.Secret))
+	fmt.Fprintf(mac, "%s|%s|%s|%d", a.Action, a.TaskID, a.Project, a.Expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// parseCardAction 校验按钮value的签名和有效期
+func parseCardAction(value map[string]string) (CardAction, error) {
+	expires, err := strconv.ParseInt(value["expires"], 10, 64)
+	if err != nil {
+		return CardAction{}, fmt.Errorf("按钮参数格式错误")
+	}
+	a := CardAction{
+		Action:  value["action"],
+		TaskID:  value["task_id"],
+		Project: value["project"],
+		Expires: expires,
+	}
+	if !hmac.Equal([]byte(signCardAction(a)), []byte(value["sig"])) {
+		return CardAction{}, fmt.Errorf("按钮签名校验失败")
+	}
+	if time.Now().Unix() > a.Expires {
+		return CardAction{}, fmt.Errorf("按钮已过期")
+	}
+	return a, nil
+}
+
+// VerifyFeishuToken 校验飞书回调携带的Verification Token（未配置时一律拒绝）
+func VerifyFeishuToken(token string) bool {
+	expected := config.AppConfig.Notification.CardActions.VerificationToken
+	return expected != "" && hmac.Equal([]byte(token), []byte(expected))
+}
+
+// HandleCardAction 校验卡片按钮并执行对应动作，operator为飞书用户open_id
+func HandleCardAction(value map[string]string, operator string) (string, error) {
+	if !config.AppConfig.CardActionsEnabled() {
+		return "", fmt.Errorf("未启用卡片交互")
+	}
+	if !config.AppConfig.IsCardActionOperator(operator) {
+		return "", fmt.Errorf("无权限执行该操作")
+	}
+
+	action, err := parseCardAction(value)
+	if err != nil {
+		return "", err
+	}
+	AppLogger.Info(fmt.Sprintf("收到卡片操作: 动作=%s, 任务ID=%s, 项目=%s, 操作人=%s",
+		action.Action, action.TaskID, action.Project, operator))
+
+	sig := value["sig"]
+	if !claimCardAction(sig, action.Expires) {
+		return "", fmt.Errorf("该按钮的操作已执行过")
+	}
+	msg, err := runCardAction(action, operator)
+	if err != nil {
+		releaseCardAction(sig)
+	}
+	return msg, err
+}
+
+// runCardAction 执行卡片按钮对应的动作
+func runCardAction(action CardAction, operator string) (string, error) {
+	switch action.Action {
+	case CardActionApprove:
+		return resolveApproval(action, true, operator)
+	case CardActionReject:
+		return resolveApproval(action, false, operator)
+	}
+
+	handler, ok := cardActionHandlers.Load(action.Action)
+	if !ok {
+		return "", fmt.Errorf("不支持的操作: %s", action.Action)
+	}
+	return handler.(CardActionHandler)(action, operator)
+}
+
+// approvalResult 审批结果
+type approvalResult struct {
+	approved bool
+	operator string
+}
+
+// pendingApprovals 等待审批的任务：任务ID -> 结果通道
+var pendingApprovals = struct {
+	mu    sync.Mutex
+	tasks map[string]chan approvalResult
+}{tasks: make(map[string]chan approvalResult)}
+
+// RequestTrafficApproval 发送流量切换审批卡片并等待处理
+// 批准时返回nil；拒绝、超时或任务被取消时返回错误
+func RequestTrafficApproval(ctx context.Context, webhookURL, taskID, project, tag, projectName string) error {
+	if webhookURL == "" {
+		return fmt.Errorf("未配置飞书通知地址，无法发起审批")
+	}
+
+	result := make(chan approvalResult, 1)
+	pendingApprovals.mu.Lock()
+	pendingApprovals.tasks[taskID] = result
+	pendingApprovals.mu.Unlock()
+	defer func() {
+		pendingApprovals.mu.Lock()
+		delete(pendingApprovals.tasks, taskID)
+		pendingApprovals.mu.Unlock()
+	}()
+
+	timeout := config.AppConfig.GetApprovalTimeout()
+	if err := sendApprovalCard(ctx, webhookURL, taskID, project, tag, projectName, timeout); err != nil {
+		return fmt.Errorf("发送审批卡片失败: %v", err)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case r := <-result:
+		if !r.approved {
+			return fmt.Errorf("流量切换被拒绝（操作人: %s）", r.operator)
+		}
+		AppLogger.Info(fmt.Sprintf("流量切换已批准: 任务ID=%s, 操作人=%s", taskID, r.operator))
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("等待审批超时（%s）", timeout)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// resolveApproval 处理审批按钮
+func resolveApproval(action CardAction, approved bool, operator string) (string, error) {
+	return ResolveTrafficApproval(action.TaskID, approved, operator)
+}
+
+// IsAwaitingApproval 任务是否正在等待流量切换审批
+func IsAwaitingApproval(taskID string) bool {
+	pendingApprovals.mu.Lock()
+	defer pendingApprovals.mu.Unlock()
+	_, ok := pendingApprovals.tasks[taskID]
+	return ok
+}
+
+// ResolveTrafficApproval 批准或拒绝任务等待中的流量切换审批（飞书卡片按钮和管理界面共用）
+func ResolveTrafficApproval(taskID string, approved bool, operator string) (string, error) {
+	pendingApprovals.mu.Lock()
+	result, ok := pendingApprovals.tasks[taskID]
+	if ok {
+		delete(pendingApprovals.tasks, taskID)
+	}
+	pendingApprovals.mu.Unlock()
+
+	if !ok {
+		return "", fmt.Errorf("任务没有等待中的审批（可能已处理或已超时）")
+	}
+	result <- approvalResult{approved: approved, operator: operator}
+
+	if approved {
+		return T("已批准，开始切换流量"), nil
+	}
+	return T("已拒绝流量切换"), nil
+}
+
+// sendApprovalCard 发送流量切换审批卡片
+func sendApprovalCard(ctx context.Context, webhookURL, taskID, project, tag, projectName string, timeout time.Duration) error {
+	name := projectName
+	if name == "" {
+		name = project
+	}
+
+	card := FeishuCardMessage{
+		MsgType: "interactive",
+		Card: FeishuCard{
+			Config: FeishuCardConfig{WideScreenMode: true},
+			Header: FeishuCardHeader{
+				Title:    FeishuText{Content: Tf("⏸️ %s 等待流量切换审批", name), Tag: "plain_text"},
+				Template: "orange",
+			},
+			Elements: []FeishuElement{
+				FeishuFieldSet{
+					Tag: "div",
+					Fields: []FeishuField{
+						feishuShortField(T("项目"), project),
+						feishuShortField(T("版本"), tag),
+					},
+				},
+				FeishuTextBlock{
+					Tag: "div",
+					Text: FeishuText{
+						Content: Tf("新版本已就绪，请确认是否切换流量（%s内未处理视为拒绝）", timeout),
+						Tag:     "lark_md",
+					},
+				},
+				FeishuActionBlock{
+					Tag: "action",
+					Actions: []FeishuButton{
+						feishuActionButton(T("批准切换"), "primary", CardActionApprove, taskID, project, nil),
+						feishuActionButton(T("拒绝"), "danger", CardActionReject, taskID, project, nil),
+					},
+				},
+			},
+		},
+	}
+	return postFeishuMessage(ctx, webhookURL, card)
+}
+
+// taskCardActions 任务结果卡片上的操作按钮：失败/取消可重试，成功可回滚
+func taskCardActions(taskID, project, status string) (FeishuElement, bool) {
+	if !config.AppConfig.CardActionsEnabled() || taskID == "" {
+		return nil, false
+	}
+
+	var buttons []FeishuButton
+	switch status {
+	case "failed", "cancel":
+		buttons = append(buttons, feishuActionButton(T("重试任务"), "primary", CardActionRetry, taskID, project, nil))
+	case "complete":
+		buttons = append(buttons, feishuActionButton(T("回滚"), "danger", CardActionRollback, taskID, project,
+			&FeishuConfirm{
+				Title: FeishuText{Content: T("确认回滚"), Tag: "plain_text"},
+				Text:  FeishuText{Content: T("将重新部署该项目上一个成功的版本，是否继续？"), Tag: "plain_text"},
+			}))
+	default:
+		return nil, false
+	}
+	return FeishuActionBlock{Tag: "action", Actions: buttons}, true
+}
+
+// feishuActionButton 构建带签名value的按钮
+func feishuActionButton(text, buttonType, action, taskID, project string, confirm *FeishuConfirm) FeishuButton {
+	return FeishuButton{
+		Tag:     "button",
+		Text:    FeishuText{Content: text, Tag: "plain_text"},
+		Type:    buttonType,
+		Value:   cardActionValue(action, taskID, project),
+		Confirm: confirm,
+	}
+}
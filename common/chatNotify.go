@@ -0,0 +1,40 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"cicd-agent/config"
+)
+
+// robotResponse 钉钉/企业微信机器人接口响应
+type robotResponse struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+// chatChannelWebhook 获取项目在渠道中的机器人地址，未启用或不需要发送该状态时返回空
+// 失败、取消事件在配置了incident_webhook时发送到告警群
+func chatChannelWebhook(channel config.ChatChannelConfig, project, status string) string {
+	if (status == "running" || status == "queued") && !channel.NotifyStart {
+		return ""
+	}
+	webhook := channel.WebhookFor(project)
+	if webhook != "" && channel.IncidentWebhook != "" && EventSeverity(status) == SeverityCritical {
+		return channel.IncidentWebhook
+	}
+	return webhook
+}
+
+// checkRobotResponse 检查机器人接口响应（业务错误同样返回200，需要检查errcode）
+func checkRobotResponse(resp *HTTPResponse) error {
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("响应异常，状态码: %d", resp.StatusCode)
+	}
+	var result robotResponse
+	if err := json.Unmarshal(resp.Body, &result); err == nil && result.ErrCode != 0 {
+		return fmt.Errorf("返回错误: %d %s", result.ErrCode, result.ErrMsg)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package common
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"cicd-agent/config"
+)
+
+// commandWaitDelay 命令结束后等待输出管道关闭的最长时间（防止子进程遗留的孙进程占用管道导致阻塞）
+const commandWaitDelay = 5 * time.Second
+
+// Command 待执行的外部命令
+type Command struct {
+	Name    string
+	Args    []string
+	Dir     string        // 工作目录，为空时使用当前目录
+	Env     []string      // 追加的环境变量（KEY=VALUE），在配置的command_env之后生效
+	Timeout time.Duration // 超时时间，为0时使用deployment.command_timeout
+}
+
+// NewCommand 创建外部命令
+func NewCommand(name string, args ...string) Command {
+	return Command{Name: name, Args: args}
+}
+
+// String 命令行文本，用于写入任务日志
+func (c Command) String() string {
+	return strings.Join(append([]string{c.Name}, c.Args...), " ")
+}
+
+// CommandRunner 外部命令执行器，步骤通过它调用kubectl/docker/ssh等命令，便于测试时替换
+type CommandRunner interface {
+	// Run 执行命令，返回合并后的标准输出和标准错误
+	Run(ctx context.Context, cmd Command) ([]byte, error)
+	// RunStream 执行命令，输出（标准输出和标准错误）逐行交给onLine
+	RunStream(ctx context.Context, cmd Command, onLine func(line string)) error
+}
+
+var (
+	commandRunnerMu sync.RWMutex
+	commandRunner   CommandRunner = ExecRunner{}
+)
+
+// SetCommandRunner 替换全局命令执行器，返回原执行器（测试中用于恢复）
+func SetCommandRunner(runner CommandRunner) CommandRunner {
+	commandRunnerMu.Lock()
+	defer commandRunnerMu.Unlock()
+	previous := commandRunner
+	commandRunner = runner
+	return previous
+}
+
+// Runner 获取全局命令执行器
+func Runner() CommandRunner {
+	commandRunnerMu.RLock()
+	defer commandRunnerMu.RUnlock()
+	return commandRunner
+}
+
+// RunCommand 使用全局执行器执行命令
+func RunCommand(ctx context.Context, cmd Command) ([]byte, error) {
+	return Runner().Run(ctx, cmd)
+}
+
+// RunCommandStream 使用全局执行器执行命令并逐行处理输出
+func RunCommandStream(ctx context.Context, cmd Command, onLine func(line string)) error {
+	return Runner().RunStream(ctx, cmd, onLine)
+}
+
+// ExecRunner 基于os/exec的命令执行器
+type ExecRunner struct{}
+
+// Run 执行命令，返回合并输出
+func (r ExecRunner) Run(ctx context.Context, cmd Command) ([]byte, error) {
+	runCtx, cancel, timeout := commandContext(ctx, cmd)
+	defer cancel()
+
+	output, err := r.build(runCtx, cmd).CombinedOutput()
+	return output, commandError(ctx, runCtx, timeout, err)
+}
+
+// RunStream 执行命令，逐行回调输出
+func (r ExecRunner) RunStream(ctx context.Context, cmd Command, onLine func(line string)) error {
+	runCtx, cancel, timeout := commandContext(ctx, cmd)
+	defer cancel()
+
+	reader, writer := io.Pipe()
+	execCmd := r.build(runCtx, cmd)
+	execCmd.Stdout = writer
+	execCmd.Stderr = writer
+	if err := execCmd.Start(); err != nil {
+		return fmt.Errorf("启动命令失败: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		err := execCmd.Wait()
+		writer.Close()
+		done <- err
+	}()
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		onLine(scanner.Text())
+	}
+	// 单行过长导致扫描中断时丢弃剩余输出，避免命令写管道阻塞
+	io.Copy(io.Discard, reader)
+
+	return commandError(ctx, runCtx, timeout, <-done)
+}
+
+// build 构建exec.Cmd（环境变量：当前进程 + command_env + 命令自带）
+func (r ExecRunner) build(ctx context.Context, cmd Command) *exec.Cmd {
+	execCmd := exec.CommandContext(ctx, cmd.Name, cmd.Args...)
+	execCmd.Dir = cmd.Dir
+	execCmd.WaitDelay = commandWaitDelay
+	if env := append(config.AppConfig.GetCommandEnv(), cmd.Env...); len(env) > 0 {
+		execCmd.Env = append(os.Environ(), env...)
+	}
+	return execCmd
+}
+
+// commandContext 为命令附加超时
+func commandContext(ctx context.Context, cmd Command) (context.Context, context.CancelFunc, time.Duration) {
+	timeout := cmd.Timeout
+	if timeout <= 0 {
+		timeout = config.AppConfig.GetCommandTimeout()
+	}
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	return runCtx, cancel, timeout
+}
+
+// commandError 区分超时错误：仅命令自身超时时改写错误，调用方取消时保留原错误由调用方判断ctx
+func commandError(ctx, runCtx context.Context, timeout time.Duration, err error) error {
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() == nil && errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("命令执行超时(%s): %v", timeout, err)
+	}
+	return err
+}
@@ -0,0 +1,84 @@
+package common
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// FakeResult 模拟命令的执行结果
+type FakeResult struct {
+	Output []byte
+	Err    error
+}
+
+// FakeRunner 测试用命令执行器：记录所有调用，按命令行前缀返回预设结果，不执行真实命令
+type FakeRunner struct {
+	mu        sync.Mutex
+	calls     []Command
+	responses []fakeResponse
+	Default   FakeResult // 未匹配任何预设时返回的结果
+}
+
+// fakeResponse 命令行前缀 -> 结果
+type fakeResponse struct {
+	prefix string
+	result FakeResult
+}
+
+// NewFakeRunner 创建模拟执行器
+func NewFakeRunner() *FakeRunner {
+	return &FakeRunner{}
+}
+
+// On 预设命令结果，命令行（Command.String()）以prefix开头时命中，先注册的优先
+func (f *FakeRunner) On(prefix string, output string, err error) *FakeRunner {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses = append(f.responses, fakeResponse{prefix: prefix, result: FakeResult{Output: []byte(output), Err: err}})
+	return f
+}
+
+// Calls 返回已执行的命令
+func (f *FakeRunner) Calls() []Command {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Command(nil), f.calls...)
+}
+
+// Run 记录调用并返回预设结果
+func (f *FakeRunner) Run(ctx context.Context, cmd Command) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	result := f.match(cmd)
+	return result.Output, result.Err
+}
+
+// RunStream 记录调用并逐行回调预设输出
+func (f *FakeRunner) RunStream(ctx context.Context, cmd Command, onLine func(line string)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	result := f.match(cmd)
+	if output := strings.TrimRight(string(result.Output), "\n"); output != "" {
+		for _, line := range strings.Split(output, "\n") {
+			onLine(line)
+		}
+	}
+	return result.Err
+}
+
+// match 记录调用并查找预设结果
+func (f *FakeRunner) match(cmd Command) FakeResult {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, cmd)
+	line := cmd.String()
+	for _, response := range f.responses {
+		if strings.HasPrefix(line, response.prefix) {
+			return response.result
+		}
+	}
+	return f.Default
+}
@@ -0,0 +1,171 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"cicd-agent/config"
+)
+
+// requireShell 真实命令测试依赖sh，不存在时跳过
+func requireShell(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("未找到sh，跳过真实命令测试")
+	}
+}
+
+func TestExecRunnerTimeout(t *testing.T) {
+	requireShell(t)
+
+	tests := []struct {
+		name          string
+		configTimeout string
+		cmdTimeout    time.Duration
+		cancelParent  bool
+		wantErr       bool
+		wantTimeout   bool
+	}{
+		{name: "命令自带超时", cmdTimeout: 100 * time.Millisecond, wantErr: true, wantTimeout: true},
+		{name: "配置默认超时", configTimeout: "100ms", wantErr: true, wantTimeout: true},
+		{name: "命令超时覆盖配置", configTimeout: "100ms", cmdTimeout: 5 * time.Second, cancelParent: true, wantErr: true},
+		{name: "调用方取消不改写错误", cmdTimeout: 5 * time.Second, cancelParent: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.AppConfig = &config.Config{}
+			config.AppConfig.Deployment.CommandTimeout = tt.configTimeout
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancelParent {
+				time.AfterFunc(200*time.Millisecond, cancel)
+			}
+
+			cmd := NewCommand("sh", "-c", "exec sleep 3")
+			cmd.Timeout = tt.cmdTimeout
+			start := time.Now()
+			_, err := ExecRunner{}.Run(ctx, cmd)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := err != nil && strings.Contains(err.Error(), "命令执行超时"); got != tt.wantTimeout {
+				t.Errorf("超时错误 = %v, want %v (err: %v)", got, tt.wantTimeout, err)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("命令未被及时终止，耗时 %s", elapsed)
+			}
+		})
+	}
+}
+
+func TestExecRunnerEnv(t *testing.T) {
+	requireShell(t)
+
+	tests := []struct {
+		name      string
+		configEnv map[string]string
+		cmdEnv    []string
+		want      string
+	}{
+		{name: "无追加变量", want: ":"},
+		{name: "配置变量", configEnv: map[string]string{"CICD_A": "conf"}, want: "conf:"},
+		{name: "命令变量", cmdEnv: []string{"CICD_B=cmd"}, want: ":cmd"},
+		{name: "命令变量覆盖配置", configEnv: map[string]string{"CICD_A": "conf", "CICD_B": "conf"}, cmdEnv: []string{"CICD_B=cmd"}, want: "conf:cmd"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.AppConfig = &config.Config{}
+			config.AppConfig.Deployment.CommandEnv = tt.configEnv
+
+			cmd := NewCommand("sh", "-c", `printf '%s:%s' "$CICD_A" "$CICD_B"`)
+			cmd.Env = tt.cmdEnv
+
+			output, err := ExecRunner{}.Run(context.Background(), cmd)
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if string(output) != tt.want {
+				t.Errorf("output = %q, want %q", output, tt.want)
+			}
+
+			var lines []string
+			if err := (ExecRunner{}).RunStream(context.Background(), cmd, func(line string) {
+				lines = append(lines, line)
+			}); err != nil {
+				t.Fatalf("RunStream: %v", err)
+			}
+			if strings.Join(lines, "\n") != tt.want {
+				t.Errorf("stream = %q, want %q", lines, tt.want)
+			}
+		})
+	}
+}
+
+func TestFakeRunner(t *testing.T) {
+	errFailed := errors.New("failed")
+
+	tests := []struct {
+		name       string
+		cancelled  bool
+		cmd        Command
+		wantOutput string
+		wantErr    error
+		wantCalls  int
+	}{
+		{name: "先注册的前缀优先", cmd: NewCommand("docker", "tag", "a", "b"), wantOutput: "tagged", wantCalls: 1},
+		{name: "次级前缀", cmd: NewCommand("docker", "push", "b"), wantErr: errFailed, wantCalls: 1},
+		{name: "未匹配返回默认结果", cmd: NewCommand("kubectl", "get", "pods"), wantOutput: "default", wantCalls: 1},
+		{name: "已取消不记录调用", cancelled: true, cmd: NewCommand("docker", "tag", "a", "b"), wantErr: context.Canceled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := NewFakeRunner().
+				On("docker tag", "tagged", nil).
+				On("docker", "", errFailed)
+			fake.Default = FakeResult{Output: []byte("default")}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancelled {
+				cancel()
+			}
+
+			output, err := fake.Run(ctx, tt.cmd)
+			if string(output) != tt.wantOutput || !errors.Is(err, tt.wantErr) {
+				t.Errorf("Run = (%q, %v), want (%q, %v)", output, err, tt.wantOutput, tt.wantErr)
+			}
+			if calls := fake.Calls(); len(calls) != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", len(calls), tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestFakeRunnerStream(t *testing.T) {
+	fake := NewFakeRunner().On("docker pull", "line1\nline2\n", nil)
+	previous := SetCommandRunner(fake)
+	defer SetCommandRunner(previous)
+
+	var lines []string
+	if err := RunCommandStream(context.Background(), NewCommand("docker", "pull", "img"), func(line string) {
+		lines = append(lines, line)
+	}); err != nil {
+		t.Fatalf("RunCommandStream: %v", err)
+	}
+	if want := []string{"line1", "line2"}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("lines = %q, want %q", lines, want)
+	}
+	if calls := fake.Calls(); len(calls) != 1 || calls[0].String() != "docker pull img" {
+		t.Errorf("calls = %v", calls)
+	}
+}
@@ -0,0 +1,64 @@
+package common
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"cicd-agent/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORSMiddleware 跨域中间件，按cors配置返回跨域头并处理预检请求
+func CORSMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" || len(config.AppConfig.CORS.AllowedOrigins) == 0 {
+			c.Next()
+			return
+		}
+
+		if !config.AppConfig.IsOriginAllowed(origin) {
+			// 不在白名单的来源不返回跨域头，由浏览器拦截；预检请求直接拒绝
+			if c.Request.Method == http.MethodOptions {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		header := c.Writer.Header()
+		header.Set("Access-Control-Allow-Origin", origin)
+		header.Add("Vary", "Origin")
+		if config.AppConfig.CORS.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+		header.Set("Access-Control-Expose-Headers", strings.Join([]string{RequestIDHeader, APIVersionHeader}, ", "))
+
+		if c.Request.Method == http.MethodOptions {
+			header.Set("Access-Control-Allow-Methods", strings.Join(config.AppConfig.GetCORSAllowedMethods(), ", "))
+			header.Set("Access-Control-Allow-Headers", strings.Join(config.AppConfig.GetCORSAllowedHeaders(), ", "))
+			header.Set("Access-Control-Max-Age", strconv.Itoa(config.AppConfig.GetCORSMaxAge()))
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// checkWebSocketOrigin WebSocket握手的来源校验，与REST接口共用cors配置
+// 未配置allowed_origins时保持原有行为允许任意来源；非浏览器客户端不带Origin时放行
+func checkWebSocketOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || len(config.AppConfig.CORS.AllowedOrigins) == 0 {
+		return true
+	}
+	if config.AppConfig.IsOriginAllowed(origin) {
+		return true
+	}
+	AppLogger.Warning("拒绝跨域WebSocket连接，来源:", origin)
+	return false
+}
@@ -6,20 +6,57 @@ import (
 	"compress/gzip"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hkdf"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
 	"io"
+	"strings"
+)
+
+const (
+	// keyedEnvelopeVersion 携带密钥ID的密文格式：v2.{密钥ID}.{base64(nonce+密文)}
+	// 旧格式为纯base64（不含"."），两种格式可以直接区分
+	keyedEnvelopeVersion = "v2"
+	// keyDerivationInfo HKDF派生密钥时使用的info
+	keyDerivationInfo = "cicd-agent payload encryption v2"
+	gcmNonceSize      = 12
 )
 
 // DecryptAndDecompress 解密并解压数据
 func DecryptAndDecompress(data string) ([]byte, error) {
-	// 使用配置中的salt作为密钥
-	encryptionSalt := config.GetEncryptionSalt()
-	key := []byte(encryptionSalt)
+	var (
+		key     []byte
+		payload string
+		aad     []byte
+	)
+
+	if keyID, encoded, ok := parseKeyedEnvelope(data); ok {
+		// 新格式：按密文中的密钥ID查找密钥，轮换期间旧密钥仍可解密
+		derived, err := deriveKeyByID(keyID)
+		if err != nil {
+			AppLogger.Error(fmt.Sprintf("查找解密密钥失败: %v", err))
+			return nil, err
+		}
+		key = derived
+		payload = encoded
+		aad = []byte(keyedEnvelopeVersion + "." + keyID)
+	} else {
+		// 旧格式：使用配置中的salt作为密钥，配置了encryption_keys后需显式开启allow_legacy_decrypt
+		if !config.LegacyDecryptAllowed() {
+			AppLogger.Error("已配置encryption_keys，拒绝旧格式密文")
+			return nil, fmt.Errorf("已配置encryption_keys，不接受旧格式密文（迁移期间可开启notification.allow_legacy_decrypt）")
+		}
+		if len(config.GetEncryptionKeys()) > 0 {
+			AppLogger.Warning("使用旧格式（encryption_salt原始密钥）解密，迁移完成后请关闭notification.allow_legacy_decrypt")
+		}
+		key = []byte(config.GetEncryptionSalt())
+		payload = data
+	}
 
 	// 1. Base64解码
-	encryptedData, err := base64.StdEncoding.DecodeString(data)
+	encryptedData, err := base64.StdEncoding.DecodeString(payload)
 	if err != nil {
 		AppLogger.Error(fmt.Sprintf("Base64解码失败: %v", err))
 		return nil, fmt.Errorf("base64解码失败: %v", err)
@@ -27,12 +64,12 @@ func DecryptAndDecompress(data string) ([]byte, error) {
 	// AppLogger.Info(fmt.Sprintf("Base64解码后长度: %d", len(encryptedData)))
 
 	// 2. AES-GCM解密
-	if len(encryptedData) < 12 {
+	if len(encryptedData) < gcmNonceSize {
 		AppLogger.Error(fmt.Sprintf("加密数据长度不足: %d", len(encryptedData)))
 		return nil, fmt.Errorf("加密数据长度不足")
 	}
-	nonce := encryptedData[:12]
-	ciphertext := encryptedData[12:]
+	nonce := encryptedData[:gcmNonceSize]
+	ciphertext := encryptedData[gcmNonceSize:]
 	// AppLogger.Info(fmt.Sprintf("Nonce长度: %d, 密文长度: %d", len(nonce), len(ciphertext)))
 
 	block, err := aes.NewCipher(key)
@@ -47,7 +84,7 @@ func DecryptAndDecompress(data string) ([]byte, error) {
 		return nil, fmt.Errorf("创建GCM失败: %v", err)
 	}
 
-	compressedData, err := aesgcm.Open(nil, nonce, ciphertext, nil)
+	compressedData, err := aesgcm.Open(nil, nonce, ciphertext, aad)
 	if err != nil {
 		AppLogger.Error(fmt.Sprintf("AES-GCM解密失败: %v", err))
 		return nil, fmt.Errorf("AES-GCM解密失败: %v", err)
@@ -79,6 +116,7 @@ func DecryptAndDecompress(data string) ([]byte, error) {
 }
 
 // CompressAndEncrypt 压缩并加密数据
+// 配置了encryption_keys时使用当前密钥派生的AES-256密钥并输出v2格式，否则沿用旧格式
 func CompressAndEncrypt(data []byte) (string, error) {
 	// 压缩数据
 	var compressedBuf bytes.Buffer
@@ -98,11 +136,23 @@ func CompressAndEncrypt(data []byte) (string, error) {
 
 	compressedData := compressedBuf.Bytes()
 
-	// 获取加密盐值
-	encryptionSalt := config.GetEncryptionSalt()
+	// 获取加密密钥
+	key := []byte(config.GetEncryptionSalt())
+	prefix := ""
+	var aad []byte
+	if activeKey, ok := config.GetActiveEncryptionKey(); ok {
+		derived, err := deriveEncryptionKey(activeKey)
+		if err != nil {
+			AppLogger.Error(fmt.Sprintf("派生加密密钥失败: %v", err))
+			return "", err
+		}
+		key = derived
+		prefix = keyedEnvelopeVersion + "." + activeKey.ID
+		aad = []byte(prefix)
+	}
 
 	// 创建AES加密器
-	block, err := aes.NewCipher([]byte(encryptionSalt))
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		AppLogger.Error(fmt.Sprintf("创建AES加密器失败: %v", err))
 		return "", fmt.Errorf("创建AES加密器失败: %v", err)
@@ -116,14 +166,14 @@ func CompressAndEncrypt(data []byte) (string, error) {
 	}
 
 	// 创建12字节的nonce
-	nonce := make([]byte, 12)
+	nonce := make([]byte, gcmNonceSize)
 	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
 		AppLogger.Error(fmt.Sprintf("生成nonce失败: %v", err))
 		return "", fmt.Errorf("生成nonce失败: %v", err)
 	}
 
-	// 加密数据
-	ciphertext := aesGcm.Seal(nil, nonce, compressedData, nil)
+	// 加密数据（v2格式将版本和密钥ID作为附加数据，防止密钥ID被篡改）
+	ciphertext := aesGcm.Seal(nil, nonce, compressedData, aad)
 
 	// 将nonce和密文组合
 	result := append(nonce, ciphertext...)
@@ -131,5 +181,36 @@ func CompressAndEncrypt(data []byte) (string, error) {
 	// 将结果转换为base64编码
 	base64Result := base64.StdEncoding.EncodeToString(result)
 
+	if prefix != "" {
+		return prefix + "." + base64Result, nil
+	}
 	return base64Result, nil
 }
+
+// parseKeyedEnvelope 解析v2格式密文，返回密钥ID和base64部分
+func parseKeyedEnvelope(data string) (string, string, bool) {
+	parts := strings.SplitN(data, ".", 3)
+	if len(parts) != 3 || parts[0] != keyedEnvelopeVersion || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+// deriveKeyByID 根据密钥ID派生解密密钥
+func deriveKeyByID(keyID string) ([]byte, error) {
+	for _, key := range config.GetEncryptionKeys() {
+		if key.ID == keyID {
+			return deriveEncryptionKey(key)
+		}
+	}
+	return nil, fmt.Errorf("未知的密钥ID: %s", keyID)
+}
+
+// deriveEncryptionKey 使用HKDF-SHA256从密钥材料派生AES-256密钥，密钥ID作为salt
+func deriveEncryptionKey(key config.EncryptionKeyConfig) ([]byte, error) {
+	derived, err := hkdf.Key(sha256.New, []byte(key.Secret), []byte(key.ID), keyDerivationInfo, 32)
+	if err != nil {
+		return nil, fmt.Errorf("HKDF派生密钥失败: %v", err)
+	}
+	return derived, nil
+}
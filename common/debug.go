@@ -0,0 +1,95 @@
+package common
+
+import (
+	"crypto/subtle"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"strings"
+	"time"
+
+	"cicd-agent/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// processStartTime 进程启动时间
+var processStartTime = time.Now()
+
+func init() {
+	// 运行时指标，通过/debug/vars输出（memstats、cmdline由expvar默认提供）
+	expvar.Publish("goroutines", expvar.Func(func() interface{} {
+		return runtime.NumGoroutine()
+	}))
+	expvar.Publish("log_connections", expvar.Func(func() interface{} {
+		return ActiveLogConnections()
+	}))
+	expvar.Publish("running_tasks", expvar.Func(func() interface{} {
+		return RunningTaskCount()
+	}))
+	expvar.Publish("uptime_seconds", expvar.Func(func() interface{} {
+		return int64(time.Since(processStartTime).Seconds())
+	}))
+}
+
+// AdminAccessMiddleware 管理接口访问控制：admin白名单、管理令牌或admin权限的API Key/JWT任一通过即可
+// 令牌通过 X-Admin-Token 头或 Authorization: Bearer 传递
+func AdminAccessMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientIP := getClientIP(c)
+		if whitelist != nil && whitelist.isAllowedIn("admin", clientIP) {
+			c.Next()
+			return
+		}
+
+		if checkAdminToken(c) || hasAdminCredential(c) {
+			c.Next()
+			return
+		}
+
+		AppLogger.Warning("未授权的管理接口访问:", clientIP)
+		// 返回404而不是403，隐藏服务存在
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": 404,
+			"msg":  "Not Found",
+		})
+		c.Abort()
+	}
+}
+
+// checkAdminToken 校验请求携带的管理令牌
+func checkAdminToken(c *gin.Context) bool {
+	expected := config.AppConfig.Debug.AdminToken
+	if expected == "" {
+		return false
+	}
+
+	token := c.GetHeader("X-Admin-Token")
+	if token == "" {
+		token = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	}
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
+// PprofHandler pprof性能分析接口，路由为 /debug/pprof/*name
+func PprofHandler(c *gin.Context) {
+	switch strings.TrimPrefix(c.Param("name"), "/") {
+	case "cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		// 索引页及heap、goroutine等命名profile
+		pprof.Index(c.Writer, c.Request)
+	}
+}
+
+// DebugVarsHandler 运行时指标接口（goroutine数、内存、日志连接数、运行中任务数）
+func DebugVarsHandler(c *gin.Context) {
+	expvar.Handler().ServeHTTP(c.Writer, c.Request)
+}
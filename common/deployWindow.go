@@ -0,0 +1,122 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"cicd-agent/config"
+)
+
+// FreezeState 全局封网状态，开启期间所有回调都不会开始部署
+type FreezeState struct {
+	Enabled   bool   `json:"enabled"`
+	Reason    string `json:"reason,omitempty"`
+	UpdatedBy string `json:"updated_by,omitempty"`
+	UpdatedAt string `json:"updated_at,omitempty"`
+}
+
+var (
+	freezeMu     sync.Mutex
+	freezeState  FreezeState
+	freezeLoaded bool
+)
+
+// GetFreezeState 获取封网状态（首次调用时从状态文件读取）
+func GetFreezeState() FreezeState {
+	freezeMu.Lock()
+	defer freezeMu.Unlock()
+	loadFreezeState()
+	return freezeState
+}
+
+// SetFreezeState 开启或解除封网，状态写入文件，重启后保持
+func SetFreezeState(enabled bool, reason, updatedBy string) (FreezeState, error) {
+	freezeMu.Lock()
+	defer freezeMu.Unlock()
+	loadFreezeState()
+
+	state := FreezeState{
+		Enabled:   enabled,
+		Reason:    reason,
+		UpdatedBy: updatedBy,
+		UpdatedAt: time.Now().Format("2006-01-02 15:04:05"),
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return freezeState, fmt.Errorf("序列化封网状态失败: %v", err)
+	}
+	if err := os.WriteFile(config.AppConfig.GetFreezeFile(), data, 0644); err != nil {
+		return freezeState, fmt.Errorf("保存封网状态失败: %v", err)
+	}
+	freezeState = state
+
+	if enabled {
+		AppLogger.Warning(fmt.Sprintf("已开启封网: 原因=%s, 操作人=%s", reason, updatedBy))
+	} else {
+		AppLogger.Info(fmt.Sprintf("已解除封网: 操作人=%s", updatedBy))
+	}
+	return state, nil
+}
+
+// loadFreezeState 从状态文件读取封网状态（调用方持有freezeMu）
+func loadFreezeState() {
+	if freezeLoaded {
+		return
+	}
+	freezeLoaded = true
+
+	data, err := os.ReadFile(config.AppConfig.GetFreezeFile())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			AppLogger.Error("读取封网状态失败:", err)
+		}
+		return
+	}
+	if err := json.Unmarshal(data, &freezeState); err != nil {
+		AppLogger.Error("解析封网状态失败:", err)
+	}
+}
+
+// CheckDeployWindow 判断项目在指定时间能否开始部署，不能时返回原因
+func CheckDeployWindow(project string, at time.Time) (bool, string) {
+	if state := GetFreezeState(); state.Enabled {
+		if state.Reason != "" {
+			return false, fmt.Sprintf("全局封网中（%s）", state.Reason)
+		}
+		return false, "全局封网中"
+	}
+
+	windows := config.AppConfig.GetDeployWindows(project)
+	if len(windows) == 0 {
+		return true, ""
+	}
+	for _, expr := range windows {
+		if schedule, err := config.ParseCron(expr); err == nil && schedule.Matches(at) {
+			return true, ""
+		}
+	}
+	return false, fmt.Sprintf("不在项目 %s 的部署窗口内", project)
+}
+
+// NextDeployWindow 获取项目在after之后最近的部署窗口开始时间（不考虑封网），一年内没有窗口时返回false
+func NextDeployWindow(project string, after time.Time) (time.Time, bool) {
+	windows := config.AppConfig.GetDeployWindows(project)
+	if len(windows) == 0 {
+		return after, true
+	}
+
+	var next time.Time
+	for _, expr := range windows {
+		schedule, err := config.ParseCron(expr)
+		if err != nil {
+			continue
+		}
+		if t, ok := schedule.Next(after); ok && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+	}
+	return next, !next.IsZero()
+}
@@ -0,0 +1,129 @@
+package common
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"cicd-agent/config"
+)
+
+// DingTalkMessage 钉钉机器人消息结构
+type DingTalkMessage struct {
+	MsgType    string              `json:"msgtype"`
+	Markdown   *DingTalkMarkdown   `json:"markdown,omitempty"`
+	ActionCard *DingTalkActionCard `json:"actionCard,omitempty"`
+}
+
+// DingTalkMarkdown markdown消息
+type DingTalkMarkdown struct {
+	Title string `json:"title"`
+	Text  string `json:"text"`
+}
+
+// DingTalkActionCard 卡片消息
+type DingTalkActionCard struct {
+	Title          string `json:"title"`
+	Text           string `json:"text"`
+	SingleTitle    string `json:"singleTitle,omitempty"`
+	SingleURL      string `json:"singleURL,omitempty"`
+	BtnOrientation string `json:"btnOrientation,omitempty"`
+}
+
+// SendDingTalkCard 发送钉钉任务通知（项目未启用钉钉渠道时直接返回）
+func SendDingTalkCard(ctx context.Context, project, tag, status, startTime, endTime, deployType, category, projectName string) error {
+	channel := config.AppConfig.Notification.DingTalk
+	webhookURL := chatChannelWebhook(channel, project, status)
+	if webhookURL == "" {
+		return nil
+	}
+	if err := ValidateOutboundURL(webhookURL); err != nil {
+		return fmt.Errorf("钉钉通知地址校验失败: %v", err)
+	}
+
+	summary := buildTaskSummary(project, tag, status, startTime, endTime, deployType, category, projectName)
+	message := buildDingTalkMessage(summary, channel.MsgType, channel.ActionURL)
+
+	jsonData, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("序列化钉钉消息失败: %v", err)
+	}
+
+	requestURL, err := signDingTalkURL(webhookURL, channel.Secret)
+	if err != nil {
+		return err
+	}
+
+	resp, err := PostJSON(ctx, requestURL, jsonData)
+	if err != nil {
+		return fmt.Errorf("发送钉钉通知失败: %v", err)
+	}
+
+	if err := checkRobotResponse(resp); err != nil {
+		return fmt.Errorf("钉钉通知%v", err)
+	}
+
+	AppLogger.Info(fmt.Sprintf("钉钉通知发送成功: 项目=%s, 状态=%s", project, status))
+	return nil
+}
+
+// buildDingTalkMessage 构建钉钉消息
+// actionCard需要跳转地址，未配置action_url时退回markdown
+func buildDingTalkMessage(summary taskCardSummary, msgType, actionURL string) DingTalkMessage {
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("### %s\n\n", summary.Title))
+	for _, field := range summary.Fields {
+		text.WriteString(fmt.Sprintf("- **%s**: %s\n", field.Label, field.Value))
+	}
+	text.WriteString(fmt.Sprintf("\n---\n\n开始时间: %s\n", summary.StartTime))
+	if summary.EndTime != "" {
+		text.WriteString(fmt.Sprintf("\n结束时间: %s\n", summary.EndTime))
+	}
+
+	if msgType == "actionCard" && actionURL != "" {
+		return DingTalkMessage{
+			MsgType: "actionCard",
+			ActionCard: &DingTalkActionCard{
+				Title:          summary.Title,
+				Text:           text.String(),
+				SingleTitle:    "查看详情",
+				SingleURL:      actionURL,
+				BtnOrientation: "0",
+			},
+		}
+	}
+	return DingTalkMessage{
+		MsgType:  "markdown",
+		Markdown: &DingTalkMarkdown{Title: summary.Title, Text: text.String()},
+	}
+}
+
+// signDingTalkURL 为机器人地址追加加签参数（未配置密钥时原样返回）
+// 签名为 HmacSHA256(timestamp + "\n" + secret) 的base64编码
+func signDingTalkURL(webhookURL, secret string) (string, error) {
+	if secret == "" {
+		return webhookURL, nil
+	}
+
+	timestamp := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + secret))
+	sign := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	parsed, err := url.Parse(webhookURL)
+	if err != nil {
+		return "", fmt.Errorf("钉钉通知地址格式错误: %v", err)
+	}
+	query := parsed.Query()
+	query.Set("timestamp", timestamp)
+	query.Set("sign", sign)
+	parsed.RawQuery = query.Encode()
+	return parsed.String(), nil
+}
@@ -0,0 +1,143 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"cicd-agent/config"
+)
+
+const (
+	dnsLookupTimeout     = 3 * time.Second
+	dnsRetryBackoff      = 200 * time.Millisecond
+	dnsFailureAlertCount = 3 // 连续失败达到该次数时输出告警
+)
+
+var (
+	whitelistDNSFailures = NewCounterVec("cicd_agent_whitelist_dns_failures_total",
+		"白名单域名解析失败次数", "domain")
+	whitelistDNSStale = NewCounterVec("cicd_agent_whitelist_dns_stale_total",
+		"白名单域名解析失败时使用上次结果的次数", "domain")
+)
+
+// dnsCacheEntry 域名解析缓存
+type dnsCacheEntry struct {
+	ips                 []string
+	expiresAt           time.Time
+	consecutiveFailures int
+}
+
+// whitelistResolver 白名单域名解析器：按TTL缓存，解析失败时沿用上次成功的结果
+type whitelistResolver struct {
+	mu    sync.Mutex
+	cache map[string]*dnsCacheEntry
+}
+
+var defaultWhitelistResolver = &whitelistResolver{cache: make(map[string]*dnsCacheEntry)}
+
+// resolveWhitelistEntries 将白名单条目解析为IP/CIDR列表
+func resolveWhitelistEntries(entries []string) []string {
+	return defaultWhitelistResolver.resolveEntries(entries)
+}
+
+// resolveEntries IP和CIDR原样保留，域名经缓存解析
+func (r *whitelistResolver) resolveEntries(entries []string) []string {
+	var result []string
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if _, err := netip.ParsePrefix(entry); err == nil {
+			result = append(result, entry)
+			continue
+		}
+		if addr, ok := parseNormalizedAddr(entry); ok {
+			result = append(result, addr.String())
+			continue
+		}
+		result = append(result, r.resolveDomain(entry)...)
+	}
+	return result
+}
+
+// resolveDomain 解析域名，缓存有效时直接返回；失败时返回上次成功的结果
+func (r *whitelistResolver) resolveDomain(domain string) []string {
+	r.mu.Lock()
+	cached, exists := r.cache[domain]
+	if exists && time.Now().Before(cached.expiresAt) {
+		ips := cached.ips
+		r.mu.Unlock()
+		return ips
+	}
+	r.mu.Unlock()
+
+	ips, err := lookupWithRetry(domain, config.AppConfig.GetDNSRetries())
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry := r.cache[domain]
+	if entry == nil {
+		entry = &dnsCacheEntry{}
+		r.cache[domain] = entry
+	}
+
+	if err != nil {
+		entry.consecutiveFailures++
+		whitelistDNSFailures.Inc(domain)
+		if entry.consecutiveFailures >= dnsFailureAlertCount {
+			AppLogger.Error(fmt.Sprintf("白名单域名 %s 已连续 %d 次解析失败: %v", domain, entry.consecutiveFailures, err))
+		} else {
+			AppLogger.Warning(fmt.Sprintf("白名单域名 %s 解析失败: %v", domain, err))
+		}
+		if len(entry.ips) > 0 {
+			// 沿用上次成功的解析结果，避免DNS抖动时白名单被清空
+			whitelistDNSStale.Inc(domain)
+			return entry.ips
+		}
+		return nil
+	}
+
+	if entry.consecutiveFailures > 0 {
+		AppLogger.Info(fmt.Sprintf("白名单域名 %s 解析已恢复", domain))
+	}
+	entry.ips = ips
+	entry.expiresAt = time.Now().Add(config.AppConfig.GetDNSCacheTTL())
+	entry.consecutiveFailures = 0
+	return ips
+}
+
+// lookupWithRetry 带超时和重试的域名解析，同时保留IPv4和IPv6地址
+func lookupWithRetry(domain string, retries int) ([]string, error) {
+	var lastErr error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(dnsRetryBackoff * time.Duration(attempt))
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), dnsLookupTimeout)
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, domain)
+		cancel()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if len(addrs) == 0 {
+			lastErr = fmt.Errorf("未解析到任何地址")
+			continue
+		}
+
+		ips := make([]string, 0, len(addrs))
+		for _, addr := range addrs {
+			ips = append(ips, normalizeIP(addr.IP.String()))
+		}
+		return ips, nil
+	}
+	return nil, lastErr
+}
@@ -0,0 +1,149 @@
+package common
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"cicd-agent/config"
+)
+
+// durationTrimRatio 计算截尾平均时两端各去掉的比例
+const durationTrimRatio = 0.1
+
+// appendStepHistory 记录步骤耗时，只保留最近duration_history次
+func appendStepHistory(info *VersionInfo, stepKey string, seconds float64) {
+	if info.StepHistory == nil {
+		info.StepHistory = make(map[string][]float64)
+	}
+	history := append(info.StepHistory[stepKey], seconds)
+	if size := config.AppConfig.GetDurationHistorySize(); len(history) > size {
+		history = history[len(history)-size:]
+	}
+	info.StepHistory[stepKey] = history
+}
+
+// stepDurationEstimate 步骤预计耗时（秒）：有历史记录时取截尾移动平均，否则取上次耗时
+func stepDurationEstimate(info *VersionInfo, stepKey string) float64 {
+	if history := info.StepHistory[stepKey]; len(history) > 0 {
+		return trimmedMean(history)
+	}
+	if duration, ok := info.StepDurations[stepKey]; ok {
+		if d, ok := duration.(float64); ok {
+			return d
+		}
+	}
+	return 0
+}
+
+// trimmedMean 截尾平均：两端各去掉10%（至少4个样本时至少各去掉1个），避免单次异常耗时影响预估
+func trimmedMean(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	trim := int(float64(len(sorted)) * durationTrimRatio)
+	if trim == 0 && len(sorted) >= 4 {
+		trim = 1
+	}
+	sorted = sorted[trim : len(sorted)-trim]
+
+	var sum float64
+	for _, v := range sorted {
+		sum += v
+	}
+	return math.Round(sum/float64(len(sorted))*100) / 100
+}
+
+// StepDurationStats 步骤历史耗时统计（秒）
+type StepDurationStats struct {
+	Step        string    `json:"step"` // 步骤键，如 step_9_pullOnline
+	Samples     int       `json:"samples"`
+	Last        float64   `json:"last"`
+	Average     float64   `json:"average"`
+	TrimmedMean float64   `json:"trimmed_mean"` // 预估使用的截尾移动平均
+	Min         float64   `json:"min"`
+	Max         float64   `json:"max"`
+	Trend       float64   `json:"trend"` // 较新一半样本相对较早一半样本的均值变化百分比，正数表示变慢
+	History     []float64 `json:"history"`
+}
+
+// ProjectDurationStats 统计项目各步骤的历史耗时，按步骤编号排序
+func ProjectDurationStats(project string) ([]StepDurationStats, error) {
+	info, err := ReadVersionInfo(project)
+	if err != nil {
+		return nil, err
+	}
+	if info == nil {
+		info = &VersionInfo{}
+	}
+
+	// 旧版本文件只有上次耗时，作为单个样本
+	histories := make(map[string][]float64)
+	for key, duration := range info.StepDurations {
+		if d, ok := duration.(float64); ok {
+			histories[key] = []float64{d}
+		}
+	}
+	for key, history := range info.StepHistory {
+		if len(history) > 0 {
+			histories[key] = history
+		}
+	}
+
+	stats := make([]StepDurationStats, 0, len(histories))
+	for key, history := range histories {
+		stats = append(stats, buildDurationStats(key, history))
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		ni, nj := stepNumber(stats[i].Step), stepNumber(stats[j].Step)
+		if ni != nj {
+			return ni < nj
+		}
+		return stats[i].Step < stats[j].Step
+	})
+	return stats, nil
+}
+
+// buildDurationStats 计算单个步骤的耗时统计
+func buildDurationStats(stepKey string, history []float64) StepDurationStats {
+	stat := StepDurationStats{
+		Step:        stepKey,
+		Samples:     len(history),
+		Last:        history[len(history)-1],
+		Average:     mean(history),
+		TrimmedMean: trimmedMean(history),
+		Min:         history[0],
+		Max:         history[0],
+		History:     history,
+	}
+	for _, v := range history {
+		stat.Min = math.Min(stat.Min, v)
+		stat.Max = math.Max(stat.Max, v)
+	}
+
+	// 至少4个样本才计算趋势
+	if len(history) >= 4 {
+		half := len(history) / 2
+		older, newer := mean(history[:half]), mean(history[len(history)-half:])
+		if older > 0 {
+			stat.Trend = math.Round((newer-older)/older*10000) / 100
+		}
+	}
+	return stat
+}
+
+// mean 平均值（保留2位小数）
+func mean(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return math.Round(sum/float64(len(values))*100) / 100
+}
+
+// stepNumber 从步骤键（step_{编号}_{类型}）中解析编号
+func stepNumber(stepKey string) int {
+	var number int
+	fmt.Sscanf(stepKey, "step_%d_", &number)
+	return number
+}
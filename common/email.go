@@ -0,0 +1,140 @@
+package common
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"cicd-agent/config"
+)
+
+// emailTimeout 连接SMTP服务器超时时间
+const emailTimeout = 10 * time.Second
+
+// SendTaskEmail 发送任务通知邮件（项目未启用邮件渠道时直接返回）
+func SendTaskEmail(project, tag, status, startTime, endTime, deployType, category, projectName string, failure *TaskFailure) error {
+	email := config.AppConfig.Notification.Email
+	recipients := email.RecipientsFor(project)
+	if len(recipients) == 0 || email.SMTPHost == "" {
+		return nil
+	}
+
+	summary := buildTaskSummary(project, tag, status, startTime, endTime, deployType, category, projectName)
+	message := buildEmailMessage(email.From, recipients, summary, failure)
+
+	if err := sendMail(email, recipients, message); err != nil {
+		return fmt.Errorf("发送邮件失败: %v", err)
+	}
+
+	AppLogger.Info(fmt.Sprintf("邮件通知发送成功: 项目=%s, 状态=%s, 收件人=%d", project, status, len(recipients)))
+	return nil
+}
+
+// buildEmailMessage 构建纯文本邮件
+func buildEmailMessage(from string, to []string, summary taskCardSummary, failure *TaskFailure) []byte {
+	var body strings.Builder
+	for _, field := range summary.Fields {
+		body.WriteString(fmt.Sprintf("%s: %s\r\n", field.Label, field.Value))
+	}
+	body.WriteString(fmt.Sprintf("开始时间: %s\r\n", summary.StartTime))
+	body.WriteString(fmt.Sprintf("结束时间: %s\r\n", summary.EndTime))
+	if failure != nil {
+		body.WriteString(fmt.Sprintf("\r\n失败步骤: %s（%s）\r\n", failure.StepName, failure.StepType))
+		if failure.LogTail != "" {
+			body.WriteString("\r\n日志末尾:\r\n")
+			body.WriteString(strings.ReplaceAll(failure.LogTail, "\n", "\r\n"))
+			body.WriteString("\r\n")
+		}
+	}
+
+	return buildPlainEmail(from, to, summary.Title, body.String())
+}
+
+// buildPlainEmail 构建UTF-8纯文本邮件（正文base64编码）
+func buildPlainEmail(from string, to []string, subject, body string) []byte {
+	var msg bytes.Buffer
+	msg.WriteString("From: " + from + "\r\n")
+	msg.WriteString("To: " + strings.Join(to, ", ") + "\r\n")
+	msg.WriteString("Subject: " + mime.BEncoding.Encode("UTF-8", subject) + "\r\n")
+	msg.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+
+	// base64正文按76字符换行
+	encoded := base64.StdEncoding.EncodeToString([]byte(body))
+	for len(encoded) > 76 {
+		msg.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	msg.WriteString(encoded + "\r\n")
+	return msg.Bytes()
+}
+
+// sendMail 通过SMTP发送邮件，465端口使用SMTPS，其他端口在服务器支持时使用STARTTLS
+func sendMail(email config.EmailConfig, to []string, message []byte) error {
+	port := email.GetSMTPPort()
+	addr := net.JoinHostPort(email.SMTPHost, strconv.Itoa(port))
+	tlsConfig := &tls.Config{ServerName: email.SMTPHost}
+
+	var conn net.Conn
+	var err error
+	if port == 465 {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: emailTimeout}, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = net.DialTimeout("tcp", addr, emailTimeout)
+	}
+	if err != nil {
+		return fmt.Errorf("连接SMTP服务器失败: %v", err)
+	}
+	conn.SetDeadline(time.Now().Add(emailTimeout * 3))
+
+	client, err := smtp.NewClient(conn, email.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("创建SMTP客户端失败: %v", err)
+	}
+	defer client.Close()
+
+	if port != 465 {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return fmt.Errorf("STARTTLS失败: %v", err)
+			}
+		}
+	}
+	if email.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", email.Username, email.Password, email.SMTPHost)); err != nil {
+			return fmt.Errorf("SMTP认证失败: %v", err)
+		}
+	}
+
+	if err := client.Mail(email.From); err != nil {
+		return fmt.Errorf("设置发件人失败: %v", err)
+	}
+	for _, rcpt := range to {
+		if err := client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("设置收件人 %s 失败: %v", rcpt, err)
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("开始写入邮件失败: %v", err)
+	}
+	if _, err := writer.Write(message); err != nil {
+		writer.Close()
+		return fmt.Errorf("写入邮件失败: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("提交邮件失败: %v", err)
+	}
+	return client.Quit()
+}
@@ -0,0 +1,197 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"cicd-agent/config"
+)
+
+// busEventSchema 事件总线消息的格式版本，字段变化时升级
+const busEventSchema = "cicd-agent.event/v1"
+
+// eventBusQueueSize 待发布事件的缓冲数量，发布跟不上时丢弃新事件（不阻塞部署）
+const eventBusQueueSize = 1000
+
+// BusEvent 事件总线消息：kind为task时是任务生命周期事件，为step时是步骤事件
+type BusEvent struct {
+	Schema      string `json:"schema"`
+	Kind        string `json:"kind"`  // task/step
+	Agent       string `json:"agent"` // 发布事件的agent主机名
+	Time        string `json:"time"`  // 事件时间（RFC3339）
+	TaskID      string `json:"task_id"`
+	Project     string `json:"project"`
+	ProjectName string `json:"project_name,omitempty"`
+	Tag         string `json:"tag,omitempty"`
+	Category    string `json:"category,omitempty"`
+	DeployType  string `json:"deploy_type,omitempty"`
+	Status      string `json:"status"` // 任务：queued/running/complete/failed/cancel；步骤：start/success/failed/cancel/skipped/paused
+	StartedAt   string `json:"started_at,omitempty"`
+	FinishedAt  string `json:"finished_at,omitempty"`
+	Step        int    `json:"step,omitempty"`
+	StepType    string `json:"step_type,omitempty"`
+	StepName    string `json:"step_name,omitempty"`
+	Message     string `json:"message,omitempty"`     // 步骤消息，任务失败时为失败原因
+	FailedStep  string `json:"failed_step,omitempty"` // 任务失败时出错的步骤类型
+}
+
+// eventPublisher 事件总线发布方式
+type eventPublisher interface {
+	Publish(project string, data []byte) error
+	Close()
+}
+
+var (
+	eventBusOnce  sync.Once
+	eventBusQueue chan BusEvent
+	agentHostname string
+)
+
+// publishTaskEvent 发布任务生命周期事件
+func publishTaskEvent(event TaskEvent) {
+	busEvent := BusEvent{
+		Kind:        "task",
+		TaskID:      event.TaskID,
+		Project:     event.Project,
+		ProjectName: event.ProjectName,
+		Tag:         event.Tag,
+		Category:    event.Category,
+		DeployType:  event.DeployType,
+		Status:      event.Status,
+		StartedAt:   event.StartedAt,
+		FinishedAt:  event.FinishedAt,
+	}
+	if event.Failure != nil {
+		busEvent.FailedStep = event.Failure.StepType
+		busEvent.Message = event.Failure.ErrorMessage
+	}
+	PublishEvent(busEvent)
+}
+
+// publishStepEvent 发布步骤事件（event_bus.steps为false时不发布）
+func publishStepEvent(taskID string, step int, stepType, stepName, status, message, project, tag string) {
+	if config.AppConfig == nil || !config.AppConfig.EventBusStepsEnabled() {
+		return
+	}
+	PublishEvent(BusEvent{
+		Kind:     "step",
+		TaskID:   taskID,
+		Project:  project,
+		Tag:      tag,
+		Status:   status,
+		Step:     step,
+		StepType: stepType,
+		StepName: stepName,
+		Message:  message,
+	})
+}
+
+// PublishEvent 将事件放入发布队列，由后台协程发布到配置的NATS或Kafka；未开启事件总线时忽略
+func PublishEvent(event BusEvent) {
+	if config.AppConfig == nil || !config.AppConfig.EventBus.Enable {
+		return
+	}
+	eventBusOnce.Do(func() {
+		agentHostname, _ = os.Hostname()
+		eventBusQueue = make(chan BusEvent, eventBusQueueSize)
+		go runEventBus()
+	})
+
+	event.Schema = busEventSchema
+	event.Agent = agentHostname
+	if event.Time == "" {
+		event.Time = time.Now().Format(time.RFC3339)
+	}
+	select {
+	case eventBusQueue <- event:
+	default:
+		AppLogger.Warning(fmt.Sprintf("事件总线队列已满，丢弃事件: 任务ID=%s, 类型=%s, 状态=%s", event.TaskID, event.Kind, event.Status))
+	}
+}
+
+// runEventBus 按顺序发布队列中的事件，配置重新加载后按新配置重建连接
+func runEventBus() {
+	var publisher eventPublisher
+	var publisherKey string
+
+	for event := range eventBusQueue {
+		busConfig := config.AppConfig.EventBus
+		if !busConfig.Enable {
+			continue
+		}
+		key := fmt.Sprintf("%s|%s|%+v|%+v", busConfig.Type, config.AppConfig.GetEventBusTopic(), busConfig.NATS, busConfig.Kafka)
+		if publisher == nil || key != publisherKey {
+			if publisher != nil {
+				publisher.Close()
+			}
+			publisher, publisherKey = newEventPublisher(busConfig), key
+		}
+
+		data, err := json.Marshal(event)
+		if err != nil {
+			AppLogger.Error("序列化事件总线消息失败:", err)
+			continue
+		}
+		if err := publisher.Publish(event.Project, data); err != nil {
+			AppLogger.Warning(fmt.Sprintf("发布事件总线消息失败: 类型=%s, 任务ID=%s, 错误=%v", busConfig.Type, event.TaskID, err))
+		}
+	}
+}
+
+// newEventPublisher 按配置创建发布方式
+func newEventPublisher(busConfig config.EventBusConfig) eventPublisher {
+	topic := config.AppConfig.GetEventBusTopic()
+	if busConfig.Type == "kafka" {
+		return &kafkaPublisher{config: busConfig.Kafka, topic: topic}
+	}
+	return &natsPublisher{config: busConfig.NATS, subject: topic}
+}
+
+// kafkaPublisher 通过Kafka REST Proxy（v2接口）发布，消息key为项目名，同一项目的事件进入同一分区保证顺序
+type kafkaPublisher struct {
+	config config.KafkaConfig
+	topic  string
+}
+
+// Publish 发布一条消息
+func (p *kafkaPublisher) Publish(project string, data []byte) error {
+	if p.config.RestURL == "" {
+		return fmt.Errorf("未配置event_bus.kafka.rest_url")
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"records": []map[string]interface{}{{"key": project, "value": json.RawMessage(data)}},
+	})
+	if err != nil {
+		return fmt.Errorf("序列化Kafka消息失败: %v", err)
+	}
+
+	header := map[string]string{
+		"Content-Type": "application/vnd.kafka.json.v2+json",
+		"Accept":       "application/vnd.kafka.v2+json",
+	}
+	for key, value := range p.config.Headers {
+		header[key] = value
+	}
+	resp, err := DoHTTP(context.Background(), HTTPRequest{
+		Method: http.MethodPost,
+		URL:    strings.TrimSuffix(p.config.RestURL, "/") + "/topics/" + p.topic,
+		Body:   body,
+		Header: header,
+	})
+	if err != nil {
+		return fmt.Errorf("请求Kafka REST Proxy失败: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Kafka REST Proxy返回状态码 %d: %s", resp.StatusCode, string(resp.Body))
+	}
+	return nil
+}
+
+// Close REST方式无需关闭
+func (p *kafkaPublisher) Close() {}
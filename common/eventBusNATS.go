@@ -0,0 +1,210 @@
+package common
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"cicd-agent/config"
+)
+
+// natsTimeout 连接NATS和等待服务端确认的超时
+const natsTimeout = 5 * time.Second
+
+// natsPublisher NATS发布（核心协议，只使用CONNECT/PUB/PING/PONG），按项目发布到 {subject}.{项目}
+// 每条消息后发送PING并等待PONG，确认服务端已处理；连接断开时下次发布前自动重连
+type natsPublisher struct {
+	config  config.NATSConfig
+	subject string
+
+	conn    net.Conn
+	writeMu *sync.Mutex // 发布与读协程回复PONG共用连接写入
+	writer  *bufio.Writer
+	pongs   chan struct{}
+	errs    chan error
+}
+
+// Publish 发布一条消息，发送失败时重连后重试一次
+func (p *natsPublisher) Publish(project string, data []byte) error {
+	subject := p.subject + "." + natsSubjectToken(project)
+	if p.conn != nil {
+		if err := p.publish(subject, data); err == nil {
+			return nil
+		}
+		p.Close()
+	}
+	if err := p.connect(); err != nil {
+		return err
+	}
+	if err := p.publish(subject, data); err != nil {
+		p.Close()
+		return err
+	}
+	return nil
+}
+
+// Close 关闭连接
+func (p *natsPublisher) Close() {
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+}
+
+// publish 发送PUB和PING，等待PONG
+func (p *natsPublisher) publish(subject string, data []byte) error {
+	p.writeMu.Lock()
+	p.conn.SetWriteDeadline(time.Now().Add(natsTimeout))
+	fmt.Fprintf(p.writer, "PUB %s %d\r\n", subject, len(data))
+	p.writer.Write(data)
+	p.writer.WriteString("\r\nPING\r\n")
+	err := p.writer.Flush()
+	p.writeMu.Unlock()
+	if err != nil {
+		return fmt.Errorf("发送NATS消息失败: %v", err)
+	}
+
+	select {
+	case <-p.pongs:
+		return nil
+	case err := <-p.errs:
+		return err
+	case <-time.After(natsTimeout):
+		return fmt.Errorf("等待NATS确认超时")
+	}
+}
+
+// connect 连接NATS：读取INFO，需要时升级TLS，发送CONNECT并用PING/PONG确认认证通过
+func (p *natsPublisher) connect() error {
+	if p.config.URL == "" {
+		return fmt.Errorf("未配置event_bus.nats.url")
+	}
+	serverURL, err := url.Parse(p.config.URL)
+	if err != nil {
+		return fmt.Errorf("NATS地址格式错误: %v", err)
+	}
+	host := serverURL.Host
+	if serverURL.Port() == "" {
+		host = net.JoinHostPort(serverURL.Hostname(), "4222")
+	}
+
+	conn, err := net.DialTimeout("tcp", host, natsTimeout)
+	if err != nil {
+		return fmt.Errorf("连接NATS失败: %v", err)
+	}
+	conn.SetDeadline(time.Now().Add(natsTimeout))
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "INFO") {
+		conn.Close()
+		return fmt.Errorf("读取NATS服务信息失败: %v %s", err, strings.TrimSpace(line))
+	}
+	if serverURL.Scheme == "tls" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: serverURL.Hostname()})
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return fmt.Errorf("NATS TLS握手失败: %v", err)
+		}
+		conn, reader = tlsConn, bufio.NewReader(tlsConn)
+	}
+
+	options := map[string]interface{}{
+		"verbose":  false,
+		"pedantic": false,
+		"name":     "cicd-agent",
+		"lang":     "go",
+		"version":  "1.0.0",
+		"protocol": 1,
+	}
+	user, password := p.config.User, p.config.Password
+	if serverURL.User != nil {
+		user = serverURL.User.Username()
+		password, _ = serverURL.User.Password()
+	}
+	if user != "" {
+		options["user"], options["pass"] = user, password
+	}
+	if p.config.Token != "" {
+		options["auth_token"] = p.config.Token
+	}
+	connectOptions, _ := json.Marshal(options)
+
+	writer := bufio.NewWriter(conn)
+	fmt.Fprintf(writer, "CONNECT %s\r\nPING\r\n", connectOptions)
+	if err := writer.Flush(); err != nil {
+		conn.Close()
+		return fmt.Errorf("发送NATS连接参数失败: %v", err)
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			conn.Close()
+			return fmt.Errorf("NATS连接确认失败: %v", err)
+		}
+		if strings.HasPrefix(line, "-ERR") {
+			conn.Close()
+			return fmt.Errorf("NATS拒绝连接: %s", strings.TrimSpace(line))
+		}
+		if strings.HasPrefix(line, "PONG") {
+			break
+		}
+	}
+	conn.SetDeadline(time.Time{})
+
+	p.conn, p.writer, p.writeMu = conn, writer, &sync.Mutex{}
+	p.pongs, p.errs = make(chan struct{}, 1), make(chan error, 1)
+	go p.readLoop(conn, reader, writer, p.writeMu, p.pongs, p.errs)
+	AppLogger.Info(fmt.Sprintf("已连接NATS事件总线: %s", serverURL.Host))
+	return nil
+}
+
+// readLoop 读取服务端消息：回复PING，转发PONG和-ERR，连接断开时退出
+func (p *natsPublisher) readLoop(conn net.Conn, reader *bufio.Reader, writer *bufio.Writer, writeMu *sync.Mutex, pongs chan struct{}, errs chan error) {
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			select {
+			case errs <- fmt.Errorf("NATS连接已断开: %v", err):
+			default:
+			}
+			return
+		}
+		switch {
+		case strings.HasPrefix(line, "PING"):
+			writeMu.Lock()
+			writer.WriteString("PONG\r\n")
+			writer.Flush()
+			writeMu.Unlock()
+		case strings.HasPrefix(line, "PONG"):
+			select {
+			case pongs <- struct{}{}:
+			default:
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			select {
+			case errs <- fmt.Errorf("NATS返回错误: %s", strings.TrimSpace(line)):
+			default:
+			}
+		}
+	}
+}
+
+// natsSubjectToken 项目名转换为NATS主题中的一段（点号、空白和通配符替换为下划线）
+func natsSubjectToken(project string) string {
+	if project == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '*', '>', ' ', '\t', '\r', '\n':
+			return '_'
+		}
+		return r
+	}, project)
+}
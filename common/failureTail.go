@@ -0,0 +1,100 @@
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	failureTailLines    = 20   // 失败通知附带的日志行数
+	failureTailLineMax  = 200  // 单行最大字符数
+	failureTailTotalMax = 3000 // 日志片段最大字符数
+)
+
+// TaskFailure 任务失败详情（失败步骤及其日志末尾）
+type TaskFailure struct {
+	StepType     string `json:"step_type"`
+	StepName     string `json:"step_name"`
+	LogTail      string `json:"log_tail"`
+	ErrorMessage string `json:"error_message,omitempty"` // 步骤失败原因
+	RolledBack   bool   `json:"rolled_back"`             // 失败后是否已恢复到原版本
+}
+
+// failedSteps 任务ID -> 最近一次失败的步骤
+var failedSteps sync.Map
+
+// ansiEscapePattern 终端颜色控制序列
+var ansiEscapePattern = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+// recordStepFailure 记录任务失败的步骤，供任务失败通知使用
+func recordStepFailure(taskID, stepType, stepName, message string) {
+	failedSteps.Store(taskID, TaskFailure{StepType: stepType, StepName: stepName, ErrorMessage: message})
+}
+
+// MarkTaskRolledBack 标记失败的任务已恢复到原版本（如web回滚备份、双版本切换失败后流量仍在旧版本）
+func MarkTaskRolledBack(taskID string) {
+	if value, ok := failedSteps.Load(taskID); ok {
+		failure := value.(TaskFailure)
+		failure.RolledBack = true
+		failedSteps.Store(taskID, failure)
+	}
+	updateTaskLogMeta(taskID, func(meta *TaskLogMeta) { meta.RolledBack = true })
+}
+
+// takeTaskFailure 取出任务失败详情并读取失败步骤的日志末尾，没有失败记录时返回nil
+func takeTaskFailure(taskID string) *TaskFailure {
+	value, ok := failedSteps.LoadAndDelete(taskID)
+	if !ok {
+		return nil
+	}
+	failure := value.(TaskFailure)
+	failure.LogTail = readStepLogTail(taskID, failure.StepType)
+	return &failure
+}
+
+// readStepLogTail 读取步骤日志最后若干行（去除控制字符并截断过长内容）
+func readStepLogTail(taskID, stepType string) string {
+	content, _, err := readTaskLogFile(buildLogFilePath(taskID, stepType))
+	if err != nil {
+		AppLogger.Warning(fmt.Sprintf("读取失败步骤日志失败: 任务=%s, 步骤=%s, 错误=%v", taskID, stepType, err))
+		return ""
+	}
+
+	var lines []string
+	for _, line := range splitLines(string(content)) {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, sanitizeLogLine(line))
+		}
+	}
+	if len(lines) > failureTailLines {
+		lines = lines[len(lines)-failureTailLines:]
+	}
+
+	tail := strings.Join(lines, "\n")
+	if utf8.RuneCountInString(tail) > failureTailTotalMax {
+		runes := []rune(tail)
+		tail = "..." + string(runes[len(runes)-failureTailTotalMax:])
+	}
+	return tail
+}
+
+// sanitizeLogLine 去除终端控制字符并截断过长的行
+func sanitizeLogLine(line string) string {
+	line = ansiEscapePattern.ReplaceAllString(line, "")
+	line = strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return ' '
+		}
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, line)
+	if utf8.RuneCountInString(line) > failureTailLineMax {
+		line = string([]rune(line)[:failureTailLineMax]) + "..."
+	}
+	return line
+}
@@ -1,17 +1,31 @@
 package common
 
 import (
-	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
+
+	"cicd-agent/config"
 )
 
 // FeishuCardMessage 飞书卡片消息结构
 type FeishuCardMessage struct {
-	MsgType string     `json:"msg_type"`
-	Card    FeishuCard `json:"card"`
+	Timestamp string     `json:"timestamp,omitempty"` // 加签时间戳（秒），机器人开启签名校验时必填
+	Sign      string     `json:"sign,omitempty"`      // 加签签名
+	MsgType   string     `json:"msg_type"`
+	Card      FeishuCard `json:"card"`
+}
+
+// feishuResponse 飞书机器人接口响应（签名错误等业务错误同样返回200）
+type feishuResponse struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
 }
 
 // FeishuCard 飞书卡片结构
@@ -53,20 +67,90 @@ type FeishuFieldSet struct {
 	Fields []FeishuField `json:"fields"`
 }
 
+// FeishuTextBlock 文本块
+type FeishuTextBlock struct {
+	Tag  string     `json:"tag"`
+	Text FeishuText `json:"text"`
+}
+
 // FeishuDivider 分割线
 type FeishuDivider struct {
 	Tag string `json:"tag"`
 }
 
+// FeishuActionBlock 按钮组
+type FeishuActionBlock struct {
+	Tag     string         `json:"tag"`
+	Actions []FeishuButton `json:"actions"`
+}
+
+// FeishuButton 交互按钮，点击后value回传到卡片请求网址
+type FeishuButton struct {
+	Tag     string            `json:"tag"`
+	Text    FeishuText        `json:"text"`
+	Type    string            `json:"type"` // default/primary/danger
+	Value   map[string]string `json:"value"`
+	Confirm *FeishuConfirm    `json:"confirm,omitempty"`
+}
+
+// FeishuConfirm 按钮二次确认弹窗
+type FeishuConfirm struct {
+	Title FeishuText `json:"title"`
+	Text  FeishuText `json:"text"`
+}
+
 // SendFeishuCard 发送飞书卡片通知
-func SendFeishuCard(webhookURL, project, tag, status, startTime, endTime, deployType, category, projectName string) error {
+// failure不为空时在卡片中附带失败步骤和日志末尾；启用卡片交互时附带重试/回滚按钮
+func SendFeishuCard(ctx context.Context, webhookURL, taskID, project, tag, status, startTime, endTime, deployType, category, projectName string, failure *TaskFailure) error {
 	if webhookURL == "" {
 		AppLogger.Info("飞书通知URL为空，跳过发送")
 		return nil
 	}
 
 	// 构建卡片消息
-	card := buildTaskCard(project, tag, status, startTime, endTime, deployType, category, projectName)
+	card := buildTaskCard(taskID, project, tag, status, startTime, endTime, deployType, category, projectName, failure)
+	if err := postFeishuMessage(ctx, webhookURL, card); err != nil {
+		return err
+	}
+
+	AppLogger.Info(fmt.Sprintf("飞书通知发送成功: 项目=%s, 状态=%s", project, status))
+	return nil
+}
+
+// SendFeishuSummary 发送文本汇总卡片（如批量部署结果）
+func SendFeishuSummary(ctx context.Context, webhookURL, title, template, content string) error {
+	if webhookURL == "" {
+		return nil
+	}
+	card := FeishuCardMessage{
+		MsgType: "interactive",
+		Card: FeishuCard{
+			Config: FeishuCardConfig{WideScreenMode: true},
+			Header: FeishuCardHeader{
+				Title:    FeishuText{Content: title, Tag: "plain_text"},
+				Template: template,
+			},
+			Elements: []FeishuElement{
+				FeishuTextBlock{
+					Tag:  "div",
+					Text: FeishuText{Content: content, Tag: "plain_text"},
+				},
+			},
+		},
+	}
+	return postFeishuMessage(ctx, webhookURL, card)
+}
+
+// postFeishuMessage 发送消息到飞书机器人
+func postFeishuMessage(ctx context.Context, webhookURL string, card FeishuCardMessage) error {
+	if err := ValidateOutboundURL(webhookURL); err != nil {
+		return fmt.Errorf("飞书通知地址校验失败: %v", err)
+	}
+
+	// 机器人配置了加签密钥时附带时间戳和签名
+	if secret := config.AppConfig.GetFeishuSecret(webhookURL); secret != "" {
+		card.Timestamp, card.Sign = signFeishuMessage(secret, time.Now().Unix())
+	}
 
 	// 序列化为JSON
 	jsonData, err := json.Marshal(card)
@@ -75,34 +159,77 @@ func SendFeishuCard(webhookURL, project, tag, status, startTime, endTime, deploy
 	}
 
 	// 发送HTTP请求
-	resp, err := http.Post(webhookURL, "application/json", bytes.NewBuffer(jsonData))
+	resp, err := PostJSON(ctx, webhookURL, jsonData)
 	if err != nil {
 		return fmt.Errorf("发送飞书通知失败: %v", err)
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("飞书通知响应异常，状态码: %d", resp.StatusCode)
 	}
-
-	AppLogger.Info(fmt.Sprintf("飞书通知发送成功: 项目=%s, 状态=%s", project, status))
+	var result feishuResponse
+	if err := json.Unmarshal(resp.Body, &result); err == nil && result.Code != 0 {
+		return fmt.Errorf("飞书通知返回错误: %d %s", result.Code, result.Msg)
+	}
 	return nil
 }
 
+// signFeishuMessage 计算飞书机器人签名
+// 以"时间戳\n密钥"为HMAC-SHA256的key对空消息签名，结果base64编码
+func signFeishuMessage(secret string, timestamp int64) (string, string) {
+	ts := strconv.FormatInt(timestamp, 10)
+	mac := hmac.New(sha256.New, []byte(ts+"\n"+secret))
+	return ts, base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
 // getDeployTypeLabel 获取部署类型标签
 func getDeployTypeLabel(deployType string) string {
 	switch deployType {
 	case "web":
-		return "前端"
+		return T("前端")
 	case "single", "double":
-		return "后端"
+		return T("后端")
 	default:
 		return ""
 	}
 }
 
-// buildTaskCard 构建任务卡片
-func buildTaskCard(project, tag, status, startTime, endTime, deployType, category, projectName string) FeishuCardMessage {
+// taskCardSummary 任务卡片内容（各群聊渠道共用）
+type taskCardSummary struct {
+	Template   string          // 飞书卡片颜色
+	Title      string          // 卡片标题
+	StatusText string          // 部署状态文字
+	Fields     []taskCardField // 主体字段（3行2列）
+	StartTime  string
+	EndTime    string
+	Data       taskCardData // 卡片模板数据
+}
+
+// taskCardField 卡片字段
+type taskCardField struct {
+	Label string
+	Value string
+	Wide  bool // 整行显示（默认半宽）
+}
+
+// taskCardData 卡片模板可引用的数据
+type taskCardData struct {
+	Project     string
+	ProjectName string
+	Tag         string
+	Status      string
+	StatusText  string
+	Duration    string
+	StartTime   string
+	EndTime     string
+	TypeLabel   string
+	Category    string
+	Emoji       string
+	Vars        map[string]string
+}
+
+// buildTaskSummary 根据任务信息生成卡片内容
+func buildTaskSummary(project, tag, status, startTime, endTime, deployType, category, projectName string) taskCardSummary {
 	// 获取部署类型标签
 	typeLabel := getDeployTypeLabel(deployType)
 	typeSuffix := ""
@@ -110,108 +237,154 @@ func buildTaskCard(project, tag, status, startTime, endTime, deployType, categor
 		typeSuffix = "-" + typeLabel
 	}
 
+	summary := taskCardSummary{StartTime: startTime, EndTime: endTime}
+	var emoji string
+
 	// 根据状态设置颜色和标题
-	var template, title, statusText string
 	switch status {
+	case "running":
+		emoji = "🚀"
+		summary.Template = "blue"
+		summary.Title = Tf("%s 【%s%s】开始部署", emoji, projectName, typeSuffix)
+		summary.StatusText = T("🚀 部署中")
+	case "queued":
+		emoji = "⏳"
+		summary.Template = "yellow"
+		summary.Title = Tf("%s 【%s%s】排队等待部署", emoji, projectName, typeSuffix)
+		summary.StatusText = T("⏳ 排队中")
 	case "complete":
-		template = "green"
-		title = fmt.Sprintf("🎉 【%s%s】部署成功", projectName, typeSuffix)
-		statusText = "✅ 部署完成"
+		emoji = "🎉"
+		summary.Template = "green"
+		summary.Title = Tf("%s 【%s%s】部署成功", emoji, projectName, typeSuffix)
+		summary.StatusText = T("✅ 部署完成")
 	case "failed":
-		template = "red"
-		title = fmt.Sprintf("❌ 【%s%s】部署失败", projectName, typeSuffix)
-		statusText = "❌ 部署失败"
+		emoji = "❌"
+		summary.Template = "red"
+		summary.Title = Tf("%s 【%s%s】部署失败", emoji, projectName, typeSuffix)
+		summary.StatusText = T("❌ 部署失败")
 	case "cancel":
-		template = "grey"
-		title = fmt.Sprintf("⏹️ 【%s%s】部署取消", projectName, typeSuffix)
-		statusText = "⏹️ 部署取消"
+		emoji = "⏹️"
+		summary.Template = "grey"
+		summary.Title = Tf("%s 【%s%s】部署取消", emoji, projectName, typeSuffix)
+		summary.StatusText = T("⏹️ 部署取消")
 	default:
-		template = "blue"
-		title = "📋 部署通知"
-		statusText = fmt.Sprintf("📋 %s", status)
+		emoji = "📋"
+		summary.Template = "blue"
+		summary.Title = T("📋 部署通知")
+		summary.StatusText = fmt.Sprintf("📋 %s", status)
 	}
 
-	// 计算耗时
 	duration := calculateDuration(startTime, endTime)
+	summary.Data = taskCardData{
+		Project:     project,
+		ProjectName: projectName,
+		Tag:         tag,
+		Status:      status,
+		StatusText:  summary.StatusText,
+		Duration:    duration,
+		StartTime:   startTime,
+		EndTime:     endTime,
+		TypeLabel:   typeLabel,
+		Category:    category,
+		Emoji:       emoji,
+	}
 
-	// 构建字段列表 - 6个字段，3行2列布局
-	var fields []FeishuField
-
-	// 第一行：项目名称、版本标签
-	fields = append(fields,
-		FeishuField{
-			IsShort: true,
-			Text: FeishuText{
-				Content: fmt.Sprintf("**项目名称**\n%s", project),
-				Tag:     "lark_md",
-			},
-		},
-		FeishuField{
-			IsShort: true,
-			Text: FeishuText{
-				Content: fmt.Sprintf("**版本标签**\n%s", tag),
-				Tag:     "lark_md",
-			},
-		},
-	)
-
-	// 第二行：部署状态、耗时
-	fields = append(fields,
-		FeishuField{
-			IsShort: true,
-			Text: FeishuText{
-				Content: fmt.Sprintf("**部署状态**\n%s", statusText),
-				Tag:     "lark_md",
-			},
-		},
-		FeishuField{
-			IsShort: true,
-			Text: FeishuText{
-				Content: fmt.Sprintf("**耗时**\n%s", duration),
-				Tag:     "lark_md",
-			},
-		},
-	)
-
-	// 第三行：额外参数、当前版本/空白
 	// 额外参数字段
-	var categoryContent string
+	categoryValue := T("无")
 	if category != "" {
-		categoryContent = fmt.Sprintf("**额外参数**\n%s", category)
-	} else {
-		categoryContent = "**额外参数**\n无"
+		categoryValue = category
 	}
 
-	fields = append(fields, FeishuField{
-		IsShort: true,
-		Text: FeishuText{
-			Content: categoryContent,
-			Tag:     "lark_md",
-		},
-	})
+	// 第一行：项目名称、版本标签；第二行：部署状态、耗时；第三行：额外参数、当前版本/部署类型
+	summary.Fields = []taskCardField{
+		{Label: T("项目名称"), Value: project},
+		{Label: T("版本标签"), Value: tag},
+		{Label: T("部署状态"), Value: summary.StatusText},
+		{Label: T("耗时"), Value: duration},
+		{Label: T("额外参数"), Value: categoryValue},
+	}
 
 	// 根据部署类型添加最后一个字段
 	if deployType == "double" {
 		// 双副本：显示当前运行版本号
-		currentVersion := getCurrentVersion(project)
-		fields = append(fields, FeishuField{
-			IsShort: true,
-			Text: FeishuText{
-				Content: fmt.Sprintf("**当前版本**\n%s", currentVersion),
-				Tag:     "lark_md",
-			},
-		})
+		summary.Fields = append(summary.Fields, taskCardField{Label: T("当前版本"), Value: getCurrentVersion(project)})
 	} else {
 		// 单副本/前端：显示部署类型
-		fields = append(fields, FeishuField{
-			IsShort: true,
-			Text: FeishuText{
-				Content: fmt.Sprintf("**部署类型**\n%s", typeLabel),
-				Tag:     "lark_md",
+		summary.Fields = append(summary.Fields, taskCardField{Label: T("部署类型"), Value: typeLabel})
+	}
+	return summary
+}
+
+// buildTaskCard 构建任务卡片
+func buildTaskCard(taskID, project, tag, status, startTime, endTime, deployType, category, projectName string, failure *TaskFailure) FeishuCardMessage {
+	summary := buildTaskSummary(project, tag, status, startTime, endTime, deployType, category, projectName)
+
+	// 按项目模板调整标题、颜色和字段
+	if tpl, ok := config.AppConfig.GetFeishuTemplate(project); ok {
+		applyFeishuTemplate(&summary, tpl)
+	}
+
+	// 构建字段列表 - 默认6个字段，3行2列布局
+	var fields []FeishuField
+	for _, field := range summary.Fields {
+		if field.Wide {
+			fields = append(fields, FeishuField{
+				IsShort: false,
+				Text:    FeishuText{Content: fmt.Sprintf("**%s**\n%s", field.Label, field.Value), Tag: "lark_md"},
+			})
+			continue
+		}
+		fields = append(fields, feishuShortField(field.Label, field.Value))
+	}
+
+	elements := []FeishuElement{
+		FeishuFieldSet{
+			Tag:    "div",
+			Fields: fields,
+		},
+		FeishuDivider{
+			Tag: "hr",
+		},
+		FeishuFieldSet{
+			Tag: "div",
+			Fields: []FeishuField{
+				feishuShortField(T("开始时间"), startTime),
+				feishuShortField(T("结束时间"), endTime),
 			},
+		},
+	}
+
+	// 重试发起的任务注明原任务，便于追溯
+	if retryOf := TaskRetryOf(taskID); retryOf != "" {
+		elements = append(elements, FeishuTextBlock{
+			Tag:  "div",
+			Text: FeishuText{Content: Tf("**重试自任务**: %s", retryOf), Tag: "lark_md"},
 		})
 	}
 
+	// 失败时附带失败步骤和日志末尾，便于直接在群里排查
+	if failure != nil {
+		elements = append(elements,
+			FeishuDivider{Tag: "hr"},
+			FeishuTextBlock{
+				Tag:  "div",
+				Text: FeishuText{Content: Tf("**失败步骤**: %s（%s）", T(failure.StepName), failure.StepType), Tag: "lark_md"},
+			},
+		)
+		if failure.LogTail != "" {
+			// 日志使用plain_text，避免其中的markdown字符被解析
+			elements = append(elements, FeishuTextBlock{
+				Tag:  "div",
+				Text: FeishuText{Content: failure.LogTail, Tag: "plain_text"},
+			})
+		}
+	}
+
+	// 启用卡片交互时附带操作按钮
+	if actions, ok := taskCardActions(taskID, project, status); ok {
+		elements = append(elements, FeishuDivider{Tag: "hr"}, actions)
+	}
+
 	return FeishuCardMessage{
 		MsgType: "interactive",
 		Card: FeishuCard{
@@ -220,39 +393,23 @@ func buildTaskCard(project, tag, status, startTime, endTime, deployType, categor
 			},
 			Header: FeishuCardHeader{
 				Title: FeishuText{
-					Content: title,
+					Content: summary.Title,
 					Tag:     "plain_text",
 				},
-				Template: template,
-			},
-			Elements: []FeishuElement{
-				FeishuFieldSet{
-					Tag:    "div",
-					Fields: fields,
-				},
-				FeishuDivider{
-					Tag: "hr",
-				},
-				FeishuFieldSet{
-					Tag: "div",
-					Fields: []FeishuField{
-						{
-							IsShort: true,
-							Text: FeishuText{
-								Content: fmt.Sprintf("**开始时间**\n%s", startTime),
-								Tag:     "lark_md",
-							},
-						},
-						{
-							IsShort: true,
-							Text: FeishuText{
-								Content: fmt.Sprintf("**结束时间**\n%s", endTime),
-								Tag:     "lark_md",
-							},
-						},
-					},
-				},
+				Template: summary.Template,
 			},
+			Elements: elements,
+		},
+	}
+}
+
+// feishuShortField 构建半宽的lark_md字段
+func feishuShortField(label, value string) FeishuField {
+	return FeishuField{
+		IsShort: true,
+		Text: FeishuText{
+			Content: fmt.Sprintf("**%s**\n%s", label, value),
+			Tag:     "lark_md",
 		},
 	}
 }
@@ -261,23 +418,31 @@ func buildTaskCard(project, tag, status, startTime, endTime, deployType, categor
 func getCurrentVersion(project string) string {
 	// 检查项目是否有版本结构
 	if !HasVersionStructure(project) {
-		return "单版本"
+		return T("单版本")
 	}
 
 	// 获取当前版本信息
 	versionInfo, err := GetCurrentVersion(project)
 	if err != nil {
 		AppLogger.Warning(fmt.Sprintf("获取项目 %s 当前版本失败: %v", project, err))
-		return "未知"
+		return T("未知")
 	}
 
-	return versionInfo.CurrentVersion
+	// 有切换记录时附带运行的标签和上一版本
+	current := versionInfo.CurrentVersion
+	if release := versionInfo.CurrentRelease(); release != nil && release.Version == current && release.Tag != "" {
+		current = Tf("%s（%s）", current, release.Tag)
+	}
+	if previous := versionInfo.PreviousRelease(); previous != nil && previous.Tag != "" {
+		return Tf("%s，上一版本 %s（%s）", current, previous.Version, previous.Tag)
+	}
+	return current
 }
 
 // calculateDuration 计算耗时
 func calculateDuration(startTime, endTime string) string {
 	if startTime == "" || endTime == "" {
-		return "未知"
+		return T("未知")
 	}
 
 	layout := "2006-01-02 15:04:05"
@@ -285,22 +450,22 @@ func calculateDuration(startTime, endTime string) string {
 	end, err2 := time.Parse(layout, endTime)
 
 	if err1 != nil || err2 != nil {
-		return "计算失败"
+		return T("计算失败")
 	}
 
 	duration := end.Sub(start)
 
 	// 格式化耗时显示
 	if duration < time.Minute {
-		return fmt.Sprintf("%.0f秒", duration.Seconds())
+		return Tf("%.0f秒", duration.Seconds())
 	} else if duration < time.Hour {
 		minutes := int(duration.Minutes())
 		seconds := int(duration.Seconds()) % 60
-		return fmt.Sprintf("%d分%d秒", minutes, seconds)
+		return Tf("%d分%d秒", minutes, seconds)
 	} else {
 		hours := int(duration.Hours())
 		minutes := int(duration.Minutes()) % 60
 		seconds := int(duration.Seconds()) % 60
-		return fmt.Sprintf("%d小时%d分%d秒", hours, minutes, seconds)
+		return Tf("%d小时%d分%d秒", hours, minutes, seconds)
 	}
 }
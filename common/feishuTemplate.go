@@ -0,0 +1,68 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"cicd-agent/config"
+)
+
+// applyFeishuTemplate 按项目模板调整卡片（模板渲染失败时保留默认内容）
+func applyFeishuTemplate(summary *taskCardSummary, tpl config.FeishuCardTemplate) {
+	status := summary.Data.Status
+	summary.Data.Vars = tpl.Vars
+
+	if emoji, ok := tpl.Emojis[status]; ok {
+		// 默认标题以emoji开头，直接替换
+		summary.Title = strings.Replace(summary.Title, summary.Data.Emoji, emoji, 1)
+		summary.Data.Emoji = emoji
+	}
+	if color, ok := tpl.Colors[status]; ok && color != "" {
+		summary.Template = color
+	}
+	if titleTpl, ok := tpl.Titles[status]; ok && titleTpl != "" {
+		if title, err := renderCardTemplate(titleTpl, summary.Data); err == nil {
+			summary.Title = title
+		} else {
+			AppLogger.Warning(fmt.Sprintf("飞书卡片标题模板渲染失败: 项目=%s, 错误=%v", summary.Data.Project, err))
+		}
+	}
+
+	if len(tpl.HideFields) > 0 {
+		hidden := make(map[string]bool, len(tpl.HideFields))
+		for _, label := range tpl.HideFields {
+			hidden[label] = true
+		}
+		fields := summary.Fields[:0]
+		for _, field := range summary.Fields {
+			if !hidden[field.Label] {
+				fields = append(fields, field)
+			}
+		}
+		summary.Fields = fields
+	}
+
+	for _, extra := range tpl.ExtraFields {
+		value, err := renderCardTemplate(extra.Value, summary.Data)
+		if err != nil {
+			AppLogger.Warning(fmt.Sprintf("飞书卡片字段模板渲染失败: 项目=%s, 字段=%s, 错误=%v", summary.Data.Project, extra.Label, err))
+			continue
+		}
+		wide := extra.Short != nil && !*extra.Short
+		summary.Fields = append(summary.Fields, taskCardField{Label: extra.Label, Value: value, Wide: wide})
+	}
+}
+
+// renderCardTemplate 渲染卡片文本模板，引用不存在的变量时输出空字符串
+func renderCardTemplate(text string, data taskCardData) (string, error) {
+	tmpl, err := template.New("card").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,15 @@
+//go:build !unix && !windows
+
+package common
+
+import "os"
+
+// lockFile 当前平台不支持flock，只依赖进程内互斥
+func lockFile(f *os.File) error {
+	return nil
+}
+
+// unlockFile 当前平台不支持flock
+func unlockFile(f *os.File) error {
+	return nil
+}
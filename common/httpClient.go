@@ -0,0 +1,159 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"cicd-agent/config"
+)
+
+// httpRetryBackoff 出站请求首次重试的等待时间，之后每次翻倍
+const httpRetryBackoff = 500 * time.Millisecond
+
+// maxHTTPRedirects 出站请求最多跟随的重定向次数
+const maxHTTPRedirects = 10
+
+// sharedHTTPClient 所有出站请求共享的客户端（连接池复用），超时由每次请求的ctx控制
+var sharedHTTPClient = &http.Client{
+	CheckRedirect: checkOutboundRedirect,
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   5 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	},
+}
+
+// checkOutboundRedirect 限制重定向次数，并对每一跳的地址做出站校验（避免被重定向到内网或不允许的协议）
+func checkOutboundRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxHTTPRedirects {
+		return fmt.Errorf("重定向次数超过 %d 次", maxHTTPRedirects)
+	}
+	if err := ValidateOutboundURL(req.URL.String()); err != nil {
+		return fmt.Errorf("重定向地址未通过校验: %v", err)
+	}
+	return nil
+}
+
+// HTTPClient 获取共享的出站HTTP客户端（需要流式读取响应时使用，调用方自行设置超时）
+func HTTPClient() *http.Client {
+	return sharedHTTPClient
+}
+
+// HTTPRequest 出站请求
+type HTTPRequest struct {
+	Method  string
+	URL     string
+	Body    []byte
+	Header  map[string]string
+	Timeout time.Duration // 单次请求超时，为0时使用outbound.timeout
+	NoRetry bool          // 不重试（请求不幂等时使用）
+}
+
+// HTTPResponse 已读取完毕的响应
+type HTTPResponse struct {
+	StatusCode int
+	Body       []byte
+}
+
+// PostJSON 发送JSON请求
+func PostJSON(ctx context.Context, url string, body []byte) (*HTTPResponse, error) {
+	return DoHTTP(ctx, HTTPRequest{
+		Method: http.MethodPost,
+		URL:    url,
+		Body:   body,
+		Header: map[string]string{"Content-Type": "application/json"},
+	})
+}
+
+// DoHTTP 使用共享客户端发送请求并读取响应，每次尝试单独计算超时
+// 网络错误和5xx/429响应按outbound.retries重试（指数退避），ctx取消时立即返回
+func DoHTTP(ctx context.Context, request HTTPRequest) (*HTTPResponse, error) {
+	retries := config.AppConfig.GetOutboundRetries()
+	if request.NoRetry {
+		retries = 0
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			timer := time.NewTimer(httpRetryBackoff << (attempt - 1))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, fmt.Errorf("%v（上次错误: %v）", ctx.Err(), lastErr)
+			case <-timer.C:
+			}
+		}
+
+		resp, err := doHTTPOnce(ctx, request)
+		if err == nil && !retryableStatus(resp.StatusCode) {
+			return resp, nil
+		}
+		if err != nil {
+			lastErr = err
+		} else {
+			lastErr = fmt.Errorf("状态码 %d", resp.StatusCode)
+		}
+		if ctx.Err() != nil {
+			return resp, err
+		}
+		if attempt == retries {
+			// 重试耗尽时返回最后一次的响应，由调用方按状态码处理
+			return resp, err
+		}
+		AppLogger.Debug(fmt.Sprintf("出站请求失败，准备重试: %s %s, 第%d次, 错误: %v", request.Method, request.URL, attempt+1, lastErr))
+	}
+	return nil, lastErr
+}
+
+// doHTTPOnce 发送一次请求
+func doHTTPOnce(ctx context.Context, request HTTPRequest) (*HTTPResponse, error) {
+	timeout := request.Timeout
+	if timeout <= 0 {
+		timeout = config.AppConfig.GetOutboundTimeout()
+	}
+	reqCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var body io.Reader
+	if request.Body != nil {
+		body = bytes.NewReader(request.Body)
+	}
+	req, err := http.NewRequestWithContext(reqCtx, request.Method, request.URL, body)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	for key, value := range request.Header {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := sharedHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %v", err)
+	}
+	return &HTTPResponse{StatusCode: resp.StatusCode, Body: respBody}, nil
+}
+
+// retryableStatus 服务端错误和限流响应可重试
+func retryableStatus(code int) bool {
+	return code >= 500 || code == http.StatusTooManyRequests
+}
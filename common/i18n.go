@@ -0,0 +1,242 @@
+package common
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 日志和通知的语言：源码中的文案为中文（zh），其他语言的消息目录以中文原文为键
+const (
+	LocaleZh = "zh"
+	LocaleEn = "en"
+)
+
+//go:embed locales/*.yaml
+var localeFS embed.FS
+
+var (
+	currentLocale atomic.Pointer[localeCatalog] // 为nil时使用中文原文
+
+	localeCatalogsMu sync.Mutex
+	localeCatalogs   = make(map[string]*localeCatalog)
+)
+
+// localeCatalog 一种语言的消息目录
+type localeCatalog struct {
+	messages  map[string]string // 原文 -> 译文（格式串按原样作为键）
+	templates []messageTemplate // 含格式化动词的条目，用于翻译已格式化的消息
+}
+
+// messageTemplate 由格式串生成的匹配模板
+type messageTemplate struct {
+	prefix  string         // 第一个动词之前的原文，用于快速筛选
+	literal int            // 原文中非动词部分的长度，越长越优先匹配
+	pattern *regexp.Regexp // 动词替换为捕获组后的正则
+	target  string         // 译文格式串
+}
+
+// formatVerbPattern 格式化动词（含%[n]d形式的参数序号）
+var formatVerbPattern = regexp.MustCompile(`%%|%(\[\d+\])?[-+# 0]*\d*(\.\d+)?[vsdqfxXtTge]`)
+
+// SetLocale 设置日志和通知使用的语言（zh/en），未知语言或目录加载失败时使用中文
+func SetLocale(locale string) error {
+	locale = strings.ToLower(strings.TrimSpace(locale))
+	if locale == "" || locale == LocaleZh {
+		currentLocale.Store(nil)
+		return nil
+	}
+	catalog, err := loadLocaleCatalog(locale)
+	if err != nil {
+		currentLocale.Store(nil)
+		return err
+	}
+	currentLocale.Store(catalog)
+	return nil
+}
+
+// loadLocaleCatalog 加载内置的消息目录（按语言缓存）
+func loadLocaleCatalog(locale string) (*localeCatalog, error) {
+	localeCatalogsMu.Lock()
+	defer localeCatalogsMu.Unlock()
+	if catalog, ok := localeCatalogs[locale]; ok {
+		return catalog, nil
+	}
+
+	data, err := localeFS.ReadFile(path.Join("locales", locale+".yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("不支持的语言: %s", locale)
+	}
+	var messages map[string]string
+	if err := yaml.Unmarshal(data, &messages); err != nil {
+		return nil, fmt.Errorf("解析%s消息目录失败: %v", locale, err)
+	}
+
+	catalog := &localeCatalog{messages: messages}
+	for source, target := range messages {
+		if tpl, ok := compileMessageTemplate(source, target); ok {
+			catalog.templates = append(catalog.templates, tpl)
+		}
+	}
+	// 原文越具体越先匹配，避免“项目=%s, 标签=%s”吞掉“项目=%s, 标签=%s, 任务ID=%s”
+	sort.Slice(catalog.templates, func(i, j int) bool {
+		if catalog.templates[i].literal != catalog.templates[j].literal {
+			return catalog.templates[i].literal > catalog.templates[j].literal
+		}
+		return catalog.templates[i].pattern.String() < catalog.templates[j].pattern.String()
+	})
+	localeCatalogs[locale] = catalog
+	return catalog, nil
+}
+
+// compileMessageTemplate 把含动词的格式串转换为匹配模板，原文中没有中文时不生成（避免过于宽泛）
+func compileMessageTemplate(source, target string) (messageTemplate, bool) {
+	locs := formatVerbPattern.FindAllStringIndex(source, -1)
+	var (
+		expr    strings.Builder
+		literal strings.Builder
+		verbs   int
+		last    int
+	)
+	expr.WriteString("(?s)^")
+	for _, loc := range locs {
+		literal.WriteString(source[last:loc[0]])
+		expr.WriteString(regexp.QuoteMeta(source[last:loc[0]]))
+		if source[loc[0]:loc[1]] == "%%" {
+			literal.WriteString("%")
+			expr.WriteString("%")
+		} else {
+			expr.WriteString("(.*?)")
+			verbs++
+		}
+		last = loc[1]
+	}
+	literal.WriteString(source[last:])
+	expr.WriteString(regexp.QuoteMeta(source[last:]))
+	expr.WriteString("$")
+
+	if verbs == 0 || !containsHan(literal.String()) {
+		return messageTemplate{}, false
+	}
+	pattern, err := regexp.Compile(expr.String())
+	if err != nil {
+		return messageTemplate{}, false
+	}
+	prefix := source
+	if len(locs) > 0 {
+		prefix = strings.ReplaceAll(source[:locs[0][0]], "%%", "%")
+	}
+	return messageTemplate{prefix: prefix, literal: len(literal.String()), pattern: pattern, target: target}, true
+}
+
+// T 翻译一条文案：先按原文查找，找不到时按格式串模板匹配已格式化的消息，都没有时返回原文
+func T(text string) string {
+	catalog := currentLocale.Load()
+	if catalog == nil {
+		return text
+	}
+	return catalog.translate(text, 0)
+}
+
+// Tf 翻译格式串后格式化
+func Tf(format string, args ...interface{}) string {
+	if catalog := currentLocale.Load(); catalog != nil {
+		if target, ok := catalog.messages[format]; ok {
+			format = target
+		}
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// translate 翻译文案，模板捕获到的中文参数（如嵌套的错误信息）继续翻译
+func (c *localeCatalog) translate(text string, depth int) string {
+	if !containsHan(text) {
+		return text
+	}
+	if target, ok := c.messages[text]; ok {
+		return target
+	}
+	if depth >= 3 {
+		return text
+	}
+	for _, tpl := range c.templates {
+		if !strings.HasPrefix(text, tpl.prefix) {
+			continue
+		}
+		match := tpl.pattern.FindStringSubmatch(text)
+		if match == nil {
+			continue
+		}
+		args := match[1:]
+		for i := range args {
+			args[i] = c.translate(args[i], depth+1)
+		}
+		return fillMessageTemplate(tpl.target, args)
+	}
+	return text
+}
+
+// fillMessageTemplate 把捕获的参数按顺序（或%[n]指定的序号）填入译文格式串
+func fillMessageTemplate(target string, args []string) string {
+	next := 0
+	return formatVerbPattern.ReplaceAllStringFunc(target, func(verb string) string {
+		if verb == "%%" {
+			return "%"
+		}
+		index := next
+		if sub := formatVerbPattern.FindStringSubmatch(verb); sub[1] != "" {
+			if n, err := strconv.Atoi(strings.Trim(sub[1], "[]")); err == nil {
+				index = n - 1
+			}
+		}
+		next = index + 1
+		if index < 0 || index >= len(args) {
+			return verb
+		}
+		return args[index]
+	})
+}
+
+// translateLogArgs 翻译日志参数中的文案和错误信息
+func translateLogArgs(v []interface{}) []interface{} {
+	catalog := currentLocale.Load()
+	if catalog == nil {
+		return v
+	}
+	translated := make([]interface{}, len(v))
+	for i, arg := range v {
+		switch value := arg.(type) {
+		case string:
+			translated[i] = catalog.translate(value, 0)
+		case error:
+			if text := value.Error(); containsHan(text) {
+				translated[i] = errors.New(catalog.translate(text, 0))
+			} else {
+				translated[i] = value
+			}
+		default:
+			translated[i] = arg
+		}
+	}
+	return translated
+}
+
+// containsHan 文本中是否包含汉字
+func containsHan(text string) bool {
+	for _, r := range text {
+		if unicode.Is(unicode.Han, r) {
+			return true
+		}
+	}
+	return false
+}
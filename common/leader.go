@@ -0,0 +1,289 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"time"
+
+	"cicd-agent/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 主备转发相关的请求/响应头
+const (
+	LeaderHeader          = "X-Cicd-Leader"       // 响应中给出当前主节点标识
+	leaderForwardedHeader = "X-Cicd-Forwarded-By" // 备用节点转发的请求，主节点不会再次转发
+)
+
+// LeaderStatus 选主状态
+type LeaderStatus struct {
+	Enabled    bool   `json:"enabled"`
+	Backend    string `json:"backend,omitempty"`
+	Identity   string `json:"identity,omitempty"`
+	IsLeader   bool   `json:"is_leader"`
+	Leader     string `json:"leader,omitempty"`      // 当前主节点标识
+	LeaderURL  string `json:"leader_url,omitempty"`  // 当前主节点访问地址
+	AcquiredAt string `json:"acquired_at,omitempty"` // 当前主节点取得租约的时间
+	RenewedAt  string `json:"renewed_at,omitempty"`  // 最近一次续约时间
+}
+
+var (
+	leaderMu      sync.RWMutex
+	leaderEnabled bool
+	leaderIsSelf  bool
+	leaderCurrent leaderRecord
+	leaderStop    chan struct{}
+
+	leaderProxyMu sync.Mutex
+	leaderProxies = make(map[string]*httputil.ReverseProxy)
+)
+
+// leaderElector 选主循环的状态
+type leaderElector struct {
+	cfg      config.LeaderElectionConfig
+	identity string
+	backend  leaseBackend
+
+	observed   leaderRecord // 最近一次观察到的其他节点租约
+	observedAt time.Time    // 观察到该租约的本地时间（按本地时钟判断过期，不依赖节点间时钟同步）
+	renewedAt  time.Time    // 本节点最近一次续约成功的时间
+}
+
+// StartLeaderElection 按leader_election配置启动选主（重新加载配置后需再次调用）
+// 未开启时本节点始终视为主节点
+func StartLeaderElection() {
+	leaderMu.Lock()
+	if leaderStop != nil {
+		close(leaderStop)
+		leaderStop = nil
+	}
+	cfg := config.AppConfig.LeaderElection
+	leaderEnabled = cfg.Enable
+	if !cfg.Enable {
+		leaderIsSelf = false
+		leaderCurrent = leaderRecord{}
+		leaderMu.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	leaderStop = stop
+	leaderMu.Unlock()
+
+	elector := &leaderElector{cfg: cfg, identity: cfg.GetIdentity(), backend: newLeaseBackend(cfg)}
+	AppLogger.Info(fmt.Sprintf("选主已启用: 后端=%s, 节点=%s, 租约=%s, 备用节点处理方式=%s",
+		cfg.GetBackend(), elector.identity, cfg.GetLeaseDuration(), cfg.GetStandbyMode()))
+
+	// 先同步竞选一次，减少启动后没有主节点的时间
+	elector.round(stop)
+	go func() {
+		ticker := time.NewTicker(cfg.GetRenewInterval())
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				elector.round(stop)
+			}
+		}
+	}()
+}
+
+// round 一轮竞选/续约
+func (e *leaderElector) round(stop chan struct{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.cfg.GetRenewInterval())
+	defer cancel()
+	now := time.Now()
+
+	record, version, err := e.backend.get(ctx)
+	if err != nil {
+		AppLogger.Warning("读取选主租约失败:", err)
+		e.checkRenewDeadline(stop, now)
+		return
+	}
+
+	if record.Holder != "" && record.Holder != e.identity {
+		if record != e.observed {
+			e.observed = record
+			e.observedAt = now
+		}
+		leaseDuration := time.Duration(record.LeaseSeconds) * time.Second
+		if leaseDuration <= 0 {
+			leaseDuration = e.cfg.GetLeaseDuration()
+		}
+		if now.Sub(e.observedAt) < leaseDuration {
+			setLeaderState(stop, false, record)
+			return
+		}
+		AppLogger.Warning(fmt.Sprintf("主节点 %s 的租约已过期，尝试接管", record.Holder))
+	}
+
+	candidate := leaderRecord{
+		Holder:       e.identity,
+		AdvertiseURL: e.cfg.AdvertiseURL,
+		AcquiredAt:   now,
+		RenewedAt:    now,
+		LeaseSeconds: int(e.cfg.GetLeaseDuration() / time.Second),
+	}
+	if record.Holder == e.identity && !record.AcquiredAt.IsZero() {
+		candidate.AcquiredAt = record.AcquiredAt
+	}
+	if err := e.backend.update(ctx, candidate, version); err != nil {
+		if !errors.Is(err, errLeaseConflict) {
+			AppLogger.Warning("更新选主租约失败:", err)
+		}
+		e.checkRenewDeadline(stop, now)
+		return
+	}
+	e.renewedAt = now
+	setLeaderState(stop, true, candidate)
+}
+
+// checkRenewDeadline 主节点续约失败超过租约有效期时主动降为备用节点（此时其他节点可能已经接管）
+func (e *leaderElector) checkRenewDeadline(stop chan struct{}, now time.Time) {
+	if IsLeader() && now.Sub(e.renewedAt) >= e.cfg.GetLeaseDuration() {
+		setLeaderState(stop, false, leaderRecord{})
+	}
+}
+
+// setLeaderState 更新选主状态，角色变化时记录日志（选主循环已被新配置替换时忽略）
+func setLeaderState(stop chan struct{}, isLeader bool, record leaderRecord) {
+	leaderMu.Lock()
+	if leaderStop != stop {
+		leaderMu.Unlock()
+		return
+	}
+	wasLeader, previous := leaderIsSelf, leaderCurrent.Holder
+	leaderIsSelf = isLeader
+	leaderCurrent = record
+	leaderMu.Unlock()
+
+	switch {
+	case isLeader && !wasLeader:
+		AppLogger.Info("本节点成为主节点:", record.Holder)
+	case !isLeader && wasLeader:
+		AppLogger.Warning("本节点不再是主节点，当前主节点:", record.Holder)
+	case !isLeader && record.Holder != previous && record.Holder != "":
+		AppLogger.Info(fmt.Sprintf("当前主节点: %s (%s)", record.Holder, record.AdvertiseURL))
+	}
+}
+
+// IsLeader 本节点是否为主节点（未开启选主时始终为true）
+func IsLeader() bool {
+	leaderMu.RLock()
+	defer leaderMu.RUnlock()
+	return !leaderEnabled || leaderIsSelf
+}
+
+// GetLeaderStatus 获取选主状态
+func GetLeaderStatus() LeaderStatus {
+	leaderMu.RLock()
+	defer leaderMu.RUnlock()
+	if !leaderEnabled {
+		return LeaderStatus{IsLeader: true}
+	}
+	cfg := config.AppConfig.LeaderElection
+	status := LeaderStatus{
+		Enabled:   true,
+		Backend:   cfg.GetBackend(),
+		Identity:  cfg.GetIdentity(),
+		IsLeader:  leaderIsSelf,
+		Leader:    leaderCurrent.Holder,
+		LeaderURL: leaderCurrent.AdvertiseURL,
+	}
+	if !leaderCurrent.AcquiredAt.IsZero() {
+		status.AcquiredAt = leaderCurrent.AcquiredAt.Format("2006-01-02 15:04:05")
+		status.RenewedAt = leaderCurrent.RenewedAt.Format("2006-01-02 15:04:05")
+	}
+	return status
+}
+
+// LeaderMiddleware 开启选主时只有主节点处理请求，备用节点按standby_mode转发、重定向到主节点或拒绝
+// 转发不携带调用方的客户端证书，使用mTLS认证调用方时应配置为redirect
+func LeaderMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if IsLeader() {
+			c.Next()
+			return
+		}
+
+		status := GetLeaderStatus()
+		if status.Leader == "" || status.LeaderURL == "" {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"code": 503, "msg": "当前没有可用的主节点，请稍后重试"})
+			c.Abort()
+			return
+		}
+		c.Header(LeaderHeader, status.Leader)
+
+		switch config.AppConfig.LeaderElection.GetStandbyMode() {
+		case config.StandbyModeRedirect:
+			// 307保留请求方法和请求体
+			c.Redirect(http.StatusTemporaryRedirect, status.LeaderURL+c.Request.URL.RequestURI())
+			c.Abort()
+		case config.StandbyModeReject:
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"code": 503,
+				"msg":  "本节点为备用节点，请调用主节点",
+				"data": gin.H{"leader": status.Leader, "leader_url": status.LeaderURL},
+			})
+			c.Abort()
+		default:
+			if c.GetHeader(leaderForwardedHeader) != "" {
+				// 转发方认为本节点是主节点，说明两边的选主状态暂时不一致，不再继续转发
+				c.JSON(http.StatusServiceUnavailable, gin.H{"code": 503, "msg": "主节点正在切换，请稍后重试"})
+				c.Abort()
+				return
+			}
+			proxy, err := leaderProxy(status.LeaderURL)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "主节点地址错误: " + err.Error()})
+				c.Abort()
+				return
+			}
+			clientIP := GetClientIP(c)
+			c.Request.Header.Set("X-Forwarded-For", clientIP)
+			c.Request.Header.Set("X-Real-IP", clientIP)
+			c.Request.Header.Set(leaderForwardedHeader, status.Identity)
+			proxy.ServeHTTP(c.Writer, c.Request)
+			c.Abort()
+		}
+	}
+}
+
+// leaderProxy 获取转发到主节点的反向代理（按地址缓存）
+func leaderProxy(leaderURL string) (*httputil.ReverseProxy, error) {
+	leaderProxyMu.Lock()
+	defer leaderProxyMu.Unlock()
+	if proxy, ok := leaderProxies[leaderURL]; ok {
+		return proxy, nil
+	}
+
+	target, err := url.Parse(leaderURL)
+	if err != nil {
+		return nil, err
+	}
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(target)
+			// 保留调用方IP（主节点需将备用节点加入server.trusted_proxies）
+			r.Out.Header.Set("X-Forwarded-For", r.In.Header.Get("X-Forwarded-For"))
+			r.Out.Header.Set("X-Real-IP", r.In.Header.Get("X-Real-IP"))
+		},
+		Transport:     HTTPClient().Transport,
+		FlushInterval: -1, // SSE日志流立即转发
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			AppLogger.Warning("转发请求到主节点失败:", err)
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusBadGateway)
+			fmt.Fprintf(w, `{"code":502,"msg":"转发请求到主节点失败"}`)
+		},
+	}
+	leaderProxies[leaderURL] = proxy
+	return proxy, nil
+}
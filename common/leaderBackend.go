@@ -0,0 +1,251 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cicd-agent/config"
+)
+
+// errLeaseConflict 租约在读取后已被其他节点修改
+var errLeaseConflict = errors.New("租约已被其他节点更新")
+
+// leaderRecord 租约内容
+type leaderRecord struct {
+	Holder       string    `json:"holder"`        // 持有者节点标识，为空表示租约空闲
+	AdvertiseURL string    `json:"advertise_url"` // 持有者的访问地址
+	AcquiredAt   time.Time `json:"acquired_at"`
+	RenewedAt    time.Time `json:"renewed_at"`
+	LeaseSeconds int       `json:"lease_seconds"`
+}
+
+// leaseBackend 租约存储：get返回当前租约和版本（不存在时返回空记录和空版本），
+// update仅在版本未变化时写入，否则返回errLeaseConflict
+type leaseBackend interface {
+	get(ctx context.Context) (leaderRecord, string, error)
+	update(ctx context.Context, record leaderRecord, version string) error
+}
+
+// newLeaseBackend 按配置创建租约存储
+func newLeaseBackend(cfg config.LeaderElectionConfig) leaseBackend {
+	if cfg.GetBackend() == config.LeaderBackendKubernetes {
+		return &kubernetesLease{
+			name:      cfg.Kubernetes.GetLeaseName(),
+			namespace: cfg.Kubernetes.GetLeaseNamespace(),
+			timeout:   cfg.GetRenewInterval(),
+		}
+	}
+	return &fileLease{path: cfg.File.Path}
+}
+
+// fileLease 共享存储上的租约文件，读写时对文件加flock，版本为文件内容的哈希
+type fileLease struct {
+	path string
+}
+
+// get 读取租约文件
+func (l *fileLease) get(ctx context.Context) (leaderRecord, string, error) {
+	var record leaderRecord
+	var version string
+	err := l.withLock(func(f *os.File) error {
+		var err error
+		record, version, err = readLeaseFile(f)
+		return err
+	})
+	return record, version, err
+}
+
+// update 版本未变化时覆盖租约文件
+func (l *fileLease) update(ctx context.Context, record leaderRecord, version string) error {
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	return l.withLock(func(f *os.File) error {
+		_, current, err := readLeaseFile(f)
+		if err != nil {
+			return err
+		}
+		if current != version {
+			return errLeaseConflict
+		}
+		if err := f.Truncate(0); err != nil {
+			return fmt.Errorf("写入租约文件失败: %v", err)
+		}
+		if _, err := f.WriteAt(data, 0); err != nil {
+			return fmt.Errorf("写入租约文件失败: %v", err)
+		}
+		return f.Sync()
+	})
+}
+
+// withLock 打开租约文件并加锁后执行fn
+func (l *fileLease) withLock(fn func(f *os.File) error) error {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return fmt.Errorf("创建租约文件目录失败: %v", err)
+	}
+	f, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("打开租约文件失败: %v", err)
+	}
+	defer f.Close()
+	if err := lockFile(f); err != nil {
+		return fmt.Errorf("锁定租约文件失败: %v", err)
+	}
+	defer unlockFile(f)
+	return fn(f)
+}
+
+// readLeaseFile 读取租约文件内容，空文件视为租约不存在
+func readLeaseFile(f *os.File) (leaderRecord, string, error) {
+	var record leaderRecord
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return record, "", fmt.Errorf("读取租约文件失败: %v", err)
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return record, "", fmt.Errorf("读取租约文件失败: %v", err)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return record, "", nil
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return record, "", fmt.Errorf("解析租约文件失败: %v", err)
+	}
+	sum := sha256.Sum256(data)
+	return record, hex.EncodeToString(sum[:]), nil
+}
+
+// kubernetesLease Kubernetes Lease（通过kubectl读写），版本为resourceVersion，
+// 持有者访问地址保存在注解中
+type kubernetesLease struct {
+	name      string
+	namespace string
+	timeout   time.Duration
+}
+
+// leaseAdvertiseAnnotation 保存持有者访问地址的注解
+const leaseAdvertiseAnnotation = "cicd-agent/advertise-url"
+
+// k8sLeaseTimeFormat Lease中MicroTime的格式
+const k8sLeaseTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// k8sLease Lease对象中用到的字段
+type k8sLease struct {
+	Metadata struct {
+		ResourceVersion string            `json:"resourceVersion"`
+		Annotations     map[string]string `json:"annotations"`
+	} `json:"metadata"`
+	Spec struct {
+		HolderIdentity       string `json:"holderIdentity"`
+		LeaseDurationSeconds int    `json:"leaseDurationSeconds"`
+		AcquireTime          string `json:"acquireTime"`
+		RenewTime            string `json:"renewTime"`
+	} `json:"spec"`
+}
+
+// get 读取Lease，不存在时返回空记录
+func (l *kubernetesLease) get(ctx context.Context) (leaderRecord, string, error) {
+	output, err := l.kubectl(ctx, "get", "lease", l.name, "-n", l.namespace, "-o", "json")
+	if err != nil {
+		if strings.Contains(string(output), "NotFound") {
+			return leaderRecord{}, "", nil
+		}
+		return leaderRecord{}, "", fmt.Errorf("读取Lease失败: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	var lease k8sLease
+	if err := json.Unmarshal(output, &lease); err != nil {
+		return leaderRecord{}, "", fmt.Errorf("解析Lease失败: %v", err)
+	}
+	record := leaderRecord{
+		Holder:       lease.Spec.HolderIdentity,
+		AdvertiseURL: lease.Metadata.Annotations[leaseAdvertiseAnnotation],
+		LeaseSeconds: lease.Spec.LeaseDurationSeconds,
+	}
+	record.AcquiredAt, _ = time.Parse(k8sLeaseTimeFormat, lease.Spec.AcquireTime)
+	record.RenewedAt, _ = time.Parse(k8sLeaseTimeFormat, lease.Spec.RenewTime)
+	return record, lease.Metadata.ResourceVersion, nil
+}
+
+// update Lease不存在时创建，否则带resourceVersion合并更新（期间被修改时API Server返回Conflict）
+func (l *kubernetesLease) update(ctx context.Context, record leaderRecord, version string) error {
+	lease := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{leaseAdvertiseAnnotation: record.AdvertiseURL},
+		},
+		"spec": map[string]interface{}{
+			"holderIdentity":       record.Holder,
+			"leaseDurationSeconds": record.LeaseSeconds,
+			"acquireTime":          record.AcquiredAt.UTC().Format(k8sLeaseTimeFormat),
+			"renewTime":            record.RenewedAt.UTC().Format(k8sLeaseTimeFormat),
+		},
+	}
+
+	if version == "" {
+		metadata := lease["metadata"].(map[string]interface{})
+		metadata["name"] = l.name
+		metadata["namespace"] = l.namespace
+		lease["apiVersion"] = "coordination.k8s.io/v1"
+		lease["kind"] = "Lease"
+		return l.create(ctx, lease)
+	}
+
+	lease["metadata"].(map[string]interface{})["resourceVersion"] = version
+	patch, err := json.Marshal(lease)
+	if err != nil {
+		return err
+	}
+	output, err := l.kubectl(ctx, "patch", "lease", l.name, "-n", l.namespace, "--type=merge", "-p", string(patch))
+	if err != nil {
+		if strings.Contains(string(output), "Conflict") || strings.Contains(string(output), "the object has been modified") {
+			return errLeaseConflict
+		}
+		return fmt.Errorf("更新Lease失败: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// create 创建Lease，已被其他节点创建时返回errLeaseConflict
+func (l *kubernetesLease) create(ctx context.Context, lease map[string]interface{}) error {
+	data, err := json.Marshal(lease)
+	if err != nil {
+		return err
+	}
+	file, err := os.CreateTemp("", "cicd-agent-lease-*.json")
+	if err != nil {
+		return fmt.Errorf("创建Lease清单文件失败: %v", err)
+	}
+	defer os.Remove(file.Name())
+	_, err = file.Write(data)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("写入Lease清单文件失败: %v", err)
+	}
+
+	output, err := l.kubectl(ctx, "create", "-f", file.Name())
+	if err != nil {
+		if strings.Contains(string(output), "AlreadyExists") {
+			return errLeaseConflict
+		}
+		return fmt.Errorf("创建Lease失败: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// kubectl 执行kubectl命令，超时为续约间隔
+func (l *kubernetesLease) kubectl(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := NewCommand("kubectl", args...)
+	cmd.Timeout = l.timeout
+	return RunCommand(ctx, cmd)
+}
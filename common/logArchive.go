@@ -0,0 +1,99 @@
+package common
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"cicd-agent/config"
+)
+
+// taskLogDownloadPath 任务日志下载接口路径
+const taskLogDownloadPath = "/api/v1/logs/download"
+
+// TaskArtifact 任务产物（如日志下载地址）
+type TaskArtifact struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// IsSafeTaskID 任务ID不能包含路径分隔符或上级目录，防止访问logs目录以外的文件
+func IsSafeTaskID(taskID string) bool {
+	return taskID != "" && taskID != "." && !strings.ContainsAny(taskID, `/\`) && !strings.Contains(taskID, "..")
+}
+
+// TaskLogDownloadURL 任务日志下载地址，未配置对外地址时返回空
+func TaskLogDownloadURL(taskID string) string {
+	base := config.AppConfig.GetPublicURL()
+	if base == "" {
+		return ""
+	}
+	return base + taskLogDownloadPath + "?task_id=" + url.QueryEscape(taskID)
+}
+
+// taskArtifacts 任务通知附带的产物列表
+func taskArtifacts(taskID string) []TaskArtifact {
+	if downloadURL := TaskLogDownloadURL(taskID); downloadURL != "" {
+		return []TaskArtifact{{Name: "logs", URL: downloadURL}}
+	}
+	return nil
+}
+
+// WriteTaskLogArchive 将任务日志目录打包为tar.gz写入w（已压缩的日志原样打包）
+func WriteTaskLogArchive(w io.Writer, taskID string) error {
+	logDir := filepath.Join("logs", taskID)
+	entries, err := os.ReadDir(logDir)
+	if err != nil {
+		return fmt.Errorf("读取任务日志目录失败: %v", err)
+	}
+
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if err := addFileToTar(tarWriter, filepath.Join(logDir, entry.Name()), taskID+"/"+entry.Name()); err != nil {
+			return err
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return fmt.Errorf("写入日志归档失败: %v", err)
+	}
+	return gzipWriter.Close()
+}
+
+// addFileToTar 写入单个文件到tar
+func addFileToTar(tarWriter *tar.Writer, path, name string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("打开日志文件失败: %v", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("读取日志文件信息失败: %v", err)
+	}
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return fmt.Errorf("构建归档头失败: %v", err)
+	}
+	header.Name = name
+
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return fmt.Errorf("写入归档头失败: %v", err)
+	}
+	// 日志可能仍在写入，只复制打开时的大小
+	if _, err := io.CopyN(tarWriter, file, info.Size()); err != nil {
+		return fmt.Errorf("写入日志内容失败: %v", err)
+	}
+	return nil
+}
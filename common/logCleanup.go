@@ -1,23 +1,80 @@
 package common
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"sync"
 	"time"
+
+	"cicd-agent/config"
 )
 
 // LogRetentionConfig 日志保留配置
 type LogRetentionConfig struct {
-	MaxDays int // 保留天数
+	MaxDays       int           // 保留天数
+	CleanupHour   int           // 每日清理时间（时）
+	CleanupMinute int           // 每日清理时间（分）
+	MaxTotalSize  int64         // 日志目录总大小上限（字节），0表示不限制
+	Compress      bool          // 是否压缩已结束任务的日志
+	CompressDelay time.Duration // 任务结束多久后压缩
 }
 
 // DefaultLogRetention 默认日志保留配置
 var DefaultLogRetention = LogRetentionConfig{
-	MaxDays: 7, // 默认保留7天
+	MaxDays:       7,                // 默认保留7天
+	CleanupHour:   2,                // 默认凌晨2点清理
+	Compress:      true,             // 默认压缩已结束任务的日志
+	CompressDelay: 10 * time.Minute, // 默认任务结束10分钟后压缩
+}
+
+// logSizeCheckInterval 配置了大小上限时的检查间隔
+const logSizeCheckInterval = 10 * time.Minute
+
+// TaskRequestFile 任务日志目录下的回调参数文件名（重试和回滚使用）
+const TaskRequestFile = "request.json"
+
+// durableTaskFiles 发布历史、回滚和重试依赖的任务记录，清理日志时保留（每个任务只占几KB）
+var durableTaskFiles = map[string]bool{
+	taskLogMetaFile: true,
+	taskReleaseFile: true,
+	TaskRequestFile: true,
 }
 
-// CleanupOldLogs 清理过期的日志目录
-func CleanupOldLogs(maxDays int) error {
+// 当前运行的定时清理任务（重新加载配置时需要先停止）
+var (
+	logCleanupMu   sync.Mutex
+	logCleanupStop chan struct{}
+)
+
+// CurrentLogRetention 根据当前配置生成日志保留配置
+func CurrentLogRetention() LogRetentionConfig {
+	if config.AppConfig == nil {
+		return DefaultLogRetention
+	}
+	hour, minute := config.AppConfig.GetLogCleanupTime()
+	return LogRetentionConfig{
+		MaxDays:       config.AppConfig.GetLogRetentionDays(),
+		CleanupHour:   hour,
+		CleanupMinute: minute,
+		MaxTotalSize:  config.AppConfig.GetLogMaxTotalSize(),
+		Compress:      config.AppConfig.IsLogCompressEnabled(),
+		CompressDelay: config.AppConfig.GetLogCompressDelay(),
+	}
+}
+
+// logDirInfo 任务日志目录信息
+type logDirInfo struct {
+	path    string
+	taskID  string
+	modTime time.Time
+	size    int64
+}
+
+// CleanupOldLogs 清理日志目录：先清理过期目录，再在总大小超限时从最旧的目录开始清理
+// 清理只删除日志等文件，保留任务记录（见durableTaskFiles），没有任务记录的目录整个删除
+func CleanupOldLogs(retention LogRetentionConfig) error {
 	logsDir := "logs"
 
 	// 检查logs目录是否存在
@@ -25,8 +82,8 @@ func CleanupOldLogs(maxDays int) error {
 		return nil
 	}
 
-	cutoffTime := time.Now().AddDate(0, 0, -maxDays)
-	AppLogger.Info("开始清理日志，保留天数:", maxDays)
+	cutoffTime := time.Now().AddDate(0, 0, -retention.MaxDays)
+	AppLogger.Info("开始清理日志，保留天数:", retention.MaxDays)
 
 	// 遍历logs目录
 	entries, err := os.ReadDir(logsDir)
@@ -35,6 +92,7 @@ func CleanupOldLogs(maxDays int) error {
 	}
 
 	deletedCount := 0
+	var remaining []logDirInfo
 	for _, entry := range entries {
 		if !entry.IsDir() {
 			continue
@@ -50,14 +108,31 @@ func CleanupOldLogs(maxDays int) error {
 		}
 
 		// 检查目录修改时间
-		if info.ModTime().Before(cutoffTime) {
-			if err := os.RemoveAll(dirPath); err != nil {
+		if info.ModTime().Before(cutoffTime) && !taskLogInUse(entry.Name()) {
+			pruned, _, err := pruneTaskLogDir(dirPath)
+			if err != nil {
 				AppLogger.Error("删除日志目录失败:", dirPath, err)
-			} else {
+			} else if pruned {
 				deletedCount++
 				AppLogger.Debug("删除过期日志目录:", dirPath)
 			}
+			continue
 		}
+
+		remaining = append(remaining, logDirInfo{
+			path:    dirPath,
+			taskID:  entry.Name(),
+			modTime: info.ModTime(),
+		})
+	}
+
+	// 补压缩已结束但尚未压缩的任务日志（如进程重启导致延迟压缩未执行）
+	if retention.Compress {
+		compressFinishedLogs(remaining, retention.CompressDelay)
+	}
+
+	if retention.MaxTotalSize > 0 {
+		deletedCount += cleanupLogsBySize(remaining, retention.MaxTotalSize)
 	}
 
 	if deletedCount > 0 {
@@ -67,30 +142,186 @@ func CleanupOldLogs(maxDays int) error {
 	return nil
 }
 
+// compressFinishedLogs 压缩已结束任务的日志目录
+func compressFinishedLogs(dirs []logDirInfo, delay time.Duration) {
+	threshold := time.Now().Add(-delay)
+	for _, dir := range dirs {
+		if taskLogInUse(dir.taskID) || dir.modTime.After(threshold) {
+			continue
+		}
+		if err := CompressTaskLogs(dir.taskID); err != nil {
+			AppLogger.Warning(fmt.Sprintf("压缩任务日志失败: 任务=%s, 错误=%v", dir.taskID, err))
+		}
+	}
+}
+
+// cleanupLogsBySize 日志总大小超过上限时，从最旧的任务目录开始删除，直到低于上限
+// 正在执行和等待执行的任务目录不会被删除
+func cleanupLogsBySize(dirs []logDirInfo, maxTotalSize int64) int {
+	var totalSize int64
+	for i := range dirs {
+		dirs[i].size = dirSize(dirs[i].path)
+		totalSize += dirs[i].size
+	}
+	if totalSize <= maxTotalSize {
+		return 0
+	}
+
+	AppLogger.Warning(fmt.Sprintf("日志目录总大小 %d 字节超过上限 %d 字节，开始删除最旧的任务日志", totalSize, maxTotalSize))
+
+	sort.Slice(dirs, func(i, j int) bool {
+		return dirs[i].modTime.Before(dirs[j].modTime)
+	})
+
+	deletedCount := 0
+	for _, dir := range dirs {
+		if totalSize <= maxTotalSize {
+			break
+		}
+		if taskLogInUse(dir.taskID) {
+			continue
+		}
+		pruned, freed, err := pruneTaskLogDir(dir.path)
+		if err != nil {
+			AppLogger.Error("删除日志目录失败:", dir.path, err)
+			continue
+		}
+		if !pruned {
+			continue
+		}
+		totalSize -= freed
+		deletedCount++
+		AppLogger.Debug("日志目录超出大小上限，删除:", dir.path)
+	}
+
+	if totalSize > maxTotalSize {
+		AppLogger.Warning(fmt.Sprintf("清理后日志目录总大小 %d 字节仍超过上限（剩余为执行中任务的日志）", totalSize))
+	}
+	return deletedCount
+}
+
+// pruneTaskLogDir 删除任务目录下除任务记录以外的文件，没有任务记录时删除整个目录
+// 返回是否删除了内容和释放的字节数；保留记录时恢复目录修改时间，避免清理操作让旧任务看起来像新任务
+func pruneTaskLogDir(dirPath string) (bool, int64, error) {
+	dirInfo, err := os.Stat(dirPath)
+	if err != nil {
+		return false, 0, err
+	}
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		return false, 0, err
+	}
+
+	var freed int64
+	pruned, kept := false, false
+	for _, entry := range entries {
+		if !entry.IsDir() && durableTaskFiles[entry.Name()] {
+			kept = true
+			continue
+		}
+		path := filepath.Join(dirPath, entry.Name())
+		size := dirSize(path)
+		if err := os.RemoveAll(path); err != nil {
+			return pruned, freed, err
+		}
+		pruned = true
+		freed += size
+	}
+
+	if !kept {
+		return true, freed, os.Remove(dirPath)
+	}
+	if pruned {
+		os.Chtimes(dirPath, dirInfo.ModTime(), dirInfo.ModTime())
+	}
+	return pruned, freed, nil
+}
+
+// taskLogInUse 任务正在执行或是等待执行的计划任务时，其日志目录不能压缩或删除
+func taskLogInUse(taskID string) bool {
+	if IsTaskRunning(taskID) {
+		return true
+	}
+	meta, err := ReadTaskLogMeta(taskID)
+	return err == nil && meta.Status == "scheduled"
+}
+
+// dirSize 计算目录下所有文件的总大小
+func dirSize(path string) int64 {
+	var size int64
+	filepath.WalkDir(path, func(_ string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
 // StartLogCleanupRoutine 启动日志清理定时任务
-func StartLogCleanupRoutine(maxDays int) {
+// 重复调用时会停止上一次启动的定时任务并按新配置重新启动（用于配置重新加载）
+func StartLogCleanupRoutine(retention LogRetentionConfig) {
+	logCleanupMu.Lock()
+	if logCleanupStop != nil {
+		close(logCleanupStop)
+	}
+	stop := make(chan struct{})
+	logCleanupStop = stop
+	logCleanupMu.Unlock()
+
 	// 启动时清理一次
 	go func() {
-		if err := CleanupOldLogs(maxDays); err != nil {
+		if err := CleanupOldLogs(retention); err != nil {
 			AppLogger.Error("日志清理失败:", err)
 		}
 	}()
 
-	// 每天凌晨2点清理
+	// 每天在配置的时间清理
 	go func() {
 		for {
 			now := time.Now()
-			// 计算下次凌晨2点的时间
-			next := time.Date(now.Year(), now.Month(), now.Day()+1, 2, 0, 0, 0, now.Location())
-			duration := next.Sub(now)
+			// 计算下次清理时间
+			next := time.Date(now.Year(), now.Month(), now.Day(), retention.CleanupHour, retention.CleanupMinute, 0, 0, now.Location())
+			if !next.After(now) {
+				next = next.AddDate(0, 0, 1)
+			}
 
-			time.Sleep(duration)
+			timer := time.NewTimer(next.Sub(now))
+			select {
+			case <-stop:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
 
-			if err := CleanupOldLogs(maxDays); err != nil {
+			if err := CleanupOldLogs(retention); err != nil {
 				AppLogger.Error("定时日志清理失败:", err)
 			}
 		}
 	}()
 
-	AppLogger.Info("日志清理定时任务已启动")
+	// 配置了大小上限时，定期检查总大小，避免部署高峰期间磁盘被写满
+	if retention.MaxTotalSize > 0 {
+		go func() {
+			ticker := time.NewTicker(logSizeCheckInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-stop:
+					return
+				case <-ticker.C:
+					if err := CleanupOldLogs(retention); err != nil {
+						AppLogger.Error("日志大小检查清理失败:", err)
+					}
+				}
+			}
+		}()
+	}
+
+	AppLogger.Info(fmt.Sprintf("日志清理定时任务已启动，保留%d天，每日%02d:%02d执行", retention.MaxDays, retention.CleanupHour, retention.CleanupMinute))
 }
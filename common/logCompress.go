@@ -0,0 +1,213 @@
+package common
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cicd-agent/config"
+)
+
+// compressedLogExt 压缩日志文件后缀（logs/{任务ID}/{步骤}.log.gz）
+const compressedLogExt = ".gz"
+
+// ScheduleTaskLogCompression 任务结束后延迟压缩任务日志
+// 延迟期间仍在查看日志的客户端可以读完剩余内容
+func ScheduleTaskLogCompression(taskID string) {
+	if config.AppConfig == nil || !config.AppConfig.IsLogCompressEnabled() {
+		return
+	}
+
+	time.AfterFunc(config.AppConfig.GetLogCompressDelay(), func() {
+		// 任务可能被重新执行，仍在运行时跳过，由夜间清理补压缩
+		if IsTaskRunning(taskID) {
+			return
+		}
+		if err := CompressTaskLogs(taskID); err != nil {
+			AppLogger.Warning(fmt.Sprintf("压缩任务日志失败: 任务=%s, 错误=%v", taskID, err))
+		}
+	})
+}
+
+// CompressTaskLogs 将任务日志目录下的所有.log文件压缩为.log.gz
+func CompressTaskLogs(taskID string) error {
+	logDir := filepath.Join("logs", taskID)
+
+	dirInfo, err := os.Stat(logDir)
+	if err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(logDir)
+	if err != nil {
+		return err
+	}
+
+	compressed := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".log") {
+			continue
+		}
+		if err := compressLogFile(filepath.Join(logDir, entry.Name())); err != nil {
+			return fmt.Errorf("压缩日志文件 %s 失败: %v", entry.Name(), err)
+		}
+		compressed++
+	}
+
+	// 恢复目录修改时间，避免压缩操作影响按时间清理
+	if compressed > 0 {
+		os.Chtimes(logDir, dirInfo.ModTime(), dirInfo.ModTime())
+		AppLogger.Debug(fmt.Sprintf("任务日志已压缩: 任务=%s, 文件数=%d", taskID, compressed))
+	}
+	return nil
+}
+
+// compressLogFile 压缩单个日志文件
+// 已存在.gz时（任务重新执行追加了日志）会将新内容合并到压缩文件末尾
+func compressLogFile(logPath string) error {
+	gzPath := logPath + compressedLogExt
+	tmpPath := gzPath + ".tmp"
+
+	tmpFile, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+
+	gzWriter := gzip.NewWriter(tmpFile)
+
+	// 先写入已有的压缩内容
+	if existing, err := readCompressedLog(logPath); err == nil {
+		if _, err := gzWriter.Write(existing); err != nil {
+			tmpFile.Close()
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		tmpFile.Close()
+		return err
+	}
+
+	plainFile, err := os.Open(logPath)
+	if err != nil {
+		tmpFile.Close()
+		return err
+	}
+	_, err = io.Copy(gzWriter, plainFile)
+	plainFile.Close()
+	if err != nil {
+		tmpFile.Close()
+		return err
+	}
+
+	if err := gzWriter.Close(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, gzPath); err != nil {
+		return err
+	}
+	return os.Remove(logPath)
+}
+
+// readCompressedLog 读取日志文件对应的.gz压缩内容（参数为未压缩的.log路径）
+func readCompressedLog(logPath string) ([]byte, error) {
+	file, err := os.Open(logPath + compressedLogExt)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	gzReader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer gzReader.Close()
+
+	return io.ReadAll(gzReader)
+}
+
+// readTaskLogFile 读取任务日志完整内容，透明处理压缩文件
+// 返回内容为压缩部分与未压缩部分依次拼接，plainSize为未压缩.log文件的大小（用于后续增量读取）
+// 两种文件都不存在时返回os.IsNotExist可判断的错误
+func readTaskLogFile(logPath string) ([]byte, int64, error) {
+	compressed, gzErr := readCompressedLog(logPath)
+	if gzErr != nil && !os.IsNotExist(gzErr) {
+		return nil, 0, gzErr
+	}
+
+	plain, err := os.ReadFile(logPath)
+	if err != nil {
+		if os.IsNotExist(err) && gzErr == nil {
+			return compressed, 0, nil
+		}
+		return nil, 0, err
+	}
+
+	if len(compressed) == 0 {
+		return plain, int64(len(plain)), nil
+	}
+	var buf bytes.Buffer
+	buf.Write(compressed)
+	if !bytes.HasSuffix(compressed, []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+	buf.Write(plain)
+	return buf.Bytes(), int64(len(plain)), nil
+}
+
+// taskLogFileExists 判断任务日志文件（含压缩文件）是否存在
+func taskLogFileExists(logPath string) bool {
+	if _, err := os.Stat(logPath); err == nil {
+		return true
+	}
+	_, err := os.Stat(logPath + compressedLogExt)
+	return err == nil
+}
+
+// openTaskLogReader 打开任务日志的顺序读取流（先压缩部分后未压缩部分）
+func openTaskLogReader(logPath string) (io.ReadCloser, error) {
+	var readers []io.Reader
+	var closers []io.Closer
+
+	if gzFile, err := os.Open(logPath + compressedLogExt); err == nil {
+		gzReader, err := gzip.NewReader(gzFile)
+		if err != nil {
+			gzFile.Close()
+			return nil, err
+		}
+		readers = append(readers, gzReader)
+		closers = append(closers, gzReader, gzFile)
+	}
+	if plainFile, err := os.Open(logPath); err == nil {
+		readers = append(readers, plainFile)
+		closers = append(closers, plainFile)
+	}
+
+	if len(readers) == 0 {
+		return nil, os.ErrNotExist
+	}
+	return &multiReadCloser{Reader: io.MultiReader(readers...), closers: closers}, nil
+}
+
+// multiReadCloser 组合多个文件的读取流
+type multiReadCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+// Close 关闭所有底层文件
+func (m *multiReadCloser) Close() error {
+	for _, closer := range m.closers {
+		closer.Close()
+	}
+	return nil
+}
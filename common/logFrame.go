@@ -0,0 +1,50 @@
+package common
+
+import (
+	"encoding/json"
+	"regexp"
+)
+
+// LogFrame 结构化日志帧（供Web界面按级别着色、按步骤过滤）
+type LogFrame struct {
+	Ts    string `json:"ts"`    // 日志时间，命令输出等无时间前缀的行为空
+	Level string `json:"level"` // 日志级别: INFO/WARNING/ERROR/COMMAND/OUTPUT/SYSTEM
+	Step  string `json:"step"`  // 步骤类型
+	Line  string `json:"line"`  // 日志正文（不含时间和级别前缀）
+}
+
+const (
+	logFormatText = "text" // 原始文本（默认）
+	logFormatJSON = "json" // 结构化JSON帧
+
+	logLevelOutput = "OUTPUT" // 命令原始输出
+	logLevelSystem = "SYSTEM" // 服务端提示信息
+)
+
+// taskLogLinePattern 匹配TaskLogger写入的行格式：2006/01/02 15:04:05 [LEVEL] message
+var taskLogLinePattern = regexp.MustCompile(`^(\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}) \[([A-Z]+)\] ?(.*)$`)
+
+// parseLogLine 将一行任务日志解析为结构化帧
+func parseLogLine(step, line string) LogFrame {
+	if matches := taskLogLinePattern.FindStringSubmatch(line); matches != nil {
+		return LogFrame{Ts: matches[1], Level: matches[2], Step: step, Line: matches[3]}
+	}
+	return LogFrame{Level: logLevelOutput, Step: step, Line: line}
+}
+
+// systemLogFrame 构建服务端提示信息帧
+func systemLogFrame(step, message string) LogFrame {
+	return LogFrame{Level: logLevelSystem, Step: step, Line: message}
+}
+
+// marshalLogFrames 将多行日志编码为JSON帧数组
+func marshalLogFrames(step string, lines []string) ([]byte, error) {
+	frames := make([]LogFrame, 0, len(lines))
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		frames = append(frames, parseLogLine(step, line))
+	}
+	return json.Marshal(frames)
+}
@@ -0,0 +1,113 @@
+package common
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// taskLogMetaFile 任务日志目录下的任务信息文件名
+const taskLogMetaFile = "meta.json"
+
+// TaskLogMeta 任务日志元信息（用于按项目检索日志）
+type TaskLogMeta struct {
+	TaskID     string `json:"task_id"`
+	Project    string `json:"project"`
+	Tag        string `json:"tag"`
+	Type       string `json:"type"`
+	StartedAt  string `json:"started_at"`
+	RequestID  string `json:"request_id,omitempty"`  // 触发任务的回调请求ID
+	Status     string `json:"status,omitempty"`      // 任务结束状态：complete/failed/cancel，执行中为空，等待计划时间为scheduled
+	FinishedAt string `json:"finished_at,omitempty"` // 任务结束时间
+	Trigger    string `json:"trigger,omitempty"`     // 触发方式：callback/retry/rollback/batch/switch/cleanup
+	RolledBack bool   `json:"rolled_back,omitempty"` // 失败后已恢复到原版本
+	DeployAt   string `json:"deploy_at,omitempty"`   // 计划任务的执行时间
+	BatchID    string `json:"batch_id,omitempty"`    // 所属的批量部署
+	RetryOf    string `json:"retry_of,omitempty"`    // 重试任务对应的原任务ID
+}
+
+// WriteTaskLogMeta 写入任务日志元信息到 logs/{任务ID}/meta.json
+func WriteTaskLogMeta(meta TaskLogMeta) {
+	logDir := filepath.Join("logs", meta.TaskID)
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		AppLogger.Error("创建任务日志目录失败:", err)
+		return
+	}
+
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		AppLogger.Error("序列化任务日志元信息失败:", err)
+		return
+	}
+	if err := os.WriteFile(filepath.Join(logDir, taskLogMetaFile), data, 0644); err != nil {
+		AppLogger.Error("写入任务日志元信息失败:", err)
+	}
+}
+
+// ReadTaskLogMeta 读取任务日志元信息
+func ReadTaskLogMeta(taskID string) (*TaskLogMeta, error) {
+	data, err := os.ReadFile(filepath.Join("logs", taskID, taskLogMetaFile))
+	if err != nil {
+		return nil, err
+	}
+	var meta TaskLogMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+// TaskRetryOf 获取重试任务对应的原任务ID，不是重试发起的任务时返回空
+func TaskRetryOf(taskID string) string {
+	meta, err := ReadTaskLogMeta(taskID)
+	if err != nil {
+		return ""
+	}
+	return meta.RetryOf
+}
+
+// FinishTaskLogMeta 任务结束时记录最终状态
+func FinishTaskLogMeta(taskID, status string) {
+	updateTaskLogMeta(taskID, func(meta *TaskLogMeta) {
+		meta.Status = status
+		meta.FinishedAt = time.Now().Format("2006-01-02 15:04:05")
+	})
+}
+
+// updateTaskLogMeta 修改任务日志元信息
+func updateTaskLogMeta(taskID string, update func(meta *TaskLogMeta)) {
+	meta, err := ReadTaskLogMeta(taskID)
+	if err != nil {
+		AppLogger.Warning("读取任务日志元信息失败:", err)
+		return
+	}
+	update(meta)
+	WriteTaskLogMeta(*meta)
+}
+
+// ListTaskLogMetas 列出项目的任务元信息，按开始时间从新到旧排序（project为空时返回全部）
+func ListTaskLogMetas(project string) []TaskLogMeta {
+	entries, err := os.ReadDir("logs")
+	if err != nil {
+		return nil
+	}
+
+	var metas []TaskLogMeta
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		meta, err := ReadTaskLogMeta(entry.Name())
+		if err != nil || (project != "" && meta.Project != project) {
+			continue
+		}
+		metas = append(metas, *meta)
+	}
+	// 时间格式固定，按字符串比较即可
+	sort.Slice(metas, func(i, j int) bool {
+		return metas[i].StartedAt > metas[j].StartedAt
+	})
+	return metas
+}
@@ -0,0 +1,194 @@
+package common
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	logSearchMaxLineSize = 1024 * 1024      // 单行最大长度
+	logSearchTimeout     = 10 * time.Second // 单次检索最长耗时
+)
+
+// LogSearchOptions 日志检索条件
+type LogSearchOptions struct {
+	Query   string    // 检索关键字（不区分大小写）
+	Project string    // 按项目过滤，为空表示全部
+	Since   time.Time // 只检索该时间之后有更新的任务
+	Context int       // 匹配行前后附带的上下文行数
+	Limit   int       // 最多返回的匹配数
+}
+
+// LogSearchMatch 单条匹配结果
+type LogSearchMatch struct {
+	TaskID  string   `json:"task_id"`
+	Project string   `json:"project,omitempty"`
+	Step    string   `json:"step"`
+	LineNo  int      `json:"line_no"`
+	Line    string   `json:"line"`
+	Before  []string `json:"before,omitempty"`
+	After   []string `json:"after,omitempty"`
+}
+
+// LogSearchResult 检索结果
+type LogSearchResult struct {
+	Matches      []LogSearchMatch `json:"matches"`
+	TasksScanned int              `json:"tasks_scanned"`
+	Truncated    bool             `json:"truncated"` // 达到数量或时间上限，结果不完整
+}
+
+// SearchTaskLogs 在任务日志中检索关键字，按任务从新到旧返回匹配行
+func SearchTaskLogs(opts LogSearchOptions) (*LogSearchResult, error) {
+	result := &LogSearchResult{Matches: []LogSearchMatch{}}
+
+	entries, err := os.ReadDir("logs")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return result, nil
+		}
+		return nil, err
+	}
+
+	// 收集符合时间条件的任务目录，最近更新的优先
+	type taskDir struct {
+		taskID  string
+		modTime time.Time
+	}
+	var dirs []taskDir
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().Before(opts.Since) {
+			continue
+		}
+		dirs = append(dirs, taskDir{taskID: entry.Name(), modTime: info.ModTime()})
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		return dirs[i].modTime.After(dirs[j].modTime)
+	})
+
+	query := strings.ToLower(opts.Query)
+	deadline := time.Now().Add(logSearchTimeout)
+
+	for _, dir := range dirs {
+		if time.Now().After(deadline) {
+			result.Truncated = true
+			break
+		}
+
+		project := ""
+		if meta, err := ReadTaskLogMeta(dir.taskID); err == nil {
+			project = meta.Project
+		}
+		if opts.Project != "" && project != opts.Project {
+			continue
+		}
+		result.TasksScanned++
+
+		for _, step := range listTaskLogSteps(dir.taskID) {
+			remaining := opts.Limit - len(result.Matches)
+			matches := searchLogFile(buildLogFilePath(dir.taskID, step), query, opts.Context, remaining)
+			for i := range matches {
+				matches[i].TaskID = dir.taskID
+				matches[i].Project = project
+				matches[i].Step = step
+			}
+			result.Matches = append(result.Matches, matches...)
+			if len(result.Matches) >= opts.Limit {
+				result.Truncated = true
+				return result, nil
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// listTaskLogSteps 列出任务日志目录下的步骤名（含已压缩的日志）
+func listTaskLogSteps(taskID string) []string {
+	entries, err := os.ReadDir(filepath.Join("logs", taskID))
+	if err != nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var steps []string
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), compressedLogExt)
+		if entry.IsDir() || !strings.HasSuffix(name, ".log") {
+			continue
+		}
+		step := strings.TrimSuffix(name, ".log")
+		if !seen[step] {
+			seen[step] = true
+			steps = append(steps, step)
+		}
+	}
+	sort.Strings(steps)
+	return steps
+}
+
+// searchLogFile 在单个日志文件中检索，最多返回limit条匹配
+func searchLogFile(logPath, query string, contextLines, limit int) []LogSearchMatch {
+	reader, err := openTaskLogReader(logPath)
+	if err != nil {
+		return nil
+	}
+	defer reader.Close()
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), logSearchMaxLineSize)
+
+	var matches []LogSearchMatch
+	var before []string
+	// pending 仍在收集后续上下文的匹配
+	var pending []int
+	lineNo := 0
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		lineNo++
+
+		// 为之前的匹配补充后续上下文
+		stillPending := pending[:0]
+		for _, idx := range pending {
+			matches[idx].After = append(matches[idx].After, line)
+			if len(matches[idx].After) < contextLines {
+				stillPending = append(stillPending, idx)
+			}
+		}
+		pending = stillPending
+
+		if len(matches) < limit && strings.Contains(strings.ToLower(line), query) {
+			match := LogSearchMatch{
+				LineNo: lineNo,
+				Line:   line,
+				Before: append([]string(nil), before...),
+			}
+			matches = append(matches, match)
+			if contextLines > 0 {
+				pending = append(pending, len(matches)-1)
+			}
+		}
+
+		// 达到上限且上下文已收集完毕
+		if len(matches) >= limit && len(pending) == 0 {
+			break
+		}
+
+		if contextLines > 0 {
+			before = append(before, line)
+			if len(before) > contextLines {
+				before = before[1:]
+			}
+		}
+	}
+
+	return matches
+}
@@ -0,0 +1,115 @@
+package common
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// logDirWatcher 任务日志目录监听器（所有连接共享同一个fsnotify实例）
+type logDirWatcher struct {
+	mu          sync.Mutex
+	watcher     *fsnotify.Watcher
+	subscribers map[string]map[chan struct{}]struct{} // 目录 -> 订阅者
+	initErr     error
+	initOnce    sync.Once
+}
+
+var sharedLogWatcher = &logDirWatcher{
+	subscribers: make(map[string]map[chan struct{}]struct{}),
+}
+
+// init 懒加载创建fsnotify监听器
+func (w *logDirWatcher) init() error {
+	w.initOnce.Do(func() {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			w.initErr = err
+			AppLogger.Warning(fmt.Sprintf("创建日志文件监听器失败，回退到轮询模式: %v", err))
+			return
+		}
+		w.watcher = watcher
+		go w.dispatch()
+	})
+	return w.initErr
+}
+
+// subscribeLogDir 订阅任务日志目录的变更事件
+// 返回的通道在目录内文件写入/创建时收到信号；订阅失败时返回错误，调用方应回退到轮询
+func subscribeLogDir(dir string) (<-chan struct{}, func(), error) {
+	return sharedLogWatcher.subscribe(dir)
+}
+
+// subscribe 订阅目录变更
+func (w *logDirWatcher) subscribe(dir string) (<-chan struct{}, func(), error) {
+	if err := w.init(); err != nil {
+		return nil, nil, err
+	}
+
+	dir = filepath.Clean(dir)
+	ch := make(chan struct{}, 1)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	subs, exists := w.subscribers[dir]
+	if !exists {
+		// 第一个订阅者负责添加目录监听
+		if err := w.watcher.Add(dir); err != nil {
+			return nil, nil, err
+		}
+		subs = make(map[chan struct{}]struct{})
+		w.subscribers[dir] = subs
+	}
+	subs[ch] = struct{}{}
+
+	unsubscribe := func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+
+		subs, exists := w.subscribers[dir]
+		if !exists {
+			return
+		}
+		delete(subs, ch)
+		// 最后一个订阅者退出时移除目录监听
+		if len(subs) == 0 {
+			delete(w.subscribers, dir)
+			_ = w.watcher.Remove(dir)
+		}
+	}
+	return ch, unsubscribe, nil
+}
+
+// dispatch 分发fsnotify事件到订阅者
+func (w *logDirWatcher) dispatch() {
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) {
+				continue
+			}
+
+			dir := filepath.Dir(filepath.Clean(event.Name))
+			w.mu.Lock()
+			for ch := range w.subscribers[dir] {
+				// 非阻塞发送，订阅者尚未处理的信号会被合并
+				select {
+				case ch <- struct{}{}:
+				default:
+				}
+			}
+			w.mu.Unlock()
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			AppLogger.Warning(fmt.Sprintf("日志文件监听器错误: %v", err))
+		}
+	}
+}
@@ -1,21 +1,34 @@
 package common
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"runtime"
+	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
 // Logger 日志配置
 type Logger struct {
 	*log.Logger
+	requestID string // 关联的请求ID，非空时输出在消息前
 }
 
 var AppLogger *Logger
 
+// logJSONFormat 是否以JSON格式输出服务日志（所有Logger共享）
+var logJSONFormat atomic.Bool
+
+// logLevelRanks 日志级别的严重程度，低于当前级别的日志不输出；其他级别（如结构化日志的自定义级别）总是输出
+var logLevelRanks = map[string]int32{"DEBUG": 0, "INFO": 1, "WARNING": 2, "ERROR": 3}
+
+// minLogLevel 当前输出的最低日志级别，默认DEBUG（输出全部日志）
+var minLogLevel atomic.Int32
+
 // InitLogger 初始化日志
 func InitLogger() {
 	AppLogger = &Logger{
@@ -23,6 +36,36 @@ func InitLogger() {
 	}
 }
 
+// SetLogFormat 设置服务日志格式: text/json
+func SetLogFormat(format string) {
+	logJSONFormat.Store(format == "json")
+}
+
+// SetLogLevel 设置服务日志的最低输出级别: debug/info/warning/error（warn同warning），为空时为debug
+func SetLogLevel(level string) error {
+	level = strings.ToUpper(strings.TrimSpace(level))
+	switch level {
+	case "":
+		level = "DEBUG"
+	case "WARN":
+		level = "WARNING"
+	}
+	rank, ok := logLevelRanks[level]
+	if !ok {
+		return fmt.Errorf("日志级别错误: %s（支持debug/info/warning/error）", level)
+	}
+	minLogLevel.Store(rank)
+	return nil
+}
+
+// WithRequestID 返回关联请求ID的日志器（共享同一输出）
+func (l *Logger) WithRequestID(requestID string) *Logger {
+	if requestID == "" {
+		return l
+	}
+	return &Logger{Logger: l.Logger, requestID: requestID}
+}
+
 // getCallerInfo 获取调用者信息
 func getCallerInfo() string {
 	_, file, line, ok := runtime.Caller(3)
@@ -44,15 +87,67 @@ func getCallerInfo() string {
 
 // logWithLevel 统一的日志输出方法
 func (l *Logger) logWithLevel(level string, v ...interface{}) {
-	caller := getCallerInfo()
+	l.output(level, getCallerInfo(), fmt.Sprint(translateLogArgs(v)...), nil)
+}
+
+// logFields 输出带结构化字段的日志
+func (l *Logger) logFields(level, message string, fields map[string]interface{}) {
+	l.output(level, getCallerInfo(), T(message), fields)
+}
+
+// output 按当前格式输出一条日志
+func (l *Logger) output(level, caller, message string, fields map[string]interface{}) {
+	if rank, ok := logLevelRanks[level]; ok && rank < minLogLevel.Load() {
+		return
+	}
 	timestamp := time.Now().Format("2006/01/02 15:04:05")
-	message := fmt.Sprint(v...)
+
+	if logJSONFormat.Load() {
+		entry := make(map[string]interface{}, len(fields)+5)
+		for key, value := range fields {
+			entry[key] = value
+		}
+		entry["time"] = timestamp
+		entry["level"] = level
+		entry["caller"] = caller
+		entry["msg"] = message
+		if l.requestID != "" {
+			entry["request_id"] = l.requestID
+		}
+		data, err := json.Marshal(entry)
+		if err == nil {
+			l.Println(string(data))
+			return
+		}
+	}
+
+	if l.requestID != "" {
+		message = fmt.Sprintf("[req=%s] %s", l.requestID, message)
+	}
+	if len(fields) > 0 {
+		message += " " + formatLogFields(fields)
+	}
 
 	// 格式：时间 [级别] 文件名:行号 消息
 	logMessage := fmt.Sprintf("%s [%s] %s %s", timestamp, level, caller, message)
 	l.Println(logMessage)
 }
 
+// formatLogFields 将字段格式化为按键排序的 key=value 文本
+func formatLogFields(fields map[string]interface{}) string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%v", key, fields[key]))
+	}
+	return strings.Join(parts, " ")
+}
+
 // Info 信息日志
 func (l *Logger) Info(v ...interface{}) {
 	l.logWithLevel("INFO", v...)
@@ -72,3 +167,8 @@ func (l *Logger) Warning(v ...interface{}) {
 func (l *Logger) Debug(v ...interface{}) {
 	l.logWithLevel("DEBUG", v...)
 }
+
+// Fields 输出带结构化字段的日志（JSON格式下字段作为独立的键）
+func (l *Logger) Fields(level, message string, fields map[string]interface{}) {
+	l.logFields(level, message, fields)
+}
@@ -0,0 +1,71 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// taskManifestFile 任务日志目录下的部署文件提交记录文件名
+const taskManifestFile = "manifest.json"
+
+// ManifestCommit 步骤13修改部署目录YAML后在目录的本地git仓库中的提交，用于查看任务对部署文件的修改和回退
+type ManifestCommit struct {
+	TaskID      string `json:"task_id"`
+	Project     string `json:"project"`
+	Tag         string `json:"tag"`
+	DeployDir   string `json:"deploy_dir"`
+	Commit      string `json:"commit"`           // 本次任务的提交，YAML没有变化时为空
+	Parent      string `json:"parent,omitempty"` // 修改前的提交
+	CommittedAt string `json:"committed_at"`
+}
+
+// WriteManifestCommit 写入部署文件提交记录到 logs/{任务ID}/manifest.json
+func WriteManifestCommit(record ManifestCommit) error {
+	logDir := filepath.Join("logs", record.TaskID)
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		return fmt.Errorf("创建任务日志目录失败: %v", err)
+	}
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化部署文件提交记录失败: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(logDir, taskManifestFile), data, 0644); err != nil {
+		return fmt.Errorf("写入部署文件提交记录失败: %v", err)
+	}
+	return nil
+}
+
+// ReadManifestCommit 读取任务的部署文件提交记录
+func ReadManifestCommit(taskID string) (*ManifestCommit, error) {
+	data, err := os.ReadFile(filepath.Join("logs", taskID, taskManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	var record ManifestCommit
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// ManifestDiff 获取任务提交对部署文件的修改（统一diff格式），YAML没有变化时返回空
+func ManifestDiff(ctx context.Context, record ManifestCommit) (string, error) {
+	if record.Commit == "" {
+		return "", nil
+	}
+	cmd := NewCommand("git", "show", "--no-color", "--format=", record.Commit)
+	if record.Parent != "" {
+		cmd = NewCommand("git", "diff", "--no-color", record.Parent, record.Commit)
+	}
+	cmd.Dir = record.DeployDir
+	output, err := RunCommand(ctx, cmd)
+	if err != nil {
+		return "", fmt.Errorf("读取提交 %s 失败: %v %s", record.Commit, err, strings.TrimSpace(string(output)))
+	}
+	return string(output), nil
+}
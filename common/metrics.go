@@ -0,0 +1,324 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cicd-agent/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// httpDurationBuckets HTTP请求耗时直方图分桶（秒）
+var httpDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// CounterVec 带标签的计数器
+type CounterVec struct {
+	name   string
+	help   string
+	labels []string
+	mu     sync.Mutex
+	values map[string]float64 // 标签值拼接 -> 计数
+}
+
+// HistogramVec 带标签的直方图
+type HistogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+// histogramSeries 单个标签组合的直方图数据
+type histogramSeries struct {
+	counts []uint64 // 各分桶累计计数
+	count  uint64
+	sum    float64
+}
+
+// metricsRegistry 指标注册表
+type metricsRegistry struct {
+	mu         sync.Mutex
+	counters   []*CounterVec
+	histograms []*HistogramVec
+}
+
+var defaultMetrics = &metricsRegistry{}
+
+var (
+	httpRequestsTotal = NewCounterVec("cicd_agent_http_requests_total",
+		"HTTP请求总数", "method", "route", "status")
+	httpRequestDuration = NewHistogramVec("cicd_agent_http_request_duration_seconds",
+		"HTTP请求耗时", httpDurationBuckets, "method", "route")
+)
+
+// NewCounterVec 创建并注册计数器
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	counter := &CounterVec{name: name, help: help, labels: labels, values: make(map[string]float64)}
+	defaultMetrics.mu.Lock()
+	defaultMetrics.counters = append(defaultMetrics.counters, counter)
+	defaultMetrics.mu.Unlock()
+	return counter
+}
+
+// NewHistogramVec 创建并注册直方图
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	histogram := &HistogramVec{name: name, help: help, labels: labels, buckets: buckets, series: make(map[string]*histogramSeries)}
+	defaultMetrics.mu.Lock()
+	defaultMetrics.histograms = append(defaultMetrics.histograms, histogram)
+	defaultMetrics.mu.Unlock()
+	return histogram
+}
+
+// Inc 计数加一，标签值顺序与创建时的标签名一致
+func (m *CounterVec) Inc(labelValues ...string) {
+	m.Add(1, labelValues...)
+}
+
+// Add 计数增加指定值
+func (m *CounterVec) Add(value float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\x00")
+	m.mu.Lock()
+	m.values[key] += value
+	m.mu.Unlock()
+}
+
+// Observe 记录一次观测值
+func (m *HistogramVec) Observe(value float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\x00")
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	series, ok := m.series[key]
+	if !ok {
+		series = &histogramSeries{counts: make([]uint64, len(m.buckets))}
+		m.series[key] = series
+	}
+	for i, bound := range m.buckets {
+		if value <= bound {
+			series.counts[i]++
+		}
+	}
+	series.count++
+	series.sum += value
+}
+
+// MetricsMiddleware 记录HTTP请求数和耗时
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		// 使用路由模板而非实际路径，避免标签基数膨胀
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		httpRequestsTotal.Inc(c.Request.Method, route, strconv.Itoa(c.Writer.Status()))
+		httpRequestDuration.Observe(time.Since(start).Seconds(), c.Request.Method, route)
+	}
+}
+
+// MetricsHandler 以Prometheus文本格式输出指标
+func MetricsHandler(c *gin.Context) {
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", renderMetrics())
+}
+
+// StartMetricsServer 在独立端口上提供/metrics（配置了metrics.listen时使用）
+func StartMetricsServer(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc(config.AppConfig.GetMetricsPath(), func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(renderMetrics())
+	})
+
+	go func() {
+		AppLogger.Info("指标服务已启动，地址:", addr)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			AppLogger.Error("指标服务启动失败:", err)
+		}
+	}()
+}
+
+// renderMetrics 生成全部指标的文本
+func renderMetrics() []byte {
+	var buf bytes.Buffer
+
+	writeGoMetrics(&buf)
+	writeProcessMetrics(&buf)
+
+	writeGauge(&buf, "cicd_agent_log_connections", "当前日志查看连接数", float64(ActiveLogConnections()))
+	writeGauge(&buf, "cicd_agent_running_tasks", "正在执行的任务数", float64(RunningTaskCount()))
+	writeGauge(&buf, "cicd_agent_notify_queue_length", "待重试的通知数", float64(NotifyQueueLength()))
+	leader := 0.0
+	if IsLeader() {
+		leader = 1
+	}
+	writeGauge(&buf, "cicd_agent_leader", "本节点是否为主节点（未开启选主时为1）", leader)
+	operationsUsed, operationsWaiting := OperationUsage()
+	writeGauge(&buf, "cicd_agent_operations_in_use", "正在执行的docker/kubectl操作占用的权重", float64(operationsUsed))
+	writeGauge(&buf, "cicd_agent_operations_waiting", "等待执行名额的docker/kubectl操作数", float64(operationsWaiting))
+
+	defaultMetrics.mu.Lock()
+	counters := append([]*CounterVec(nil), defaultMetrics.counters...)
+	histograms := append([]*HistogramVec(nil), defaultMetrics.histograms...)
+	defaultMetrics.mu.Unlock()
+
+	for _, counter := range counters {
+		counter.write(&buf)
+	}
+	for _, histogram := range histograms {
+		histogram.write(&buf)
+	}
+	return buf.Bytes()
+}
+
+// writeGoMetrics 输出Go运行时指标
+func writeGoMetrics(buf *bytes.Buffer) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	writeGauge(buf, "go_goroutines", "Number of goroutines that currently exist.", float64(runtime.NumGoroutine()))
+	writeGauge(buf, "go_threads", "Number of OS threads created.", float64(threadCount()))
+	writeGauge(buf, "go_memstats_alloc_bytes", "Number of bytes allocated and still in use.", float64(mem.Alloc))
+	writeCounter(buf, "go_memstats_alloc_bytes_total", "Total number of bytes allocated, even if freed.", float64(mem.TotalAlloc))
+	writeGauge(buf, "go_memstats_sys_bytes", "Number of bytes obtained from system.", float64(mem.Sys))
+	writeGauge(buf, "go_memstats_heap_alloc_bytes", "Number of heap bytes allocated and still in use.", float64(mem.HeapAlloc))
+	writeGauge(buf, "go_memstats_heap_inuse_bytes", "Number of heap bytes that are in use.", float64(mem.HeapInuse))
+	writeGauge(buf, "go_memstats_heap_objects", "Number of allocated objects.", float64(mem.HeapObjects))
+	writeGauge(buf, "go_memstats_stack_inuse_bytes", "Number of bytes in use by the stack allocator.", float64(mem.StackInuse))
+	writeCounter(buf, "go_gc_cycles_total", "Number of completed GC cycles.", float64(mem.NumGC))
+	writeCounter(buf, "go_gc_pause_seconds_total", "Total GC pause time.", float64(mem.PauseTotalNs)/1e9)
+	fmt.Fprintf(buf, "# HELP go_info Information about the Go environment.\n# TYPE go_info gauge\ngo_info{version=%q} 1\n", runtime.Version())
+}
+
+// threadCount 获取当前OS线程数
+func threadCount() int {
+	n, _ := runtime.ThreadCreateProfile(nil)
+	return n
+}
+
+// writeProcessMetrics 输出进程指标（依赖/proc，非Linux系统只输出启动时间）
+func writeProcessMetrics(buf *bytes.Buffer) {
+	writeGauge(buf, "process_start_time_seconds", "Start time of the process since unix epoch in seconds.", float64(processStartTime.Unix()))
+
+	if entries, err := os.ReadDir("/proc/self/fd"); err == nil {
+		writeGauge(buf, "process_open_fds", "Number of open file descriptors.", float64(len(entries)))
+	}
+
+	data, err := os.ReadFile("/proc/self/stat")
+	if err != nil {
+		return
+	}
+	// 进程名可能包含空格，从最后一个')'之后开始解析
+	stat := string(data)
+	if idx := strings.LastIndexByte(stat, ')'); idx >= 0 {
+		stat = stat[idx+1:]
+	}
+	fields := strings.Fields(stat)
+	// fields[0]为state，utime/stime/vsize/rss分别为第14/15/23/24个字段
+	if len(fields) < 22 {
+		return
+	}
+	const clockTicks = 100
+	utime, _ := strconv.ParseFloat(fields[11], 64)
+	stime, _ := strconv.ParseFloat(fields[12], 64)
+	vsize, _ := strconv.ParseFloat(fields[20], 64)
+	rss, _ := strconv.ParseFloat(fields[21], 64)
+
+	writeCounter(buf, "process_cpu_seconds_total", "Total user and system CPU time spent in seconds.", (utime+stime)/clockTicks)
+	writeGauge(buf, "process_virtual_memory_bytes", "Virtual memory size in bytes.", vsize)
+	writeGauge(buf, "process_resident_memory_bytes", "Resident memory size in bytes.", rss*float64(os.Getpagesize()))
+}
+
+// writeGauge 输出单值gauge
+func writeGauge(buf *bytes.Buffer, name, help string, value float64) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n", name, help, name, name, formatMetricValue(value))
+}
+
+// writeCounter 输出单值counter
+func writeCounter(buf *bytes.Buffer, name, help string, value float64) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s counter\n%s %s\n", name, help, name, name, formatMetricValue(value))
+}
+
+// write 输出计数器全部序列
+func (m *CounterVec) write(buf *bytes.Buffer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s counter\n", m.name, m.help, m.name)
+	for _, key := range sortedKeys(m.values) {
+		fmt.Fprintf(buf, "%s%s %s\n", m.name, formatLabels(m.labels, key, ""), formatMetricValue(m.values[key]))
+	}
+}
+
+// write 输出直方图全部序列
+func (m *HistogramVec) write(buf *bytes.Buffer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s histogram\n", m.name, m.help, m.name)
+	keys := make([]string, 0, len(m.series))
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		series := m.series[key]
+		for i, bound := range m.buckets {
+			le := `le="` + formatMetricValue(bound) + `"`
+			fmt.Fprintf(buf, "%s_bucket%s %d\n", m.name, formatLabels(m.labels, key, le), series.counts[i])
+		}
+		fmt.Fprintf(buf, "%s_bucket%s %d\n", m.name, formatLabels(m.labels, key, `le="+Inf"`), series.count)
+		fmt.Fprintf(buf, "%s_sum%s %s\n", m.name, formatLabels(m.labels, key, ""), formatMetricValue(series.sum))
+		fmt.Fprintf(buf, "%s_count%s %d\n", m.name, formatLabels(m.labels, key, ""), series.count)
+	}
+}
+
+// formatLabels 生成标签文本，extra为附加的已格式化标签（如le）
+func formatLabels(names []string, key, extra string) string {
+	var parts []string
+	if len(names) > 0 {
+		values := strings.Split(key, "\x00")
+		for i, name := range names {
+			value := ""
+			if i < len(values) {
+				value = values[i]
+			}
+			parts = append(parts, fmt.Sprintf("%s=%q", name, value))
+		}
+	}
+	if extra != "" {
+		parts = append(parts, extra)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// formatMetricValue 格式化指标值
+func formatMetricValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// sortedKeys 返回排序后的map键
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
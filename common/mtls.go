@@ -0,0 +1,104 @@
+package common
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+
+	"cicd-agent/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loadClientCAPool 加载签发客户端证书的CA
+func loadClientCAPool(caFile string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("读取客户端CA证书失败: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("解析客户端CA证书失败: %s", caFile)
+	}
+	return pool, nil
+}
+
+// configureClientAuth 为TLS配置客户端证书校验
+// 使用VerifyClientCertIfGiven：日志查看、健康检查等接口不强制要求证书，由中间件按接口校验
+func configureClientAuth(tlsConfig *tls.Config) error {
+	caFile := config.AppConfig.Server.TLS.ClientCAFile
+	if caFile == "" {
+		return nil
+	}
+	pool, err := loadClientCAPool(caFile)
+	if err != nil {
+		return err
+	}
+	tlsConfig.ClientCAs = pool
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	return nil
+}
+
+// hasVerifiedClientCert 请求是否携带了受信任CA签发的客户端证书（且CN在允许列表中）
+func hasVerifiedClientCert(c *gin.Context) bool {
+	if c.Request.TLS == nil || len(c.Request.TLS.VerifiedChains) == 0 {
+		return false
+	}
+
+	allowedCNs := config.AppConfig.Server.TLS.ClientAllowedCNs
+	if len(allowedCNs) == 0 {
+		return true
+	}
+	commonName := c.Request.TLS.VerifiedChains[0][0].Subject.CommonName
+	for _, cn := range allowedCNs {
+		if cn == commonName {
+			return true
+		}
+	}
+	AppLogger.Warning("客户端证书CN不在允许列表中:", commonName)
+	return false
+}
+
+// CallerAuthMiddleware 变更类接口的调用方认证，按配置组合IP白名单与客户端证书
+// listName为该接口使用的白名单名称
+func CallerAuthMiddleware(listName string) gin.HandlerFunc {
+	whitelistCheck := IPWhitelistMiddleware(listName)
+
+	return func(c *gin.Context) {
+		switch config.AppConfig.GetClientAuthMode() {
+		case "mtls":
+			if !hasVerifiedClientCert(c) {
+				rejectClientCert(c)
+				return
+			}
+			c.Next()
+		case "both":
+			if !hasVerifiedClientCert(c) {
+				rejectClientCert(c)
+				return
+			}
+			whitelistCheck(c)
+		case "either":
+			if hasVerifiedClientCert(c) {
+				c.Next()
+				return
+			}
+			whitelistCheck(c)
+		default:
+			whitelistCheck(c)
+		}
+	}
+}
+
+// rejectClientCert 拒绝未通过证书校验的请求
+func rejectClientCert(c *gin.Context) {
+	AppLogger.Warning("客户端证书校验失败:", getClientIP(c))
+	// 返回404而不是403，隐藏服务存在
+	c.JSON(http.StatusNotFound, gin.H{
+		"code": 404,
+		"msg":  "Not Found",
+	})
+	c.Abort()
+}
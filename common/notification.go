@@ -1,12 +1,9 @@
 package common
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
-	"io"
 	"math"
-	"net/http"
 	"strings"
 	"time"
 
@@ -28,20 +25,31 @@ type UnifiedNotificationData struct {
 	StartedAt     string                 `json:"started_at,omitempty"`     // 开始时间
 	Type          string                 `json:"type,omitempty"`           // 任务类型
 	FinishedAt    string                 `json:"finished_at"`              // 结束时间
-	Status        string                 `json:"status,omitempty"`         // 状态 (running/complete/cancel)
+	Status        string                 `json:"status,omitempty"`         // 状态 (queued/running/complete/cancel)
 	Remote        string                 `json:"remote,omitempty"`         // 来源（agent/server），此处固定为agent
 	StepDurations map[string]interface{} `json:"step_durations,omitempty"` // 任务各步骤耗时（秒）
+	Failure       *TaskFailure           `json:"failure,omitempty"`        // 失败步骤及日志末尾（仅失败时）
+	FailedStep    string                 `json:"failed_step,omitempty"`    // 失败步骤类型（仅失败时）
+	ErrorMessage  string                 `json:"error_message,omitempty"`  // 失败原因（仅失败时）
+	RolledBack    bool                   `json:"rolled_back,omitempty"`    // 失败后是否已恢复到原版本
+	Artifacts     []TaskArtifact         `json:"artifacts,omitempty"`      // 任务产物（日志下载地址等）
+	RetryOf       string                 `json:"retry_of,omitempty"`       // 重试任务对应的原任务ID
 
 	// 步骤通知字段
-	Step           int     `json:"step,omitempty"`             // 步骤编号
-	StepType       string  `json:"step_type,omitempty"`        // 步骤类型
-	StepStartedAt  string  `json:"step_started_at,omitempty"`  // 步骤开始时间
-	StepFinishedAt string  `json:"step_finished_at,omitempty"` // 步骤完成时间
-	StepName       string  `json:"step_name,omitempty"`        // 步骤名称
-	StepStatus     string  `json:"step_status,omitempty"`      // 步骤状态 (success/failed/cancel)
-	Duration       float64 `json:"duration"`                   // 持续时间(秒，保留2位小数)
-	LastDuration   float64 `json:"last_duration"`              // 上一个步骤的耗时(秒，保留2位小数)
-	EstimatedEnd   string  `json:"estimated_end,omitempty"`    // 预计结束时间
+	Step             int     `json:"step,omitempty"`               // 步骤编号
+	StepType         string  `json:"step_type,omitempty"`          // 步骤类型
+	StepStartedAt    string  `json:"step_started_at,omitempty"`    // 步骤开始时间
+	StepFinishedAt   string  `json:"step_finished_at,omitempty"`   // 步骤完成时间
+	StepName         string  `json:"step_name,omitempty"`          // 步骤名称
+	StepStatus       string  `json:"step_status,omitempty"`        // 步骤状态 (running/success/failed/cancel/skipped/paused)
+	Duration         float64 `json:"duration"`                     // 持续时间(秒，保留2位小数)
+	LastDuration     float64 `json:"last_duration"`                // 上一个步骤的耗时(秒，保留2位小数)
+	EstimatedEnd     string  `json:"estimated_end,omitempty"`      // 预计结束时间
+	TaskEstimatedEnd string  `json:"task_estimated_end,omitempty"` // 整个任务的预计结束时间，每个步骤通知中刷新
+	TotalSteps       int     `json:"total_steps,omitempty"`        // 任务总步骤数
+	CompletedSteps   int     `json:"completed_steps,omitempty"`    // 已完成步骤数
+	Percent          float64 `json:"percent,omitempty"`            // 任务完成百分比（保留2位小数）
+	RepeatCount      int     `json:"repeat_count,omitempty"`       // 上次发送后被去重抑制的相同通知次数
 }
 
 // NotificationResponse 通知响应结构
@@ -51,11 +59,17 @@ type NotificationResponse struct {
 	Data    string `json:"data"`
 }
 
-// 步骤开始时间记录
-var stepStartTimes = make(map[string]time.Time)
-
 // SendStepNotification 发送步骤通知
 func SendStepNotification(taskID string, step int, stepType, stepName, status, message, project, tag string) error {
+	// 记录失败步骤，任务失败通知中附带该步骤的日志
+	if status == "failed" {
+		recordStepFailure(taskID, stepType, stepName, message)
+	}
+	// 未配置通知地址时同样记录进度和时间线
+	totalSteps, completedSteps := stepProgress(taskID, stepType, status)
+	recordStepTimeline(taskID, step, stepType, stepName, status, message)
+	publishStepEvent(taskID, step, stepType, stepName, status, message, project, tag)
+
 	// 获取通知URL
 	notifyURL := getNotifyURL()
 	if notifyURL == "" {
@@ -63,6 +77,12 @@ func SendStepNotification(taskID string, step int, stepType, stepName, status, m
 		return nil
 	}
 
+	// 健康检查抖动等情况下会短时间内产生大量相同通知，窗口内只发送一次
+	allowed, repeatCount := dedupStepNotification(taskID, step, stepType, status)
+	if !allowed {
+		return nil
+	}
+
 	// 步骤键值，用于记录开始时间 - 统一使用step_stepType格式
 	stepKey := fmt.Sprintf("step_%d_%s", step, stepType)
 	currentTime := time.Now()
@@ -78,6 +98,10 @@ func SendStepNotification(taskID string, step int, stepType, stepName, status, m
 		stepStatus = "failed"
 	case "cancel":
 		stepStatus = "cancel"
+	case "skipped":
+		stepStatus = "skipped"
+	case "paused":
+		stepStatus = "paused"
 	default:
 		stepStatus = "running"
 	}
@@ -88,51 +112,35 @@ func SendStepNotification(taskID string, step int, stepType, stepName, status, m
 		ID:         taskID,
 		Step:       step,
 		StepType:   stepType,
-		StepName:   stepName,
+		StepName:   T(stepName),
 		StepStatus: stepStatus,
 		Remote:     "agent",
+
+		TotalSteps:     totalSteps,
+		CompletedSteps: completedSteps,
+		Percent:        progressPercent(totalSteps, completedSteps),
+		RepeatCount:    repeatCount,
 	}
 
 	// 计算 last_duration 和 estimated_end
 	notificationData.LastDuration = getLastStepDuration(project, stepKey)
 	notificationData.EstimatedEnd = calculateEstimatedEnd(project, stepKey)
+	notificationData.TaskEstimatedEnd = estimateTaskEnd(taskID, project, stepType, status)
 
 	// 调试日志
 	//AppLogger.Info(fmt.Sprintf("步骤 %s(%s) - 上次耗时: %.2f秒, 预计结束: %s", stepName, stepKey, notificationData.LastDuration, notificationData.EstimatedEnd))
 
-	// 设置步骤开始时间 - 兼容新旧格式
-	var startTime time.Time
-	var exists bool
-	var keyToDelete string
-
-	// 优先使用新格式查找
-	if startTime, exists = stepStartTimes[stepKey]; exists {
-		keyToDelete = stepKey
-	} else {
-		// 兼容旧格式：taskID_step_stepType
-		oldStepKey := fmt.Sprintf("%s_%d_%s", taskID, step, stepType)
-		if startTime, exists = stepStartTimes[oldStepKey]; exists {
-			keyToDelete = oldStepKey
-			//AppLogger.Info(fmt.Sprintf("使用旧格式键值找到开始时间: %s", oldStepKey))
-		}
-	}
-
-	if exists {
-		notificationData.StepStartedAt = startTime.Format("2006-01-02 15:04:05")
-
-		// 如果是完成状态，设置完成时间和持续时间
-		if status == "success" || status == "failed" || status == "cancel" {
+	// 设置步骤开始时间（按任务记录，并发任务互不影响）
+	if status == "start" {
+		notificationData.StepStartedAt = stepStartTimes.start(taskID, stepKey, currentTime).Format("2006-01-02 15:04:05")
+	} else if status == "success" || status == "failed" || status == "cancel" {
+		if startTime, exists := stepStartTimes.finish(taskID, stepKey); exists {
+			notificationData.StepStartedAt = startTime.Format("2006-01-02 15:04:05")
 			notificationData.StepFinishedAt = currentTime.Format("2006-01-02 15:04:05")
 			// 计算持续时间并转换为秒数，保留2位小数
 			durationMs := currentTime.Sub(startTime).Milliseconds()
 			notificationData.Duration = math.Round(float64(durationMs)/1000.0*100) / 100
-			// 清理已完成步骤的开始时间记录
-			delete(stepStartTimes, keyToDelete)
 		}
-	} else if status == "start" {
-		// 如果是开始状态但没有记录，使用当前时间并记录
-		notificationData.StepStartedAt = currentTime.Format("2006-01-02 15:04:05")
-		stepStartTimes[stepKey] = currentTime
 	}
 
 	// 序列化为JSON
@@ -162,29 +170,11 @@ func SendStepNotification(taskID string, step int, stepType, stepName, status, m
 		return fmt.Errorf("序列化请求体失败: %v", err)
 	}
 
-	// 发送HTTP请求
+	// 发送HTTP请求（失败时进入重试队列，按顺序补发）
 	// AppLogger.Info(fmt.Sprintf("正在发送HTTP请求到: %s", notifyURL))
-	resp, err := http.Post(notifyURL, "application/json", bytes.NewReader(requestJson))
-	if err != nil {
+	if err := deliverNotification(notifyContext(taskID), taskID, notifyURL, requestJson); err != nil {
 		AppLogger.Error(fmt.Sprintf("发送通知请求失败: %v", err))
-		return fmt.Errorf("发送通知请求失败: %v", err)
-	}
-	defer resp.Body.Close()
-
-	// 读取响应
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		AppLogger.Error(fmt.Sprintf("读取响应失败: %v", err))
-		return fmt.Errorf("读取响应失败: %v", err)
-	}
-
-	//AppLogger.Info(fmt.Sprintf("收到响应状态码: %d", resp.StatusCode))
-	// AppLogger.Info(fmt.Sprintf("响应内容: %s", string(respBody)))
-
-	// 检查响应状态
-	if resp.StatusCode != 200 {
-		AppLogger.Error(fmt.Sprintf("远程接口返回错误状态码 %d: %s", resp.StatusCode, string(respBody)))
-		return fmt.Errorf("远程接口返回错误: %s", string(respBody))
+		return err
 	}
 
 	// AppLogger.Info("通知发送成功")
@@ -204,7 +194,7 @@ func SendStepNotification(taskID string, step int, stepType, stepName, status, m
 	return nil
 }
 
-// getLastStepDuration 获取指定步骤的上次耗时（秒数，保留2位小数）
+// getLastStepDuration 获取指定步骤的预计耗时（最近几次耗时的截尾移动平均，秒数，保留2位小数）
 func getLastStepDuration(project, stepName string) float64 {
 	// 对于web项目，不需要获取历史耗时信息
 	if strings.Contains(project, "-web") {
@@ -217,13 +207,8 @@ func getLastStepDuration(project, stepName string) float64 {
 		return 0.0
 	}
 
-	if duration, ok := versionInfo.StepDurations[stepName]; ok {
-		if d, ok := duration.(float64); ok {
-			// 返回秒数，保留2位小数
-			return math.Round(d*100) / 100
-		}
-	}
-	return 0.0
+	// 返回秒数（截尾移动平均），保留2位小数
+	return math.Round(stepDurationEstimate(versionInfo, stepName)*100) / 100
 }
 
 // calculateEstimatedEnd 计算当前步骤的预计结束时间
@@ -233,7 +218,7 @@ func calculateEstimatedEnd(project, currentStepName string) string {
 
 	// 如果没有历史数据，使用默认估算时间（30秒）
 	if lastDuration == 0 {
-		lastDuration = 30.0
+		lastDuration = defaultStepEstimate
 	}
 
 	// 当前步骤预估结束时间 = 当前时间 + 上次执行耗时
@@ -263,11 +248,12 @@ func getNotifyURL() string {
 	if !config.AppConfig.Notification.Enable {
 		return ""
 	}
-	return config.AppConfig.Notification.NotifyURL
+	return filterOutboundURL(config.AppConfig.Notification.NotifyURL, "通知地址")
 }
 
 // SendTaskNotification 发送任务级别通知（最终完成/取消/失败）
-func SendTaskNotification(taskID, name, startedAt, status string, opsURL, proURL string, stepDurations map[string]interface{}) error {
+// failure为失败步骤详情，非失败状态传nil
+func SendTaskNotification(taskID, name, startedAt, status string, opsURL, proURL string, stepDurations map[string]interface{}, failure *TaskFailure) error {
 	// 获取通知URL
 	notifyURL := getNotifyURL()
 	if notifyURL == "" {
@@ -278,12 +264,16 @@ func SendTaskNotification(taskID, name, startedAt, status string, opsURL, proURL
 	// 规范状态
 	normStatus := status
 	switch status {
-	case "complete", "failed", "cancel", "running":
+	case "complete", "failed", "cancel", "running", "queued":
 		// ok
 	default:
 		normStatus = "complete"
 	}
 
+	// 回调方提供的飞书地址会被服务端使用，不在允许列表中的直接丢弃
+	opsURL = filterOutboundURL(opsURL, "运维飞书地址")
+	proURL = filterOutboundURL(proURL, "产品飞书地址")
+
 	// 构建任务通知数据（IsStep=false）
 	notificationData := UnifiedNotificationData{
 		IsStep:        false,
@@ -296,6 +286,14 @@ func SendTaskNotification(taskID, name, startedAt, status string, opsURL, proURL
 		OpsURL:        opsURL,
 		FeishuURL:     proURL,
 		StepDurations: stepDurations,
+		Failure:       failure,
+		Artifacts:     taskArtifacts(taskID),
+		RetryOf:       TaskRetryOf(taskID),
+	}
+	if failure != nil {
+		notificationData.FailedStep = failure.StepType
+		notificationData.ErrorMessage = failure.ErrorMessage
+		notificationData.RolledBack = failure.RolledBack
 	}
 
 	// 序列化为JSON
@@ -324,29 +322,11 @@ func SendTaskNotification(taskID, name, startedAt, status string, opsURL, proURL
 		return fmt.Errorf("序列化任务请求体失败: %v", err)
 	}
 
-	// 发送HTTP请求
+	// 发送HTTP请求（失败时进入重试队列，按顺序补发）
 	//AppLogger.Info(fmt.Sprintf("正在发送任务通知HTTP请求到: %s", notifyURL))
-	resp, err := http.Post(notifyURL, "application/json", bytes.NewReader(requestJson))
-	if err != nil {
+	if err := deliverNotification(notifyContext(taskID), taskID, notifyURL, requestJson); err != nil {
 		AppLogger.Error(fmt.Sprintf("发送任务通知请求失败: %v", err))
-		return fmt.Errorf("发送任务通知请求失败: %v", err)
-	}
-	defer resp.Body.Close()
-
-	// 读取响应
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		AppLogger.Error(fmt.Sprintf("读取任务通知响应失败: %v", err))
-		return fmt.Errorf("读取任务通知响应失败: %v", err)
-	}
-
-	AppLogger.Info(fmt.Sprintf("任务通知响应状态码: %d", resp.StatusCode))
-	AppLogger.Info(fmt.Sprintf("任务通知响应内容: %s", string(respBody)))
-
-	// 检查响应状态
-	if resp.StatusCode != 200 {
-		AppLogger.Error(fmt.Sprintf("任务通知远程接口返回错误状态码 %d: %s", resp.StatusCode, string(respBody)))
-		return fmt.Errorf("远程接口返回错误: %s", string(respBody))
+		return err
 	}
 
 	//AppLogger.Info("任务通知发送成功")
@@ -0,0 +1,196 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"cicd-agent/config"
+)
+
+// TaskEvent 任务生命周期事件，各通知渠道共用
+type TaskEvent struct {
+	TaskID        string
+	Project       string
+	ProjectName   string
+	Tag           string
+	Category      string
+	DeployType    string // web/single/double
+	Status        string // queued/running/complete/failed/cancel
+	StartedAt     string
+	FinishedAt    string
+	OpsURL        string // 运维飞书地址（回调传入）
+	ProURL        string // 产品飞书地址（回调传入）
+	StepDurations map[string]interface{}
+	Failure       *TaskFailure // 失败步骤及日志末尾，failed事件未指定时自动从最近失败的步骤读取
+}
+
+// 事件级别
+const (
+	SeverityInfo     = "info"     // 开始、成功
+	SeverityCritical = "critical" // 失败、取消
+)
+
+// EventSeverity 根据任务状态获取事件级别
+func EventSeverity(status string) string {
+	if status == "failed" || status == "cancel" {
+		return SeverityCritical
+	}
+	return SeverityInfo
+}
+
+// Notifier 任务通知渠道
+// 渠道自行判断项目是否启用（未启用时返回nil），新渠道通过RegisterNotifier注册
+type Notifier interface {
+	Name() string
+	Notify(event TaskEvent) error
+}
+
+// notifierRegistry 通知渠道注册表
+type notifierRegistry struct {
+	mu        sync.RWMutex
+	notifiers []Notifier
+}
+
+var notifiers = &notifierRegistry{}
+
+func init() {
+	RegisterNotifier(notifierFunc{"notify", notifyServer})
+	RegisterNotifier(notifierFunc{"feishu", notifyFeishu})
+	RegisterNotifier(notifierFunc{"dingtalk", notifyDingTalk})
+	RegisterNotifier(notifierFunc{"wecom", notifyWeCom})
+	RegisterNotifier(notifierFunc{"slack", notifySlack})
+	RegisterNotifier(notifierFunc{"email", notifyEmail})
+}
+
+// RegisterNotifier 注册通知渠道，同名渠道会被替换
+func RegisterNotifier(notifier Notifier) {
+	notifiers.mu.Lock()
+	defer notifiers.mu.Unlock()
+
+	for i, existing := range notifiers.notifiers {
+		if existing.Name() == notifier.Name() {
+			notifiers.notifiers[i] = notifier
+			return
+		}
+	}
+	notifiers.notifiers = append(notifiers.notifiers, notifier)
+}
+
+// NotifyTask 并发向项目启用的所有渠道发送任务通知，单个渠道失败不影响其他渠道
+// notification.routing配置了项目（或"*"）时按事件级别选择渠道；
+// 否则notification.channels配置了项目（或"*"）时只发送列出的渠道，都未配置时发送全部已注册渠道
+func NotifyTask(event TaskEvent) error {
+	if event.FinishedAt == "" && event.Status != "running" && event.Status != "queued" {
+		event.FinishedAt = time.Now().Format("2006-01-02 15:04:05")
+	}
+
+	if event.Status == "failed" && event.Failure == nil {
+		event.Failure = takeTaskFailure(event.TaskID)
+	} else if event.Status != "failed" {
+		failedSteps.Delete(event.TaskID)
+	}
+
+	// 事件总线不受渠道路由限制，开启后发布全部任务事件
+	publishTaskEvent(event)
+
+	selected := selectNotifiers(event.Project, event.Status)
+	errs := make([]error, len(selected))
+
+	var wg sync.WaitGroup
+	for i, notifier := range selected {
+		wg.Add(1)
+		go func(i int, notifier Notifier) {
+			defer wg.Done()
+			if err := notifier.Notify(event); err != nil {
+				errs[i] = fmt.Errorf("%s: %v", notifier.Name(), err)
+			}
+		}(i, notifier)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// selectNotifiers 获取项目在该状态下启用的通知渠道
+func selectNotifiers(project, status string) []Notifier {
+	notifiers.mu.RLock()
+	defer notifiers.mu.RUnlock()
+
+	names := config.AppConfig.GetNotificationChannels(project)
+	if route, ok := config.AppConfig.GetNotificationRoute(project); ok {
+		names = route.Info
+		if EventSeverity(status) == SeverityCritical {
+			names = route.Critical
+		}
+		if names == nil {
+			return nil
+		}
+	}
+	if names == nil {
+		return append([]Notifier(nil), notifiers.notifiers...)
+	}
+
+	var selected []Notifier
+	for _, notifier := range notifiers.notifiers {
+		for _, name := range names {
+			if notifier.Name() == name {
+				selected = append(selected, notifier)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+// notifierFunc 以函数实现的通知渠道
+type notifierFunc struct {
+	name   string
+	notify func(event TaskEvent) error
+}
+
+// Name 渠道名称
+func (n notifierFunc) Name() string { return n.name }
+
+// Notify 发送通知
+func (n notifierFunc) Notify(event TaskEvent) error { return n.notify(event) }
+
+// notifyServer 通知中心服务（notification.notify_url），开始事件不发送
+func notifyServer(event TaskEvent) error {
+	if event.Status == "running" {
+		return nil
+	}
+	return SendTaskNotification(event.TaskID, event.Project, event.StartedAt, event.Status, event.OpsURL, event.ProURL, event.StepDurations, event.Failure)
+}
+
+// notifyFeishu 飞书卡片，开始事件不发送
+func notifyFeishu(event TaskEvent) error {
+	if event.Status == "running" {
+		return nil
+	}
+	return SendFeishuCard(notifyContext(event.TaskID), event.OpsURL, event.TaskID, event.Project, event.Tag, event.Status, event.StartedAt, event.FinishedAt, event.DeployType, event.Category, event.ProjectName, event.Failure)
+}
+
+// notifyDingTalk 钉钉机器人
+func notifyDingTalk(event TaskEvent) error {
+	return SendDingTalkCard(notifyContext(event.TaskID), event.Project, event.Tag, event.Status, event.StartedAt, event.FinishedAt, event.DeployType, event.Category, event.ProjectName)
+}
+
+// notifyWeCom 企业微信机器人
+func notifyWeCom(event TaskEvent) error {
+	return SendWeComCard(notifyContext(event.TaskID), event.Project, event.Tag, event.Status, event.StartedAt, event.FinishedAt, event.DeployType, event.Category, event.ProjectName)
+}
+
+// notifySlack Slack
+func notifySlack(event TaskEvent) error {
+	return SendSlackMessage(notifyContext(event.TaskID), event.Project, event.Tag, event.Status, event.StartedAt, event.FinishedAt, event.DeployType, event.Category, event.ProjectName)
+}
+
+// notifyEmail 邮件，排队、开始事件不发送
+func notifyEmail(event TaskEvent) error {
+	if event.Status == "running" || event.Status == "queued" {
+		return nil
+	}
+	return SendTaskEmail(event.Project, event.Tag, event.Status, event.StartedAt, event.FinishedAt, event.DeployType, event.Category, event.ProjectName, event.Failure)
+}
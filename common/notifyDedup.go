@@ -0,0 +1,74 @@
+package common
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"cicd-agent/config"
+)
+
+// notifyDeduper 步骤通知去重：窗口内相同的通知只发送一次，被抑制的次数随下一次发送的同类通知上报
+type notifyDeduper struct {
+	mu      sync.Mutex
+	entries map[string]*dedupEntry
+}
+
+// dedupEntry 同类通知的发送记录
+type dedupEntry struct {
+	lastSent   time.Time
+	suppressed int // 上次发送后被抑制的次数
+}
+
+var stepNotifyDeduper = &notifyDeduper{entries: make(map[string]*dedupEntry)}
+
+var notifySuppressedTotal = NewCounterVec("cicd_agent_notify_suppressed_total",
+	"被去重抑制的步骤通知数", "step_type")
+
+// stepNotifyKey 步骤通知的去重键（任务、步骤和状态相同视为相同通知）
+func stepNotifyKey(taskID string, step int, stepType, status string) string {
+	return fmt.Sprintf("%s|%d|%s|%s", taskID, step, stepType, status)
+}
+
+// allow 判断通知是否发送，允许时返回之前被抑制的次数
+func (d *notifyDeduper) allow(key string, window time.Duration) (bool, int) {
+	if window <= 0 {
+		return true, 0
+	}
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// 清理长时间没有出现的记录
+	for k, entry := range d.entries {
+		if now.Sub(entry.lastSent) > 10*window {
+			delete(d.entries, k)
+		}
+	}
+
+	entry, ok := d.entries[key]
+	if !ok {
+		d.entries[key] = &dedupEntry{lastSent: now}
+		return true, 0
+	}
+	if now.Sub(entry.lastSent) < window {
+		entry.suppressed++
+		return false, 0
+	}
+
+	suppressed := entry.suppressed
+	entry.lastSent = now
+	entry.suppressed = 0
+	return true, suppressed
+}
+
+// dedupStepNotification 判断步骤通知是否需要发送，返回之前被抑制的重复次数
+func dedupStepNotification(taskID string, step int, stepType, status string) (bool, int) {
+	allowed, repeats := stepNotifyDeduper.allow(stepNotifyKey(taskID, step, stepType, status), config.AppConfig.GetNotifyDedupWindow())
+	if !allowed {
+		notifySuppressedTotal.Inc(stepType)
+		AppLogger.Debug(fmt.Sprintf("抑制重复的步骤通知: 任务=%s, 步骤=%s, 状态=%s", taskID, stepType, status))
+	}
+	return allowed, repeats
+}
@@ -0,0 +1,277 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cicd-agent/config"
+)
+
+// notifyQueueInterval 重试队列检查间隔
+const notifyQueueInterval = 2 * time.Second
+
+// queuedNotification 待重试的通知（每条一个文件：{队列目录}/{ID}.json）
+type queuedNotification struct {
+	ID          string    `json:"id"`
+	TaskID      string    `json:"task_id"`
+	URL         string    `json:"url"`
+	Body        []byte    `json:"body"` // 已加密的完整请求体
+	Attempts    int       `json:"attempts"`
+	CreatedAt   time.Time `json:"created_at"`
+	NextAttempt time.Time `json:"next_attempt"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// notifyQueue 通知重试队列，按创建顺序保存，同一任务的通知按顺序送达
+type notifyQueue struct {
+	mu    sync.Mutex
+	items []*queuedNotification
+	seq   atomic.Uint64
+	once  sync.Once
+}
+
+var notifyRetryQueue = &notifyQueue{}
+
+var notifyRetryTotal = NewCounterVec("cicd_agent_notify_retry_total",
+	"通知重试队列处理次数", "result")
+
+// StartNotifyQueue 加载磁盘上未送达的通知并启动后台重试
+func StartNotifyQueue() {
+	notifyRetryQueue.once.Do(func() {
+		if err := notifyRetryQueue.load(); err != nil {
+			AppLogger.Error("加载通知重试队列失败:", err)
+		}
+		go notifyRetryQueue.run()
+	})
+}
+
+// NotifyQueueLength 当前待重试的通知数量
+func NotifyQueueLength() int {
+	notifyRetryQueue.mu.Lock()
+	defer notifyRetryQueue.mu.Unlock()
+	return len(notifyRetryQueue.items)
+}
+
+// deliverNotification 发送通知到通知中心，失败时写入重试队列
+// 同一任务已有排队中的通知时直接排队，保证服务端按顺序收到任务状态
+// 成功发送或成功入队都返回nil
+// 发送途中任务被取消导致的失败同样入队，由后台补发
+func deliverNotification(ctx context.Context, taskID, notifyURL string, body []byte) error {
+	if notifyRetryQueue.hasPending(taskID) {
+		return notifyRetryQueue.enqueue(taskID, notifyURL, body, "同一任务有待重试的通知")
+	}
+
+	err := postNotification(ctx, notifyURL, body)
+	if err == nil {
+		return nil
+	}
+	if queueErr := notifyRetryQueue.enqueue(taskID, notifyURL, body, err.Error()); queueErr != nil {
+		AppLogger.Error("通知写入重试队列失败:", queueErr)
+		return err
+	}
+	AppLogger.Warning(fmt.Sprintf("通知发送失败，已加入重试队列: 任务=%s, 错误=%v", taskID, err))
+	return nil
+}
+
+// postNotification 发送通知请求，非200响应视为失败
+func postNotification(ctx context.Context, notifyURL string, body []byte) error {
+	resp, err := PostJSON(ctx, notifyURL, body)
+	if err != nil {
+		return fmt.Errorf("发送通知请求失败: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("远程接口返回错误状态码 %d: %s", resp.StatusCode, string(resp.Body))
+	}
+	return nil
+}
+
+// hasPending 判断任务是否有排队中的通知
+func (q *notifyQueue) hasPending(taskID string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, item := range q.items {
+		if item.TaskID == taskID {
+			return true
+		}
+	}
+	return false
+}
+
+// enqueue 加入队列并持久化
+func (q *notifyQueue) enqueue(taskID, notifyURL string, body []byte, reason string) error {
+	now := time.Now()
+	item := &queuedNotification{
+		ID:          fmt.Sprintf("%020d-%06d", now.UnixNano(), q.seq.Add(1)%1000000),
+		TaskID:      taskID,
+		URL:         notifyURL,
+		Body:        body,
+		CreatedAt:   now,
+		NextAttempt: now.Add(q.backoff(0)),
+		LastError:   reason,
+	}
+	if err := q.persist(item); err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	q.items = append(q.items, item)
+	q.mu.Unlock()
+	return nil
+}
+
+// run 后台定时处理到期的通知
+func (q *notifyQueue) run() {
+	ticker := time.NewTicker(notifyQueueInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		q.drain()
+	}
+}
+
+// drain 按顺序重试到期的通知，同一任务前面的通知未送达时后面的不发送
+func (q *notifyQueue) drain() {
+	q.mu.Lock()
+	items := append([]*queuedNotification(nil), q.items...)
+	q.mu.Unlock()
+	if len(items) == 0 {
+		return
+	}
+
+	now := time.Now()
+	maxAge := config.AppConfig.GetNotifyRetryMaxAge()
+	blocked := make(map[string]bool)
+
+	for _, item := range items {
+		if now.Sub(item.CreatedAt) > maxAge {
+			AppLogger.Error(fmt.Sprintf("通知超过最长重试时间，已丢弃: 任务=%s, 尝试次数=%d, 最后错误=%s",
+				item.TaskID, item.Attempts, item.LastError))
+			notifyRetryTotal.Inc("expired")
+			q.remove(item)
+			continue
+		}
+		if blocked[item.TaskID] || now.Before(item.NextAttempt) {
+			blocked[item.TaskID] = true
+			continue
+		}
+
+		if err := postNotification(context.Background(), item.URL, item.Body); err != nil {
+			item.Attempts++
+			item.LastError = err.Error()
+			item.NextAttempt = time.Now().Add(q.backoff(item.Attempts))
+			if persistErr := q.persist(item); persistErr != nil {
+				AppLogger.Error("更新通知重试记录失败:", persistErr)
+			}
+			notifyRetryTotal.Inc("failed")
+			blocked[item.TaskID] = true
+			continue
+		}
+
+		AppLogger.Info(fmt.Sprintf("通知重试发送成功: 任务=%s, 尝试次数=%d", item.TaskID, item.Attempts+1))
+		notifyRetryTotal.Inc("success")
+		q.remove(item)
+	}
+}
+
+// backoff 计算第attempts次失败后的重试间隔（指数退避）
+func (q *notifyQueue) backoff(attempts int) time.Duration {
+	minBackoff, maxBackoff := config.AppConfig.GetNotifyRetryBackoff()
+	delay := minBackoff
+	for i := 0; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
+
+// remove 从队列和磁盘删除
+func (q *notifyQueue) remove(target *queuedNotification) {
+	q.mu.Lock()
+	for i, item := range q.items {
+		if item == target {
+			q.items = append(q.items[:i], q.items[i+1:]...)
+			break
+		}
+	}
+	q.mu.Unlock()
+
+	if err := os.Remove(q.itemPath(target.ID)); err != nil && !os.IsNotExist(err) {
+		AppLogger.Warning("删除通知重试记录失败:", err)
+	}
+}
+
+// persist 写入磁盘（先写临时文件再重命名，避免进程退出时留下半个文件）
+func (q *notifyQueue) persist(item *queuedNotification) error {
+	dir := config.AppConfig.GetNotifyQueueDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("创建通知队列目录失败: %v", err)
+	}
+
+	data, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("序列化通知重试记录失败: %v", err)
+	}
+	path := q.itemPath(item.ID)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("写入通知重试记录失败: %v", err)
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// load 启动时加载磁盘上的通知
+func (q *notifyQueue) load() error {
+	dir := config.AppConfig.GetNotifyQueueDir()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var items []*queuedNotification
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			AppLogger.Warning("读取通知重试记录失败:", err)
+			continue
+		}
+		var item queuedNotification
+		if err := json.Unmarshal(data, &item); err != nil || item.ID == "" {
+			AppLogger.Warning("通知重试记录格式错误，已忽略:", entry.Name())
+			continue
+		}
+		items = append(items, &item)
+	}
+
+	// ID以创建时间开头，排序后即为创建顺序
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+
+	q.mu.Lock()
+	q.items = items
+	q.mu.Unlock()
+
+	if len(items) > 0 {
+		AppLogger.Info(fmt.Sprintf("已加载待重试通知: %d条", len(items)))
+	}
+	return nil
+}
+
+// itemPath 通知记录文件路径
+func (q *notifyQueue) itemPath(id string) string {
+	return filepath.Join(config.AppConfig.GetNotifyQueueDir(), id+".json")
+}
@@ -0,0 +1,90 @@
+package common
+
+import (
+	"context"
+	"sync"
+
+	"cicd-agent/config"
+)
+
+// 受全局并发限制的操作类型（对应deployment.operation_weights的键）
+const (
+	OperationPull  = "pull"  // docker pull
+	OperationPush  = "push"  // docker push
+	OperationApply = "apply" // kubectl apply
+)
+
+// weightedSemaphore 带权重的信号量，上限每次获取时从配置读取，重新加载配置后立即生效
+type weightedSemaphore struct {
+	mu      sync.Mutex
+	used    int
+	waiting int
+	limit   func() int
+	changed chan struct{} // 释放时关闭并替换，唤醒所有等待者重新检查
+}
+
+// operationLimiter 所有任务共享的docker/kubectl操作限制
+var operationLimiter = &weightedSemaphore{
+	limit:   func() int { return config.AppConfig.GetOperationLimit() },
+	changed: make(chan struct{}),
+}
+
+// AcquireOperation 获取一次操作的执行名额，返回释放函数；名额不足时阻塞直到有名额或ctx取消
+func AcquireOperation(ctx context.Context, operation string) (func(), error) {
+	weight, err := operationLimiter.acquire(ctx, config.AppConfig.GetOperationWeight(operation))
+	if err != nil {
+		return nil, err
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() { operationLimiter.release(weight) })
+	}, nil
+}
+
+// OperationUsage 当前占用的权重和等待中的操作数
+func OperationUsage() (int, int) {
+	operationLimiter.mu.Lock()
+	defer operationLimiter.mu.Unlock()
+	return operationLimiter.used, operationLimiter.waiting
+}
+
+// acquire 获取n个名额（超过上限时按上限计算，避免永远无法获取），返回实际占用的权重
+func (s *weightedSemaphore) acquire(ctx context.Context, n int) (int, error) {
+	s.mu.Lock()
+	s.waiting++
+	defer func() {
+		s.mu.Lock()
+		s.waiting--
+		s.mu.Unlock()
+	}()
+
+	for {
+		limit := s.limit()
+		if n > limit {
+			n = limit
+		}
+		if s.used+n <= limit {
+			s.used += n
+			s.mu.Unlock()
+			return n, nil
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+		s.mu.Lock()
+	}
+}
+
+// release 释放n个名额并唤醒等待者
+func (s *weightedSemaphore) release(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.used -= n
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
@@ -0,0 +1,49 @@
+package common
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"cicd-agent/config"
+)
+
+// ValidateOutboundURL 校验出站请求地址是否符合outbound配置（协议和主机白名单）
+// 通知和Webhook地址可能来自回调参数，发送前必须校验以防止SSRF
+func ValidateOutboundURL(rawURL string) error {
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return fmt.Errorf("出站地址格式错误: %v", err)
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("出站地址缺少主机名: %s", rawURL)
+	}
+
+	schemeAllowed := false
+	for _, scheme := range config.AppConfig.GetOutboundAllowedSchemes() {
+		if strings.EqualFold(scheme, parsed.Scheme) {
+			schemeAllowed = true
+			break
+		}
+	}
+	if !schemeAllowed {
+		return fmt.Errorf("出站地址协议不被允许: %s", parsed.Scheme)
+	}
+
+	if !config.AppConfig.IsOutboundHostAllowed(parsed.Hostname()) {
+		return fmt.Errorf("出站地址主机不在允许列表中: %s", parsed.Hostname())
+	}
+	return nil
+}
+
+// filterOutboundURL 地址不被允许时记录警告并返回空字符串
+func filterOutboundURL(rawURL, usage string) string {
+	if rawURL == "" {
+		return ""
+	}
+	if err := ValidateOutboundURL(rawURL); err != nil {
+		AppLogger.Warning(fmt.Sprintf("已忽略%s: %v", usage, err))
+		return ""
+	}
+	return rawURL
+}
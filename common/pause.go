@@ -0,0 +1,89 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// taskPause 暂停中的任务，恢复时关闭resumed
+type taskPause struct {
+	pausedAt time.Time
+	resumed  chan struct{}
+}
+
+// 暂停的任务：任务ID -> 暂停状态
+var (
+	taskPauseMu sync.Mutex
+	taskPauses  = make(map[string]*taskPause)
+)
+
+// PauseTask 暂停执行中的任务，任务在当前步骤结束后、下一步骤开始前停住
+func PauseTask(taskID, operator string) error {
+	if !IsTaskRunning(taskID) {
+		return fmt.Errorf("任务未在执行中")
+	}
+	taskPauseMu.Lock()
+	defer taskPauseMu.Unlock()
+	if _, ok := taskPauses[taskID]; ok {
+		return fmt.Errorf("任务已暂停")
+	}
+	taskPauses[taskID] = &taskPause{pausedAt: time.Now(), resumed: make(chan struct{})}
+	AppLogger.Info(fmt.Sprintf("任务已暂停: 任务ID=%s, 操作人=%s", taskID, operator))
+	return nil
+}
+
+// ResumeTask 恢复暂停的任务
+func ResumeTask(taskID, operator string) error {
+	taskPauseMu.Lock()
+	defer taskPauseMu.Unlock()
+	pause, ok := taskPauses[taskID]
+	if !ok {
+		return fmt.Errorf("任务未暂停")
+	}
+	close(pause.resumed)
+	delete(taskPauses, taskID)
+	AppLogger.Info(fmt.Sprintf("任务已恢复: 任务ID=%s, 操作人=%s, 暂停时长=%s",
+		taskID, operator, time.Since(pause.pausedAt).Round(time.Second)))
+	return nil
+}
+
+// IsTaskPaused 判断任务是否处于暂停状态
+func IsTaskPaused(taskID string) bool {
+	taskPauseMu.Lock()
+	defer taskPauseMu.Unlock()
+	_, ok := taskPauses[taskID]
+	return ok
+}
+
+// WaitIfPaused 任务暂停时阻塞直到恢复或ctx（任务执行使用的上下文）被取消（取消由接下来的步骤检查并通知），返回是否等待过
+// onPause在开始等待时调用一次，用于发送暂停通知
+func WaitIfPaused(ctx context.Context, taskID string, onPause func()) bool {
+	taskPauseMu.Lock()
+	pause, ok := taskPauses[taskID]
+	taskPauseMu.Unlock()
+	if !ok {
+		return false
+	}
+
+	if onPause != nil {
+		onPause()
+	}
+	select {
+	case <-pause.resumed:
+	case <-ctx.Done():
+		clearTaskPause(taskID)
+	}
+	return true
+}
+
+// clearTaskPause 移除任务的暂停状态（任务取消或结束时）
+func clearTaskPause(taskID string) {
+	taskPauseMu.Lock()
+	defer taskPauseMu.Unlock()
+	if pause, ok := taskPauses[taskID]; ok {
+		close(pause.resumed)
+		delete(taskPauses, taskID)
+	}
+}
@@ -0,0 +1,20 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// TempWorkDir 系统临时目录下的工作目录（Linux为/tmp/<name>，Windows为%TEMP%\<name>）
+func TempWorkDir(name string) string {
+	return filepath.Join(os.TempDir(), name)
+}
+
+// DefaultSSHKeyPath 当前用户的默认SSH私钥（~/.ssh/id_rsa），获取不到用户目录时使用相对路径
+func DefaultSSHKeyPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".ssh", "id_rsa")
+	}
+	return filepath.Join(home, ".ssh", "id_rsa")
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package common
+
+// DefaultNginxConfDir 本机nginx配置目录
+func DefaultNginxConfDir() string {
+	return "/etc/nginx/conf.d"
+}
@@ -0,0 +1,8 @@
+//go:build windows
+
+package common
+
+// DefaultNginxConfDir 本机nginx配置目录（Windows版nginx默认解压到C:\nginx）
+func DefaultNginxConfDir() string {
+	return `C:\nginx\conf\conf.d`
+}
@@ -0,0 +1,106 @@
+package common
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"cicd-agent/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rateLimitIdleTTL 令牌桶闲置多久后回收
+const rateLimitIdleTTL = 10 * time.Minute
+
+// tokenBucket 令牌桶
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// rateLimiter 按“接口+客户端IP”维护令牌桶
+type rateLimiter struct {
+	mu          sync.Mutex
+	buckets     map[string]*tokenBucket
+	lastCleanup time.Time
+}
+
+var defaultRateLimiter = &rateLimiter{buckets: make(map[string]*tokenBucket)}
+
+// allow 尝试消耗一个令牌，不足时返回需要等待的时间
+func (l *rateLimiter) allow(key string, rule config.RateLimitRule) (bool, time.Duration) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.cleanup(now)
+
+	bucket, exists := l.buckets[key]
+	if !exists {
+		bucket = &tokenBucket{tokens: float64(rule.Burst), lastSeen: now}
+		l.buckets[key] = bucket
+	} else {
+		// 按流逝时间补充令牌
+		elapsed := now.Sub(bucket.lastSeen).Seconds()
+		bucket.tokens = math.Min(float64(rule.Burst), bucket.tokens+elapsed*rule.RPS)
+		bucket.lastSeen = now
+	}
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - bucket.tokens) / rule.RPS * float64(time.Second))
+	return false, wait
+}
+
+// cleanup 定期回收闲置的令牌桶（调用方持有锁）
+func (l *rateLimiter) cleanup(now time.Time) {
+	if now.Sub(l.lastCleanup) < rateLimitIdleTTL {
+		return
+	}
+	l.lastCleanup = now
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.lastSeen) > rateLimitIdleTTL {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// RateLimitMiddleware 限流中间件，超过限制时返回429
+func RateLimitMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			c.Next()
+			return
+		}
+		route = config.NormalizeRoutePath(route)
+
+		rule, limited := config.AppConfig.GetRateLimitRule(route)
+		if !limited {
+			c.Next()
+			return
+		}
+
+		clientIP := getClientIP(c)
+		allowed, wait := defaultRateLimiter.allow(route+"|"+clientIP, rule)
+		if !allowed {
+			AppLogger.Warning(fmt.Sprintf("请求过于频繁已限流: IP=%s, 接口=%s", clientIP, route))
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"code": 429,
+				"msg":  "请求过于频繁，请稍后重试",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,258 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"cicd-agent/config"
+)
+
+// 依赖检查结果
+const (
+	DependencyOK      = "ok"
+	DependencyFailed  = "failed"
+	DependencySkipped = "skipped" // 当前配置不需要该依赖
+)
+
+// DependencyStatus 单个外部依赖的检查结果
+type DependencyStatus struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	Message   string `json:"message,omitempty"`
+	LatencyMs int64  `json:"latency_ms"`
+}
+
+// ReadinessReport 依赖检查结果（/ready只返回各项状态，完整内容见/api/v1/ready）
+type ReadinessReport struct {
+	Ready     bool               `json:"ready"`
+	CheckedAt string             `json:"checked_at"`
+	Checks    []DependencyStatus `json:"checks"`
+}
+
+// dependencyCheck 一项依赖检查，run返回成功时的说明；run为nil时跳过并返回skip
+type dependencyCheck struct {
+	name string
+	run  func(ctx context.Context) (string, error)
+	skip string
+}
+
+var (
+	readinessMu       sync.Mutex
+	readinessReport   ReadinessReport
+	readinessReportAt time.Time
+)
+
+// CheckReadiness 检查agent能否正常处理部署：IP白名单已解析、依赖的外部命令可用、任务日志目录可写
+func CheckReadiness() error {
+	var problems []string
+	if err := checkWhitelistResolved(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	for _, name := range requiredCommands() {
+		if _, err := exec.LookPath(name); err != nil {
+			problems = append(problems, fmt.Sprintf("未找到命令 %s", name))
+		}
+	}
+	if err := checkLogDirWritable(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// checkWhitelistResolved 每个配置了条目的白名单至少解析出一个地址（域名全部解析失败时白名单会拒绝所有请求）
+func checkWhitelistResolved() error {
+	if whitelist == nil {
+		return fmt.Errorf("IP白名单未初始化")
+	}
+	whitelist.mutex.RLock()
+	defer whitelist.mutex.RUnlock()
+
+	var empty []string
+	for _, name := range config.AppConfig.WhitelistNames() {
+		if len(config.AppConfig.GetWhitelistEntries(name)) == 0 {
+			continue
+		}
+		if set, ok := whitelist.allowedIPs[name]; !ok || len(set.entries) == 0 {
+			empty = append(empty, name)
+		}
+	}
+	if len(empty) > 0 {
+		return fmt.Errorf("白名单 %s 未解析到任何地址", strings.Join(empty, ", "))
+	}
+	return nil
+}
+
+// hasJavaProjects 是否配置了Java项目（需要docker和kubectl）
+func hasJavaProjects() bool {
+	return len(config.AppConfig.Deployment.Double) > 0 || len(config.AppConfig.Deployment.Single) > 0
+}
+
+// requiredCommands 按配置需要的外部命令：有Java项目时需要kubectl和docker，有GitOps项目或记录部署文件修改时需要git
+func requiredCommands() []string {
+	var commands []string
+	hasJava := hasJavaProjects()
+	if hasJava {
+		commands = append(commands, "kubectl", "docker")
+	}
+	if len(config.AppConfig.GitOps.Projects) > 0 || (hasJava && config.AppConfig.ManifestTrackingEnabled()) {
+		commands = append(commands, "git")
+	}
+	return commands
+}
+
+// checkLogDirWritable 检查任务日志目录可写
+func checkLogDirWritable() error {
+	if err := os.MkdirAll("logs", 0755); err != nil {
+		return fmt.Errorf("创建日志目录失败: %v", err)
+	}
+	file, err := os.CreateTemp("logs", ".ready-*")
+	if err != nil {
+		return fmt.Errorf("日志目录不可写: %v", err)
+	}
+	file.Close()
+	os.Remove(file.Name())
+	return nil
+}
+
+// CheckDependencies 主动检查外部依赖（docker守护进程、各集群的kubectl连通性、Harbor接口、日志目录），
+// 各项并行执行，结果按readiness.cache_ttl缓存
+func CheckDependencies() ReadinessReport {
+	readinessMu.Lock()
+	defer readinessMu.Unlock()
+	cfg := config.AppConfig.Readiness
+	if !readinessReportAt.IsZero() && time.Since(readinessReportAt) < cfg.GetCacheTTL() {
+		return readinessReport
+	}
+
+	checks := dependencyChecks(cfg)
+	results := make([]DependencyStatus, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check dependencyCheck) {
+			defer wg.Done()
+			results[i] = runDependencyCheck(check, cfg.GetTimeout())
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := ReadinessReport{Ready: true, CheckedAt: time.Now().Format("2006-01-02 15:04:05"), Checks: results}
+	for _, result := range results {
+		if result.Status == DependencyFailed {
+			report.Ready = false
+		}
+	}
+	readinessReport, readinessReportAt = report, time.Now()
+	return report
+}
+
+// runDependencyCheck 执行一项检查
+func runDependencyCheck(check dependencyCheck, timeout time.Duration) DependencyStatus {
+	status := DependencyStatus{Name: check.name}
+	if check.run == nil {
+		status.Status = DependencySkipped
+		status.Message = check.skip
+		return status
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	start := time.Now()
+	message, err := check.run(ctx)
+	status.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		status.Status = DependencyFailed
+		status.Message = err.Error()
+		return status
+	}
+	status.Status = DependencyOK
+	status.Message = message
+	return status
+}
+
+// dependencyChecks 按配置生成检查项：docker和kubectl仅在配置了Java项目时检查，Harbor仅检查已配置的地址
+func dependencyChecks(cfg config.ReadinessConfig) []dependencyCheck {
+	hasJava := hasJavaProjects()
+	checks := []dependencyCheck{{name: "docker", skip: "未配置Java项目"}}
+	if hasJava {
+		checks[0].run = checkDockerDaemon
+	}
+
+	contexts := cfg.KubeContexts
+	if len(contexts) == 0 {
+		contexts = []string{""}
+	}
+	for _, kubeContext := range contexts {
+		check := dependencyCheck{name: "kubectl", skip: "未配置Java项目"}
+		if kubeContext != "" {
+			check.name = "kubectl/" + kubeContext
+		}
+		if hasJava {
+			check.run = func(ctx context.Context) (string, error) {
+				return checkKubeCluster(ctx, kubeContext, cfg.GetTimeout())
+			}
+		}
+		checks = append(checks, check)
+	}
+
+	for _, harbor := range []struct{ name, host string }{
+		{"harbor_online", config.AppConfig.Harbor.Online},
+		{"harbor_offline", config.AppConfig.Harbor.Offline},
+	} {
+		check := dependencyCheck{name: harbor.name, skip: "未配置地址"}
+		if harbor.host != "" {
+			check.run = func(ctx context.Context) (string, error) {
+				return checkHarbor(ctx, harbor.host)
+			}
+		}
+		checks = append(checks, check)
+	}
+
+	checks = append(checks, dependencyCheck{name: "logs_dir", run: func(ctx context.Context) (string, error) {
+		return "", checkLogDirWritable()
+	}})
+	return checks
+}
+
+// checkDockerDaemon 通过docker info确认docker守护进程可用
+func checkDockerDaemon(ctx context.Context) (string, error) {
+	output, err := RunCommand(ctx, NewCommand("docker", "info", "--format", "{{.ServerVersion}}"))
+	if err != nil {
+		return "", fmt.Errorf("docker守护进程不可用: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return "版本 " + strings.TrimSpace(string(output)), nil
+}
+
+// checkKubeCluster 通过API Server的/readyz确认kubectl能连接集群，kubeContext为空时使用当前上下文
+func checkKubeCluster(ctx context.Context, kubeContext string, timeout time.Duration) (string, error) {
+	var args []string
+	if kubeContext != "" {
+		args = append(args, "--context", kubeContext)
+	}
+	args = append(args, "get", "--raw", "/readyz", "--request-timeout="+timeout.String())
+	output, err := RunCommand(ctx, NewCommand("kubectl", args...))
+	if err != nil {
+		return "", fmt.Errorf("连接集群失败: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// checkHarbor 请求Harbor的ping接口（无需认证）
+func checkHarbor(ctx context.Context, host string) (string, error) {
+	resp, err := doHTTPOnce(ctx, HTTPRequest{Method: http.MethodGet, URL: fmt.Sprintf("https://%s/api/v2.0/ping", host)})
+	if err != nil {
+		return "", fmt.Errorf("请求Harbor失败: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Harbor返回状态码 %d", resp.StatusCode)
+	}
+	return host, nil
+}
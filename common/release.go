@@ -0,0 +1,58 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// taskReleaseFile 任务日志目录下的发布记录文件名
+const taskReleaseFile = "release.json"
+
+// ReleaseRecord 一次发布的内容（步骤13应用部署文件后记录），用于发布历史中对比相邻两次发布的变化
+type ReleaseRecord struct {
+	TaskID     string                  `json:"task_id"`
+	Project    string                  `json:"project"`
+	Tag        string                  `json:"tag"`
+	Version    string                  `json:"version,omitempty"` // 部署到的版本（双版本项目为v1/v2）
+	DeployedAt string                  `json:"deployed_at"`
+	Images     map[string]ReleaseImage `json:"images"` // 服务名 -> 镜像
+	Files      map[string]string       `json:"files"`  // 部署文件相对路径 -> 内容摘要（镜像标签替换为占位符后计算，只改标签的文件摘要不变）
+}
+
+// ReleaseImage 发布使用的镜像
+type ReleaseImage struct {
+	Image  string `json:"image"`
+	Digest string `json:"digest,omitempty"` // 镜像摘要（sha256:...），获取失败时为空
+}
+
+// WriteReleaseRecord 写入发布记录到 logs/{任务ID}/release.json
+func WriteReleaseRecord(record ReleaseRecord) error {
+	logDir := filepath.Join("logs", record.TaskID)
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		return fmt.Errorf("创建任务日志目录失败: %v", err)
+	}
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化发布记录失败: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(logDir, taskReleaseFile), data, 0644); err != nil {
+		return fmt.Errorf("写入发布记录失败: %v", err)
+	}
+	return nil
+}
+
+// ReadReleaseRecord 读取任务的发布记录
+func ReadReleaseRecord(taskID string) (*ReleaseRecord, error) {
+	data, err := os.ReadFile(filepath.Join("logs", taskID, taskReleaseFile))
+	if err != nil {
+		return nil, err
+	}
+	var record ReleaseRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
@@ -0,0 +1,77 @@
+package common
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+
+	"cicd-agent/config"
+)
+
+// nonceCache 已使用的nonce（LRU），容量满时淘汰最久未使用的记录
+// 记录在时间窗口过后自然失效，超出窗口的请求会先被时间戳校验拒绝
+type nonceCache struct {
+	mu      sync.Mutex
+	order   *list.List               // 队首为最近使用
+	entries map[string]*list.Element // 作用域+nonce -> 链表节点
+}
+
+// nonceEntry LRU节点
+type nonceEntry struct {
+	key      string
+	expireAt time.Time
+}
+
+var replayNonces = &nonceCache{order: list.New(), entries: make(map[string]*list.Element)}
+
+// checkAndStore nonce未使用过（或已过期）时记录并返回true
+func (n *nonceCache) checkAndStore(key string, expireAt time.Time, capacity int) bool {
+	now := time.Now()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if elem, exists := n.entries[key]; exists {
+		entry := elem.Value.(*nonceEntry)
+		if now.Before(entry.expireAt) {
+			n.order.MoveToFront(elem)
+			return false
+		}
+		entry.expireAt = expireAt
+		n.order.MoveToFront(elem)
+		return true
+	}
+
+	n.entries[key] = n.order.PushFront(&nonceEntry{key: key, expireAt: expireAt})
+	for n.order.Len() > capacity {
+		oldest := n.order.Back()
+		n.order.Remove(oldest)
+		delete(n.entries, oldest.Value.(*nonceEntry).key)
+	}
+	return true
+}
+
+// verifyReplay 校验请求时间戳和nonce
+// scope区分不同接口的nonce空间；未携带nonce和时间戳且未开启replay.require_nonce时直接通过
+func verifyReplay(scope, nonce string, unixSeconds int64, window time.Duration) error {
+	if nonce == "" && unixSeconds == 0 {
+		if config.AppConfig.Replay.RequireNonce {
+			return fmt.Errorf("缺少nonce或时间戳")
+		}
+		return nil
+	}
+	if nonce == "" || unixSeconds == 0 {
+		return fmt.Errorf("nonce和时间戳必须同时提供")
+	}
+
+	issuedAt := time.Unix(unixSeconds, 0)
+	if skew := time.Since(issuedAt); skew > window || skew < -window {
+		return fmt.Errorf("请求时间戳超出允许范围")
+	}
+
+	if !replayNonces.checkAndStore(scope+":"+nonce, issuedAt.Add(window), config.AppConfig.GetReplayCacheSize()) {
+		return fmt.Errorf("重复的请求（重放）")
+	}
+	return nil
+}
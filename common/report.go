@@ -0,0 +1,229 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cicd-agent/config"
+)
+
+// ProjectReport 项目在统计周期内的部署情况
+type ProjectReport struct {
+	Project      string  `json:"project"`
+	Deploys      int     `json:"deploys"`
+	Succeeded    int     `json:"succeeded"`
+	Failed       int     `json:"failed"`
+	Cancelled    int     `json:"cancelled"`
+	Rollbacks    int     `json:"rollbacks"`     // 回滚触发的任务及失败后自动恢复的任务
+	SuccessRate  float64 `json:"success_rate"`  // 成功率（百分比）
+	MeanDuration float64 `json:"mean_duration"` // 已结束任务的平均耗时（秒）
+
+	totalDuration float64
+	finished      int
+}
+
+// DeployReport 部署汇总报告
+type DeployReport struct {
+	Period   string          `json:"period"`
+	Since    time.Time       `json:"since"`
+	Until    time.Time       `json:"until"`
+	Total    ProjectReport   `json:"total"`
+	Projects []ProjectReport `json:"projects"`
+}
+
+var (
+	reportMu   sync.Mutex
+	reportStop chan struct{}
+)
+
+// BuildDeployReport 根据任务记录统计[since, until)内开始的任务
+func BuildDeployReport(period string, since, until time.Time) DeployReport {
+	report := DeployReport{Period: period, Since: since, Until: until, Total: ProjectReport{Project: T("全部")}}
+	projects := make(map[string]*ProjectReport)
+
+	for _, meta := range ListTaskLogMetas("") {
+		startedAt, err := time.ParseInLocation("2006-01-02 15:04:05", meta.StartedAt, time.Local)
+		if err != nil || startedAt.Before(since) || !startedAt.Before(until) {
+			continue
+		}
+
+		stats, ok := projects[meta.Project]
+		if !ok {
+			stats = &ProjectReport{Project: meta.Project}
+			projects[meta.Project] = stats
+		}
+		for _, s := range []*ProjectReport{stats, &report.Total} {
+			s.add(meta, startedAt)
+		}
+	}
+
+	for _, stats := range projects {
+		stats.finish()
+		report.Projects = append(report.Projects, *stats)
+	}
+	report.Total.finish()
+	sort.Slice(report.Projects, func(i, j int) bool {
+		return report.Projects[i].Deploys > report.Projects[j].Deploys
+	})
+	return report
+}
+
+// add 计入一个任务
+func (p *ProjectReport) add(meta TaskLogMeta, startedAt time.Time) {
+	p.Deploys++
+	switch meta.Status {
+	case "complete":
+		p.Succeeded++
+	case "failed":
+		p.Failed++
+	case "cancel":
+		p.Cancelled++
+	}
+	if meta.Trigger == "rollback" || meta.RolledBack {
+		p.Rollbacks++
+	}
+	if finishedAt, err := time.ParseInLocation("2006-01-02 15:04:05", meta.FinishedAt, time.Local); err == nil {
+		p.totalDuration += finishedAt.Sub(startedAt).Seconds()
+		p.finished++
+	}
+}
+
+// finish 计算成功率和平均耗时
+func (p *ProjectReport) finish() {
+	if p.Deploys > 0 {
+		p.SuccessRate = float64(p.Succeeded*10000/p.Deploys) / 100
+	}
+	if p.finished > 0 {
+		p.MeanDuration = float64(int(p.totalDuration/float64(p.finished)*100)) / 100
+	}
+}
+
+// StartReportScheduler 按notification.report配置定时发送部署汇总报告（重新加载配置后需再次调用）
+func StartReportScheduler() {
+	reportMu.Lock()
+	if reportStop != nil {
+		close(reportStop)
+		reportStop = nil
+	}
+	cfg := config.AppConfig.Notification.Report
+	if !cfg.Enable {
+		reportMu.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	reportStop = stop
+	reportMu.Unlock()
+
+	go func() {
+		for {
+			next := nextReportTime(cfg, time.Now())
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-stop:
+				timer.Stop()
+				return
+			case <-timer.C:
+				// 多节点部署时只由主节点发送，避免重复
+				if IsLeader() {
+					sendScheduledReport(cfg)
+				}
+			}
+		}
+	}()
+	AppLogger.Info(fmt.Sprintf("部署汇总报告已启用: 周期=%s, 下次发送=%s",
+		cfg.GetReportPeriod(), nextReportTime(cfg, time.Now()).Format("2006-01-02 15:04")))
+}
+
+// nextReportTime 计算下次发送时间
+func nextReportTime(cfg config.ReportConfig, now time.Time) time.Time {
+	hour, minute := cfg.GetReportTime()
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+	if cfg.GetReportPeriod() == config.ReportWeekly {
+		days := (int(cfg.GetReportWeekday()) - int(now.Weekday()) + 7) % 7
+		next = next.AddDate(0, 0, days)
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 7)
+		}
+		return next
+	}
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// sendScheduledReport 统计并发送报告
+func sendScheduledReport(cfg config.ReportConfig) {
+	until := time.Now()
+	since := until.AddDate(0, 0, -1)
+	if cfg.GetReportPeriod() == config.ReportWeekly {
+		since = until.AddDate(0, 0, -7)
+	}
+	report := BuildDeployReport(cfg.GetReportPeriod(), since, until)
+
+	if cfg.FeishuWebhook != "" {
+		if err := postFeishuMessage(context.Background(), cfg.FeishuWebhook, buildReportCard(report)); err != nil {
+			AppLogger.Error("发送部署汇总报告到飞书失败:", err)
+		}
+	}
+	if len(cfg.Email) > 0 {
+		email := config.AppConfig.Notification.Email
+		message := buildPlainEmail(email.From, cfg.Email, reportTitle(report), buildReportText(report, "\r\n"))
+		if err := sendMail(email, cfg.Email, message); err != nil {
+			AppLogger.Error("发送部署汇总报告邮件失败:", err)
+		}
+	}
+	AppLogger.Info(fmt.Sprintf("部署汇总报告已发送: 任务数=%d, 项目数=%d", report.Total.Deploys, len(report.Projects)))
+}
+
+// reportTitle 报告标题
+func reportTitle(report DeployReport) string {
+	if report.Period == config.ReportWeekly {
+		return Tf("📊 部署周报（%s ~ %s）", report.Since.Format("01-02"), report.Until.Format("01-02"))
+	}
+	return Tf("📊 部署日报（%s）", report.Until.Format("2006-01-02"))
+}
+
+// buildReportText 报告正文
+func buildReportText(report DeployReport, newline string) string {
+	var lines []string
+	lines = append(lines, formatReportLine(report.Total), "")
+	if len(report.Projects) == 0 {
+		lines = append(lines, T("统计周期内没有部署任务"))
+	}
+	for _, project := range report.Projects {
+		lines = append(lines, formatReportLine(project))
+	}
+	return strings.Join(lines, newline)
+}
+
+// formatReportLine 单个项目的统计行
+func formatReportLine(p ProjectReport) string {
+	return Tf("%s: 部署%d次，成功%d，失败%d，取消%d，成功率%.2f%%，平均耗时%s，回滚%d次",
+		p.Project, p.Deploys, p.Succeeded, p.Failed, p.Cancelled, p.SuccessRate,
+		(time.Duration(p.MeanDuration) * time.Second).String(), p.Rollbacks)
+}
+
+// buildReportCard 报告飞书卡片
+func buildReportCard(report DeployReport) FeishuCardMessage {
+	return FeishuCardMessage{
+		MsgType: "interactive",
+		Card: FeishuCard{
+			Config: FeishuCardConfig{WideScreenMode: true},
+			Header: FeishuCardHeader{
+				Title:    FeishuText{Content: reportTitle(report), Tag: "plain_text"},
+				Template: "blue",
+			},
+			Elements: []FeishuElement{
+				FeishuTextBlock{
+					Tag:  "div",
+					Text: FeishuText{Content: buildReportText(report, "\n"), Tag: "plain_text"},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,69 @@
+package common
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// RequestIDHeader 请求ID的HTTP头
+	RequestIDHeader = "X-Request-ID"
+	// requestIDKey gin上下文中保存请求ID的键
+	requestIDKey = "request_id"
+	// maxRequestIDLength 允许透传的请求ID最大长度
+	maxRequestIDLength = 64
+)
+
+// RequestIDMiddleware 请求ID中间件
+// 优先沿用调用方传入的X-Request-ID，否则生成新的ID；写入gin上下文并在响应头中返回
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !isValidRequestID(requestID) {
+			requestID = NewRequestID()
+		}
+
+		c.Set(requestIDKey, requestID)
+		c.Header(RequestIDHeader, requestID)
+		c.Next()
+	}
+}
+
+// NewRequestID 生成新的请求ID
+func NewRequestID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
+
+// GetRequestID 获取当前请求的ID
+func GetRequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// RequestLogger 获取带有当前请求ID的日志器
+func RequestLogger(c *gin.Context) *Logger {
+	return AppLogger.WithRequestID(GetRequestID(c))
+}
+
+// isValidRequestID 校验调用方传入的请求ID，只允许字母、数字和-_.
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-' || r == '_' || r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
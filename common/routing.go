@@ -0,0 +1,223 @@
+package common
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cicd-agent/config"
+)
+
+// RoutedByHeader 路由模式转发的请求带有该头（值为转发方主机名），接收方校验RoutingTokenHeader通过后直接处理，不再次转发
+const RoutedByHeader = "X-Cicd-Routed-By"
+
+// RoutingTokenHeader 转发请求携带的routing.token
+const RoutingTokenHeader = "X-Cicd-Routing-Token"
+
+// VerifyRoutingToken 校验转发请求携带的routing.token，本agent未配置routing.token时不接受任何转发请求
+func VerifyRoutingToken(token string) bool {
+	expected := config.AppConfig.Routing.Token
+	return token != "" && expected != "" && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
+// RoutingTargetStatus 目标agent地址的健康状态
+type RoutingTargetStatus struct {
+	Agent     string `json:"agent"`
+	URL       string `json:"url"`
+	Healthy   bool   `json:"healthy"`
+	CheckedAt string `json:"checked_at,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// routingHealth 地址 -> 最近一次健康检查或转发的结果
+type routingHealth struct {
+	healthy   bool
+	checkedAt time.Time
+	err       string
+}
+
+var (
+	routingMu     sync.Mutex
+	routingStop   chan struct{}
+	routingStates = make(map[string]routingHealth)
+)
+
+// StartRoutingHealthCheck 按routing.health_check_interval定期检查各目标agent（重新加载配置后需再次调用）
+func StartRoutingHealthCheck() {
+	routingMu.Lock()
+	if routingStop != nil {
+		close(routingStop)
+		routingStop = nil
+	}
+	cfg := config.AppConfig.Routing
+	routingStates = make(map[string]routingHealth)
+	if !cfg.Enable || len(cfg.Agents) == 0 {
+		routingMu.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	routingStop = stop
+	routingMu.Unlock()
+
+	interval := cfg.GetHealthCheckInterval()
+	go func() {
+		checkRoutingTargets(cfg)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				checkRoutingTargets(cfg)
+			}
+		}
+	}()
+	AppLogger.Info(fmt.Sprintf("路由模式已启用: 目标agent=%d, 项目映射=%d, 健康检查间隔=%s",
+		len(cfg.Agents), len(cfg.Projects), interval))
+}
+
+// checkRoutingTargets 检查全部目标地址
+func checkRoutingTargets(cfg config.RoutingConfig) {
+	var wg sync.WaitGroup
+	for name, agent := range cfg.Agents {
+		for _, baseURL := range agent.URLs {
+			wg.Add(1)
+			go func(name, baseURL, healthPath string) {
+				defer wg.Done()
+				ctx, cancel := context.WithTimeout(context.Background(), cfg.GetTimeout())
+				defer cancel()
+				resp, err := doHTTPOnce(ctx, HTTPRequest{Method: http.MethodGet, URL: strings.TrimSuffix(baseURL, "/") + healthPath})
+				if err == nil && resp.StatusCode != http.StatusOK {
+					err = fmt.Errorf("状态码 %d", resp.StatusCode)
+				}
+				setRoutingHealth(name, baseURL, err)
+			}(name, baseURL, agent.GetHealthPath())
+		}
+	}
+	wg.Wait()
+}
+
+// setRoutingHealth 记录地址的健康状态，状态变化时记录日志
+func setRoutingHealth(agent, baseURL string, err error) {
+	state := routingHealth{healthy: err == nil, checkedAt: time.Now()}
+	if err != nil {
+		state.err = err.Error()
+	}
+
+	routingMu.Lock()
+	previous, known := routingStates[baseURL]
+	routingStates[baseURL] = state
+	routingMu.Unlock()
+
+	// 首次检查正常时不记录，之后只在状态变化时记录
+	if (known && previous.healthy == state.healthy) || (!known && state.healthy) {
+		return
+	}
+	if state.healthy {
+		AppLogger.Info(fmt.Sprintf("目标agent %s 已恢复: %s", agent, baseURL))
+	} else {
+		AppLogger.Warning(fmt.Sprintf("目标agent %s 不可用: %s, 错误: %s", agent, baseURL, state.err))
+	}
+}
+
+// routingTargets 按健康状态排序的目标地址：健康或尚未检查的地址在前，全部不健康时仍依次尝试
+func routingTargets(agent config.RoutingAgentConfig) []string {
+	routingMu.Lock()
+	defer routingMu.Unlock()
+	targets := append([]string(nil), agent.URLs...)
+	sort.SliceStable(targets, func(i, j int) bool {
+		return routingUsable(targets[i]) && !routingUsable(targets[j])
+	})
+	return targets
+}
+
+// routingUsable 地址健康或尚未检查（调用方持有routingMu）
+func routingUsable(baseURL string) bool {
+	state, ok := routingStates[baseURL]
+	return !ok || state.healthy
+}
+
+// ForwardToAgent 把请求转发给目标agent（路径与本agent收到的一致），依次尝试各地址，
+// 网络错误和5xx/429响应按routing.retries重试（指数退避）；重试耗尽时返回最后一次的响应
+// 目标agent对回调按任务ID去重，响应丢失导致的重复转发不会重复部署
+func ForwardToAgent(ctx context.Context, name string, agent config.RoutingAgentConfig, method, path string, body []byte, header map[string]string) (*HTTPResponse, string, error) {
+	cfg := config.AppConfig.Routing
+	var (
+		lastResp *HTTPResponse
+		lastURL  string
+		lastErr  error
+	)
+	for attempt := 0; attempt <= cfg.GetRetries(); attempt++ {
+		if attempt > 0 {
+			timer := time.NewTimer(cfg.GetRetryBackoff() << (attempt - 1))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, lastURL, fmt.Errorf("%v（上次错误: %v）", ctx.Err(), lastErr)
+			case <-timer.C:
+			}
+		}
+
+		for _, baseURL := range routingTargets(agent) {
+			resp, err := doHTTPOnce(ctx, HTTPRequest{
+				Method:  method,
+				URL:     strings.TrimSuffix(baseURL, "/") + path,
+				Body:    body,
+				Header:  header,
+				Timeout: cfg.GetTimeout(),
+			})
+			if err == nil && !retryableStatus(resp.StatusCode) {
+				setRoutingHealth(name, baseURL, nil)
+				return resp, baseURL, nil
+			}
+			if err == nil {
+				err = fmt.Errorf("状态码 %d", resp.StatusCode)
+			}
+			if resp == nil || resp.StatusCode >= 500 {
+				setRoutingHealth(name, baseURL, err)
+			}
+			lastResp, lastURL, lastErr = resp, baseURL, err
+			AppLogger.Warning(fmt.Sprintf("转发到agent %s 失败: %s%s, 第%d次, 错误: %v", name, baseURL, path, attempt+1, err))
+			if ctx.Err() != nil {
+				return lastResp, lastURL, lastErr
+			}
+		}
+	}
+	if lastResp != nil {
+		return lastResp, lastURL, nil
+	}
+	return nil, lastURL, lastErr
+}
+
+// GetRoutingStatus 获取各目标agent地址的健康状态
+func GetRoutingStatus() []RoutingTargetStatus {
+	cfg := config.AppConfig.Routing
+	routingMu.Lock()
+	defer routingMu.Unlock()
+
+	var result []RoutingTargetStatus
+	for name, agent := range cfg.Agents {
+		for _, baseURL := range agent.URLs {
+			status := RoutingTargetStatus{Agent: name, URL: baseURL, Healthy: true}
+			if state, ok := routingStates[baseURL]; ok {
+				status.Healthy = state.healthy
+				status.CheckedAt = state.checkedAt.Format("2006-01-02 15:04:05")
+				status.Error = state.err
+			}
+			result = append(result, status)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Agent != result[j].Agent {
+			return result[i].Agent < result[j].Agent
+		}
+		return result[i].URL < result[j].URL
+	})
+	return result
+}
@@ -0,0 +1,139 @@
+package common
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"cicd-agent/config"
+)
+
+// certReloader 证书热加载器，证书文件被轮换后自动加载新证书
+type certReloader struct {
+	certFile string
+	keyFile  string
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	modTime  time.Time
+}
+
+// newCertReloader 加载证书并按间隔检查更新
+func newCertReloader(certFile, keyFile string, interval time.Duration) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	if interval > 0 {
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for range ticker.C {
+				r.reloadIfChanged()
+			}
+		}()
+	}
+	return r, nil
+}
+
+// reload 重新读取证书和私钥
+func (r *certReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("加载TLS证书失败: %v", err)
+	}
+	modTime := r.latestModTime()
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.modTime = modTime
+	r.mu.Unlock()
+	return nil
+}
+
+// reloadIfChanged 证书或私钥文件修改时间变化时重新加载
+func (r *certReloader) reloadIfChanged() {
+	modTime := r.latestModTime()
+
+	r.mu.RLock()
+	changed := modTime.After(r.modTime)
+	r.mu.RUnlock()
+	if !changed {
+		return
+	}
+
+	if err := r.reload(); err != nil {
+		// 新证书可能尚未写完整，保留旧证书，下次检查时重试
+		AppLogger.Warning("TLS证书已更新但加载失败，继续使用旧证书:", err)
+		return
+	}
+	AppLogger.Info("TLS证书已重新加载:", r.certFile)
+}
+
+// latestModTime 获取证书和私钥中较新的修改时间
+func (r *certReloader) latestModTime() time.Time {
+	var latest time.Time
+	for _, path := range []string{r.certFile, r.keyFile} {
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+// getCertificate 供tls.Config使用
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// NewHTTPServer 创建HTTP服务，配置了证书时启用TLS
+func NewHTTPServer(addr string, handler http.Handler) (*http.Server, error) {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	tlsConfig := config.AppConfig.Server.TLS
+	if !tlsConfig.Enabled() {
+		return server, nil
+	}
+
+	reloader, err := newCertReloader(tlsConfig.CertFile, tlsConfig.KeyFile, config.AppConfig.GetTLSReloadInterval())
+	if err != nil {
+		return nil, err
+	}
+	server.TLSConfig = &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.getCertificate,
+	}
+	if err := configureClientAuth(server.TLSConfig); err != nil {
+		return nil, err
+	}
+	return server, nil
+}
+
+// RunHTTPServer 启动HTTP服务（启用TLS时使用HTTPS），端口监听成功后通知systemd就绪检查
+func RunHTTPServer(server *http.Server) error {
+	addr := server.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	NotifyReadyWhenHealthy(server.Handler)
+
+	if server.TLSConfig != nil {
+		// 证书由TLSConfig.GetCertificate提供
+		return server.ServeTLS(listener, "", "")
+	}
+	return server.Serve(listener)
+}
@@ -0,0 +1,131 @@
+package common
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"cicd-agent/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// SignatureHeader 请求签名头，格式为 sha256=<十六进制签名>
+	SignatureHeader = "X-Signature"
+	// SignatureTimestampHeader 签名时间戳头（Unix秒）
+	SignatureTimestampHeader = "X-Signature-Timestamp"
+	// SignatureKeyHeader 签名密钥ID头，未传递时使用default
+	SignatureKeyHeader = "X-Signature-Key"
+	// SignatureNonceHeader 请求nonce头（可选，开启replay.require_nonce后必须携带），参与签名计算
+	SignatureNonceHeader = "X-Signature-Nonce"
+)
+
+// ComputeSignature 计算请求签名
+// 签名内容为 "时间戳.请求体"，携带nonce时为 "时间戳.nonce.请求体"
+func ComputeSignature(secret, timestamp, nonce string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	if nonce != "" {
+		mac.Write([]byte(nonce))
+		mac.Write([]byte("."))
+	}
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// CallbackSignatureMiddleware 回调签名校验中间件（callback.signature.enable开启时生效）
+// 校验签名、时间戳偏差，并拒绝有效期内重复的请求
+func CallbackSignatureMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !config.AppConfig.Callback.Signature.Enable {
+			c.Next()
+			return
+		}
+
+		keyID := c.GetHeader(SignatureKeyHeader)
+		secret, ok := config.AppConfig.GetCallbackSecret(keyID)
+		if !ok {
+			rejectSignature(c, "未知的签名密钥ID: "+keyID)
+			return
+		}
+
+		signature := strings.TrimPrefix(c.GetHeader(SignatureHeader), "sha256=")
+		timestamp := c.GetHeader(SignatureTimestampHeader)
+		if signature == "" || timestamp == "" {
+			rejectSignature(c, "缺少签名或时间戳")
+			return
+		}
+
+		unixSeconds, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			rejectSignature(c, "时间戳格式错误: "+timestamp)
+			return
+		}
+		tolerance := config.AppConfig.GetCallbackSignatureTolerance()
+		signedAt := time.Unix(unixSeconds, 0)
+		if skew := time.Since(signedAt); skew > tolerance || skew < -tolerance {
+			rejectSignature(c, "签名时间戳超出允许范围")
+			return
+		}
+
+		// 签名校验通过前限制读取的请求体大小，避免未认证的调用方让agent缓存任意大的请求体
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, config.AppConfig.GetCallbackMaxBodySize()))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				RequestLogger(c).Warning("回调请求体超出上限:", tooLarge.Limit, " IP:", getClientIP(c))
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+					"code": 413,
+					"msg":  "请求体过大",
+				})
+				c.Abort()
+				return
+			}
+			rejectSignature(c, "读取请求体失败")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		nonce := c.GetHeader(SignatureNonceHeader)
+		expected := ComputeSignature(secret, timestamp, nonce, body)
+		if !hmac.Equal([]byte(strings.ToLower(signature)), []byte(expected)) {
+			rejectSignature(c, "签名不匹配")
+			return
+		}
+
+		// 未携带nonce时以签名本身作为nonce，同一请求在有效期内只能使用一次
+		replayNonce := nonce
+		if replayNonce == "" {
+			if config.AppConfig.Replay.RequireNonce {
+				rejectSignature(c, "缺少nonce")
+				return
+			}
+			replayNonce = expected
+		}
+		if err := verifyReplay("callback", replayNonce, unixSeconds, tolerance); err != nil {
+			rejectSignature(c, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// rejectSignature 拒绝签名校验失败的请求
+func rejectSignature(c *gin.Context, reason string) {
+	RequestLogger(c).Warning("回调签名校验失败:", reason, " IP:", getClientIP(c))
+	c.JSON(http.StatusUnauthorized, gin.H{
+		"code": 401,
+		"msg":  "签名校验失败",
+	})
+	c.Abort()
+}
@@ -0,0 +1,167 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"cicd-agent/config"
+)
+
+// slackPostMessageURL Slack Web API发送消息地址（配置bot_token时使用）
+const slackPostMessageURL = "https://slack.com/api/chat.postMessage"
+
+// SlackMessage Slack消息结构（Block Kit）
+type SlackMessage struct {
+	Channel     string            `json:"channel,omitempty"`
+	Text        string            `json:"text"` // 通知栏预览文字
+	Attachments []SlackAttachment `json:"attachments,omitempty"`
+}
+
+// SlackAttachment 带颜色条的附件，内部为Block Kit块
+type SlackAttachment struct {
+	Color  string       `json:"color"`
+	Blocks []SlackBlock `json:"blocks"`
+}
+
+// SlackBlock Block Kit块
+type SlackBlock struct {
+	Type     string      `json:"type"`
+	Text     *SlackText  `json:"text,omitempty"`
+	Fields   []SlackText `json:"fields,omitempty"`
+	Elements []SlackText `json:"elements,omitempty"`
+}
+
+// SlackText 文本对象
+type SlackText struct {
+	Type string `json:"type"` // plain_text/mrkdwn
+	Text string `json:"text"`
+}
+
+// slackAPIResponse Slack Web API响应
+type slackAPIResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error"`
+}
+
+// SendSlackMessage 发送Slack任务通知（项目未启用Slack渠道时直接返回）
+func SendSlackMessage(ctx context.Context, project, tag, status, startTime, endTime, deployType, category, projectName string) error {
+	slack := config.AppConfig.Notification.Slack
+	if (status == "running" || status == "queued") && !slack.NotifyStart {
+		return nil
+	}
+
+	webhookURL := chatChannelWebhook(slack.ChatChannelConfig, project, status)
+	useBot := slack.BotToken != ""
+	if useBot {
+		// Bot方式按项目频道发送，只要求项目启用了Slack渠道
+		if _, ok := slack.Projects[project]; !ok {
+			if _, ok := slack.Projects["*"]; !ok {
+				return nil
+			}
+		}
+		webhookURL = slackPostMessageURL
+	}
+	if webhookURL == "" {
+		return nil
+	}
+	if err := ValidateOutboundURL(webhookURL); err != nil {
+		return fmt.Errorf("Slack通知地址校验失败: %v", err)
+	}
+
+	summary := buildTaskSummary(project, tag, status, startTime, endTime, deployType, category, projectName)
+	message := buildSlackMessage(summary, status)
+	if useBot {
+		message.Channel = slack.ChannelFor(project)
+		if slack.IncidentChannel != "" && EventSeverity(status) == SeverityCritical {
+			message.Channel = slack.IncidentChannel
+		}
+		if message.Channel == "" {
+			return fmt.Errorf("项目 %s 未配置Slack频道", project)
+		}
+	}
+
+	jsonData, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("序列化Slack消息失败: %v", err)
+	}
+
+	header := map[string]string{"Content-Type": "application/json; charset=utf-8"}
+	if useBot {
+		header["Authorization"] = "Bearer " + slack.BotToken
+	}
+
+	resp, err := DoHTTP(ctx, HTTPRequest{
+		Method: http.MethodPost,
+		URL:    webhookURL,
+		Body:   jsonData,
+		Header: header,
+	})
+	if err != nil {
+		return fmt.Errorf("发送Slack通知失败: %v", err)
+	}
+
+	respBody := resp.Body
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Slack通知响应异常，状态码: %d, 内容: %s", resp.StatusCode, string(respBody))
+	}
+	// Web API的业务错误同样返回200（Incoming Webhook成功时返回纯文本ok）
+	if useBot {
+		var result slackAPIResponse
+		if err := json.Unmarshal(respBody, &result); err == nil && !result.OK {
+			return fmt.Errorf("Slack通知返回错误: %s", result.Error)
+		}
+	}
+
+	AppLogger.Info(fmt.Sprintf("Slack通知发送成功: 项目=%s, 状态=%s", project, status))
+	return nil
+}
+
+// buildSlackMessage 构建Slack Block Kit消息
+func buildSlackMessage(summary taskCardSummary, status string) SlackMessage {
+	fields := make([]SlackText, 0, len(summary.Fields))
+	for _, field := range summary.Fields {
+		fields = append(fields, SlackText{Type: "mrkdwn", Text: fmt.Sprintf("*%s*\n%s", field.Label, escapeSlackText(field.Value))})
+	}
+
+	timeline := fmt.Sprintf("开始时间: %s", summary.StartTime)
+	if summary.EndTime != "" {
+		timeline += fmt.Sprintf("  |  结束时间: %s", summary.EndTime)
+	}
+
+	return SlackMessage{
+		Text: summary.Title,
+		Attachments: []SlackAttachment{
+			{
+				Color: slackStatusColor(status),
+				Blocks: []SlackBlock{
+					{Type: "header", Text: &SlackText{Type: "plain_text", Text: summary.Title}},
+					{Type: "section", Fields: fields},
+					{Type: "context", Elements: []SlackText{{Type: "mrkdwn", Text: timeline}}},
+				},
+			},
+		},
+	}
+}
+
+// escapeSlackText 转义Slack mrkdwn中的控制字符
+func escapeSlackText(text string) string {
+	replacer := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	return replacer.Replace(text)
+}
+
+// slackStatusColor 附件颜色条
+func slackStatusColor(status string) string {
+	switch status {
+	case "complete":
+		return "#2eb886"
+	case "failed":
+		return "#e01e5a"
+	case "running":
+		return "#1d9bd1"
+	default:
+		return "#9e9e9e"
+	}
+}
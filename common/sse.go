@@ -0,0 +1,282 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	sseHeartbeatInterval = 15 * time.Second       // 心跳注释发送间隔
+	ssePollInterval      = 500 * time.Millisecond // fsnotify不可用时的轮询间隔
+	sseMaxInitialLines   = 1000                   // 首次连接最多发送的行数
+)
+
+// sseLogStream SSE日志流
+type sseLogStream struct {
+	w           gin.ResponseWriter
+	flusher     http.Flusher
+	logFilePath string
+	stepType    string
+	format      string // 输出格式: text/json
+	pos         int64  // 已发送内容在日志文件中的偏移量，同时作为事件ID
+	resumeToken string // 续传令牌，随事件ID下发，重连时凭它跳过nonce校验
+}
+
+// TaskLogSSE 任务日志SSE处理函数（参数与WebSocket接口一致）
+// 客户端示例：
+// const es = new EventSource(`http://agent地址/sse/task/logs?data=加密参数`);
+// es.onmessage = function(event) { console.log(event.data); };
+// 断线重连时浏览器会自动携带Last-Event-ID（偏移量.续传令牌），服务端校验令牌后从该偏移量继续推送
+// 参数format=json时，每个事件的data为一个LogFrame对象：{ts, level, step, line}
+func TaskLogSSE(c *gin.Context) {
+	// 解析断点续传位置（支持请求头和查询参数两种方式）
+	resumeFrom, resumeToken := int64(-1), ""
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("lastEventId")
+	}
+	if lastEventID != "" {
+		posPart, token, _ := strings.Cut(lastEventID, ".")
+		if pos, err := strconv.ParseInt(posPart, 10, 64); err == nil && pos >= 0 {
+			resumeFrom, resumeToken = pos, token
+		}
+	}
+
+	params, ok := decodeTaskLogParams(c, resumeToken)
+	if !ok {
+		return
+	}
+
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "当前连接不支持流式输出"})
+		return
+	}
+
+	// 与WebSocket共用连接数上限
+	if !acquireLogConn(params.TaskID) {
+		AppLogger.Warning(fmt.Sprintf("日志连接数已达上限，拒绝连接: 任务=%s", params.TaskID))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "日志连接数已达上限"})
+		return
+	}
+	defer releaseLogConn(params.TaskID)
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no") // 禁止nginx缓冲
+	c.Status(http.StatusOK)
+
+	s := &sseLogStream{
+		w:           c.Writer,
+		flusher:     flusher,
+		logFilePath: buildLogFilePath(params.TaskID, params.StepType),
+		stepType:    params.StepType,
+		format:      params.Format,
+		resumeToken: newLogResumeToken(params.Nonce),
+	}
+
+	// 告知客户端重连间隔
+	fmt.Fprint(s.w, "retry: 3000\n\n")
+
+	if resumeFrom >= 0 {
+		s.pos = resumeFrom
+		if err := s.sendNew(); err != nil {
+			return
+		}
+	} else if err := s.sendInitial(); err != nil {
+		return
+	}
+	// 没有日志时也下发事件ID，保证客户端重连时携带续传令牌
+	if _, err := fmt.Fprint(s.w, "id: "+s.eventID()+"\n\n"); err != nil {
+		return
+	}
+	s.flusher.Flush()
+
+	// 订阅日志目录变更，失败时回退到轮询
+	taskDir := filepath.Dir(s.logFilePath)
+	notify, unsubscribe, err := subscribeLogDir(taskDir)
+	if err == nil {
+		defer unsubscribe()
+	}
+	var pollC <-chan time.Time
+	if notify == nil {
+		pollTicker := time.NewTicker(ssePollInterval)
+		defer pollTicker.Stop()
+		pollC = pollTicker.C
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	clientGone := c.Request.Context().Done()
+	for {
+		var sendErr error
+		select {
+		case <-clientGone:
+			return
+		case <-notify:
+			sendErr = s.sendNew()
+		case <-pollC:
+			sendErr = s.sendNew()
+		case <-heartbeat.C:
+			_, sendErr = fmt.Fprint(s.w, ": heartbeat\n\n")
+		}
+		if sendErr != nil {
+			return
+		}
+		s.flusher.Flush()
+	}
+}
+
+// sendInitial 首次连接时发送已有日志（超出限制时只发送最后的部分）
+func (s *sseLogStream) sendInitial() error {
+	if !taskLogFileExists(s.logFilePath) {
+		return s.writeSystemEvent("日志文件不存在或尚未生成")
+	}
+
+	// 已压缩的历史日志（任务结束后压缩）
+	var lines []string
+	if compressed, err := readCompressedLog(s.logFilePath); err == nil {
+		for _, line := range splitLines(string(compressed)) {
+			if strings.TrimSpace(line) != "" {
+				lines = append(lines, line)
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		AppLogger.Warning(fmt.Sprintf("读取压缩日志文件失败: %v", err))
+	}
+
+	plainLines, newPos, err := readCompleteLines(s.logFilePath, 0)
+	if err != nil && !os.IsNotExist(err) {
+		AppLogger.Warning(fmt.Sprintf("读取日志文件失败: %v", err))
+		return nil
+	}
+	lines = append(lines, plainLines...)
+	s.pos = newPos
+
+	if len(lines) > sseMaxInitialLines {
+		prefix := fmt.Sprintf("[日志过长，仅显示最后%d行，总共%d行]", sseMaxInitialLines, len(lines))
+		if err := s.writeSystemEvent(prefix); err != nil {
+			return err
+		}
+		lines = lines[len(lines)-sseMaxInitialLines:]
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return s.writeEvent(s.eventID(), lines)
+}
+
+// sendNew 发送自上次偏移量以来新增的日志
+func (s *sseLogStream) sendNew() error {
+	lines, newPos, err := readCompleteLines(s.logFilePath, s.pos)
+	if err != nil {
+		// 日志文件不存在时静默等待
+		return nil
+	}
+	s.pos = newPos
+	if len(lines) == 0 {
+		return nil
+	}
+	return s.writeEvent(s.eventID(), lines)
+}
+
+// eventID 事件ID：当前偏移量和续传令牌
+func (s *sseLogStream) eventID() string {
+	return strconv.FormatInt(s.pos, 10) + "." + s.resumeToken
+}
+
+// writeEvent 写入SSE事件
+// 文本模式下多行日志作为同一事件的多个data字段；JSON模式下每行一个事件，事件ID只写在最后一个
+func (s *sseLogStream) writeEvent(id string, lines []string) error {
+	var buf bytes.Buffer
+	if s.format == logFormatJSON {
+		for i, line := range lines {
+			if i == len(lines)-1 && id != "" {
+				buf.WriteString("id: " + id + "\n")
+			}
+			data, _ := json.Marshal(parseLogLine(s.stepType, line))
+			buf.WriteString("data: " + string(data) + "\n\n")
+		}
+		_, err := s.w.Write(buf.Bytes())
+		return err
+	}
+
+	if id != "" {
+		buf.WriteString("id: " + id + "\n")
+	}
+	for _, line := range lines {
+		buf.WriteString("data: " + line + "\n")
+	}
+	buf.WriteString("\n")
+	_, err := s.w.Write(buf.Bytes())
+	return err
+}
+
+// writeSystemEvent 写入服务端提示信息事件（不携带事件ID）
+func (s *sseLogStream) writeSystemEvent(message string) error {
+	if s.format == logFormatJSON {
+		data, _ := json.Marshal(systemLogFrame(s.stepType, message))
+		_, err := fmt.Fprintf(s.w, "data: %s\n\n", data)
+		return err
+	}
+	_, err := fmt.Fprintf(s.w, "data: %s\n\n", message)
+	return err
+}
+
+// readCompleteLines 从指定偏移量读取完整的日志行（不含末尾未写完的半行）
+// 返回读取到的非空行以及新的偏移量；文件被截断时从头开始读取
+func readCompleteLines(path string, offset int64) ([]string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, offset, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, offset, err
+	}
+	if info.Size() < offset {
+		offset = 0
+	}
+	if info.Size() == offset {
+		return nil, offset, nil
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, offset, err
+	}
+	data, err := io.ReadAll(io.LimitReader(file, info.Size()-offset))
+	if err != nil {
+		return nil, offset, err
+	}
+
+	// 只消费到最后一个换行符，半行留待下次读取
+	lastNewline := bytes.LastIndexByte(data, '\n')
+	if lastNewline < 0 {
+		return nil, offset, nil
+	}
+	data = data[:lastNewline+1]
+
+	var lines []string
+	for _, line := range splitLines(string(data)) {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, offset + int64(len(data)), nil
+}
@@ -1,6 +1,7 @@
 package common
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -120,6 +121,37 @@ func (t *TaskLogger) GetStepWriter(stepType string) (io.Writer, error) {
 	return t.getWriter(stepType)
 }
 
+// StreamCommand 执行命令并将输出逐行实时写入步骤日志（日志查看器无需等待命令结束）
+// label非空时作为每行前缀，便于区分并发执行的同类命令；logger为nil时只执行命令
+func (t *TaskLogger) StreamCommand(ctx context.Context, stepType, label string, cmd Command) error {
+	if t == nil {
+		_, err := RunCommand(ctx, cmd)
+		return err
+	}
+
+	writer, err := t.GetStepWriter(stepType)
+	if err != nil {
+		AppLogger.Error("获取日志写入器失败:", err)
+		_, err := RunCommand(ctx, cmd)
+		return err
+	}
+
+	prefix := ""
+	if label != "" {
+		prefix = "[" + label + "] "
+	}
+	fmt.Fprintf(writer, "%s [COMMAND] %s\n", time.Now().Format("2006/01/02 15:04:05"), cmd.String())
+
+	err = RunCommandStream(ctx, cmd, func(line string) {
+		// 每行一次写入，并发命令的输出按行交错而不会截断
+		fmt.Fprintf(writer, "%s%s\n", prefix, line)
+	})
+	if err != nil {
+		fmt.Fprintf(writer, "%s [ERROR] %sCommand failed: %v\n", time.Now().Format("2006/01/02 15:04:05"), prefix, err)
+	}
+	return err
+}
+
 // WriteConsole 写入控制台日志（同时写入console.log文件）
 func (t *TaskLogger) WriteConsole(level, message string) {
 	if t == nil {
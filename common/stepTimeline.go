@@ -0,0 +1,103 @@
+package common
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// stepTimelineFile 任务日志目录下保存步骤时间线的文件名
+const stepTimelineFile = "timeline.json"
+
+// StepRecord 步骤时间线中的一个步骤（按首次通知的顺序排列）
+type StepRecord struct {
+	Step       int    `json:"step"`
+	Type       string `json:"type"`
+	Name       string `json:"name"`
+	Status     string `json:"status"` // start/success/failed/cancel/skipped/paused
+	Message    string `json:"message,omitempty"`
+	StartedAt  string `json:"started_at,omitempty"`
+	FinishedAt string `json:"finished_at,omitempty"`
+}
+
+// stepTimelineMu 串行化时间线文件的读写
+var stepTimelineMu sync.Mutex
+
+// recordStepTimeline 按步骤通知更新任务的步骤时间线，写入 logs/{任务ID}/timeline.json
+func recordStepTimeline(taskID string, step int, stepType, stepName, status, message string) {
+	if taskID == "" {
+		return
+	}
+	stepTimelineMu.Lock()
+	defer stepTimelineMu.Unlock()
+
+	timeline, _ := readStepTimeline(taskID)
+	now := time.Now().Format("2006-01-02 15:04:05")
+	index := -1
+	for i := range timeline {
+		if timeline[i].Type == stepType {
+			index = i
+		}
+	}
+	if index < 0 || (status == "start" && timeline[index].FinishedAt != "") {
+		// 首次出现或单独重新执行的步骤追加一条记录
+		timeline = append(timeline, StepRecord{Step: step, Type: stepType, Name: stepName})
+		index = len(timeline) - 1
+	}
+
+	record := &timeline[index]
+	record.Status, record.Message = status, message
+	switch status {
+	case "start":
+		if record.StartedAt == "" {
+			record.StartedAt = now
+		}
+	case "paused":
+	default:
+		record.FinishedAt = now
+	}
+
+	data, err := json.Marshal(timeline)
+	if err != nil {
+		AppLogger.Warning("序列化步骤时间线失败:", err)
+		return
+	}
+	logDir := filepath.Join("logs", taskID)
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		AppLogger.Warning("创建任务日志目录失败:", err)
+		return
+	}
+	path := filepath.Join(logDir, stepTimelineFile)
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		AppLogger.Warning("写入步骤时间线失败:", err)
+		return
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		AppLogger.Warning("写入步骤时间线失败:", err)
+	}
+}
+
+// ReadStepTimeline 读取任务的步骤时间线，没有记录时返回空
+func ReadStepTimeline(taskID string) ([]StepRecord, error) {
+	stepTimelineMu.Lock()
+	defer stepTimelineMu.Unlock()
+	return readStepTimeline(taskID)
+}
+
+// readStepTimeline 读取时间线文件（调用方持有锁）
+func readStepTimeline(taskID string) ([]StepRecord, error) {
+	data, err := os.ReadFile(filepath.Join("logs", taskID, stepTimelineFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var timeline []StepRecord
+	if err := json.Unmarshal(data, &timeline); err != nil {
+		return nil, err
+	}
+	return timeline, nil
+}
@@ -0,0 +1,99 @@
+package common
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// stepTimerFile 任务日志目录下保存步骤开始时间的文件名
+const stepTimerFile = "step_timers.json"
+
+// stepTimerStore 步骤开始时间：任务ID -> 步骤键 -> 开始时间
+// 按任务隔离，避免并发任务的同名步骤互相覆盖；每次变更写入任务日志目录，agent重启后仍可计算耗时
+type stepTimerStore struct {
+	mu     sync.Mutex
+	timers map[string]map[string]time.Time
+}
+
+var stepStartTimes = &stepTimerStore{timers: make(map[string]map[string]time.Time)}
+
+// start 记录步骤开始时间，已存在时保留原时间
+func (s *stepTimerStore) start(taskID, stepKey string, at time.Time) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	timers := s.load(taskID)
+	if existing, ok := timers[stepKey]; ok {
+		return existing
+	}
+	timers[stepKey] = at
+	s.persist(taskID, timers)
+	return at
+}
+
+// finish 取出并删除步骤开始时间
+func (s *stepTimerStore) finish(taskID, stepKey string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	timers := s.load(taskID)
+	startTime, ok := timers[stepKey]
+	if ok {
+		delete(timers, stepKey)
+		s.persist(taskID, timers)
+	}
+	return startTime, ok
+}
+
+// clear 任务结束时删除全部记录
+func (s *stepTimerStore) clear(taskID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.timers, taskID)
+	if err := os.Remove(filepath.Join("logs", taskID, stepTimerFile)); err != nil && !os.IsNotExist(err) {
+		AppLogger.Warning("删除步骤计时记录失败:", err)
+	}
+}
+
+// load 获取任务的计时记录，内存中没有时从磁盘加载（调用方持有锁）
+func (s *stepTimerStore) load(taskID string) map[string]time.Time {
+	if timers, ok := s.timers[taskID]; ok {
+		return timers
+	}
+
+	timers := make(map[string]time.Time)
+	if data, err := os.ReadFile(filepath.Join("logs", taskID, stepTimerFile)); err == nil {
+		if err := json.Unmarshal(data, &timers); err != nil {
+			AppLogger.Warning("解析步骤计时记录失败:", err)
+			timers = make(map[string]time.Time)
+		}
+	}
+	s.timers[taskID] = timers
+	return timers
+}
+
+// persist 写入磁盘（调用方持有锁）
+func (s *stepTimerStore) persist(taskID string, timers map[string]time.Time) {
+	logDir := filepath.Join("logs", taskID)
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		AppLogger.Warning("创建任务日志目录失败:", err)
+		return
+	}
+	data, err := json.Marshal(timers)
+	if err != nil {
+		AppLogger.Warning("序列化步骤计时记录失败:", err)
+		return
+	}
+	path := filepath.Join(logDir, stepTimerFile)
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		AppLogger.Warning("写入步骤计时记录失败:", err)
+		return
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		AppLogger.Warning("写入步骤计时记录失败:", err)
+	}
+}
@@ -0,0 +1,141 @@
+package common
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// sdReady 是否已通知systemd服务就绪（READY=1）
+var sdReady atomic.Bool
+
+// SdNotify 向systemd发送状态通知（sd_notify协议），未在systemd下运行（没有NOTIFY_SOCKET）时返回false
+func SdNotify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:] // 抽象命名空间
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, fmt.Errorf("连接systemd通知socket失败: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, fmt.Errorf("发送systemd通知失败: %v", err)
+	}
+	return true, nil
+}
+
+// sdNotifyLogged 发送systemd通知，失败时记录警告
+func sdNotifyLogged(state string) {
+	if _, err := SdNotify(state); err != nil {
+		AppLogger.Warning(err)
+	}
+}
+
+// sdWatchdogInterval systemd要求的看门狗间隔（WATCHDOG_USEC），未启用或不是发给本进程时返回0
+func sdWatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// NotifyReadyWhenHealthy 服务开始监听后调用：依赖检查（见CheckReadiness）通过后通知systemd READY=1，
+// 未通过时在STATUS中说明原因并定期重试；同时按WATCHDOG_USEC启动看门狗
+func NotifyReadyWhenHealthy(handler http.Handler) {
+	startSdWatchdog(handler)
+	if os.Getenv("NOTIFY_SOCKET") == "" {
+		return
+	}
+
+	go func() {
+		for {
+			err := CheckReadiness()
+			if err == nil {
+				sdNotifyLogged("READY=1\nSTATUS=服务运行中")
+				sdReady.Store(true)
+				AppLogger.Info("已通知systemd服务就绪")
+				return
+			}
+			AppLogger.Warning("就绪检查未通过，稍后重试:", err)
+			sdNotifyLogged("STATUS=等待就绪: " + err.Error())
+			time.Sleep(10 * time.Second)
+		}
+	}()
+}
+
+// NotifyReloading 通知systemd正在重新加载配置（Type=notify-reload要求同时发送MONOTONIC_USEC），done在加载完成后调用
+// 服务尚未就绪时done不发送READY=1，就绪通知仍由NotifyReadyWhenHealthy在依赖检查通过后发送
+func NotifyReloading() (done func()) {
+	state := "RELOADING=1\nSTATUS=重新加载配置"
+	if usec, ok := monotonicUsec(); ok {
+		state += "\nMONOTONIC_USEC=" + strconv.FormatInt(usec, 10)
+	}
+	sdNotifyLogged(state)
+	return func() {
+		if sdReady.Load() {
+			sdNotifyLogged("READY=1\nSTATUS=服务运行中")
+		} else {
+			sdNotifyLogged("STATUS=等待就绪")
+		}
+	}
+}
+
+// startSdWatchdog 按看门狗间隔的一半在进程内请求/health，处理正常时才发送WATCHDOG=1；
+// 服务卡死（请求无法在间隔内完成）时停止喂狗，由systemd重启agent
+func startSdWatchdog(handler http.Handler) {
+	interval := sdWatchdogInterval()
+	if interval == 0 {
+		return
+	}
+	period := interval / 2
+	AppLogger.Info(fmt.Sprintf("systemd看门狗已启用: 超时=%s, 检查间隔=%s", interval, period))
+
+	go func() {
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := probeHealth(handler, period); err != nil {
+				AppLogger.Error("看门狗健康检查失败，停止通知systemd:", err)
+				continue
+			}
+			sdNotifyLogged("WATCHDOG=1")
+		}
+	}()
+}
+
+// probeHealth 在进程内通过路由处理一次/health请求（不经过网络，避免受TLS和白名单配置影响）
+func probeHealth(handler http.Handler, timeout time.Duration) error {
+	result := make(chan int, 1)
+	go func() {
+		req := httptest.NewRequest(http.MethodGet, "/health", nil)
+		req.RemoteAddr = "127.0.0.1:0"
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		result <- recorder.Code
+	}()
+
+	select {
+	case code := <-result:
+		if code != http.StatusOK {
+			return fmt.Errorf("/health返回状态码 %d", code)
+		}
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("/health在%s内未响应", timeout)
+	}
+}
@@ -0,0 +1,12 @@
+package common
+
+import "golang.org/x/sys/unix"
+
+// monotonicUsec CLOCK_MONOTONIC的当前值（微秒），用于RELOADING=1通知的MONOTONIC_USEC
+func monotonicUsec() (int64, bool) {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts); err != nil {
+		return 0, false
+	}
+	return ts.Nano() / 1000, true
+}
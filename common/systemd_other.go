@@ -0,0 +1,8 @@
+//go:build !linux
+
+package common
+
+// monotonicUsec 非Linux系统不使用systemd，不提供MONOTONIC_USEC
+func monotonicUsec() (int64, bool) {
+	return 0, false
+}
@@ -0,0 +1,102 @@
+package common
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// defaultStepEstimate 没有历史耗时的步骤按30秒估算
+const defaultStepEstimate = 30.0
+
+// PipelineStep 流水线中的步骤（编号和类型与步骤通知一致）
+type PipelineStep struct {
+	Step    int
+	Type    string
+	Skipped bool // 项目配置跳过，不计入预计耗时
+}
+
+// taskProgress 任务执行进度
+type taskProgress struct {
+	mu        sync.Mutex
+	steps     []PipelineStep
+	completed map[string]bool // 已完成的步骤类型
+}
+
+// taskProgresses 任务ID -> 执行进度
+var taskProgresses sync.Map
+
+// StartTaskProgress 登记任务的步骤计划，步骤通知据此计算整体进度
+func StartTaskProgress(taskID string, steps []PipelineStep) {
+	taskProgresses.Store(taskID, &taskProgress{steps: steps, completed: make(map[string]bool)})
+}
+
+// FinishTaskProgress 任务结束时清理进度记录
+func FinishTaskProgress(taskID string) {
+	taskProgresses.Delete(taskID)
+}
+
+// stepProgress 记录步骤状态，返回总步骤数和已完成步骤数（未登记步骤计划的任务返回0, 0）
+func stepProgress(taskID, stepType, status string) (int, int) {
+	value, ok := taskProgresses.Load(taskID)
+	if !ok {
+		return 0, 0
+	}
+	progress := value.(*taskProgress)
+
+	progress.mu.Lock()
+	defer progress.mu.Unlock()
+	if status == "success" || status == "skipped" {
+		progress.completed[stepType] = true
+	}
+	return len(progress.steps), len(progress.completed)
+}
+
+// progressPercent 计算完成百分比（保留2位小数）
+func progressPercent(total, completed int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(completed)/float64(total)*10000) / 100
+}
+
+// estimateTaskEnd 估算整个任务的结束时间：当前步骤（执行中时）与后续未完成步骤的历史耗时之和
+// 步骤失败或取消时任务即将结束，返回空
+func estimateTaskEnd(taskID, project, stepType, status string) string {
+	if status == "failed" || status == "cancel" {
+		return ""
+	}
+	value, ok := taskProgresses.Load(taskID)
+	if !ok {
+		return ""
+	}
+	progress := value.(*taskProgress)
+
+	progress.mu.Lock()
+	var remaining []PipelineStep
+	for _, step := range progress.steps {
+		if progress.completed[step.Type] || step.Skipped {
+			continue
+		}
+		if step.Type == stepType && status != "start" {
+			continue
+		}
+		remaining = append(remaining, step)
+	}
+	progress.mu.Unlock()
+
+	var seconds float64
+	for _, step := range remaining {
+		seconds += estimateStepDuration(project, fmt.Sprintf("step_%d_%s", step.Step, step.Type))
+	}
+	return time.Now().Add(time.Duration(seconds * float64(time.Second))).Format("2006-01-02 15:04:05")
+}
+
+// estimateStepDuration 步骤预计耗时（秒），没有历史数据时按默认值估算
+func estimateStepDuration(project, stepKey string) float64 {
+	if duration := getLastStepDuration(project, stepKey); duration > 0 {
+		return duration
+	}
+	return defaultStepEstimate
+}
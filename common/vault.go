@@ -0,0 +1,55 @@
+package common
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"cicd-agent/config"
+)
+
+var (
+	vaultMu   sync.Mutex
+	vaultStop chan struct{}
+)
+
+// StartVaultWatcher 按vault.refresh_interval定期检查配置引用的Vault密钥，发生轮换时调用onRotate重新加载配置
+// （重新加载配置后需再次调用）
+func StartVaultWatcher(onRotate func()) {
+	vaultMu.Lock()
+	if vaultStop != nil {
+		close(vaultStop)
+		vaultStop = nil
+	}
+	cfg := config.AppConfig.Vault
+	if !cfg.Enable {
+		vaultMu.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	vaultStop = stop
+	vaultMu.Unlock()
+
+	interval := cfg.GetRefreshInterval()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				rotated, err := config.VaultSecretsRotated()
+				if err != nil {
+					AppLogger.Warning("检查Vault密钥轮换失败:", err)
+					continue
+				}
+				if rotated {
+					AppLogger.Info("Vault密钥已轮换，重新加载配置")
+					onRotate()
+				}
+			}
+		}
+	}()
+	AppLogger.Info(fmt.Sprintf("Vault密钥轮换检查已启用: 间隔=%s", interval))
+}
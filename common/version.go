@@ -9,14 +9,48 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 )
 
 // VersionInfo 版本信息结构
 type VersionInfo struct {
-	CurrentVersion string                 `json:"current_version"` // v1 或 v2
-	LastUpdated    string                 `json:"last_updated"`    // 最后更新时间
-	StepDurations  map[string]interface{} `json:"step_durations"`  // 上次各步骤执行时间
+	CurrentVersion string                 `json:"current_version"`        // v1 或 v2
+	LastUpdated    string                 `json:"last_updated"`           // 最后更新时间
+	StepDurations  map[string]interface{} `json:"step_durations"`         // 上次各步骤执行时间
+	StepHistory    map[string][]float64   `json:"step_history,omitempty"` // 各步骤最近若干次执行时间（秒），用于截尾移动平均
+
+	PreviousVersion string          `json:"previous_version,omitempty"` // 上一次切换前承载流量的版本，回滚时切回该版本
+	History         []VersionSwitch `json:"history,omitempty"`          // 流量切换记录，按时间顺序追加，只保留最近versionHistoryLimit次
+}
+
+// VersionSwitch 一次流量切换记录
+type VersionSwitch struct {
+	Version    string `json:"version"`           // 切换到的版本
+	Tag        string `json:"tag,omitempty"`     // 该版本运行的镜像标签
+	TaskID     string `json:"task_id,omitempty"` // 执行切换的任务
+	SwitchedAt string `json:"switched_at"`
+}
+
+// versionHistoryLimit 版本文件中保留的流量切换记录数
+const versionHistoryLimit = 200
+
+// CurrentRelease 当前承载流量的版本对应的切换记录，没有记录时返回nil
+func (v *VersionInfo) CurrentRelease() *VersionSwitch {
+	if len(v.History) == 0 {
+		return nil
+	}
+	return &v.History[len(v.History)-1]
+}
+
+// PreviousRelease 上一次承载流量的版本（previous_version）的最近一次切换记录，没有记录时返回nil
+func (v *VersionInfo) PreviousRelease() *VersionSwitch {
+	for i := len(v.History) - 2; i >= 0; i-- {
+		if v.History[i].Version == v.PreviousVersion {
+			return &v.History[i]
+		}
+	}
+	return nil
 }
 
 // StatusResponse 远程状态接口响应结构
@@ -26,6 +60,9 @@ type StatusResponse struct {
 	CurrentVersion string            `json:"current_version"`
 }
 
+// versionFileLocks 版本文件的进程内锁：版本文件路径 -> 互斥锁
+var versionFileLocks sync.Map
+
 // GetCurrentVersion 读取版本文件，如果不存在则创建默认文件
 func GetCurrentVersion(project string) (*VersionInfo, error) {
 	// 获取项目部署目录
@@ -36,17 +73,38 @@ func GetCurrentVersion(project string) (*VersionInfo, error) {
 
 	currentFile := filepath.Join(deployDir, ".current")
 
-	// 检查文件是否存在
-	if _, err := os.Stat(currentFile); os.IsNotExist(err) {
-		// 文件不存在，创建默认文件
-		return createDefaultVersionFile(project, currentFile)
+	// 文件存在，读取并解析（写入均为原子替换，读取无需加锁）
+	if _, err := os.Stat(currentFile); err == nil {
+		return loadVersionFile(currentFile)
 	}
 
-	// 文件存在，读取并解析
-	return readVersionFile(currentFile)
+	// 文件不存在，加锁后创建默认文件（加锁期间可能已被其他任务创建）
+	unlock, err := lockVersionFile(currentFile)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if _, err := os.Stat(currentFile); err == nil {
+		return loadVersionFile(currentFile)
+	}
+	return createDefaultVersionFile(project, currentFile)
 }
 
-// createDefaultVersionFile 创建默认版本文件
+// ReadVersionInfo 只读取项目的版本文件，文件不存在时返回nil（不创建默认文件），用于查询接口
+func ReadVersionInfo(project string) (*VersionInfo, error) {
+	deployDir, exists := config.AppConfig.GetProjectPath(project)
+	if !exists {
+		return nil, fmt.Errorf("项目 %s 的部署目录未配置", project)
+	}
+
+	currentFile := filepath.Join(deployDir, ".current")
+	if _, err := os.Stat(currentFile); os.IsNotExist(err) {
+		return nil, nil
+	}
+	return loadVersionFile(currentFile)
+}
+
+// createDefaultVersionFile 创建默认版本文件（调用方持有版本文件锁）
 func createDefaultVersionFile(project, filePath string) (*VersionInfo, error) {
 	defaultVersion := &VersionInfo{
 		CurrentVersion: "v1",
@@ -54,14 +112,7 @@ func createDefaultVersionFile(project, filePath string) (*VersionInfo, error) {
 		StepDurations:  make(map[string]interface{}),
 	}
 
-	// 序列化为JSON
-	data, err := json.MarshalIndent(defaultVersion, "", "  ")
-	if err != nil {
-		return nil, fmt.Errorf("序列化默认版本信息失败: %v", err)
-	}
-
-	// 写入文件
-	if err := ioutil.WriteFile(filePath, data, 0644); err != nil {
+	if err := writeVersionFile(filePath, defaultVersion); err != nil {
 		return nil, fmt.Errorf("创建默认版本文件失败: %v", err)
 	}
 
@@ -86,6 +137,80 @@ func readVersionFile(filePath string) (*VersionInfo, error) {
 	return &versionInfo, nil
 }
 
+// loadVersionFile 读取版本文件，文件损坏时使用.bak备份（下次写入时恢复版本文件）
+func loadVersionFile(filePath string) (*VersionInfo, error) {
+	versionInfo, err := readVersionFile(filePath)
+	if err == nil {
+		return versionInfo, nil
+	}
+	backup, backupErr := readVersionFile(filePath + ".bak")
+	if backupErr != nil {
+		return nil, err
+	}
+	AppLogger.Warning(fmt.Sprintf("版本文件 %s 损坏，使用备份: %v", filePath, err))
+	return backup, nil
+}
+
+// lockVersionFile 锁定版本文件（进程内互斥 + flock，防止其他进程同时修改），返回解锁函数
+func lockVersionFile(filePath string) (func(), error) {
+	value, _ := versionFileLocks.LoadOrStore(filePath, &sync.Mutex{})
+	mu := value.(*sync.Mutex)
+	mu.Lock()
+
+	lock, err := os.OpenFile(filePath+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		mu.Unlock()
+		return nil, fmt.Errorf("打开版本锁文件失败: %v", err)
+	}
+	if err := lockFile(lock); err != nil {
+		lock.Close()
+		mu.Unlock()
+		return nil, fmt.Errorf("锁定版本文件失败: %v", err)
+	}
+	return func() {
+		unlockFile(lock)
+		lock.Close()
+		mu.Unlock()
+	}, nil
+}
+
+// writeVersionFile 原子写入版本文件：先写临时文件再重命名替换，并同步写入.bak备份用于损坏恢复
+func writeVersionFile(filePath string, versionInfo *VersionInfo) error {
+	data, err := json.MarshalIndent(versionInfo, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化版本信息失败: %v", err)
+	}
+	if err := writeFileAtomic(filePath+".bak", data); err != nil {
+		return fmt.Errorf("写入版本备份文件失败: %v", err)
+	}
+	return writeFileAtomic(filePath, data)
+}
+
+// writeFileAtomic 写入同目录下的临时文件并落盘后重命名为目标文件，读取方不会读到写了一半的内容
+func writeFileAtomic(filePath string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filePath)
+}
+
 // getRemoteCurrentVersion 从流量代理接口获取当前版本
 func getRemoteCurrentVersion(ctx context.Context, project string) (string, error) {
 	// 检查流量代理是否开启
@@ -114,34 +239,17 @@ func getRemoteCurrentVersion(ctx context.Context, project string) (string, error
 
 // tryGetVersionFromURL 尝试从指定URL获取版本信息
 func tryGetVersionFromURL(ctx context.Context, url string) (string, error) {
-	// 创建带超时的HTTP客户端
-	client := &http.Client{
-		Timeout: 3 * time.Second,
-	}
-
-	// 创建请求
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return "", fmt.Errorf("创建请求失败: %v", err)
-	}
-
-	// 发送请求
-	resp, err := client.Do(req)
+	// 多个代理地址依次尝试，单个地址不重试
+	resp, err := DoHTTP(ctx, HTTPRequest{Method: http.MethodGet, URL: url, Timeout: 3 * time.Second, NoRetry: true})
 	if err != nil {
 		return "", fmt.Errorf("请求失败: %v", err)
 	}
-	defer resp.Body.Close()
 
 	// 检查状态码
 	if resp.StatusCode != http.StatusOK {
 		return "", fmt.Errorf("HTTP状态码错误: %d", resp.StatusCode)
 	}
-
-	// 读取响应体
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("读取响应失败: %v", err)
-	}
+	body := resp.Body
 
 	// 解析JSON
 	var statusResp StatusResponse
@@ -166,7 +274,6 @@ func GetVersion(project string) (string, error) {
 	// 优先尝试从远程接口获取
 	if remoteVersion, err := getRemoteCurrentVersion(ctx, project); err == nil {
 		return remoteVersion, nil
-		AppLogger.Info(fmt.Sprintf("远程获取版本成功: %s", remoteVersion))
 	} else {
 		AppLogger.Info(fmt.Sprintf("远程获取版本失败，回退到本地读取: %v", err))
 	}
@@ -179,61 +286,77 @@ func GetVersion(project string) (string, error) {
 	return versionInfo.CurrentVersion, nil
 }
 
-// UpdateVersion 更新版本字段
-func UpdateVersion(project, newVersion string) error {
-	// 读取当前版本信息
-	versionInfo, err := GetCurrentVersion(project)
-	if err != nil {
-		return fmt.Errorf("读取版本信息失败: %v", err)
-	}
-
-	// 更新版本字段
-	versionInfo.CurrentVersion = newVersion
-	versionInfo.LastUpdated = time.Now().Format("2006-01-02 15:04:05")
-
-	// 保存到文件
-	return saveVersionFile(project, versionInfo)
+// UpdateVersion 流量切换后更新当前版本，记录上一版本和切换记录
+// tag为该版本运行的镜像标签，为空时（如手动切换）沿用该版本上一次切换记录中的标签
+func UpdateVersion(project, newVersion, tag, taskID string) error {
+	return updateVersionInfo(project, func(versionInfo *VersionInfo) {
+		if tag == "" {
+			for i := len(versionInfo.History) - 1; i >= 0; i-- {
+				if versionInfo.History[i].Version == newVersion {
+					tag = versionInfo.History[i].Tag
+					break
+				}
+			}
+		}
+		if versionInfo.CurrentVersion != newVersion {
+			versionInfo.PreviousVersion = versionInfo.CurrentVersion
+		}
+		versionInfo.CurrentVersion = newVersion
+		versionInfo.History = append(versionInfo.History, VersionSwitch{
+			Version:    newVersion,
+			Tag:        tag,
+			TaskID:     taskID,
+			SwitchedAt: time.Now().Format("2006-01-02 15:04:05"),
+		})
+		if len(versionInfo.History) > versionHistoryLimit {
+			versionInfo.History = versionInfo.History[len(versionInfo.History)-versionHistoryLimit:]
+		}
+	})
 }
 
-// saveVersionFile 保存版本信息到文件
-func saveVersionFile(project string, versionInfo *VersionInfo) error {
+// updateVersionInfo 加锁读取-修改-原子写回版本文件，文件不存在时基于默认内容修改
+func updateVersionInfo(project string, update func(versionInfo *VersionInfo)) error {
 	// 获取项目部署目录
 	deployDir, exists := config.AppConfig.GetProjectPath(project)
 	if !exists {
 		return fmt.Errorf("项目 %s 的部署目录未配置", project)
 	}
-
 	currentFile := filepath.Join(deployDir, ".current")
 
-	// 序列化为JSON
-	data, err := json.MarshalIndent(versionInfo, "", "  ")
+	unlock, err := lockVersionFile(currentFile)
 	if err != nil {
-		return fmt.Errorf("序列化版本信息失败: %v", err)
+		return err
 	}
+	defer unlock()
 
-	// 写入文件
-	if err := ioutil.WriteFile(currentFile, data, 0644); err != nil {
-		return fmt.Errorf("写入版本文件失败: %v", err)
+	versionInfo := &VersionInfo{CurrentVersion: "v1", StepDurations: make(map[string]interface{})}
+	if _, statErr := os.Stat(currentFile); statErr == nil {
+		if versionInfo, err = loadVersionFile(currentFile); err != nil {
+			return fmt.Errorf("读取版本信息失败: %v", err)
+		}
 	}
 
+	update(versionInfo)
+	versionInfo.LastUpdated = time.Now().Format("2006-01-02 15:04:05")
+
+	if err := writeVersionFile(currentFile, versionInfo); err != nil {
+		return fmt.Errorf("写入版本文件失败: %v", err)
+	}
 	AppLogger.Info(fmt.Sprintf("已更新项目 %s 的版本: %s", project, versionInfo.CurrentVersion))
 	return nil
 }
 
 // UpdateStepDuration 更新步骤耗时信息
 func UpdateStepDuration(project, stepName string, duration interface{}) error {
-	// 读取当前版本信息
-	versionInfo, err := GetCurrentVersion(project)
-	if err != nil {
-		return fmt.Errorf("读取版本信息失败: %v", err)
-	}
-
-	// 更新步骤耗时
-	versionInfo.StepDurations[stepName] = duration
-	versionInfo.LastUpdated = time.Now().Format("2006-01-02 15:04:05")
-
-	// 保存到文件
-	return saveVersionFile(project, versionInfo)
+	return updateVersionInfo(project, func(versionInfo *VersionInfo) {
+		if versionInfo.StepDurations == nil {
+			versionInfo.StepDurations = make(map[string]interface{})
+		}
+		versionInfo.StepDurations[stepName] = duration
+		if seconds, ok := duration.(float64); ok {
+			appendStepHistory(versionInfo, stepName, seconds)
+		}
+	})
 }
 
 // HasVersionStructure 检查项目是否有v1/v2版本结构（基于配置）
@@ -0,0 +1,152 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"cicd-agent/config"
+)
+
+// WeComMessage 企业微信机器人消息结构
+type WeComMessage struct {
+	MsgType      string             `json:"msgtype"`
+	Markdown     *WeComMarkdown     `json:"markdown,omitempty"`
+	TemplateCard *WeComTemplateCard `json:"template_card,omitempty"`
+}
+
+// WeComMarkdown markdown消息
+type WeComMarkdown struct {
+	Content string `json:"content"`
+}
+
+// WeComTemplateCard 文本通知模板卡片
+type WeComTemplateCard struct {
+	CardType              string                   `json:"card_type"`
+	Source                *WeComCardSource         `json:"source,omitempty"`
+	MainTitle             WeComCardTitle           `json:"main_title"`
+	EmphasisContent       *WeComCardTitle          `json:"emphasis_content,omitempty"`
+	HorizontalContentList []WeComHorizontalContent `json:"horizontal_content_list,omitempty"`
+	CardAction            WeComCardAction          `json:"card_action"`
+}
+
+// WeComCardSource 卡片来源
+type WeComCardSource struct {
+	Desc      string `json:"desc"`
+	DescColor int    `json:"desc_color"` // 0灰色 1黑色 2红色 3绿色
+}
+
+// WeComCardTitle 卡片标题
+type WeComCardTitle struct {
+	Title string `json:"title"`
+	Desc  string `json:"desc,omitempty"`
+}
+
+// WeComHorizontalContent 二级标题+文本
+type WeComHorizontalContent struct {
+	KeyName string `json:"keyname"`
+	Value   string `json:"value"`
+}
+
+// WeComCardAction 卡片跳转
+type WeComCardAction struct {
+	Type int    `json:"type"` // 1跳转URL
+	URL  string `json:"url"`
+}
+
+// SendWeComCard 发送企业微信任务通知（项目未启用企业微信渠道时直接返回）
+// 配置了action_url时发送模板卡片，否则发送markdown消息
+func SendWeComCard(ctx context.Context, project, tag, status, startTime, endTime, deployType, category, projectName string) error {
+	channel := config.AppConfig.Notification.WeCom
+	webhookURL := chatChannelWebhook(channel, project, status)
+	if webhookURL == "" {
+		return nil
+	}
+	if err := ValidateOutboundURL(webhookURL); err != nil {
+		return fmt.Errorf("企业微信通知地址校验失败: %v", err)
+	}
+
+	summary := buildTaskSummary(project, tag, status, startTime, endTime, deployType, category, projectName)
+	message := buildWeComMessage(summary, status, channel.ActionURL)
+
+	jsonData, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("序列化企业微信消息失败: %v", err)
+	}
+
+	resp, err := PostJSON(ctx, webhookURL, jsonData)
+	if err != nil {
+		return fmt.Errorf("发送企业微信通知失败: %v", err)
+	}
+
+	if err := checkRobotResponse(resp); err != nil {
+		return fmt.Errorf("企业微信通知%v", err)
+	}
+
+	AppLogger.Info(fmt.Sprintf("企业微信通知发送成功: 项目=%s, 状态=%s", project, status))
+	return nil
+}
+
+// buildWeComMessage 构建企业微信消息
+func buildWeComMessage(summary taskCardSummary, status, actionURL string) WeComMessage {
+	if actionURL == "" {
+		return WeComMessage{
+			MsgType:  "markdown",
+			Markdown: &WeComMarkdown{Content: buildWeComMarkdown(summary, status)},
+		}
+	}
+
+	contents := make([]WeComHorizontalContent, 0, len(summary.Fields))
+	for _, field := range summary.Fields {
+		contents = append(contents, WeComHorizontalContent{KeyName: field.Label, Value: field.Value})
+	}
+
+	return WeComMessage{
+		MsgType: "template_card",
+		TemplateCard: &WeComTemplateCard{
+			CardType:              "text_notice",
+			Source:                &WeComCardSource{Desc: "cicd-agent", DescColor: weComStatusColor(status)},
+			MainTitle:             WeComCardTitle{Title: summary.Title, Desc: fmt.Sprintf("开始时间: %s", summary.StartTime)},
+			EmphasisContent:       &WeComCardTitle{Title: summary.StatusText},
+			HorizontalContentList: contents,
+			CardAction:            WeComCardAction{Type: 1, URL: actionURL},
+		},
+	}
+}
+
+// buildWeComMarkdown 构建企业微信markdown内容
+func buildWeComMarkdown(summary taskCardSummary, status string) string {
+	color := "comment"
+	switch status {
+	case "complete":
+		color = "info"
+	case "failed":
+		color = "warning"
+	}
+
+	var content strings.Builder
+	content.WriteString(fmt.Sprintf("**<font color=\"%s\">%s</font>**\n", color, summary.Title))
+	for _, field := range summary.Fields {
+		content.WriteString(fmt.Sprintf("> %s: <font color=\"comment\">%s</font>\n", field.Label, field.Value))
+	}
+	content.WriteString(fmt.Sprintf("> 开始时间: %s\n", summary.StartTime))
+	if summary.EndTime != "" {
+		content.WriteString(fmt.Sprintf("> 结束时间: %s\n", summary.EndTime))
+	}
+	return content.String()
+}
+
+// weComStatusColor 模板卡片来源文字颜色
+func weComStatusColor(status string) int {
+	switch status {
+	case "complete":
+		return 3
+	case "failed":
+		return 2
+	case "running":
+		return 1
+	default:
+		return 0
+	}
+}
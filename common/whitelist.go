@@ -4,6 +4,8 @@ import (
 	"cicd-agent/config"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"net/netip"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -11,9 +13,65 @@ import (
 
 // IPWhitelist IP白名单管理器
 type IPWhitelist struct {
-	allowedIPs map[string]bool
-	mutex      sync.RWMutex
-	stopChan   chan struct{}
+	allowedIPs  map[string]*ipSet // 白名单名称 -> IP集合
+	tempEntries []*TempWhitelistEntry
+	lastRefresh time.Time
+	mutex       sync.RWMutex
+	stopChan    chan struct{}
+}
+
+// ipSet 单个白名单的IP集合：单个地址使用哈希查找，CIDR网段按前缀匹配
+type ipSet struct {
+	entries  []string // 解析后的原始条目（用于展示）
+	addrs    map[netip.Addr]bool
+	prefixes []netip.Prefix
+}
+
+// newIPSet 由IP或CIDR字符串构建IP集合，无法解析的条目会被忽略
+func newIPSet(entries []string) *ipSet {
+	set := &ipSet{entries: entries, addrs: make(map[netip.Addr]bool)}
+	for _, entry := range entries {
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				AppLogger.Warning("无效的白名单网段:", entry)
+				continue
+			}
+			set.prefixes = append(set.prefixes, prefix.Masked())
+			continue
+		}
+		addr, ok := parseNormalizedAddr(entry)
+		if !ok {
+			AppLogger.Warning("无效的白名单IP:", entry)
+			continue
+		}
+		set.addrs[addr] = true
+	}
+	// 前缀越短覆盖范围越大，优先匹配
+	sort.Slice(set.prefixes, func(i, j int) bool {
+		return set.prefixes[i].Bits() < set.prefixes[j].Bits()
+	})
+	return set
+}
+
+// contains 判断IP是否在集合中
+func (s *ipSet) contains(ip string) bool {
+	if s == nil {
+		return false
+	}
+	addr, ok := parseNormalizedAddr(ip)
+	if !ok {
+		return false
+	}
+	if s.addrs[addr] {
+		return true
+	}
+	for _, prefix := range s.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
 }
 
 var whitelist *IPWhitelist
@@ -21,7 +79,7 @@ var whitelist *IPWhitelist
 // InitWhitelist 初始化IP白名单
 func InitWhitelist() {
 	whitelist = &IPWhitelist{
-		allowedIPs: make(map[string]bool),
+		allowedIPs: make(map[string]*ipSet),
 		stopChan:   make(chan struct{}),
 	}
 
@@ -39,18 +97,19 @@ func (w *IPWhitelist) updateIPs() {
 		return
 	}
 
-	ips := config.AppConfig.ResolveWhitelistIPs()
+	// 解析所有白名单（默认白名单及按接口分组的白名单）
+	allowedIPs := make(map[string]*ipSet)
+	for _, name := range config.AppConfig.WhitelistNames() {
+		entries := config.AppConfig.GetWhitelistEntries(name)
+		allowedIPs[name] = newIPSet(resolveWhitelistEntries(entries))
+	}
 
 	w.mutex.Lock()
 	defer w.mutex.Unlock()
 
-	// 清空旧的IP列表
-	w.allowedIPs = make(map[string]bool)
-
-	// 添加新的IP列表
-	for _, ip := range ips {
-		w.allowedIPs[ip] = true
-	}
+	// 替换为新的IP列表
+	w.allowedIPs = allowedIPs
+	w.lastRefresh = time.Now()
 }
 
 // startUpdateRoutine 启动定时更新routine
@@ -73,12 +132,19 @@ func (w *IPWhitelist) startUpdateRoutine() {
 	}
 }
 
-// isAllowed 检查IP是否在白名单中
-func (w *IPWhitelist) isAllowed(ip string) bool {
+// isAllowedIn 检查IP是否在指定白名单中，名单未配置时使用默认白名单
+func (w *IPWhitelist) isAllowedIn(listName, ip string) bool {
 	w.mutex.RLock()
 	defer w.mutex.RUnlock()
 
-	return w.allowedIPs[ip]
+	ips, exists := w.allowedIPs[listName]
+	if !exists {
+		ips = w.allowedIPs[config.DefaultWhitelistName]
+	}
+	if ips.contains(ip) {
+		return true
+	}
+	return w.tempAllowed(listName, ip)
 }
 
 // Stop 停止IP白名单更新
@@ -86,30 +152,90 @@ func (w *IPWhitelist) Stop() {
 	close(w.stopChan)
 }
 
-// getClientIP 获取客户端真实IP
+// getClientIP 获取客户端真实IP（已规范化，IPv4映射的IPv6地址转为IPv4）
+// 只有直连地址属于server.trusted_proxies时才采信X-Forwarded-For/X-Real-IP，
+// 否则直接使用连接的源地址，防止伪造请求头绕过白名单
 func getClientIP(c *gin.Context) string {
-	// 优先从X-Forwarded-For获取
-	forwarded := c.GetHeader("X-Forwarded-For")
-	if forwarded != "" {
-		// X-Forwarded-For可能包含多个IP，取第一个
-		ips := strings.Split(forwarded, ",")
-		if len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
+	remoteIP := normalizeIP(c.Request.RemoteAddr)
+
+	trusted := config.AppConfig.GetTrustedProxies()
+	if !isTrustedProxy(trusted, remoteIP) {
+		return remoteIP
+	}
+
+	// X-Forwarded-For从右往左逐跳检查，第一个非可信代理的地址即为客户端
+	if forwarded := c.GetHeader("X-Forwarded-For"); forwarded != "" {
+		hops := strings.Split(forwarded, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := normalizeIP(hops[i])
+			if _, ok := parseNormalizedAddr(hop); !ok {
+				// 无法解析的地址不再继续向左信任
+				break
+			}
+			if i == 0 || !isTrustedProxy(trusted, hop) {
+				return hop
+			}
 		}
 	}
 
-	// 从X-Real-IP获取
-	realIP := c.GetHeader("X-Real-IP")
-	if realIP != "" {
-		return strings.TrimSpace(realIP)
+	if realIP := c.GetHeader("X-Real-IP"); realIP != "" {
+		if _, ok := parseNormalizedAddr(realIP); ok {
+			return normalizeIP(realIP)
+		}
 	}
 
-	// 最后使用RemoteAddr
-	return c.ClientIP()
+	return remoteIP
+}
+
+// GetClientIP 获取客户端真实IP（供其他包使用）
+func GetClientIP(c *gin.Context) string {
+	return getClientIP(c)
+}
+
+// isTrustedProxy 判断地址是否属于可信代理
+func isTrustedProxy(trusted []netip.Prefix, ip string) bool {
+	if len(trusted) == 0 {
+		return false
+	}
+	addr, ok := parseNormalizedAddr(ip)
+	if !ok {
+		return false
+	}
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeIP 规范化IP字符串：去掉端口、IPv6方括号和zone，IPv4映射地址转为IPv4
+// 无法解析时返回去掉空白后的原值
+func normalizeIP(value string) string {
+	value = strings.TrimSpace(value)
+	if addr, ok := parseNormalizedAddr(value); ok {
+		return addr.String()
+	}
+	return value
+}
+
+// parseNormalizedAddr 解析IP，兼容 [::1]:8080、1.2.3.4:8080、fe80::1%eth0、::ffff:1.2.3.4 等格式
+func parseNormalizedAddr(value string) (netip.Addr, bool) {
+	value = strings.TrimSpace(value)
+
+	if addrPort, err := netip.ParseAddrPort(value); err == nil {
+		return addrPort.Addr().WithZone("").Unmap(), true
+	}
+	addr, err := netip.ParseAddr(strings.Trim(value, "[]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.WithZone("").Unmap(), true
 }
 
 // IPWhitelistMiddleware IP白名单检查中间件
-func IPWhitelistMiddleware() gin.HandlerFunc {
+// listName为whitelist.lists中的名单名称，未配置该名单时使用默认白名单
+func IPWhitelistMiddleware(listName string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if whitelist == nil {
 			AppLogger.Error("IP白名单未初始化")
@@ -123,8 +249,8 @@ func IPWhitelistMiddleware() gin.HandlerFunc {
 
 		clientIP := getClientIP(c)
 
-		if !whitelist.isAllowed(clientIP) {
-			AppLogger.Warning("未授权的IP访问:", clientIP)
+		if !whitelist.isAllowedIn(listName, clientIP) {
+			AppLogger.Warning("未授权的IP访问:", clientIP, " 白名单:", listName)
 			// 返回404而不是403，隐藏服务存在
 			c.JSON(http.StatusNotFound, gin.H{
 				"code": 404,
@@ -0,0 +1,145 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"cicd-agent/config"
+)
+
+// TempWhitelistEntry 临时白名单条目（到期自动失效）
+type TempWhitelistEntry struct {
+	List      string    `json:"list"`  // 白名单名称
+	Entry     string    `json:"entry"` // IP或CIDR网段
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedBy string    `json:"created_by,omitempty"`
+	set       *ipSet
+}
+
+// WhitelistSnapshot 当前白名单状态
+type WhitelistSnapshot struct {
+	Lists       map[string][]string   `json:"lists"` // 白名单名称 -> 已解析的IP/网段
+	Temporary   []*TempWhitelistEntry `json:"temporary"`
+	LastRefresh time.Time             `json:"last_refresh"`
+}
+
+// tempAllowed 检查IP是否在未过期的临时条目中（调用方持有读锁）
+// 添加到默认白名单的临时条目对未单独配置的分组同样生效
+func (w *IPWhitelist) tempAllowed(listName, ip string) bool {
+	_, listConfigured := w.allowedIPs[listName]
+	now := time.Now()
+	for _, entry := range w.tempEntries {
+		if now.After(entry.ExpiresAt) {
+			continue
+		}
+		if entry.List != listName && !(entry.List == config.DefaultWhitelistName && !listConfigured) {
+			continue
+		}
+		if entry.set.contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Refresh 立即重新解析白名单
+func (w *IPWhitelist) Refresh() {
+	w.updateIPs()
+	AppLogger.Info("IP白名单已手动刷新")
+}
+
+// AddTempEntry 添加临时白名单条目，entry可为IP、CIDR或域名（域名立即解析）
+func (w *IPWhitelist) AddTempEntry(listName, entry string, ttl time.Duration, createdBy string) (*TempWhitelistEntry, error) {
+	if listName == "" {
+		listName = config.DefaultWhitelistName
+	}
+	resolved := resolveWhitelistEntries([]string{entry})
+	set := newIPSet(resolved)
+	if len(set.addrs) == 0 && len(set.prefixes) == 0 {
+		return nil, fmt.Errorf("无法解析白名单条目: %s", entry)
+	}
+
+	temp := &TempWhitelistEntry{
+		List:      listName,
+		Entry:     entry,
+		ExpiresAt: time.Now().Add(ttl),
+		CreatedBy: createdBy,
+		set:       set,
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	// 同一名单的相同条目只保留最新一条
+	w.removeTempLocked(listName, entry)
+	w.pruneTempLocked()
+	w.tempEntries = append(w.tempEntries, temp)
+
+	AppLogger.Info(fmt.Sprintf("添加临时白名单: 名单=%s, 条目=%s, 有效期至%s, 操作人=%s",
+		listName, entry, temp.ExpiresAt.Format("2006-01-02 15:04:05"), createdBy))
+	return temp, nil
+}
+
+// RemoveTempEntry 移除临时白名单条目，返回是否存在
+func (w *IPWhitelist) RemoveTempEntry(listName, entry string) bool {
+	if listName == "" {
+		listName = config.DefaultWhitelistName
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	removed := w.removeTempLocked(listName, entry)
+	if removed {
+		AppLogger.Info(fmt.Sprintf("移除临时白名单: 名单=%s, 条目=%s", listName, entry))
+	}
+	return removed
+}
+
+// Snapshot 获取当前白名单状态
+func (w *IPWhitelist) Snapshot() *WhitelistSnapshot {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.pruneTempLocked()
+
+	snapshot := &WhitelistSnapshot{
+		Lists:       make(map[string][]string, len(w.allowedIPs)),
+		Temporary:   append([]*TempWhitelistEntry{}, w.tempEntries...),
+		LastRefresh: w.lastRefresh,
+	}
+	for name, set := range w.allowedIPs {
+		entries := append([]string{}, set.entries...)
+		sort.Strings(entries)
+		snapshot.Lists[name] = entries
+	}
+	return snapshot
+}
+
+// removeTempLocked 移除指定临时条目（调用方持有写锁）
+func (w *IPWhitelist) removeTempLocked(listName, entry string) bool {
+	removed := false
+	kept := w.tempEntries[:0]
+	for _, temp := range w.tempEntries {
+		if temp.List == listName && temp.Entry == entry {
+			removed = true
+			continue
+		}
+		kept = append(kept, temp)
+	}
+	w.tempEntries = kept
+	return removed
+}
+
+// pruneTempLocked 清理已过期的临时条目（调用方持有写锁）
+func (w *IPWhitelist) pruneTempLocked() {
+	now := time.Now()
+	kept := w.tempEntries[:0]
+	for _, temp := range w.tempEntries {
+		if now.Before(temp.ExpiresAt) {
+			kept = append(kept, temp)
+		}
+	}
+	w.tempEntries = kept
+}
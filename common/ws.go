@@ -2,6 +2,10 @@ package common
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -11,6 +15,8 @@ import (
 	"sync"
 	"time"
 
+	"cicd-agent/config"
+
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 )
@@ -18,10 +24,8 @@ import (
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
-	// 允许所有跨域请求
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+	// 按cors配置校验来源
+	CheckOrigin: checkWebSocketOrigin,
 }
 
 // taskLogConnection 任务日志WebSocket连接管理
@@ -37,53 +41,83 @@ type taskLogConnection struct {
 	bufferSize  int
 	flushTicker *time.Ticker
 	maxLines    int
+	format      string // 输出格式: text/json
+	closeOnce   sync.Once
+
+	writeTimeout time.Duration // 单次写超时
+	idleTimeout  time.Duration // 空闲超时（未收到pong或消息）
+	pingInterval time.Duration // ping保活间隔
 }
 
-// TaskLogWebSocket 任务日志WebSocket处理函数
-// 客户端示例：
-// const ws = new WebSocket(`ws://agent地址/ws/task/logs?data=加密参数`);
-// ws.onmessage = function(event) { console.log(event.data); };
-func TaskLogWebSocket(c *gin.Context) {
-	// 获取加密的参数
-	encryptedData := c.Query("data")
-	if encryptedData == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少加密参数"})
-		return
-	}
+// 日志连接数统计（WebSocket与SSE共用）
+var (
+	logConnMu      sync.Mutex
+	logConnTotal   int
+	logConnPerTask = make(map[string]int)
+)
 
-	// 解密参数（使用common中的解密方法）
-	decryptedData, err := DecryptAndDecompress(encryptedData)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "解密参数失败"})
-		return
+// acquireLogConn 申请一个日志连接名额，超过全局或单任务上限时返回false
+func acquireLogConn(taskID string) bool {
+	logConnMu.Lock()
+	defer logConnMu.Unlock()
+
+	if logConnTotal >= config.AppConfig.GetWebSocketMaxConnections() {
+		return false
 	}
+	if logConnPerTask[taskID] >= config.AppConfig.GetWebSocketMaxPerTask() {
+		return false
+	}
+	logConnTotal++
+	logConnPerTask[taskID]++
+	return true
+}
 
-	// 解析解密后的参数
-	var params struct {
-		TaskID   string `json:"taskId"`
-		StepType string `json:"stepType"`
+// releaseLogConn 释放日志连接名额
+func releaseLogConn(taskID string) {
+	logConnMu.Lock()
+	defer logConnMu.Unlock()
+
+	if logConnTotal > 0 {
+		logConnTotal--
+	}
+	if logConnPerTask[taskID] <= 1 {
+		delete(logConnPerTask, taskID)
+	} else {
+		logConnPerTask[taskID]--
 	}
+}
 
-	if err := json.Unmarshal(decryptedData, &params); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "解析参数失败"})
+// ActiveLogConnections 获取当前日志连接总数
+func ActiveLogConnections() int {
+	logConnMu.Lock()
+	defer logConnMu.Unlock()
+	return logConnTotal
+}
+
+// TaskLogWebSocket 任务日志WebSocket处理函数
+// 客户端示例：
+// const ws = new WebSocket(`ws://agent地址/ws/task/logs?data=加密参数`);
+// ws.onmessage = function(event) { console.log(event.data); };
+// 参数format=json时，每条消息为LogFrame数组：[{ts, level, step, line}, ...]
+func TaskLogWebSocket(c *gin.Context) {
+	params, ok := decodeTaskLogParams(c, "")
+	if !ok {
 		return
 	}
-
 	taskID := params.TaskID
 	stepType := params.StepType
 
-	if taskID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少任务ID参数"})
-		return
-	}
-	if stepType == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少步骤名称参数"})
+	// 检查连接数上限，防止遗弃的浏览器标签页耗尽文件句柄
+	if !acquireLogConn(taskID) {
+		AppLogger.Warning(fmt.Sprintf("日志连接数已达上限，拒绝连接: 任务=%s", taskID))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "日志连接数已达上限"})
 		return
 	}
 
 	// 升级HTTP连接为WebSocket连接
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
+		releaseLogConn(taskID)
 		AppLogger.Error(fmt.Sprintf("升级WebSocket连接失败: %v", err))
 		return
 	}
@@ -103,6 +137,11 @@ func TaskLogWebSocket(c *gin.Context) {
 		bufferSize:  0,
 		flushTicker: time.NewTicker(200 * time.Millisecond),
 		maxLines:    1000,
+		format:      params.Format,
+
+		writeTimeout: config.AppConfig.GetWebSocketWriteTimeout(),
+		idleTimeout:  config.AppConfig.GetWebSocketIdleTimeout(),
+		pingInterval: config.AppConfig.GetWebSocketPingInterval(),
 	}
 
 	// 发送当前日志
@@ -116,6 +155,115 @@ func TaskLogWebSocket(c *gin.Context) {
 
 	// 处理客户端消息
 	go tc.handleMessages()
+
+	// 定期发送ping保活
+	go tc.pingRoutine()
+}
+
+// taskLogParams 日志查看接口的解密参数
+type taskLogParams struct {
+	TaskID   string `json:"taskId"`
+	StepType string `json:"stepType"`
+	Format   string `json:"format"` // text（默认）或json
+	Nonce    string `json:"nonce"`  // 一次性随机串，与ts一起用于防重放
+	Ts       int64  `json:"ts"`     // 参数生成时间（Unix秒）
+}
+
+// decodeTaskLogParams 解密并校验日志查看参数，失败时直接写入错误响应
+// resumeToken为SSE重连时Last-Event-ID中的续传令牌，WebSocket不支持续传，传空
+func decodeTaskLogParams(c *gin.Context, resumeToken string) (*taskLogParams, bool) {
+	// 获取加密的参数
+	encryptedData := c.Query("data")
+	if encryptedData == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少加密参数"})
+		return nil, false
+	}
+
+	// 解密参数（使用common中的解密方法）
+	decryptedData, err := DecryptAndDecompress(encryptedData)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "解密参数失败"})
+		return nil, false
+	}
+
+	// 解析解密后的参数
+	var params taskLogParams
+	if err := json.Unmarshal(decryptedData, &params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "解析参数失败"})
+		return nil, false
+	}
+
+	if params.TaskID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少任务ID参数"})
+		return nil, false
+	}
+
+	// 未携带认证凭证的请求只接受一次性参数（见RequireScopeOrLogTicket）
+	if c.GetBool(logTicketRequiredKey) && (params.Nonce == "" || params.Ts == 0) {
+		AppLogger.Warning(fmt.Sprintf("日志查看请求缺少认证凭证: 任务=%s, IP=%s", params.TaskID, getClientIP(c)))
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "认证失败"})
+		return nil, false
+	}
+
+	// 防重放：SSE断线重连会复用同一参数，续传令牌（首次连接通过nonce校验后由服务端签发）有效时只校验时间窗口
+	if resumeToken != "" && params.Nonce != "" && params.Ts != 0 && verifyLogResumeToken(params.Nonce, resumeToken) {
+		window := config.AppConfig.GetReplayWindow()
+		if skew := time.Since(time.Unix(params.Ts, 0)); skew > window || skew < -window {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "参数已过期"})
+			return nil, false
+		}
+	} else if err := verifyReplay("logs", params.Nonce, params.Ts, config.AppConfig.GetReplayWindow()); err != nil {
+		AppLogger.Warning(fmt.Sprintf("日志查看参数防重放校验失败: 任务=%s, 原因=%v", params.TaskID, err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if params.StepType == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少步骤名称参数"})
+		return nil, false
+	}
+
+	// 输出格式也允许通过明文查询参数指定
+	if params.Format == "" {
+		params.Format = c.Query("format")
+	}
+	if params.Format != logFormatJSON {
+		params.Format = logFormatText
+	}
+	return &params, true
+}
+
+// logResumeKey 签发续传令牌的进程内密钥，重启后旧令牌失效
+var logResumeKey = func() []byte {
+	key := make([]byte, 32)
+	rand.Read(key) // Go 1.24起crypto/rand.Read不会返回错误
+	return key
+}()
+
+// newLogResumeToken 为已通过nonce校验的日志查看参数签发续传令牌
+func newLogResumeToken(nonce string) string {
+	mac := hmac.New(sha256.New, logResumeKey)
+	mac.Write([]byte("logs:" + nonce))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifyLogResumeToken 校验续传令牌是否由本进程为该nonce签发
+func verifyLogResumeToken(nonce, token string) bool {
+	return hmac.Equal([]byte(token), []byte(newLogResumeToken(nonce)))
+}
+
+// NewTaskLogParams 生成日志查看接口的加密参数（data），供已通过认证的管理界面直接连接WebSocket/SSE
+func NewTaskLogParams(taskID, stepType, format string) (string, error) {
+	data, err := json.Marshal(taskLogParams{
+		TaskID:   taskID,
+		StepType: stepType,
+		Format:   format,
+		Nonce:    NewRequestID(),
+		Ts:       time.Now().Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	return CompressAndEncrypt(data)
 }
 
 // buildLogFilePath 构建日志文件路径
@@ -154,22 +302,43 @@ func (tc *taskLogConnection) sendCurrentLogs() {
 	tc.mu.Lock()
 	defer tc.mu.Unlock()
 
-	// 检查日志文件是否存在
-	if _, err := os.Stat(tc.logFilePath); os.IsNotExist(err) {
-		err := tc.conn.WriteMessage(websocket.TextMessage, []byte("日志文件不存在或尚未生成"))
+	// 读取日志文件内容（已压缩的日志透明解压）
+	content, plainSize, err := readTaskLogFile(tc.logFilePath)
+	if os.IsNotExist(err) {
+		err := tc.write(tc.systemMessage("日志文件不存在或尚未生成"))
 		if err != nil {
 			AppLogger.Error(fmt.Sprintf("发送消息失败: %v", err))
 		}
 		return
 	}
-
-	// 读取日志文件内容
-	content, err := os.ReadFile(tc.logFilePath)
 	if err != nil {
 		AppLogger.Warning(fmt.Sprintf("读取日志文件失败: %v", err))
 		return
 	}
 
+	// JSON模式：按行解析为结构化帧发送
+	if len(content) > 0 && tc.format == logFormatJSON {
+		lines := splitLines(string(content))
+		var frames []LogFrame
+		if len(lines) > tc.maxLines {
+			frames = append(frames, systemLogFrame(tc.stepType, fmt.Sprintf("[日志过长，仅显示最后%d行，总共%d行]", tc.maxLines, len(lines))))
+			lines = lines[len(lines)-tc.maxLines:]
+		}
+		for _, line := range lines {
+			if line == "" {
+				continue
+			}
+			frames = append(frames, parseLogLine(tc.stepType, line))
+		}
+		data, _ := json.Marshal(frames)
+		if err := tc.write(data); err != nil {
+			AppLogger.Error(fmt.Sprintf("发送日志失败: %v", err))
+			return
+		}
+		tc.lastFilePos = plainSize
+		return
+	}
+
 	// 发送日志内容（限制行数）
 	if len(content) > 0 {
 		// 按行分割内容
@@ -182,82 +351,123 @@ func (tc *taskLogConnection) sendCurrentLogs() {
 			prefixMsg := fmt.Sprintf("[日志过长，仅显示最后%d行，总共%d行]\n", tc.maxLines, len(lines))
 			sendContent := prefixMsg + strings.Join(sendLines, "\n")
 
-			err := tc.conn.WriteMessage(websocket.TextMessage, []byte(sendContent))
+			err := tc.write([]byte(sendContent))
 			if err != nil {
 				AppLogger.Error(fmt.Sprintf("发送日志失败: %v", err))
 				return
 			}
 		} else {
 			// 发送全部内容
-			err := tc.conn.WriteMessage(websocket.TextMessage, content)
+			err := tc.write(content)
 			if err != nil {
 				AppLogger.Error(fmt.Sprintf("发送日志失败: %v", err))
 				return
 			}
 		}
-		// 设置文件位置为实际文件大小
-		tc.lastFilePos = int64(len(content))
+		// 设置文件位置为未压缩日志文件的大小
+		tc.lastFilePos = plainSize
 	}
 }
 
 // watchTaskLogs 监听任务日志更新
+// 优先使用共享的fsnotify目录监听，目录尚不存在或监听失败时回退到轮询
 func (tc *taskLogConnection) watchTaskLogs() {
-	ticker := time.NewTicker(500 * time.Millisecond)
-	defer ticker.Stop()
+	taskDir := filepath.Dir(tc.logFilePath)
+
+	var notify <-chan struct{}
+	var pollC <-chan time.Time
+	unsubscribe := func() {}
+	defer func() { unsubscribe() }()
+
+	// trySubscribe 尝试订阅目录变更，成功后停止轮询
+	var ticker *time.Ticker
+	trySubscribe := func() {
+		ch, unsub, err := subscribeLogDir(taskDir)
+		if err != nil {
+			return
+		}
+		notify = ch
+		unsubscribe = unsub
+		if ticker != nil {
+			ticker.Stop()
+			pollC = nil
+		}
+		// 订阅前可能已有新内容写入
+		tc.readNewLogs()
+	}
+
+	trySubscribe()
+	if notify == nil {
+		ticker = time.NewTicker(500 * time.Millisecond)
+		defer ticker.Stop()
+		pollC = ticker.C
+	}
 
 	for {
 		select {
 		case <-tc.closeChan:
 			return
-		case <-ticker.C:
-			// 检查日志文件是否有更新
-			fileInfo, err := os.Stat(tc.logFilePath)
-			if err != nil {
-				// 日志文件不存在时静默等待
-				continue
+		case <-notify:
+			tc.readNewLogs()
+		case <-pollC:
+			tc.readNewLogs()
+			if notify == nil {
+				trySubscribe()
 			}
+		}
+	}
+}
 
-			// 如果文件大小有变化，读取新增内容
-			if fileInfo.Size() > tc.lastFilePos {
-				file, err := os.Open(tc.logFilePath)
-				if err != nil {
-					AppLogger.Error(fmt.Sprintf("打开日志文件失败: %v", err))
-					continue
-				}
-
-				// 从上次位置开始读取
-				file.Seek(tc.lastFilePos, 0)
-				buffer := make([]byte, fileInfo.Size()-tc.lastFilePos)
-				n, err := file.Read(buffer)
-				file.Close()
-
-				if err != nil {
-					AppLogger.Error(fmt.Sprintf("读取日志文件失败: %v", err))
-					continue
-				}
-
-				if n > 0 {
-					// 解析新增日志
-					newContent := string(buffer[:n])
-					newLogs := splitLines(newContent)
-
-					// 添加到缓冲区
-					tc.mu.Lock()
-					for _, log := range newLogs {
-						if log == "" {
-							continue
-						}
-						tc.logBuffer = append(tc.logBuffer, log)
-						tc.bufferSize++
-					}
-					tc.mu.Unlock()
-				}
-
-				// 更新文件位置
-				tc.lastFilePos = fileInfo.Size()
+// readNewLogs 读取日志文件新增内容并放入缓冲区
+func (tc *taskLogConnection) readNewLogs() {
+	// 检查日志文件是否有更新
+	fileInfo, err := os.Stat(tc.logFilePath)
+	if err != nil {
+		// 日志文件不存在时静默等待
+		return
+	}
+
+	// 如果文件大小有变化，读取新增内容
+	if fileInfo.Size() <= tc.lastFilePos {
+		return
+	}
+
+	file, err := os.Open(tc.logFilePath)
+	if err != nil {
+		AppLogger.Error(fmt.Sprintf("打开日志文件失败: %v", err))
+		return
+	}
+
+	// 从上次位置开始读取
+	file.Seek(tc.lastFilePos, 0)
+	buffer := make([]byte, fileInfo.Size()-tc.lastFilePos)
+	n, err := file.Read(buffer)
+	file.Close()
+
+	if err != nil {
+		AppLogger.Error(fmt.Sprintf("读取日志文件失败: %v", err))
+		return
+	}
+
+	if n > 0 {
+		// 解析新增日志
+		newContent := string(buffer[:n])
+		newLogs := splitLines(newContent)
+
+		// 添加到缓冲区
+		tc.mu.Lock()
+		for _, log := range newLogs {
+			if log == "" {
+				continue
 			}
+			tc.logBuffer = append(tc.logBuffer, log)
+			tc.bufferSize++
 		}
+		tc.mu.Unlock()
 	}
+
+	// 更新文件位置
+	tc.lastFilePos = fileInfo.Size()
 }
 
 // flushBufferRoutine 定期刷新缓冲区
@@ -269,42 +479,83 @@ func (tc *taskLogConnection) flushBufferRoutine() {
 		case <-tc.closeChan:
 			return
 		case <-tc.flushTicker.C:
-			tc.flushBuffer()
+			if err := tc.flushBuffer(); err != nil {
+				// 写超时或连接已断开，关闭连接释放资源
+				tc.close()
+				return
+			}
 		}
 	}
 }
 
 // flushBuffer 刷新缓冲区，发送积累的日志
-func (tc *taskLogConnection) flushBuffer() {
+func (tc *taskLogConnection) flushBuffer() error {
 	tc.mu.Lock()
 	defer tc.mu.Unlock()
 
 	if tc.bufferSize == 0 {
-		return
+		return nil
 	}
 
 	// 构建批量消息
 	var buffer bytes.Buffer
-	for _, log := range tc.logBuffer {
-		buffer.WriteString(log + "\n")
+	if tc.format == logFormatJSON {
+		data, _ := marshalLogFrames(tc.stepType, tc.logBuffer)
+		buffer.Write(data)
+	} else {
+		for _, log := range tc.logBuffer {
+			buffer.WriteString(log + "\n")
+		}
 	}
 
 	// 发送批量消息
-	err := tc.conn.WriteMessage(websocket.TextMessage, buffer.Bytes())
+	err := tc.write(buffer.Bytes())
 	if err != nil {
 		AppLogger.Error(fmt.Sprintf("批量发送日志失败: %v", err))
-		return
+		return err
 	}
 
 	// 清空缓冲区
 	tc.logBuffer = tc.logBuffer[:0]
 	tc.bufferSize = 0
+	return nil
+}
+
+// write 带写超时地发送文本消息
+func (tc *taskLogConnection) write(data []byte) error {
+	tc.conn.SetWriteDeadline(time.Now().Add(tc.writeTimeout))
+	return tc.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// pingRoutine 定期发送ping，写失败时关闭连接
+func (tc *taskLogConnection) pingRoutine() {
+	ticker := time.NewTicker(tc.pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-tc.closeChan:
+			return
+		case <-ticker.C:
+			// WriteControl可与其他写操作并发调用
+			if err := tc.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(tc.writeTimeout)); err != nil {
+				tc.close()
+				return
+			}
+		}
+	}
 }
 
 // handleMessages 处理客户端消息
 func (tc *taskLogConnection) handleMessages() {
 	defer tc.close()
 
+	// 空闲超时：在idleTimeout内未收到pong或任何消息则认为连接已失效
+	tc.conn.SetReadDeadline(time.Now().Add(tc.idleTimeout))
+	tc.conn.SetPongHandler(func(string) error {
+		return tc.conn.SetReadDeadline(time.Now().Add(tc.idleTimeout))
+	})
+
 	for {
 		// 读取客户端消息
 		_, _, err := tc.conn.ReadMessage()
@@ -314,23 +565,30 @@ func (tc *taskLogConnection) handleMessages() {
 			}
 			break
 		}
-		// 目前不处理客户端发送的消息
+		// 目前不处理客户端发送的消息，仅刷新空闲超时
+		tc.conn.SetReadDeadline(time.Now().Add(tc.idleTimeout))
 	}
 }
 
 // close 关闭连接
 func (tc *taskLogConnection) close() {
-	select {
-	case <-tc.closeChan:
-		// 已经关闭
-		return
-	default:
+	tc.closeOnce.Do(func() {
 		// 关闭前发送剩余的日志
 		tc.flushBuffer()
 
 		close(tc.closeChan)
 		tc.conn.Close()
+		releaseLogConn(tc.taskID)
+	})
+}
+
+// systemMessage 按输出格式构建服务端提示消息
+func (tc *taskLogConnection) systemMessage(message string) []byte {
+	if tc.format == logFormatJSON {
+		data, _ := json.Marshal([]LogFrame{systemLogFrame(tc.stepType, message)})
+		return data
 	}
+	return []byte(message)
 }
 
 // splitLines 按行分割字符串
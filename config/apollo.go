@@ -0,0 +1,78 @@
+package config
+
+import "fmt"
+
+// ApolloConfig Apollo配置发布：配置的项目在应用服务部署（步骤13）之前通过OpenAPI修改命名空间并发布，任务失败或取消时回滚
+type ApolloConfig struct {
+	PortalURL string                         `yaml:"portal_url"` // Apollo Portal地址（如 http://apollo-portal:8070）
+	Token     string                         `yaml:"token"`      // 开放平台授权的Token
+	Operator  string                         `yaml:"operator"`   // 修改和发布使用的Apollo用户名，默认apollo
+	Env       string                         `yaml:"env"`        // 默认环境，默认PRO
+	Projects  map[string]ApolloProjectConfig `yaml:"projects"`   // 项目名 -> 发布的配置，只有配置的项目执行该步骤
+}
+
+// ApolloProjectConfig 项目对应的Apollo应用和需要修改的命名空间
+type ApolloProjectConfig struct {
+	AppID      string                  `yaml:"app_id"`  // Apollo应用ID，默认项目名
+	Env        string                  `yaml:"env"`     // 覆盖默认环境
+	Cluster    string                  `yaml:"cluster"` // 集群，默认default
+	Namespaces []ApolloNamespaceConfig `yaml:"namespaces"`
+}
+
+// ApolloNamespaceConfig 命名空间中需要修改的配置项，值中的{tag}替换为镜像标签
+type ApolloNamespaceConfig struct {
+	Name  string            `yaml:"name"` // 命名空间，默认application
+	Items map[string]string `yaml:"items"`
+}
+
+// GetApolloProject 获取项目的Apollo发布配置（已填充默认值），未配置时返回false
+func (c *Config) GetApolloProject(project string) (ApolloProjectConfig, bool) {
+	projectConfig, ok := c.Apollo.Projects[project]
+	if !ok || len(projectConfig.Namespaces) == 0 {
+		return ApolloProjectConfig{}, false
+	}
+	if projectConfig.AppID == "" {
+		projectConfig.AppID = project
+	}
+	if projectConfig.Env == "" {
+		projectConfig.Env = c.Apollo.Env
+	}
+	if projectConfig.Env == "" {
+		projectConfig.Env = "PRO"
+	}
+	if projectConfig.Cluster == "" {
+		projectConfig.Cluster = "default"
+	}
+	namespaces := make([]ApolloNamespaceConfig, len(projectConfig.Namespaces))
+	for i, namespace := range projectConfig.Namespaces {
+		if namespace.Name == "" {
+			namespace.Name = "application"
+		}
+		namespaces[i] = namespace
+	}
+	projectConfig.Namespaces = namespaces
+	return projectConfig, true
+}
+
+// GetApolloOperator 获取修改和发布使用的Apollo用户名，默认apollo
+func (c *Config) GetApolloOperator() string {
+	if c.Apollo.Operator == "" {
+		return "apollo"
+	}
+	return c.Apollo.Operator
+}
+
+// validateApollo 校验Apollo发布配置
+func validateApollo(apollo ApolloConfig) error {
+	if len(apollo.Projects) > 0 && (apollo.PortalURL == "" || apollo.Token == "") {
+		return fmt.Errorf("配置了Apollo发布项目但未配置apollo.portal_url或apollo.token")
+	}
+	for project, projectConfig := range apollo.Projects {
+		for _, namespace := range projectConfig.Namespaces {
+			if len(namespace.Items) == 0 {
+				return fmt.Errorf("项目 %s 的Apollo命名空间 %s 没有配置items", project, namespace.Name)
+			}
+		}
+	}
+	return nil
+}
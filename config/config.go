@@ -6,6 +6,10 @@ import (
 	"io/ioutil"
 	"log"
 	"net"
+	"net/netip"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -22,19 +26,84 @@ type Config struct {
 	Deployment   DeploymentConfig   `yaml:"deployment"`
 	Notification NotificationConfig `yaml:"notification"`
 	TrafficProxy TrafficProxyConfig `yaml:"traffic_proxy"`
+	WebSocket    WebSocketConfig    `yaml:"websocket"`
+	Logging      LoggingConfig      `yaml:"logging"`
+	Audit        AuditConfig        `yaml:"audit"`
+	Debug        DebugConfig        `yaml:"debug"`
+	Metrics      MetricsConfig      `yaml:"metrics"`
+	CORS         CORSConfig         `yaml:"cors"`
+	RateLimit    RateLimitConfig    `yaml:"rate_limit"`
+	Auth         AuthConfig         `yaml:"auth"`
+	Replay       ReplayConfig       `yaml:"replay"`
+	Outbound     OutboundConfig     `yaml:"outbound"`
+	EventBus     EventBusConfig     `yaml:"event_bus"`
+	Webhooks     WebhooksConfig     `yaml:"webhooks"`
+	GitOps       GitOpsConfig       `yaml:"gitops"`
+	Nacos        NacosConfig        `yaml:"nacos"`
+	Apollo       ApolloConfig       `yaml:"apollo"`
+	Migration    MigrationConfig    `yaml:"migration"`
+	Redis        RedisConfig        `yaml:"redis"`
+	SmokeTest    SmokeTestConfig    `yaml:"smoke_test"`
+	PerfGate     PerfGateConfig     `yaml:"perf_gate"`
+	Warmup       WarmupConfig       `yaml:"warmup"`
+	Drain        DrainConfig        `yaml:"drain"`
+	ConfigSync   ConfigSyncConfig   `yaml:"config_sync"`
+	Vault        VaultConfig        `yaml:"vault"`
+	UI           UIConfig           `yaml:"ui"`
+
+	LeaderElection LeaderElectionConfig `yaml:"leader_election"`
+	Routing        RoutingConfig        `yaml:"routing"`
+	Readiness      ReadinessConfig      `yaml:"readiness"`
+
+	// 日志和通知卡片的语言: zh（默认）/en
+	Locale string `yaml:"locale"`
+
+	// 流水线定义，pipelines_dir目录下的文件追加在pipelines之后
+	Pipelines    []PipelineConfig `yaml:"pipelines"`
+	PipelinesDir string           `yaml:"pipelines_dir"`
 }
 
 // ServerConfig 服务器配置
 type ServerConfig struct {
-	Host string `yaml:"host"`
-	Port string `yaml:"port"`
+	Host           string    `yaml:"host"`
+	Port           string    `yaml:"port"`
+	TLS            TLSConfig `yaml:"tls"`
+	TrustedProxies []string  `yaml:"trusted_proxies"` // 可信代理（IP或CIDR），仅来自这些地址的X-Forwarded-For/X-Real-IP会被采信
+	PublicURL      string    `yaml:"public_url"`      // agent对外访问地址（如 https://agent.example.com），用于生成日志下载链接；为空时使用callback.domain
+}
+
+// TLSConfig HTTPS配置
+type TLSConfig struct {
+	CertFile       string `yaml:"cert_file"`       // 证书文件路径
+	KeyFile        string `yaml:"key_file"`        // 私钥文件路径
+	ReloadInterval string `yaml:"reload_interval"` // 检查证书更新的间隔（如 1m），为空表示不自动重新加载
+
+	// 双向TLS：配置CA后校验客户端证书
+	ClientCAFile     string   `yaml:"client_ca_file"`     // 签发客户端证书的CA
+	ClientAuthMode   string   `yaml:"client_auth_mode"`   // 变更接口的调用方认证: whitelist（默认）/mtls/both/either
+	ClientAllowedCNs []string `yaml:"client_allowed_cns"` // 允许的客户端证书CN，为空表示CA签发的均可
+}
+
+// Enabled 是否启用HTTPS
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" && t.KeyFile != ""
 }
 
 // RemoteConfig 远程服务配置
 type RemoteConfig struct {
 	UpdateURL string `yaml:"update_url"`
+	// 远程服务调用/callback和/task/cancel时的请求体加密方式：
+	// off（默认，只接受明文）、optional（明文和EncryptedRequest均可）、required（只接受EncryptedRequest）
+	RequestEncryption string `yaml:"request_encryption"`
 }
 
+// 请求体加密方式
+const (
+	RequestEncryptionOff      = "off"
+	RequestEncryptionOptional = "optional"
+	RequestEncryptionRequired = "required"
+)
+
 // HarborConfig Harbor配置
 type HarborConfig struct {
 	Online          string `yaml:"online"`
@@ -54,8 +123,19 @@ type SSHConfig struct {
 
 // CallbackConfig 回调配置
 type CallbackConfig struct {
-	Domain string `yaml:"domain"`
-	Path   string `yaml:"path"`
+	Domain    string                  `yaml:"domain"`
+	Path      string                  `yaml:"path"`
+	Signature CallbackSignatureConfig `yaml:"signature"`
+}
+
+// CallbackSignatureConfig 回调签名校验配置
+// 签名算法：HMAC-SHA256(secret, 时间戳 + "." + 请求体)，十六进制编码
+type CallbackSignatureConfig struct {
+	Enable    bool              `yaml:"enable"`
+	Tolerance string            `yaml:"tolerance"` // 允许的时间戳偏差，默认5m
+	Secrets   map[string]string `yaml:"secrets"`   // 密钥ID -> 密钥，不同中心服务使用不同密钥；未指定密钥ID时使用default
+
+	MaxBodySize string `yaml:"max_body_size"` // 校验签名时读取请求体的上限（如 1MB），超出时拒绝，默认1MB
 }
 
 // WebConfig Web部署配置
@@ -63,24 +143,92 @@ type WebConfig struct {
 	DownloadURL string `yaml:"download_url"`
 	DownloadDir string `yaml:"download_dir"`
 	WebDir      string `yaml:"web_dir"`
+
+	DownloadTimeout string `yaml:"download_timeout"` // 下载产物的超时时间，默认30m
 }
 
 // WhitelistConfig IP白名单配置
 type WhitelistConfig struct {
-	Domains        []string `yaml:"domains"`
-	UpdateInterval string   `yaml:"update_interval"`
+	Domains        []string            `yaml:"domains"`
+	UpdateInterval string              `yaml:"update_interval"`
+	Lists          map[string][]string `yaml:"lists"`         // 按接口分组的白名单（update/callback/cancel/logs/admin），条目可用 @default 或 @其他名单 引用；未配置的分组使用domains
+	DNSCacheTTL    string              `yaml:"dns_cache_ttl"` // 域名解析结果缓存时间，默认5m
+	DNSRetries     int                 `yaml:"dns_retries"`   // 单次解析失败重试次数，默认2
 }
 
+// DefaultWhitelistName 默认白名单（whitelist.domains）的名称
+const DefaultWhitelistName = "default"
+
 // ProjectsConfig 项目配置
 type ProjectsConfig struct {
-	ValidNames []string `yaml:"valid_names"`
-	WebKeyword string   `yaml:"web_keyword"`
+	ValidNames []string                         `yaml:"valid_names"`
+	WebKeyword string                           `yaml:"web_keyword"`
+	Overrides  map[string]ProjectOverrideConfig `yaml:"overrides"` // 项目名 -> 流水线覆盖配置
+}
+
+// ProjectOverrideConfig 项目的流水线覆盖配置：跳过的步骤在步骤通知中报告为skipped，参数覆盖流水线中的同名参数
+type ProjectOverrideConfig struct {
+	Skip   []string                     `yaml:"skip"`   // 跳过的步骤类型（如 trafficSwitching）
+	Params map[string]map[string]string `yaml:"params"` // 步骤类型 -> 参数（如 checkService: {health_path: /health}）
 }
 
 // DeploymentConfig 部署配置
 type DeploymentConfig struct {
 	Double map[string]string `yaml:"double"` // 支持AB版本切换的项目
 	Single map[string]string `yaml:"single"` // 单版本项目
+
+	// 外部命令（kubectl/docker/ssh）的默认超时，默认10m；步骤自行设置的超时优先
+	CommandTimeout string `yaml:"command_timeout"`
+	// 执行外部命令时追加的环境变量（如KUBECONFIG、DOCKER_CONFIG）
+	CommandEnv map[string]string `yaml:"command_env"`
+
+	// 所有任务共享的docker/kubectl操作并发上限（按权重累计），默认20
+	OperationLimit int `yaml:"operation_limit"`
+	// 各类操作占用的权重（pull/push/apply），默认均为1
+	OperationWeights map[string]int `yaml:"operation_weights"`
+	// 同时执行的部署任务上限，超出的任务排队等待，默认0不限制
+	MaxRunningTasks int `yaml:"max_running_tasks"`
+	// 回调去重窗口：窗口内相同项目、标签和任务ID的回调只受理一次，默认10m
+	CallbackDedupWindow string `yaml:"callback_dedup_window"`
+
+	// 镜像传输（拉取/推送）限速
+	Transfer TransferConfig `yaml:"transfer"`
+
+	// 部署窗口与封网
+	Window DeployWindowConfig `yaml:"window"`
+
+	// 批量部署
+	Batch BatchConfig `yaml:"batch"`
+
+	// 项目依赖：项目名 -> 需要先部署的上游项目；批量部署时按依赖顺序执行，上游失败时下游直接跳过
+	Dependencies map[string][]string `yaml:"dependencies"`
+
+	// 部署目录使用本地git仓库记录步骤13对YAML的修改（每个任务一次提交），默认true
+	TrackManifests *bool `yaml:"track_manifests"`
+}
+
+// BatchConfig 批量部署配置
+type BatchConfig struct {
+	Mode     string `yaml:"mode"`      // parallel并行（默认）/sequential按请求顺序依次部署，失败后停止
+	MaxItems int    `yaml:"max_items"` // 单个批次的最大项目数，默认20
+}
+
+// DeployWindowConfig 部署窗口配置：回调只在项目的部署窗口内且未封网时开始部署
+// 卡片触发的重试、回滚属于人工操作，不受限制
+type DeployWindowConfig struct {
+	Windows       map[string][]string `yaml:"windows"`        // 项目名 -> 允许开始部署的时间（cron表达式：分 时 日 月 周），"*"表示所有项目；未配置的项目不限制
+	OutsideAction string              `yaml:"outside_action"` // 窗口外或封网期间到达的回调：queue排队到下一个窗口（默认）/reject拒绝并通知
+	FreezeFile    string              `yaml:"freeze_file"`    // 封网状态文件，默认freeze.json
+}
+
+// TransferConfig 镜像传输限速配置
+// bandwidth_limit和interface都配置时，镜像传输期间在网卡上用tc限制出入带宽（需要root权限），全部传输结束后移除
+type TransferConfig struct {
+	MaxParallelPulls  int    `yaml:"max_parallel_pulls"`  // 单个任务同时拉取的镜像数，默认20
+	MaxParallelPushes int    `yaml:"max_parallel_pushes"` // 单个任务同时推送的镜像数，默认20
+	BandwidthLimit    string `yaml:"bandwidth_limit"`     // tc速率（如 50mbit）
+	BandwidthBurst    string `yaml:"bandwidth_burst"`     // tc突发大小，默认1mb
+	Interface         string `yaml:"interface"`           // 限速的网卡（如 eth0）
 }
 
 // NotificationConfig 通知配置
@@ -88,17 +236,504 @@ type NotificationConfig struct {
 	Enable         bool   `yaml:"enable"`
 	NotifyURL      string `yaml:"notify_url"`
 	EncryptionSalt string `yaml:"encryption_salt"`
+
+	// 密钥轮换：配置encryption_keys后，加密使用active_key_id对应的密钥（HKDF派生，密文携带密钥ID），
+	// 解密时按密文中的密钥ID查找，列表中的旧密钥在轮换期间仍可解密；未配置时沿用encryption_salt原始密钥
+	EncryptionKeys []EncryptionKeyConfig `yaml:"encryption_keys"`
+	ActiveKeyID    string                `yaml:"active_key_id"` // 为空时使用列表中的第一个密钥
+	// 配置encryption_keys后仍接受旧格式（encryption_salt原始密钥）的密文，默认不接受，仅在迁移期间临时开启
+	AllowLegacyDecrypt bool `yaml:"allow_legacy_decrypt"`
+
+	// 步骤通知去重窗口：窗口内相同任务、步骤和状态的通知只发送一次，默认5s，0表示不去重
+	DedupWindow string `yaml:"dedup_window"`
+
+	// 每个步骤保留的历史耗时次数，预计耗时取截尾移动平均，默认10
+	DurationHistory int `yaml:"duration_history"`
+
+	// 通知中心发送失败时写入磁盘队列，后台按指数退避重试
+	Retry NotificationRetryConfig `yaml:"retry"`
+
+	// 按项目选择通知渠道（notify/feishu/dingtalk/wecom/slack/email），"*"为默认；未配置时发送全部渠道
+	Channels map[string][]string `yaml:"channels"`
+
+	// 按事件级别选择通知渠道，项目名 -> 路由（"*"为默认），配置后优先于channels
+	// info级别：开始、成功；critical级别：失败、取消
+	Routing map[string]NotificationRoute `yaml:"routing"`
+
+	// 飞书机器人加签密钥（安全设置为"签名校验"时填写），机器人地址或hook ID -> 密钥
+	FeishuSecrets map[string]string `yaml:"feishu_secrets"`
+
+	// 飞书卡片模板，项目名 -> 模板（"*"为默认模板）
+	FeishuTemplates map[string]FeishuCardTemplate `yaml:"feishu_templates"`
+
+	// 群聊机器人渠道，与飞书卡片同时发送
+	DingTalk ChatChannelConfig `yaml:"dingtalk"`
+	WeCom    ChatChannelConfig `yaml:"wecom"`
+	Slack    SlackConfig       `yaml:"slack"`
+
+	// 邮件通知
+	Email EmailConfig `yaml:"email"`
+
+	// 定时部署汇总报告
+	Report ReportConfig `yaml:"report"`
+
+	// 飞书卡片交互按钮（批准流量切换、回滚、重试）
+	CardActions CardActionConfig `yaml:"card_actions"`
+}
+
+// CardActionConfig 飞书卡片交互配置
+// 按钮回调地址为 /api/v1/feishu/card-action（在飞书应用的"消息卡片请求网址"中配置）
+// 回调通过飞书Verification Token和按钮值中的HMAC签名双重校验，不经过IP白名单
+type CardActionConfig struct {
+	Enable            bool     `yaml:"enable"`
+	Secret            string   `yaml:"secret"`             // 按钮值签名密钥
+	VerificationToken string   `yaml:"verification_token"` // 飞书应用的Verification Token
+	TokenTTL          string   `yaml:"token_ttl"`          // 按钮有效期，默认24h
+	ApprovalProjects  []string `yaml:"approval_projects"`  // 流量切换前需要审批的双版本项目，"*"表示所有项目
+	ApprovalTimeout   string   `yaml:"approval_timeout"`   // 等待审批的最长时间，超时视为拒绝，默认30m
+	Operators         []string `yaml:"operators"`          // 允许操作的飞书用户open_id，开启时必须配置
+}
+
+// NotificationRoute 按事件级别配置的通知渠道
+type NotificationRoute struct {
+	Info     []string `yaml:"info"`     // 开始、成功事件的渠道（如安静的通知群）
+	Critical []string `yaml:"critical"` // 失败、取消事件的渠道（如告警群、邮件）
+}
+
+// ReportConfig 部署汇总报告配置（统计范围受日志保留天数限制）
+type ReportConfig struct {
+	Enable        bool     `yaml:"enable"`
+	Period        string   `yaml:"period"`         // daily（默认，统计最近24小时）/weekly（统计最近7天）
+	Time          string   `yaml:"time"`           // 发送时间 HH:MM，默认09:00
+	Weekday       string   `yaml:"weekday"`        // 每周报告的发送日（monday~sunday），默认monday
+	FeishuWebhook string   `yaml:"feishu_webhook"` // 接收报告的飞书机器人
+	Email         []string `yaml:"email"`          // 接收报告的邮箱（使用notification.email的SMTP配置）
+}
+
+// 报告周期
+const (
+	ReportDaily  = "daily"
+	ReportWeekly = "weekly"
+)
+
+// GetReportPeriod 获取报告周期
+func (r ReportConfig) GetReportPeriod() string {
+	if r.Period == ReportWeekly {
+		return ReportWeekly
+	}
+	return ReportDaily
+}
+
+// GetReportTime 获取报告发送时间（时、分）
+func (r ReportConfig) GetReportTime() (int, int) {
+	if t, err := time.Parse("15:04", r.Time); err == nil {
+		return t.Hour(), t.Minute()
+	}
+	return 9, 0
+}
+
+// GetReportWeekday 获取每周报告的发送日
+func (r ReportConfig) GetReportWeekday() time.Weekday {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(day.String(), r.Weekday) {
+			return day
+		}
+	}
+	return time.Monday
+}
+
+// EmailConfig 邮件通知配置
+type EmailConfig struct {
+	SMTPHost string              `yaml:"smtp_host"`
+	SMTPPort int                 `yaml:"smtp_port"` // 默认25；465端口使用SMTPS
+	Username string              `yaml:"username"`
+	Password string              `yaml:"password"`
+	From     string              `yaml:"from"`
+	To       []string            `yaml:"to"`       // 默认收件人
+	Projects map[string][]string `yaml:"projects"` // 启用邮件的项目 -> 收件人（为空时使用to），"*"表示所有项目
+}
+
+// RecipientsFor 获取项目的邮件收件人，项目未启用邮件时返回空
+func (e EmailConfig) RecipientsFor(project string) []string {
+	recipients, ok := e.Projects[project]
+	if !ok {
+		recipients, ok = e.Projects["*"]
+	}
+	if !ok {
+		return nil
+	}
+	if len(recipients) > 0 {
+		return recipients
+	}
+	return e.To
+}
+
+// GetSMTPPort 获取SMTP端口
+func (e EmailConfig) GetSMTPPort() int {
+	if e.SMTPPort > 0 {
+		return e.SMTPPort
+	}
+	return 25
+}
+
+// SlackConfig Slack通知配置
+// 使用Incoming Webhook时频道由webhook决定；配置bot_token时通过chat.postMessage发送到项目对应的频道
+type SlackConfig struct {
+	ChatChannelConfig `yaml:",inline"`
+	BotToken          string            `yaml:"bot_token"`        // Bot User OAuth Token（xoxb-开头）
+	DefaultChannel    string            `yaml:"default_channel"`  // 未单独配置频道的项目使用的频道
+	IncidentChannel   string            `yaml:"incident_channel"` // 失败、取消事件使用的频道，为空时与其他事件相同
+	Channels          map[string]string `yaml:"channels"`         // 项目名 -> 频道（如 #deploy-order）
+}
+
+// ChannelFor 获取项目对应的Slack频道
+func (s SlackConfig) ChannelFor(project string) string {
+	if channel, ok := s.Channels[project]; ok && channel != "" {
+		return channel
+	}
+	return s.DefaultChannel
+}
+
+// NotificationRetryConfig 通知重试队列配置
+type NotificationRetryConfig struct {
+	Dir        string `yaml:"dir"`         // 队列目录，默认notify_queue
+	MaxAge     string `yaml:"max_age"`     // 超过该时间仍未送达的通知被丢弃，默认24h
+	MinBackoff string `yaml:"min_backoff"` // 首次重试间隔，默认5s
+	MaxBackoff string `yaml:"max_backoff"` // 最大重试间隔，默认10m
+}
+
+// FeishuCardTemplate 飞书卡片模板（标题和字段值为Go模板，可引用 .Project .ProjectName .Tag .Status
+// .StatusText .Duration .StartTime .EndTime .TypeLabel .Category .Emoji 以及 .Vars 中的自定义变量）
+type FeishuCardTemplate struct {
+	Titles      map[string]string `yaml:"titles"`       // 状态(complete/failed/cancel) -> 标题模板
+	Colors      map[string]string `yaml:"colors"`       // 状态 -> 卡片颜色（green/red/grey/blue/orange等）
+	Emojis      map[string]string `yaml:"emojis"`       // 状态 -> 标题emoji，模板中通过 .Emoji 引用
+	Vars        map[string]string `yaml:"vars"`         // 自定义变量，如 owner、environment
+	ExtraFields []FeishuCardField `yaml:"extra_fields"` // 追加的字段
+	HideFields  []string          `yaml:"hide_fields"`  // 隐藏的默认字段（按字段名，如 额外参数）
+}
+
+// FeishuCardField 飞书卡片自定义字段
+type FeishuCardField struct {
+	Label string `yaml:"label"` // 字段名
+	Value string `yaml:"value"` // 字段值模板，如 [变更单]({{.Vars.ticket_url}})
+	Short *bool  `yaml:"short"` // 是否半宽显示，默认true
+}
+
+// GetFeishuTemplate 获取项目的飞书卡片模板
+func (c *Config) GetFeishuTemplate(project string) (FeishuCardTemplate, bool) {
+	if tpl, ok := c.Notification.FeishuTemplates[project]; ok {
+		return tpl, true
+	}
+	tpl, ok := c.Notification.FeishuTemplates["*"]
+	return tpl, ok
+}
+
+// GetFeishuSecret 获取飞书机器人的加签密钥，先按完整地址查找，再按地址末尾的hook ID查找
+func (c *Config) GetFeishuSecret(webhookURL string) string {
+	if secret, ok := c.Notification.FeishuSecrets[webhookURL]; ok {
+		return secret
+	}
+	hookID := webhookURL[strings.LastIndex(webhookURL, "/")+1:]
+	return c.Notification.FeishuSecrets[hookID]
+}
+
+// ChatChannelConfig 群聊机器人渠道配置
+type ChatChannelConfig struct {
+	Webhook     string            `yaml:"webhook"`      // 默认机器人地址
+	Secret      string            `yaml:"secret"`       // 加签密钥（钉钉安全设置为"加签"时填写）
+	MsgType     string            `yaml:"msg_type"`     // 消息类型（钉钉: markdown/actionCard），默认markdown
+	ActionURL   string            `yaml:"action_url"`   // 卡片跳转地址（如部署平台任务页），钉钉actionCard和企业微信模板卡片必须配置
+	NotifyStart bool              `yaml:"notify_start"` // 任务排队、开始时也发送通知
+	Projects    map[string]string `yaml:"projects"`     // 启用该渠道的项目 -> 机器人地址（为空时使用webhook），"*"表示所有项目
+
+	IncidentWebhook string `yaml:"incident_webhook"` // 失败、取消事件使用的机器人地址（告警群），为空时与其他事件相同
+}
+
+// WebhookFor 获取项目在该渠道使用的机器人地址，项目未启用该渠道时返回空
+func (ch ChatChannelConfig) WebhookFor(project string) string {
+	webhook, ok := ch.Projects[project]
+	if !ok {
+		webhook, ok = ch.Projects["*"]
+	}
+	if !ok {
+		return ""
+	}
+	if webhook != "" {
+		return webhook
+	}
+	return ch.Webhook
+}
+
+// EncryptionKeyConfig 加密密钥配置
+type EncryptionKeyConfig struct {
+	ID     string `yaml:"id"`     // 密钥ID，写入密文头部（不能包含"."）
+	Secret string `yaml:"secret"` // 密钥材料，经HKDF-SHA256派生出AES-256密钥
 }
 
 // TrafficProxyConfig 流量代理配置
 type TrafficProxyConfig struct {
-	Enable bool     `yaml:"enable"`
-	JXH    []string `yaml:"jxh"`
-	YSH    []string `yaml:"ysh"`
+	Enable   bool                `yaml:"enable"`
+	Projects map[string][]string `yaml:",inline"` // 项目名 -> 代理地址列表（如 jxh、ysh）
+}
+
+// WebSocketConfig 日志流连接配置（WebSocket/SSE）
+type WebSocketConfig struct {
+	MaxConnections int    `yaml:"max_connections"` // 全局最大日志连接数，默认200
+	MaxPerTask     int    `yaml:"max_per_task"`    // 单个任务最大日志连接数，默认20
+	PingInterval   string `yaml:"ping_interval"`   // ping保活间隔，默认30s
+	WriteTimeout   string `yaml:"write_timeout"`   // 单次写超时，默认10s
+	IdleTimeout    string `yaml:"idle_timeout"`    // 未收到pong/消息的空闲超时，默认75s
+}
+
+// LoggingConfig 任务日志配置
+type LoggingConfig struct {
+	RetentionDays int    `yaml:"retention_days"` // 任务日志保留天数，默认7天
+	CleanupTime   string `yaml:"cleanup_time"`   // 每日清理时间(HH:MM)，默认02:00
+	MaxTotalSize  string `yaml:"max_total_size"` // 日志目录总大小上限(如 10GB、500MB)，为空表示不限制
+	Compress      *bool  `yaml:"compress"`       // 任务结束后是否gzip压缩日志，默认开启
+	CompressDelay string `yaml:"compress_delay"` // 任务结束后延迟多久压缩，默认10m
+
+	Format    string          `yaml:"format"`     // 服务日志格式: text（默认）/json
+	Level     string          `yaml:"level"`      // 服务日志最低输出级别: debug（默认，输出全部）/info/warning/error；启动参数--log-level优先
+	AccessLog AccessLogConfig `yaml:"access_log"` // HTTP访问日志
+}
+
+// AccessLogConfig HTTP访问日志配置
+type AccessLogConfig struct {
+	SampleRate float64  `yaml:"sample_rate"` // 采样率(0-1]，默认1即全部记录；5xx响应始终记录
+	SkipHealth bool     `yaml:"skip_health"` // 是否跳过/health和/ready健康检查请求
+	SkipPaths  []string `yaml:"skip_paths"`  // 其他不记录的路径
+}
+
+// AuditConfig 审计日志配置
+type AuditConfig struct {
+	File string `yaml:"file"` // 审计日志文件路径，默认audit/audit.log
+}
+
+// DebugConfig 调试接口配置
+type DebugConfig struct {
+	AdminToken string `yaml:"admin_token"` // 管理令牌，非白名单IP可凭此令牌访问调试接口
+}
+
+// MetricsConfig 指标接口配置
+type MetricsConfig struct {
+	Listen string `yaml:"listen"` // 独立监听地址（如 :9101），为空时挂在主服务端口上
+	Path   string `yaml:"path"`   // 指标路径，默认/metrics
+}
+
+// UIConfig 内置管理界面配置（/ui，界面调用的接口仍按各自的白名单和权限校验）
+type UIConfig struct {
+	Enable *bool `yaml:"enable"` // 是否提供管理界面，默认true
+}
+
+// CORSConfig 跨域配置（同时用于REST接口和WebSocket握手的Origin校验）
+type CORSConfig struct {
+	AllowedOrigins   []string `yaml:"allowed_origins"`   // 允许的来源，支持 * 和 *.example.com；为空时REST不返回跨域头、WebSocket允许任意来源
+	AllowedMethods   []string `yaml:"allowed_methods"`   // 默认 GET, POST, OPTIONS
+	AllowedHeaders   []string `yaml:"allowed_headers"`   // 默认 Content-Type, Authorization, X-Request-ID, X-API-Version
+	AllowCredentials bool     `yaml:"allow_credentials"` // 是否允许携带凭证
+	MaxAge           int      `yaml:"max_age"`           // 预检结果缓存秒数，默认600
+}
+
+// RateLimitConfig 限流配置（按客户端IP+接口的令牌桶）
+type RateLimitConfig struct {
+	Enable  bool                     `yaml:"enable"`
+	Default RateLimitRule            `yaml:"default"` // 未单独配置的接口使用的限制，rps为0表示不限流
+	Routes  map[string]RateLimitRule `yaml:"routes"`  // 按接口路径配置，如 /callback、/task/cancel（新旧路径共用）
+}
+
+// RateLimitRule 令牌桶参数
+type RateLimitRule struct {
+	RPS   float64 `yaml:"rps"`   // 每秒补充的令牌数
+	Burst int     `yaml:"burst"` // 桶容量，默认与rps相同（至少为1）
+}
+
+// ReplayConfig 防重放配置（加密的日志查看参数和签名回调）
+// 请求携带nonce和时间戳时，超出时间窗口或nonce重复的请求会被拒绝
+type ReplayConfig struct {
+	Window       string `yaml:"window"`        // 日志查看参数允许的时钟偏差，默认5m（签名回调使用callback.signature.tolerance）
+	CacheSize    int    `yaml:"cache_size"`    // 记录已使用nonce的数量上限，默认10000
+	RequireNonce bool   `yaml:"require_nonce"` // 为true时必须携带nonce和时间戳，否则只校验携带了的请求
+}
+
+// OutboundConfig 出站请求目标限制（通知接口、飞书等Webhook）
+// 回调参数中的Webhook地址由调用方提供，配置allowed_hosts后只允许向列表内的主机发送请求
+type OutboundConfig struct {
+	AllowedHosts   []string `yaml:"allowed_hosts"`   // 允许的主机名，支持 *.example.com 通配子域名；为空表示不限制主机
+	AllowedSchemes []string `yaml:"allowed_schemes"` // 允许的协议，默认http和https
+
+	// 出站HTTP请求（通知、Webhook、流量代理）的单次超时和重试
+	Timeout string `yaml:"timeout"` // 单次请求超时，默认10s
+	Retries int    `yaml:"retries"` // 网络错误或5xx/429响应时的重试次数，默认2，负数表示不重试
+}
+
+// EventBusConfig 事件总线配置：任务和步骤事件（JSON）发布到NATS或Kafka，供CMDB、审计、分析等系统订阅
+// NATS按项目发布到 {subject}.{项目} 主题；Kafka通过REST Proxy发布，消息key为项目名
+type EventBusConfig struct {
+	Enable bool   `yaml:"enable"`
+	Type   string `yaml:"type"`  // nats/kafka
+	Topic  string `yaml:"topic"` // NATS主题前缀或Kafka topic，默认cicd.deploy.events
+	Steps  *bool  `yaml:"steps"` // 是否发布步骤事件，默认true
+
+	NATS  NATSConfig  `yaml:"nats"`
+	Kafka KafkaConfig `yaml:"kafka"`
+}
+
+// NATSConfig NATS连接配置
+type NATSConfig struct {
+	URL      string `yaml:"url"`   // nats://host:4222，tls://host:4222使用TLS
+	Token    string `yaml:"token"` // 令牌认证
+	User     string `yaml:"user"`  // 用户名密码认证
+	Password string `yaml:"password"`
+}
+
+// KafkaConfig Kafka REST Proxy配置（v2接口：POST /topics/{topic}）
+type KafkaConfig struct {
+	RestURL string            `yaml:"rest_url"` // REST Proxy地址，如 http://kafka-rest:8082
+	Headers map[string]string `yaml:"headers"`  // 额外请求头（如认证）
+}
+
+// WebhooksConfig 代码托管平台的Webhook直接触发部署（不经过中心服务）
+type WebhooksConfig struct {
+	GitLab  GitLabWebhookConfig  `yaml:"gitlab"`
+	GitHub  GitHubWebhookConfig  `yaml:"github"`
+	Jenkins JenkinsWebhookConfig `yaml:"jenkins"`
+}
+
+// GitLabWebhookConfig GitLab Webhook配置（Pipeline Hook / Tag Push Hook），请求由X-Gitlab-Token校验
+type GitLabWebhookConfig struct {
+	Enable   bool              `yaml:"enable"`
+	Tokens   map[string]string `yaml:"tokens"`   // GitLab项目路径（path_with_namespace）-> Secret Token，"*"为默认
+	Projects map[string]string `yaml:"projects"` // GitLab项目路径 -> agent项目名，未配置时使用GitLab项目路径的最后一段
+	Trigger  string            `yaml:"trigger"`  // pipeline（默认）：流水线成功后部署；tag_push：推送标签即部署（镜像由其他方式预先构建）
+	Branches []string          `yaml:"branches"` // 流水线触发时允许部署的分支（镜像标签为提交短SHA），默认只部署标签流水线
+}
+
+// TokenFor 获取GitLab项目的Secret Token
+func (g GitLabWebhookConfig) TokenFor(path string) string {
+	if token, ok := g.Tokens[path]; ok {
+		return token
+	}
+	return g.Tokens["*"]
+}
+
+// ProjectFor 获取GitLab项目对应的agent项目名
+func (g GitLabWebhookConfig) ProjectFor(path string) string {
+	if project, ok := g.Projects[path]; ok && project != "" {
+		return project
+	}
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+// GetTrigger 获取GitLab触发部署的事件，默认pipeline
+func (g GitLabWebhookConfig) GetTrigger() string {
+	if g.Trigger == "tag_push" {
+		return "tag_push"
+	}
+	return "pipeline"
+}
+
+// GitHubWebhookConfig GitHub（含GitHub Enterprise）Webhook配置（release / workflow_run），请求由X-Hub-Signature-256校验
+type GitHubWebhookConfig struct {
+	Enable    bool              `yaml:"enable"`
+	Secrets   map[string]string `yaml:"secrets"`   // 仓库（owner/repo）-> Webhook Secret，"*"为默认
+	Repos     map[string]string `yaml:"repos"`     // 仓库（owner/repo）-> agent项目名，未配置时使用仓库名
+	Trigger   string            `yaml:"trigger"`   // release（默认）：发布Release时部署；workflow_run：工作流成功后部署
+	Workflows []string          `yaml:"workflows"` // workflow_run触发时只处理这些工作流（名称），为空时处理全部
+	Branches  []string          `yaml:"branches"`  // workflow_run触发时允许部署的分支（镜像标签为提交短SHA），Release触发的工作流以标签部署
+}
+
+// SecretFor 获取仓库的Webhook Secret
+func (g GitHubWebhookConfig) SecretFor(repo string) string {
+	if secret, ok := g.Secrets[repo]; ok {
+		return secret
+	}
+	return g.Secrets["*"]
+}
+
+// ProjectFor 获取仓库对应的agent项目名
+func (g GitHubWebhookConfig) ProjectFor(repo string) string {
+	if project, ok := g.Repos[repo]; ok && project != "" {
+		return project
+	}
+	return repo[strings.LastIndex(repo, "/")+1:]
+}
+
+// GetTrigger 获取GitHub触发部署的事件，默认release
+func (g GitHubWebhookConfig) GetTrigger() string {
+	if g.Trigger == "workflow_run" {
+		return "workflow_run"
+	}
+	return "release"
+}
+
+// JenkinsWebhookConfig Jenkins构建通知配置（Notification插件的构建JSON或Generic Webhook插件的请求体），迁移期间旧任务直接调用agent
+// 认证方式与Jenkins远程触发一致：查询参数token（任务的远程触发令牌），或 用户名+API Token 的Basic认证（需携带Jenkins-Crumb）
+type JenkinsWebhookConfig struct {
+	Enable       bool              `yaml:"enable"`
+	Tokens       map[string]string `yaml:"tokens"`        // Jenkins任务名 -> 远程触发令牌，"*"为默认
+	Users        map[string]string `yaml:"users"`         // Jenkins用户名 -> API Token（Basic认证）
+	RequireCrumb *bool             `yaml:"require_crumb"` // Basic认证时是否要求Jenkins-Crumb请求头，默认true
+	Jobs         map[string]string `yaml:"jobs"`          // Jenkins任务名 -> agent项目名，未配置时使用任务名的最后一段
+	TagParam     string            `yaml:"tag_param"`     // 携带镜像标签的构建参数名，默认TAG
+}
+
+// TokenFor 获取Jenkins任务的远程触发令牌
+func (j JenkinsWebhookConfig) TokenFor(job string) string {
+	if token, ok := j.Tokens[job]; ok {
+		return token
+	}
+	return j.Tokens["*"]
+}
+
+// ProjectFor 获取Jenkins任务对应的agent项目名（文件夹中的任务取最后一段）
+func (j JenkinsWebhookConfig) ProjectFor(job string) string {
+	if project, ok := j.Jobs[job]; ok && project != "" {
+		return project
+	}
+	return job[strings.LastIndex(job, "/")+1:]
+}
+
+// CrumbRequired Basic认证时是否要求Jenkins-Crumb，默认true
+func (j JenkinsWebhookConfig) CrumbRequired() bool {
+	return j.RequireCrumb == nil || *j.RequireCrumb
+}
+
+// GetTagParam 获取携带镜像标签的构建参数名，默认TAG
+func (j JenkinsWebhookConfig) GetTagParam() string {
+	if j.TagParam == "" {
+		return "TAG"
+	}
+	return j.TagParam
+}
+
+// AuthConfig 接口令牌认证配置（在IP白名单等网络校验之外额外要求凭证）
+// 权限范围: deploy（/update、/callback）、cancel、logs（日志查看与检索）、admin（全部）
+type AuthConfig struct {
+	Enable  bool           `yaml:"enable"`
+	APIKeys []APIKeyConfig `yaml:"api_keys"` // 静态API Key
+	JWT     JWTConfig      `yaml:"jwt"`
+}
+
+// APIKeyConfig 静态API Key
+type APIKeyConfig struct {
+	Name   string   `yaml:"name"` // 调用方名称，用于日志和审计
+	Key    string   `yaml:"key"`
+	Scopes []string `yaml:"scopes"`
+}
+
+// JWTConfig JWT校验配置（HS256）
+type JWTConfig struct {
+	Secret string `yaml:"secret"` // 签名密钥，为空表示不接受JWT
+	Issuer string `yaml:"issuer"` // 期望的签发者，为空不校验
 }
 
 var AppConfig *Config
 
+// loadedConfigPath 最近一次加载的配置文件路径（供重新加载使用）
+var loadedConfigPath string
+
 // LoadConfig 从YAML文件加载配置
 func LoadConfig(configPath string) (*Config, error) {
 	if configPath == "" {
@@ -115,13 +750,110 @@ func LoadConfig(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("解析配置文件失败: %v", err)
 	}
 
-	// 初始化完成后无需特殊处理
+	if config.PipelinesDir != "" {
+		pipelines, err := loadPipelineDir(config.PipelinesDir)
+		if err != nil {
+			return nil, err
+		}
+		config.Pipelines = append(config.Pipelines, pipelines...)
+	}
+	vaultValues, err := resolveVaultRefs(config)
+	if err != nil {
+		return nil, err
+	}
+	if err := validatePipelines(config.Pipelines); err != nil {
+		return nil, fmt.Errorf("流水线配置错误: %v", err)
+	}
+	if err := validateDependencies(config.Deployment.Dependencies); err != nil {
+		return nil, fmt.Errorf("项目依赖配置错误: %v", err)
+	}
+	for project, windows := range config.Deployment.Window.Windows {
+		for _, expr := range windows {
+			if _, err := ParseCron(expr); err != nil {
+				return nil, fmt.Errorf("项目 %s 的部署窗口配置错误: %v", project, err)
+			}
+		}
+	}
+
+	switch strings.ToLower(config.Logging.Level) {
+	case "", "debug", "info", "warn", "warning", "error":
+	default:
+		return nil, fmt.Errorf("日志级别错误: %s（支持debug/info/warning/error）", config.Logging.Level)
+	}
+	switch strings.ToLower(strings.TrimSpace(config.Locale)) {
+	case "", "zh", "en":
+	default:
+		return nil, fmt.Errorf("语言配置错误: %s（支持zh/en）", config.Locale)
+	}
+	if value := config.Callback.Signature.MaxBodySize; value != "" {
+		if size, err := parseByteSize(value); err != nil || size <= 0 {
+			return nil, fmt.Errorf("callback.signature.max_body_size格式错误: %s", value)
+		}
+	}
+	if config.Notification.CardActions.Enable && len(config.Notification.CardActions.Operators) == 0 {
+		return nil, fmt.Errorf("开启notification.card_actions时需要配置operators（允许操作的飞书用户open_id）")
+	}
+	if config.EventBus.Enable && config.EventBus.Type != "nats" && config.EventBus.Type != "kafka" {
+		return nil, fmt.Errorf("事件总线类型错误: %s（支持nats/kafka）", config.EventBus.Type)
+	}
+	if err := validateGitOps(config.GitOps); err != nil {
+		return nil, err
+	}
+	if err := validateNacos(config.Nacos); err != nil {
+		return nil, err
+	}
+	if err := validateApollo(config.Apollo); err != nil {
+		return nil, err
+	}
+	if err := validateMigration(config.Migration); err != nil {
+		return nil, err
+	}
+	if err := validateRedis(config.Redis); err != nil {
+		return nil, err
+	}
+	if err := validateSmokeTest(config.SmokeTest); err != nil {
+		return nil, err
+	}
+	if err := validatePerfGate(config.PerfGate); err != nil {
+		return nil, err
+	}
+	if err := validateWarmup(config.Warmup); err != nil {
+		return nil, err
+	}
+	if err := validateDrain(config.Drain); err != nil {
+		return nil, err
+	}
+	if err := validateConfigSync(config.ConfigSync); err != nil {
+		return nil, err
+	}
+	if err := validateVault(config.Vault); err != nil {
+		return nil, err
+	}
+	if err := validateLeaderElection(config.LeaderElection); err != nil {
+		return nil, err
+	}
+	if err := validateRouting(config.Routing); err != nil {
+		return nil, err
+	}
+	if err := validateReadiness(config.Readiness); err != nil {
+		return nil, err
+	}
+	if err := writeVaultFiles(config.Vault, vaultValues); err != nil {
+		return nil, err
+	}
+	saveVaultState(config.Vault, vaultValues)
 
 	AppConfig = config
+	loadedConfigPath = configPath
 	log.Printf("配置加载成功: %s", configPath)
 	return AppConfig, nil
 }
 
+// ReloadConfig 重新加载最近一次加载的配置文件
+func ReloadConfig() (*Config, error) {
+	return LoadConfig(loadedConfigPath)
+}
+
 // GetEncryptionSalt 获取加密盐值
 func GetEncryptionSalt() string {
 	if AppConfig != nil && AppConfig.Notification.EncryptionSalt != "" {
@@ -130,14 +862,161 @@ func GetEncryptionSalt() string {
 	return "DqJHGSTaw11yWhyjhMmiX1hgd3AoYARg" // 默认值
 }
 
+// GetNotifyQueueDir 获取通知重试队列目录
+func (c *Config) GetNotifyQueueDir() string {
+	if c.Notification.Retry.Dir != "" {
+		return c.Notification.Retry.Dir
+	}
+	return "notify_queue"
+}
+
+// GetNotifyRetryMaxAge 获取通知最长重试时间
+func (c *Config) GetNotifyRetryMaxAge() time.Duration {
+	return parseDurationOrDefault(c.Notification.Retry.MaxAge, 24*time.Hour)
+}
+
+// GetNotifyRetryBackoff 获取通知重试的最小和最大间隔
+func (c *Config) GetNotifyRetryBackoff() (time.Duration, time.Duration) {
+	return parseDurationOrDefault(c.Notification.Retry.MinBackoff, 5*time.Second),
+		parseDurationOrDefault(c.Notification.Retry.MaxBackoff, 10*time.Minute)
+}
+
+// CardActionsEnabled 是否启用飞书卡片交互按钮（必须配置签名密钥）
+func (c *Config) CardActionsEnabled() bool {
+	return c.Notification.CardActions.Enable && c.Notification.CardActions.Secret != ""
+}
+
+// GetCardActionTokenTTL 获取卡片按钮有效期
+func (c *Config) GetCardActionTokenTTL() time.Duration {
+	return parseDurationOrDefault(c.Notification.CardActions.TokenTTL, 24*time.Hour)
+}
+
+// GetApprovalTimeout 获取流量切换审批超时时间
+func (c *Config) GetApprovalTimeout() time.Duration {
+	return parseDurationOrDefault(c.Notification.CardActions.ApprovalTimeout, 30*time.Minute)
+}
+
+// RequiresTrafficApproval 项目流量切换前是否需要审批
+func (c *Config) RequiresTrafficApproval(project string) bool {
+	if !c.CardActionsEnabled() {
+		return false
+	}
+	for _, name := range c.Notification.CardActions.ApprovalProjects {
+		if name == project || name == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// IsCardActionOperator 飞书用户是否允许操作卡片按钮，未配置operators时一律拒绝
+func (c *Config) IsCardActionOperator(openID string) bool {
+	if openID == "" {
+		return false
+	}
+	for _, operator := range c.Notification.CardActions.Operators {
+		if operator == openID {
+			return true
+		}
+	}
+	return false
+}
+
+// GetNotifyDedupWindow 获取步骤通知去重窗口
+func (c *Config) GetNotifyDedupWindow() time.Duration {
+	return parseDurationOrDefault(c.Notification.DedupWindow, 5*time.Second)
+}
+
+// GetDurationHistorySize 获取每个步骤保留的历史耗时次数
+func (c *Config) GetDurationHistorySize() int {
+	if c.Notification.DurationHistory > 0 {
+		return c.Notification.DurationHistory
+	}
+	return 10
+}
+
+// GetNotificationRoute 获取项目的事件级别路由
+func (c *Config) GetNotificationRoute(project string) (NotificationRoute, bool) {
+	if route, ok := c.Notification.Routing[project]; ok {
+		return route, true
+	}
+	route, ok := c.Notification.Routing["*"]
+	return route, ok
+}
+
+// GetNotificationChannels 获取项目启用的通知渠道，返回nil表示未限制
+func (c *Config) GetNotificationChannels(project string) []string {
+	if channels, ok := c.Notification.Channels[project]; ok {
+		return channels
+	}
+	if channels, ok := c.Notification.Channels["*"]; ok {
+		return channels
+	}
+	return nil
+}
+
+// GetEncryptionKeys 获取轮换密钥列表（忽略ID或密钥为空的条目）
+func GetEncryptionKeys() []EncryptionKeyConfig {
+	if AppConfig == nil {
+		return nil
+	}
+	var keys []EncryptionKeyConfig
+	for _, key := range AppConfig.Notification.EncryptionKeys {
+		if key.ID == "" || key.Secret == "" || strings.Contains(key.ID, ".") {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// LegacyDecryptAllowed 是否接受旧格式密文：未配置encryption_keys时始终接受，配置后需开启allow_legacy_decrypt
+func LegacyDecryptAllowed() bool {
+	return len(GetEncryptionKeys()) == 0 || AppConfig.Notification.AllowLegacyDecrypt
+}
+
+// GetActiveEncryptionKey 获取当前用于加密的密钥，未配置轮换密钥时返回false
+func GetActiveEncryptionKey() (EncryptionKeyConfig, bool) {
+	keys := GetEncryptionKeys()
+	if len(keys) == 0 {
+		return EncryptionKeyConfig{}, false
+	}
+	for _, key := range keys {
+		if key.ID == AppConfig.Notification.ActiveKeyID {
+			return key, true
+		}
+	}
+	return keys[0], true
+}
+
+// GetRequestEncryption 获取远程服务请求体加密方式，无效值按off处理
+func (c *Config) GetRequestEncryption() string {
+	switch strings.ToLower(strings.TrimSpace(c.Remote.RequestEncryption)) {
+	case RequestEncryptionOptional:
+		return RequestEncryptionOptional
+	case RequestEncryptionRequired:
+		return RequestEncryptionRequired
+	default:
+		return RequestEncryptionOff
+	}
+}
+
 // GetCallbackURL 获取完整的回调URL
 func (c *Config) GetCallbackURL() string {
 	return c.Callback.Domain + c.Callback.Path
 }
 
-// GetServerAddr 获取服务器监听地址
+// GetPublicURL 获取agent对外访问地址（不含末尾的/）
+func (c *Config) GetPublicURL() string {
+	if c.Server.PublicURL != "" {
+		return strings.TrimRight(c.Server.PublicURL, "/")
+	}
+	return strings.TrimRight(c.Callback.Domain, "/")
+}
+
+// GetServerAddr 获取服务器监听地址（IPv6地址自动加方括号）
 func (c *Config) GetServerAddr() string {
-	return c.Server.Host + ":" + c.Server.Port
+	return net.JoinHostPort(strings.Trim(c.Server.Host, "[]"), c.Server.Port)
 }
 
 // GetUpdateInterval 获取更新间隔时间
@@ -150,19 +1029,657 @@ func (c *Config) GetUpdateInterval() time.Duration {
 	return duration
 }
 
+// parseDurationOrDefault 解析时间间隔字符串，为空或格式错误时返回默认值
+func parseDurationOrDefault(value string, defaultValue time.Duration) time.Duration {
+	if value == "" {
+		return defaultValue
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		log.Printf("解析时间间隔 %q 失败，使用默认值%v: %v", value, defaultValue, err)
+		return defaultValue
+	}
+	return duration
+}
+
+// GetCommandTimeout 获取外部命令默认超时
+func (c *Config) GetCommandTimeout() time.Duration {
+	return parseDurationOrDefault(c.Deployment.CommandTimeout, 10*time.Minute)
+}
+
+// GetCommandEnv 获取执行外部命令时追加的环境变量（KEY=VALUE，按名称排序）
+func (c *Config) GetCommandEnv() []string {
+	env := make([]string, 0, len(c.Deployment.CommandEnv))
+	for key, value := range c.Deployment.CommandEnv {
+		env = append(env, key+"="+value)
+	}
+	sort.Strings(env)
+	return env
+}
+
+// GetOperationLimit 获取全局docker/kubectl操作并发上限
+func (c *Config) GetOperationLimit() int {
+	if c.Deployment.OperationLimit > 0 {
+		return c.Deployment.OperationLimit
+	}
+	return 20
+}
+
+// GetMaxRunningTasks 获取同时执行的任务上限，0表示不限制
+func (c *Config) GetMaxRunningTasks() int {
+	if c.Deployment.MaxRunningTasks > 0 {
+		return c.Deployment.MaxRunningTasks
+	}
+	return 0
+}
+
+// GetCallbackDedupWindow 获取回调去重窗口
+func (c *Config) GetCallbackDedupWindow() time.Duration {
+	return parseDurationOrDefault(c.Deployment.CallbackDedupWindow, 10*time.Minute)
+}
+
+// ManifestTrackingEnabled 是否用git记录部署目录中YAML的修改，默认开启
+func (c *Config) ManifestTrackingEnabled() bool {
+	return c.Deployment.TrackManifests == nil || *c.Deployment.TrackManifests
+}
+
+// UIEnabled 是否提供内置管理界面，默认开启
+func (c *Config) UIEnabled() bool {
+	return c.UI.Enable == nil || *c.UI.Enable
+}
+
+// GetOperationWeight 获取操作占用的权重，未配置时为1
+func (c *Config) GetOperationWeight(operation string) int {
+	if weight := c.Deployment.OperationWeights[operation]; weight > 0 {
+		return weight
+	}
+	return 1
+}
+
+// GetMaxParallelPulls 获取单个任务同时拉取的镜像数
+func (c *Config) GetMaxParallelPulls() int {
+	if c.Deployment.Transfer.MaxParallelPulls > 0 {
+		return c.Deployment.Transfer.MaxParallelPulls
+	}
+	return 20
+}
+
+// GetMaxParallelPushes 获取单个任务同时推送的镜像数
+func (c *Config) GetMaxParallelPushes() int {
+	if c.Deployment.Transfer.MaxParallelPushes > 0 {
+		return c.Deployment.Transfer.MaxParallelPushes
+	}
+	return 20
+}
+
+// BandwidthLimitEnabled 是否启用镜像传输限速
+func (c *Config) BandwidthLimitEnabled() bool {
+	return c.Deployment.Transfer.BandwidthLimit != "" && c.Deployment.Transfer.Interface != ""
+}
+
+// GetBandwidthBurst 获取tc突发大小
+func (c *Config) GetBandwidthBurst() string {
+	if c.Deployment.Transfer.BandwidthBurst != "" {
+		return c.Deployment.Transfer.BandwidthBurst
+	}
+	return "1mb"
+}
+
+// GetDeployWindows 获取项目的部署窗口（cron表达式），未配置时返回nil表示不限制
+func (c *Config) GetDeployWindows(project string) []string {
+	if windows, ok := c.Deployment.Window.Windows[project]; ok {
+		return windows
+	}
+	return c.Deployment.Window.Windows["*"]
+}
+
+// GetOutsideWindowAction 获取窗口外回调的处理方式：queue/reject
+func (c *Config) GetOutsideWindowAction() string {
+	if c.Deployment.Window.OutsideAction == "reject" {
+		return "reject"
+	}
+	return "queue"
+}
+
+// GetFreezeFile 获取封网状态文件路径
+func (c *Config) GetFreezeFile() string {
+	if c.Deployment.Window.FreezeFile != "" {
+		return c.Deployment.Window.FreezeFile
+	}
+	return "freeze.json"
+}
+
+// GetProjectDependencies 获取项目的上游依赖项目
+func (c *Config) GetProjectDependencies(project string) []string {
+	return c.Deployment.Dependencies[project]
+}
+
+// validateDependencies 检查项目依赖中是否存在环
+func validateDependencies(dependencies map[string][]string) error {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int)
+	var visit func(project string, path []string) error
+	visit = func(project string, path []string) error {
+		switch state[project] {
+		case visiting:
+			return fmt.Errorf("存在循环依赖: %s", strings.Join(append(path, project), " -> "))
+		case visited:
+			return nil
+		}
+		state[project] = visiting
+		for _, upstream := range dependencies[project] {
+			if err := visit(upstream, append(path, project)); err != nil {
+				return err
+			}
+		}
+		state[project] = visited
+		return nil
+	}
+
+	projects := make([]string, 0, len(dependencies))
+	for project := range dependencies {
+		projects = append(projects, project)
+	}
+	sort.Strings(projects)
+	for _, project := range projects {
+		if err := visit(project, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetBatchMode 获取批量部署方式：parallel/sequential
+func (c *Config) GetBatchMode() string {
+	if c.Deployment.Batch.Mode == "sequential" {
+		return "sequential"
+	}
+	return "parallel"
+}
+
+// GetBatchMaxItems 获取单个批次的最大项目数
+func (c *Config) GetBatchMaxItems() int {
+	if c.Deployment.Batch.MaxItems > 0 {
+		return c.Deployment.Batch.MaxItems
+	}
+	return 20
+}
+
+// GetWebDownloadTimeout 获取下载前端产物的超时时间
+func (c *Config) GetWebDownloadTimeout() time.Duration {
+	return parseDurationOrDefault(c.Web.DownloadTimeout, 30*time.Minute)
+}
+
+// GetWebSocketMaxConnections 获取全局最大日志连接数
+func (c *Config) GetWebSocketMaxConnections() int {
+	if c.WebSocket.MaxConnections > 0 {
+		return c.WebSocket.MaxConnections
+	}
+	return 200
+}
+
+// GetWebSocketMaxPerTask 获取单任务最大日志连接数
+func (c *Config) GetWebSocketMaxPerTask() int {
+	if c.WebSocket.MaxPerTask > 0 {
+		return c.WebSocket.MaxPerTask
+	}
+	return 20
+}
+
+// GetWebSocketPingInterval 获取ping保活间隔
+func (c *Config) GetWebSocketPingInterval() time.Duration {
+	return parseDurationOrDefault(c.WebSocket.PingInterval, 30*time.Second)
+}
+
+// GetWebSocketWriteTimeout 获取单次写超时
+func (c *Config) GetWebSocketWriteTimeout() time.Duration {
+	return parseDurationOrDefault(c.WebSocket.WriteTimeout, 10*time.Second)
+}
+
+// GetWebSocketIdleTimeout 获取空闲超时（至少为ping间隔的2倍）
+func (c *Config) GetWebSocketIdleTimeout() time.Duration {
+	idle := parseDurationOrDefault(c.WebSocket.IdleTimeout, 75*time.Second)
+	if minIdle := 2 * c.GetWebSocketPingInterval(); idle < minIdle {
+		return minIdle
+	}
+	return idle
+}
+
+// GetLogRetentionDays 获取任务日志保留天数
+func (c *Config) GetLogRetentionDays() int {
+	if c.Logging.RetentionDays > 0 {
+		return c.Logging.RetentionDays
+	}
+	return 7
+}
+
+// GetLogCleanupTime 获取每日日志清理时间（时, 分），默认凌晨2点
+func (c *Config) GetLogCleanupTime() (int, int) {
+	if c.Logging.CleanupTime == "" {
+		return 2, 0
+	}
+	t, err := time.Parse("15:04", c.Logging.CleanupTime)
+	if err != nil {
+		log.Printf("解析日志清理时间 %q 失败，使用默认值02:00: %v", c.Logging.CleanupTime, err)
+		return 2, 0
+	}
+	return t.Hour(), t.Minute()
+}
+
+// GetLogMaxTotalSize 获取日志目录总大小上限（字节），0表示不限制
+func (c *Config) GetLogMaxTotalSize() int64 {
+	if c.Logging.MaxTotalSize == "" {
+		return 0
+	}
+	size, err := parseByteSize(c.Logging.MaxTotalSize)
+	if err != nil {
+		log.Printf("解析日志目录大小上限 %q 失败，不限制大小: %v", c.Logging.MaxTotalSize, err)
+		return 0
+	}
+	return size
+}
+
+// IsLogCompressEnabled 是否压缩已结束任务的日志，默认开启
+func (c *Config) IsLogCompressEnabled() bool {
+	if c.Logging.Compress == nil {
+		return true
+	}
+	return *c.Logging.Compress
+}
+
+// GetLogCompressDelay 获取任务结束后压缩日志的延迟（留给查看中的客户端读完剩余日志），默认10分钟
+func (c *Config) GetLogCompressDelay() time.Duration {
+	return parseDurationOrDefault(c.Logging.CompressDelay, 10*time.Minute)
+}
+
+// GetLogFormat 获取服务日志格式
+func (c *Config) GetLogFormat() string {
+	if strings.ToLower(c.Logging.Format) == "json" {
+		return "json"
+	}
+	return "text"
+}
+
+// GetLocale 获取日志和通知使用的语言，未配置时为zh
+func (c *Config) GetLocale() string {
+	if c == nil {
+		return "zh"
+	}
+	if locale := strings.ToLower(strings.TrimSpace(c.Locale)); locale != "" {
+		return locale
+	}
+	return "zh"
+}
+
+// GetAccessLogSampleRate 获取访问日志采样率，未配置或非法时返回1
+func (c *Config) GetAccessLogSampleRate() float64 {
+	rate := c.Logging.AccessLog.SampleRate
+	if rate <= 0 || rate > 1 {
+		return 1
+	}
+	return rate
+}
+
+// ShouldSkipAccessLog 判断路径是否不记录访问日志
+func (c *Config) ShouldSkipAccessLog(path string) bool {
+	if c.Logging.AccessLog.SkipHealth && (path == "/health" || path == "/ready") {
+		return true
+	}
+	for _, skip := range c.Logging.AccessLog.SkipPaths {
+		if skip == path {
+			return true
+		}
+	}
+	return false
+}
+
+// GetTLSReloadInterval 获取证书重新加载检查间隔，0表示不自动重新加载
+func (c *Config) GetTLSReloadInterval() time.Duration {
+	if c.Server.TLS.ReloadInterval == "" {
+		return 0
+	}
+	return parseDurationOrDefault(c.Server.TLS.ReloadInterval, time.Minute)
+}
+
+// GetClientAuthMode 获取变更接口的调用方认证方式
+// whitelist: 仅IP白名单; mtls: 仅客户端证书; both: 两者都需通过; either: 任一通过即可
+func (c *Config) GetClientAuthMode() string {
+	switch mode := strings.ToLower(c.Server.TLS.ClientAuthMode); mode {
+	case "mtls", "both", "either":
+		if c.Server.TLS.ClientCAFile == "" {
+			log.Printf("未配置client_ca_file，调用方认证方式 %s 回退为whitelist", mode)
+			return "whitelist"
+		}
+		return mode
+	default:
+		return "whitelist"
+	}
+}
+
+// IsOriginAllowed 判断来源是否在跨域白名单中
+func (c *Config) IsOriginAllowed(origin string) bool {
+	for _, allowed := range c.CORS.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+		// 通配子域名：*.example.com 匹配 https://a.example.com
+		if strings.HasPrefix(allowed, "*.") {
+			host := origin
+			if idx := strings.Index(host, "://"); idx >= 0 {
+				host = host[idx+3:]
+			}
+			if idx := strings.LastIndex(host, ":"); idx >= 0 {
+				host = host[:idx]
+			}
+			if strings.HasSuffix(strings.ToLower(host), strings.ToLower(allowed[1:])) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GetCORSAllowedMethods 获取允许的跨域方法
+func (c *Config) GetCORSAllowedMethods() []string {
+	if len(c.CORS.AllowedMethods) > 0 {
+		return c.CORS.AllowedMethods
+	}
+	return []string{"GET", "POST", "OPTIONS"}
+}
+
+// GetCORSAllowedHeaders 获取允许的跨域请求头
+func (c *Config) GetCORSAllowedHeaders() []string {
+	if len(c.CORS.AllowedHeaders) > 0 {
+		return c.CORS.AllowedHeaders
+	}
+	return []string{"Content-Type", "Authorization", "X-Request-ID", "X-API-Version"}
+}
+
+// GetCORSMaxAge 获取预检结果缓存秒数
+func (c *Config) GetCORSMaxAge() int {
+	if c.CORS.MaxAge > 0 {
+		return c.CORS.MaxAge
+	}
+	return 600
+}
+
+// GetRateLimitRule 获取接口的限流规则，ok为false表示不限流
+// route为去掉/api/v1或/api前缀后的路径
+func (c *Config) GetRateLimitRule(route string) (RateLimitRule, bool) {
+	if !c.RateLimit.Enable {
+		return RateLimitRule{}, false
+	}
+
+	rule, exists := c.RateLimit.Routes[route]
+	if !exists {
+		for path, r := range c.RateLimit.Routes {
+			if NormalizeRoutePath(path) == route {
+				rule, exists = r, true
+				break
+			}
+		}
+	}
+	if !exists {
+		rule = c.RateLimit.Default
+	}
+	if rule.RPS <= 0 {
+		return RateLimitRule{}, false
+	}
+	if rule.Burst <= 0 {
+		rule.Burst = int(rule.RPS)
+		if rule.Burst < 1 {
+			rule.Burst = 1
+		}
+	}
+	return rule, true
+}
+
+// NormalizeRoutePath 去掉/api/v1或/api前缀，使新旧路径对应同一接口
+func NormalizeRoutePath(path string) string {
+	for _, prefix := range []string{"/api/v1", "/api"} {
+		if strings.HasPrefix(path, prefix+"/") {
+			return strings.TrimPrefix(path, prefix)
+		}
+	}
+	return path
+}
+
+// GetCallbackSignatureTolerance 获取回调签名时间戳允许偏差
+func (c *Config) GetCallbackSignatureTolerance() time.Duration {
+	return parseDurationOrDefault(c.Callback.Signature.Tolerance, 5*time.Minute)
+}
+
+// GetCallbackMaxBodySize 获取校验签名时读取请求体的上限（字节），默认1MB
+func (c *Config) GetCallbackMaxBodySize() int64 {
+	size, err := parseByteSize(c.Callback.Signature.MaxBodySize)
+	if c.Callback.Signature.MaxBodySize == "" || err != nil || size <= 0 {
+		return 1 << 20
+	}
+	return size
+}
+
+// GetCallbackSecret 根据密钥ID获取回调签名密钥
+func (c *Config) GetCallbackSecret(keyID string) (string, bool) {
+	if keyID == "" {
+		keyID = "default"
+	}
+	secret, ok := c.Callback.Signature.Secrets[keyID]
+	return secret, ok && secret != ""
+}
+
+// GetMetricsPath 获取指标接口路径
+func (c *Config) GetMetricsPath() string {
+	if c.Metrics.Path != "" {
+		return c.Metrics.Path
+	}
+	return "/metrics"
+}
+
+// GetAuditFile 获取审计日志文件路径
+func (c *Config) GetAuditFile() string {
+	if c.Audit.File != "" {
+		return c.Audit.File
+	}
+	return "audit/audit.log"
+}
+
+// parseByteSize 解析带单位的容量字符串，支持 B/KB/MB/GB/TB（不区分大小写，1KB=1024B）
+func parseByteSize(value string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	units := []struct {
+		suffix string
+		factor int64
+	}{
+		{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+		{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10},
+		{"B", 1},
+	}
+
+	factor := int64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(s, unit.suffix) {
+			factor = unit.factor
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			break
+		}
+	}
+
+	number, err := strconv.ParseFloat(s, 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("无效的容量: %s", value)
+	}
+	return int64(number * float64(factor)), nil
+}
+
 // ResolveWhitelistIPs 解析白名单域名为IP地址
 func (c *Config) ResolveWhitelistIPs() []string {
+	return c.ResolveWhitelistEntries(c.Whitelist.Domains)
+}
+
+// GetReplayWindow 获取日志查看参数允许的时钟偏差
+func (c *Config) GetReplayWindow() time.Duration {
+	return parseDurationOrDefault(c.Replay.Window, 5*time.Minute)
+}
+
+// GetReplayCacheSize 获取nonce缓存容量
+func (c *Config) GetReplayCacheSize() int {
+	if c.Replay.CacheSize > 0 {
+		return c.Replay.CacheSize
+	}
+	return 10000
+}
+
+// GetOutboundAllowedSchemes 获取出站请求允许的协议
+func (c *Config) GetOutboundAllowedSchemes() []string {
+	if len(c.Outbound.AllowedSchemes) > 0 {
+		return c.Outbound.AllowedSchemes
+	}
+	return []string{"http", "https"}
+}
+
+// GetEventBusTopic 获取事件总线的主题，默认cicd.deploy.events
+func (c *Config) GetEventBusTopic() string {
+	if c.EventBus.Topic != "" {
+		return c.EventBus.Topic
+	}
+	return "cicd.deploy.events"
+}
+
+// EventBusStepsEnabled 事件总线是否发布步骤事件，默认发布
+func (c *Config) EventBusStepsEnabled() bool {
+	return c.EventBus.Steps == nil || *c.EventBus.Steps
+}
+
+// GetOutboundTimeout 获取出站HTTP请求的单次超时
+func (c *Config) GetOutboundTimeout() time.Duration {
+	return parseDurationOrDefault(c.Outbound.Timeout, 10*time.Second)
+}
+
+// GetOutboundRetries 获取出站HTTP请求的重试次数
+func (c *Config) GetOutboundRetries() int {
+	if c.Outbound.Retries < 0 {
+		return 0
+	}
+	if c.Outbound.Retries == 0 {
+		return 2
+	}
+	return c.Outbound.Retries
+}
+
+// IsOutboundHostAllowed 判断出站请求的主机名是否在允许列表中
+func (c *Config) IsOutboundHostAllowed(host string) bool {
+	if len(c.Outbound.AllowedHosts) == 0 {
+		return true
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, allowed := range c.Outbound.AllowedHosts {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if allowed == "*" || allowed == host {
+			return true
+		}
+		// 通配子域名：*.feishu.cn 匹配 open.feishu.cn
+		if strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetTrustedProxies 解析可信代理列表，无效条目会被忽略
+func (c *Config) GetTrustedProxies() []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, entry := range c.Server.TrustedProxies {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		if addr, err := netip.ParseAddr(strings.Trim(entry, "[]")); err == nil {
+			addr = addr.WithZone("").Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+		}
+	}
+	return prefixes
+}
+
+// GetDNSCacheTTL 获取白名单域名解析缓存时间
+func (c *Config) GetDNSCacheTTL() time.Duration {
+	return parseDurationOrDefault(c.Whitelist.DNSCacheTTL, 5*time.Minute)
+}
+
+// GetDNSRetries 获取白名单域名解析重试次数
+func (c *Config) GetDNSRetries() int {
+	if c.Whitelist.DNSRetries > 0 {
+		return c.Whitelist.DNSRetries
+	}
+	return 2
+}
+
+// WhitelistNames 获取所有白名单名称（含默认白名单）
+func (c *Config) WhitelistNames() []string {
+	names := []string{DefaultWhitelistName}
+	for name := range c.Whitelist.Lists {
+		if name != DefaultWhitelistName {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// GetWhitelistEntries 获取指定白名单的条目，展开 @名单 引用
+// 名单未配置时返回默认白名单
+func (c *Config) GetWhitelistEntries(name string) []string {
+	return c.expandWhitelist(name, map[string]bool{})
+}
+
+// expandWhitelist 展开白名单引用，visited用于防止循环引用
+func (c *Config) expandWhitelist(name string, visited map[string]bool) []string {
+	if visited[name] {
+		return nil
+	}
+	visited[name] = true
+
+	entries, exists := c.Whitelist.Lists[name]
+	if name == DefaultWhitelistName || !exists {
+		return c.Whitelist.Domains
+	}
+
+	var result []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry, "@") {
+			result = append(result, c.expandWhitelist(strings.TrimPrefix(entry, "@"), visited)...)
+			continue
+		}
+		result = append(result, entry)
+	}
+	return result
+}
+
+// ResolveWhitelistEntries 将白名单条目（IP、CIDR网段或域名）解析为IP地址，CIDR原样返回
+func (c *Config) ResolveWhitelistEntries(entries []string) []string {
 	var ips []string
-	for _, domain := range c.Whitelist.Domains {
-		if ip := net.ParseIP(domain); ip != nil {
-			// 如果已经是IP地址，直接添加
+	for _, domain := range entries {
+		if _, _, err := net.ParseCIDR(domain); err == nil {
+			// CIDR网段直接保留，由白名单按前缀匹配
 			ips = append(ips, domain)
+		} else if ip := net.ParseIP(strings.Trim(domain, "[]")); ip != nil {
+			// 如果已经是IP地址，直接添加（去掉IPv6的方括号）
+			ips = append(ips, ip.String())
 		} else {
 			// 解析域名
 			if resolvedIPs, err := net.LookupIP(domain); err == nil {
+				// 同时保留A和AAAA记录，双栈环境下客户端可能使用IPv6访问
 				for _, ip := range resolvedIPs {
 					if ipv4 := ip.To4(); ipv4 != nil {
 						ips = append(ips, ipv4.String())
+					} else {
+						ips = append(ips, ip.String())
 					}
 				}
 			} else {
@@ -188,6 +1705,11 @@ func (c *Config) IsValidProject(projectName string) bool {
 	return false
 }
 
+// GetProjectOverride 获取项目的流水线覆盖配置，未配置时返回空配置
+func (c *Config) GetProjectOverride(projectName string) ProjectOverrideConfig {
+	return c.Projects.Overrides[projectName]
+}
+
 // IsWebProject 判断是否为Web项目
 func (c *Config) IsWebProject(projectName string) bool {
 	return strings.Contains(projectName, c.Projects.WebKeyword)
@@ -224,7 +1746,7 @@ func (c *Config) IsSingleProject(projectName string) bool {
 func (c *Config) GetWebPath(projectName string) string {
 	// 去掉-web后缀
 	project := strings.TrimSuffix(projectName, "-web")
-	return c.Web.WebDir + project + "/web"
+	return filepath.Join(c.Web.WebDir, project, "web")
 }
 
 // GetWebDownloadURL 获取产物下载URL
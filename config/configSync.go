@@ -0,0 +1,37 @@
+package config
+
+import "fmt"
+
+// ConfigSyncConfig ConfigMap/Secret同步：配置的项目在应用服务部署（步骤13）之前，将目录中的ConfigMap/Secret清单
+// 渲染后应用到目标namespace，保证新版本namespace（如首次部署的v2）拥有最新的配置
+type ConfigSyncConfig struct {
+	Projects map[string]ConfigSyncProjectConfig `yaml:"projects"` // 项目名 -> 同步配置，只有配置的项目执行该步骤
+}
+
+// ConfigSyncProjectConfig 项目的清单目录和模板数据
+// 目录中的.yaml/.yml/.tmpl文件按text/template渲染，可使用 {{.Project}} {{.Tag}} {{.Namespace}} {{.Values.xxx}}
+// 以及函数 secret "key"（从secrets_file读取，不存在时报错）、env "NAME"、b64enc
+type ConfigSyncProjectConfig struct {
+	Dir         string            `yaml:"dir"`          // 清单目录，相对路径基于项目部署目录
+	Values      map[string]string `yaml:"values"`       // 模板变量
+	SecretsFile string            `yaml:"secrets_file"` // 敏感值文件（YAML键值对），不放在清单目录中，避免进入部署仓库
+}
+
+// GetConfigSyncProject 获取项目的ConfigMap/Secret同步配置，未配置时返回false
+func (c *Config) GetConfigSyncProject(project string) (ConfigSyncProjectConfig, bool) {
+	projectConfig, ok := c.ConfigSync.Projects[project]
+	if !ok || projectConfig.Dir == "" {
+		return ConfigSyncProjectConfig{}, false
+	}
+	return projectConfig, true
+}
+
+// validateConfigSync 校验ConfigMap/Secret同步配置
+func validateConfigSync(configSync ConfigSyncConfig) error {
+	for project, projectConfig := range configSync.Projects {
+		if projectConfig.Dir == "" {
+			return fmt.Errorf("项目 %s 的ConfigMap/Secret同步未配置dir", project)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSearchLimit 查找下一个匹配时间的最大范围
+const cronSearchLimit = 366 * 24 * time.Hour
+
+// CronSchedule 解析后的cron表达式（分 时 日 月 周），按分钟匹配
+type CronSchedule struct {
+	minute, hour, dom, month, dow []bool
+	domAny, dowAny                bool // 日、周字段为*
+}
+
+// cronField cron字段的取值范围
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"分", 0, 59},
+	{"时", 0, 23},
+	{"日", 1, 31},
+	{"月", 1, 12},
+	{"周", 0, 7}, // 0和7都表示周日
+}
+
+// ParseCron 解析cron表达式，支持 *、数字、范围(a-b)、步长(*/n、a-b/n、a/n即从a到字段最大值)和逗号分隔的列表
+func ParseCron(expr string) (*CronSchedule, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("cron表达式 %q 应包含5个字段（分 时 日 月 周）", expr)
+	}
+
+	values := make([][]bool, len(cronFields))
+	for i, field := range cronFields {
+		set, err := parseCronField(parts[i], field)
+		if err != nil {
+			return nil, fmt.Errorf("cron表达式 %q 的%s字段错误: %v", expr, field.name, err)
+		}
+		values[i] = set
+	}
+	// 周日统一按0匹配
+	values[4][0] = values[4][0] || values[4][7]
+
+	return &CronSchedule{
+		minute: values[0],
+		hour:   values[1],
+		dom:    values[2],
+		month:  values[3],
+		dow:    values[4],
+		domAny: parts[2] == "*",
+		dowAny: parts[4] == "*",
+	}, nil
+}
+
+// parseCronField 解析单个字段，返回按取值索引的匹配表
+func parseCronField(value string, field cronField) ([]bool, error) {
+	set := make([]bool, field.max+1)
+	for _, item := range strings.Split(value, ",") {
+		rangePart, step, hasStep := item, 1, false
+		if i := strings.Index(item, "/"); i >= 0 {
+			n, err := strconv.Atoi(item[i+1:])
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("无效的步长 %q", item)
+			}
+			rangePart, step, hasStep = item[:i], n, true
+		}
+
+		start, end := field.min, field.max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if start, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, fmt.Errorf("无效的取值 %q", item)
+			}
+			// 单个数字只取该值；带步长时（a/n）从a取到字段最大值
+			if !hasStep {
+				end = start
+			}
+			if len(bounds) == 2 {
+				if end, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, fmt.Errorf("无效的取值 %q", item)
+				}
+			}
+		}
+		if start < field.min || end > field.max || start > end {
+			return nil, fmt.Errorf("取值 %q 超出范围 %d-%d", item, field.min, field.max)
+		}
+		for v := start; v <= end; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+// Matches 判断时间所在的分钟是否匹配
+// 与标准cron一致：日和周字段都有限制时满足其一即可
+func (s *CronSchedule) Matches(t time.Time) bool {
+	if !s.minute[t.Minute()] || !s.hour[t.Hour()] || !s.month[int(t.Month())] {
+		return false
+	}
+	domMatch, dowMatch := s.dom[t.Day()], s.dow[int(t.Weekday())]
+	if s.domAny || s.dowAny {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// Next 返回after之后（含after所在分钟）第一个匹配的时间，一年内没有匹配时返回false
+func (s *CronSchedule) Next(after time.Time) (time.Time, bool) {
+	if s.Matches(after) {
+		return after, true
+	}
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	for limit := after.Add(cronSearchLimit); t.Before(limit); t = t.Add(time.Minute) {
+		if s.Matches(t) {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
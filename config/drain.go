@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DrainConfig 连接排空：配置的项目在清理旧版本（步骤16）缩容之前，通知旧版本pod停止接收请求，
+// 并等待网关上的在途连接数降为0（或超时），避免缩容时丢弃请求
+type DrainConfig struct {
+	Projects map[string]DrainProjectConfig `yaml:"projects"` // 项目名 -> 排空配置，只有配置的项目排空
+}
+
+// DrainProjectConfig 项目的排空方式，endpoint/annotation/connections_url可组合使用
+type DrainProjectConfig struct {
+	Selector           string `yaml:"selector"`            // 旧版本pod的标签选择器，默认namespace中全部pod
+	Endpoint           string `yaml:"endpoint"`            // 在每个旧版本pod上调用的排空接口路径（如 /actuator/drain）
+	Method             string `yaml:"method"`              // 排空接口的方法，默认POST
+	Port               int    `yaml:"port"`                // 排空接口的端口，默认8080
+	Annotation         string `yaml:"annotation"`          // 为旧版本pod添加的注解（key=value，如 cicd-agent/draining=true），供网关或sidecar感知
+	ConnectionsURL     string `yaml:"connections_url"`     // 查询在途连接数的地址（支持{namespace}和{gateway}，namespace为旧版本）
+	ConnectionsPattern string `yaml:"connections_pattern"` // 从响应中提取连接数的正则（第一个分组，多处匹配时求和），默认(\d+)
+	Timeout            string `yaml:"timeout"`             // 等待连接数降为0的超时，默认60s；超时后继续缩容
+	PollInterval       string `yaml:"poll_interval"`       // 查询连接数的间隔，默认2s
+	GracePeriod        string `yaml:"grace_period"`        // 未配置connections_url时，调用排空接口后等待的时长，默认10s
+}
+
+// GetDrainProject 获取项目的排空配置（已填充默认值），未配置时返回false
+func (c *Config) GetDrainProject(project string) (DrainProjectConfig, bool) {
+	projectConfig, ok := c.Drain.Projects[project]
+	if !ok || (projectConfig.Endpoint == "" && projectConfig.Annotation == "" && projectConfig.ConnectionsURL == "") {
+		return DrainProjectConfig{}, false
+	}
+	projectConfig.Method = strings.ToUpper(projectConfig.Method)
+	if projectConfig.Method == "" {
+		projectConfig.Method = http.MethodPost
+	}
+	if projectConfig.Port <= 0 {
+		projectConfig.Port = 8080
+	}
+	if projectConfig.ConnectionsPattern == "" {
+		projectConfig.ConnectionsPattern = `(\d+)`
+	}
+	return projectConfig, true
+}
+
+// GetTimeout 获取等待在途连接数降为0的超时，默认60s
+func (d DrainProjectConfig) GetTimeout() time.Duration {
+	return parseDurationOrDefault(d.Timeout, 60*time.Second)
+}
+
+// GetPollInterval 获取查询在途连接数的间隔，默认2s
+func (d DrainProjectConfig) GetPollInterval() time.Duration {
+	return parseDurationOrDefault(d.PollInterval, 2*time.Second)
+}
+
+// GetGracePeriod 获取未配置connections_url时调用排空接口后等待的时长，默认10s
+func (d DrainProjectConfig) GetGracePeriod() time.Duration {
+	return parseDurationOrDefault(d.GracePeriod, 10*time.Second)
+}
+
+// validateDrain 校验排空配置
+func validateDrain(drain DrainConfig) error {
+	for project, projectConfig := range drain.Projects {
+		if projectConfig.Endpoint != "" && !strings.HasPrefix(projectConfig.Endpoint, "/") {
+			return fmt.Errorf("项目 %s 的排空接口endpoint应以/开头", project)
+		}
+		if projectConfig.Annotation != "" && !strings.Contains(projectConfig.Annotation, "=") {
+			return fmt.Errorf("项目 %s 的排空注解应为key=value格式", project)
+		}
+		if projectConfig.ConnectionsPattern != "" {
+			pattern, err := regexp.Compile(projectConfig.ConnectionsPattern)
+			if err != nil {
+				return fmt.Errorf("项目 %s 的connections_pattern错误: %v", project, err)
+			}
+			if pattern.NumSubexp() < 1 {
+				return fmt.Errorf("项目 %s 的connections_pattern需要包含一个分组", project)
+			}
+		}
+	}
+	return nil
+}
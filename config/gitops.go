@@ -0,0 +1,100 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// GitOpsConfig ArgoCD交接模式：配置的项目在检查镜像（步骤12）之后不再由agent部署，
+// 而是更新GitOps仓库中的镜像标签（提交并推送），再等待ArgoCD Application同步完成且健康
+type GitOpsConfig struct {
+	Projects    map[string]GitOpsProjectConfig `yaml:"projects"`     // 项目名 -> GitOps仓库配置，只有配置的项目使用交接模式
+	WorkDir     string                         `yaml:"work_dir"`     // 克隆GitOps仓库的目录，默认gitops
+	AuthorName  string                         `yaml:"author_name"`  // 提交作者，默认cicd-agent
+	AuthorEmail string                         `yaml:"author_email"` // 提交邮箱，默认cicd-agent@localhost
+	ArgoCD      ArgoCDConfig                   `yaml:"argocd"`
+}
+
+// GitOpsProjectConfig 项目的GitOps仓库和ArgoCD Application
+type GitOpsProjectConfig struct {
+	Repo         string   `yaml:"repo"`          // 仓库地址（凭证使用git的凭证配置或写在地址中）
+	Branch       string   `yaml:"branch"`        // 提交的分支，默认main
+	Files        []string `yaml:"files"`         // 需要更新镜像标签的文件（相对仓库根目录）
+	Application  string   `yaml:"application"`   // ArgoCD Application名称，默认项目名
+	AppNamespace string   `yaml:"app_namespace"` // Application所在命名空间（ArgoCD开启多命名空间时使用），为空使用ArgoCD默认
+}
+
+// ArgoCDConfig ArgoCD API配置
+type ArgoCDConfig struct {
+	Server       string `yaml:"server"`        // ArgoCD地址（如 https://argocd.example.com）
+	Token        string `yaml:"token"`         // API Token（账号需有applications get权限）
+	SyncTimeout  string `yaml:"sync_timeout"`  // 等待同步完成且健康的超时，默认10m
+	PollInterval string `yaml:"poll_interval"` // 查询Application状态的间隔，默认5s
+}
+
+// GetGitOpsProject 获取项目的GitOps配置，未配置时返回false（使用agent部署）
+func (c *Config) GetGitOpsProject(project string) (GitOpsProjectConfig, bool) {
+	projectConfig, ok := c.GitOps.Projects[project]
+	if !ok {
+		return GitOpsProjectConfig{}, false
+	}
+	if projectConfig.Branch == "" {
+		projectConfig.Branch = "main"
+	}
+	if projectConfig.Application == "" {
+		projectConfig.Application = project
+	}
+	return projectConfig, true
+}
+
+// GetGitOpsWorkDir 获取克隆GitOps仓库的目录，默认gitops
+func (c *Config) GetGitOpsWorkDir() string {
+	if c.GitOps.WorkDir == "" {
+		return "gitops"
+	}
+	return c.GitOps.WorkDir
+}
+
+// GetGitOpsAuthor 获取提交作者和邮箱
+func (c *Config) GetGitOpsAuthor() (string, string) {
+	name, email := c.GitOps.AuthorName, c.GitOps.AuthorEmail
+	if name == "" {
+		name = "cicd-agent"
+	}
+	if email == "" {
+		email = "cicd-agent@localhost"
+	}
+	return name, email
+}
+
+// GetArgoCDSyncTimeout 获取等待ArgoCD同步的超时，默认10m
+func (c *Config) GetArgoCDSyncTimeout() time.Duration {
+	if timeout, err := time.ParseDuration(c.GitOps.ArgoCD.SyncTimeout); err == nil && timeout > 0 {
+		return timeout
+	}
+	return 10 * time.Minute
+}
+
+// GetArgoCDPollInterval 获取查询Application状态的间隔，默认5s
+func (c *Config) GetArgoCDPollInterval() time.Duration {
+	if interval, err := time.ParseDuration(c.GitOps.ArgoCD.PollInterval); err == nil && interval > 0 {
+		return interval
+	}
+	return 5 * time.Second
+}
+
+// validateGitOps 校验交接模式项目的必填配置
+func validateGitOps(gitops GitOpsConfig) error {
+	if len(gitops.Projects) > 0 && gitops.ArgoCD.Server == "" {
+		return fmt.Errorf("配置了GitOps项目但未配置gitops.argocd.server")
+	}
+	for project, projectConfig := range gitops.Projects {
+		if projectConfig.Repo == "" {
+			return fmt.Errorf("GitOps项目 %s 未配置repo", project)
+		}
+		if len(projectConfig.Files) == 0 {
+			return fmt.Errorf("GitOps项目 %s 未配置需要更新镜像标签的files", project)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// 选主后端
+const (
+	LeaderBackendFile       = "file"       // 共享存储上的租约文件（NFS等，通过flock互斥）
+	LeaderBackendKubernetes = "kubernetes" // Kubernetes Lease（coordination.k8s.io/v1，通过kubectl读写）
+)
+
+// 备用节点收到请求时的处理方式
+const (
+	StandbyModeProxy    = "proxy"    // 转发给主节点
+	StandbyModeRedirect = "redirect" // 返回307重定向到主节点
+	StandbyModeReject   = "reject"   // 返回503并在响应中给出主节点地址
+)
+
+// LeaderElectionConfig 多agent高可用：同一站点的agent通过租约选出主节点，只有主节点处理回调和任务接口，
+// 备用节点按standby_mode转发、重定向或拒绝；主节点续约失败超过lease_duration后由备用节点接管
+type LeaderElectionConfig struct {
+	Enable        bool   `yaml:"enable"`
+	Backend       string `yaml:"backend"`        // file/kubernetes，默认file
+	Identity      string `yaml:"identity"`       // 节点标识，默认主机名
+	AdvertiseURL  string `yaml:"advertise_url"`  // 其他节点访问本节点的地址（如 http://10.0.0.11:8080），转发和重定向时使用
+	LeaseDuration string `yaml:"lease_duration"` // 租约有效期，默认15s
+	RenewInterval string `yaml:"renew_interval"` // 续约/竞选间隔，默认5s，需小于lease_duration
+	StandbyMode   string `yaml:"standby_mode"`   // proxy/redirect/reject，默认proxy；转发时需将备用节点加入主节点的server.trusted_proxies以保留调用方IP
+
+	File       LeaderFileConfig       `yaml:"file"`
+	Kubernetes LeaderKubernetesConfig `yaml:"kubernetes"`
+}
+
+// LeaderFileConfig 租约文件配置
+type LeaderFileConfig struct {
+	Path string `yaml:"path"` // 租约文件路径，需位于各节点共享的存储上
+}
+
+// LeaderKubernetesConfig Kubernetes Lease配置
+type LeaderKubernetesConfig struct {
+	Name      string `yaml:"name"`      // Lease名称，默认cicd-agent-leader
+	Namespace string `yaml:"namespace"` // 默认default
+}
+
+// GetBackend 获取选主后端，默认file
+func (l LeaderElectionConfig) GetBackend() string {
+	if l.Backend == "" {
+		return LeaderBackendFile
+	}
+	return l.Backend
+}
+
+// GetIdentity 获取节点标识，默认主机名
+func (l LeaderElectionConfig) GetIdentity() string {
+	if l.Identity != "" {
+		return l.Identity
+	}
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		return "cicd-agent"
+	}
+	return hostname
+}
+
+// GetLeaseDuration 获取租约有效期，默认15s
+func (l LeaderElectionConfig) GetLeaseDuration() time.Duration {
+	return parseDurationOrDefault(l.LeaseDuration, 15*time.Second)
+}
+
+// GetRenewInterval 获取续约/竞选间隔，默认5s
+func (l LeaderElectionConfig) GetRenewInterval() time.Duration {
+	return parseDurationOrDefault(l.RenewInterval, 5*time.Second)
+}
+
+// GetStandbyMode 获取备用节点的处理方式，默认proxy
+func (l LeaderElectionConfig) GetStandbyMode() string {
+	if l.StandbyMode == "" {
+		return StandbyModeProxy
+	}
+	return l.StandbyMode
+}
+
+// GetLeaseName 获取Lease名称，默认cicd-agent-leader
+func (k LeaderKubernetesConfig) GetLeaseName() string {
+	if k.Name == "" {
+		return "cicd-agent-leader"
+	}
+	return k.Name
+}
+
+// GetLeaseNamespace 获取Lease所在namespace，默认default
+func (k LeaderKubernetesConfig) GetLeaseNamespace() string {
+	if k.Namespace == "" {
+		return "default"
+	}
+	return k.Namespace
+}
+
+// validateLeaderElection 校验选主配置
+func validateLeaderElection(leader LeaderElectionConfig) error {
+	if !leader.Enable {
+		return nil
+	}
+	switch leader.GetBackend() {
+	case LeaderBackendFile:
+		if leader.File.Path == "" {
+			return fmt.Errorf("leader_election.file.path不能为空")
+		}
+	case LeaderBackendKubernetes:
+	default:
+		return fmt.Errorf("leader_election.backend不支持: %s（可选 file/kubernetes）", leader.Backend)
+	}
+	switch leader.GetStandbyMode() {
+	case StandbyModeProxy, StandbyModeRedirect, StandbyModeReject:
+	default:
+		return fmt.Errorf("leader_election.standby_mode不支持: %s（可选 proxy/redirect/reject）", leader.StandbyMode)
+	}
+	if leader.AdvertiseURL == "" {
+		return fmt.Errorf("leader_election.advertise_url不能为空")
+	}
+	parsed, err := url.Parse(leader.AdvertiseURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("leader_election.advertise_url格式错误: %s", leader.AdvertiseURL)
+	}
+	if strings.TrimSuffix(parsed.Path, "/") != "" {
+		return fmt.Errorf("leader_election.advertise_url不能包含路径: %s", leader.AdvertiseURL)
+	}
+	for name, value := range map[string]string{"lease_duration": leader.LeaseDuration, "renew_interval": leader.RenewInterval} {
+		if value == "" {
+			continue
+		}
+		if duration, err := time.ParseDuration(value); err != nil || duration <= 0 {
+			return fmt.Errorf("leader_election.%s格式错误: %s", name, value)
+		}
+	}
+	if leader.GetRenewInterval() >= leader.GetLeaseDuration() {
+		return fmt.Errorf("leader_election.renew_interval需小于lease_duration")
+	}
+	return nil
+}
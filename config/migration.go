@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// MigrationConfig 数据库迁移：配置的项目在应用服务部署之前，在目标namespace中启动迁移Job（Flyway/Liquibase等镜像）并等待完成，
+// 迁移失败时中止部署
+type MigrationConfig struct {
+	Timeout      string                            `yaml:"timeout"`       // 默认等待Job完成的超时，默认30m（同时作为Job的activeDeadlineSeconds）
+	PollInterval string                            `yaml:"poll_interval"` // 查询Job状态的间隔，默认5s
+	TTL          string                            `yaml:"ttl"`           // Job结束后保留的时间（ttlSecondsAfterFinished），默认24h
+	Projects     map[string]MigrationProjectConfig `yaml:"projects"`      // 项目名 -> 迁移Job配置，只有配置的项目执行该步骤
+}
+
+// MigrationProjectConfig 项目的迁移Job配置，image/command/args/env中的{tag}替换为镜像标签
+type MigrationProjectConfig struct {
+	Image          string            `yaml:"image"`            // 迁移镜像（如 flyway/flyway:10 或 harbor/project/migration:{tag}）
+	Command        []string          `yaml:"command"`          // 覆盖镜像的entrypoint
+	Args           []string          `yaml:"args"`             // 参数（如 ["migrate"] 或 ["update"]）
+	Env            map[string]string `yaml:"env"`              // 环境变量
+	EnvFromSecrets []string          `yaml:"env_from_secrets"` // 以环境变量方式注入的Secret（如数据库账号密码）
+	Namespace      string            `yaml:"namespace"`        // 运行Job的namespace，默认本次部署的目标namespace
+	ServiceAccount string            `yaml:"service_account"`  // Job使用的ServiceAccount
+	BackoffLimit   int               `yaml:"backoff_limit"`    // 失败重试次数，默认0（迁移失败不自动重试）
+	Timeout        string            `yaml:"timeout"`          // 覆盖默认超时
+}
+
+// GetMigrationProject 获取项目的数据库迁移配置，未配置时返回false
+func (c *Config) GetMigrationProject(project string) (MigrationProjectConfig, bool) {
+	projectConfig, ok := c.Migration.Projects[project]
+	if !ok || projectConfig.Image == "" {
+		return MigrationProjectConfig{}, false
+	}
+	return projectConfig, true
+}
+
+// GetMigrationTimeout 获取项目等待迁移Job完成的超时，默认30m
+func (c *Config) GetMigrationTimeout(project string) time.Duration {
+	if timeout, err := time.ParseDuration(c.Migration.Projects[project].Timeout); err == nil && timeout > 0 {
+		return timeout
+	}
+	return parseDurationOrDefault(c.Migration.Timeout, 30*time.Minute)
+}
+
+// GetMigrationPollInterval 获取查询迁移Job状态的间隔，默认5s
+func (c *Config) GetMigrationPollInterval() time.Duration {
+	return parseDurationOrDefault(c.Migration.PollInterval, 5*time.Second)
+}
+
+// GetMigrationTTL 获取迁移Job结束后保留的时间，默认24h
+func (c *Config) GetMigrationTTL() time.Duration {
+	return parseDurationOrDefault(c.Migration.TTL, 24*time.Hour)
+}
+
+// validateMigration 校验数据库迁移配置
+func validateMigration(migration MigrationConfig) error {
+	for project, projectConfig := range migration.Projects {
+		if projectConfig.Image == "" {
+			return fmt.Errorf("项目 %s 的数据库迁移未配置image", project)
+		}
+		if projectConfig.BackoffLimit < 0 {
+			return fmt.Errorf("项目 %s 的数据库迁移backoff_limit不能为负数", project)
+		}
+		if projectConfig.Timeout != "" {
+			if _, err := time.ParseDuration(projectConfig.Timeout); err != nil {
+				return fmt.Errorf("项目 %s 的数据库迁移timeout格式错误: %v", project, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// NacosConfig Nacos配置发布：配置的项目在应用服务部署（步骤13）之前发布配置，任务失败或取消时回滚
+type NacosConfig struct {
+	Server    string                        `yaml:"server"`    // Nacos地址（如 http://nacos:8848，未包含路径时使用/nacos）
+	Username  string                        `yaml:"username"`  // 开启鉴权时的用户名
+	Password  string                        `yaml:"password"`  // 开启鉴权时的密码
+	Namespace string                        `yaml:"namespace"` // 默认命名空间ID，为空使用public
+	Group     string                        `yaml:"group"`     // 默认分组，默认DEFAULT_GROUP
+	Projects  map[string]NacosProjectConfig `yaml:"projects"`  // 项目名 -> 发布的配置
+}
+
+// NacosProjectConfig 项目发布的配置
+type NacosProjectConfig struct {
+	Namespace string            `yaml:"namespace"` // 覆盖默认命名空间
+	Group     string            `yaml:"group"`     // 覆盖默认分组
+	Items     []NacosItemConfig `yaml:"items"`
+}
+
+// NacosItemConfig 一项配置：file发布整个文件，keys只修改properties格式配置中的键；文件内容和键值中的{tag}替换为镜像标签
+type NacosItemConfig struct {
+	DataID string            `yaml:"data_id"`
+	Group  string            `yaml:"group"` // 覆盖项目分组
+	Type   string            `yaml:"type"`  // 配置格式（text/json/xml/yaml/html/properties），默认按data_id扩展名
+	File   string            `yaml:"file"`  // 配置文件路径，相对路径基于项目部署目录
+	Keys   map[string]string `yaml:"keys"`  // 需要修改的键 -> 值（配置不存在时按这些键新建）
+}
+
+// GetNacosProject 获取项目发布的配置（已填充命名空间、分组和格式的默认值），未配置时返回false
+func (c *Config) GetNacosProject(project string) (NacosProjectConfig, bool) {
+	projectConfig, ok := c.Nacos.Projects[project]
+	if !ok || len(projectConfig.Items) == 0 {
+		return NacosProjectConfig{}, false
+	}
+	if projectConfig.Namespace == "" {
+		projectConfig.Namespace = c.Nacos.Namespace
+	}
+	if projectConfig.Group == "" {
+		projectConfig.Group = c.Nacos.Group
+	}
+	if projectConfig.Group == "" {
+		projectConfig.Group = "DEFAULT_GROUP"
+	}
+
+	items := make([]NacosItemConfig, len(projectConfig.Items))
+	for i, item := range projectConfig.Items {
+		if item.Group == "" {
+			item.Group = projectConfig.Group
+		}
+		if item.Type == "" {
+			item.Type = nacosConfigType(item)
+		}
+		items[i] = item
+	}
+	projectConfig.Items = items
+	return projectConfig, true
+}
+
+// nacosConfigType 按data_id扩展名推断配置格式，只修改键时为properties
+func nacosConfigType(item NacosItemConfig) string {
+	if item.File == "" {
+		return "properties"
+	}
+	switch ext := strings.TrimPrefix(path.Ext(item.DataID), "."); ext {
+	case "json", "xml", "yaml", "html", "properties":
+		return ext
+	case "yml":
+		return "yaml"
+	}
+	return "text"
+}
+
+// validateNacos 校验Nacos发布配置
+func validateNacos(nacos NacosConfig) error {
+	if len(nacos.Projects) > 0 && nacos.Server == "" {
+		return fmt.Errorf("配置了Nacos发布项目但未配置nacos.server")
+	}
+	for project, projectConfig := range nacos.Projects {
+		for i, item := range projectConfig.Items {
+			if item.DataID == "" {
+				return fmt.Errorf("项目 %s 的第%d项Nacos配置未指定data_id", project, i+1)
+			}
+			if (item.File == "") == (len(item.Keys) == 0) {
+				return fmt.Errorf("项目 %s 的Nacos配置 %s 需要且只能指定file或keys之一", project, item.DataID)
+			}
+		}
+	}
+	return nil
+}
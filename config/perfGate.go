@@ -0,0 +1,93 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// PerfGateConfig 性能门禁：配置的项目在流量切换（步骤15）之前，以固定速率压测新版本网关，
+// P95延迟或错误率超过阈值时部署失败，不切换流量
+type PerfGateConfig struct {
+	Projects map[string]PerfGateProjectConfig `yaml:"projects"` // 项目名 -> 压测配置，只有配置的项目执行该步骤
+}
+
+// PerfGateProjectConfig 项目的压测请求和阈值；url和body中的{tag}替换为镜像标签
+type PerfGateProjectConfig struct {
+	BaseURL      string            `yaml:"base_url"`       // 以/开头的url的前缀，默认新版本网关 http://{gateway}:8080；支持{namespace}和{gateway}
+	Method       string            `yaml:"method"`         // 默认GET
+	URL          string            `yaml:"url"`            // 压测地址，以/开头时拼接base_url
+	Headers      map[string]string `yaml:"headers"`        // 请求头
+	Body         string            `yaml:"body"`           // 请求体
+	RPS          int               `yaml:"rps"`            // 每秒请求数，默认20
+	Duration     string            `yaml:"duration"`       // 压测时长，默认30s
+	Concurrency  int               `yaml:"concurrency"`    // 最大并发请求数，默认等于rps
+	Timeout      string            `yaml:"timeout"`        // 单个请求超时，默认5s，超时计为错误
+	MaxP95       string            `yaml:"max_p95"`        // P95延迟上限，默认500ms
+	MaxErrorRate float64           `yaml:"max_error_rate"` // 错误率上限（0-1，状态码>=400或请求失败计为错误），默认0.01
+}
+
+// GetPerfGateProject 获取项目的压测配置（已填充默认值），未配置时返回false
+func (c *Config) GetPerfGateProject(project string) (PerfGateProjectConfig, bool) {
+	projectConfig, ok := c.PerfGate.Projects[project]
+	if !ok || projectConfig.URL == "" {
+		return PerfGateProjectConfig{}, false
+	}
+	if projectConfig.BaseURL == "" {
+		projectConfig.BaseURL = "http://{gateway}:8080"
+	}
+	projectConfig.Method = strings.ToUpper(projectConfig.Method)
+	if projectConfig.Method == "" {
+		projectConfig.Method = http.MethodGet
+	}
+	if projectConfig.RPS <= 0 {
+		projectConfig.RPS = 20
+	}
+	if projectConfig.Concurrency <= 0 {
+		projectConfig.Concurrency = projectConfig.RPS
+	}
+	if projectConfig.MaxErrorRate <= 0 {
+		projectConfig.MaxErrorRate = 0.01
+	}
+	return projectConfig, true
+}
+
+// GetDuration 获取压测时长，默认30s
+func (p PerfGateProjectConfig) GetDuration() time.Duration {
+	return parseDurationOrDefault(p.Duration, 30*time.Second)
+}
+
+// GetTimeout 获取单个请求超时，默认5s
+func (p PerfGateProjectConfig) GetTimeout() time.Duration {
+	return parseDurationOrDefault(p.Timeout, 5*time.Second)
+}
+
+// GetMaxP95 获取P95延迟上限，默认500ms
+func (p PerfGateProjectConfig) GetMaxP95() time.Duration {
+	return parseDurationOrDefault(p.MaxP95, 500*time.Millisecond)
+}
+
+// validatePerfGate 校验性能门禁配置
+func validatePerfGate(perfGate PerfGateConfig) error {
+	for project, projectConfig := range perfGate.Projects {
+		if projectConfig.URL == "" {
+			return fmt.Errorf("项目 %s 的性能门禁未配置url", project)
+		}
+		if projectConfig.RPS > 1000 {
+			return fmt.Errorf("项目 %s 的性能门禁rps不能超过1000", project)
+		}
+		if projectConfig.MaxErrorRate < 0 || projectConfig.MaxErrorRate > 1 {
+			return fmt.Errorf("项目 %s 的性能门禁max_error_rate应在0到1之间", project)
+		}
+		for name, value := range map[string]string{"duration": projectConfig.Duration, "timeout": projectConfig.Timeout, "max_p95": projectConfig.MaxP95} {
+			if value == "" {
+				continue
+			}
+			if _, err := time.ParseDuration(value); err != nil {
+				return fmt.Errorf("项目 %s 的性能门禁%s格式错误: %v", project, name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PipelineConfig 流水线定义：按顺序执行的步骤列表
+// 任务按项目和部署类型选择流水线：先匹配projects包含该项目的流水线，再匹配projects为空的同类型流水线，
+// 都未匹配时使用内置流水线
+type PipelineConfig struct {
+	Name     string               `yaml:"name"`
+	Type     string               `yaml:"type"`     // 部署类型: web/single/double
+	Projects []string             `yaml:"projects"` // 适用的项目，为空表示该类型的默认流水线
+	Steps    []PipelineStepConfig `yaml:"steps"`
+}
+
+// PipelineStepConfig 流水线步骤
+type PipelineStepConfig struct {
+	Type   string            `yaml:"type"`   // 步骤类型（如 pullOnline、deployService）
+	Params map[string]string `yaml:"params"` // 步骤参数
+}
+
+// loadPipelineDir 读取目录下的流水线定义（*.yaml/*.yml，每个文件一条流水线），按文件名排序
+func loadPipelineDir(dir string) ([]PipelineConfig, error) {
+	var files []string
+	for _, pattern := range []string{"*.yaml", "*.yml"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+
+	pipelines := make([]PipelineConfig, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("读取流水线文件 %s 失败: %v", file, err)
+		}
+		var pipeline PipelineConfig
+		if err := yaml.Unmarshal(data, &pipeline); err != nil {
+			return nil, fmt.Errorf("解析流水线文件 %s 失败: %v", file, err)
+		}
+		if pipeline.Name == "" {
+			pipeline.Name = filepath.Base(file)
+		}
+		pipelines = append(pipelines, pipeline)
+	}
+	return pipelines, nil
+}
+
+// validatePipelines 校验流水线定义的基本格式
+func validatePipelines(pipelines []PipelineConfig) error {
+	for _, pipeline := range pipelines {
+		if pipeline.Type == "" {
+			return fmt.Errorf("流水线 %s 未指定type", pipeline.Name)
+		}
+		if len(pipeline.Steps) == 0 {
+			return fmt.Errorf("流水线 %s 没有步骤", pipeline.Name)
+		}
+		for i, step := range pipeline.Steps {
+			if step.Type == "" {
+				return fmt.Errorf("流水线 %s 第%d个步骤未指定type", pipeline.Name, i+1)
+			}
+		}
+	}
+	return nil
+}
+
+// FindPipeline 查找项目和部署类型对应的流水线，未配置时返回false（使用内置流水线）
+func (c *Config) FindPipeline(project, deployType string) (PipelineConfig, bool) {
+	var fallback *PipelineConfig
+	for i := range c.Pipelines {
+		pipeline := &c.Pipelines[i]
+		if pipeline.Type != deployType {
+			continue
+		}
+		if len(pipeline.Projects) == 0 {
+			if fallback == nil {
+				fallback = pipeline
+			}
+			continue
+		}
+		for _, name := range pipeline.Projects {
+			if name == project {
+				return *pipeline, true
+			}
+		}
+	}
+	if fallback != nil {
+		return *fallback, true
+	}
+	return PipelineConfig{}, false
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ReadinessConfig /ready接口：主动检查docker、kubectl、Harbor和日志目录，任一依赖不可用时返回503
+type ReadinessConfig struct {
+	Timeout      string   `yaml:"timeout"`       // 单项检查的超时，默认5s
+	CacheTTL     string   `yaml:"cache_ttl"`     // 检查结果的缓存时间，避免探针频繁调用时反复执行docker/kubectl，默认10s
+	KubeContexts []string `yaml:"kube_contexts"` // 需要检查的kubeconfig上下文（多集群），为空时检查当前上下文
+}
+
+// GetTimeout 获取单项检查的超时，默认5s
+func (r ReadinessConfig) GetTimeout() time.Duration {
+	return parseDurationOrDefault(r.Timeout, 5*time.Second)
+}
+
+// GetCacheTTL 获取检查结果的缓存时间，默认10s
+func (r ReadinessConfig) GetCacheTTL() time.Duration {
+	return parseDurationOrDefault(r.CacheTTL, 10*time.Second)
+}
+
+// validateReadiness 校验就绪检查配置
+func validateReadiness(readiness ReadinessConfig) error {
+	for name, value := range map[string]string{"timeout": readiness.Timeout, "cache_ttl": readiness.CacheTTL} {
+		if value == "" {
+			continue
+		}
+		if duration, err := time.ParseDuration(value); err != nil || duration <= 0 {
+			return fmt.Errorf("readiness.%s格式错误: %s", name, value)
+		}
+	}
+	for _, context := range readiness.KubeContexts {
+		if strings.TrimSpace(context) == "" {
+			return fmt.Errorf("readiness.kube_contexts不能包含空值")
+		}
+	}
+	return nil
+}
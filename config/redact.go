@@ -0,0 +1,86 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redactedValue 脱敏后的占位文本
+const redactedValue = "******"
+
+// sensitiveConfigKeys 值需要脱敏的配置项（yaml键名），map和列表类型的配置项脱敏其中全部字符串
+var sensitiveConfigKeys = map[string]bool{
+	"password":           true,
+	"offline_password":   true,
+	"secret":             true,
+	"secrets":            true,
+	"secret_id":          true,
+	"token":              true,
+	"tokens":             true,
+	"admin_token":        true,
+	"bot_token":          true,
+	"verification_token": true,
+	"feishu_secrets":     true,
+	"encryption_salt":    true,
+	"key":                true, // auth.api_keys中的API Key
+	"headers":            true, // 请求头中通常带有认证信息
+}
+
+// Redacted 返回脱敏后的配置副本：密码、密钥、令牌等配置项以及从Vault读取的值替换为******（供print-config输出）
+func (c *Config) Redacted() (*Config, error) {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("序列化配置失败: %v", err)
+	}
+	redacted := &Config{}
+	if err := yaml.Unmarshal(data, redacted); err != nil {
+		return nil, fmt.Errorf("复制配置失败: %v", err)
+	}
+
+	vaultState.Lock()
+	vaultValues := make(map[string]bool, len(vaultState.values))
+	for _, value := range vaultState.values {
+		vaultValues[value] = true
+	}
+	vaultState.Unlock()
+
+	redactValue(reflect.ValueOf(redacted).Elem(), false, vaultValues)
+	return redacted, nil
+}
+
+// redactValue 递归脱敏：sensitive表示所在配置项需要脱敏
+func redactValue(value reflect.Value, sensitive bool, vaultValues map[string]bool) {
+	switch value.Kind() {
+	case reflect.String:
+		if text := value.String(); text != "" && (sensitive || vaultValues[text]) && value.CanSet() {
+			value.SetString(redactedValue)
+		}
+	case reflect.Ptr:
+		if !value.IsNil() {
+			redactValue(value.Elem(), sensitive, vaultValues)
+		}
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+			redactValue(value.Field(i), sensitive || sensitiveConfigKeys[name], vaultValues)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			redactValue(value.Index(i), sensitive, vaultValues)
+		}
+	case reflect.Map:
+		for _, key := range value.MapKeys() {
+			elem := reflect.New(value.Type().Elem()).Elem()
+			elem.Set(value.MapIndex(key))
+			redactValue(elem, sensitive, vaultValues)
+			value.SetMapIndex(key, elem)
+		}
+	}
+}
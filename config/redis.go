@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// RedisConfig 部署后清理缓存：配置的项目在流量切换（单版本为部署/交接完成）之后，删除匹配的键或发布失效消息
+type RedisConfig struct {
+	Timeout  string                        `yaml:"timeout"`  // 连接和单条命令的超时，默认10s
+	Projects map[string]RedisProjectConfig `yaml:"projects"` // 项目名 -> 缓存清理配置，只有配置的项目执行该步骤
+}
+
+// RedisProjectConfig 项目的Redis连接和清理内容；patterns、message中的{tag}替换为镜像标签
+type RedisProjectConfig struct {
+	Addr     string         `yaml:"addr"`     // 地址（host:port）
+	Username string         `yaml:"username"` // ACL用户名（Redis 6+），为空时只用密码认证
+	Password string         `yaml:"password"`
+	DB       int            `yaml:"db"`
+	TLS      bool           `yaml:"tls"`
+	Secret   RedisSecretRef `yaml:"secret"` // 从Kubernetes Secret读取地址和凭据，读取到的值优先于上面的配置
+
+	Patterns []string `yaml:"patterns"` // 需要删除的键模式（SCAN MATCH语法，如 user:*）
+	Channel  string   `yaml:"channel"`  // 发布失效消息的频道
+	Message  string   `yaml:"message"`  // 失效消息内容，默认 {"project":...,"tag":...,"task_id":...}
+	Required bool     `yaml:"required"` // 清理失败时任务是否失败，默认只记录警告（流量已经切换）
+}
+
+// RedisSecretRef Kubernetes Secret中保存Redis地址和凭据的键
+type RedisSecretRef struct {
+	Name        string `yaml:"name"`
+	Namespace   string `yaml:"namespace"`    // 默认项目当前运行的namespace
+	AddrKey     string `yaml:"addr_key"`     // 默认不读取
+	UsernameKey string `yaml:"username_key"` // 默认不读取
+	PasswordKey string `yaml:"password_key"` // 默认password
+}
+
+// GetRedisProject 获取项目的缓存清理配置，未配置时返回false
+func (c *Config) GetRedisProject(project string) (RedisProjectConfig, bool) {
+	projectConfig, ok := c.Redis.Projects[project]
+	if !ok || (len(projectConfig.Patterns) == 0 && projectConfig.Channel == "") {
+		return RedisProjectConfig{}, false
+	}
+	if projectConfig.Secret.Name != "" && projectConfig.Secret.PasswordKey == "" {
+		projectConfig.Secret.PasswordKey = "password"
+	}
+	return projectConfig, true
+}
+
+// GetRedisTimeout 获取Redis连接和单条命令的超时，默认10s
+func (c *Config) GetRedisTimeout() time.Duration {
+	return parseDurationOrDefault(c.Redis.Timeout, 10*time.Second)
+}
+
+// validateRedis 校验缓存清理配置
+func validateRedis(redis RedisConfig) error {
+	for project, projectConfig := range redis.Projects {
+		if len(projectConfig.Patterns) == 0 && projectConfig.Channel == "" {
+			return fmt.Errorf("项目 %s 的缓存清理未配置patterns或channel", project)
+		}
+		if projectConfig.Addr == "" && (projectConfig.Secret.Name == "" || projectConfig.Secret.AddrKey == "") {
+			return fmt.Errorf("项目 %s 的缓存清理未配置addr（或secret.addr_key）", project)
+		}
+		for _, pattern := range projectConfig.Patterns {
+			if pattern == "" || pattern == "*" {
+				return fmt.Errorf("项目 %s 的缓存清理模式 %q 会删除全部键，不允许使用", project, pattern)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// RoutingConfig 路由模式：多个agent共用一个回调入口时，本agent按项目把回调和更新请求转发给负责该项目的agent，
+// 中心服务每个站点只需配置一个地址；任务取消、日志等按任务ID的接口仍需直接调用执行任务的agent
+type RoutingConfig struct {
+	Enable              bool                          `yaml:"enable"`
+	Agents              map[string]RoutingAgentConfig `yaml:"agents"`                // agent名 -> 地址
+	Projects            map[string]string             `yaml:"projects"`              // 项目名 -> agent名，未配置的项目转发到default_agent
+	DefaultAgent        string                        `yaml:"default_agent"`         // 未配置映射的项目转发到该agent，为空时由本agent处理
+	Retries             int                           `yaml:"retries"`               // 转发失败（网络错误或5xx）后的重试次数，默认2，负数表示不重试
+	RetryBackoff        string                        `yaml:"retry_backoff"`         // 首次重试的等待时间，之后每次翻倍，默认1s
+	Timeout             string                        `yaml:"timeout"`               // 单次转发超时，默认10s
+	HealthCheckInterval string                        `yaml:"health_check_interval"` // 检查各agent健康状态的间隔，默认10s
+	Token               string                        `yaml:"token"`                 // agent之间转发使用的共享令牌，转发方和接收方需配置相同的值；接收方校验通过才按已转发的请求处理
+}
+
+// RoutingAgentConfig 目标agent
+type RoutingAgentConfig struct {
+	URLs       []string `yaml:"urls"`        // agent地址（如 http://10.0.0.21:8080），可配置主备多个地址，优先使用健康检查通过的地址
+	Token      string   `yaml:"token"`       // 转发时使用的API Key或JWT（目标agent开启auth时需要），为空时不带Authorization
+	HealthPath string   `yaml:"health_path"` // 健康检查路径，默认/health
+}
+
+// GetRouteAgent 获取项目应转发到的agent，由本agent处理时返回false
+func (c *Config) GetRouteAgent(project string) (string, RoutingAgentConfig, bool) {
+	if !c.Routing.Enable {
+		return "", RoutingAgentConfig{}, false
+	}
+	name, ok := c.Routing.Projects[project]
+	if !ok {
+		name = c.Routing.DefaultAgent
+	}
+	agent, ok := c.Routing.Agents[name]
+	if name == "" || !ok {
+		return "", RoutingAgentConfig{}, false
+	}
+	return name, agent, true
+}
+
+// GetHealthPath 获取健康检查路径，默认/health
+func (a RoutingAgentConfig) GetHealthPath() string {
+	if a.HealthPath == "" {
+		return "/health"
+	}
+	return a.HealthPath
+}
+
+// GetRetries 获取转发重试次数，未配置时默认2，负数表示不重试
+func (r RoutingConfig) GetRetries() int {
+	if r.Retries < 0 {
+		return 0
+	}
+	if r.Retries == 0 {
+		return 2
+	}
+	return r.Retries
+}
+
+// GetRetryBackoff 获取首次重试的等待时间，默认1s
+func (r RoutingConfig) GetRetryBackoff() time.Duration {
+	return parseDurationOrDefault(r.RetryBackoff, time.Second)
+}
+
+// GetTimeout 获取单次转发超时，默认10s
+func (r RoutingConfig) GetTimeout() time.Duration {
+	return parseDurationOrDefault(r.Timeout, 10*time.Second)
+}
+
+// GetHealthCheckInterval 获取健康检查间隔，默认10s
+func (r RoutingConfig) GetHealthCheckInterval() time.Duration {
+	return parseDurationOrDefault(r.HealthCheckInterval, 10*time.Second)
+}
+
+// validateRouting 校验路由模式配置
+func validateRouting(routing RoutingConfig) error {
+	if !routing.Enable {
+		return nil
+	}
+	if routing.Token == "" {
+		return fmt.Errorf("开启路由模式时需要配置routing.token")
+	}
+	for name, agent := range routing.Agents {
+		if len(agent.URLs) == 0 {
+			return fmt.Errorf("routing.agents.%s未配置urls", name)
+		}
+		for _, rawURL := range agent.URLs {
+			parsed, err := url.Parse(rawURL)
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				return fmt.Errorf("routing.agents.%s的地址格式错误: %s", name, rawURL)
+			}
+			if strings.TrimSuffix(parsed.Path, "/") != "" {
+				return fmt.Errorf("routing.agents.%s的地址不能包含路径: %s", name, rawURL)
+			}
+		}
+		if agent.HealthPath != "" && !strings.HasPrefix(agent.HealthPath, "/") {
+			return fmt.Errorf("routing.agents.%s的health_path应以/开头", name)
+		}
+	}
+	for project, name := range routing.Projects {
+		if _, ok := routing.Agents[name]; !ok {
+			return fmt.Errorf("项目 %s 的路由目标 %s 未在routing.agents中配置", project, name)
+		}
+	}
+	if routing.DefaultAgent != "" {
+		if _, ok := routing.Agents[routing.DefaultAgent]; !ok {
+			return fmt.Errorf("routing.default_agent %s 未在routing.agents中配置", routing.DefaultAgent)
+		}
+	}
+	for name, value := range map[string]string{"retry_backoff": routing.RetryBackoff, "timeout": routing.Timeout, "health_check_interval": routing.HealthCheckInterval} {
+		if value == "" {
+			continue
+		}
+		if duration, err := time.ParseDuration(value); err != nil || duration <= 0 {
+			return fmt.Errorf("routing.%s格式错误: %s", name, value)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// SmokeTestConfig 冒烟测试：配置的项目在服务就绪检查（步骤14）之后、流量切换（步骤15）之前，
+// 对新版本namespace的网关依次发送请求并校验响应，任一用例失败时不切换流量
+type SmokeTestConfig struct {
+	Timeout  string                            `yaml:"timeout"`  // 单个请求的超时，默认10s
+	Projects map[string]SmokeTestProjectConfig `yaml:"projects"` // 项目名 -> 测试用例，只有配置的项目执行该步骤
+}
+
+// SmokeTestProjectConfig 项目的冒烟测试
+type SmokeTestProjectConfig struct {
+	BaseURL       string          `yaml:"base_url"`       // 以/开头的用例地址的前缀，默认新版本网关 http://{gateway}:8080；支持{namespace}和{gateway}
+	Retries       int             `yaml:"retries"`        // 用例失败后的重试次数（服务刚就绪时可能需要预热），默认0
+	RetryInterval string          `yaml:"retry_interval"` // 重试间隔，默认5s
+	Cases         []SmokeTestCase `yaml:"cases"`
+}
+
+// SmokeTestCase 一个测试用例，url和body中的{tag}替换为镜像标签
+type SmokeTestCase struct {
+	Name         string            `yaml:"name"`          // 用例名称，默认 方法 地址
+	Method       string            `yaml:"method"`        // 默认GET
+	URL          string            `yaml:"url"`           // 以/开头时拼接base_url，否则为完整地址
+	Headers      map[string]string `yaml:"headers"`       // 请求头
+	Body         string            `yaml:"body"`          // 请求体
+	ExpectStatus int               `yaml:"expect_status"` // 期望的状态码，默认200
+	BodyRegex    string            `yaml:"body_regex"`    // 响应体需要匹配的正则表达式
+}
+
+// GetSmokeTestProject 获取项目的冒烟测试（已填充方法、状态码和名称的默认值），未配置时返回false
+func (c *Config) GetSmokeTestProject(project string) (SmokeTestProjectConfig, bool) {
+	projectConfig, ok := c.SmokeTest.Projects[project]
+	if !ok || len(projectConfig.Cases) == 0 {
+		return SmokeTestProjectConfig{}, false
+	}
+	if projectConfig.BaseURL == "" {
+		projectConfig.BaseURL = "http://{gateway}:8080"
+	}
+	cases := make([]SmokeTestCase, len(projectConfig.Cases))
+	for i, testCase := range projectConfig.Cases {
+		if testCase.Method == "" {
+			testCase.Method = http.MethodGet
+		}
+		testCase.Method = strings.ToUpper(testCase.Method)
+		if testCase.ExpectStatus == 0 {
+			testCase.ExpectStatus = http.StatusOK
+		}
+		if testCase.Name == "" {
+			testCase.Name = testCase.Method + " " + testCase.URL
+		}
+		cases[i] = testCase
+	}
+	projectConfig.Cases = cases
+	return projectConfig, true
+}
+
+// GetSmokeTestTimeout 获取冒烟测试单个请求的超时，默认10s
+func (c *Config) GetSmokeTestTimeout() time.Duration {
+	return parseDurationOrDefault(c.SmokeTest.Timeout, 10*time.Second)
+}
+
+// GetSmokeTestRetryInterval 获取项目冒烟测试用例的重试间隔，默认5s
+func (c *Config) GetSmokeTestRetryInterval(project string) time.Duration {
+	return parseDurationOrDefault(c.SmokeTest.Projects[project].RetryInterval, 5*time.Second)
+}
+
+// validateSmokeTest 校验冒烟测试用例
+func validateSmokeTest(smokeTest SmokeTestConfig) error {
+	for project, projectConfig := range smokeTest.Projects {
+		for i, testCase := range projectConfig.Cases {
+			if testCase.URL == "" {
+				return fmt.Errorf("项目 %s 的第%d个冒烟测试用例未配置url", project, i+1)
+			}
+			if testCase.BodyRegex != "" {
+				if _, err := regexp.Compile(testCase.BodyRegex); err != nil {
+					return fmt.Errorf("项目 %s 的第%d个冒烟测试用例body_regex错误: %v", project, i+1, err)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,437 @@
+package config
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// VaultRefPrefix 配置中引用Vault密钥的前缀，格式为 vault:<路径>#<键>，如 vault:secret/data/cicd/harbor#password
+// 路径为Vault API路径（KV v2需包含data/），任意字符串配置项（含map和列表中的值）均可引用
+const VaultRefPrefix = "vault:"
+
+// defaultVaultTokenFile Kubernetes认证默认读取的ServiceAccount令牌
+const defaultVaultTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// VaultConfig HashiCorp Vault配置：Harbor凭据、webhook密钥、命令环境变量等敏感配置可改为引用Vault，
+// 启动和重新加载时读取；refresh_interval定期检查引用的密钥，发生轮换时重新加载配置
+type VaultConfig struct {
+	Enable          bool              `yaml:"enable"`
+	Address         string            `yaml:"address"`          // Vault地址，如 https://vault.example.com:8200
+	Namespace       string            `yaml:"namespace"`        // Vault企业版命名空间，为空不设置
+	CACert          string            `yaml:"ca_cert"`          // 校验Vault证书的CA文件，为空使用系统CA
+	Timeout         string            `yaml:"timeout"`          // 单次请求超时，默认10s
+	RefreshInterval string            `yaml:"refresh_interval"` // 检查密钥轮换的间隔，默认5m
+	Auth            VaultAuthConfig   `yaml:"auth"`
+	Files           map[string]string `yaml:"files"` // 本地文件路径 -> Vault引用，如SSH私钥 /root/.ssh/id_rsa: vault:secret/data/cicd/ssh#private_key；写入权限0600
+}
+
+// VaultAuthConfig Vault认证配置
+type VaultAuthConfig struct {
+	Method string `yaml:"method"` // approle/kubernetes
+	Mount  string `yaml:"mount"`  // 认证方式的挂载路径，默认与method相同
+
+	// approle认证
+	RoleID       string `yaml:"role_id"`
+	SecretID     string `yaml:"secret_id"`
+	SecretIDFile string `yaml:"secret_id_file"` // 从文件读取secret_id（优先于secret_id）
+
+	// kubernetes认证
+	Role      string `yaml:"role"`
+	TokenFile string `yaml:"token_file"` // ServiceAccount令牌文件，默认 /var/run/secrets/kubernetes.io/serviceaccount/token
+}
+
+// GetTimeout 获取单次请求超时，默认10s
+func (v VaultConfig) GetTimeout() time.Duration {
+	return parseDurationOrDefault(v.Timeout, 10*time.Second)
+}
+
+// GetRefreshInterval 获取检查密钥轮换的间隔，默认5m
+func (v VaultConfig) GetRefreshInterval() time.Duration {
+	return parseDurationOrDefault(v.RefreshInterval, 5*time.Minute)
+}
+
+// GetMount 获取认证方式的挂载路径
+func (a VaultAuthConfig) GetMount() string {
+	if a.Mount != "" {
+		return strings.Trim(a.Mount, "/")
+	}
+	return a.Method
+}
+
+// validateVault 校验Vault配置
+func validateVault(vault VaultConfig) error {
+	if !vault.Enable {
+		return nil
+	}
+	if vault.Address == "" {
+		return fmt.Errorf("vault配置错误: 未配置address")
+	}
+	switch vault.Auth.Method {
+	case "approle":
+		if vault.Auth.RoleID == "" || (vault.Auth.SecretID == "" && vault.Auth.SecretIDFile == "") {
+			return fmt.Errorf("vault配置错误: approle认证需要配置role_id和secret_id（或secret_id_file）")
+		}
+	case "kubernetes":
+		if vault.Auth.Role == "" {
+			return fmt.Errorf("vault配置错误: kubernetes认证需要配置role")
+		}
+	default:
+		return fmt.Errorf("vault配置错误: 认证方式 %q 不支持（支持approle/kubernetes）", vault.Auth.Method)
+	}
+	for path, ref := range vault.Files {
+		if _, _, err := parseVaultRef(ref); err != nil {
+			return fmt.Errorf("vault配置错误: 文件 %s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// vaultState 最近一次成功加载配置时引用的密钥值（供轮换检查对比）及登录令牌
+var vaultState struct {
+	sync.Mutex
+	config      VaultConfig
+	values      map[string]string // 引用 -> 值
+	token       string
+	tokenConfig VaultConfig // 登录令牌对应的Vault配置，配置变化后重新登录
+	tokenExpiry time.Time
+}
+
+// resolveVaultRefs 将配置中所有 vault: 引用替换为Vault中的值，返回本次读取的引用及其值
+func resolveVaultRefs(config *Config) (map[string]string, error) {
+	var refs []string
+	walkConfigStrings(reflect.ValueOf(config).Elem(), func(value string) (string, error) {
+		if strings.HasPrefix(value, VaultRefPrefix) {
+			refs = append(refs, value)
+		}
+		return value, nil
+	})
+	for _, ref := range config.Vault.Files {
+		refs = append(refs, ref)
+	}
+	if len(refs) == 0 {
+		return nil, nil
+	}
+	if !config.Vault.Enable {
+		return nil, fmt.Errorf("配置引用了Vault密钥（%s），但未启用vault", refs[0])
+	}
+	if err := validateVault(config.Vault); err != nil {
+		return nil, err
+	}
+
+	values, err := readVaultRefs(config.Vault, refs)
+	if err != nil {
+		return nil, err
+	}
+	err = walkConfigStrings(reflect.ValueOf(config).Elem(), func(value string) (string, error) {
+		if !strings.HasPrefix(value, VaultRefPrefix) {
+			return value, nil
+		}
+		return values[value], nil
+	})
+	return values, err
+}
+
+// readVaultRefs 读取引用的密钥，同一路径只请求一次
+func readVaultRefs(vault VaultConfig, refs []string) (map[string]string, error) {
+	secrets := make(map[string]map[string]interface{})
+	values := make(map[string]string, len(refs))
+	for _, ref := range refs {
+		if _, ok := values[ref]; ok {
+			continue
+		}
+		path, key, err := parseVaultRef(ref)
+		if err != nil {
+			return nil, err
+		}
+		data, ok := secrets[path]
+		if !ok {
+			if data, err = readVaultSecret(vault, path); err != nil {
+				return nil, err
+			}
+			secrets[path] = data
+		}
+		value, ok := data[key]
+		if !ok {
+			return nil, fmt.Errorf("Vault密钥 %s 中没有键 %s", path, key)
+		}
+		if text, isString := value.(string); isString {
+			values[ref] = text
+		} else {
+			encoded, _ := json.Marshal(value)
+			values[ref] = string(encoded)
+		}
+	}
+	return values, nil
+}
+
+// parseVaultRef 解析 vault:<路径>#<键>
+func parseVaultRef(ref string) (string, string, error) {
+	path, key, found := strings.Cut(strings.TrimPrefix(ref, VaultRefPrefix), "#")
+	path = strings.Trim(path, "/")
+	if !strings.HasPrefix(ref, VaultRefPrefix) || !found || path == "" || key == "" {
+		return "", "", fmt.Errorf("Vault引用 %q 格式错误（应为 vault:<路径>#<键>）", ref)
+	}
+	return path, key, nil
+}
+
+// readVaultSecret 读取密钥数据（KV v2返回data.data），令牌失效时重新登录一次
+func readVaultSecret(vault VaultConfig, path string) (map[string]interface{}, error) {
+	token, err := vaultToken(vault, false)
+	if err != nil {
+		return nil, err
+	}
+	status, body, err := vaultRequest(vault, http.MethodGet, path, token, nil)
+	if err == nil && status == http.StatusForbidden {
+		if token, err = vaultToken(vault, true); err != nil {
+			return nil, err
+		}
+		status, body, err = vaultRequest(vault, http.MethodGet, path, token, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取Vault密钥 %s 失败: %v", path, err)
+	}
+	if status == http.StatusNotFound {
+		return nil, fmt.Errorf("Vault密钥 %s 不存在", path)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("读取Vault密钥 %s 失败: 状态码 %d: %s", path, status, string(body))
+	}
+
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return nil, fmt.Errorf("解析Vault密钥 %s 失败: %v", path, err)
+	}
+	if nested, ok := secret.Data["data"].(map[string]interface{}); ok {
+		if _, isV2 := secret.Data["metadata"]; isV2 {
+			return nested, nil
+		}
+	}
+	return secret.Data, nil
+}
+
+// vaultToken 获取登录令牌，过期前30秒或force时重新登录
+func vaultToken(vault VaultConfig, force bool) (string, error) {
+	vaultState.Lock()
+	defer vaultState.Unlock()
+	if !force && vaultState.token != "" && reflect.DeepEqual(vaultState.tokenConfig, vault) && time.Until(vaultState.tokenExpiry) > 30*time.Second {
+		return vaultState.token, nil
+	}
+
+	payload := map[string]string{}
+	switch vault.Auth.Method {
+	case "approle":
+		payload["role_id"] = vault.Auth.RoleID
+		payload["secret_id"] = vault.Auth.SecretID
+		if vault.Auth.SecretIDFile != "" {
+			data, err := os.ReadFile(vault.Auth.SecretIDFile)
+			if err != nil {
+				return "", fmt.Errorf("读取Vault secret_id文件失败: %v", err)
+			}
+			payload["secret_id"] = strings.TrimSpace(string(data))
+		}
+	case "kubernetes":
+		tokenFile := vault.Auth.TokenFile
+		if tokenFile == "" {
+			tokenFile = defaultVaultTokenFile
+		}
+		data, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return "", fmt.Errorf("读取ServiceAccount令牌失败: %v", err)
+		}
+		payload["role"] = vault.Auth.Role
+		payload["jwt"] = strings.TrimSpace(string(data))
+	}
+
+	body, _ := json.Marshal(payload)
+	status, respBody, err := vaultRequest(vault, http.MethodPost, "auth/"+vault.Auth.GetMount()+"/login", "", body)
+	if err != nil {
+		return "", fmt.Errorf("登录Vault失败: %v", err)
+	}
+	if status != http.StatusOK {
+		return "", fmt.Errorf("登录Vault失败: 状态码 %d: %s", status, string(respBody))
+	}
+	var login struct {
+		Auth struct {
+			ClientToken   string `json:"client_token"`
+			LeaseDuration int    `json:"lease_duration"`
+		} `json:"auth"`
+	}
+	if err := json.Unmarshal(respBody, &login); err != nil || login.Auth.ClientToken == "" {
+		return "", fmt.Errorf("解析Vault登录结果失败: %s", string(respBody))
+	}
+
+	vaultState.tokenConfig = vault
+	vaultState.token = login.Auth.ClientToken
+	vaultState.tokenExpiry = time.Now().Add(time.Duration(login.Auth.LeaseDuration) * time.Second)
+	if login.Auth.LeaseDuration <= 0 {
+		vaultState.tokenExpiry = time.Now().Add(24 * time.Hour) // 不过期的令牌（如root）
+	}
+	return vaultState.token, nil
+}
+
+// vaultRequest 发送Vault API请求（config包不能依赖common，这里直接使用net/http）
+func vaultRequest(vault VaultConfig, method, path, token string, body []byte) (int, []byte, error) {
+	client, err := vaultHTTPClient(vault)
+	if err != nil {
+		return 0, nil, err
+	}
+	req, err := http.NewRequest(method, strings.TrimSuffix(vault.Address, "/")+"/v1/"+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if vault.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", vault.Namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	return resp.StatusCode, respBody, err
+}
+
+// vaultHTTPClient 按配置创建HTTP客户端（配置了ca_cert时只信任该CA）
+func vaultHTTPClient(vault VaultConfig) (*http.Client, error) {
+	client := &http.Client{Timeout: vault.GetTimeout()}
+	if vault.CACert == "" {
+		return client, nil
+	}
+	data, err := os.ReadFile(vault.CACert)
+	if err != nil {
+		return nil, fmt.Errorf("读取Vault CA证书失败: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("Vault CA证书 %s 中没有有效证书", vault.CACert)
+	}
+	client.Transport = &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}
+	return client, nil
+}
+
+// writeVaultFiles 将vault.files引用的密钥写入本地文件，内容未变化时不重写
+func writeVaultFiles(vault VaultConfig, values map[string]string) error {
+	paths := make([]string, 0, len(vault.Files))
+	for path := range vault.Files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		content := values[vault.Files[path]]
+		if current, err := os.ReadFile(path); err == nil && string(current) == content {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return fmt.Errorf("创建目录 %s 失败: %v", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			return fmt.Errorf("写入Vault密钥文件 %s 失败: %v", path, err)
+		}
+		if err := os.Chmod(path, 0600); err != nil {
+			return fmt.Errorf("设置文件 %s 权限失败: %v", path, err)
+		}
+		log.Printf("已从Vault写入密钥文件: %s", path)
+	}
+	return nil
+}
+
+// saveVaultState 记录本次加载使用的Vault配置和密钥值
+func saveVaultState(vault VaultConfig, values map[string]string) {
+	vaultState.Lock()
+	defer vaultState.Unlock()
+	vaultState.config = vault
+	vaultState.values = values
+}
+
+// VaultSecretsRotated 重新读取当前配置引用的全部密钥，返回是否有密钥发生变化（需要重新加载配置）
+func VaultSecretsRotated() (bool, error) {
+	vaultState.Lock()
+	vault := vaultState.config
+	previous := vaultState.values
+	vaultState.Unlock()
+	if !vault.Enable || len(previous) == 0 {
+		return false, nil
+	}
+
+	refs := make([]string, 0, len(previous))
+	for ref := range previous {
+		refs = append(refs, ref)
+	}
+	current, err := readVaultRefs(vault, refs)
+	if err != nil {
+		return false, err
+	}
+	for ref, value := range current {
+		if previous[ref] != value {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// walkConfigStrings 遍历结构体、指针、列表和map中的全部字符串（只处理导出字段，跳过vault配置），用fn的返回值替换
+func walkConfigStrings(value reflect.Value, fn func(string) (string, error)) error {
+	switch value.Kind() {
+	case reflect.String:
+		replaced, err := fn(value.String())
+		if err != nil {
+			return err
+		}
+		if value.CanSet() && replaced != value.String() {
+			value.SetString(replaced)
+		}
+	case reflect.Ptr:
+		if !value.IsNil() {
+			return walkConfigStrings(value.Elem(), fn)
+		}
+	case reflect.Struct:
+		if value.Type() == reflect.TypeOf(VaultConfig{}) {
+			return nil // vault本身的配置不解析引用，files中的引用单独处理
+		}
+		for i := 0; i < value.NumField(); i++ {
+			if value.Type().Field(i).IsExported() {
+				if err := walkConfigStrings(value.Field(i), fn); err != nil {
+					return err
+				}
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			if err := walkConfigStrings(value.Index(i), fn); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		for _, key := range value.MapKeys() {
+			// map中的值不可寻址，复制后处理再写回
+			elem := reflect.New(value.Type().Elem()).Elem()
+			elem.Set(value.MapIndex(key))
+			if err := walkConfigStrings(elem, fn); err != nil {
+				return err
+			}
+			value.SetMapIndex(key, elem)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// WarmupConfig JVM预热：配置的项目在流量切换（步骤15）真正切换之前，持续向新版本发送预热请求，
+// 让JIT编译、连接池和缓存在接收生产流量前完成初始化，避免切换瞬间的延迟尖刺
+type WarmupConfig struct {
+	Projects map[string]WarmupProjectConfig `yaml:"projects"` // 项目名 -> 预热配置，只有配置的项目预热
+}
+
+// WarmupProjectConfig 项目的预热配置
+type WarmupProjectConfig struct {
+	Duration    string          `yaml:"duration"`    // 预热时长，默认30s
+	Concurrency int             `yaml:"concurrency"` // 每个目标的并发请求数，默认2
+	Timeout     string          `yaml:"timeout"`     // 单个请求超时，默认10s
+	BaseURL     string          `yaml:"base_url"`    // 预热地址前缀（支持{namespace}和{gateway}）；为空时直接请求新版本的每个pod
+	Port        int             `yaml:"port"`        // 直接请求pod时的端口，默认8080
+	Selector    string          `yaml:"selector"`    // 直接请求pod时的标签选择器（如 app=gateway），默认namespace中全部运行中的pod
+	Requests    []WarmupRequest `yaml:"requests"`    // 预热请求，按顺序循环发送
+}
+
+// WarmupRequest 预热请求，path和body中的{tag}替换为镜像标签
+type WarmupRequest struct {
+	Method  string            `yaml:"method"` // 默认GET
+	Path    string            `yaml:"path"`
+	Headers map[string]string `yaml:"headers"`
+	Body    string            `yaml:"body"`
+}
+
+// GetWarmupProject 获取项目的预热配置（已填充默认值），未配置时返回false
+func (c *Config) GetWarmupProject(project string) (WarmupProjectConfig, bool) {
+	projectConfig, ok := c.Warmup.Projects[project]
+	if !ok || len(projectConfig.Requests) == 0 {
+		return WarmupProjectConfig{}, false
+	}
+	if projectConfig.Concurrency <= 0 {
+		projectConfig.Concurrency = 2
+	}
+	if projectConfig.Port <= 0 {
+		projectConfig.Port = 8080
+	}
+	requests := make([]WarmupRequest, len(projectConfig.Requests))
+	for i, request := range projectConfig.Requests {
+		request.Method = strings.ToUpper(request.Method)
+		if request.Method == "" {
+			request.Method = http.MethodGet
+		}
+		requests[i] = request
+	}
+	projectConfig.Requests = requests
+	return projectConfig, true
+}
+
+// GetDuration 获取预热时长，默认30s
+func (w WarmupProjectConfig) GetDuration() time.Duration {
+	return parseDurationOrDefault(w.Duration, 30*time.Second)
+}
+
+// GetTimeout 获取单个预热请求的超时，默认10s
+func (w WarmupProjectConfig) GetTimeout() time.Duration {
+	return parseDurationOrDefault(w.Timeout, 10*time.Second)
+}
+
+// validateWarmup 校验预热配置
+func validateWarmup(warmup WarmupConfig) error {
+	for project, projectConfig := range warmup.Projects {
+		for i, request := range projectConfig.Requests {
+			if !strings.HasPrefix(request.Path, "/") {
+				return fmt.Errorf("项目 %s 的第%d个预热请求path应以/开头", project, i+1)
+			}
+		}
+	}
+	return nil
+}
@@ -1,44 +1,228 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"cicd-agent/common"
 	"cicd-agent/config"
 	"cicd-agent/router"
+	"cicd-agent/taskCenter"
+
+	"gopkg.in/yaml.v3"
 )
 
+// logLevelFlag 启动参数指定的日志级别，非空时优先于logging.level（重新加载配置后保持不变）
+var logLevelFlag string
+
 func main() {
+	flags := flag.NewFlagSet("cicd-agent", flag.ExitOnError)
+	configPath := flags.String("config", "config/config.yaml", "配置文件路径")
+	flags.StringVar(&logLevelFlag, "log-level", "", "服务日志级别 debug/info/warning/error，默认使用logging.level")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "用法: cicd-agent [--config 配置文件] [--log-level 级别] [命令]")
+		fmt.Fprintln(os.Stderr, "\n命令:")
+		fmt.Fprintln(os.Stderr, "  run              启动服务（默认）")
+		fmt.Fprintln(os.Stderr, "  version          输出版本信息")
+		fmt.Fprintln(os.Stderr, "  validate-config  解析并校验配置文件后退出，配置错误时退出码为1")
+		fmt.Fprintln(os.Stderr, "  print-config     输出解析后的配置（密码、密钥、令牌等已脱敏）")
+		fmt.Fprintln(os.Stderr, "\n参数:")
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[1:])
+	if logLevelFlag != "" {
+		if err := common.SetLogLevel(logLevelFlag); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	switch command := flags.Arg(0); command {
+	case "", "run":
+		run(*configPath)
+	case "version":
+		fmt.Println(versionString())
+	case "validate-config":
+		if _, err := config.LoadConfig(*configPath); err != nil {
+			fmt.Fprintf(os.Stderr, "配置校验失败: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("配置校验通过:", *configPath)
+	case "print-config":
+		printConfig(*configPath)
+	default:
+		fmt.Fprintf(os.Stderr, "未知命令: %s\n\n", command)
+		flags.Usage()
+		os.Exit(2)
+	}
+}
+
+// run 启动服务
+func run(configPath string) {
 	// 初始化配置
-	if _, err := config.LoadConfig(""); err != nil {
+	if _, err := config.LoadConfig(configPath); err != nil {
 		log.Fatalf("加载配置失败: %v", err)
 	}
 
 	// 初始化日志
 	common.InitLogger()
+	common.SetLogFormat(config.AppConfig.GetLogFormat())
+	applyLogLevel()
+	applyLocale()
+	common.AppLogger.Info(versionString())
 
-	// 启动日志清理定时任务（保留7天）
-	common.StartLogCleanupRoutine(7)
+	// 启动日志清理定时任务（保留天数与执行时间见logging配置）
+	common.StartLogCleanupRoutine(common.CurrentLogRetention())
 
 	// 初始化IP白名单
 	common.InitWhitelist()
 
+	// 多节点部署时选主，只有主节点处理回调和任务接口（见leader_election配置）
+	common.StartLeaderElection()
+
+	// 路由模式下定期检查目标agent的健康状态（见routing配置）
+	common.StartRoutingHealthCheck()
+
+	// 启动通知重试队列（补发上次运行未送达的通知）
+	common.StartNotifyQueue()
+
+	// 启动部署汇总报告定时任务（见notification.report配置）
+	common.StartReportScheduler()
+
+	// 恢复上次运行未执行的计划部署任务
+	taskCenter.RestoreScheduledTasks()
+
+	// 监听SIGHUP信号重新加载配置
+	go watchReloadSignal()
+
+	// 定期检查Vault密钥轮换，轮换后重新加载配置（见vault配置）
+	common.StartVaultWatcher(reloadConfig)
+
 	// 设置路由
 	r := router.SetupRouter()
 
+	// 指标接口使用独立端口时单独启动
+	if config.AppConfig.Metrics.Listen != "" {
+		common.StartMetricsServer(config.AppConfig.Metrics.Listen)
+	}
+
 	// 输出配置信息
 	printConfigInfo()
 
 	// 启动服务器
-	addr := config.AppConfig.Server.Host + ":" + config.AppConfig.Server.Port
-	common.AppLogger.Info("启动CICD代理服务", "地址: "+addr)
+	addr := config.AppConfig.GetServerAddr()
+	server, err := common.NewHTTPServer(addr, r)
+	if err != nil {
+		log.Fatalf("创建HTTP服务失败: %v", err)
+	}
+
+	if server.TLSConfig != nil {
+		common.AppLogger.Info("启动CICD代理服务(HTTPS)", "地址: "+addr)
+	} else {
+		common.AppLogger.Info("启动CICD代理服务", "地址: "+addr)
+	}
 
-	if err := r.Run(addr); err != nil {
+	if err := common.RunHTTPServer(server); err != nil {
 		common.AppLogger.Error("启动服务器失败:", err)
 		log.Fatalf("启动服务器失败: %v", err)
 	}
 }
 
+// watchReloadSignal 收到SIGHUP时重新加载配置
+func watchReloadSignal() {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGHUP)
+
+	for range sigChan {
+		common.AppLogger.Info("收到SIGHUP信号，重新加载配置")
+		reloadConfig()
+	}
+}
+
+// reloadConfig 重新加载配置（含Vault密钥）并重新启动依赖配置的定时任务，失败时保留原配置
+func reloadConfig() {
+	done := common.NotifyReloading()
+	defer done()
+
+	if _, err := config.ReloadConfig(); err != nil {
+		common.AppLogger.Error("重新加载配置失败:", err)
+		return
+	}
+	common.SetLogFormat(config.AppConfig.GetLogFormat())
+	applyLogLevel()
+	applyLocale()
+	common.StartLogCleanupRoutine(common.CurrentLogRetention())
+	common.StartReportScheduler()
+	common.StartLeaderElection()
+	common.StartRoutingHealthCheck()
+	common.StartVaultWatcher(reloadConfig)
+}
+
+// applyLogLevel 未通过启动参数指定日志级别时使用logging.level
+func applyLogLevel() {
+	if logLevelFlag != "" {
+		return
+	}
+	if err := common.SetLogLevel(config.AppConfig.Logging.Level); err != nil {
+		common.AppLogger.Warning(err)
+	}
+}
+
+// applyLocale 按locale配置切换日志和通知的语言
+func applyLocale() {
+	if err := common.SetLocale(config.AppConfig.GetLocale()); err != nil {
+		common.AppLogger.Warning(err)
+	}
+}
+
+// printConfig 加载配置并输出脱敏后的YAML
+func printConfig(configPath string) {
+	if _, err := config.LoadConfig(configPath); err != nil {
+		fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
+		os.Exit(1)
+	}
+	redacted, err := config.AppConfig.Redacted()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	var node yaml.Node
+	if err := node.Encode(redacted); err != nil {
+		fmt.Fprintf(os.Stderr, "序列化配置失败: %v\n", err)
+		os.Exit(1)
+	}
+	pruneEmptyNodes(&node)
+	data, err := yaml.Marshal(&node)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "序列化配置失败: %v\n", err)
+		os.Exit(1)
+	}
+	os.Stdout.Write(data)
+}
+
+// pruneEmptyNodes 删除未配置的项（空字符串、null、空列表和空map），只保留实际配置的内容
+func pruneEmptyNodes(node *yaml.Node) bool {
+	switch node.Kind {
+	case yaml.MappingNode:
+		content := node.Content[:0]
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if !pruneEmptyNodes(node.Content[i+1]) {
+				content = append(content, node.Content[i], node.Content[i+1])
+			}
+		}
+		node.Content = content
+		return len(content) == 0
+	case yaml.SequenceNode:
+		return len(node.Content) == 0
+	case yaml.ScalarNode:
+		return node.Tag == "!!null" || (node.Tag == "!!str" && node.Value == "")
+	}
+	return false
+}
+
 // printConfigInfo 输出配置信息
 func printConfigInfo() {
 	log.Println("========================================")
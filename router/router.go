@@ -2,7 +2,9 @@ package router
 
 import (
 	"cicd-agent/common"
+	"cicd-agent/config"
 	"cicd-agent/taskCenter"
+	"cicd-agent/ui"
 	"github.com/gin-gonic/gin"
 )
 
@@ -13,30 +15,280 @@ func SetupRouter() *gin.Engine {
 
 	r := gin.New()
 
+	// 客户端IP统一由common.GetClientIP按server.trusted_proxies判断，gin自身不信任任何代理头
+	r.SetTrustedProxies(nil)
+
 	// 添加中间件
-	r.Use(gin.Logger())
+	r.Use(common.RequestIDMiddleware())
+	r.Use(common.AccessLogMiddleware())
+	r.Use(common.MetricsMiddleware())
 	r.Use(gin.Recovery())
+	r.Use(common.CORSMiddleware())
+	r.Use(common.RateLimitMiddleware())
 
-	// API路由组
-	apiGroup := r.Group("/")
+	// 接口处理链（/api/v1 与兼容的旧路径共用）
+	// 白名单按接口分组（whitelist.lists），未单独配置的分组使用默认白名单
+	// 开启auth时各接口还需携带对应权限的API Key或JWT
+	// 变更类接口均经过审计中间件（记录在白名单校验之前，被拒绝的调用同样留痕）
+	updateHandlers := []gin.HandlerFunc{ // IP白名单和/或客户端证书验证
+		common.AuditMiddleware(),
+		common.CallerAuthMiddleware("update"),
+		common.RequireScope(common.ScopeDeploy),
+		taskCenter.HandleUpdate,
+	}
+	callbackHandlers := []gin.HandlerFunc{ // IP白名单和/或客户端证书验证，开启签名时校验HMAC签名
+		common.AuditMiddleware(),
+		common.CallerAuthMiddleware("callback"),
+		common.RequireScope(common.ScopeDeploy),
+		common.CallbackSignatureMiddleware(),
+		taskCenter.HandleCallback,
+	}
+	batchHandlers := []gin.HandlerFunc{ // IP白名单和/或客户端证书验证
+		common.AuditMiddleware(),
+		common.CallerAuthMiddleware("update"),
+		common.RequireScope(common.ScopeDeploy),
+		taskCenter.HandleBatchDeploy,
+	}
+	batchStatusHandlers := []gin.HandlerFunc{ // IP白名单验证
+		common.IPWhitelistMiddleware("logs"),
+		common.RequireScope(common.ScopeLogs),
+		taskCenter.HandleBatchStatus,
+	}
+	cancelHandlers := []gin.HandlerFunc{ // IP白名单和/或客户端证书验证
+		common.AuditMiddleware(),
+		common.CallerAuthMiddleware("cancel"),
+		common.RequireScope(common.ScopeCancel),
+		taskCenter.HandleCancel,
+	}
+	retryHandlers := []gin.HandlerFunc{ // IP白名单和/或客户端证书验证
+		common.AuditMiddleware(),
+		common.CallerAuthMiddleware("update"),
+		common.RequireScope(common.ScopeDeploy),
+		taskCenter.HandleTaskRetry,
+	}
+	rerunHandlers := []gin.HandlerFunc{ // IP白名单和/或客户端证书验证
+		common.AuditMiddleware(),
+		common.CallerAuthMiddleware("update"),
+		common.RequireScope(common.ScopeDeploy),
+		taskCenter.HandleStepRerun,
+	}
+	switchHandlers := []gin.HandlerFunc{ // IP白名单和/或客户端证书验证
+		common.AuditMiddleware(),
+		common.CallerAuthMiddleware("update"),
+		common.RequireScope(common.ScopeDeploy),
+		taskCenter.HandleProjectSwitch,
+	}
+	cleanupHandlers := []gin.HandlerFunc{ // IP白名单和/或客户端证书验证
+		common.AuditMiddleware(),
+		common.CallerAuthMiddleware("update"),
+		common.RequireScope(common.ScopeDeploy),
+		taskCenter.HandleProjectCleanup,
+	}
+	healthCheckHandlers := []gin.HandlerFunc{ // IP白名单和/或客户端证书验证
+		common.AuditMiddleware(),
+		common.CallerAuthMiddleware("update"),
+		common.RequireScope(common.ScopeDeploy),
+		taskCenter.HandleProjectHealthCheck,
+	}
+	pauseHandlers := []gin.HandlerFunc{ // IP白名单和/或客户端证书验证
+		common.AuditMiddleware(),
+		common.CallerAuthMiddleware("cancel"),
+		common.RequireScope(common.ScopeCancel),
+		taskCenter.HandleTaskPause,
+	}
+	resumeHandlers := []gin.HandlerFunc{ // IP白名单和/或客户端证书验证
+		common.AuditMiddleware(),
+		common.CallerAuthMiddleware("cancel"),
+		common.RequireScope(common.ScopeCancel),
+		taskCenter.HandleTaskResume,
+	}
+	approveHandlers := []gin.HandlerFunc{ // IP白名单和/或客户端证书验证
+		common.AuditMiddleware(),
+		common.CallerAuthMiddleware("update"),
+		common.RequireScope(common.ScopeDeploy),
+		taskCenter.HandleTaskApprove,
+	}
+	rejectHandlers := []gin.HandlerFunc{ // IP白名单和/或客户端证书验证
+		common.AuditMiddleware(),
+		common.CallerAuthMiddleware("cancel"),
+		common.RequireScope(common.ScopeCancel),
+		taskCenter.HandleTaskReject,
+	}
+	rollbackHandlers := []gin.HandlerFunc{ // IP白名单和/或客户端证书验证
+		common.AuditMiddleware(),
+		common.CallerAuthMiddleware("update"),
+		common.RequireScope(common.ScopeDeploy),
+		taskCenter.HandleTaskRollback,
+	}
+	taskStepsHandlers := []gin.HandlerFunc{ // IP白名单验证
+		common.IPWhitelistMiddleware("logs"),
+		common.RequireScope(common.ScopeLogs),
+		taskCenter.HandleTaskSteps,
+	}
+	logTokenHandlers := []gin.HandlerFunc{ // IP白名单验证
+		common.IPWhitelistMiddleware("logs"),
+		common.RequireScope(common.ScopeLogs),
+		taskCenter.HandleTaskLogToken,
+	}
+	logSearchHandlers := []gin.HandlerFunc{ // IP白名单验证
+		common.IPWhitelistMiddleware("logs"),
+		common.RequireScope(common.ScopeLogs),
+		taskCenter.HandleLogSearch,
+	}
+	logDownloadHandlers := []gin.HandlerFunc{ // IP白名单验证
+		common.IPWhitelistMiddleware("logs"),
+		common.RequireScope(common.ScopeLogs),
+		taskCenter.HandleLogDownload,
+	}
+	durationHandlers := []gin.HandlerFunc{ // IP白名单验证
+		common.IPWhitelistMiddleware("logs"),
+		common.RequireScope(common.ScopeLogs),
+		taskCenter.HandleProjectDurations,
+	}
+	taskListHandlers := []gin.HandlerFunc{ // IP白名单验证
+		common.IPWhitelistMiddleware("logs"),
+		common.RequireScope(common.ScopeLogs),
+		taskCenter.HandleTaskList,
+	}
+	projectListHandlers := []gin.HandlerFunc{ // IP白名单验证
+		common.IPWhitelistMiddleware("logs"),
+		common.RequireScope(common.ScopeLogs),
+		taskCenter.HandleProjectList,
+	}
+	versionHandlers := []gin.HandlerFunc{ // IP白名单验证
+		common.IPWhitelistMiddleware("logs"),
+		common.RequireScope(common.ScopeLogs),
+		taskCenter.HandleProjectVersion,
+	}
+	historyHandlers := []gin.HandlerFunc{ // IP白名单验证
+		common.IPWhitelistMiddleware("logs"),
+		common.RequireScope(common.ScopeLogs),
+		taskCenter.HandleProjectHistory,
+	}
+	manifestDiffHandlers := []gin.HandlerFunc{ // IP白名单验证
+		common.IPWhitelistMiddleware("logs"),
+		common.RequireScope(common.ScopeLogs),
+		taskCenter.HandleTaskManifestDiff,
+	}
+	routingStatusHandlers := []gin.HandlerFunc{ // IP白名单验证
+		common.IPWhitelistMiddleware("logs"),
+		common.RequireScope(common.ScopeLogs),
+		taskCenter.HandleRoutingStatus,
+	}
+	auditHandlers := []gin.HandlerFunc{ // IP白名单验证
+		common.IPWhitelistMiddleware("admin"),
+		common.RequireScope(common.ScopeAdmin),
+		taskCenter.HandleAuditQuery,
+	}
+
+	// 日志查看接口（WebSocket/SSE）
+	wsHandlers := []gin.HandlerFunc{common.RequireScopeOrLogTicket(common.ScopeLogs), common.TaskLogWebSocket}
+	sseHandlers := []gin.HandlerFunc{common.RequireScopeOrLogTicket(common.ScopeLogs), common.TaskLogSSE}
+
+	// 选主状态接口（各节点自己处理，不转发到主节点）
+	r.GET("/api/v1/leader", common.IPWhitelistMiddleware("logs"), common.RequireScope(common.ScopeLogs), taskCenter.HandleLeaderStatus)
+
+	// 就绪检查详情（各节点自己处理，不转发到主节点）
+	r.GET("/api/v1/ready", common.IPWhitelistMiddleware("logs"), common.RequireScope(common.ScopeLogs), taskCenter.HandleReadyDetail)
+
+	// v1接口（开启选主时备用节点转发、重定向到主节点或拒绝，见leader_election配置）
+	v1 := r.Group("/api/v1", common.LeaderMiddleware(), common.APIVersionMiddleware())
 	{
-		// /update 接口 - 只需要IP白名单验证
-		apiGroup.POST("/update",
-			common.IPWhitelistMiddleware(),
-			taskCenter.HandleUpdate,
-		)
+		v1.POST("/update", updateHandlers...)
+		v1.POST("/callback", callbackHandlers...)
+		v1.POST("/batch", batchHandlers...)
+		v1.GET("/batch/:id", batchStatusHandlers...)
+		v1.POST("/task/cancel", cancelHandlers...)
+		v1.POST("/task/:id/retry", retryHandlers...)
+		v1.POST("/task/:id/steps/:stepType/rerun", rerunHandlers...)
+		v1.POST("/task/:id/pause", pauseHandlers...)
+		v1.POST("/task/:id/resume", resumeHandlers...)
+		v1.POST("/task/:id/approve", approveHandlers...)
+		v1.POST("/task/:id/reject", rejectHandlers...)
+		v1.POST("/task/:id/rollback", rollbackHandlers...)
+		v1.GET("/task/:id/steps", taskStepsHandlers...)
+		v1.GET("/task/:id/log-token", logTokenHandlers...)
+		v1.GET("/task/:id/manifest-diff", manifestDiffHandlers...)
+		v1.GET("/tasks", taskListHandlers...)
+		v1.GET("/logs/search", logSearchHandlers...)
+		v1.GET("/logs/download", logDownloadHandlers...)
+		v1.GET("/projects", projectListHandlers...)
+		v1.GET("/projects/:project/durations", durationHandlers...)
+		v1.GET("/project/:project/version", versionHandlers...)
+		v1.GET("/project/:project/history", historyHandlers...)
+		v1.POST("/project/:project/switch", switchHandlers...)
+		v1.POST("/project/:project/cleanup", cleanupHandlers...)
+		v1.POST("/project/:project/healthcheck", healthCheckHandlers...)
+		v1.GET("/audit", auditHandlers...)
+		v1.GET("/routing", routingStatusHandlers...)
+		v1.GET("/ws/task/logs", wsHandlers...)
+		v1.GET("/sse/task/logs", sseHandlers...)
+	}
 
-		// /callback 接口 - 只需要IP白名单验证
-		apiGroup.POST("/callback",
-			common.IPWhitelistMiddleware(),
-			taskCenter.HandleCallback,
-		)
+	// 白名单管理接口（admin白名单 + admin权限，操作均记录审计）
+	whitelistGroup := v1.Group("/whitelist",
+		common.AuditMiddleware(),
+		common.IPWhitelistMiddleware("admin"),
+		common.RequireScope(common.ScopeAdmin),
+	)
+	{
+		whitelistGroup.GET("", taskCenter.HandleWhitelistView)
+		whitelistGroup.POST("/temp", taskCenter.HandleWhitelistAddTemp)
+		whitelistGroup.DELETE("/temp", taskCenter.HandleWhitelistRemoveTemp)
+		whitelistGroup.POST("/refresh", taskCenter.HandleWhitelistRefresh)
+	}
 
-		// /cancel 接口 - 只需要IP白名单验证
-		apiGroup.POST("/api/task/cancel",
-			common.IPWhitelistMiddleware(),
-			taskCenter.HandleCancel,
-		)
+	// 封网管理接口（admin白名单 + admin权限，操作均记录审计）
+	freezeGroup := v1.Group("/freeze",
+		common.AuditMiddleware(),
+		common.IPWhitelistMiddleware("admin"),
+		common.RequireScope(common.ScopeAdmin),
+	)
+	{
+		freezeGroup.GET("", taskCenter.HandleFreezeView)
+		freezeGroup.POST("", taskCenter.HandleFreezeUpdate)
+	}
+
+	// 飞书卡片按钮回调（飞书服务器调用，不经过IP白名单，由Verification Token和按钮签名校验）
+	v1.POST("/feishu/card-action", common.AuditMiddleware(), taskCenter.HandleFeishuCardAction)
+
+	// 代码托管平台Webhook（不经过IP白名单，由各平台的Secret Token校验）
+	v1.POST("/webhook/gitlab", common.AuditMiddleware(), taskCenter.HandleGitLabWebhook)
+	v1.POST("/webhook/github", common.AuditMiddleware(), taskCenter.HandleGitHubWebhook)
+
+	// Jenkins构建通知（迁移期间旧Jenkins任务直接调用，由远程触发令牌或Basic认证+Crumb校验）
+	v1.GET("/jenkins/crumbIssuer/api/json", taskCenter.HandleJenkinsCrumb)
+	v1.POST("/jenkins/notify", common.AuditMiddleware(), taskCenter.HandleJenkinsNotify)
+
+	// 兼容旧路径（滚动升级期间中心服务仍使用旧路径调用）
+	legacy := r.Group("/", common.LeaderMiddleware(), common.APIVersionMiddleware())
+	{
+		legacy.POST("/update", updateHandlers...)
+		legacy.POST("/callback", callbackHandlers...)
+		legacy.GET("/jenkins/crumbIssuer/api/json", taskCenter.HandleJenkinsCrumb)
+		legacy.POST("/jenkins/notify", common.AuditMiddleware(), taskCenter.HandleJenkinsNotify)
+		legacy.POST("/api/task/cancel", cancelHandlers...)
+		legacy.POST("/api/task/:id/retry", retryHandlers...)
+		legacy.POST("/api/task/:id/steps/:stepType/rerun", rerunHandlers...)
+		legacy.POST("/api/task/:id/pause", pauseHandlers...)
+		legacy.POST("/api/task/:id/resume", resumeHandlers...)
+		legacy.GET("/api/task/:id/manifest-diff", manifestDiffHandlers...)
+		legacy.GET("/api/tasks", taskListHandlers...)
+		legacy.GET("/api/logs/search", logSearchHandlers...)
+		legacy.GET("/api/projects", projectListHandlers...)
+		legacy.GET("/api/projects/:project/durations", durationHandlers...)
+		legacy.GET("/api/project/:project/version", versionHandlers...)
+		legacy.GET("/api/project/:project/history", historyHandlers...)
+		legacy.POST("/api/project/:project/switch", switchHandlers...)
+		legacy.POST("/api/project/:project/cleanup", cleanupHandlers...)
+		legacy.POST("/api/project/:project/healthcheck", healthCheckHandlers...)
+		legacy.GET("/api/audit", auditHandlers...)
+
+		// WebSocket日志查看接口
+		legacy.GET("/ws/task/logs", wsHandlers...)
+
+		// SSE日志查看接口（供无法使用WebSocket的客户端）
+		legacy.GET("/sse/task/logs", sseHandlers...)
 	}
 
 	// 健康检查接口（不需要认证）
@@ -47,8 +299,26 @@ func SetupRouter() *gin.Engine {
 		})
 	})
 
-	// WebSocket日志查看接口
-	r.GET("/ws/task/logs", common.TaskLogWebSocket)
+	// 就绪检查接口（不需要认证）：检查docker、kubectl、Harbor和日志目录，只返回各项状态
+	r.GET("/ready", taskCenter.HandleReady)
+
+	// 指标接口（未配置独立端口时挂在主服务上）
+	if config.AppConfig.Metrics.Listen == "" {
+		r.GET(config.AppConfig.GetMetricsPath(), common.MetricsHandler)
+	}
+
+	// 内置管理界面（静态页面，数据和操作经由上面的v1接口）
+	if config.AppConfig.UIEnabled() {
+		ui.Register(r, common.IPWhitelistMiddleware("logs"))
+	}
+
+	// 调试接口 - IP白名单或管理令牌
+	debugGroup := r.Group("/debug", common.AdminAccessMiddleware())
+	{
+		debugGroup.GET("/pprof/*name", common.PprofHandler)
+		debugGroup.POST("/pprof/*name", common.PprofHandler)
+		debugGroup.GET("/vars", common.DebugVarsHandler)
+	}
 
 	return r
 }
@@ -0,0 +1,33 @@
+package taskCenter
+
+import (
+	"cicd-agent/common"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleTaskApprove 批准任务等待中的流量切换（与飞书卡片的批准按钮等效）
+// POST /api/v1/task/:id/approve
+func HandleTaskApprove(c *gin.Context) {
+	resolveTaskApproval(c, true)
+}
+
+// HandleTaskReject 拒绝任务等待中的流量切换
+// POST /api/v1/task/:id/reject
+func HandleTaskReject(c *gin.Context) {
+	resolveTaskApproval(c, false)
+}
+
+// resolveTaskApproval 处理审批请求
+func resolveTaskApproval(c *gin.Context, approved bool) {
+	taskID, operator := c.Param("id"), taskOperator(c)
+	msg, err := common.ResolveTrafficApproval(taskID, approved, operator)
+	if err != nil {
+		c.JSON(http.StatusConflict, Response{Code: 409, Msg: err.Error()})
+		return
+	}
+	common.RequestLogger(c).Info(fmt.Sprintf("流量切换审批: 任务ID=%s, 批准=%t, 操作人=%s", taskID, approved, operator))
+	c.JSON(http.StatusOK, Response{Code: 200, Msg: msg})
+}
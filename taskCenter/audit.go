@@ -0,0 +1,41 @@
+package taskCenter
+
+import (
+	"cicd-agent/common"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	auditDefaultLimit = 100
+	auditMaxLimit     = 1000
+)
+
+// HandleAuditQuery 查询审计记录
+// GET /api/audit?since=24h&path=/callback&ip=10.0.0.1&limit=100
+func HandleAuditQuery(c *gin.Context) {
+	query := common.AuditQuery{
+		Path:     c.Query("path"),
+		ClientIP: c.Query("ip"),
+		Limit:    boundedIntQuery(c, "limit", auditDefaultLimit, 1, auditMaxLimit),
+	}
+
+	if since := c.Query("since"); since != "" {
+		sinceTime, err := parseSince(since)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: err.Error()})
+			return
+		}
+		query.Since = sinceTime
+	}
+
+	entries, err := common.QueryAuditEntries(query)
+	if err != nil {
+		common.AppLogger.Error("查询审计记录失败:", err)
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Msg: "查询审计记录失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Code: 200, Msg: "查询成功", Data: entries})
+}
@@ -0,0 +1,381 @@
+package taskCenter
+
+import (
+	"cicd-agent/common"
+	"cicd-agent/config"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// batchRetention 已结束批次在内存中保留的时间
+const batchRetention = 24 * time.Hour
+
+// BatchItemResult 批次中单个项目的执行结果
+type BatchItemResult struct {
+	Project string `json:"project"`
+	Tag     string `json:"tag"`
+	Type    string `json:"type"`
+	TaskID  string `json:"task_id"`
+	Status  string `json:"status"` // pending/running/complete/failed/cancel/skipped
+	Message string `json:"message,omitempty"`
+}
+
+// BatchStatus 批量部署状态
+type BatchStatus struct {
+	BatchID    string            `json:"batch_id"`
+	Mode       string            `json:"mode"`
+	Status     string            `json:"status"` // running/complete/failed
+	CreatedAt  string            `json:"created_at"`
+	FinishedAt string            `json:"finished_at,omitempty"`
+	Items      []BatchItemResult `json:"items"`
+}
+
+// batchRun 执行中的批次
+type batchRun struct {
+	mu       sync.Mutex
+	status   BatchStatus
+	requests []CallbackRequest // 按依赖顺序排列
+	upstream [][]int           // 各项目在批次内的上游项目（requests中的下标）
+	opsURL   string
+}
+
+var (
+	batchesMu sync.Mutex
+	batches   = make(map[string]*batchRun)
+)
+
+// HandleBatchDeploy 批量部署多个项目，按deployment.batch.mode并行或依次执行，结束后发送一条汇总通知
+// 批次内的项目按deployment.dependencies的依赖顺序执行，上游部署未成功时下游直接跳过
+// POST /api/v1/batch
+func HandleBatchDeploy(c *gin.Context) {
+	logger := common.RequestLogger(c)
+
+	var req BatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("批量部署参数绑定失败:", err)
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: fmt.Sprintf("请求参数错误: %v", err)})
+		return
+	}
+	if max := config.AppConfig.GetBatchMaxItems(); len(req.Items) > max {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: fmt.Sprintf("批次项目数 %d 超过上限 %d", len(req.Items), max)})
+		return
+	}
+
+	if req.BatchID == "" {
+		req.BatchID = fmt.Sprintf("batch-%d", time.Now().UnixNano())
+	}
+	run, err := newBatchRun(req)
+	if err != nil {
+		logger.Error("批量部署参数校验失败:", err)
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: err.Error()})
+		return
+	}
+
+	// 任一项目当前不能部署时整个批次拒绝，避免协同发布只完成一部分
+	for _, item := range run.requests {
+		if allowed, reason := common.CheckDeployWindow(item.Project, time.Now()); !allowed {
+			logger.Warning(fmt.Sprintf("拒绝批量部署: 批次=%s, %s", req.BatchID, reason))
+			c.JSON(http.StatusForbidden, Response{Code: 403, Msg: fmt.Sprintf("拒绝部署: %s", reason)})
+			return
+		}
+	}
+
+	batchesMu.Lock()
+	if _, exists := batches[req.BatchID]; exists {
+		batchesMu.Unlock()
+		c.JSON(http.StatusConflict, Response{Code: 409, Msg: fmt.Sprintf("批次 %s 已存在", req.BatchID)})
+		return
+	}
+	pruneBatches()
+	batches[req.BatchID] = run
+	batchesMu.Unlock()
+
+	logger.Info(fmt.Sprintf("开始批量部署: 批次=%s, 方式=%s, 项目数=%d", req.BatchID, run.status.Mode, len(run.requests)))
+	go run.execute(common.GetRequestID(c))
+
+	c.JSON(http.StatusOK, Response{Code: 200, Msg: "批量部署已开始", Data: run.snapshot()})
+}
+
+// HandleBatchStatus 查询批量部署状态
+// GET /api/v1/batch/:id
+func HandleBatchStatus(c *gin.Context) {
+	batchesMu.Lock()
+	run, ok := batches[c.Param("id")]
+	batchesMu.Unlock()
+	if !ok {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Msg: "未找到对应的批次"})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 200, Msg: "查询成功", Data: run.snapshot()})
+}
+
+// newBatchRun 校验批次中的项目并生成各项目的任务参数
+func newBatchRun(req BatchRequest) (*batchRun, error) {
+	run := &batchRun{
+		status: BatchStatus{
+			BatchID:   req.BatchID,
+			Mode:      config.AppConfig.GetBatchMode(),
+			Status:    "running",
+			CreatedAt: time.Now().Format("2006-01-02 15:04:05"),
+		},
+		opsURL: req.UpdateFeishuURL,
+	}
+
+	seen := make(map[string]bool, len(req.Items))
+	for _, item := range req.Items {
+		if seen[item.Project] {
+			return nil, fmt.Errorf("项目 %s 在批次中重复", item.Project)
+		}
+		seen[item.Project] = true
+
+		deployType, err := resolveDeployType(item.Project, item.Type)
+		if err != nil {
+			return nil, err
+		}
+		// 各项目不单独发送飞书卡片，由批次汇总通知代替
+		taskReq := CallbackRequest{
+			Project:         item.Project,
+			Type:            deployType,
+			Category:        item.Category,
+			Status:          "success",
+			Tag:             item.Tag,
+			TaskID:          fmt.Sprintf("%s-%s", req.BatchID, item.Project),
+			CreateTime:      run.status.CreatedAt,
+			ProjectName:     item.ProjectName,
+			NotifyFeishuURL: req.NotifyFeishuURL,
+			BatchID:         req.BatchID,
+		}
+		run.requests = append(run.requests, taskReq)
+	}
+
+	if err := run.orderByDependencies(); err != nil {
+		return nil, err
+	}
+	for _, taskReq := range run.requests {
+		run.status.Items = append(run.status.Items, BatchItemResult{
+			Project: taskReq.Project,
+			Tag:     taskReq.Tag,
+			Type:    taskReq.Type,
+			TaskID:  taskReq.TaskID,
+			Status:  "pending",
+		})
+	}
+	return run, nil
+}
+
+// orderByDependencies 按批次内的项目依赖排序（无依赖关系的项目保持请求中的顺序），并记录各项目的上游
+func (b *batchRun) orderByDependencies() error {
+	index := make(map[string]int, len(b.requests))
+	for i, req := range b.requests {
+		index[req.Project] = i
+	}
+
+	// 只考虑同一批次内的依赖，上游不在批次中时视为已部署
+	pending := make([]int, len(b.requests))
+	downstream := make([][]int, len(b.requests))
+	for i, req := range b.requests {
+		for _, upstream := range config.AppConfig.GetProjectDependencies(req.Project) {
+			if j, ok := index[upstream]; ok {
+				pending[i]++
+				downstream[j] = append(downstream[j], i)
+			}
+		}
+	}
+
+	order := make([]int, 0, len(b.requests))
+	placed := make([]bool, len(b.requests))
+	for len(order) < len(b.requests) {
+		next := -1
+		for i := range b.requests {
+			if !placed[i] && pending[i] == 0 {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			return fmt.Errorf("批次中的项目存在循环依赖")
+		}
+		placed[next] = true
+		order = append(order, next)
+		for _, i := range downstream[next] {
+			pending[i]--
+		}
+	}
+
+	position := make([]int, len(b.requests))
+	for pos, i := range order {
+		position[i] = pos
+	}
+	requests := make([]CallbackRequest, len(order))
+	b.upstream = make([][]int, len(order))
+	for pos, i := range order {
+		requests[pos] = b.requests[i]
+		for _, upstream := range config.AppConfig.GetProjectDependencies(b.requests[i].Project) {
+			if j, ok := index[upstream]; ok {
+				b.upstream[pos] = append(b.upstream[pos], position[j])
+			}
+		}
+	}
+	b.requests = requests
+	return nil
+}
+
+// resolveDeployType 校验项目并确定部署类型（未指定时按配置判断）
+func resolveDeployType(project, deployType string) (string, error) {
+	if !config.AppConfig.IsValidProject(project) {
+		return "", fmt.Errorf("项目 %s 不在有效项目列表中", project)
+	}
+	if deployType == "web" || (deployType == "" && config.AppConfig.IsWebProject(project)) {
+		return "web", nil
+	}
+	if _, exists := config.AppConfig.GetProjectPath(project); !exists {
+		return "", fmt.Errorf("项目 %s 未配置部署目录", project)
+	}
+	if deployType != "" {
+		return deployType, nil
+	}
+	if config.AppConfig.IsDoubleProject(project) {
+		return "double", nil
+	}
+	return "single", nil
+}
+
+// execute 执行批次中的全部项目，结束后发送汇总通知
+func (b *batchRun) execute(requestID string) {
+	if b.status.Mode == "sequential" {
+		for i := range b.requests {
+			if b.runItem(i, requestID) != "complete" {
+				b.skipRemaining(i+1, fmt.Sprintf("%s 部署未成功，停止后续部署", b.requests[i].Project))
+				break
+			}
+		}
+	} else {
+		// 并行执行，各项目等待其上游结束后开始
+		done := make([]chan struct{}, len(b.requests))
+		for i := range done {
+			done[i] = make(chan struct{})
+		}
+		var wg sync.WaitGroup
+		for i := range b.requests {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				defer close(done[i])
+				for _, j := range b.upstream[i] {
+					<-done[j]
+					if status := b.itemStatus(j); status != "complete" {
+						b.setItemStatus(i, "skipped", fmt.Sprintf("上游项目 %s 部署未成功", b.requests[j].Project))
+						return
+					}
+				}
+				b.runItem(i, requestID)
+			}(i)
+		}
+		wg.Wait()
+	}
+
+	b.mu.Lock()
+	b.status.Status = "complete"
+	for _, item := range b.status.Items {
+		if item.Status != "complete" {
+			b.status.Status = "failed"
+			break
+		}
+	}
+	b.status.FinishedAt = time.Now().Format("2006-01-02 15:04:05")
+	b.mu.Unlock()
+
+	b.notify()
+}
+
+// runItem 执行单个项目的部署任务，返回任务最终状态
+func (b *batchRun) runItem(i int, requestID string) string {
+	b.setItemStatus(i, "running", "")
+	runCallbackTask(b.requests[i], requestID, "batch")
+
+	status := "failed"
+	if meta, err := common.ReadTaskLogMeta(b.requests[i].TaskID); err == nil && meta.Status != "" {
+		status = meta.Status
+	}
+	b.setItemStatus(i, status, "")
+	return status
+}
+
+// itemStatus 获取项目状态
+func (b *batchRun) itemStatus(i int) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.status.Items[i].Status
+}
+
+// skipRemaining 跳过从start开始的项目
+func (b *batchRun) skipRemaining(start int, message string) {
+	for i := start; i < len(b.requests); i++ {
+		b.setItemStatus(i, "skipped", message)
+	}
+}
+
+// setItemStatus 更新项目状态
+func (b *batchRun) setItemStatus(i int, status, message string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.status.Items[i].Status = status
+	b.status.Items[i].Message = message
+}
+
+// snapshot 获取批次状态副本
+func (b *batchRun) snapshot() BatchStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	status := b.status
+	status.Items = append([]BatchItemResult(nil), b.status.Items...)
+	return status
+}
+
+// notify 发送批次汇总通知
+func (b *batchRun) notify() {
+	status := b.snapshot()
+
+	title, template := fmt.Sprintf("📦 批量部署完成（%s）", status.BatchID), "green"
+	if status.Status != "complete" {
+		title, template = fmt.Sprintf("❌ 批量部署未全部成功（%s）", status.BatchID), "red"
+	}
+
+	statusText := map[string]string{
+		"complete": "✅ 成功",
+		"failed":   "❌ 失败",
+		"cancel":   "⏹️ 取消",
+		"skipped":  "⏭️ 跳过",
+	}
+	lines := []string{fmt.Sprintf("部署方式: %s，开始: %s，结束: %s", status.Mode, status.CreatedAt, status.FinishedAt), ""}
+	for _, item := range status.Items {
+		line := fmt.Sprintf("%s（%s）: %s", item.Project, item.Tag, statusText[item.Status])
+		if item.Message != "" {
+			line += "，" + item.Message
+		}
+		lines = append(lines, line)
+	}
+	content := strings.Join(lines, "\n")
+
+	common.AppLogger.Info(fmt.Sprintf("批量部署结束: 批次=%s, 状态=%s", status.BatchID, status.Status))
+	if err := common.SendFeishuSummary(context.Background(), b.opsURL, title, template, content); err != nil {
+		common.AppLogger.Error(fmt.Sprintf("发送批量部署汇总通知失败: 批次=%s, 错误=%v", status.BatchID, err))
+	}
+}
+
+// pruneBatches 清理已结束超过保留时间的批次（调用方持有batchesMu）
+func pruneBatches() {
+	cutoff := time.Now().Add(-batchRetention).Format("2006-01-02 15:04:05")
+	for id, run := range batches {
+		status := run.snapshot()
+		if status.FinishedAt != "" && status.FinishedAt < cutoff {
+			delete(batches, id)
+		}
+	}
+}
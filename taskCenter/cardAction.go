@@ -0,0 +1,186 @@
+package taskCenter
+
+import (
+	"cicd-agent/common"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	common.RegisterCardActionHandler(common.CardActionRetry, retryTaskFromCard)
+	common.RegisterCardActionHandler(common.CardActionRollback, rollbackTaskFromCard)
+}
+
+// FeishuCardCallback 飞书卡片回调请求
+// 兼容旧版卡片回调（字段在顶层）和新版card.action.trigger事件（字段在event中）
+type FeishuCardCallback struct {
+	Type      string `json:"type"`      // url_verification为配置回调地址时的校验请求
+	Challenge string `json:"challenge"` // 校验请求需原样返回
+	Token     string `json:"token"`
+	OpenID    string `json:"open_id"`
+	Action    struct {
+		Value map[string]string `json:"value"`
+	} `json:"action"`
+
+	Schema string `json:"schema"`
+	Header struct {
+		Token string `json:"token"`
+	} `json:"header"`
+	Event struct {
+		Operator struct {
+			OpenID string `json:"open_id"`
+		} `json:"operator"`
+		Action struct {
+			Value map[string]string `json:"value"`
+		} `json:"action"`
+	} `json:"event"`
+}
+
+// HandleFeishuCardAction 处理飞书卡片按钮回调（批准/拒绝流量切换、回滚、重试）
+func HandleFeishuCardAction(c *gin.Context) {
+	logger := common.RequestLogger(c)
+
+	var req FeishuCardCallback
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("卡片回调参数绑定失败:", err)
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: fmt.Sprintf("请求参数错误: %v", err)})
+		return
+	}
+
+	token, operator, value := req.Token, req.OpenID, req.Action.Value
+	if req.Schema != "" {
+		token, operator, value = req.Header.Token, req.Event.Operator.OpenID, req.Event.Action.Value
+	}
+
+	if !common.VerifyFeishuToken(token) {
+		logger.Warning("卡片回调Verification Token校验失败")
+		c.JSON(http.StatusUnauthorized, Response{Code: 401, Msg: "Verification Token校验失败"})
+		return
+	}
+
+	// 配置回调地址时的校验请求
+	if req.Type == "url_verification" {
+		c.JSON(http.StatusOK, gin.H{"challenge": req.Challenge})
+		return
+	}
+
+	msg, err := common.HandleCardAction(value, operator)
+	toastType := "success"
+	if err != nil {
+		logger.Warning("卡片操作失败:", err)
+		msg, toastType = err.Error(), "error"
+	}
+
+	// 飞书要求200响应，结果通过toast展示给操作人
+	c.JSON(http.StatusOK, gin.H{
+		"toast": gin.H{"type": toastType, "content": msg},
+	})
+}
+
+// retryTaskFromCard 使用原任务的回调参数重新执行任务
+func retryTaskFromCard(action common.CardAction, operator string) (string, error) {
+	newTaskID, _, err := retryTask(action.TaskID, operator, common.NewRequestID())
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("已重新发起任务: %s", newTaskID), nil
+}
+
+// rollbackTaskFromCard 重新部署项目上一个成功的版本
+func rollbackTaskFromCard(action common.CardAction, operator string) (string, error) {
+	tag, _, _, err := rollbackTask(action.TaskID, action.Project, operator, common.NewRequestID())
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("开始回滚到版本 %s", tag), nil
+}
+
+// findPreviousSuccess 查找指定任务之前最近一次成功且版本不同的任务
+// preferredTag不为空时（双版本项目切换记录中的上一个标签）优先回滚到该标签
+func findPreviousSuccess(metas []common.TaskLogMeta, taskID, preferredTag string) (*common.TaskLogMeta, error) {
+	var current *common.TaskLogMeta
+	for i := range metas {
+		if metas[i].TaskID == taskID {
+			current = &metas[i]
+			break
+		}
+	}
+	if current == nil {
+		return nil, fmt.Errorf("未找到任务记录: %s", taskID)
+	}
+
+	// metas按开始时间从新到旧排列
+	var fallback *common.TaskLogMeta
+	for i := range metas {
+		meta := &metas[i]
+		if meta.StartedAt >= current.StartedAt || meta.TaskID == current.TaskID {
+			continue
+		}
+		if meta.Status != "complete" || meta.Tag == current.Tag || isMaintenanceTask(meta) {
+			continue
+		}
+		if preferredTag == "" || meta.Tag == preferredTag {
+			return meta, nil
+		}
+		if fallback == nil {
+			fallback = meta
+		}
+	}
+	if fallback != nil {
+		return fallback, nil
+	}
+	return nil, fmt.Errorf("未找到可回滚的历史版本")
+}
+
+// previousServingTag 从版本文件的切换记录中查找任务切换之前承载流量的标签，没有记录时返回空
+func previousServingTag(project, taskID string) string {
+	if !common.HasVersionStructure(project) {
+		return ""
+	}
+	info, err := common.ReadVersionInfo(project)
+	if err != nil || info == nil {
+		return ""
+	}
+	for i := len(info.History) - 1; i > 0; i-- {
+		if info.History[i].TaskID != taskID {
+			continue
+		}
+		for j := i - 1; j >= 0; j-- {
+			if tag := info.History[j].Tag; tag != "" && tag != info.History[i].Tag {
+				return tag
+			}
+		}
+		break
+	}
+	return ""
+}
+
+// saveTaskRequest 保存任务的回调参数到 logs/{任务ID}/request.json
+func saveTaskRequest(taskID string, req CallbackRequest) {
+	data, err := json.MarshalIndent(req, "", "  ")
+	if err != nil {
+		common.AppLogger.Error("序列化任务参数失败:", err)
+		return
+	}
+	if err := os.WriteFile(filepath.Join("logs", taskID, common.TaskRequestFile), data, 0644); err != nil {
+		common.AppLogger.Error("保存任务参数失败:", err)
+	}
+}
+
+// loadTaskRequest 读取任务的回调参数
+func loadTaskRequest(taskID string) (*CallbackRequest, error) {
+	data, err := os.ReadFile(filepath.Join("logs", taskID, common.TaskRequestFile))
+	if err != nil {
+		return nil, err
+	}
+	var req CallbackRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
@@ -0,0 +1,62 @@
+package taskCenter
+
+import (
+	"cicd-agent/common"
+	"cicd-agent/config"
+	"cicd-agent/taskStep/javaBuild"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleProjectCleanup 立即清理双版本项目未承载流量的版本（缩容next版本），用于清理步骤被跳过或失败后回收资源
+// 请求体可选，wait为清理前的等待时长，默认立即清理
+// POST /api/v1/project/:project/cleanup {"wait": "30s"}
+func HandleProjectCleanup(c *gin.Context) {
+	logger := common.RequestLogger(c)
+	project := c.Param("project")
+
+	var req CleanupRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: fmt.Sprintf("请求参数错误: %v", err)})
+		return
+	}
+	var wait time.Duration
+	if req.Wait != "" {
+		var err error
+		if wait, err = time.ParseDuration(req.Wait); err != nil || wait < 0 {
+			c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: fmt.Sprintf("wait格式错误: %s", req.Wait)})
+			return
+		}
+	}
+	if !config.AppConfig.IsValidProject(project) {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: fmt.Sprintf("项目 %s 不在有效项目列表中", project)})
+		return
+	}
+	if !common.HasVersionStructure(project) {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: fmt.Sprintf("项目 %s 不是双版本结构，没有可清理的旧版本", project)})
+		return
+	}
+	if running := runningUpstreamTasks([]string{project}, ""); len(running) > 0 {
+		c.JSON(http.StatusConflict, Response{Code: 409, Msg: fmt.Sprintf("项目有正在执行的任务: %s", strings.Join(running, ", "))})
+		return
+	}
+
+	taskID := fmt.Sprintf("%s-cleanup-%d", project, time.Now().Unix())
+	logger.Warning(fmt.Sprintf("手动清理旧版本: 项目=%s, 等待=%s, 任务ID=%s, 操作人=%s", project, wait, taskID, taskOperator(c)))
+	go runProjectTask(project, "", "手动清理旧版本", taskID, common.GetRequestID(c), "cleanup", func(ctx context.Context) error {
+		return javaBuild.CleanupOldVersion(ctx, taskID, project, wait)
+	})
+
+	c.JSON(http.StatusOK, Response{
+		Code: 200,
+		Msg:  "已开始清理旧版本",
+		Data: gin.H{"task_id": taskID},
+	})
+}
@@ -0,0 +1,76 @@
+package taskCenter
+
+import (
+	"cicd-agent/common"
+	"cicd-agent/config"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// acceptedCallback 已受理的回调
+type acceptedCallback struct {
+	taskID     string
+	acceptedAt time.Time
+}
+
+// 最近受理的回调：项目/标签/任务ID -> 任务
+var (
+	acceptedMu        sync.Mutex
+	acceptedCallbacks = make(map[string]acceptedCallback)
+)
+
+// callbackKey 回调去重键（回调未带任务ID时按项目和标签去重）
+func callbackKey(req CallbackRequest) string {
+	return fmt.Sprintf("%s/%s/%s", req.Project, req.Tag, req.TaskID)
+}
+
+// claimCallback 登记回调，去重窗口内已受理过相同回调时返回已有的任务ID和false
+func claimCallback(req CallbackRequest, taskID string) (string, bool) {
+	window := config.AppConfig.GetCallbackDedupWindow()
+	now := time.Now()
+
+	acceptedMu.Lock()
+	defer acceptedMu.Unlock()
+
+	// 清理超出窗口的记录
+	for key, accepted := range acceptedCallbacks {
+		if now.Sub(accepted.acceptedAt) > window {
+			delete(acceptedCallbacks, key)
+		}
+	}
+
+	key := callbackKey(req)
+	if accepted, ok := acceptedCallbacks[key]; ok {
+		return accepted.taskID, false
+	}
+	acceptedCallbacks[key] = acceptedCallback{taskID: taskID, acceptedAt: now}
+	return taskID, true
+}
+
+// forgetCallback 回调最终未受理（如被拒绝部署）时移除登记，之后相同的回调重新处理
+func forgetCallback(req CallbackRequest) {
+	acceptedMu.Lock()
+	defer acceptedMu.Unlock()
+	delete(acceptedCallbacks, callbackKey(req))
+}
+
+// currentTaskStatus 获取任务当前状态：scheduled/queued/running，已结束的任务为记录的最终状态
+func currentTaskStatus(taskID string) string {
+	scheduledMu.Lock()
+	_, scheduled := scheduledTasks[taskID]
+	scheduledMu.Unlock()
+	if scheduled {
+		return "scheduled"
+	}
+	if _, queued := queuedTaskPositions()[taskID]; queued {
+		return "queued"
+	}
+	if common.IsTaskRunning(taskID) {
+		return "running"
+	}
+	if meta, err := common.ReadTaskLogMeta(taskID); err == nil && meta.Status != "" {
+		return meta.Status
+	}
+	return "running"
+}
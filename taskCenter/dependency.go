@@ -0,0 +1,78 @@
+package taskCenter
+
+import (
+	"cicd-agent/common"
+	"cicd-agent/config"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// upstreamPollInterval 等待上游项目部署结束的检查间隔
+const upstreamPollInterval = 5 * time.Second
+
+// waitForUpstream 等待上游项目（deployment.dependencies）正在执行的任务结束
+// 上游任务未成功时返回错误，下游不再部署；任务取消时返回ctx错误
+func waitForUpstream(ctx context.Context, req CallbackRequest, taskID string) error {
+	upstreams := config.AppConfig.GetProjectDependencies(req.Project)
+	if len(upstreams) == 0 {
+		return nil
+	}
+
+	waiting := runningUpstreamTasks(upstreams, taskID)
+	if len(waiting) == 0 {
+		return nil
+	}
+	if taskLogger := common.NewTaskLogger(taskID); taskLogger != nil {
+		taskLogger.WriteConsole("INFO", fmt.Sprintf("等待上游项目部署结束: %s", strings.Join(waiting, ", ")))
+		taskLogger.Close()
+	}
+
+	for len(waiting) > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(upstreamPollInterval):
+		}
+
+		var still []string
+		for _, upstreamTaskID := range waiting {
+			if common.IsTaskRunning(upstreamTaskID) {
+				still = append(still, upstreamTaskID)
+				continue
+			}
+			meta, err := common.ReadTaskLogMeta(upstreamTaskID)
+			if err != nil || meta.Status != "complete" {
+				project := upstreamTaskID
+				if meta != nil {
+					project = meta.Project
+				}
+				return fmt.Errorf("上游项目 %s 部署未成功（任务 %s）", project, upstreamTaskID)
+			}
+		}
+		waiting = still
+	}
+	return nil
+}
+
+// runningUpstreamTasks 获取上游项目正在执行的任务ID
+func runningUpstreamTasks(upstreams []string, taskID string) []string {
+	var running []string
+	for _, runningID := range common.RunningTaskIDs() {
+		if runningID == taskID {
+			continue
+		}
+		meta, err := common.ReadTaskLogMeta(runningID)
+		if err != nil {
+			continue
+		}
+		for _, upstream := range upstreams {
+			if meta.Project == upstream {
+				running = append(running, runningID)
+				break
+			}
+		}
+	}
+	return running
+}
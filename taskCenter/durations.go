@@ -0,0 +1,29 @@
+package taskCenter
+
+import (
+	"cicd-agent/common"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleProjectDurations 查询项目各步骤的历史耗时、平均值和趋势
+// GET /api/v1/projects/:project/durations
+func HandleProjectDurations(c *gin.Context) {
+	project := c.Param("project")
+
+	stats, err := common.ProjectDurationStats(project)
+	if err != nil {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Msg: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code: 200,
+		Msg:  "查询成功",
+		Data: gin.H{
+			"project": project,
+			"steps":   stats,
+		},
+	})
+}
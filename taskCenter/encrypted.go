@@ -0,0 +1,53 @@
+package taskCenter
+
+import (
+	"bytes"
+	"cicd-agent/common"
+	"cicd-agent/config"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// decodeRequestBody 按remote.request_encryption处理请求体
+// 请求体为EncryptedRequest时解密解压后替换为明文，后续可直接ShouldBindJSON
+func decodeRequestBody(c *gin.Context) error {
+	mode := config.AppConfig.GetRequestEncryption()
+	if mode == config.RequestEncryptionOff {
+		return nil
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		return fmt.Errorf("读取请求体失败: %v", err)
+	}
+
+	var envelope EncryptedRequest
+	encrypted := json.Unmarshal(body, &envelope) == nil && envelope.Data != ""
+	if !encrypted {
+		if mode == config.RequestEncryptionRequired {
+			return fmt.Errorf("请求体必须加密")
+		}
+		resetRequestBody(c, body)
+		return nil
+	}
+
+	plain, err := common.DecryptAndDecompress(envelope.Data)
+	if err != nil {
+		return fmt.Errorf("解密请求数据失败: %v", err)
+	}
+	resetRequestBody(c, plain)
+	return nil
+}
+
+// resetRequestBody 重新设置请求体
+func resetRequestBody(c *gin.Context, body []byte) {
+	c.Request.Body = http.NoBody
+	if len(body) > 0 {
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	c.Request.ContentLength = int64(len(body))
+}
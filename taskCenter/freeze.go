@@ -0,0 +1,73 @@
+package taskCenter
+
+import (
+	"cicd-agent/common"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleFreezeView 查看封网状态
+// GET /api/v1/freeze
+func HandleFreezeView(c *gin.Context) {
+	c.JSON(http.StatusOK, Response{Code: 200, Msg: "查询成功", Data: common.GetFreezeState()})
+}
+
+// HandleFreezeUpdate 开启或解除全局封网，解除后排队中的任务按部署窗口继续执行
+// POST /api/v1/freeze {"enable": true, "reason": "双十一封网"}
+func HandleFreezeUpdate(c *gin.Context) {
+	var req FreezeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: fmt.Sprintf("请求参数错误: %v", err)})
+		return
+	}
+
+	state, err := common.SetFreezeState(req.Enable, req.Reason, taskOperator(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Msg: err.Error()})
+		return
+	}
+
+	msg := "已开启封网"
+	if !req.Enable {
+		releaseFrozenTasks()
+		msg = "已解除封网"
+	}
+	c.JSON(http.StatusOK, Response{Code: 200, Msg: msg, Data: state})
+}
+
+// notifyDeployRejected 回调因部署窗口或封网被拒绝时，按部署失败通知各渠道并附带原因
+func notifyDeployRejected(req CallbackRequest, reason string) {
+	taskID := req.TaskID
+	if taskID == "" {
+		taskID = fmt.Sprintf("%s-%s-%d", req.Project, req.Tag, time.Now().Unix())
+	}
+	notifyTaskBlocked(req, taskID, "deployWindow", "部署窗口检查", reason)
+}
+
+// notifyTaskBlocked 任务未开始部署就结束时，按部署失败通知各渠道，失败步骤为阻止部署的检查
+func notifyTaskBlocked(req CallbackRequest, taskID, stepType, stepName, reason string) {
+	event := common.TaskEvent{
+		TaskID:        taskID,
+		Project:       req.Project,
+		ProjectName:   req.ProjectName,
+		Tag:           req.Tag,
+		Category:      req.Category,
+		DeployType:    req.Type,
+		Status:        "failed",
+		StartedAt:     time.Now().Format("2006-01-02 15:04:05"),
+		OpsURL:        req.UpdateFeishuURL,
+		ProURL:        req.NotifyFeishuURL,
+		StepDurations: req.StepDurations,
+		Failure: &common.TaskFailure{
+			StepType:     stepType,
+			StepName:     stepName,
+			ErrorMessage: reason,
+		},
+	}
+	if err := common.NotifyTask(event); err != nil {
+		common.AppLogger.Error(fmt.Sprintf("发送%s失败通知失败: 项目=%s, 错误=%v", stepName, req.Project, err))
+	}
+}
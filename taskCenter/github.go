@@ -0,0 +1,166 @@
+package taskCenter
+
+import (
+	"cicd-agent/common"
+	"cicd-agent/config"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// githubWebhook GitHub Webhook请求体中用到的字段（release和workflow_run事件）
+type githubWebhook struct {
+	Action  string `json:"action"`
+	Release struct {
+		TagName     string `json:"tag_name"`
+		Name        string `json:"name"`
+		Draft       bool   `json:"draft"`
+		PublishedAt string `json:"published_at"`
+	} `json:"release"`
+	WorkflowRun struct {
+		ID         int64  `json:"id"`
+		Name       string `json:"name"`
+		Event      string `json:"event"` // 触发工作流的事件：push/release/...
+		HeadBranch string `json:"head_branch"`
+		HeadSHA    string `json:"head_sha"`
+		Conclusion string `json:"conclusion"`
+		UpdatedAt  string `json:"updated_at"`
+	} `json:"workflow_run"`
+	Repository struct {
+		Name     string `json:"name"`
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	Sender struct {
+		Login string `json:"login"`
+	} `json:"sender"`
+}
+
+// HandleGitHubWebhook 接收GitHub（含GitHub Enterprise）的release / workflow_run事件，转换为构建成功回调后按回调流程部署
+// 默认发布Release时部署（镜像标签为Release标签）；配置为workflow_run时工作流成功后部署，
+// Release触发的工作流以标签部署，webhooks.github.branches中的分支以提交短SHA为镜像标签
+// 不触发部署的事件返回200，避免GitHub将Webhook标记为失败
+// POST /api/v1/webhook/github
+func HandleGitHubWebhook(c *gin.Context) {
+	logger := common.RequestLogger(c)
+	githubConfig := config.AppConfig.Webhooks.GitHub
+	if !githubConfig.Enable {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Msg: "未开启GitHub Webhook"})
+		return
+	}
+
+	// 签名针对原始请求体计算，需先读取原文再解析
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: fmt.Sprintf("读取请求体失败: %v", err)})
+		return
+	}
+	var hook githubWebhook
+	if err := json.Unmarshal(body, &hook); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: fmt.Sprintf("请求参数错误: %v", err)})
+		return
+	}
+	repo := hook.Repository.FullName
+	if !verifyGitHubSignature(githubConfig.SecretFor(repo), body, c.GetHeader("X-Hub-Signature-256")) {
+		logger.Warning(fmt.Sprintf("GitHub Webhook校验失败: 仓库=%s, 来源=%s", repo, common.GetClientIP(c)))
+		c.JSON(http.StatusUnauthorized, Response{Code: 401, Msg: "X-Hub-Signature-256校验失败"})
+		return
+	}
+
+	event := c.GetHeader("X-GitHub-Event")
+	if event == "ping" {
+		c.JSON(http.StatusOK, Response{Code: 200, Msg: "pong"})
+		return
+	}
+	tag, reason := githubDeployTag(event, hook, githubConfig)
+	if tag == "" {
+		logger.Info(fmt.Sprintf("忽略GitHub事件: 仓库=%s, 事件=%s, 动作=%s, 原因=%s", repo, event, hook.Action, reason))
+		c.JSON(http.StatusOK, Response{Code: 200, Msg: fmt.Sprintf("事件已忽略: %s", reason)})
+		return
+	}
+
+	project := githubConfig.ProjectFor(repo)
+	deployType, err := webhookDeployType(project)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: err.Error()})
+		return
+	}
+
+	req := CallbackRequest{
+		Project:     project,
+		Type:        deployType,
+		Status:      "success",
+		Tag:         tag,
+		ProjectName: hook.Repository.Name,
+		CreateTime:  time.Now().Format("2006-01-02 15:04:05"),
+	}
+	if event == "workflow_run" {
+		// 同一工作流运行的重复投递按任务ID去重
+		req.TaskID = fmt.Sprintf("%s-github-%d", project, hook.WorkflowRun.ID)
+		req.FinishedAt = hook.WorkflowRun.UpdatedAt
+	} else {
+		req.FinishedAt = hook.Release.PublishedAt
+	}
+	logger.Info("GitHub触发部署:", fmt.Sprintf("仓库=%s, 项目=%s, 标签=%s, 事件=%s, 用户=%s", repo, project, tag, event, hook.Sender.Login))
+
+	acceptCallback(c, req)
+}
+
+// verifyGitHubSignature 校验X-Hub-Signature-256（sha256=请求体的HMAC-SHA256十六进制），未配置Secret时拒绝
+func verifyGitHubSignature(secret string, body []byte, signature string) bool {
+	if secret == "" || !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(strings.ToLower(strings.TrimPrefix(signature, "sha256="))), []byte(expected))
+}
+
+// githubDeployTag 根据GitHub事件和配置确定部署的镜像标签，不需要部署时返回空标签和原因
+func githubDeployTag(event string, hook githubWebhook, githubConfig config.GitHubWebhookConfig) (string, string) {
+	trigger := githubConfig.GetTrigger()
+	switch event {
+	case "release":
+		if trigger != "release" {
+			return "", "已配置为工作流成功后部署"
+		}
+		if hook.Action != "published" {
+			return "", fmt.Sprintf("Release动作为 %s", hook.Action)
+		}
+		if hook.Release.Draft {
+			return "", "草稿Release"
+		}
+		return hook.Release.TagName, ""
+	case "workflow_run":
+		if trigger != "workflow_run" {
+			return "", "已配置为发布Release时部署"
+		}
+		run := hook.WorkflowRun
+		if hook.Action != "completed" {
+			return "", fmt.Sprintf("工作流动作为 %s", hook.Action)
+		}
+		if run.Conclusion != "success" {
+			return "", fmt.Sprintf("工作流结果为 %s", run.Conclusion)
+		}
+		if len(githubConfig.Workflows) > 0 && !slices.Contains(githubConfig.Workflows, run.Name) {
+			return "", fmt.Sprintf("工作流 %s 未配置部署", run.Name)
+		}
+		// release触发的工作流，head_branch为Release标签
+		if run.Event == "release" {
+			return run.HeadBranch, ""
+		}
+		if slices.Contains(githubConfig.Branches, run.HeadBranch) {
+			return shortSHA(run.HeadSHA), ""
+		}
+		return "", fmt.Sprintf("分支 %s 未配置部署", run.HeadBranch)
+	}
+	return "", fmt.Sprintf("不支持的事件类型 %s", event)
+}
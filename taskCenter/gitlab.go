@@ -0,0 +1,130 @@
+package taskCenter
+
+import (
+	"cicd-agent/common"
+	"cicd-agent/config"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gitlabWebhook GitLab Webhook请求体中用到的字段（Pipeline Hook和Tag Push Hook）
+type gitlabWebhook struct {
+	ObjectKind       string `json:"object_kind"` // pipeline/tag_push
+	Ref              string `json:"ref"`         // tag_push: refs/tags/v1.0.0
+	CheckoutSHA      string `json:"checkout_sha"`
+	UserUsername     string `json:"user_username"`
+	ObjectAttributes struct {
+		ID         int64  `json:"id"`
+		Ref        string `json:"ref"`
+		Tag        bool   `json:"tag"`
+		SHA        string `json:"sha"`
+		Status     string `json:"status"`
+		FinishedAt string `json:"finished_at"`
+	} `json:"object_attributes"`
+	Project struct {
+		Name              string `json:"name"`
+		PathWithNamespace string `json:"path_with_namespace"`
+	} `json:"project"`
+	User struct {
+		Username string `json:"username"`
+	} `json:"user"`
+}
+
+// HandleGitLabWebhook 接收GitLab的Pipeline Hook / Tag Push Hook，转换为构建成功回调后按回调流程部署
+// 默认标签流水线成功后部署（镜像标签为Git标签），webhooks.gitlab.branches中的分支流水线以提交短SHA为镜像标签
+// 不触发部署的事件返回200，避免GitLab将Webhook标记为失败
+// POST /api/v1/webhook/gitlab
+func HandleGitLabWebhook(c *gin.Context) {
+	logger := common.RequestLogger(c)
+	gitlabConfig := config.AppConfig.Webhooks.GitLab
+	if !gitlabConfig.Enable {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Msg: "未开启GitLab Webhook"})
+		return
+	}
+
+	var hook gitlabWebhook
+	if err := c.ShouldBindJSON(&hook); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: fmt.Sprintf("请求参数错误: %v", err)})
+		return
+	}
+	path := hook.Project.PathWithNamespace
+	token := gitlabConfig.TokenFor(path)
+	if token == "" || subtle.ConstantTimeCompare([]byte(c.GetHeader("X-Gitlab-Token")), []byte(token)) != 1 {
+		logger.Warning(fmt.Sprintf("GitLab Webhook校验失败: GitLab项目=%s, 来源=%s", path, common.GetClientIP(c)))
+		c.JSON(http.StatusUnauthorized, Response{Code: 401, Msg: "X-Gitlab-Token校验失败"})
+		return
+	}
+
+	tag, reason := gitlabDeployTag(hook, gitlabConfig)
+	if tag == "" {
+		logger.Info(fmt.Sprintf("忽略GitLab事件: GitLab项目=%s, 类型=%s, 原因=%s", path, hook.ObjectKind, reason))
+		c.JSON(http.StatusOK, Response{Code: 200, Msg: fmt.Sprintf("事件已忽略: %s", reason)})
+		return
+	}
+
+	project := gitlabConfig.ProjectFor(path)
+	deployType, err := webhookDeployType(project)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: err.Error()})
+		return
+	}
+
+	req := CallbackRequest{
+		Project:     project,
+		Type:        deployType,
+		Status:      "success",
+		Tag:         tag,
+		ProjectName: hook.Project.Name,
+		CreateTime:  time.Now().Format("2006-01-02 15:04:05"),
+		FinishedAt:  hook.ObjectAttributes.FinishedAt,
+	}
+	if hook.ObjectKind == "pipeline" {
+		// 同一流水线的重复投递按任务ID去重
+		req.TaskID = fmt.Sprintf("%s-gitlab-%d", project, hook.ObjectAttributes.ID)
+	}
+	operator := hook.User.Username
+	if operator == "" {
+		operator = hook.UserUsername
+	}
+	logger.Info("GitLab触发部署:", fmt.Sprintf("GitLab项目=%s, 项目=%s, 标签=%s, 事件=%s, 用户=%s", path, project, tag, hook.ObjectKind, operator))
+
+	acceptCallback(c, req)
+}
+
+// gitlabDeployTag 根据GitLab事件和配置确定部署的镜像标签，不需要部署时返回空标签和原因
+func gitlabDeployTag(hook gitlabWebhook, gitlabConfig config.GitLabWebhookConfig) (string, string) {
+	trigger := gitlabConfig.GetTrigger()
+	switch hook.ObjectKind {
+	case "tag_push":
+		if trigger != "tag_push" {
+			return "", "标签推送事件，等待流水线成功后部署"
+		}
+		if hook.CheckoutSHA == "" || strings.Trim(hook.CheckoutSHA, "0") == "" {
+			return "", "标签已删除"
+		}
+		return strings.TrimPrefix(hook.Ref, "refs/tags/"), ""
+	case "pipeline":
+		if trigger != "pipeline" {
+			return "", "已配置为标签推送时部署"
+		}
+		attributes := hook.ObjectAttributes
+		if attributes.Status != "success" {
+			return "", fmt.Sprintf("流水线状态为 %s", attributes.Status)
+		}
+		if attributes.Tag {
+			return attributes.Ref, ""
+		}
+		for _, branch := range gitlabConfig.Branches {
+			if branch == attributes.Ref {
+				return shortSHA(attributes.SHA), ""
+			}
+		}
+		return "", fmt.Sprintf("分支 %s 未配置部署", attributes.Ref)
+	}
+	return "", fmt.Sprintf("不支持的事件类型 %s", hook.ObjectKind)
+}
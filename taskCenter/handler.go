@@ -6,7 +6,9 @@ import (
 	"cicd-agent/config"
 	"cicd-agent/taskStep/javaBuild"
 	"cicd-agent/taskStep/webBuild"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -17,9 +19,11 @@ import (
 
 // HandleUpdate 处理更新请求
 func HandleUpdate(c *gin.Context) {
+	logger := common.RequestLogger(c)
+
 	// 记录原始请求数据
 	body, _ := c.GetRawData()
-	common.AppLogger.Info("收到更新请求，原始数据:", string(body))
+	logger.Info("收到更新请求，原始数据:", string(body))
 
 	// 重新设置请求体，因为GetRawData会消耗掉
 	c.Request.Body = http.NoBody
@@ -35,18 +39,23 @@ func HandleUpdate(c *gin.Context) {
 
 	var req UpdateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		common.AppLogger.Error("请求参数绑定失败:", err)
-		common.AppLogger.Error("期望的结构体:", fmt.Sprintf("%+v", UpdateRequest{}))
+		logger.Error("请求参数绑定失败:", err)
+		logger.Error("期望的结构体:", fmt.Sprintf("%+v", UpdateRequest{}))
 		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: "请求参数错误"})
 		return
 	}
 
 	//common.AppLogger.Info("收到更新请求:", fmt.Sprintf("项目=%s, 类型=%s, 分类=%s", req.Project, req.Type, req.Category))
 
+	// 路由模式下由其他agent负责的项目，原样转发
+	if routeToOwner(c, req.Project, body) {
+		return
+	}
+
 	// 验证项目是否有效
 	if !config.AppConfig.IsValidProject(req.Project) {
 		errMsg := fmt.Sprintf("项目 %s 不在有效项目列表中", req.Project)
-		common.AppLogger.Error("项目验证失败:", errMsg)
+		logger.Error("项目验证失败:", errMsg)
 		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: errMsg})
 		return
 	}
@@ -55,7 +64,7 @@ func HandleUpdate(c *gin.Context) {
 	if req.Type != "web" {
 		if _, exists := config.AppConfig.GetProjectPath(req.Project); !exists {
 			errMsg := fmt.Sprintf("项目 %s 未配置部署目录", req.Project)
-			common.AppLogger.Error("配置验证失败:", errMsg)
+			logger.Error("配置验证失败:", errMsg)
 			c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: errMsg})
 			return
 		}
@@ -70,9 +79,22 @@ func HandleUpdate(c *gin.Context) {
 		}
 	}
 
+	// 计划部署时间在发起构建前校验，避免构建完成后才发现格式错误
+	if _, err := parseDeployAt(req.DeployAt); err != nil {
+		logger.Error("请求参数校验失败:", err)
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: err.Error()})
+		return
+	}
+
+	if _, err := parsePriority(req.Priority); err != nil {
+		logger.Error("请求参数校验失败:", err)
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: err.Error()})
+		return
+	}
+
 	// 验证通过，进行远程调用
-	if err := callRemoteAPI(req); err != nil {
-		common.AppLogger.Error("调用远程API失败:", err)
+	if err := callRemoteAPI(c.Request.Context(), req, common.GetRequestID(c)); err != nil {
+		logger.Error("调用远程API失败:", err)
 		c.JSON(http.StatusInternalServerError, Response{Code: 500, Msg: "调用远程API失败"})
 		return
 	}
@@ -81,20 +103,26 @@ func HandleUpdate(c *gin.Context) {
 
 // HandleCallback 处理回调请求
 func HandleCallback(c *gin.Context) {
+	logger := common.RequestLogger(c)
+
 	// 记录原始回调数据
 	body, _ := c.GetRawData()
 	// common.AppLogger.Info("收到回调请求，原始数据:", string(body))
 
 	// 重新设置请求体
-	c.Request.Body = http.NoBody
-	if len(body) > 0 {
-		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	resetRequestBody(c, body)
+
+	// 按配置解密EncryptedRequest格式的请求体
+	if err := decodeRequestBody(c); err != nil {
+		logger.Error("回调请求解密失败:", err)
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: err.Error()})
+		return
 	}
 
-	// 直接解析明文回调请求
+	// 解析回调请求
 	var req CallbackRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		common.AppLogger.Error("请求参数绑定失败:", err)
+		logger.Error("请求参数绑定失败:", err)
 		c.JSON(http.StatusBadRequest, Response{
 			Code: 400,
 			Msg:  fmt.Sprintf("请求参数错误: %v", err),
@@ -104,9 +132,14 @@ func HandleCallback(c *gin.Context) {
 
 	// common.AppLogger.Info("解析后的回调参数:", fmt.Sprintf("%+v", req))
 
+	// 路由模式下由其他agent负责的项目，原样转发（含非成功状态的回调）
+	if routeToOwner(c, req.Project, body) {
+		return
+	}
+
 	// 只处理成功状态的回调
 	if req.Status != "success" {
-		common.AppLogger.Info("非成功状态的回调，跳过处理:", req.Status)
+		logger.Info("非成功状态的回调，跳过处理:", req.Status)
 		c.JSON(http.StatusOK, Response{
 			Code: 200,
 			Msg:  "回调处理完成（非成功状态）",
@@ -115,104 +148,281 @@ func HandleCallback(c *gin.Context) {
 	}
 
 	// 记录成功构建任务
-	common.AppLogger.Info("构建成功回调:", fmt.Sprintf("项目=%s, 标签=%s, 任务ID=%s, 完成时间=%s",
+	logger.Info("构建成功回调:", fmt.Sprintf("项目=%s, 标签=%s, 任务ID=%s, 完成时间=%s",
 		req.Project, req.Tag, req.TaskID, req.FinishedAt))
 
-	// 异步处理镜像拉取和推送，根据项目名称后缀判断构建类型
-	go func() {
-		// 使用任务ID或生成一个临时ID
-		taskID := req.TaskID
-		if taskID == "" {
-			taskID = fmt.Sprintf("%s-%s-%d", req.Project, req.Tag, time.Now().Unix())
-		}
+	acceptCallback(c, req)
+}
 
-		// 为任务创建可取消的上下文（供外部取消接口使用）
-		ctx, _ := common.CreateTaskContext(taskID)
-
-		// 根据type字段判断构建类型: web/double/single
-		if req.Type == "web" {
-			// Web项目构建
-			processor := webBuild.NewRemoteProcessor(
-				req.Project,
-				req.Category,
-				req.Tag,
-				req.ProjectName,
-				taskID,
-				req.Type,
-				ctx,
-				req.UpdateFeishuURL,
-				req.NotifyFeishuURL,
-				req.CreateTime,
-				req.StepDurations,
-			)
-			if err := processor.ProcessRemoteRequest(); err != nil {
-				common.AppLogger.Error("web构建处理失败:", fmt.Sprintf("项目=%s, 标签=%s, 错误=%v",
-					req.Project, req.Tag, err))
-			} else {
-				common.AppLogger.Info("web构建处理成功:", fmt.Sprintf("项目=%s, 标签=%s",
-					req.Project, req.Tag))
-			}
-		} else if req.Type == "double" {
-			// Java双版本部署
-			processor := javaBuild.NewDoubleVersionProcessor(
-				req.Project,
-				req.Tag,
-				req.ProjectName,
-				taskID,
-				req.Type,
-				ctx,
-				req.UpdateFeishuURL,
-				req.NotifyFeishuURL,
-				req.CreateTime,
-				req.StepDurations,
-			)
-			if err := processor.ProcessDoubleVersionDeployment(); err != nil {
-				common.AppLogger.Error("双版本java构建处理失败:", fmt.Sprintf("项目=%s, 标签=%s, 错误=%v",
-					req.Project, req.Tag, err))
-			} else {
-				common.AppLogger.Info("双版本java构建处理成功:", fmt.Sprintf("项目=%s, 标签=%s",
-					req.Project, req.Tag))
-			}
-		} else {
-			// Java单版本部署 (type == "single" 或其他)
-			processor := javaBuild.NewSingleVersionProcessor(
-				req.Project,
-				req.Category,
-				req.Tag,
-				req.ProjectName,
-				taskID,
-				req.Type,
-				ctx,
-				req.UpdateFeishuURL,
-				req.NotifyFeishuURL,
-				req.CreateTime,
-				req.StepDurations,
-			)
-			if err := processor.ProcessSingleVersionDeployment(); err != nil {
-				common.AppLogger.Error("单版本java构建处理失败:", fmt.Sprintf("项目=%s, 标签=%s, 错误=%v",
-					req.Project, req.Tag, err))
-			} else {
-				common.AppLogger.Info("单版本java构建处理成功:", fmt.Sprintf("项目=%s, 标签=%s",
-					req.Project, req.Tag))
+// acceptCallback 受理构建成功的回调：校验计划时间和优先级、去重、检查部署窗口，然后立即执行或计划执行部署
+// 中心服务的回调和GitLab等Webhook转换后的回调共用
+func acceptCallback(c *gin.Context, req CallbackRequest) {
+	logger := common.RequestLogger(c)
+	requestID := common.GetRequestID(c)
+
+	// 指定了计划部署时间时，到点再执行
+	deployAt, err := parseDeployAt(req.DeployAt)
+	if err != nil {
+		logger.Error("请求参数校验失败:", err)
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: err.Error()})
+		return
+	}
+	if _, err := parsePriority(req.Priority); err != nil {
+		logger.Error("请求参数校验失败:", err)
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: err.Error()})
+		return
+	}
+	startAt := time.Now()
+	if deployAt.After(startAt) {
+		startAt = deployAt
+	}
+
+	// CI重试可能重复发送同一回调，窗口内已受理过时直接返回已有任务
+	taskID := req.TaskID
+	if taskID == "" {
+		taskID = fmt.Sprintf("%s-%s-%d", req.Project, req.Tag, time.Now().Unix())
+	}
+	if existingID, ok := claimCallback(req, taskID); !ok {
+		status := currentTaskStatus(existingID)
+		logger.Warning(fmt.Sprintf("忽略重复的回调: 项目=%s, 标签=%s, 已有任务ID=%s, 状态=%s", req.Project, req.Tag, existingID, status))
+		c.JSON(http.StatusOK, Response{
+			Code: 200,
+			Msg:  "重复的回调，任务已受理",
+			Data: gin.H{"task_id": existingID, "status": status},
+		})
+		return
+	}
+	req.TaskID = taskID
+
+	// 部署窗口外或封网期间按配置排队或拒绝
+	if allowed, reason := common.CheckDeployWindow(req.Project, startAt); !allowed {
+		queueAt, err := nextDeployTime(req.Project, startAt)
+		if err == nil && config.AppConfig.GetOutsideWindowAction() == "queue" {
+			taskID := scheduleCallbackTask(req, requestID, "callback", queueAt, reason)
+			msg := fmt.Sprintf("%s，任务已排队等待封网解除", reason)
+			if !queueAt.IsZero() {
+				msg = fmt.Sprintf("%s，任务已排队到 %s 执行", reason, formatDeployAt(queueAt))
 			}
+			logger.Warning(fmt.Sprintf("回调未立即部署: 项目=%s, 标签=%s, %s", req.Project, req.Tag, msg))
+			c.JSON(http.StatusOK, Response{Code: 200, Msg: msg, Data: gin.H{"task_id": taskID}})
+			return
 		}
+		if err != nil {
+			reason = fmt.Sprintf("%s，%v", reason, err)
+		}
+		logger.Warning(fmt.Sprintf("拒绝部署: 项目=%s, 标签=%s, 原因=%s", req.Project, req.Tag, reason))
+		forgetCallback(req)
+		go notifyDeployRejected(req, reason)
+		c.JSON(http.StatusForbidden, Response{Code: 403, Msg: fmt.Sprintf("拒绝部署: %s", reason)})
+		return
+	}
 
-		// 清理任务上下文
-		common.CleanupTask(taskID)
-	}()
+	if deployAt.After(time.Now()) {
+		taskID := scheduleCallbackTask(req, requestID, "callback", deployAt, "")
+		c.JSON(http.StatusOK, Response{
+			Code: 200,
+			Msg:  fmt.Sprintf("任务已计划在 %s 执行", formatDeployAt(deployAt)),
+			Data: gin.H{"task_id": taskID},
+		})
+		return
+	}
+
+	// 异步处理镜像拉取和推送，根据项目名称后缀判断构建类型
+	go runCallbackTask(req, requestID, "callback")
 
 	c.JSON(http.StatusOK, Response{
 		Code: 200,
 		Msg:  "回调处理成功",
+		Data: gin.H{"task_id": taskID},
+	})
+}
+
+// runCallbackTask 执行回调触发的部署任务（批量、计划任务同样由此执行）
+// trigger为触发方式：callback/batch/schedule等
+func runCallbackTask(req CallbackRequest, requestID, trigger string) {
+	// 使用任务ID或生成一个临时ID
+	taskID := req.TaskID
+	if taskID == "" {
+		taskID = fmt.Sprintf("%s-%s-%d", req.Project, req.Tag, time.Now().Unix())
+	}
+
+	// 为任务创建可取消的上下文（供外部取消接口使用）
+	ctx, _ := common.CreateTaskContext(taskID)
+	registerCallbackTask(taskID, req, requestID, trigger)
+	executeCallbackTask(ctx, taskID, req, requestID)
+}
+
+// startClaimedTask 原子地占用新任务的上下文并登记任务后在后台执行（重试、回滚在返回前调用），
+// 任务ID已被占用时返回错误，并发请求中只有一个能发起任务
+func startClaimedTask(req CallbackRequest, requestID, trigger string) error {
+	ctx, _, ok := common.TryCreateTaskContext(req.TaskID)
+	if !ok {
+		return fmt.Errorf("任务 %s 已在执行中", req.TaskID)
+	}
+	registerCallbackTask(req.TaskID, req, requestID, trigger)
+	go executeCallbackTask(ctx, req.TaskID, req, requestID)
+	return nil
+}
+
+// registerCallbackTask 记录任务信息和回调参数（在任务上下文创建之后、开始执行之前调用）
+func registerCallbackTask(taskID string, req CallbackRequest, requestID, trigger string) {
+	// 记录任务信息，供日志检索按项目过滤
+	common.WriteTaskLogMeta(common.TaskLogMeta{
+		TaskID:    taskID,
+		Project:   req.Project,
+		Tag:       req.Tag,
+		Type:      req.Type,
+		StartedAt: time.Now().Format("2006-01-02 15:04:05"),
+		RequestID: requestID,
+		Trigger:   trigger,
+		DeployAt:  req.DeployAt,
+		BatchID:   req.BatchID,
+		RetryOf:   req.RetryOf,
 	})
+	// 保存回调参数，供重试和回滚重新发起任务
+	saveTaskRequest(taskID, req)
+
+	// 在任务控制台日志中记录请求ID，便于与服务端、agent日志关联
+	if taskLogger := common.NewTaskLogger(taskID); taskLogger != nil {
+		taskLogger.WriteConsole("INFO", fmt.Sprintf("回调请求ID: %s", requestID))
+		taskLogger.Close()
+	}
+	common.AppLogger.WithRequestID(requestID).Info("任务已创建:", fmt.Sprintf("任务ID=%s", taskID))
+}
+
+// executeCallbackTask 等待上游依赖和执行名额后执行部署，结束时记录最终状态并清理任务上下文
+func executeCallbackTask(ctx context.Context, taskID string, req CallbackRequest, requestID string) {
+	logger := common.AppLogger.WithRequestID(requestID)
+
+	// 上游项目正在部署时先等待其结束，上游部署未成功时不再部署；执行中的任务达到上限时排队
+	var (
+		err       error
+		preempted bool
+	)
+	if err = waitForUpstream(ctx, req, taskID); err != nil {
+		logger.Warning("任务未执行:", fmt.Sprintf("项目=%s, 标签=%s, 原因=%v", req.Project, req.Tag, err))
+		if ctx.Err() == nil {
+			notifyTaskBlocked(req, taskID, "dependency", "上游依赖检查", err.Error())
+		}
+	} else if release, slotErr := acquireTaskSlot(ctx, req, taskID); slotErr != nil {
+		err = slotErr
+		if preempted = !errors.Is(slotErr, context.Canceled); preempted {
+			// 被同项目的高优先级任务抢占（可能随后又被取消）
+			logger.Warning("任务未执行:", fmt.Sprintf("项目=%s, 标签=%s, 原因=%v", req.Project, req.Tag, err))
+			notifyTaskBlocked(req, taskID, "queue", "排队等待", err.Error())
+		} else {
+			logger.Warning("任务排队期间已取消:", fmt.Sprintf("项目=%s, 标签=%s", req.Project, req.Tag))
+			notifyQueueEvent(req, taskID, "cancel")
+		}
+	} else {
+		err = runDeployment(ctx, req, taskID, logger)
+		release()
+	}
+
+	// 记录任务最终状态，回滚时据此查找上一个成功的版本
+	status := "complete"
+	if err != nil {
+		status = "failed"
+		if ctx.Err() == context.Canceled && !preempted {
+			status = "cancel"
+		}
+	}
+	common.FinishTaskLogMeta(taskID, status)
+
+	// 清理任务上下文
+	common.CleanupTask(taskID)
+
+	// 延迟压缩任务日志
+	common.ScheduleTaskLogCompression(taskID)
+}
+
+// runDeployment 根据type字段选择处理器执行部署: web/double/single
+func runDeployment(ctx context.Context, req CallbackRequest, taskID string, logger *common.Logger) error {
+	var err error
+	if req.Type == "web" {
+		// Web项目构建
+		processor := webBuild.NewRemoteProcessor(
+			req.Project,
+			req.Category,
+			req.Tag,
+			req.ProjectName,
+			taskID,
+			req.Type,
+			ctx,
+			req.UpdateFeishuURL,
+			req.NotifyFeishuURL,
+			req.CreateTime,
+			req.StepDurations,
+		)
+		if err = processor.ProcessRemoteRequest(); err != nil {
+			logger.Error("web构建处理失败:", fmt.Sprintf("项目=%s, 标签=%s, 错误=%v",
+				req.Project, req.Tag, err))
+		} else {
+			logger.Info("web构建处理成功:", fmt.Sprintf("项目=%s, 标签=%s",
+				req.Project, req.Tag))
+		}
+	} else if req.Type == "double" {
+		// Java双版本部署
+		processor := javaBuild.NewDoubleVersionProcessor(
+			req.Project,
+			req.Tag,
+			req.ProjectName,
+			taskID,
+			req.Type,
+			ctx,
+			req.UpdateFeishuURL,
+			req.NotifyFeishuURL,
+			req.CreateTime,
+			req.StepDurations,
+		)
+		if err = processor.ProcessDoubleVersionDeployment(); err != nil {
+			logger.Error("双版本java构建处理失败:", fmt.Sprintf("项目=%s, 标签=%s, 错误=%v",
+				req.Project, req.Tag, err))
+		} else {
+			logger.Info("双版本java构建处理成功:", fmt.Sprintf("项目=%s, 标签=%s",
+				req.Project, req.Tag))
+		}
+	} else {
+		// Java单版本部署 (type == "single" 或其他)
+		processor := javaBuild.NewSingleVersionProcessor(
+			req.Project,
+			req.Category,
+			req.Tag,
+			req.ProjectName,
+			taskID,
+			req.Type,
+			ctx,
+			req.UpdateFeishuURL,
+			req.NotifyFeishuURL,
+			req.CreateTime,
+			req.StepDurations,
+		)
+		if err = processor.ProcessSingleVersionDeployment(); err != nil {
+			logger.Error("单版本java构建处理失败:", fmt.Sprintf("项目=%s, 标签=%s, 错误=%v",
+				req.Project, req.Tag, err))
+		} else {
+			logger.Info("单版本java构建处理成功:", fmt.Sprintf("项目=%s, 标签=%s",
+				req.Project, req.Tag))
+		}
+	}
+	return err
 }
 
 // HandleCancel 取消正在执行的任务
 func HandleCancel(c *gin.Context) {
-	// 直接解析明文取消请求
+	logger := common.RequestLogger(c)
+
+	// 按配置解密EncryptedRequest格式的请求体
+	if err := decodeRequestBody(c); err != nil {
+		logger.Error("取消请求解密失败:", err)
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: err.Error()})
+		return
+	}
+
+	// 解析取消请求
 	var req CancelRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		common.AppLogger.Error("取消请求参数绑定失败:", err)
+		logger.Error("取消请求参数绑定失败:", err)
 		c.JSON(http.StatusBadRequest, Response{
 			Code: 400,
 			Msg:  fmt.Sprintf("请求参数错误: %v", err),
@@ -221,16 +431,23 @@ func HandleCancel(c *gin.Context) {
 	}
 
 	if ok := common.CancelTask(req.ID); ok {
-		common.AppLogger.Info("收到取消任务请求:", req.ID)
+		logger.Info("收到取消任务请求:", req.ID)
 		c.JSON(http.StatusOK, Response{Code: 200, Msg: "任务取消信号已发送"})
 		return
 	}
 
+	// 尚未开始的计划任务直接移除
+	if ok := cancelScheduledTask(req.ID); ok {
+		logger.Info("收到取消计划任务请求:", req.ID)
+		c.JSON(http.StatusOK, Response{Code: 200, Msg: "计划任务已取消"})
+		return
+	}
+
 	c.JSON(http.StatusNotFound, Response{Code: 404, Msg: "未找到对应的任务或任务已结束"})
 }
 
-// callRemoteAPI 调用远程API
-func callRemoteAPI(req UpdateRequest) error {
+// callRemoteAPI 调用远程API（调用方断开连接时中止请求）
+func callRemoteAPI(ctx context.Context, req UpdateRequest, requestID string) error {
 	// 构建回调URL
 	callbackURL := config.AppConfig.GetCallbackURL()
 
@@ -242,6 +459,8 @@ func callRemoteAPI(req UpdateRequest) error {
 		CallbackURL: callbackURL,
 		Type:        req.Type,
 		Category:    req.Category,
+		DeployAt:    req.DeployAt,
+		Priority:    req.Priority,
 	}
 
 	// 序列化请求
@@ -253,24 +472,22 @@ func callRemoteAPI(req UpdateRequest) error {
 	//common.AppLogger.Info("发送到远程服务的URL:", config.AppConfig.Remote.UpdateURL)
 	common.AppLogger.Info("发送到远程服务的数据:", string(jsonData))
 
-	// 发送HTTP请求
-	resp, err := http.Post(
-		config.AppConfig.Remote.UpdateURL,
-		"application/json",
-		bytes.NewBuffer(jsonData),
-	)
+	// 发送HTTP请求；远程构建请求不幂等，失败不重试
+	resp, err := common.DoHTTP(ctx, common.HTTPRequest{
+		Method: http.MethodPost,
+		URL:    config.AppConfig.Remote.UpdateURL,
+		Body:   jsonData,
+		Header: map[string]string{
+			"Content-Type": "application/json",
+			// 透传请求ID，便于服务端关联后续回调
+			common.RequestIDHeader: requestID,
+		},
+		NoRetry: true,
+	})
 	if err != nil {
 		return fmt.Errorf("发送请求失败: %v", err)
 	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-
-		}
-	}(resp.Body)
-
-	// 读取响应内容
-	respBody, _ := io.ReadAll(resp.Body)
+	respBody := resp.Body
 	//common.AppLogger.Info("远程服务响应状态:", resp.StatusCode)
 	//common.AppLogger.Info("远程服务响应内容:", string(respBody))
 
@@ -0,0 +1,63 @@
+package taskCenter
+
+import (
+	"cicd-agent/common"
+	"cicd-agent/config"
+	"cicd-agent/taskStep/javaBuild"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleProjectHealthCheck 对项目的pod执行一次健康检查并返回每个pod的结果，用于切换流量前确认环境
+// 请求体可选，namespace为检查的namespace（需属于该项目），默认当前承载流量的版本
+// POST /api/v1/project/:project/healthcheck {"namespace": "demo-service-v2"}
+func HandleProjectHealthCheck(c *gin.Context) {
+	logger := common.RequestLogger(c)
+	project := c.Param("project")
+
+	var req HealthCheckRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: fmt.Sprintf("请求参数错误: %v", err)})
+		return
+	}
+	if !config.AppConfig.IsValidProject(project) {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: fmt.Sprintf("项目 %s 不在有效项目列表中", project)})
+		return
+	}
+	if _, ok := config.AppConfig.GetProjectPath(project); !ok {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: fmt.Sprintf("项目 %s 不是Java服务项目，无法执行健康检查", project)})
+		return
+	}
+
+	namespace, pods, err := javaBuild.CheckProjectHealth(c.Request.Context(), project, req.Namespace)
+	if err != nil {
+		logger.Warning(fmt.Sprintf("健康检查失败: 项目=%s, namespace=%s, 错误=%v", project, namespace, err))
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Msg: fmt.Sprintf("健康检查失败: %v", err)})
+		return
+	}
+
+	healthy := 0
+	for _, pod := range pods {
+		if pod.Healthy {
+			healthy++
+		}
+	}
+	logger.Info(fmt.Sprintf("健康检查完成: 项目=%s, namespace=%s, 健康=%d/%d", project, namespace, healthy, len(pods)))
+
+	c.JSON(http.StatusOK, Response{
+		Code: 200,
+		Msg:  "健康检查完成",
+		Data: gin.H{
+			"project":   project,
+			"namespace": namespace,
+			"total":     len(pods),
+			"healthy":   healthy,
+			"all_ready": len(pods) > 0 && healthy == len(pods),
+			"pods":      pods,
+		},
+	})
+}
@@ -0,0 +1,117 @@
+package taskCenter
+
+import (
+	"cicd-agent/common"
+	"cicd-agent/config"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	historyDefaultLimit = 20
+	historyMaxLimit     = 200
+)
+
+// HandleProjectHistory 查询项目的发布历史：每次成功发布的标签、时间，以及相对上一次发布的镜像和部署文件变化
+// GET /api/v1/project/:project/history?limit=20
+func HandleProjectHistory(c *gin.Context) {
+	project := c.Param("project")
+	if !config.AppConfig.IsValidProject(project) {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: fmt.Sprintf("项目 %s 不在有效项目列表中", project)})
+		return
+	}
+	limit := boundedIntQuery(c, "limit", historyDefaultLimit, 1, historyMaxLimit)
+
+	// metas按开始时间从新到旧排列，多取一条用于计算最后一项的变化
+	var releases []*common.ReleaseRecord
+	var metas []*common.TaskLogMeta
+	all := common.ListTaskLogMetas(project)
+	for i := range all {
+		meta := &all[i]
+		if meta.Status != "complete" || isMaintenanceTask(meta) {
+			continue
+		}
+		record, err := common.ReadReleaseRecord(meta.TaskID)
+		if err != nil {
+			continue
+		}
+		releases = append(releases, record)
+		metas = append(metas, meta)
+		if len(releases) > limit {
+			break
+		}
+	}
+
+	history := make([]ReleaseHistoryItem, 0, limit)
+	for i := 0; i < len(releases) && i < limit; i++ {
+		release := releases[i]
+		item := ReleaseHistoryItem{
+			TaskID:     release.TaskID,
+			Tag:        release.Tag,
+			Version:    release.Version,
+			Trigger:    metas[i].Trigger,
+			DeployedAt: release.DeployedAt,
+			FinishedAt: metas[i].FinishedAt,
+		}
+		if i+1 < len(releases) {
+			item.PreviousTag = releases[i+1].Tag
+			item.Changes = diffReleases(releases[i+1], release)
+		}
+		history = append(history, item)
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code: 200,
+		Msg:  "查询成功",
+		Data: gin.H{
+			"project": project,
+			"history": history,
+		},
+	})
+}
+
+// diffReleases 对比两次发布的镜像和部署文件
+func diffReleases(previous, current *common.ReleaseRecord) *ReleaseChanges {
+	changes := &ReleaseChanges{}
+	for service, image := range current.Images {
+		old, ok := previous.Images[service]
+		switch {
+		case !ok:
+			changes.ImagesAdded = append(changes.ImagesAdded, service)
+		case image.Digest != "" && image.Digest == old.Digest:
+			changes.ImagesUnchanged = append(changes.ImagesUnchanged, service)
+		default:
+			changes.ImagesChanged = append(changes.ImagesChanged, service)
+		}
+	}
+	for service := range previous.Images {
+		if _, ok := current.Images[service]; !ok {
+			changes.ImagesRemoved = append(changes.ImagesRemoved, service)
+		}
+	}
+
+	for file, sum := range current.Files {
+		old, ok := previous.Files[file]
+		if !ok {
+			changes.FilesAdded = append(changes.FilesAdded, file)
+		} else if old != sum {
+			changes.FilesChanged = append(changes.FilesChanged, file)
+		}
+	}
+	for file := range previous.Files {
+		if _, ok := current.Files[file]; !ok {
+			changes.FilesRemoved = append(changes.FilesRemoved, file)
+		}
+	}
+
+	for _, list := range [][]string{
+		changes.ImagesAdded, changes.ImagesRemoved, changes.ImagesChanged, changes.ImagesUnchanged,
+		changes.FilesAdded, changes.FilesRemoved, changes.FilesChanged,
+	} {
+		sort.Strings(list)
+	}
+	return changes
+}
@@ -0,0 +1,223 @@
+package taskCenter
+
+import (
+	"cicd-agent/common"
+	"cicd-agent/config"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// jenkinsCrumbField Jenkins CSRF Crumb请求头
+const jenkinsCrumbField = "Jenkins-Crumb"
+
+// jenkinsNotification Jenkins构建通知：兼容Notification插件的构建JSON，以及Generic Webhook插件/curl发送的扁平字段
+type jenkinsNotification struct {
+	// Notification插件
+	Name  string `json:"name"`
+	Build struct {
+		Number     int64             `json:"number"`
+		Phase      string            `json:"phase"`  // STARTED/COMPLETED/FINALIZED
+		Status     string            `json:"status"` // SUCCESS/FAILURE/UNSTABLE/ABORTED
+		FullURL    string            `json:"full_url"`
+		Timestamp  int64             `json:"timestamp"` // 构建开始时间（毫秒）
+		Duration   int64             `json:"duration"`  // 构建耗时（毫秒）
+		Parameters map[string]string `json:"parameters"`
+		SCM        struct {
+			Branch string `json:"branch"`
+			Commit string `json:"commit"`
+		} `json:"scm"`
+	} `json:"build"`
+
+	// Generic Webhook插件/curl
+	Job         string            `json:"job"`
+	BuildNumber int64             `json:"build_number"`
+	Result      string            `json:"result"`
+	Project     string            `json:"project"`
+	Tag         string            `json:"tag"`
+	Commit      string            `json:"commit"`
+	Parameters  map[string]string `json:"parameters"`
+}
+
+var (
+	jenkinsCrumbOnce sync.Once
+	jenkinsCrumbKey  []byte
+)
+
+// HandleJenkinsNotify 接收Jenkins构建完成通知，转换为构建成功回调后按回调流程部署
+// 镜像标签依次取构建参数（webhooks.jenkins.tag_param，默认TAG）、tag字段、提交短SHA
+// 未完成或未成功的构建返回200并忽略，避免Jenkins任务因通知失败而报错
+// POST /jenkins/notify、/api/v1/jenkins/notify
+func HandleJenkinsNotify(c *gin.Context) {
+	logger := common.RequestLogger(c)
+	jenkinsConfig := config.AppConfig.Webhooks.Jenkins
+	if !jenkinsConfig.Enable {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Msg: "未开启Jenkins通知"})
+		return
+	}
+
+	var notification jenkinsNotification
+	if err := c.ShouldBindJSON(&notification); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: fmt.Sprintf("请求参数错误: %v", err)})
+		return
+	}
+	job := notification.Name
+	if job == "" {
+		job = notification.Job
+	}
+	operator, err := authenticateJenkins(c, jenkinsConfig, job)
+	if err != nil {
+		logger.Warning(fmt.Sprintf("Jenkins通知认证失败: 任务=%s, 来源=%s, 原因=%v", job, common.GetClientIP(c), err))
+		c.JSON(http.StatusUnauthorized, Response{Code: 401, Msg: err.Error()})
+		return
+	}
+
+	phase, status := notification.Build.Phase, notification.Build.Status
+	if status == "" {
+		status = notification.Result
+	}
+	if phase != "" && phase != "COMPLETED" && phase != "FINALIZED" {
+		c.JSON(http.StatusOK, Response{Code: 200, Msg: fmt.Sprintf("事件已忽略: 构建阶段为 %s", phase)})
+		return
+	}
+	if status != "SUCCESS" {
+		logger.Info(fmt.Sprintf("忽略Jenkins通知: 任务=%s, 构建结果=%s", job, status))
+		c.JSON(http.StatusOK, Response{Code: 200, Msg: fmt.Sprintf("事件已忽略: 构建结果为 %s", status)})
+		return
+	}
+
+	tag := jenkinsDeployTag(notification, jenkinsConfig.GetTagParam())
+	if tag == "" {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: fmt.Sprintf("未找到镜像标签，请传递构建参数 %s 或 tag 字段", jenkinsConfig.GetTagParam())})
+		return
+	}
+	project := notification.Project
+	if project == "" {
+		if job == "" {
+			c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: "缺少任务名或项目名"})
+			return
+		}
+		project = jenkinsConfig.ProjectFor(job)
+	}
+	deployType, err := webhookDeployType(project)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: err.Error()})
+		return
+	}
+
+	req := CallbackRequest{
+		Project:     project,
+		Type:        deployType,
+		Status:      "success",
+		Tag:         tag,
+		ProjectName: job,
+		CreateTime:  time.Now().Format("2006-01-02 15:04:05"),
+	}
+	if build := notification.Build; build.Timestamp > 0 {
+		req.FinishedAt = time.UnixMilli(build.Timestamp + build.Duration).Format("2006-01-02 15:04:05")
+	}
+	number := notification.Build.Number
+	if number == 0 {
+		number = notification.BuildNumber
+	}
+	if number > 0 {
+		// COMPLETED和FINALIZED两次通知、以及重复投递按任务ID去重
+		req.TaskID = fmt.Sprintf("%s-jenkins-%d", project, number)
+	}
+	logger.Info("Jenkins触发部署:", fmt.Sprintf("任务=%s, 构建号=%d, 项目=%s, 标签=%s, 用户=%s", job, number, project, tag, operator))
+
+	acceptCallback(c, req)
+}
+
+// HandleJenkinsCrumb 兼容Jenkins的Crumb签发接口，使用Basic认证获取Jenkins-Crumb后再调用通知接口
+// GET /jenkins/crumbIssuer/api/json、/api/v1/jenkins/crumbIssuer/api/json
+func HandleJenkinsCrumb(c *gin.Context) {
+	jenkinsConfig := config.AppConfig.Webhooks.Jenkins
+	if !jenkinsConfig.Enable {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Msg: "未开启Jenkins通知"})
+		return
+	}
+	user, apiToken, ok := c.Request.BasicAuth()
+	if !ok || !jenkinsUserValid(jenkinsConfig, user, apiToken) {
+		c.Header("WWW-Authenticate", `Basic realm="Jenkins"`)
+		c.JSON(http.StatusUnauthorized, Response{Code: 401, Msg: "用户名或API Token错误"})
+		return
+	}
+	// 与Jenkins返回格式一致，便于现有脚本直接解析
+	c.JSON(http.StatusOK, gin.H{
+		"_class":            "hudson.security.csrf.DefaultCrumbIssuer",
+		"crumb":             jenkinsCrumb(user),
+		"crumbRequestField": jenkinsCrumbField,
+	})
+}
+
+// authenticateJenkins 校验Jenkins请求：查询参数token匹配任务的远程触发令牌，或Basic认证（需要时校验Crumb），返回操作人
+func authenticateJenkins(c *gin.Context, jenkinsConfig config.JenkinsWebhookConfig, job string) (string, error) {
+	if token := c.Query("token"); token != "" {
+		expected := jenkinsConfig.TokenFor(job)
+		if expected == "" || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+			return "", fmt.Errorf("远程触发令牌错误")
+		}
+		return "jenkins", nil
+	}
+
+	user, apiToken, ok := c.Request.BasicAuth()
+	if !ok {
+		return "", fmt.Errorf("缺少token参数或Basic认证")
+	}
+	if !jenkinsUserValid(jenkinsConfig, user, apiToken) {
+		return "", fmt.Errorf("用户名或API Token错误")
+	}
+	if jenkinsConfig.CrumbRequired() {
+		crumb := c.GetHeader(jenkinsCrumbField)
+		if subtle.ConstantTimeCompare([]byte(crumb), []byte(jenkinsCrumb(user))) != 1 {
+			return "", fmt.Errorf("缺少或无效的%s", jenkinsCrumbField)
+		}
+	}
+	return user, nil
+}
+
+// jenkinsUserValid 校验Jenkins用户名和API Token
+func jenkinsUserValid(jenkinsConfig config.JenkinsWebhookConfig, user, apiToken string) bool {
+	expected, ok := jenkinsConfig.Users[user]
+	return ok && expected != "" && subtle.ConstantTimeCompare([]byte(apiToken), []byte(expected)) == 1
+}
+
+// jenkinsCrumb 按用户生成Crumb（进程启动时随机生成密钥，重启后需重新获取，与Jenkins会话失效的行为一致）
+func jenkinsCrumb(user string) string {
+	jenkinsCrumbOnce.Do(func() {
+		jenkinsCrumbKey = make([]byte, 32)
+		rand.Read(jenkinsCrumbKey)
+	})
+	mac := hmac.New(sha256.New, jenkinsCrumbKey)
+	mac.Write([]byte(user))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// jenkinsDeployTag 确定部署的镜像标签：构建参数 > tag字段 > 提交短SHA
+func jenkinsDeployTag(notification jenkinsNotification, tagParam string) string {
+	if tag := notification.Build.Parameters[tagParam]; tag != "" {
+		return tag
+	}
+	if tag := notification.Parameters[tagParam]; tag != "" {
+		return tag
+	}
+	if notification.Tag != "" {
+		return notification.Tag
+	}
+	if commit := notification.Build.SCM.Commit; commit != "" {
+		return shortSHA(commit)
+	}
+	if notification.Commit != "" {
+		return shortSHA(notification.Commit)
+	}
+	return ""
+}
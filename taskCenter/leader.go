@@ -0,0 +1,15 @@
+package taskCenter
+
+import (
+	"net/http"
+
+	"cicd-agent/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleLeaderStatus 查看选主状态（不转发到主节点，用于确认各节点的角色）
+// GET /api/v1/leader
+func HandleLeaderStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, Response{Code: 200, Msg: "查询成功", Data: common.GetLeaderStatus()})
+}
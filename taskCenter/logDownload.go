@@ -0,0 +1,33 @@
+package taskCenter
+
+import (
+	"cicd-agent/common"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleLogDownload 下载任务的全部日志（tar.gz）
+// GET /api/v1/logs/download?task_id=任务ID
+func HandleLogDownload(c *gin.Context) {
+	taskID := c.Query("task_id")
+	if !common.IsSafeTaskID(taskID) {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: "任务ID无效"})
+		return
+	}
+	if info, err := os.Stat(filepath.Join("logs", taskID)); err != nil || !info.IsDir() {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Msg: "未找到任务日志"})
+		return
+	}
+
+	c.Header("Content-Type", "application/gzip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-logs.tar.gz"`, taskID))
+	c.Status(http.StatusOK)
+	if err := common.WriteTaskLogArchive(c.Writer, taskID); err != nil {
+		// 响应头已发送，只能记录日志
+		common.RequestLogger(c).Error("打包任务日志失败:", err)
+	}
+}
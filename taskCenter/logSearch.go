@@ -0,0 +1,83 @@
+package taskCenter
+
+import (
+	"cicd-agent/common"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	logSearchDefaultLimit   = 100
+	logSearchMaxLimit       = 500
+	logSearchDefaultContext = 2
+	logSearchMaxContext     = 10
+)
+
+// HandleLogSearch 跨任务检索日志
+// GET /api/logs/search?q=关键字&project=项目&since=24h&context=2&limit=100
+// since 支持时长（如 24h、30m）或时间（2006-01-02、2006-01-02 15:04:05、RFC3339）
+func HandleLogSearch(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: "缺少检索关键字参数q"})
+		return
+	}
+
+	opts := common.LogSearchOptions{
+		Query:   query,
+		Project: c.Query("project"),
+		Context: boundedIntQuery(c, "context", logSearchDefaultContext, 0, logSearchMaxContext),
+		Limit:   boundedIntQuery(c, "limit", logSearchDefaultLimit, 1, logSearchMaxLimit),
+	}
+
+	if since := c.Query("since"); since != "" {
+		sinceTime, err := parseSince(since)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: err.Error()})
+			return
+		}
+		opts.Since = sinceTime
+	}
+
+	result, err := common.SearchTaskLogs(opts)
+	if err != nil {
+		common.AppLogger.Error("检索任务日志失败:", err)
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Msg: "检索任务日志失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Code: 200, Msg: "检索成功", Data: result})
+}
+
+// parseSince 解析since参数，支持时长或绝对时间
+func parseSince(value string) (time.Time, error) {
+	if duration, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-duration), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无效的since参数: %s", value)
+}
+
+// boundedIntQuery 读取整数查询参数，缺省或非法时使用默认值，并限制在[min, max]范围内
+func boundedIntQuery(c *gin.Context, key string, defaultValue, min, max int) int {
+	value, err := strconv.Atoi(c.Query(key))
+	if err != nil {
+		return defaultValue
+	}
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
@@ -0,0 +1,47 @@
+package taskCenter
+
+import (
+	"cicd-agent/common"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleTaskManifestDiff 查询任务在部署目录本地git仓库中的提交和对部署文件的修改（diff）
+// 需要回退时在部署目录执行 git revert <commit> 后重新apply
+// GET /api/v1/task/:id/manifest-diff
+func HandleTaskManifestDiff(c *gin.Context) {
+	taskID := c.Param("id")
+
+	record, err := common.ReadManifestCommit(taskID)
+	if err != nil {
+		if os.IsNotExist(err) {
+			c.JSON(http.StatusNotFound, Response{Code: 404, Msg: fmt.Sprintf("任务 %s 没有部署文件提交记录", taskID)})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Msg: fmt.Sprintf("读取部署文件提交记录失败: %v", err)})
+		return
+	}
+	diff, err := common.ManifestDiff(c.Request.Context(), *record)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Msg: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code: 200,
+		Msg:  "查询成功",
+		Data: gin.H{
+			"task_id":      record.TaskID,
+			"project":      record.Project,
+			"tag":          record.Tag,
+			"deploy_dir":   record.DeployDir,
+			"commit":       record.Commit,
+			"parent":       record.Parent,
+			"committed_at": record.CommittedAt,
+			"diff":         diff,
+		},
+	})
+}
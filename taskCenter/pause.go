@@ -0,0 +1,36 @@
+package taskCenter
+
+import (
+	"cicd-agent/common"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleTaskPause 暂停执行中的任务，任务在当前步骤结束后停住（如流量切换前检查集群）
+// POST /api/v1/task/:id/pause
+func HandleTaskPause(c *gin.Context) {
+	if err := common.PauseTask(c.Param("id"), taskOperator(c)); err != nil {
+		c.JSON(http.StatusConflict, Response{Code: 409, Msg: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 200, Msg: "任务将在当前步骤结束后暂停"})
+}
+
+// HandleTaskResume 恢复暂停的任务
+// POST /api/v1/task/:id/resume
+func HandleTaskResume(c *gin.Context) {
+	if err := common.ResumeTask(c.Param("id"), taskOperator(c)); err != nil {
+		c.JSON(http.StatusConflict, Response{Code: 409, Msg: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 200, Msg: "任务已恢复"})
+}
+
+// taskOperator 操作人：认证主体，未开启认证时为客户端IP
+func taskOperator(c *gin.Context) string {
+	if subject := common.GetAuthSubject(c); subject != "" {
+		return subject
+	}
+	return common.GetClientIP(c)
+}
@@ -0,0 +1,61 @@
+package taskCenter
+
+import (
+	"cicd-agent/common"
+	"context"
+	"fmt"
+	"time"
+)
+
+// runProjectTask 执行不经过部署流水线的项目运维任务（手动切换流量、清理旧版本）
+// 任务有独立的任务ID、日志和通知，可通过取消接口中止；tag和category仅用于通知展示
+func runProjectTask(project, tag, category, taskID, requestID, trigger string, run func(ctx context.Context) error) {
+	ctx, _ := common.CreateTaskContext(taskID)
+	startedAt := time.Now().Format("2006-01-02 15:04:05")
+	common.WriteTaskLogMeta(common.TaskLogMeta{
+		TaskID:    taskID,
+		Project:   project,
+		Tag:       tag,
+		Type:      "double",
+		StartedAt: startedAt,
+		RequestID: requestID,
+		Trigger:   trigger,
+	})
+
+	notifyProjectTask(project, tag, category, taskID, startedAt, "running")
+	status := "complete"
+	if err := run(ctx); err != nil {
+		common.AppLogger.Error(fmt.Sprintf("%s失败: 项目=%s, 任务ID=%s, 错误=%v", category, project, taskID, err))
+		status = "failed"
+		if ctx.Err() == context.Canceled {
+			status = "cancel"
+		}
+	}
+	notifyProjectTask(project, tag, category, taskID, startedAt, status)
+
+	common.FinishTaskLogMeta(taskID, status)
+	common.CleanupTask(taskID)
+	common.ScheduleTaskLogCompression(taskID)
+}
+
+// notifyProjectTask 发送运维任务通知
+func notifyProjectTask(project, tag, category, taskID, startedAt, status string) {
+	event := common.TaskEvent{
+		TaskID:      taskID,
+		Project:     project,
+		ProjectName: project,
+		Tag:         tag,
+		Category:    category,
+		DeployType:  "double",
+		Status:      status,
+		StartedAt:   startedAt,
+	}
+	if err := common.NotifyTask(event); err != nil {
+		common.AppLogger.Error(fmt.Sprintf("发送%s通知失败: 状态=%s, 错误=%v", category, status, err))
+	}
+}
+
+// isMaintenanceTask 判断是否为运维任务，运维任务没有部署参数，不能重试或作为回滚目标
+func isMaintenanceTask(meta *common.TaskLogMeta) bool {
+	return meta.Trigger == "switch" || meta.Trigger == "cleanup"
+}
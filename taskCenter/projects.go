@@ -0,0 +1,102 @@
+package taskCenter
+
+import (
+	"cicd-agent/common"
+	"cicd-agent/config"
+	"net/http"
+	"path/filepath"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleProjectList 查询所有配置的项目：类型、部署路径、当前版本、流量代理地址和最近一次部署
+// GET /api/v1/projects
+func HandleProjectList(c *gin.Context) {
+	lastDeployments := make(map[string]*DeploymentSummary)
+	metas := common.ListTaskLogMetas("")
+	// metas按开始时间从新到旧排列，每个项目取第一条部署记录（跳过手动切换、清理等运维任务）
+	for i := range metas {
+		meta := &metas[i]
+		if _, ok := lastDeployments[meta.Project]; ok || isMaintenanceTask(meta) {
+			continue
+		}
+		status := meta.Status
+		if status == "" {
+			status = "running"
+		}
+		lastDeployments[meta.Project] = &DeploymentSummary{
+			TaskID:     meta.TaskID,
+			Tag:        meta.Tag,
+			Status:     status,
+			Trigger:    meta.Trigger,
+			StartedAt:  meta.StartedAt,
+			FinishedAt: meta.FinishedAt,
+		}
+	}
+
+	projects := make([]ProjectInfo, 0)
+	for _, project := range configuredProjects() {
+		info := describeProject(project)
+		info.LastDeployment = lastDeployments[project]
+		projects = append(projects, info)
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code: 200,
+		Msg:  "查询成功",
+		Data: gin.H{
+			"projects":      projects,
+			"traffic_proxy": config.AppConfig.GetTrafficProxyEnable(),
+		},
+	})
+}
+
+// configuredProjects 配置中出现的全部项目（双版本、单版本和有效项目列表），按名称排序
+func configuredProjects() []string {
+	seen := make(map[string]bool)
+	var projects []string
+	add := func(project string) {
+		if project != "" && !seen[project] {
+			seen[project] = true
+			projects = append(projects, project)
+		}
+	}
+	for project := range config.AppConfig.Deployment.Double {
+		add(project)
+	}
+	for project := range config.AppConfig.Deployment.Single {
+		add(project)
+	}
+	for _, project := range config.AppConfig.Projects.ValidNames {
+		add(project)
+	}
+	sort.Strings(projects)
+	return projects
+}
+
+// describeProject 根据配置和版本文件生成项目清单项（不含最近部署）
+func describeProject(project string) ProjectInfo {
+	info := ProjectInfo{Project: project, Type: "unknown"}
+	path, ok := config.AppConfig.GetProjectPath(project)
+	switch {
+	case ok && common.HasVersionStructure(project):
+		info.Type, info.Path = "double", path
+		info.DeploymentPaths = []string{
+			filepath.Join(path, "deployment-v1"),
+			filepath.Join(path, "deployment-v2"),
+		}
+		if versionInfo, err := common.ReadVersionInfo(project); err == nil && versionInfo != nil {
+			info.CurrentVersion = versionInfo.CurrentVersion
+		}
+		if config.AppConfig.GetTrafficProxyEnable() {
+			info.TrafficProxyURLs = config.AppConfig.GetTrafficProxyURLs(project)
+		}
+	case ok:
+		info.Type, info.Path = "single", path
+		info.DeploymentPaths = []string{filepath.Join(path, "deployment")}
+	case config.AppConfig.IsWebProject(project):
+		info.Type, info.Path = "web", config.AppConfig.GetWebPath(project)
+	}
+	return info
+}
@@ -0,0 +1,225 @@
+package taskCenter
+
+import (
+	"cicd-agent/common"
+	"cicd-agent/config"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 任务优先级：高优先级（hotfix）任务排在普通任务之前
+const (
+	priorityNormal = 0
+	priorityHigh   = 1
+)
+
+// queuedTask 等待执行名额的任务
+type queuedTask struct {
+	req           CallbackRequest
+	taskID        string
+	priority      int
+	queuedAt      time.Time
+	ready         chan struct{} // 分配到名额时关闭
+	preempted     chan struct{} // 被同项目高优先级任务抢占时关闭
+	preemptReason string
+}
+
+// 全局任务队列：执行中的任务数、各项目执行中的任务和按优先级、到达顺序排队的任务
+// 同一项目的任务依次执行（共用部署目录和集群资源），不同项目的任务受max_running_tasks限制
+var (
+	taskQueueMu     sync.Mutex
+	runningSlots    int
+	runningProjects = make(map[string]bool)
+	taskQueue       []*queuedTask
+)
+
+// parsePriority 解析回调的优先级：normal（默认）/high，hotfix等同于high
+func parsePriority(value string) (int, error) {
+	switch value {
+	case "", "normal":
+		return priorityNormal, nil
+	case "high", "hotfix":
+		return priorityHigh, nil
+	}
+	return priorityNormal, fmt.Errorf("priority取值错误: %s（支持normal/high/hotfix）", value)
+}
+
+// acquireTaskSlot 获取任务执行名额：同项目有任务在执行或达到deployment.max_running_tasks上限时排队并发送queued通知
+// 高优先级任务排在所有低优先级任务之前，并抢占同项目排队中的低优先级任务（避免旧版本覆盖hotfix）
+// 返回释放函数；排队期间任务被取消时返回ctx的错误，被抢占时（包括抢占后又被取消）返回抢占原因
+func acquireTaskSlot(ctx context.Context, req CallbackRequest, taskID string) (func(), error) {
+	var once sync.Once
+	release := func() { once.Do(func() { releaseTaskSlot(req.Project) }) }
+
+	priority, _ := parsePriority(req.Priority)
+	item := &queuedTask{
+		req:       req,
+		taskID:    taskID,
+		priority:  priority,
+		queuedAt:  time.Now(),
+		ready:     make(chan struct{}),
+		preempted: make(chan struct{}),
+	}
+	taskQueueMu.Lock()
+	enqueueTask(item)
+	if priority > priorityNormal {
+		preemptQueuedTasks(item)
+	}
+	// 有空闲名额时立即分配（包括调大上限后多出的名额）
+	dispatchQueuedTasks()
+	position := queuePosition(taskID)
+	projectBusy := runningProjects[req.Project]
+	taskQueueMu.Unlock()
+	if position == 0 {
+		return release, nil
+	}
+
+	reason := fmt.Sprintf("执行中的任务已达上限 %d，排队第 %d 位", config.AppConfig.GetMaxRunningTasks(), position)
+	if projectBusy {
+		reason = fmt.Sprintf("项目 %s 有任务正在执行，排队第 %d 位", req.Project, position)
+	}
+	common.AppLogger.Info(fmt.Sprintf("任务排队等待执行: 任务ID=%s, 项目=%s, %s", taskID, req.Project, reason))
+	if taskLogger := common.NewTaskLogger(taskID); taskLogger != nil {
+		taskLogger.WriteConsole("INFO", fmt.Sprintf("任务排队等待执行: %s", reason))
+		taskLogger.Close()
+	}
+	go notifyQueueEvent(req, taskID, "queued")
+
+	select {
+	case <-item.ready:
+		common.AppLogger.Info(fmt.Sprintf("任务结束排队: 任务ID=%s, 等待时长=%s", taskID, time.Since(item.queuedAt).Round(time.Second)))
+		return release, nil
+	case <-item.preempted:
+		return nil, errors.New(item.preemptReason)
+	case <-ctx.Done():
+		taskQueueMu.Lock()
+		removeQueuedTask(taskID)
+		// 名额的分配和抢占只在持有taskQueueMu时发生，此处可确定任务的实际状态
+		granted, preempted := false, false
+		select {
+		case <-item.ready:
+			granted = true
+		case <-item.preempted:
+			preempted = true
+		default:
+		}
+		taskQueueMu.Unlock()
+		if granted {
+			// 取消的同时分配到了名额，归还给下一个任务
+			release()
+		}
+		if preempted {
+			// 取消前已被抢占，按抢占处理（已不在队列中，不占用名额）
+			return nil, errors.New(item.preemptReason)
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// releaseTaskSlot 任务结束后归还名额，按顺序启动排队的任务
+func releaseTaskSlot(project string) {
+	taskQueueMu.Lock()
+	defer taskQueueMu.Unlock()
+	runningSlots--
+	delete(runningProjects, project)
+	dispatchQueuedTasks()
+}
+
+// slotAvailable 判断是否还有空闲名额（调用方持有taskQueueMu）
+func slotAvailable() bool {
+	limit := config.AppConfig.GetMaxRunningTasks()
+	return limit <= 0 || runningSlots < limit
+}
+
+// dispatchQueuedTasks 按队列顺序为项目空闲的任务分配名额，同项目有任务执行中的任务继续排队（调用方持有taskQueueMu）
+func dispatchQueuedTasks() {
+	remaining := taskQueue[:0]
+	for _, item := range taskQueue {
+		if !slotAvailable() || runningProjects[item.req.Project] {
+			remaining = append(remaining, item)
+			continue
+		}
+		runningSlots++
+		runningProjects[item.req.Project] = true
+		close(item.ready)
+	}
+	taskQueue = remaining
+}
+
+// enqueueTask 按优先级插入队列：排在第一个优先级更低的任务之前（调用方持有taskQueueMu）
+func enqueueTask(item *queuedTask) {
+	for i, queued := range taskQueue {
+		if queued.priority < item.priority {
+			taskQueue = append(taskQueue[:i], append([]*queuedTask{item}, taskQueue[i:]...)...)
+			return
+		}
+	}
+	taskQueue = append(taskQueue, item)
+}
+
+// preemptQueuedTasks 移除同项目排队中优先级更低的任务，由被抢占的任务发送通知（调用方持有taskQueueMu）
+func preemptQueuedTasks(by *queuedTask) {
+	remaining := taskQueue[:0]
+	for _, queued := range taskQueue {
+		if queued.req.Project != by.req.Project || queued.priority >= by.priority {
+			remaining = append(remaining, queued)
+			continue
+		}
+		queued.preemptReason = fmt.Sprintf("已被同项目高优先级任务 %s（标签 %s）抢占", by.taskID, by.req.Tag)
+		close(queued.preempted)
+		common.AppLogger.Warning(fmt.Sprintf("排队任务被抢占: 任务ID=%s, 项目=%s, 抢占任务ID=%s",
+			queued.taskID, queued.req.Project, by.taskID))
+	}
+	taskQueue = remaining
+}
+
+// removeQueuedTask 从队列中移除任务，任务已不在队列中（已分配名额或被抢占）时不做处理（调用方持有taskQueueMu）
+func removeQueuedTask(taskID string) {
+	if position := queuePosition(taskID); position > 0 {
+		taskQueue = append(taskQueue[:position-1], taskQueue[position:]...)
+	}
+}
+
+// queuePosition 任务的排队位置（从1开始），不在队列中时返回0（调用方持有taskQueueMu）
+func queuePosition(taskID string) int {
+	for i, item := range taskQueue {
+		if item.taskID == taskID {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// queuedTaskPositions 排队中的任务及其排队位置（从1开始）
+func queuedTaskPositions() map[string]int {
+	taskQueueMu.Lock()
+	defer taskQueueMu.Unlock()
+	positions := make(map[string]int, len(taskQueue))
+	for i, item := range taskQueue {
+		positions[item.taskID] = i + 1
+	}
+	return positions
+}
+
+// notifyQueueEvent 通知各渠道排队中的任务状态：queued开始排队，cancel排队期间被取消
+func notifyQueueEvent(req CallbackRequest, taskID, status string) {
+	event := common.TaskEvent{
+		TaskID:        taskID,
+		Project:       req.Project,
+		ProjectName:   req.ProjectName,
+		Tag:           req.Tag,
+		Category:      req.Category,
+		DeployType:    req.Type,
+		Status:        status,
+		StartedAt:     time.Now().Format("2006-01-02 15:04:05"),
+		OpsURL:        req.UpdateFeishuURL,
+		ProURL:        req.NotifyFeishuURL,
+		StepDurations: req.StepDurations,
+	}
+	if err := common.NotifyTask(event); err != nil {
+		common.AppLogger.Error(fmt.Sprintf("发送排队任务%s通知失败: 项目=%s, 错误=%v", status, req.Project, err))
+	}
+}
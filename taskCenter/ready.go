@@ -0,0 +1,46 @@
+package taskCenter
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"cicd-agent/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleReady 就绪检查：逐项检查外部依赖，全部可用时返回200，否则返回503（不需要认证，供负载均衡和探针使用）
+// 只返回各项的检查状态，地址、集群上下文和错误信息通过/api/v1/ready查看
+// GET /ready
+func HandleReady(c *gin.Context) {
+	report := common.CheckDependencies()
+	checks := make([]gin.H, 0, len(report.Checks))
+	kubeIndex := 0
+	for _, check := range report.Checks {
+		name := check.Name
+		// 多集群时检查项名称带有kubectl上下文，匿名调用方只看到序号
+		if strings.HasPrefix(name, "kubectl/") {
+			kubeIndex++
+			name = fmt.Sprintf("kubectl/%d", kubeIndex)
+		}
+		checks = append(checks, gin.H{"name": name, "status": check.Status})
+	}
+
+	if !report.Ready {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "msg": "部分依赖不可用", "checks": checks})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "msg": "依赖检查通过", "checks": checks})
+}
+
+// HandleReadyDetail 查看就绪检查的详细结果（检查时间、耗时和失败原因），依赖不可用时返回503
+// GET /api/v1/ready
+func HandleReadyDetail(c *gin.Context) {
+	report := common.CheckDependencies()
+	if !report.Ready {
+		c.JSON(http.StatusServiceUnavailable, Response{Code: 503, Msg: "部分依赖不可用", Data: report})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 200, Msg: "依赖检查通过", Data: report})
+}
@@ -0,0 +1,87 @@
+package taskCenter
+
+import (
+	"cicd-agent/common"
+	"cicd-agent/taskStep/javaBuild"
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleStepRerun 单独重新执行已结束任务的一个步骤（如检查服务、流量切换），不重新传输镜像
+// 使用原任务保存的参数，日志写入原任务目录
+// POST /api/v1/task/:id/steps/:stepType/rerun
+func HandleStepRerun(c *gin.Context) {
+	logger := common.RequestLogger(c)
+	taskID, stepType := c.Param("id"), c.Param("stepType")
+
+	meta, err := common.ReadTaskLogMeta(taskID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Msg: fmt.Sprintf("未找到任务记录: %s", taskID)})
+		return
+	}
+	if meta.Status == "" || meta.Status == "scheduled" {
+		c.JSON(http.StatusConflict, Response{Code: 409, Msg: "任务尚未执行结束"})
+		return
+	}
+	req, err := loadTaskRequest(taskID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Msg: fmt.Sprintf("读取原任务参数失败: %v", err)})
+		return
+	}
+	if req.Type == "web" {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: "web项目的步骤依赖前序步骤的产物，不支持单独重新执行"})
+		return
+	}
+	if !slices.Contains(javaBuild.StepTypes(req.Type), stepType) {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: fmt.Sprintf("%s部署没有步骤 %s", req.Type, stepType)})
+		return
+	}
+
+	// 原子地占用任务上下文，避免并发请求同时重新执行同一任务
+	ctx, _, ok := common.TryCreateTaskContext(taskID)
+	if !ok {
+		c.JSON(http.StatusConflict, Response{Code: 409, Msg: "任务仍在执行中"})
+		return
+	}
+
+	logger.Info(fmt.Sprintf("重新执行任务步骤: 任务ID=%s, 步骤=%s, 操作人=%s", taskID, stepType, taskOperator(c)))
+	go rerunTaskStep(ctx, *req, taskID, stepType)
+
+	c.JSON(http.StatusOK, Response{
+		Code: 200,
+		Msg:  "已开始重新执行步骤",
+		Data: gin.H{"task_id": taskID, "step_type": stepType},
+	})
+}
+
+// rerunTaskStep 使用原任务ID重新执行步骤（ctx为处理函数占用的任务上下文），执行期间可通过取消接口中止，同样受执行中任务上限限制
+func rerunTaskStep(ctx context.Context, req CallbackRequest, taskID, stepType string) {
+	defer func() {
+		common.CleanupTask(taskID)
+		common.ScheduleTaskLogCompression(taskID)
+	}()
+
+	release, err := acquireTaskSlot(ctx, req, taskID)
+	if err != nil {
+		common.AppLogger.Warning(fmt.Sprintf("步骤重新执行未开始: 任务ID=%s, 步骤=%s, 原因=%v", taskID, stepType, err))
+		return
+	}
+	defer release()
+
+	if req.Type == "double" {
+		err = javaBuild.NewDoubleVersionProcessor(req.Project, req.Tag, req.ProjectName, taskID, req.Type, ctx,
+			req.UpdateFeishuURL, req.NotifyFeishuURL, req.CreateTime, req.StepDurations).RerunStep(stepType)
+	} else {
+		err = javaBuild.NewSingleVersionProcessor(req.Project, req.Category, req.Tag, req.ProjectName, taskID, req.Type, ctx,
+			req.UpdateFeishuURL, req.NotifyFeishuURL, req.CreateTime, req.StepDurations).RerunStep(stepType)
+	}
+	if err != nil {
+		common.AppLogger.Error(fmt.Sprintf("步骤重新执行失败: 任务ID=%s, 步骤=%s, 错误=%v", taskID, stepType, err))
+		return
+	}
+	common.AppLogger.Info(fmt.Sprintf("步骤重新执行完成: 任务ID=%s, 步骤=%s", taskID, stepType))
+}
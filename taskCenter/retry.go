@@ -0,0 +1,73 @@
+package taskCenter
+
+import (
+	"cicd-agent/common"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleTaskRetry 使用原任务的参数重新发起失败或已取消的任务
+// POST /api/v1/task/:id/retry
+func HandleTaskRetry(c *gin.Context) {
+	logger := common.RequestLogger(c)
+	taskID := c.Param("id")
+
+	newTaskID, code, err := retryTask(taskID, taskOperator(c), common.GetRequestID(c))
+	if err != nil {
+		logger.Warning(fmt.Sprintf("重试任务失败: 任务ID=%s, 原因=%v", taskID, err))
+		c.JSON(code, Response{Code: code, Msg: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code: 200,
+		Msg:  "已重新发起任务",
+		Data: gin.H{"task_id": newTaskID, "retry_of": taskID},
+	})
+}
+
+// taskActionMu 串行化重试、回滚的检查和发起：检查没有执行中的任务与登记新任务在同一把锁内完成，
+// 避免重复点击或重放的请求同时发起两次部署
+var taskActionMu sync.Mutex
+
+// retryTask 使用原任务的回调参数重新执行任务，新任务记录原任务ID；原任务需已失败或取消，且没有执行中的重试
+// 返回新任务ID，失败时同时返回对应的HTTP状态码
+func retryTask(taskID, operator, requestID string) (string, int, error) {
+	taskActionMu.Lock()
+	defer taskActionMu.Unlock()
+
+	if common.IsTaskRunning(taskID) {
+		return "", http.StatusConflict, fmt.Errorf("任务仍在执行中")
+	}
+	meta, err := common.ReadTaskLogMeta(taskID)
+	if err != nil {
+		return "", http.StatusNotFound, fmt.Errorf("未找到任务记录: %s", taskID)
+	}
+	if meta.Status != "failed" && meta.Status != "cancel" {
+		return "", http.StatusConflict, fmt.Errorf("只能重试失败或已取消的任务，当前状态: %s", meta.Status)
+	}
+	for _, other := range common.ListTaskLogMetas(meta.Project) {
+		if other.RetryOf == taskID && common.IsTaskRunning(other.TaskID) {
+			return "", http.StatusConflict, fmt.Errorf("任务已在重试中: %s", other.TaskID)
+		}
+	}
+
+	req, err := loadTaskRequest(taskID)
+	if err != nil {
+		return "", http.StatusNotFound, fmt.Errorf("读取原任务参数失败: %v", err)
+	}
+
+	req.TaskID = fmt.Sprintf("%s-retry-%d", taskID, time.Now().Unix())
+	req.CreateTime = time.Now().Format("2006-01-02 15:04:05")
+	req.DeployAt = ""
+	req.RetryOf = taskID
+	if err := startClaimedTask(*req, requestID, "retry"); err != nil {
+		return "", http.StatusConflict, err
+	}
+	common.AppLogger.Info(fmt.Sprintf("重试任务: 原任务=%s, 新任务=%s, 操作人=%s", taskID, req.TaskID, operator))
+	return req.TaskID, http.StatusOK, nil
+}
@@ -0,0 +1,70 @@
+package taskCenter
+
+import (
+	"cicd-agent/common"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleTaskRollback 重新部署任务之前最近一次成功的版本（与飞书卡片的回滚按钮等效）
+// POST /api/v1/task/:id/rollback
+func HandleTaskRollback(c *gin.Context) {
+	logger := common.RequestLogger(c)
+	taskID := c.Param("id")
+
+	meta, err := common.ReadTaskLogMeta(taskID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Msg: fmt.Sprintf("未找到任务记录: %s", taskID)})
+		return
+	}
+	tag, newTaskID, code, err := rollbackTask(taskID, meta.Project, taskOperator(c), common.GetRequestID(c))
+	if err != nil {
+		logger.Warning(fmt.Sprintf("回滚失败: 任务ID=%s, 原因=%v", taskID, err))
+		c.JSON(code, Response{Code: code, Msg: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code: 200,
+		Msg:  fmt.Sprintf("开始回滚到版本 %s", tag),
+		Data: gin.H{"task_id": newTaskID, "tag": tag, "rollback_of": taskID},
+	})
+}
+
+// rollbackTask 重新部署项目在指定任务之前最近一次成功的版本，返回回滚到的标签和新任务ID
+// 失败时同时返回对应的HTTP状态码
+func rollbackTask(taskID, project, operator, requestID string) (string, string, int, error) {
+	taskActionMu.Lock()
+	defer taskActionMu.Unlock()
+
+	metas := common.ListTaskLogMetas(project)
+	// 已取消的任务在执行CleanupTask之前仍在恢复部署目录和配置，同样视为执行中
+	for _, meta := range metas {
+		if common.IsTaskRunning(meta.TaskID) {
+			return "", "", http.StatusConflict, fmt.Errorf("项目有正在执行的任务: %s", meta.TaskID)
+		}
+	}
+
+	previous, err := findPreviousSuccess(metas, taskID, previousServingTag(project, taskID))
+	if err != nil {
+		return "", "", http.StatusNotFound, err
+	}
+
+	req, err := loadTaskRequest(previous.TaskID)
+	if err != nil {
+		return "", "", http.StatusNotFound, fmt.Errorf("读取版本 %s 的任务参数失败: %v", previous.Tag, err)
+	}
+
+	req.TaskID = fmt.Sprintf("%s-rollback-%d", taskID, time.Now().Unix())
+	req.CreateTime = time.Now().Format("2006-01-02 15:04:05")
+	req.DeployAt = ""
+	if err := startClaimedTask(*req, requestID, "rollback"); err != nil {
+		return "", "", http.StatusConflict, err
+	}
+	common.AppLogger.Info(fmt.Sprintf("触发回滚: 项目=%s, 回滚到版本=%s, 新任务=%s, 操作人=%s",
+		project, previous.Tag, req.TaskID, operator))
+	return previous.Tag, req.TaskID, http.StatusOK, nil
+}
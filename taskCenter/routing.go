@@ -0,0 +1,79 @@
+package taskCenter
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"cicd-agent/common"
+	"cicd-agent/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// routedHeaders 转发时透传的请求头（签名按原始请求体计算，原样转发后目标agent可直接校验）
+var routedHeaders = []string{
+	"Content-Type",
+	common.RequestIDHeader,
+	common.APIVersionHeader,
+	common.SignatureHeader,
+	common.SignatureTimestampHeader,
+	common.SignatureNonceHeader,
+	common.SignatureKeyHeader,
+}
+
+// routeToOwner 路由模式下项目由其他agent负责时，把原始请求体转发给该agent并原样返回其响应
+// 返回false表示由本agent处理；已经被转发过的请求（routing.token校验通过）不再转发，校验不通过时拒绝
+func routeToOwner(c *gin.Context, project string, rawBody []byte) bool {
+	logger := common.RequestLogger(c)
+	if routedBy := c.GetHeader(common.RoutedByHeader); routedBy != "" {
+		if common.VerifyRoutingToken(c.GetHeader(common.RoutingTokenHeader)) {
+			return false
+		}
+		logger.Warning(fmt.Sprintf("转发请求的routing token校验失败: 项目=%s, 转发方=%s, 来源IP=%s", project, routedBy, common.GetClientIP(c)))
+		c.JSON(http.StatusForbidden, Response{Code: 403, Msg: "转发请求的routing token校验失败"})
+		return true
+	}
+	name, agent, ok := config.AppConfig.GetRouteAgent(project)
+	if !ok {
+		return false
+	}
+
+	header := make(map[string]string)
+	for _, key := range routedHeaders {
+		if value := c.GetHeader(key); value != "" {
+			header[key] = value
+		}
+	}
+	// 调用方的Authorization只用于本agent，不透传给其他agent
+	if agent.Token != "" {
+		header["Authorization"] = "Bearer " + agent.Token
+	}
+	// 目标agent需将本agent加入server.trusted_proxies才会采信调用方IP
+	header["X-Forwarded-For"] = common.GetClientIP(c)
+	hostname, _ := os.Hostname()
+	header[common.RoutedByHeader] = hostname
+	header[common.RoutingTokenHeader] = config.AppConfig.Routing.Token
+
+	resp, target, err := common.ForwardToAgent(c.Request.Context(), name, agent, c.Request.Method, c.Request.URL.RequestURI(), rawBody, header)
+	if err != nil {
+		logger.Error(fmt.Sprintf("项目 %s 的请求转发到agent %s 失败: %v", project, name, err))
+		c.JSON(http.StatusBadGateway, Response{Code: 502, Msg: fmt.Sprintf("转发到agent %s 失败: %v", name, err)})
+		return true
+	}
+	logger.Info(fmt.Sprintf("项目 %s 的请求已转发到agent %s: %s, 状态码 %d", project, name, target, resp.StatusCode))
+	c.Header("X-Cicd-Routed-To", name)
+	c.Data(resp.StatusCode, "application/json; charset=utf-8", resp.Body)
+	return true
+}
+
+// HandleRoutingStatus 查看路由模式下各目标agent的健康状态
+// GET /api/v1/routing
+func HandleRoutingStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, Response{Code: 200, Msg: "查询成功", Data: gin.H{
+		"enabled":  config.AppConfig.Routing.Enable,
+		"projects": config.AppConfig.Routing.Projects,
+		"default":  config.AppConfig.Routing.DefaultAgent,
+		"agents":   common.GetRoutingStatus(),
+	}})
+}
@@ -0,0 +1,239 @@
+package taskCenter
+
+import (
+	"cicd-agent/common"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// deployAtLayouts deploy_at支持的时间格式（不带时区的按本地时间解析）
+var deployAtLayouts = []string{time.RFC3339, "2006-01-02 15:04:05"}
+
+// scheduledTask 等待到点执行的任务；deployAt为零值表示封网中，等待解除后再安排
+type scheduledTask struct {
+	req       CallbackRequest
+	requestID string
+	trigger   string
+	deployAt  time.Time
+	reason    string // 排队原因（部署窗口、封网）
+	timer     *time.Timer
+}
+
+// 计划任务：任务ID -> 计划
+var (
+	scheduledMu    sync.Mutex
+	scheduledTasks = make(map[string]*scheduledTask)
+)
+
+// parseDeployAt 解析deploy_at，为空时返回零值
+func parseDeployAt(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range deployAtLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("deploy_at格式错误: %s（支持RFC3339或2006-01-02 15:04:05）", value)
+}
+
+// scheduleCallbackTask 登记计划任务，到deploy_at时执行；记录任务信息和回调参数，重启后可恢复
+// deployAt为零值时等待封网解除
+func scheduleCallbackTask(req CallbackRequest, requestID, trigger string, deployAt time.Time, reason string) string {
+	if req.TaskID == "" {
+		req.TaskID = fmt.Sprintf("%s-%s-%d", req.Project, req.Tag, time.Now().Unix())
+	}
+
+	common.WriteTaskLogMeta(common.TaskLogMeta{
+		TaskID:    req.TaskID,
+		Project:   req.Project,
+		Tag:       req.Tag,
+		Type:      req.Type,
+		RequestID: requestID,
+		Status:    "scheduled",
+		Trigger:   trigger,
+		DeployAt:  formatDeployAt(deployAt),
+	})
+	saveTaskRequest(req.TaskID, req)
+
+	addScheduledTask(req, requestID, trigger, deployAt, reason)
+	return req.TaskID
+}
+
+// formatDeployAt 格式化计划执行时间，等待封网解除时为空
+func formatDeployAt(deployAt time.Time) string {
+	if deployAt.IsZero() {
+		return ""
+	}
+	return deployAt.Format("2006-01-02 15:04:05")
+}
+
+// addScheduledTask 启动计划任务的定时器（等待封网解除的任务不启动定时器）
+func addScheduledTask(req CallbackRequest, requestID, trigger string, deployAt time.Time, reason string) {
+	task := &scheduledTask{req: req, requestID: requestID, trigger: trigger, deployAt: deployAt, reason: reason}
+
+	scheduledMu.Lock()
+	defer scheduledMu.Unlock()
+	if previous, ok := scheduledTasks[req.TaskID]; ok && previous.timer != nil {
+		previous.timer.Stop()
+	}
+	scheduledTasks[req.TaskID] = task
+	if deployAt.IsZero() {
+		common.AppLogger.Info(fmt.Sprintf("任务等待封网解除: 任务ID=%s, 项目=%s, 标签=%s", req.TaskID, req.Project, req.Tag))
+		return
+	}
+	task.timer = time.AfterFunc(time.Until(deployAt), func() { startScheduledTask(req.TaskID) })
+
+	common.AppLogger.Info(fmt.Sprintf("任务已计划: 任务ID=%s, 项目=%s, 标签=%s, 执行时间=%s",
+		req.TaskID, req.Project, req.Tag, formatDeployAt(deployAt)))
+}
+
+// startScheduledTask 到点执行计划任务；此时不在部署窗口内或已封网时重新排队
+func startScheduledTask(taskID string) {
+	scheduledMu.Lock()
+	task, ok := scheduledTasks[taskID]
+	delete(scheduledTasks, taskID)
+	scheduledMu.Unlock()
+	if !ok {
+		return
+	}
+
+	if allowed, reason := common.CheckDeployWindow(task.req.Project, time.Now()); !allowed {
+		deployAt, err := nextDeployTime(task.req.Project, time.Now())
+		if err != nil {
+			common.AppLogger.Warning(fmt.Sprintf("计划任务无法执行: 任务ID=%s, 原因=%s, %v", taskID, reason, err))
+			common.FinishTaskLogMeta(taskID, "failed")
+			notifyDeployRejected(task.req, fmt.Sprintf("%s，%v", reason, err))
+			return
+		}
+		common.AppLogger.Info(fmt.Sprintf("计划任务重新排队: 任务ID=%s, 原因=%s", taskID, reason))
+		recordDeployAt(taskID, deployAt)
+		addScheduledTask(task.req, task.requestID, task.trigger, deployAt, reason)
+		return
+	}
+
+	common.AppLogger.Info(fmt.Sprintf("计划任务开始执行: 任务ID=%s, 项目=%s", taskID, task.req.Project))
+	runCallbackTask(task.req, task.requestID, task.trigger)
+}
+
+// nextDeployTime 不能立即部署时计算排队到的时间：封网中返回零值（等待解除），否则为下一个部署窗口
+func nextDeployTime(project string, after time.Time) (time.Time, error) {
+	if common.GetFreezeState().Enabled {
+		return time.Time{}, nil
+	}
+	next, ok := common.NextDeployWindow(project, after)
+	if !ok {
+		return time.Time{}, fmt.Errorf("一年内没有可用的部署窗口")
+	}
+	return next, nil
+}
+
+// recordDeployAt 更新任务信息中的计划执行时间
+func recordDeployAt(taskID string, deployAt time.Time) {
+	meta, err := common.ReadTaskLogMeta(taskID)
+	if err != nil {
+		common.AppLogger.Warning("读取任务日志元信息失败:", err)
+		return
+	}
+	meta.DeployAt = formatDeployAt(deployAt)
+	common.WriteTaskLogMeta(*meta)
+}
+
+// releaseFrozenTasks 封网解除后，为等待中的任务安排执行时间（部署窗口内的立即执行）
+func releaseFrozenTasks() {
+	scheduledMu.Lock()
+	var frozen []*scheduledTask
+	for _, task := range scheduledTasks {
+		if task.deployAt.IsZero() {
+			frozen = append(frozen, task)
+		}
+	}
+	scheduledMu.Unlock()
+
+	for _, task := range frozen {
+		deployAt, err := nextDeployTime(task.req.Project, time.Now())
+		if err != nil || deployAt.IsZero() {
+			// 封网再次开启或没有部署窗口时，由执行时的检查处理
+			deployAt = time.Now()
+		}
+		recordDeployAt(task.req.TaskID, deployAt)
+		addScheduledTask(task.req, task.requestID, task.trigger, deployAt, task.reason)
+	}
+}
+
+// cancelScheduledTask 取消尚未开始的计划任务
+func cancelScheduledTask(taskID string) bool {
+	scheduledMu.Lock()
+	task, ok := scheduledTasks[taskID]
+	if ok {
+		if task.timer != nil {
+			task.timer.Stop()
+		}
+		delete(scheduledTasks, taskID)
+	}
+	scheduledMu.Unlock()
+	if !ok {
+		return false
+	}
+
+	common.FinishTaskLogMeta(taskID, "cancel")
+	common.AppLogger.Info(fmt.Sprintf("计划任务已取消: 任务ID=%s, 项目=%s", taskID, task.req.Project))
+	return true
+}
+
+// RestoreScheduledTasks 启动时恢复未执行的计划任务（已过执行时间的立即执行）
+func RestoreScheduledTasks() {
+	defer func() {
+		// 停机期间解除了封网时，等待中的任务重新安排
+		if !common.GetFreezeState().Enabled {
+			releaseFrozenTasks()
+		}
+	}()
+
+	for _, meta := range common.ListTaskLogMetas("") {
+		if meta.Status != "scheduled" {
+			continue
+		}
+		req, err := loadTaskRequest(meta.TaskID)
+		if err != nil {
+			common.AppLogger.Error(fmt.Sprintf("恢复计划任务失败: 任务ID=%s, 错误=%v", meta.TaskID, err))
+			continue
+		}
+		deployAt, err := parseDeployAt(meta.DeployAt)
+		if err != nil {
+			common.AppLogger.Error(fmt.Sprintf("恢复计划任务失败: 任务ID=%s, 错误=%v", meta.TaskID, err))
+			continue
+		}
+		addScheduledTask(*req, meta.RequestID, meta.Trigger, deployAt, "")
+	}
+}
+
+// listScheduledTasks 列出计划任务，按执行时间排序
+func listScheduledTasks() []TaskInfo {
+	scheduledMu.Lock()
+	pending := make([]*scheduledTask, 0, len(scheduledTasks))
+	for _, task := range scheduledTasks {
+		pending = append(pending, task)
+	}
+	scheduledMu.Unlock()
+
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].deployAt.Before(pending[j].deployAt)
+	})
+	tasks := make([]TaskInfo, 0, len(pending))
+	for _, task := range pending {
+		tasks = append(tasks, TaskInfo{
+			TaskID:   task.req.TaskID,
+			Project:  task.req.Project,
+			Tag:      task.req.Tag,
+			Type:     task.req.Type,
+			Status:   "scheduled",
+			DeployAt: formatDeployAt(task.deployAt),
+			Reason:   task.reason,
+		})
+	}
+	return tasks
+}
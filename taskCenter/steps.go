@@ -0,0 +1,54 @@
+package taskCenter
+
+import (
+	"cicd-agent/common"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleTaskSteps 查询任务的步骤时间线（各步骤的状态、开始和结束时间）
+// GET /api/v1/task/:id/steps
+func HandleTaskSteps(c *gin.Context) {
+	taskID := c.Param("id")
+	meta, err := common.ReadTaskLogMeta(taskID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Msg: fmt.Sprintf("未找到任务记录: %s", taskID)})
+		return
+	}
+	timeline, err := common.ReadStepTimeline(taskID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Msg: fmt.Sprintf("读取步骤时间线失败: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code: 200,
+		Msg:  "查询成功",
+		Data: gin.H{
+			"task":              meta,
+			"running":           common.IsTaskRunning(taskID),
+			"paused":            common.IsTaskPaused(taskID),
+			"awaiting_approval": common.IsAwaitingApproval(taskID),
+			"steps":             timeline,
+		},
+	})
+}
+
+// HandleTaskLogToken 为已通过认证的调用方（如管理界面）生成日志查看接口的加密参数
+// GET /api/v1/task/:id/log-token?step=console&format=json
+func HandleTaskLogToken(c *gin.Context) {
+	taskID := c.Param("id")
+	if _, err := common.ReadTaskLogMeta(taskID); err != nil {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Msg: fmt.Sprintf("未找到任务记录: %s", taskID)})
+		return
+	}
+	step := c.DefaultQuery("step", "console")
+	data, err := common.NewTaskLogParams(taskID, step, c.Query("format"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Msg: fmt.Sprintf("生成日志查看参数失败: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 200, Msg: "生成成功", Data: gin.H{"data": data}})
+}
@@ -0,0 +1,56 @@
+package taskCenter
+
+import (
+	"cicd-agent/common"
+	"cicd-agent/config"
+	"cicd-agent/taskStep/javaBuild"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleProjectSwitch 紧急情况下单独切换双版本项目的流量（v1/v2），不经过部署流水线
+// 切换作为独立任务执行，有自己的任务日志和通知，可通过取消接口中止
+// POST /api/v1/project/:project/switch {"version": "v1"}
+func HandleProjectSwitch(c *gin.Context) {
+	logger := common.RequestLogger(c)
+	project := c.Param("project")
+
+	var req SwitchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: fmt.Sprintf("请求参数错误: %v", err)})
+		return
+	}
+	if !config.AppConfig.IsValidProject(project) {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: fmt.Sprintf("项目 %s 不在有效项目列表中", project)})
+		return
+	}
+	if !common.HasVersionStructure(project) {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: fmt.Sprintf("项目 %s 不是双版本结构，无法切换流量", project)})
+		return
+	}
+	if running := runningUpstreamTasks([]string{project}, ""); len(running) > 0 {
+		c.JSON(http.StatusConflict, Response{Code: 409, Msg: fmt.Sprintf("项目有正在执行的任务: %s", strings.Join(running, ", "))})
+		return
+	}
+	if current, err := common.GetVersion(project); err == nil && current == req.Version {
+		c.JSON(http.StatusConflict, Response{Code: 409, Msg: fmt.Sprintf("项目流量已在版本 %s", req.Version)})
+		return
+	}
+
+	taskID := fmt.Sprintf("%s-switch-%s-%d", project, req.Version, time.Now().Unix())
+	logger.Warning(fmt.Sprintf("手动切换流量: 项目=%s, 目标版本=%s, 任务ID=%s, 操作人=%s", project, req.Version, taskID, taskOperator(c)))
+	go runProjectTask(project, req.Version, "手动切换流量", taskID, common.GetRequestID(c), "switch", func(ctx context.Context) error {
+		return javaBuild.SwitchTraffic(ctx, taskID, project, req.Version)
+	})
+
+	c.JSON(http.StatusOK, Response{
+		Code: 200,
+		Msg:  "已开始切换流量",
+		Data: gin.H{"task_id": taskID},
+	})
+}
@@ -0,0 +1,52 @@
+package taskCenter
+
+import (
+	"cicd-agent/common"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleTaskList 查询执行中（含暂停）、排队中和等待执行的计划任务
+// GET /api/v1/tasks?project=xxx
+func HandleTaskList(c *gin.Context) {
+	project := c.Query("project")
+
+	var tasks []TaskInfo
+	for _, task := range listScheduledTasks() {
+		if project == "" || task.Project == project {
+			tasks = append(tasks, task)
+		}
+	}
+
+	var running []TaskInfo
+	positions := queuedTaskPositions()
+	for _, taskID := range common.RunningTaskIDs() {
+		task := TaskInfo{TaskID: taskID, Status: "running"}
+		if position, ok := positions[taskID]; ok {
+			task.Status, task.Position = "queued", position
+		} else if common.IsTaskPaused(taskID) {
+			task.Status = "paused"
+		}
+		task.AwaitingApproval = common.IsAwaitingApproval(taskID)
+		if meta, err := common.ReadTaskLogMeta(taskID); err == nil {
+			task.Project, task.Tag, task.Type = meta.Project, meta.Tag, meta.Type
+			task.StartedAt, task.DeployAt = meta.StartedAt, meta.DeployAt
+		}
+		if project == "" || task.Project == project {
+			running = append(running, task)
+		}
+	}
+	sort.Slice(running, func(i, j int) bool {
+		return running[i].StartedAt < running[j].StartedAt
+	})
+
+	c.JSON(http.StatusOK, Response{
+		Code: 200,
+		Msg:  "查询成功",
+		Data: gin.H{
+			"tasks": append(running, tasks...),
+		},
+	})
+}
@@ -5,6 +5,8 @@ type UpdateRequest struct {
 	Project  string `json:"project" binding:"required"`
 	Type     string `json:"type"`
 	Category string `json:"category,omitempty"`
+	DeployAt string `json:"deploy_at,omitempty"` // 计划部署时间，随构建请求透传，构建完成的回调中原样带回
+	Priority string `json:"priority,omitempty"`  // 优先级（normal/high/hotfix），同样随构建请求透传
 }
 
 // CallbackRequest 回调请求结构
@@ -21,6 +23,10 @@ type CallbackRequest struct {
 	UpdateFeishuURL string                 `json:"update_feishu"` // ops -> update
 	NotifyFeishuURL string                 `json:"notify_feishu"` // pro -> notify
 	StepDurations   map[string]interface{} `json:"step_durations"`
+	DeployAt        string                 `json:"deploy_at,omitempty"` // 计划部署时间（RFC3339或2006-01-02 15:04:05），为空立即部署
+	BatchID         string                 `json:"batch_id,omitempty"`  // 批量部署发起的任务所属批次
+	Priority        string                 `json:"priority,omitempty"`  // normal（默认）/high（hotfix），高优先级任务优先获得执行名额
+	RetryOf         string                 `json:"retry_of,omitempty"`  // 重试发起的任务对应的原任务ID
 }
 
 // RemoteCallRequest 远程调用请求结构
@@ -29,6 +35,8 @@ type RemoteCallRequest struct {
 	CallbackURL string `json:"callback_url"`
 	Type        string `json:"type,omitempty"` // double/single/web
 	Category    string `json:"category,omitempty"`
+	DeployAt    string `json:"deploy_at,omitempty"`
+	Priority    string `json:"priority,omitempty"`
 }
 
 // CancelRequest 取消任务请求结构
@@ -36,6 +44,103 @@ type CancelRequest struct {
 	ID string `json:"id" binding:"required"`
 }
 
+// TaskInfo 任务列表项
+type TaskInfo struct {
+	TaskID    string `json:"task_id"`
+	Project   string `json:"project"`
+	Tag       string `json:"tag"`
+	Type      string `json:"type"`
+	Status    string `json:"status"` // scheduled/queued/running/paused
+	StartedAt string `json:"started_at,omitempty"`
+	DeployAt  string `json:"deploy_at,omitempty"` // 计划执行时间，等待封网解除时为空
+	Reason    string `json:"reason,omitempty"`    // 排队原因（部署窗口、封网）
+	Position  int    `json:"position,omitempty"`  // 等待执行名额的排队位置
+
+	AwaitingApproval bool `json:"awaiting_approval,omitempty"` // 正在等待流量切换审批
+}
+
+// ProjectInfo 项目清单项
+type ProjectInfo struct {
+	Project          string             `json:"project"`
+	Type             string             `json:"type"`                         // double/single/web，未配置部署目录的项目为unknown
+	Path             string             `json:"path,omitempty"`               // 部署目录（web项目为站点目录）
+	DeploymentPaths  []string           `json:"deployment_paths,omitempty"`   // 各版本的部署路径
+	CurrentVersion   string             `json:"current_version,omitempty"`    // 版本文件记录的当前版本（双版本项目）
+	TrafficProxyURLs []string           `json:"traffic_proxy_urls,omitempty"` // 流量代理地址，未开启流量代理时为空
+	LastDeployment   *DeploymentSummary `json:"last_deployment,omitempty"`
+}
+
+// DeploymentSummary 最近一次部署概要
+type DeploymentSummary struct {
+	TaskID     string `json:"task_id"`
+	Tag        string `json:"tag"`
+	Status     string `json:"status"` // complete/failed/cancel，执行中为running
+	Trigger    string `json:"trigger,omitempty"`
+	StartedAt  string `json:"started_at"`
+	FinishedAt string `json:"finished_at,omitempty"`
+}
+
+// ReleaseHistoryItem 项目发布历史中的一次发布
+type ReleaseHistoryItem struct {
+	TaskID      string          `json:"task_id"`
+	Tag         string          `json:"tag"`
+	Version     string          `json:"version,omitempty"`
+	Trigger     string          `json:"trigger,omitempty"`
+	DeployedAt  string          `json:"deployed_at"`
+	FinishedAt  string          `json:"finished_at,omitempty"`
+	PreviousTag string          `json:"previous_tag,omitempty"`
+	Changes     *ReleaseChanges `json:"changes,omitempty"` // 相对上一次发布的变化，最早的一次发布为空
+}
+
+// ReleaseChanges 相邻两次发布之间的变化
+type ReleaseChanges struct {
+	ImagesAdded     []string `json:"images_added,omitempty"`
+	ImagesRemoved   []string `json:"images_removed,omitempty"`
+	ImagesChanged   []string `json:"images_changed,omitempty"`   // 镜像摘要变化（或无法获取摘要）的服务
+	ImagesUnchanged []string `json:"images_unchanged,omitempty"` // 标签变化但镜像摘要相同的服务
+	FilesAdded      []string `json:"files_added,omitempty"`
+	FilesRemoved    []string `json:"files_removed,omitempty"`
+	FilesChanged    []string `json:"files_changed,omitempty"` // 除镜像标签外内容有变化的部署文件
+}
+
+// BatchItem 批量部署中的一个项目
+type BatchItem struct {
+	Project     string `json:"project" binding:"required"`
+	Tag         string `json:"tag" binding:"required"`
+	Type        string `json:"type"` // double/single/web，为空时按配置判断
+	Category    string `json:"category"`
+	ProjectName string `json:"project_name"`
+}
+
+// BatchRequest 批量部署请求
+type BatchRequest struct {
+	BatchID         string      `json:"batch_id"`
+	Items           []BatchItem `json:"items" binding:"required,min=1,dive"`
+	UpdateFeishuURL string      `json:"update_feishu"` // 批次汇总通知发送到该地址
+	NotifyFeishuURL string      `json:"notify_feishu"`
+}
+
+// FreezeRequest 开启/解除封网请求
+type FreezeRequest struct {
+	Enable bool   `json:"enable"`
+	Reason string `json:"reason"`
+}
+
+// SwitchRequest 手动切换流量请求
+type SwitchRequest struct {
+	Version string `json:"version" binding:"required,oneof=v1 v2"`
+}
+
+// CleanupRequest 手动清理旧版本请求
+type CleanupRequest struct {
+	Wait string `json:"wait"` // 清理前的等待时长（如 30s），默认立即清理
+}
+
+// HealthCheckRequest 手动健康检查请求
+type HealthCheckRequest struct {
+	Namespace string `json:"namespace"` // 检查的namespace，默认当前承载流量的版本
+}
+
 // EncryptedRequest 加密请求结构
 type EncryptedRequest struct {
 	Data string `json:"data" binding:"required"`
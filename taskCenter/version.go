@@ -0,0 +1,48 @@
+package taskCenter
+
+import (
+	"cicd-agent/common"
+	"cicd-agent/config"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleProjectVersion 查询项目版本文件（.current）记录的当前版本、更新时间、步骤耗时和流量切换记录
+// GET /api/v1/project/:project/version
+func HandleProjectVersion(c *gin.Context) {
+	project := c.Param("project")
+
+	if _, ok := config.AppConfig.GetProjectPath(project); !ok {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Msg: fmt.Sprintf("项目 %s 的部署目录未配置", project)})
+		return
+	}
+	info, err := common.ReadVersionInfo(project)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Code: 500, Msg: err.Error()})
+		return
+	}
+	if info == nil {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Msg: fmt.Sprintf("项目 %s 尚未生成版本文件", project)})
+		return
+	}
+
+	deployType := "single"
+	if common.HasVersionStructure(project) {
+		deployType = "double"
+	}
+	c.JSON(http.StatusOK, Response{
+		Code: 200,
+		Msg:  "查询成功",
+		Data: gin.H{
+			"project":          project,
+			"type":             deployType,
+			"current_version":  info.CurrentVersion,
+			"last_updated":     info.LastUpdated,
+			"step_durations":   info.StepDurations,
+			"previous_version": info.PreviousVersion,
+			"history":          info.History,
+		},
+	})
+}
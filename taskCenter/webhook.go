@@ -0,0 +1,31 @@
+package taskCenter
+
+import (
+	"cicd-agent/config"
+	"fmt"
+)
+
+// webhookDeployType 校验Webhook映射到的项目并确定部署类型：web/double/single
+func webhookDeployType(project string) (string, error) {
+	if !config.AppConfig.IsValidProject(project) {
+		return "", fmt.Errorf("项目 %s 不在有效项目列表中", project)
+	}
+	if config.AppConfig.IsWebProject(project) {
+		return "web", nil
+	}
+	if _, exists := config.AppConfig.GetProjectPath(project); !exists {
+		return "", fmt.Errorf("项目 %s 未配置部署目录", project)
+	}
+	if config.AppConfig.IsDoubleProject(project) {
+		return "double", nil
+	}
+	return "single", nil
+}
+
+// shortSHA 提交的短SHA（前8位），作为分支构建的镜像标签
+func shortSHA(sha string) string {
+	if len(sha) > 8 {
+		return sha[:8]
+	}
+	return sha
+}
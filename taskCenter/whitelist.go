@@ -0,0 +1,103 @@
+package taskCenter
+
+import (
+	"cicd-agent/common"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	tempWhitelistDefaultTTL = time.Hour
+	tempWhitelistMaxTTL     = 7 * 24 * time.Hour
+)
+
+// TempWhitelistRequest 临时白名单请求
+type TempWhitelistRequest struct {
+	List  string `json:"list"`                     // 白名单名称，默认default
+	Entry string `json:"entry" binding:"required"` // IP、CIDR或域名
+	TTL   string `json:"ttl"`                      // 有效期，如 30m、2h，默认1h，最长7天
+}
+
+// HandleWhitelistView 查看当前生效的白名单（含临时条目）
+func HandleWhitelistView(c *gin.Context) {
+	whitelist := common.GetWhitelist()
+	if whitelist == nil {
+		c.JSON(http.StatusServiceUnavailable, Response{Code: 503, Msg: "IP白名单未初始化"})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 200, Msg: "查询成功", Data: whitelist.Snapshot()})
+}
+
+// HandleWhitelistAddTemp 添加临时白名单条目
+func HandleWhitelistAddTemp(c *gin.Context) {
+	whitelist := common.GetWhitelist()
+	if whitelist == nil {
+		c.JSON(http.StatusServiceUnavailable, Response{Code: 503, Msg: "IP白名单未初始化"})
+		return
+	}
+
+	var req TempWhitelistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: fmt.Sprintf("请求参数错误: %v", err)})
+		return
+	}
+
+	ttl := tempWhitelistDefaultTTL
+	if req.TTL != "" {
+		parsed, err := time.ParseDuration(req.TTL)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: "无效的ttl参数: " + req.TTL})
+			return
+		}
+		ttl = parsed
+	}
+	if ttl > tempWhitelistMaxTTL {
+		ttl = tempWhitelistMaxTTL
+	}
+
+	createdBy := common.GetAuthSubject(c)
+	if createdBy == "" {
+		createdBy = common.GetClientIP(c)
+	}
+	entry, err := whitelist.AddTempEntry(req.List, req.Entry, ttl, createdBy)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 200, Msg: "临时白名单已添加", Data: entry})
+}
+
+// HandleWhitelistRemoveTemp 移除临时白名单条目
+// DELETE /api/v1/whitelist/temp?list=callback&entry=10.0.0.1
+func HandleWhitelistRemoveTemp(c *gin.Context) {
+	whitelist := common.GetWhitelist()
+	if whitelist == nil {
+		c.JSON(http.StatusServiceUnavailable, Response{Code: 503, Msg: "IP白名单未初始化"})
+		return
+	}
+
+	entry := c.Query("entry")
+	if entry == "" {
+		c.JSON(http.StatusBadRequest, Response{Code: 400, Msg: "缺少entry参数"})
+		return
+	}
+	if !whitelist.RemoveTempEntry(c.Query("list"), entry) {
+		c.JSON(http.StatusNotFound, Response{Code: 404, Msg: "未找到对应的临时白名单条目"})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 200, Msg: "临时白名单已移除"})
+}
+
+// HandleWhitelistRefresh 立即重新解析白名单域名
+func HandleWhitelistRefresh(c *gin.Context) {
+	whitelist := common.GetWhitelist()
+	if whitelist == nil {
+		c.JSON(http.StatusServiceUnavailable, Response{Code: 503, Msg: "IP白名单未初始化"})
+		return
+	}
+	whitelist.Refresh()
+	c.JSON(http.StatusOK, Response{Code: 200, Msg: "白名单已刷新", Data: whitelist.Snapshot()})
+}
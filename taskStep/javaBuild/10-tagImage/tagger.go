@@ -3,7 +3,6 @@ package tagImage
 import (
 	"context"
 	"fmt"
-	"os/exec"
 	"sync"
 
 	"cicd-agent/common"
@@ -61,8 +60,8 @@ func tagSingleImage(ctx context.Context, onlineImage, localImage string, taskLog
 		taskLogger.WriteStep("tagImages", "INFO", fmt.Sprintf("标记镜像: %s -> %s", onlineImage, localImage))
 	}
 
-	cmd := exec.CommandContext(ctx, "docker", "tag", onlineImage, localImage)
-	output, err := cmd.CombinedOutput()
+	cmd := common.NewCommand("docker", "tag", onlineImage, localImage)
+	output, err := common.RunCommand(ctx, cmd)
 
 	// 写入命令执行日志
 	if taskLogger != nil {
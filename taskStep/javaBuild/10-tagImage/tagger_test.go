@@ -0,0 +1,92 @@
+package tagImage
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"cicd-agent/common"
+)
+
+func TestTagImages(t *testing.T) {
+	tests := []struct {
+		name      string
+		online    []string
+		local     []string
+		failOn    string
+		cancelled bool
+		wantErr   string
+		wantCalls []string
+	}{
+		{
+			name:   "逐个标记",
+			online: []string{"online/app-a:v1", "online/app-b:v1"},
+			local:  []string{"local/app-a:v1", "local/app-b:v1"},
+			wantCalls: []string{
+				"docker tag online/app-a:v1 local/app-a:v1",
+				"docker tag online/app-b:v1 local/app-b:v1",
+			},
+		},
+		{
+			name:    "数量不匹配不执行命令",
+			online:  []string{"online/app-a:v1"},
+			local:   []string{"local/app-a:v1", "local/app-b:v1"},
+			wantErr: "在线镜像和本地镜像数量不匹配",
+		},
+		{
+			name:    "单个失败返回错误",
+			online:  []string{"online/app-a:v1", "online/app-b:v1"},
+			local:   []string{"local/app-a:v1", "local/app-b:v1"},
+			failOn:  "docker tag online/app-b:v1",
+			wantErr: "docker tag命令执行失败",
+			wantCalls: []string{
+				"docker tag online/app-a:v1 local/app-a:v1",
+				"docker tag online/app-b:v1 local/app-b:v1",
+			},
+		},
+		{
+			name:      "已取消不执行命令",
+			online:    []string{"online/app-a:v1"},
+			local:     []string{"local/app-a:v1"},
+			cancelled: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := common.NewFakeRunner()
+			if tt.failOn != "" {
+				fake.On(tt.failOn, "Error: No such image", errors.New("exit status 1"))
+			}
+			previous := common.SetCommandRunner(fake)
+			defer common.SetCommandRunner(previous)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancelled {
+				cancel()
+			}
+
+			err := TagImages(ctx, tt.online, tt.local, "task-1", nil)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("TagImages: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+
+			// 并发标记，调用顺序不固定
+			var calls []string
+			for _, call := range fake.Calls() {
+				calls = append(calls, call.String())
+			}
+			sort.Strings(calls)
+			if !reflect.DeepEqual(calls, tt.wantCalls) {
+				t.Errorf("calls = %q, want %q", calls, tt.wantCalls)
+			}
+		})
+	}
+}
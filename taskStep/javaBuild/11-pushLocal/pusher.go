@@ -3,16 +3,17 @@ package pushLocal
 import (
 	"context"
 	"fmt"
-	"os/exec"
 	"sync"
 
 	"cicd-agent/common"
+	"cicd-agent/config"
 )
 
 // ImagePusher 镜像推送器
 type ImagePusher struct {
-	taskID     string
-	taskLogger *common.TaskLogger
+	taskID         string
+	taskLogger     *common.TaskLogger
+	maxConcurrency int // 项目配置的并发上限，为0时使用全局配置
 }
 
 // NewImagePusher 创建镜像推送器
@@ -23,6 +24,11 @@ func NewImagePusher(taskID string, taskLogger *common.TaskLogger) *ImagePusher {
 	}
 }
 
+// SetMaxConcurrency 设置项目的并发上限（覆盖全局配置）
+func (p *ImagePusher) SetMaxConcurrency(n int) {
+	p.maxConcurrency = n
+}
+
 // PushImages 并发推送镜像（可取消）
 func (p *ImagePusher) PushImages(ctx context.Context, images []string) error {
 	if len(images) == 0 {
@@ -34,6 +40,9 @@ func (p *ImagePusher) PushImages(ctx context.Context, images []string) error {
 		p.taskLogger.WriteStep("pushLocal", "INFO", fmt.Sprintf("推送镜像: 总数=%d, 并发数=%d", len(images), maxConcurrency))
 	}
 
+	// 按配置限制镜像传输带宽
+	defer common.AcquireBandwidthLimit(ctx, p.taskLogger, "pushLocal")()
+
 	semaphore := make(chan struct{}, maxConcurrency)
 	var wg sync.WaitGroup
 	errChan := make(chan error, len(images))
@@ -86,13 +95,15 @@ func (p *ImagePusher) pushSingleImage(ctx context.Context, image string) error {
 		p.taskLogger.WriteStep("pushLocal", "INFO", fmt.Sprintf("开始推送镜像: %s", image))
 	}
 
-	cmd := exec.CommandContext(ctx, "docker", "push", image)
-	output, err := cmd.CombinedOutput()
-
-	// 写入命令执行日志
-	if p.taskLogger != nil {
-		p.taskLogger.WriteCommand("pushLocal", "docker push "+image, output, err)
+	// 所有任务共享全局并发名额
+	release, err := common.AcquireOperation(ctx, common.OperationPush)
+	if err != nil {
+		return fmt.Errorf("等待推送镜像 %s 的执行名额被取消", image)
 	}
+	defer release()
+
+	// 推送进度实时写入任务日志
+	err = p.taskLogger.StreamCommand(ctx, "pushLocal", image, common.NewCommand("docker", "push", image))
 
 	if err != nil {
 		// 检查是否是上下文取消导致的错误
@@ -110,8 +121,11 @@ func (p *ImagePusher) pushSingleImage(ctx context.Context, image string) error {
 
 // calculatePushConcurrency 计算推送并发数
 func (p *ImagePusher) calculatePushConcurrency(imageCount int) int {
-	// 直接根据服务数量设置线程数，最大不超过20个线程
-	const maxConcurrency = 20
+	// 直接根据服务数量设置线程数，最大不超过配置的并发数（默认20）
+	maxConcurrency := config.AppConfig.GetMaxParallelPushes()
+	if p.maxConcurrency > 0 {
+		maxConcurrency = p.maxConcurrency
+	}
 	const minConcurrency = 1
 
 	// 如果服务数量小于等于最大并发数，使用服务数量作为并发数
@@ -0,0 +1,137 @@
+package apolloRelease
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"cicd-agent/common"
+	"cicd-agent/config"
+)
+
+// client Apollo开放平台（OpenAPI）客户端
+type client struct {
+	portalURL string
+	token     string
+	operator  string
+}
+
+// Namespace 命名空间坐标
+type Namespace struct {
+	Env     string `json:"env"`
+	AppID   string `json:"app_id"`
+	Cluster string `json:"cluster"`
+	Name    string `json:"name"`
+}
+
+// apolloItem Apollo配置项
+type apolloItem struct {
+	Key                      string `json:"key"`
+	Value                    string `json:"value"`
+	Comment                  string `json:"comment,omitempty"`
+	DataChangeCreatedBy      string `json:"dataChangeCreatedBy,omitempty"`
+	DataChangeLastModifiedBy string `json:"dataChangeLastModifiedBy,omitempty"`
+}
+
+// newClient 按配置创建客户端
+func newClient() *client {
+	return &client{
+		portalURL: strings.TrimSuffix(config.AppConfig.Apollo.PortalURL, "/"),
+		token:     config.AppConfig.Apollo.Token,
+		operator:  config.AppConfig.GetApolloOperator(),
+	}
+}
+
+// getItem 读取配置项，不存在时返回false
+func (c *client) getItem(ctx context.Context, namespace Namespace, key string) (string, bool, error) {
+	resp, err := c.do(ctx, http.MethodGet, c.namespaceURL(namespace)+"/items/"+url.PathEscape(key), nil)
+	if err != nil {
+		return "", false, fmt.Errorf("读取配置项 %s 失败: %v", key, err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return "", false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", false, fmt.Errorf("读取配置项 %s 失败: 状态码 %d: %s", key, resp.StatusCode, string(resp.Body))
+	}
+	var item apolloItem
+	if err := json.Unmarshal(resp.Body, &item); err != nil {
+		return "", false, fmt.Errorf("解析配置项 %s 失败: %v", key, err)
+	}
+	return item.Value, true, nil
+}
+
+// setItem 修改配置项，不存在时创建
+func (c *client) setItem(ctx context.Context, namespace Namespace, key, value, comment string) error {
+	body, _ := json.Marshal(apolloItem{
+		Key:                      key,
+		Value:                    value,
+		Comment:                  comment,
+		DataChangeCreatedBy:      c.operator,
+		DataChangeLastModifiedBy: c.operator,
+	})
+	resp, err := c.do(ctx, http.MethodPut, c.namespaceURL(namespace)+"/items/"+url.PathEscape(key)+"?createIfNotExists=true", body)
+	if err != nil {
+		return fmt.Errorf("修改配置项 %s 失败: %v", key, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("修改配置项 %s 失败: 状态码 %d: %s", key, resp.StatusCode, string(resp.Body))
+	}
+	return nil
+}
+
+// deleteItem 删除配置项
+func (c *client) deleteItem(ctx context.Context, namespace Namespace, key string) error {
+	resp, err := c.do(ctx, http.MethodDelete, c.namespaceURL(namespace)+"/items/"+url.PathEscape(key)+"?operator="+url.QueryEscape(c.operator), nil)
+	if err != nil {
+		return fmt.Errorf("删除配置项 %s 失败: %v", key, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("删除配置项 %s 失败: 状态码 %d: %s", key, resp.StatusCode, string(resp.Body))
+	}
+	return nil
+}
+
+// release 发布命名空间，返回发布ID
+func (c *client) release(ctx context.Context, namespace Namespace, title, comment string) (int64, error) {
+	body, _ := json.Marshal(map[string]string{
+		"releaseTitle":   title,
+		"releaseComment": comment,
+		"releasedBy":     c.operator,
+	})
+	resp, err := c.do(ctx, http.MethodPost, c.namespaceURL(namespace)+"/releases", body)
+	if err != nil {
+		return 0, fmt.Errorf("发布命名空间 %s 失败: %v", namespace.Name, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("发布命名空间 %s 失败: 状态码 %d: %s", namespace.Name, resp.StatusCode, string(resp.Body))
+	}
+	var release struct {
+		ID int64 `json:"id"`
+	}
+	json.Unmarshal(resp.Body, &release)
+	return release.ID, nil
+}
+
+// namespaceURL 命名空间的OpenAPI地址
+func (c *client) namespaceURL(namespace Namespace) string {
+	return fmt.Sprintf("%s/openapi/v1/envs/%s/apps/%s/clusters/%s/namespaces/%s", c.portalURL,
+		url.PathEscape(namespace.Env), url.PathEscape(namespace.AppID), url.PathEscape(namespace.Cluster), url.PathEscape(namespace.Name))
+}
+
+// do 发送OpenAPI请求（修改和发布不幂等，不重试）
+func (c *client) do(ctx context.Context, method, requestURL string, body []byte) (*common.HTTPResponse, error) {
+	return common.DoHTTP(ctx, common.HTTPRequest{
+		Method: method,
+		URL:    requestURL,
+		Body:   body,
+		Header: map[string]string{
+			"Authorization": c.token,
+			"Content-Type":  "application/json;charset=UTF-8",
+		},
+		NoRetry: method == http.MethodPost,
+	})
+}
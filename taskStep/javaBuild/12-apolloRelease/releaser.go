@@ -0,0 +1,163 @@
+package apolloRelease
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"cicd-agent/common"
+	"cicd-agent/config"
+)
+
+// Backup 本次任务修改并发布的命名空间及配置项的原值，任务失败或取消时据此回滚
+type Backup struct {
+	Namespaces []NamespaceBackup `json:"namespaces"`
+}
+
+// NamespaceBackup 一个命名空间中被修改配置项的原值
+type NamespaceBackup struct {
+	Namespace Namespace    `json:"namespace"`
+	Items     []ItemBackup `json:"items"`
+	Released  bool         `json:"released"` // 是否已发布，未发布的修改回滚时同样恢复
+}
+
+// ItemBackup 配置项的原值
+type ItemBackup struct {
+	Key     string `json:"key"`
+	Existed bool   `json:"existed"` // 修改前是否存在，不存在时回滚为删除
+	Value   string `json:"value"`
+}
+
+// ConfigReleaser Apollo配置发布器
+type ConfigReleaser struct {
+	taskID     string
+	taskLogger *common.TaskLogger
+}
+
+// NewConfigReleaser 创建Apollo配置发布器
+func NewConfigReleaser(taskID string, taskLogger *common.TaskLogger) *ConfigReleaser {
+	return &ConfigReleaser{
+		taskID:     taskID,
+		taskLogger: taskLogger,
+	}
+}
+
+// Release 按顺序修改各命名空间的配置项并发布（没有变化的命名空间不发布），返回修改前的原值
+// 中途失败时同样返回已修改部分的备份，由调用方回滚
+func (r *ConfigReleaser) Release(ctx context.Context, project, tag string) (*Backup, error) {
+	projectConfig, ok := config.AppConfig.GetApolloProject(project)
+	if !ok {
+		return nil, fmt.Errorf("项目 %s 未配置Apollo发布", project)
+	}
+	apolloClient := newClient()
+
+	backup := &Backup{}
+	for _, namespaceConfig := range projectConfig.Namespaces {
+		namespace := Namespace{Env: projectConfig.Env, AppID: projectConfig.AppID, Cluster: projectConfig.Cluster, Name: namespaceConfig.Name}
+		keys := make([]string, 0, len(namespaceConfig.Items))
+		for key := range namespaceConfig.Items {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		namespaceBackup := NamespaceBackup{Namespace: namespace}
+		for _, key := range keys {
+			value := strings.ReplaceAll(namespaceConfig.Items[key], "{tag}", tag)
+			current, existed, err := apolloClient.getItem(ctx, namespace, key)
+			if err != nil {
+				return appendBackup(backup, namespaceBackup), err
+			}
+			if existed && current == value {
+				continue
+			}
+			if err := apolloClient.setItem(ctx, namespace, key, value, fmt.Sprintf("task %s", r.taskID)); err != nil {
+				return appendBackup(backup, namespaceBackup), err
+			}
+			namespaceBackup.Items = append(namespaceBackup.Items, ItemBackup{Key: key, Existed: existed, Value: current})
+			if existed {
+				r.log("INFO", fmt.Sprintf("%s/%s: %s %q -> %q", namespace.AppID, namespace.Name, key, current, value))
+			} else {
+				r.log("INFO", fmt.Sprintf("%s/%s: 新增 %s = %q", namespace.AppID, namespace.Name, key, value))
+			}
+		}
+		if len(namespaceBackup.Items) == 0 {
+			r.log("INFO", fmt.Sprintf("%s/%s 配置没有变化，跳过发布", namespace.AppID, namespace.Name))
+			continue
+		}
+
+		releaseID, err := apolloClient.release(ctx, namespace, releaseTitle(tag), fmt.Sprintf("cicd-agent task %s", r.taskID))
+		if err != nil {
+			return appendBackup(backup, namespaceBackup), err
+		}
+		namespaceBackup.Released = true
+		backup.Namespaces = append(backup.Namespaces, namespaceBackup)
+		r.log("INFO", fmt.Sprintf("已发布 %s/%s（%s/%s），发布ID=%d", namespace.AppID, namespace.Name, namespace.Env, namespace.Cluster, releaseID))
+	}
+	return backup, nil
+}
+
+// Rollback 按逆序恢复配置项的原值（原来不存在的删除）并重新发布；单个命名空间失败时继续回滚其他命名空间
+func (r *ConfigReleaser) Rollback(ctx context.Context, backup *Backup) error {
+	if backup == nil || len(backup.Namespaces) == 0 {
+		return nil
+	}
+	apolloClient := newClient()
+
+	var failed []string
+	for i := len(backup.Namespaces) - 1; i >= 0; i-- {
+		namespaceBackup := backup.Namespaces[i]
+		namespace := namespaceBackup.Namespace
+		if err := r.restoreNamespace(ctx, apolloClient, namespaceBackup); err != nil {
+			r.log("ERROR", fmt.Sprintf("回滚 %s/%s 失败: %v", namespace.AppID, namespace.Name, err))
+			failed = append(failed, namespace.Name)
+			continue
+		}
+		r.log("INFO", fmt.Sprintf("已回滚 %s/%s", namespace.AppID, namespace.Name))
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d 个命名空间回滚失败: %s", len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// restoreNamespace 恢复一个命名空间的配置项，修改已发布时重新发布
+func (r *ConfigReleaser) restoreNamespace(ctx context.Context, apolloClient *client, namespaceBackup NamespaceBackup) error {
+	namespace := namespaceBackup.Namespace
+	for _, item := range namespaceBackup.Items {
+		var err error
+		if item.Existed {
+			err = apolloClient.setItem(ctx, namespace, item.Key, item.Value, fmt.Sprintf("rollback task %s", r.taskID))
+		} else {
+			err = apolloClient.deleteItem(ctx, namespace, item.Key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if !namespaceBackup.Released {
+		return nil
+	}
+	_, err := apolloClient.release(ctx, namespace, releaseTitle("rollback"), fmt.Sprintf("cicd-agent task %s 失败回滚", r.taskID))
+	return err
+}
+
+// appendBackup 将有修改的命名空间加入备份
+func appendBackup(backup *Backup, namespaceBackup NamespaceBackup) *Backup {
+	if len(namespaceBackup.Items) > 0 {
+		backup.Namespaces = append(backup.Namespaces, namespaceBackup)
+	}
+	return backup
+}
+
+// releaseTitle 发布标题（Apollo要求非空）
+func releaseTitle(label string) string {
+	return fmt.Sprintf("cicd-agent-%s", label)
+}
+
+// log 写入步骤日志
+func (r *ConfigReleaser) log(level, message string) {
+	if r.taskLogger != nil {
+		r.taskLogger.WriteStep("apolloRelease", level, message)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"cicd-agent/common"
 	"cicd-agent/config"
 	"context"
+	"encoding/base64"
 	"fmt"
 	"net/http"
 	"strings"
@@ -35,26 +36,19 @@ func (c *ImageChecker) CheckImageExistsInHarbor(ctx context.Context, projectName
 		c.taskLogger.WriteStep("checkImage", "INFO", fmt.Sprintf("检查Harbor镜像: %s/%s:%s", projectName, imageName, tag))
 	}
 
-	// 创建HTTP请求
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return false, fmt.Errorf("创建请求失败: %v", err)
-	}
-
 	// 设置基本认证
-	req.SetBasicAuth(config.AppConfig.Harbor.OfflineUser, config.AppConfig.Harbor.OfflinePassword)
+	auth := base64.StdEncoding.EncodeToString([]byte(config.AppConfig.Harbor.OfflineUser + ":" + config.AppConfig.Harbor.OfflinePassword))
 
-	// 创建HTTP客户端
-	client := &http.Client{
+	// 发送请求（任务取消时中止）
+	resp, err := common.DoHTTP(ctx, common.HTTPRequest{
+		Method:  http.MethodGet,
+		URL:     url,
+		Header:  map[string]string{"Authorization": "Basic " + auth},
 		Timeout: 30 * time.Second,
-	}
-
-	// 发送请求
-	resp, err := client.Do(req)
+	})
 	if err != nil {
 		return false, fmt.Errorf("请求Harbor失败: %v", err)
 	}
-	defer resp.Body.Close()
 
 	// 检查响应状态码
 	exists := resp.StatusCode == 200
@@ -203,7 +197,7 @@ func CheckImages(ctx context.Context, images []string, projectName string, tag s
 		if taskLogger != nil {
 			taskLogger.WriteStep("checkImage", "ERROR", errMsg)
 		}
-		return fmt.Errorf(errMsg)
+		return fmt.Errorf("%s", errMsg)
 	}
 
 	return nil
@@ -0,0 +1,239 @@
+package configSync
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"cicd-agent/common"
+	"cicd-agent/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+// templateData 清单模板可用的数据
+type templateData struct {
+	Project   string
+	Tag       string
+	Namespace string
+	Values    map[string]string
+}
+
+// manifestHeader 校验清单时读取的字段
+type manifestHeader struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Name      string `yaml:"name"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"metadata"`
+}
+
+// ConfigSyncer ConfigMap/Secret同步器
+type ConfigSyncer struct {
+	taskID     string
+	taskLogger *common.TaskLogger
+}
+
+// NewConfigSyncer 创建ConfigMap/Secret同步器
+func NewConfigSyncer(taskID string, taskLogger *common.TaskLogger) *ConfigSyncer {
+	return &ConfigSyncer{
+		taskID:     taskID,
+		taskLogger: taskLogger,
+	}
+}
+
+// Sync 渲染项目目录中的清单并应用到namespace（namespace不存在时创建），返回应用的资源数量
+// 清单只允许ConfigMap和Secret，渲染结果写入临时目录，不记录内容
+func (s *ConfigSyncer) Sync(ctx context.Context, project, tag, namespace, baseDir string) (int, error) {
+	projectConfig, ok := config.AppConfig.GetConfigSyncProject(project)
+	if !ok {
+		return 0, fmt.Errorf("项目 %s 未配置ConfigMap/Secret同步", project)
+	}
+	dir := projectConfig.Dir
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(baseDir, dir)
+	}
+
+	secrets, err := loadSecrets(projectConfig.SecretsFile)
+	if err != nil {
+		return 0, err
+	}
+	files, err := manifestFiles(dir)
+	if err != nil {
+		return 0, fmt.Errorf("读取清单目录 %s 失败: %v", dir, err)
+	}
+	if len(files) == 0 {
+		s.log("WARNING", fmt.Sprintf("清单目录 %s 中没有清单文件", dir))
+		return 0, nil
+	}
+
+	renderDir, err := os.MkdirTemp("", "config-sync-*")
+	if err != nil {
+		return 0, fmt.Errorf("创建临时目录失败: %v", err)
+	}
+	defer os.RemoveAll(renderDir)
+
+	data := templateData{Project: project, Tag: tag, Namespace: namespace, Values: projectConfig.Values}
+	var resources []string
+	for i, file := range files {
+		rendered, err := renderManifest(file, data, secrets)
+		if err != nil {
+			return 0, fmt.Errorf("渲染 %s 失败: %v", filepath.Base(file), err)
+		}
+		names, err := checkManifest(rendered, namespace)
+		if err != nil {
+			return 0, fmt.Errorf("清单 %s 不合法: %v", filepath.Base(file), err)
+		}
+		resources = append(resources, names...)
+		if err := os.WriteFile(filepath.Join(renderDir, fmt.Sprintf("%03d.yaml", i)), rendered, 0600); err != nil {
+			return 0, fmt.Errorf("写入渲染结果失败: %v", err)
+		}
+	}
+	s.log("INFO", fmt.Sprintf("已渲染 %d 个文件，共 %d 个资源: %s", len(files), len(resources), strings.Join(resources, ", ")))
+
+	if err := s.ensureNamespace(ctx, namespace); err != nil {
+		return 0, err
+	}
+
+	release, err := common.AcquireOperation(ctx, common.OperationApply)
+	if err != nil {
+		return 0, fmt.Errorf("等待kubectl apply执行名额被取消")
+	}
+	defer release()
+	if err := s.taskLogger.StreamCommand(ctx, "configSync", "", common.NewCommand("kubectl", "apply", "-n", namespace, "-f", renderDir)); err != nil {
+		return 0, fmt.Errorf("应用ConfigMap/Secret失败: %v", err)
+	}
+	return len(resources), nil
+}
+
+// ensureNamespace namespace不存在时创建（首次部署新版本时）
+func (s *ConfigSyncer) ensureNamespace(ctx context.Context, namespace string) error {
+	if _, err := common.RunCommand(ctx, common.NewCommand("kubectl", "get", "namespace", namespace)); err == nil {
+		return nil
+	}
+	cmd := common.NewCommand("kubectl", "create", "namespace", namespace)
+	output, err := common.RunCommand(ctx, cmd)
+	if s.taskLogger != nil {
+		s.taskLogger.WriteCommand("configSync", cmd.String(), output, err)
+	}
+	if err != nil {
+		if strings.Contains(string(output), "AlreadyExists") {
+			return nil
+		}
+		return fmt.Errorf("创建namespace %s 失败: %v", namespace, err)
+	}
+	s.log("INFO", fmt.Sprintf("已创建namespace %s", namespace))
+	return nil
+}
+
+// manifestFiles 按路径顺序列出目录中的清单文件（跳过隐藏目录）
+func manifestFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path != dir && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch filepath.Ext(entry.Name()) {
+		case ".yaml", ".yml", ".tmpl":
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// loadSecrets 读取敏感值文件（YAML键值对），未配置时返回空
+func loadSecrets(path string) (map[string]string, error) {
+	secrets := map[string]string{}
+	if path == "" {
+		return secrets, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取敏感值文件失败: %v", err)
+	}
+	if err := yaml.Unmarshal(data, &secrets); err != nil {
+		return nil, fmt.Errorf("解析敏感值文件失败: %v", err)
+	}
+	return secrets, nil
+}
+
+// renderManifest 按模板渲染清单文件
+func renderManifest(path string, data templateData, secrets map[string]string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	funcs := template.FuncMap{
+		"secret": func(key string) (string, error) {
+			value, ok := secrets[key]
+			if !ok {
+				return "", fmt.Errorf("敏感值 %s 不存在", key)
+			}
+			return value, nil
+		},
+		"env": os.Getenv,
+		"b64enc": func(value string) string {
+			return base64.StdEncoding.EncodeToString([]byte(value))
+		},
+	}
+	tmpl, err := template.New(filepath.Base(path)).Option("missingkey=error").Funcs(funcs).Parse(string(content))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// checkManifest 校验渲染结果中的每个文档都是ConfigMap或Secret，且未指定其他namespace，返回资源名称
+func checkManifest(content []byte, namespace string) ([]string, error) {
+	var names []string
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var header manifestHeader
+		err := decoder.Decode(&header)
+		if errors.Is(err, io.EOF) {
+			return names, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Kind == "" {
+			continue
+		}
+		if header.Kind != "ConfigMap" && header.Kind != "Secret" {
+			return nil, fmt.Errorf("只允许ConfigMap和Secret，发现 %s", header.Kind)
+		}
+		if header.Metadata.Name == "" {
+			return nil, fmt.Errorf("%s 未指定metadata.name", header.Kind)
+		}
+		if header.Metadata.Namespace != "" && header.Metadata.Namespace != namespace {
+			return nil, fmt.Errorf("%s/%s 指定了其他namespace %s", header.Kind, header.Metadata.Name, header.Metadata.Namespace)
+		}
+		names = append(names, header.Kind+"/"+header.Metadata.Name)
+	}
+}
+
+// log 写入步骤日志
+func (s *ConfigSyncer) log(level, message string) {
+	if s.taskLogger != nil {
+		s.taskLogger.WriteStep("configSync", level, message)
+	}
+}
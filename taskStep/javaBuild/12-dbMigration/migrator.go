@@ -0,0 +1,273 @@
+package dbMigration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"cicd-agent/common"
+	"cicd-agent/config"
+)
+
+// invalidNameChars Job名称中不允许的字符（DNS-1123标签）
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// jobStatus kubectl get job -o json 中用到的状态字段
+type jobStatus struct {
+	Status struct {
+		Active     int `json:"active"`
+		Succeeded  int `json:"succeeded"`
+		Failed     int `json:"failed"`
+		Conditions []struct {
+			Type    string `json:"type"`
+			Status  string `json:"status"`
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+		} `json:"conditions"`
+	} `json:"status"`
+}
+
+// Migrator 数据库迁移执行器
+type Migrator struct {
+	taskID     string
+	taskLogger *common.TaskLogger
+}
+
+// NewMigrator 创建数据库迁移执行器
+func NewMigrator(taskID string, taskLogger *common.TaskLogger) *Migrator {
+	return &Migrator{
+		taskID:     taskID,
+		taskLogger: taskLogger,
+	}
+}
+
+// Run 在namespace中创建迁移Job并等待完成，Job的日志写入任务日志；迁移失败、超时或被取消时返回错误
+// 被取消时删除Job，失败和超时的Job保留（ttl到期后由Kubernetes清理）便于排查
+func (m *Migrator) Run(ctx context.Context, project, tag, namespace string) error {
+	projectConfig, ok := config.AppConfig.GetMigrationProject(project)
+	if !ok {
+		return fmt.Errorf("项目 %s 未配置数据库迁移", project)
+	}
+	if projectConfig.Namespace != "" {
+		namespace = projectConfig.Namespace
+	}
+	timeout := config.AppConfig.GetMigrationTimeout(project)
+
+	jobName := migrationJobName(project)
+	manifest, err := json.MarshalIndent(m.buildJob(jobName, namespace, project, tag, projectConfig, timeout), "", "  ")
+	if err != nil {
+		return fmt.Errorf("生成迁移Job失败: %v", err)
+	}
+	if err := m.createJob(ctx, manifest); err != nil {
+		return err
+	}
+	m.log("INFO", fmt.Sprintf("已创建迁移Job %s/%s，镜像 %s，超时 %s", namespace, jobName, strings.ReplaceAll(projectConfig.Image, "{tag}", tag), timeout))
+
+	waitErr := m.waitJob(ctx, jobName, namespace, timeout)
+	if ctx.Err() == context.Canceled {
+		m.deleteJob(jobName, namespace)
+		return ctx.Err()
+	}
+	m.captureLogs(ctx, jobName, namespace)
+	if waitErr != nil {
+		return waitErr
+	}
+	m.log("INFO", fmt.Sprintf("迁移Job %s 执行成功", jobName))
+	return nil
+}
+
+// buildJob 生成迁移Job（Kubernetes接受JSON格式的清单）
+func (m *Migrator) buildJob(jobName, namespace, project, tag string, projectConfig config.MigrationProjectConfig, timeout time.Duration) map[string]interface{} {
+	render := func(value string) string {
+		return strings.ReplaceAll(value, "{tag}", tag)
+	}
+	renderAll := func(values []string) []string {
+		rendered := make([]string, len(values))
+		for i, value := range values {
+			rendered[i] = render(value)
+		}
+		return rendered
+	}
+
+	container := map[string]interface{}{
+		"name":  "migration",
+		"image": render(projectConfig.Image),
+	}
+	if len(projectConfig.Command) > 0 {
+		container["command"] = renderAll(projectConfig.Command)
+	}
+	if len(projectConfig.Args) > 0 {
+		container["args"] = renderAll(projectConfig.Args)
+	}
+	if len(projectConfig.Env) > 0 {
+		names := make([]string, 0, len(projectConfig.Env))
+		for name := range projectConfig.Env {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		env := make([]map[string]string, 0, len(names))
+		for _, name := range names {
+			env = append(env, map[string]string{"name": name, "value": render(projectConfig.Env[name])})
+		}
+		container["env"] = env
+	}
+	if len(projectConfig.EnvFromSecrets) > 0 {
+		envFrom := make([]map[string]interface{}, 0, len(projectConfig.EnvFromSecrets))
+		for _, secret := range projectConfig.EnvFromSecrets {
+			envFrom = append(envFrom, map[string]interface{}{"secretRef": map[string]string{"name": secret}})
+		}
+		container["envFrom"] = envFrom
+	}
+
+	podSpec := map[string]interface{}{
+		"restartPolicy": "Never",
+		"containers":    []interface{}{container},
+	}
+	if projectConfig.ServiceAccount != "" {
+		podSpec["serviceAccountName"] = projectConfig.ServiceAccount
+	}
+
+	return map[string]interface{}{
+		"apiVersion": "batch/v1",
+		"kind":       "Job",
+		"metadata": map[string]interface{}{
+			"name":      jobName,
+			"namespace": namespace,
+			"labels": map[string]string{
+				"app.kubernetes.io/managed-by": "cicd-agent",
+				"cicd-agent/migration":         project,
+			},
+			"annotations": map[string]string{
+				"cicd-agent/task-id": m.taskID,
+				"cicd-agent/tag":     tag,
+			},
+		},
+		"spec": map[string]interface{}{
+			"backoffLimit":            projectConfig.BackoffLimit,
+			"activeDeadlineSeconds":   int64(timeout.Seconds()),
+			"ttlSecondsAfterFinished": int64(config.AppConfig.GetMigrationTTL().Seconds()),
+			"template":                map[string]interface{}{"spec": podSpec},
+		},
+	}
+}
+
+// createJob 写入临时清单文件并执行kubectl create
+func (m *Migrator) createJob(ctx context.Context, manifest []byte) error {
+	file, err := os.CreateTemp("", "migration-job-*.json")
+	if err != nil {
+		return fmt.Errorf("创建迁移Job清单文件失败: %v", err)
+	}
+	defer os.Remove(file.Name())
+	_, err = file.Write(manifest)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("写入迁移Job清单文件失败: %v", err)
+	}
+
+	release, err := common.AcquireOperation(ctx, common.OperationApply)
+	if err != nil {
+		return fmt.Errorf("等待kubectl执行名额被取消")
+	}
+	defer release()
+
+	cmd := common.NewCommand("kubectl", "create", "-f", file.Name())
+	output, err := common.RunCommand(ctx, cmd)
+	if m.taskLogger != nil {
+		m.taskLogger.WriteCommand("dbMigration", cmd.String(), output, err)
+	}
+	if err != nil {
+		return fmt.Errorf("创建迁移Job失败: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// waitJob 轮询Job状态直到完成、失败或超时（超时比activeDeadlineSeconds多留1分钟，通常由Kubernetes先标记失败）
+func (m *Migrator) waitJob(ctx context.Context, jobName, namespace string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout + time.Minute)
+	ticker := time.NewTicker(config.AppConfig.GetMigrationPollInterval())
+	defer ticker.Stop()
+
+	lastState := ""
+	for {
+		output, err := common.RunCommand(ctx, common.NewCommand("kubectl", "get", "job", jobName, "-n", namespace, "-o", "json"))
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			m.log("WARNING", fmt.Sprintf("查询迁移Job状态失败: %v: %s", err, strings.TrimSpace(string(output))))
+		} else {
+			var job jobStatus
+			if err := json.Unmarshal(output, &job); err != nil {
+				return fmt.Errorf("解析迁移Job状态失败: %v", err)
+			}
+			for _, condition := range job.Status.Conditions {
+				if condition.Status != "True" {
+					continue
+				}
+				switch condition.Type {
+				case "Complete":
+					return nil
+				case "Failed":
+					return fmt.Errorf("迁移Job %s 执行失败: %s %s", jobName, condition.Reason, condition.Message)
+				}
+			}
+			state := fmt.Sprintf("运行中 %d，成功 %d，失败 %d", job.Status.Active, job.Status.Succeeded, job.Status.Failed)
+			if state != lastState {
+				m.log("INFO", fmt.Sprintf("迁移Job %s: %s", jobName, state))
+				lastState = state
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("等待迁移Job %s 完成超时(%s)", jobName, timeout)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// captureLogs 将Job所有pod的日志写入任务日志
+func (m *Migrator) captureLogs(ctx context.Context, jobName, namespace string) {
+	cmd := common.NewCommand("kubectl", "logs", "-n", namespace, "-l", "job-name="+jobName,
+		"--all-containers", "--prefix", "--tail=-1", "--ignore-errors")
+	if err := m.taskLogger.StreamCommand(ctx, "dbMigration", "", cmd); err != nil {
+		m.log("WARNING", fmt.Sprintf("获取迁移Job日志失败: %v", err))
+	}
+}
+
+// deleteJob 删除Job及其pod（任务可能已取消，使用独立的ctx）
+func (m *Migrator) deleteJob(jobName, namespace string) {
+	cmd := common.NewCommand("kubectl", "delete", "job", jobName, "-n", namespace, "--ignore-not-found", "--wait=false")
+	output, err := common.RunCommand(context.Background(), cmd)
+	if m.taskLogger != nil {
+		m.taskLogger.WriteCommand("dbMigration", cmd.String(), output, err)
+	}
+	if err != nil {
+		common.AppLogger.Error(fmt.Sprintf("删除迁移Job %s/%s 失败: %v", namespace, jobName, err))
+	}
+}
+
+// migrationJobName 生成Job名称：{项目}-migration-{时间戳}，不超过63个字符
+func migrationJobName(project string) string {
+	suffix := fmt.Sprintf("-migration-%d", time.Now().Unix())
+	name := strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(project), "-"), "-")
+	if len(name)+len(suffix) > 63 {
+		name = strings.TrimRight(name[:63-len(suffix)], "-")
+	}
+	return name + suffix
+}
+
+// log 写入步骤日志
+func (m *Migrator) log(level, message string) {
+	if m.taskLogger != nil {
+		m.taskLogger.WriteStep("dbMigration", level, message)
+	}
+}
@@ -0,0 +1,125 @@
+package nacosPublish
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"cicd-agent/common"
+	"cicd-agent/config"
+)
+
+// client Nacos Open API（v1）客户端
+type client struct {
+	baseURL     string
+	accessToken string
+}
+
+// ConfigKey 一项配置的坐标
+type ConfigKey struct {
+	DataID    string `json:"data_id"`
+	Group     string `json:"group"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// newClient 创建客户端，配置了用户名时先登录获取accessToken
+func newClient(ctx context.Context, nacosConfig config.NacosConfig) (*client, error) {
+	baseURL := strings.TrimSuffix(nacosConfig.Server, "/")
+	if parsed, err := url.Parse(baseURL); err == nil && (parsed.Path == "" || parsed.Path == "/") {
+		baseURL += "/nacos"
+	}
+	c := &client{baseURL: baseURL}
+	if nacosConfig.Username == "" {
+		return c, nil
+	}
+
+	form := url.Values{"username": {nacosConfig.Username}, "password": {nacosConfig.Password}}
+	resp, err := common.DoHTTP(ctx, common.HTTPRequest{
+		Method: http.MethodPost,
+		URL:    c.baseURL + "/v1/auth/login",
+		Body:   []byte(form.Encode()),
+		Header: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("登录Nacos失败: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("登录Nacos失败: 状态码 %d: %s", resp.StatusCode, string(resp.Body))
+	}
+	var login struct {
+		AccessToken string `json:"accessToken"`
+	}
+	if err := json.Unmarshal(resp.Body, &login); err != nil || login.AccessToken == "" {
+		return nil, fmt.Errorf("解析Nacos登录结果失败: %s", string(resp.Body))
+	}
+	c.accessToken = login.AccessToken
+	return c, nil
+}
+
+// get 读取配置内容，配置不存在时返回false
+func (c *client) get(ctx context.Context, key ConfigKey) (string, bool, error) {
+	resp, err := common.DoHTTP(ctx, common.HTTPRequest{
+		Method: http.MethodGet,
+		URL:    c.baseURL + "/v1/cs/configs?" + c.query(key).Encode(),
+	})
+	if err != nil {
+		return "", false, fmt.Errorf("读取配置 %s 失败: %v", key.DataID, err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return string(resp.Body), true, nil
+	case http.StatusNotFound:
+		return "", false, nil
+	}
+	return "", false, fmt.Errorf("读取配置 %s 失败: 状态码 %d: %s", key.DataID, resp.StatusCode, string(resp.Body))
+}
+
+// publish 发布配置
+func (c *client) publish(ctx context.Context, key ConfigKey, content, configType string) error {
+	form := c.query(key)
+	form.Set("content", content)
+	form.Set("type", configType)
+	resp, err := common.DoHTTP(ctx, common.HTTPRequest{
+		Method: http.MethodPost,
+		URL:    c.baseURL + "/v1/cs/configs",
+		Body:   []byte(form.Encode()),
+		Header: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+	})
+	if err != nil {
+		return fmt.Errorf("发布配置 %s 失败: %v", key.DataID, err)
+	}
+	if resp.StatusCode != http.StatusOK || strings.TrimSpace(string(resp.Body)) != "true" {
+		return fmt.Errorf("发布配置 %s 失败: 状态码 %d: %s", key.DataID, resp.StatusCode, string(resp.Body))
+	}
+	return nil
+}
+
+// remove 删除配置
+func (c *client) remove(ctx context.Context, key ConfigKey) error {
+	resp, err := common.DoHTTP(ctx, common.HTTPRequest{
+		Method: http.MethodDelete,
+		URL:    c.baseURL + "/v1/cs/configs?" + c.query(key).Encode(),
+	})
+	if err != nil {
+		return fmt.Errorf("删除配置 %s 失败: %v", key.DataID, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("删除配置 %s 失败: 状态码 %d: %s", key.DataID, resp.StatusCode, string(resp.Body))
+	}
+	return nil
+}
+
+// query 配置坐标和accessToken参数
+func (c *client) query(key ConfigKey) url.Values {
+	values := url.Values{"dataId": {key.DataID}, "group": {key.Group}}
+	if key.Namespace != "" {
+		values.Set("tenant", key.Namespace)
+	}
+	if c.accessToken != "" {
+		values.Set("accessToken", c.accessToken)
+	}
+	return values
+}
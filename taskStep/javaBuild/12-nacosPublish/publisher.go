@@ -0,0 +1,220 @@
+package nacosPublish
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"cicd-agent/common"
+	"cicd-agent/config"
+)
+
+// maxDiffLines 参与逐行对比的最大行数，超过时只记录行数变化
+const maxDiffLines = 2000
+
+// Backup 发布前的配置内容，任务失败或取消时据此回滚
+type Backup struct {
+	Items []BackupItem `json:"items"`
+}
+
+// BackupItem 一项已发布配置的原内容
+type BackupItem struct {
+	Key     ConfigKey `json:"key"`
+	Existed bool      `json:"existed"` // 发布前配置是否存在，不存在时回滚为删除
+	Content string    `json:"content"`
+	Type    string    `json:"type"`
+}
+
+// ConfigPublisher Nacos配置发布器
+type ConfigPublisher struct {
+	taskID     string
+	taskLogger *common.TaskLogger
+}
+
+// NewConfigPublisher 创建Nacos配置发布器
+func NewConfigPublisher(taskID string, taskLogger *common.TaskLogger) *ConfigPublisher {
+	return &ConfigPublisher{
+		taskID:     taskID,
+		taskLogger: taskLogger,
+	}
+}
+
+// Publish 按顺序发布项目配置的各项内容（记录与当前内容的差异，没有变化的跳过），返回已发布项的原内容
+// 中途失败时同样返回已发布部分的备份，由调用方回滚
+func (p *ConfigPublisher) Publish(ctx context.Context, project, tag, baseDir string) (*Backup, error) {
+	projectConfig, ok := config.AppConfig.GetNacosProject(project)
+	if !ok {
+		return nil, fmt.Errorf("项目 %s 未配置Nacos发布", project)
+	}
+	nacosClient, err := newClient(ctx, config.AppConfig.Nacos)
+	if err != nil {
+		return nil, err
+	}
+
+	backup := &Backup{}
+	for _, item := range projectConfig.Items {
+		key := ConfigKey{DataID: item.DataID, Group: item.Group, Namespace: projectConfig.Namespace}
+		current, existed, err := nacosClient.get(ctx, key)
+		if err != nil {
+			return backup, err
+		}
+		content, err := renderContent(item, current, tag, baseDir)
+		if err != nil {
+			return backup, err
+		}
+		if existed && content == current {
+			p.log("INFO", fmt.Sprintf("配置 %s（%s）没有变化，跳过发布", item.DataID, item.Group))
+			continue
+		}
+
+		p.log("INFO", fmt.Sprintf("配置 %s（%s）变更:\n%s", item.DataID, item.Group, strings.Join(lineDiff(current, content), "\n")))
+		if err := nacosClient.publish(ctx, key, content, item.Type); err != nil {
+			return backup, err
+		}
+		backup.Items = append(backup.Items, BackupItem{Key: key, Existed: existed, Content: current, Type: item.Type})
+		p.log("INFO", fmt.Sprintf("已发布配置 %s（%s）", item.DataID, item.Group))
+	}
+	return backup, nil
+}
+
+// Rollback 按发布的逆序恢复配置的原内容，发布前不存在的配置删除；单项失败时继续回滚其他项
+func (p *ConfigPublisher) Rollback(ctx context.Context, backup *Backup) error {
+	if backup == nil || len(backup.Items) == 0 {
+		return nil
+	}
+	nacosClient, err := newClient(ctx, config.AppConfig.Nacos)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for i := len(backup.Items) - 1; i >= 0; i-- {
+		item := backup.Items[i]
+		if item.Existed {
+			err = nacosClient.publish(ctx, item.Key, item.Content, item.Type)
+		} else {
+			err = nacosClient.remove(ctx, item.Key)
+		}
+		if err != nil {
+			p.log("ERROR", fmt.Sprintf("回滚配置失败: %v", err))
+			failed = append(failed, item.Key.DataID)
+			continue
+		}
+		p.log("INFO", fmt.Sprintf("已回滚配置 %s（%s）", item.Key.DataID, item.Key.Group))
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d 项配置回滚失败: %s", len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// renderContent 生成要发布的内容：file读取文件，keys在当前内容上修改；{tag}替换为镜像标签
+func renderContent(item config.NacosItemConfig, current, tag, baseDir string) (string, error) {
+	if item.File != "" {
+		path := item.File
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("读取配置文件 %s 失败: %v", item.File, err)
+		}
+		return strings.ReplaceAll(string(data), "{tag}", tag), nil
+	}
+
+	values := make(map[string]string, len(item.Keys))
+	for key, value := range item.Keys {
+		values[key] = strings.ReplaceAll(value, "{tag}", tag)
+	}
+	return setProperties(current, values), nil
+}
+
+// setProperties 修改properties内容中的键（保留其他行和注释），不存在的键按名称顺序追加到末尾
+func setProperties(content string, values map[string]string) string {
+	var lines []string
+	if content != "" {
+		lines = strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	}
+	found := make(map[string]bool, len(values))
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "!") {
+			continue
+		}
+		index := strings.IndexAny(trimmed, "=:")
+		if index < 0 {
+			continue
+		}
+		key := strings.TrimSpace(trimmed[:index])
+		if value, ok := values[key]; ok {
+			lines[i] = key + "=" + value
+			found[key] = true
+		}
+	}
+
+	var missing []string
+	for key := range values {
+		if !found[key] {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	for _, key := range missing {
+		lines = append(lines, key+"="+values[key])
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// lineDiff 逐行对比（最长公共子序列），返回以 -/+ 标记的变化行
+func lineDiff(oldContent, newContent string) []string {
+	oldLines := strings.Split(oldContent, "\n")
+	newLines := strings.Split(newContent, "\n")
+	if oldContent == "" {
+		oldLines = nil
+	}
+	if len(oldLines) > maxDiffLines || len(newLines) > maxDiffLines {
+		return []string{fmt.Sprintf("（内容较大，不逐行对比：%d 行 -> %d 行）", len(oldLines), len(newLines))}
+	}
+
+	// lcs[i][j]: oldLines[i:]与newLines[j:]的最长公共子序列长度
+	lcs := make([][]int, len(oldLines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(newLines)+1)
+	}
+	for i := len(oldLines) - 1; i >= 0; i-- {
+		for j := len(newLines) - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var diff []string
+	i, j := 0, 0
+	for i < len(oldLines) || j < len(newLines) {
+		switch {
+		case i < len(oldLines) && j < len(newLines) && oldLines[i] == newLines[j]:
+			i++
+			j++
+		case i < len(oldLines) && (j == len(newLines) || lcs[i+1][j] >= lcs[i][j+1]):
+			diff = append(diff, "- "+oldLines[i])
+			i++
+		default:
+			diff = append(diff, "+ "+newLines[j])
+			j++
+		}
+	}
+	return diff
+}
+
+// log 写入步骤日志
+func (p *ConfigPublisher) log(level, message string) {
+	if p.taskLogger != nil {
+		p.taskLogger.WriteStep("nacosPublish", level, message)
+	}
+}
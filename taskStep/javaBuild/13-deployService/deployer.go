@@ -5,7 +5,6 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -35,24 +34,60 @@ func (d *ServiceDeployer) DeployServices(ctx context.Context, deployDir, project
 }
 
 // DeployServicesWithCategory 部署服务（支持category，可取消）
+// 修改YAML前为部署目录创建快照，kubectl apply开始前失败或被取消时恢复快照，使目录内容始终与实际运行的版本一致；
+// apply已开始后集群中可能已有部分资源更新，此时保留修改后的文件，不再恢复
 func (d *ServiceDeployer) DeployServicesWithCategory(ctx context.Context, deployDir, project, newTag, category string) error {
+	snapshot, err := snapshotDeployDir(deployDir)
+	if err != nil {
+		return fmt.Errorf("创建部署目录快照失败: %v", err)
+	}
+	defer os.Remove(snapshot)
+
+	applyStarted, err := d.deployServices(ctx, deployDir, project, newTag, category)
+	if err != nil {
+		if applyStarted {
+			if d.taskLogger != nil {
+				d.taskLogger.WriteStep("deployService", "WARNING", "kubectl apply已开始执行，集群中部分资源可能已更新，保留部署目录中修改后的文件")
+			}
+			return err
+		}
+		d.restoreAfterFailure(deployDir, snapshot, err)
+		return err
+	}
+	return nil
+}
+
+// deployServices 更新YAML文件中的镜像标签并执行kubectl apply，返回kubectl apply是否已开始执行
+func (d *ServiceDeployer) deployServices(ctx context.Context, deployDir, project, newTag, category string) (bool, error) {
 	// 获取所有YAML文件
 	yamlFiles, err := d.getYamlFiles(deployDir)
 	if err != nil {
-		return fmt.Errorf("获取YAML文件失败: %v", err)
+		return false, fmt.Errorf("获取YAML文件失败: %v", err)
 	}
 
 	if len(yamlFiles) == 0 {
 		if d.taskLogger != nil {
 			d.taskLogger.WriteStep("deployService", "INFO", "没有找到需要部署的YAML文件")
 		}
-		return nil
+		return false, nil
 	}
 
 	if d.taskLogger != nil {
 		d.taskLogger.WriteStep("deployService", "INFO", fmt.Sprintf("找到 %d 个YAML文件需要处理", len(yamlFiles)))
 	}
 
+	// 用部署目录的本地git仓库记录本次修改，记录失败只写日志，不影响部署
+	trackManifests := config.AppConfig.ManifestTrackingEnabled()
+	var parentCommit string
+	if trackManifests {
+		if parentCommit, err = d.prepareManifestRepo(ctx, deployDir); err != nil {
+			trackManifests = false
+			if d.taskLogger != nil {
+				d.taskLogger.WriteStep("deployService", "WARNING", fmt.Sprintf("记录部署文件修改失败: %v", err))
+			}
+		}
+	}
+
 	// 并发处理YAML文件
 	var wg sync.WaitGroup
 	errChan := make(chan error, len(yamlFiles))
@@ -90,20 +125,25 @@ func (d *ServiceDeployer) DeployServicesWithCategory(ctx context.Context, deploy
 	// 检查是否有错误
 	for err := range errChan {
 		if err != nil {
-			return err
+			return false, err
 		}
 	}
 
 	if d.taskLogger != nil {
 		d.taskLogger.WriteStep("deployService", "INFO", "所有YAML文件处理完成")
 	}
+	if trackManifests {
+		if err := d.recordManifestChanges(ctx, deployDir, project, newTag, parentCommit); err != nil && d.taskLogger != nil {
+			d.taskLogger.WriteStep("deployService", "WARNING", fmt.Sprintf("记录部署文件修改失败: %v", err))
+		}
+	}
 
 	// 执行kubectl apply应用所有部署文件
-	if err := d.applyDeployments(ctx, deployDir, project, category); err != nil {
-		return fmt.Errorf("应用部署文件失败: %v", err)
+	if applyStarted, err := d.applyDeployments(ctx, deployDir, project, category); err != nil {
+		return applyStarted, fmt.Errorf("应用部署文件失败: %v", err)
 	}
 
-	return nil
+	return true, nil
 }
 
 // getYamlFiles 获取目录下所有YAML文件
@@ -114,6 +154,10 @@ func (d *ServiceDeployer) getYamlFiles(deployDir string) ([]string, error) {
 		if err != nil {
 			return err
 		}
+		// 跳过记录部署文件修改的git仓库
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
 
 		if !info.IsDir() && (strings.HasSuffix(info.Name(), ".yaml") || strings.HasSuffix(info.Name(), ".yml")) {
 			yamlFiles = append(yamlFiles, path)
@@ -198,13 +242,13 @@ func (d *ServiceDeployer) updateYamlFile(filePath, project, newTag string) error
 	return nil
 }
 
-// applyDeployments 执行kubectl apply应用部署文件
-func (d *ServiceDeployer) applyDeployments(ctx context.Context, deployDir, project, category string) error {
+// applyDeployments 执行kubectl apply应用部署文件，返回kubectl apply是否已开始执行
+func (d *ServiceDeployer) applyDeployments(ctx context.Context, deployDir, project, category string) (bool, error) {
 	if d.taskLogger != nil {
 		d.taskLogger.WriteStep("deployService", "INFO", fmt.Sprintf("开始应用部署文件，目录: %s, 项目: %s, 分类: %s", deployDir, project, category))
 	}
 
-	var cmd *exec.Cmd
+	var cmd common.Command
 
 	// 检查是否为风控项目且有category
 	if strings.Contains(project, "risk") && category != "" {
@@ -212,41 +256,44 @@ func (d *ServiceDeployer) applyDeployments(ctx context.Context, deployDir, proje
 		serviceFile := fmt.Sprintf("bxhd-risk-%s.yaml", category)
 		serviceFilePath := filepath.Join(deployDir, serviceFile)
 		if _, err := os.Stat(serviceFilePath); os.IsNotExist(err) {
-			return fmt.Errorf("指定的服务文件不存在: %s", serviceFilePath)
+			return false, fmt.Errorf("指定的服务文件不存在: %s", serviceFilePath)
 		}
 		if d.taskLogger != nil {
 			d.taskLogger.WriteStep("deployService", "INFO", fmt.Sprintf("风控项目 - 应用服务文件: %s", serviceFile))
 		}
-		cmd = exec.CommandContext(ctx, "kubectl", "apply", "-f", serviceFile)
+		cmd = common.NewCommand("kubectl", "apply", "-f", serviceFile)
 	} else {
 		// 非风控项目或无category，应用所有文件
 		if d.taskLogger != nil {
 			d.taskLogger.WriteStep("deployService", "INFO", "非风控项目或无分类 - 应用所有YAML文件")
 		}
-		cmd = exec.CommandContext(ctx, "kubectl", "apply", "-f", ".")
+		cmd = common.NewCommand("kubectl", "apply", "-f", ".")
 	}
 
 	cmd.Dir = deployDir // 设置工作目录
 
-	output, err := cmd.CombinedOutput()
-
-	// 写入命令执行日志
-	if d.taskLogger != nil {
-		d.taskLogger.WriteCommand("deployService", cmd.String(), output, err)
+	// 所有任务共享全局并发名额
+	release, err := common.AcquireOperation(ctx, common.OperationApply)
+	if err != nil {
+		return false, fmt.Errorf("等待kubectl apply执行名额被取消")
 	}
+	defer release()
+
+	// apply输出实时写入任务日志
+	err = d.taskLogger.StreamCommand(ctx, "deployService", "", cmd)
 
 	if err != nil {
 		// 检查是否是上下文取消导致的错误
 		if ctx.Err() == context.Canceled {
-			return fmt.Errorf("kubectl apply被取消")
+			return true, fmt.Errorf("kubectl apply被取消")
 		}
-		return fmt.Errorf("kubectl apply执行失败: %v", err)
+		return true, fmt.Errorf("kubectl apply执行失败: %v", err)
 	}
 
 	if d.taskLogger != nil {
 		d.taskLogger.WriteStep("deployService", "INFO", "kubectl apply执行成功")
 	}
-	return nil
+	return true, nil
 }
 
 // DeployServices 部署服务列表（包装函数，无日志记录）
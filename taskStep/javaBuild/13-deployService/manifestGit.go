@@ -0,0 +1,98 @@
+package deployService
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cicd-agent/common"
+)
+
+// manifestAuthor 部署目录本地仓库的提交作者
+const manifestAuthor = "cicd-agent"
+
+// prepareManifestRepo 部署目录没有git仓库时初始化，并把修改前的内容（含人工修改）提交为基线，返回当前提交
+func (d *ServiceDeployer) prepareManifestRepo(ctx context.Context, deployDir string) (string, error) {
+	if _, err := os.Stat(filepath.Join(deployDir, ".git")); os.IsNotExist(err) {
+		if _, err := d.manifestGit(ctx, deployDir, "init", "-q"); err != nil {
+			return "", fmt.Errorf("初始化部署目录git仓库失败: %v", err)
+		}
+		if d.taskLogger != nil {
+			d.taskLogger.WriteStep("deployService", "INFO", fmt.Sprintf("已在部署目录初始化git仓库: %s", deployDir))
+		}
+	}
+
+	committed, err := d.commitManifests(ctx, deployDir, fmt.Sprintf("baseline before task %s", d.taskID))
+	if err != nil {
+		return "", err
+	}
+	if committed && d.taskLogger != nil {
+		d.taskLogger.WriteStep("deployService", "INFO", "部署目录存在未记录的修改，已提交为基线")
+	}
+	// 空目录没有任何提交时返回空
+	head, _ := d.manifestGit(ctx, deployDir, "rev-parse", "--verify", "-q", "HEAD")
+	return head, nil
+}
+
+// recordManifestChanges 提交本次任务对YAML的修改，并记录到任务目录供查看diff
+func (d *ServiceDeployer) recordManifestChanges(ctx context.Context, deployDir, project, newTag, parent string) error {
+	committed, err := d.commitManifests(ctx, deployDir, fmt.Sprintf("deploy %s %s (task %s)", project, newTag, d.taskID))
+	if err != nil {
+		return err
+	}
+
+	record := common.ManifestCommit{
+		TaskID:      d.taskID,
+		Project:     project,
+		Tag:         newTag,
+		DeployDir:   deployDir,
+		Parent:      parent,
+		CommittedAt: time.Now().Format("2006-01-02 15:04:05"),
+	}
+	if committed {
+		if record.Commit, err = d.manifestGit(ctx, deployDir, "rev-parse", "HEAD"); err != nil {
+			return err
+		}
+		if d.taskLogger != nil {
+			d.taskLogger.WriteStep("deployService", "INFO", fmt.Sprintf("部署文件修改已提交: %s", record.Commit))
+		}
+	} else if d.taskLogger != nil {
+		d.taskLogger.WriteStep("deployService", "INFO", "部署文件没有变化，无需提交")
+	}
+	if d.taskID == "" {
+		return nil
+	}
+	return common.WriteManifestCommit(record)
+}
+
+// commitManifests 暂存部署目录的全部修改并提交，没有修改时不提交，返回是否提交
+func (d *ServiceDeployer) commitManifests(ctx context.Context, deployDir, message string) (bool, error) {
+	if _, err := d.manifestGit(ctx, deployDir, "add", "-A"); err != nil {
+		return false, fmt.Errorf("暂存部署文件失败: %v", err)
+	}
+	status, err := d.manifestGit(ctx, deployDir, "status", "--porcelain")
+	if err != nil {
+		return false, fmt.Errorf("读取部署目录状态失败: %v", err)
+	}
+	if status == "" {
+		return false, nil
+	}
+	if _, err := d.manifestGit(ctx, deployDir, "-c", "user.name="+manifestAuthor, "-c", "user.email="+manifestAuthor+"@localhost", "commit", "-q", "-m", message); err != nil {
+		return false, fmt.Errorf("提交部署文件失败: %v", err)
+	}
+	return true, nil
+}
+
+// manifestGit 在部署目录执行git命令，返回去除首尾空白的输出
+func (d *ServiceDeployer) manifestGit(ctx context.Context, deployDir string, args ...string) (string, error) {
+	cmd := common.NewCommand("git", args...)
+	cmd.Dir = deployDir
+	output, err := common.RunCommand(ctx, cmd)
+	if err != nil {
+		return "", fmt.Errorf("%v %s", err, strings.TrimSpace(string(output)))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
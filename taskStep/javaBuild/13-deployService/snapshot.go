@@ -0,0 +1,200 @@
+package deployService
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// snapshotDeployDir 将部署目录（不含记录修改的.git）打包为临时tar.gz，返回快照文件路径
+func snapshotDeployDir(deployDir string) (string, error) {
+	file, err := os.CreateTemp("", "deploy-snapshot-*.tar.gz")
+	if err != nil {
+		return "", fmt.Errorf("创建快照文件失败: %v", err)
+	}
+	snapshot := file.Name()
+
+	gzipWriter := gzip.NewWriter(file)
+	tarWriter := tar.NewWriter(gzipWriter)
+	err = filepath.Walk(deployDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(deployDir, path)
+		if err != nil || relPath == "." {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relPath)
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		source, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer source.Close()
+		_, err = io.Copy(tarWriter, source)
+		return err
+	})
+	if err == nil {
+		err = tarWriter.Close()
+	}
+	if err == nil {
+		err = gzipWriter.Close()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(snapshot)
+		return "", fmt.Errorf("打包部署目录失败: %v", err)
+	}
+	return snapshot, nil
+}
+
+// restoreDeployDir 用快照恢复部署目录：先解包到同级临时目录，成功后移入.git并替换原目录，解包失败时原目录不受影响
+func restoreDeployDir(deployDir, snapshot string) error {
+	deployDir = filepath.Clean(deployDir)
+	info, err := os.Stat(deployDir)
+	if err != nil {
+		return fmt.Errorf("读取部署目录失败: %v", err)
+	}
+	// 临时目录与部署目录同级，保证rename在同一文件系统内完成
+	restoreDir, err := os.MkdirTemp(filepath.Dir(deployDir), "."+filepath.Base(deployDir)+"-restore-*")
+	if err != nil {
+		return fmt.Errorf("创建恢复目录失败: %v", err)
+	}
+	defer os.RemoveAll(restoreDir)
+	if err := os.Chmod(restoreDir, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("设置恢复目录权限失败: %v", err)
+	}
+	if err := extractSnapshot(snapshot, restoreDir); err != nil {
+		return err
+	}
+
+	gitDir := filepath.Join(deployDir, ".git")
+	_, statErr := os.Stat(gitDir)
+	hasGit := statErr == nil
+	if hasGit {
+		if err := os.Rename(gitDir, filepath.Join(restoreDir, ".git")); err != nil {
+			return fmt.Errorf("迁移修改记录失败: %v", err)
+		}
+	}
+
+	oldDir := restoreDir + ".old"
+	if err := os.Rename(deployDir, oldDir); err != nil {
+		if hasGit {
+			os.Rename(filepath.Join(restoreDir, ".git"), gitDir)
+		}
+		return fmt.Errorf("替换部署目录失败: %v", err)
+	}
+	if err := os.Rename(restoreDir, deployDir); err != nil {
+		os.Rename(oldDir, deployDir)
+		if hasGit {
+			os.Rename(filepath.Join(restoreDir, ".git"), gitDir)
+		}
+		return fmt.Errorf("替换部署目录失败: %v", err)
+	}
+	os.RemoveAll(oldDir)
+	return nil
+}
+
+// extractSnapshot 将快照解包到目标目录
+func extractSnapshot(snapshot, targetDir string) error {
+	file, err := os.Open(snapshot)
+	if err != nil {
+		return fmt.Errorf("打开快照文件失败: %v", err)
+	}
+	defer file.Close()
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("读取快照文件失败: %v", err)
+	}
+	defer gzipReader.Close()
+
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("解包快照失败: %v", err)
+		}
+		target := filepath.Join(targetDir, filepath.FromSlash(header.Name))
+		if !strings.HasPrefix(target, filepath.Clean(targetDir)+string(os.PathSeparator)) {
+			return fmt.Errorf("快照中的路径不合法: %s", header.Name)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, os.FileMode(header.Mode).Perm())
+		case tar.TypeSymlink:
+			err = os.Symlink(header.Linkname, target)
+		case tar.TypeReg:
+			err = writeSnapshotFile(target, os.FileMode(header.Mode).Perm(), tarReader)
+		}
+		if err != nil {
+			return fmt.Errorf("恢复 %s 失败: %v", header.Name, err)
+		}
+	}
+}
+
+// writeSnapshotFile 写入快照中的文件
+func writeSnapshotFile(target string, mode os.FileMode, content io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, content); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// restoreAfterFailure 步骤失败或任务取消后恢复部署目录，使目录内容与实际运行的版本一致；开启修改记录时将恢复提交到本地仓库
+func (d *ServiceDeployer) restoreAfterFailure(deployDir, snapshot string, cause error) {
+	if err := restoreDeployDir(deployDir, snapshot); err != nil {
+		if d.taskLogger != nil {
+			d.taskLogger.WriteStep("deployService", "ERROR", fmt.Sprintf("恢复部署目录失败: %v", err))
+		}
+		return
+	}
+	if d.taskLogger != nil {
+		d.taskLogger.WriteStep("deployService", "INFO", fmt.Sprintf("部署未完成（%v），已将部署目录恢复到修改前的快照", cause))
+	}
+
+	if _, err := os.Stat(filepath.Join(deployDir, ".git")); err != nil {
+		return
+	}
+	// 任务可能已取消，使用独立的ctx提交恢复
+	if _, err := d.commitManifests(context.Background(), deployDir, fmt.Sprintf("restore after task %s: %v", d.taskID, cause)); err != nil && d.taskLogger != nil {
+		d.taskLogger.WriteStep("deployService", "WARNING", fmt.Sprintf("提交部署目录恢复失败: %v", err))
+	}
+}
@@ -0,0 +1,144 @@
+package gitopsHandoff
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"cicd-agent/common"
+	"cicd-agent/config"
+)
+
+// argoApplication ArgoCD Application状态中用到的字段
+type argoApplication struct {
+	Status struct {
+		Sync struct {
+			Status   string `json:"status"`   // Synced/OutOfSync/Unknown
+			Revision string `json:"revision"` // 已同步的提交
+		} `json:"sync"`
+		Health struct {
+			Status  string `json:"status"` // Healthy/Progressing/Degraded/Suspended/Missing/Unknown
+			Message string `json:"message"`
+		} `json:"health"`
+		OperationState struct {
+			Phase      string `json:"phase"` // Running/Succeeded/Failed/Error/Terminating
+			Message    string `json:"message"`
+			SyncResult struct {
+				Revision string `json:"revision"`
+			} `json:"syncResult"`
+		} `json:"operationState"`
+	} `json:"status"`
+}
+
+// ApplicationWaiter 等待ArgoCD Application同步到指定提交并健康
+type ApplicationWaiter struct {
+	taskID     string
+	taskLogger *common.TaskLogger
+}
+
+// NewApplicationWaiter 创建Application等待器
+func NewApplicationWaiter(taskID string, taskLogger *common.TaskLogger) *ApplicationWaiter {
+	return &ApplicationWaiter{
+		taskID:     taskID,
+		taskLogger: taskLogger,
+	}
+}
+
+// WaitSyncedAndHealthy 轮询Application状态，直到已同步到revision（为空时不检查提交）且状态为Synced/Healthy
+// 同步操作针对该提交失败时立即返回错误；超过gitops.argocd.sync_timeout返回最后一次的状态
+func (w *ApplicationWaiter) WaitSyncedAndHealthy(ctx context.Context, application, appNamespace, revision string) error {
+	timeout := config.AppConfig.GetArgoCDSyncTimeout()
+	interval := config.AppConfig.GetArgoCDPollInterval()
+	deadline := time.Now().Add(timeout)
+	w.log("INFO", fmt.Sprintf("等待ArgoCD Application %s 同步到 %s 且健康，超时 %s", application, shortRevision(revision), timeout))
+
+	lastState := ""
+	refresh := true // 首次查询要求ArgoCD刷新，尽快发现新提交
+	for {
+		app, err := w.getApplication(ctx, application, appNamespace, refresh)
+		refresh = false
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			w.log("WARNING", fmt.Sprintf("查询Application失败: %v", err))
+		} else {
+			status := app.Status
+			state := fmt.Sprintf("同步=%s(%s), 健康=%s", status.Sync.Status, shortRevision(status.Sync.Revision), status.Health.Status)
+			if state != lastState {
+				w.log("INFO", state)
+				lastState = state
+			}
+			revisionMatched := revision == "" || status.Sync.Revision == revision
+			if revisionMatched && status.Sync.Status == "Synced" && status.Health.Status == "Healthy" {
+				w.log("INFO", fmt.Sprintf("Application %s 已同步且健康", application))
+				return nil
+			}
+			operation := status.OperationState
+			if (operation.Phase == "Failed" || operation.Phase == "Error") && (revision == "" || operation.SyncResult.Revision == revision) {
+				return fmt.Errorf("ArgoCD同步失败: %s", operation.Message)
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("等待ArgoCD同步超时（%s），最后状态: %s", timeout, lastState)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// getApplication 通过ArgoCD API查询Application
+func (w *ApplicationWaiter) getApplication(ctx context.Context, application, appNamespace string, refresh bool) (*argoApplication, error) {
+	argoConfig := config.AppConfig.GitOps.ArgoCD
+	query := url.Values{}
+	if appNamespace != "" {
+		query.Set("appNamespace", appNamespace)
+	}
+	if refresh {
+		query.Set("refresh", "normal")
+	}
+	requestURL := strings.TrimSuffix(argoConfig.Server, "/") + "/api/v1/applications/" + url.PathEscape(application)
+	if len(query) > 0 {
+		requestURL += "?" + query.Encode()
+	}
+
+	resp, err := common.DoHTTP(ctx, common.HTTPRequest{
+		Method: http.MethodGet,
+		URL:    requestURL,
+		Header: map[string]string{"Authorization": "Bearer " + argoConfig.Token},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ArgoCD返回状态码 %d: %s", resp.StatusCode, string(resp.Body))
+	}
+	var app argoApplication
+	if err := json.Unmarshal(resp.Body, &app); err != nil {
+		return nil, fmt.Errorf("解析Application失败: %v", err)
+	}
+	return &app, nil
+}
+
+// log 写入步骤日志
+func (w *ApplicationWaiter) log(level, message string) {
+	if w.taskLogger != nil {
+		w.taskLogger.WriteStep("argocdSync", level, message)
+	}
+}
+
+// shortRevision 提交的前8位，用于日志
+func shortRevision(revision string) string {
+	if len(revision) > 8 {
+		return revision[:8]
+	}
+	return revision
+}
@@ -0,0 +1,171 @@
+package gitopsHandoff
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"cicd-agent/common"
+	"cicd-agent/config"
+)
+
+// repoLocks 同一项目的GitOps仓库工作目录同时只允许一个任务操作
+var repoLocks sync.Map
+
+// RepoUpdater GitOps仓库更新器：拉取仓库最新内容，更新项目镜像标签后提交并推送
+type RepoUpdater struct {
+	taskID     string
+	taskLogger *common.TaskLogger
+}
+
+// NewRepoUpdater 创建GitOps仓库更新器
+func NewRepoUpdater(taskID string, taskLogger *common.TaskLogger) *RepoUpdater {
+	return &RepoUpdater{
+		taskID:     taskID,
+		taskLogger: taskLogger,
+	}
+}
+
+// UpdateImageTag 更新仓库中项目镜像的标签并推送，返回推送后的提交（镜像标签已是目标标签时不提交，返回当前提交）
+// 推送被拒绝（远端有新提交）时重新拉取后再试一次
+func (u *RepoUpdater) UpdateImageTag(ctx context.Context, project, tag string, repoConfig config.GitOpsProjectConfig) (string, error) {
+	lock, _ := repoLocks.LoadOrStore(project, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	repoDir := filepath.Join(config.AppConfig.GetGitOpsWorkDir(), project)
+	var lastErr error
+	for attempt := 0; attempt < 2; attempt++ {
+		if err := u.syncRepo(ctx, repoDir, repoConfig); err != nil {
+			return "", err
+		}
+		changed, err := u.updateFiles(repoDir, project, tag, repoConfig.Files)
+		if err != nil {
+			return "", err
+		}
+		if !changed {
+			u.log("INFO", fmt.Sprintf("镜像标签已是 %s，无需提交", tag))
+			return u.git(ctx, repoDir, "rev-parse", "HEAD")
+		}
+
+		name, email := config.AppConfig.GetGitOpsAuthor()
+		message := fmt.Sprintf("deploy %s %s (task %s)", project, tag, u.taskID)
+		if _, err := u.git(ctx, repoDir, "-c", "user.name="+name, "-c", "user.email="+email, "commit", "-a", "-m", message); err != nil {
+			return "", fmt.Errorf("提交失败: %v", err)
+		}
+		if _, err := u.git(ctx, repoDir, "push", "origin", "HEAD:"+repoConfig.Branch); err != nil {
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			lastErr = err
+			u.log("WARNING", fmt.Sprintf("推送失败，重新拉取后重试: %v", err))
+			continue
+		}
+		revision, err := u.git(ctx, repoDir, "rev-parse", "HEAD")
+		if err != nil {
+			return "", err
+		}
+		u.log("INFO", fmt.Sprintf("已推送到 %s 分支 %s: %s", redactURL(repoConfig.Repo), repoConfig.Branch, revision))
+		return revision, nil
+	}
+	return "", fmt.Errorf("推送失败: %v", lastErr)
+}
+
+// syncRepo 首次使用时克隆仓库，之后拉取分支最新内容并丢弃本地修改
+func (u *RepoUpdater) syncRepo(ctx context.Context, repoDir string, repoConfig config.GitOpsProjectConfig) error {
+	if _, err := os.Stat(filepath.Join(repoDir, ".git")); err != nil {
+		if err := os.MkdirAll(filepath.Dir(repoDir), 0755); err != nil {
+			return fmt.Errorf("创建GitOps工作目录失败: %v", err)
+		}
+		os.RemoveAll(repoDir)
+		u.log("INFO", fmt.Sprintf("克隆GitOps仓库: %s（分支 %s）", redactURL(repoConfig.Repo), repoConfig.Branch))
+		// 克隆命令包含仓库地址（可能带凭证），不写入命令日志
+		cmd := common.NewCommand("git", "clone", "--branch", repoConfig.Branch, "--single-branch", repoConfig.Repo, repoDir)
+		if output, err := common.RunCommand(ctx, cmd); err != nil {
+			return fmt.Errorf("克隆GitOps仓库失败: %v %s", err, strings.TrimSpace(strings.ReplaceAll(string(output), repoConfig.Repo, redactURL(repoConfig.Repo))))
+		}
+		return nil
+	}
+
+	if _, err := u.git(ctx, repoDir, "fetch", "origin", repoConfig.Branch); err != nil {
+		return fmt.Errorf("拉取GitOps仓库失败: %v", err)
+	}
+	if _, err := u.git(ctx, repoDir, "checkout", "-B", repoConfig.Branch, "FETCH_HEAD"); err != nil {
+		return fmt.Errorf("切换到分支 %s 失败: %v", repoConfig.Branch, err)
+	}
+	if _, err := u.git(ctx, repoDir, "reset", "--hard", "FETCH_HEAD"); err != nil {
+		return fmt.Errorf("重置GitOps仓库失败: %v", err)
+	}
+	return nil
+}
+
+// updateFiles 将文件中项目镜像（离线Harbor/项目/服务:标签）的标签替换为新标签，返回是否有文件变化
+func (u *RepoUpdater) updateFiles(repoDir, project, tag string, files []string) (bool, error) {
+	imagePattern := regexp.MustCompile(`(` + regexp.QuoteMeta(config.AppConfig.Harbor.Offline) + `/` + regexp.QuoteMeta(project) + `/[^:\s"']+):([^\s"'@]+)`)
+
+	changed := false
+	for _, file := range files {
+		path := filepath.Join(repoDir, filepath.FromSlash(file))
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return false, fmt.Errorf("读取文件 %s 失败: %v", file, err)
+		}
+		matches := imagePattern.FindAllStringSubmatch(string(data), -1)
+		if len(matches) == 0 {
+			u.log("WARNING", fmt.Sprintf("文件 %s 中没有项目 %s 的镜像", file, project))
+			continue
+		}
+		content := imagePattern.ReplaceAllString(string(data), "${1}:"+tag)
+		if content == string(data) {
+			continue
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return false, fmt.Errorf("写入文件 %s 失败: %v", file, err)
+		}
+		for _, match := range matches {
+			if match[2] != tag {
+				u.log("INFO", fmt.Sprintf("文件 %s: %s 标签 %s -> %s", file, match[1], match[2], tag))
+			}
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
+// git 在仓库目录执行git命令，输出写入步骤日志，返回去除首尾空白的输出
+func (u *RepoUpdater) git(ctx context.Context, repoDir string, args ...string) (string, error) {
+	cmd := common.NewCommand("git", args...)
+	cmd.Dir = repoDir
+	output, err := common.RunCommand(ctx, cmd)
+	if u.taskLogger != nil {
+		u.taskLogger.WriteStep("gitopsCommit", "INFO", fmt.Sprintf("[COMMAND] %s", cmd.String()))
+		if text := strings.TrimSpace(string(output)); text != "" {
+			u.taskLogger.WriteStep("gitopsCommit", "INFO", text)
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("%v %s", err, strings.TrimSpace(string(output)))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// log 写入步骤日志
+func (u *RepoUpdater) log(level, message string) {
+	if u.taskLogger != nil {
+		u.taskLogger.WriteStep("gitopsCommit", level, message)
+	}
+}
+
+// redactURL 隐藏仓库地址中的密码，用于日志
+func redactURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.User == nil {
+		return rawURL
+	}
+	return parsed.Redacted()
+}
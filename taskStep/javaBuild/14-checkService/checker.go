@@ -3,7 +3,7 @@ package checkService
 import (
 	"context"
 	"fmt"
-	"os/exec"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -13,11 +13,16 @@ import (
 
 // ServiceChecker 服务检查器
 type ServiceChecker struct {
-	taskID     string
-	project    string
-	taskLogger *common.TaskLogger
+	taskID         string
+	project        string
+	taskLogger     *common.TaskLogger
+	healthPath     string // 健康检查路径
+	maxConcurrency int    // 项目配置的并发上限，为0时按pod数量计算
 }
 
+// defaultHealthPath 默认的健康检查路径
+const defaultHealthPath = "/actuator/health"
+
 // 不使用filebeat容器的项目列表（这些项目只有一个容器）
 var noFilebeatProjects = []string{"bjjf", "jzsk"}
 
@@ -37,7 +42,21 @@ func NewServiceChecker(taskID string, project string, taskLogger *common.TaskLog
 		taskID:     taskID,
 		project:    project,
 		taskLogger: taskLogger,
+		healthPath: defaultHealthPath,
+	}
+}
+
+// SetHealthPath 设置项目的健康检查路径（如 /health）
+func (c *ServiceChecker) SetHealthPath(path string) {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
 	}
+	c.healthPath = path
+}
+
+// SetMaxConcurrency 设置项目的并发检查上限
+func (c *ServiceChecker) SetMaxConcurrency(n int) {
+	c.maxConcurrency = n
 }
 
 // CheckServicesReady 检查服务就绪状态
@@ -166,8 +185,8 @@ func (c *ServiceChecker) getAllControllers(ctx context.Context, namespace string
 	allControllers := make(map[string][]string)
 
 	// 获取所有Deployment
-	cmdDeploy := exec.CommandContext(ctx, "kubectl", "get", "deployments", "-n", namespace, "--no-headers", "-o", "custom-columns=NAME:.metadata.name")
-	outputDeploy, err := cmdDeploy.CombinedOutput()
+	cmdDeploy := common.NewCommand("kubectl", "get", "deployments", "-n", namespace, "--no-headers", "-o", "custom-columns=NAME:.metadata.name")
+	outputDeploy, err := common.RunCommand(ctx, cmdDeploy)
 	if c.taskLogger != nil {
 		c.taskLogger.WriteCommand("checkService", cmdDeploy.String(), outputDeploy, err)
 	}
@@ -182,8 +201,8 @@ func (c *ServiceChecker) getAllControllers(ctx context.Context, namespace string
 	}
 
 	// 获取所有StatefulSet
-	cmdSts := exec.CommandContext(ctx, "kubectl", "get", "statefulsets", "-n", namespace, "--no-headers", "-o", "custom-columns=NAME:.metadata.name")
-	outputSts, err := cmdSts.CombinedOutput()
+	cmdSts := common.NewCommand("kubectl", "get", "statefulsets", "-n", namespace, "--no-headers", "-o", "custom-columns=NAME:.metadata.name")
+	outputSts, err := common.RunCommand(ctx, cmdSts)
 	if c.taskLogger != nil {
 		c.taskLogger.WriteCommand("checkService", cmdSts.String(), outputSts, err)
 	}
@@ -198,8 +217,8 @@ func (c *ServiceChecker) getAllControllers(ctx context.Context, namespace string
 	}
 
 	// 获取所有独立的ReplicaSet（不属于Deployment的）
-	cmdRs := exec.CommandContext(ctx, "kubectl", "get", "replicasets", "-n", namespace, "--no-headers", "-o", "custom-columns=NAME:.metadata.name,OWNER:.metadata.ownerReferences[0].kind")
-	outputRs, err := cmdRs.CombinedOutput()
+	cmdRs := common.NewCommand("kubectl", "get", "replicasets", "-n", namespace, "--no-headers", "-o", "custom-columns=NAME:.metadata.name,OWNER:.metadata.ownerReferences[0].kind")
+	outputRs, err := common.RunCommand(ctx, cmdRs)
 	if c.taskLogger != nil {
 		c.taskLogger.WriteCommand("checkService", cmdRs.String(), outputRs, err)
 	}
@@ -231,10 +250,10 @@ func (c *ServiceChecker) getFailedControllers(ctx context.Context, namespace str
 
 	// 对每个失败的pod查询其控制器信息
 	for _, podName := range failedPods {
-		cmd := exec.CommandContext(ctx, "kubectl", "get", "pod", podName, "-n", namespace,
+		cmd := common.NewCommand("kubectl", "get", "pod", podName, "-n", namespace,
 			"-o", "jsonpath={.metadata.ownerReferences[0].kind},{.metadata.ownerReferences[0].name}")
 
-		output, err := cmd.CombinedOutput()
+		output, err := common.RunCommand(ctx, cmd)
 
 		if c.taskLogger != nil {
 			c.taskLogger.WriteCommand("checkService", cmd.String(), output, err)
@@ -292,11 +311,11 @@ func (c *ServiceChecker) getFailedControllers(ctx context.Context, namespace str
 // getFailedControllersOld 获取失败Pod对应的控制器（旧版本，使用field-selector）
 func (c *ServiceChecker) getFailedControllersOld(ctx context.Context, namespace string) (map[string][]string, error) {
 	// 获取所有非Running状态的Pod及其控制器信息
-	cmd := exec.CommandContext(ctx, "kubectl", "get", "pods", "-n", namespace,
+	cmd := common.NewCommand("kubectl", "get", "pods", "-n", namespace,
 		"--field-selector=status.phase!=Running", "--no-headers",
 		"-o", "custom-columns=NAME:.metadata.name,CONTROLLER:.metadata.ownerReferences[0].name,KIND:.metadata.ownerReferences[0].kind")
 
-	output, err := cmd.CombinedOutput()
+	output, err := common.RunCommand(ctx, cmd)
 	if err != nil {
 		if strings.Contains(string(output), "No resources found") {
 			return make(map[string][]string), nil
@@ -357,8 +376,8 @@ func (c *ServiceChecker) scaleDownSpecificDeployment(ctx context.Context, namesp
 		c.taskLogger.WriteStep("checkService", "INFO", fmt.Sprintf("缩容指定Deployment: %s", name))
 	}
 
-	scaleCmd := exec.CommandContext(ctx, "kubectl", "scale", "deployment", name, "-n", namespace, "--replicas=0")
-	scaleOutput, scaleErr := scaleCmd.CombinedOutput()
+	scaleCmd := common.NewCommand("kubectl", "scale", "deployment", name, "-n", namespace, "--replicas=0")
+	scaleOutput, scaleErr := common.RunCommand(ctx, scaleCmd)
 
 	if c.taskLogger != nil {
 		c.taskLogger.WriteCommand("checkService", scaleCmd.String(), scaleOutput, scaleErr)
@@ -380,8 +399,8 @@ func (c *ServiceChecker) scaleDownSpecificReplicaSet(ctx context.Context, namesp
 		c.taskLogger.WriteStep("checkService", "INFO", fmt.Sprintf("缩容指定ReplicaSet: %s", name))
 	}
 
-	scaleCmd := exec.CommandContext(ctx, "kubectl", "scale", "replicaset", name, "-n", namespace, "--replicas=0")
-	scaleOutput, scaleErr := scaleCmd.CombinedOutput()
+	scaleCmd := common.NewCommand("kubectl", "scale", "replicaset", name, "-n", namespace, "--replicas=0")
+	scaleOutput, scaleErr := common.RunCommand(ctx, scaleCmd)
 
 	if c.taskLogger != nil {
 		c.taskLogger.WriteCommand("checkService", scaleCmd.String(), scaleOutput, scaleErr)
@@ -403,8 +422,8 @@ func (c *ServiceChecker) scaleDownSpecificStatefulSet(ctx context.Context, names
 		c.taskLogger.WriteStep("checkService", "INFO", fmt.Sprintf("缩容指定StatefulSet: %s", name))
 	}
 
-	scaleCmd := exec.CommandContext(ctx, "kubectl", "scale", "statefulset", name, "-n", namespace, "--replicas=0")
-	scaleOutput, scaleErr := scaleCmd.CombinedOutput()
+	scaleCmd := common.NewCommand("kubectl", "scale", "statefulset", name, "-n", namespace, "--replicas=0")
+	scaleOutput, scaleErr := common.RunCommand(ctx, scaleCmd)
 
 	if c.taskLogger != nil {
 		c.taskLogger.WriteCommand("checkService", scaleCmd.String(), scaleOutput, scaleErr)
@@ -423,8 +442,8 @@ func (c *ServiceChecker) scaleDownSpecificStatefulSet(ctx context.Context, names
 // scaleDownDeployments 缩容所有Deployment到0个副本
 func (c *ServiceChecker) scaleDownDeployments(ctx context.Context, namespace string) error {
 	// 获取所有Deployment及其副本数
-	cmd := exec.CommandContext(ctx, "kubectl", "get", "deployment", "-n", namespace, "--no-headers", "-o", "custom-columns=NAME:.metadata.name,REPLICAS:.spec.replicas")
-	output, err := cmd.CombinedOutput()
+	cmd := common.NewCommand("kubectl", "get", "deployment", "-n", namespace, "--no-headers", "-o", "custom-columns=NAME:.metadata.name,REPLICAS:.spec.replicas")
+	output, err := common.RunCommand(ctx, cmd)
 
 	// 写入命令执行日志
 	if c.taskLogger != nil {
@@ -468,8 +487,8 @@ func (c *ServiceChecker) scaleDownDeployments(ctx context.Context, namespace str
 		if c.taskLogger != nil {
 			c.taskLogger.WriteStep("checkService", "INFO", fmt.Sprintf("缩容Deployment %s (当前副本:%s) 到0个副本", deploymentName, replicas))
 		}
-		scaleCmd := exec.CommandContext(ctx, "kubectl", "scale", "deployment", deploymentName, "-n", namespace, "--replicas=0")
-		scaleOutput, scaleErr := scaleCmd.CombinedOutput()
+		scaleCmd := common.NewCommand("kubectl", "scale", "deployment", deploymentName, "-n", namespace, "--replicas=0")
+		scaleOutput, scaleErr := common.RunCommand(ctx, scaleCmd)
 
 		// 写入命令执行日志
 		if c.taskLogger != nil {
@@ -493,8 +512,8 @@ func (c *ServiceChecker) scaleDownDeployments(ctx context.Context, namespace str
 // scaleDownStatefulSets 缩容所有StatefulSet到0个副本
 func (c *ServiceChecker) scaleDownStatefulSets(ctx context.Context, namespace string) error {
 	// 获取所有StatefulSet
-	cmd := exec.CommandContext(ctx, "kubectl", "get", "statefulset", "-n", namespace, "--no-headers", "-o", "custom-columns=NAME:.metadata.name")
-	output, err := cmd.CombinedOutput()
+	cmd := common.NewCommand("kubectl", "get", "statefulset", "-n", namespace, "--no-headers", "-o", "custom-columns=NAME:.metadata.name")
+	output, err := common.RunCommand(ctx, cmd)
 
 	// 写入命令执行日志
 	if c.taskLogger != nil {
@@ -522,8 +541,8 @@ func (c *ServiceChecker) scaleDownStatefulSets(ctx context.Context, namespace st
 		if c.taskLogger != nil {
 			c.taskLogger.WriteStep("checkService", "INFO", fmt.Sprintf("缩容StatefulSet %s 到0个副本", statefulset))
 		}
-		scaleCmd := exec.CommandContext(ctx, "kubectl", "scale", "statefulset", statefulset, "-n", namespace, "--replicas=0")
-		scaleOutput, scaleErr := scaleCmd.CombinedOutput()
+		scaleCmd := common.NewCommand("kubectl", "scale", "statefulset", statefulset, "-n", namespace, "--replicas=0")
+		scaleOutput, scaleErr := common.RunCommand(ctx, scaleCmd)
 
 		// 写入命令执行日志
 		if c.taskLogger != nil {
@@ -547,8 +566,8 @@ func (c *ServiceChecker) scaleDownStatefulSets(ctx context.Context, namespace st
 // scaleDownReplicaSets 缩容所有ReplicaSet到0个副本
 func (c *ServiceChecker) scaleDownReplicaSets(ctx context.Context, namespace string) error {
 	// 获取所有ReplicaSet及其副本数
-	cmd := exec.CommandContext(ctx, "kubectl", "get", "replicaset", "-n", namespace, "--no-headers", "-o", "custom-columns=NAME:.metadata.name,REPLICAS:.spec.replicas")
-	output, err := cmd.CombinedOutput()
+	cmd := common.NewCommand("kubectl", "get", "replicaset", "-n", namespace, "--no-headers", "-o", "custom-columns=NAME:.metadata.name,REPLICAS:.spec.replicas")
+	output, err := common.RunCommand(ctx, cmd)
 	if err != nil {
 		// 如果没有ReplicaSet，不算错误
 		if strings.Contains(string(output), "No resources found") {
@@ -586,8 +605,8 @@ func (c *ServiceChecker) scaleDownReplicaSets(ctx context.Context, namespace str
 		if c.taskLogger != nil {
 			c.taskLogger.WriteStep("checkService", "INFO", fmt.Sprintf("缩容ReplicaSet %s (当前副本:%s) 到0个副本", replicasetName, replicas))
 		}
-		scaleCmd := exec.CommandContext(ctx, "kubectl", "scale", "replicaset", replicasetName, "-n", namespace, "--replicas=0")
-		if scaleOutput, scaleErr := scaleCmd.CombinedOutput(); scaleErr != nil {
+		scaleCmd := common.NewCommand("kubectl", "scale", "replicaset", replicasetName, "-n", namespace, "--replicas=0")
+		if scaleOutput, scaleErr := common.RunCommand(ctx, scaleCmd); scaleErr != nil {
 			if c.taskLogger != nil {
 				c.taskLogger.WriteStep("checkService", "ERROR", fmt.Sprintf("缩容ReplicaSet %s 失败: %v, 输出: %s", replicasetName, scaleErr, string(scaleOutput)))
 			}
@@ -604,9 +623,9 @@ func (c *ServiceChecker) scaleDownReplicaSets(ctx context.Context, namespace str
 // getAllPods 获取命名空间下所有pod名称
 func (c *ServiceChecker) getAllPods(ctx context.Context, namespace string) ([]string, error) {
 	// 直接获取命名空间下的所有pod
-	cmd := exec.CommandContext(ctx, "kubectl", "get", "pod", "-n", namespace, "--no-headers", "-o", "custom-columns=NAME:.metadata.name")
+	cmd := common.NewCommand("kubectl", "get", "pod", "-n", namespace, "--no-headers", "-o", "custom-columns=NAME:.metadata.name")
 
-	output, err := cmd.CombinedOutput()
+	output, err := common.RunCommand(ctx, cmd)
 	if err != nil {
 		return nil, fmt.Errorf("获取命名空间 %s 下的pod列表失败: %v, 输出: %s", namespace, err, string(output))
 	}
@@ -826,8 +845,8 @@ func (c *ServiceChecker) waitForAllPodsRunning(ctx context.Context, namespace st
 // getAllPodsWithStatus 获取所有pod及其状态
 func (c *ServiceChecker) getAllPodsWithStatus(ctx context.Context, namespace string) (map[string]string, error) {
 	cmdArgs := []string{"get", "pods", "-n", namespace, "-o", "jsonpath={range .items[*]}{.metadata.name}{\"\\t\"}{.status.phase}{\"\\n\"}{end}"}
-	cmd := exec.CommandContext(ctx, "kubectl", cmdArgs...)
-	output, err := cmd.CombinedOutput()
+	cmd := common.NewCommand("kubectl", cmdArgs...)
+	output, err := common.RunCommand(ctx, cmd)
 	if err != nil {
 		return nil, fmt.Errorf("获取pod状态失败: %v, 输出: %s", err, string(output))
 	}
@@ -1043,6 +1062,55 @@ func (c *ServiceChecker) checkPodListHealth(ctx context.Context, namespace strin
 	return completedPods
 }
 
+// PodHealth 单个pod的健康检查结果
+type PodHealth struct {
+	Pod     string `json:"pod"`
+	Phase   string `json:"phase"`
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+}
+
+// HealthReport 对namespace下的所有pod执行一次健康检查（不等待、不重试），按pod名称排序返回每个pod的结果
+func (c *ServiceChecker) HealthReport(ctx context.Context, namespace string) ([]PodHealth, error) {
+	podStates, err := c.getAllPodsWithStatus(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	report := make([]PodHealth, 0, len(podStates))
+	for podName, phase := range podStates {
+		report = append(report, PodHealth{Pod: podName, Phase: phase})
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Pod < report[j].Pod })
+	if len(report) == 0 {
+		return report, nil
+	}
+
+	semaphore := make(chan struct{}, c.calculateConcurrency(len(report)))
+	var wg sync.WaitGroup
+	for i := range report {
+		pod := &report[i]
+		if pod.Phase != "Running" {
+			pod.Error = fmt.Sprintf("pod状态为 %s", pod.Phase)
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			if err := c.checkSinglePodHealth(ctx, namespace, pod.Pod); err != nil {
+				pod.Error = err.Error()
+				return
+			}
+			pod.Healthy = true
+		}()
+	}
+	wg.Wait()
+	return report, nil
+}
+
 // updatePodStatusMap 更新pod状态映射
 func (c *ServiceChecker) updatePodStatusMap(podStatusMap map[string]*PodStatus, allPods []string) {
 	// 创建当前pod集合
@@ -1112,6 +1180,12 @@ func (c *ServiceChecker) checkPendingPods(ctx context.Context, namespace string,
 
 // calculateConcurrency 根据pod数量计算合理的并发数
 func (c *ServiceChecker) calculateConcurrency(podCount int) int {
+	if c.maxConcurrency > 0 {
+		if podCount < c.maxConcurrency {
+			return podCount
+		}
+		return c.maxConcurrency
+	}
 	if podCount <= 20 {
 		return podCount // 20个以下：全并发
 	} else if podCount <= 100 {
@@ -1165,8 +1239,8 @@ func (c *ServiceChecker) checkPendingPodsWithConcurrency(ctx context.Context, na
 // isPodRunning 检查pod是否处于Running状态
 func (c *ServiceChecker) isPodRunning(ctx context.Context, namespace, podName string) bool {
 	cmdArgs := []string{"get", "pod", "-n", namespace, podName, "-o", "jsonpath={.status.phase}"}
-	cmd := exec.CommandContext(ctx, "kubectl", cmdArgs...)
-	output, err := cmd.CombinedOutput()
+	cmd := common.NewCommand("kubectl", cmdArgs...)
+	output, err := common.RunCommand(ctx, cmd)
 	if err != nil {
 		return false
 	}
@@ -1180,16 +1254,17 @@ func (c *ServiceChecker) checkSinglePodHealth(ctx context.Context, namespace, po
 	defer cancel()
 
 	// 根据项目判断是否使用filebeat容器
+	healthURL := "http://127.0.0.1:8080" + c.healthPath
 	var cmdArgs []string
 	if c.needFilebeat() {
 		// 默认使用filebeat容器
-		cmdArgs = []string{"exec", "-n", namespace, podName, "-c", "filebeat", "--", "curl", "-s", "http://127.0.0.1:8080/actuator/health"}
+		cmdArgs = []string{"exec", "-n", namespace, podName, "-c", "filebeat", "--", "curl", "-s", healthURL}
 	} else {
 		// 某些项目只有一个容器，不需要指定容器名
-		cmdArgs = []string{"exec", "-n", namespace, podName, "--", "curl", "-s", "http://127.0.0.1:8080/actuator/health"}
+		cmdArgs = []string{"exec", "-n", namespace, podName, "--", "curl", "-s", healthURL}
 	}
-	cmd := exec.CommandContext(cmdCtx, "kubectl", cmdArgs...)
-	output, err := cmd.CombinedOutput()
+	cmd := common.NewCommand("kubectl", cmdArgs...)
+	output, err := common.RunCommand(cmdCtx, cmd)
 
 	if err != nil {
 		return fmt.Errorf("健康检查命令执行失败: %v", err)
@@ -1258,9 +1333,9 @@ func (c *ServiceChecker) checkSingleServiceReady(ctx context.Context, namespace,
 
 // checkPodStatus 检查pod状态
 func (c *ServiceChecker) checkPodStatus(ctx context.Context, namespace, podName string) error {
-	cmd := exec.CommandContext(ctx, "kubectl", "get", "pod", "-n", namespace, podName, "-o", "jsonpath={.status.phase}")
+	cmd := common.NewCommand("kubectl", "get", "pod", "-n", namespace, podName, "-o", "jsonpath={.status.phase}")
 
-	output, err := cmd.CombinedOutput()
+	output, err := common.RunCommand(ctx, cmd)
 	if err != nil {
 		return fmt.Errorf("获取pod状态失败: %v, 输出: %s", err, string(output))
 	}
@@ -1297,9 +1372,9 @@ func (c *ServiceChecker) getPodName(ctx context.Context, namespace, serviceName
 		}
 
 		for _, selector := range selectors {
-			cmd := exec.CommandContext(ctx, "kubectl", "get", "pods", "-n", namespace, "-l", selector, "-o", "jsonpath={.items[0].metadata.name}")
+			cmd := common.NewCommand("kubectl", "get", "pods", "-n", namespace, "-l", selector, "-o", "jsonpath={.items[0].metadata.name}")
 
-			output, err := cmd.CombinedOutput()
+			output, err := common.RunCommand(ctx, cmd)
 			if err == nil {
 				podName := strings.TrimSpace(string(output))
 				if podName != "" {
@@ -0,0 +1,195 @@
+package perfGate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cicd-agent/common"
+	"cicd-agent/config"
+)
+
+// Report 压测结果
+type Report struct {
+	Requests  int           // 完成的请求数
+	Errors    int           // 失败的请求数（请求失败或状态码>=400）
+	ErrorRate float64       // 错误率
+	RPS       float64       // 实际达到的每秒请求数
+	P50       time.Duration // 延迟分位数（包含失败的请求）
+	P95       time.Duration
+	P99       time.Duration
+	Max       time.Duration
+}
+
+// String 压测结果摘要
+func (r Report) String() string {
+	return fmt.Sprintf("请求 %d，错误 %d（%.2f%%），实际RPS %.1f，P50 %s，P95 %s，P99 %s，最大 %s",
+		r.Requests, r.Errors, r.ErrorRate*100, r.RPS, r.P50, r.P95, r.P99, r.Max)
+}
+
+// LoadProber 性能门禁压测器
+type LoadProber struct {
+	taskID     string
+	taskLogger *common.TaskLogger
+}
+
+// NewLoadProber 创建压测器
+func NewLoadProber(taskID string, taskLogger *common.TaskLogger) *LoadProber {
+	return &LoadProber{
+		taskID:     taskID,
+		taskLogger: taskLogger,
+	}
+}
+
+// Run 以配置的速率压测新版本（baseURL为已解析的地址前缀），P95延迟或错误率超过阈值时返回错误
+func (p *LoadProber) Run(ctx context.Context, project, tag, baseURL string) (Report, error) {
+	projectConfig, ok := config.AppConfig.GetPerfGateProject(project)
+	if !ok {
+		return Report{}, fmt.Errorf("项目 %s 未配置性能门禁", project)
+	}
+
+	requestURL := strings.ReplaceAll(projectConfig.URL, "{tag}", tag)
+	if strings.HasPrefix(requestURL, "/") {
+		requestURL = baseURL + requestURL
+	}
+	var body []byte
+	if projectConfig.Body != "" {
+		body = []byte(strings.ReplaceAll(projectConfig.Body, "{tag}", tag))
+	}
+	request := common.HTTPRequest{
+		Method:  projectConfig.Method,
+		URL:     requestURL,
+		Body:    body,
+		Header:  projectConfig.Headers,
+		Timeout: projectConfig.GetTimeout(),
+		NoRetry: true,
+	}
+
+	duration := projectConfig.GetDuration()
+	p.log("INFO", fmt.Sprintf("开始压测 %s %s：%d RPS，持续 %s，最大并发 %d，阈值 P95<=%s、错误率<=%.2f%%",
+		request.Method, requestURL, projectConfig.RPS, duration, projectConfig.Concurrency, projectConfig.GetMaxP95(), projectConfig.MaxErrorRate*100))
+
+	report, err := p.load(ctx, request, projectConfig.RPS, projectConfig.Concurrency, duration)
+	if err != nil {
+		return report, err
+	}
+	p.log("INFO", fmt.Sprintf("压测完成：%s", report))
+
+	var violations []string
+	if report.Requests == 0 {
+		violations = append(violations, "没有完成的请求")
+	}
+	if report.P95 > projectConfig.GetMaxP95() {
+		violations = append(violations, fmt.Sprintf("P95延迟 %s 超过 %s", report.P95, projectConfig.GetMaxP95()))
+	}
+	if report.ErrorRate > projectConfig.MaxErrorRate {
+		violations = append(violations, fmt.Sprintf("错误率 %.2f%% 超过 %.2f%%", report.ErrorRate*100, projectConfig.MaxErrorRate*100))
+	}
+	if len(violations) > 0 {
+		return report, fmt.Errorf("未通过性能门禁: %s", strings.Join(violations, "，"))
+	}
+	return report, nil
+}
+
+// load 按固定间隔发出请求（并发达到上限时等待，实际RPS会低于目标），统计延迟和错误
+func (p *LoadProber) load(ctx context.Context, request common.HTTPRequest, rps, concurrency int, duration time.Duration) (Report, error) {
+	var (
+		mu         sync.Mutex
+		latencies  []time.Duration
+		errorCount int
+		firstErr   string
+		wg         sync.WaitGroup
+	)
+	semaphore := make(chan struct{}, concurrency)
+	ticker := time.NewTicker(time.Second / time.Duration(rps))
+	defer ticker.Stop()
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	start := time.Now()
+	sendRequest := func() {
+		defer wg.Done()
+		defer func() { <-semaphore }()
+
+		begin := time.Now()
+		resp, err := common.DoHTTP(ctx, request)
+		latency := time.Since(begin)
+		if ctx.Err() != nil {
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		latencies = append(latencies, latency)
+		if err != nil || resp.StatusCode >= 400 {
+			errorCount++
+			if firstErr == "" {
+				if err != nil {
+					firstErr = err.Error()
+				} else {
+					firstErr = fmt.Sprintf("状态码 %d", resp.StatusCode)
+				}
+			}
+		}
+	}
+
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-timer.C:
+			break loop
+		case <-ticker.C:
+			select {
+			case semaphore <- struct{}{}:
+			case <-ctx.Done():
+				break loop
+			case <-timer.C:
+				break loop
+			}
+			wg.Add(1)
+			go sendRequest()
+		}
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return Report{}, ctx.Err()
+	}
+	elapsed := time.Since(start)
+
+	if firstErr != "" {
+		p.log("WARNING", fmt.Sprintf("压测中有 %d 个请求失败，首个错误: %s", errorCount, firstErr))
+	}
+	return summarize(latencies, errorCount, elapsed), nil
+}
+
+// summarize 计算错误率、实际RPS和延迟分位数
+func summarize(latencies []time.Duration, errors int, elapsed time.Duration) Report {
+	report := Report{Requests: len(latencies), Errors: errors}
+	if len(latencies) == 0 {
+		return report
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	percentile := func(q float64) time.Duration {
+		index := int(float64(len(latencies))*q+0.5) - 1
+		return latencies[max(0, min(index, len(latencies)-1))]
+	}
+	report.ErrorRate = float64(errors) / float64(len(latencies))
+	report.RPS = float64(len(latencies)) / elapsed.Seconds()
+	report.P50 = percentile(0.50)
+	report.P95 = percentile(0.95)
+	report.P99 = percentile(0.99)
+	report.Max = latencies[len(latencies)-1]
+	return report
+}
+
+// log 写入步骤日志
+func (p *LoadProber) log(level, message string) {
+	if p.taskLogger != nil {
+		p.taskLogger.WriteStep("perfGate", level, message)
+	}
+}
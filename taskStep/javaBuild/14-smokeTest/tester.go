@@ -0,0 +1,127 @@
+package smokeTest
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"cicd-agent/common"
+	"cicd-agent/config"
+)
+
+// maxLoggedBody 失败时写入日志的响应体最大长度
+const maxLoggedBody = 500
+
+// SmokeTester 冒烟测试执行器
+type SmokeTester struct {
+	taskID     string
+	taskLogger *common.TaskLogger
+}
+
+// NewSmokeTester 创建冒烟测试执行器
+func NewSmokeTester(taskID string, taskLogger *common.TaskLogger) *SmokeTester {
+	return &SmokeTester{
+		taskID:     taskID,
+		taskLogger: taskLogger,
+	}
+}
+
+// Run 对新版本（baseURL为已解析的测试地址前缀）依次执行项目的全部用例（失败的用例按配置重试），有用例失败时返回错误
+func (s *SmokeTester) Run(ctx context.Context, project, tag, baseURL string) error {
+	projectConfig, ok := config.AppConfig.GetSmokeTestProject(project)
+	if !ok {
+		return fmt.Errorf("项目 %s 未配置冒烟测试", project)
+	}
+	s.log("INFO", fmt.Sprintf("开始冒烟测试，共 %d 个用例，地址: %s", len(projectConfig.Cases), baseURL))
+
+	retryInterval := config.AppConfig.GetSmokeTestRetryInterval(project)
+	var failed []string
+	for _, testCase := range projectConfig.Cases {
+		var err error
+		for attempt := 0; attempt <= projectConfig.Retries; attempt++ {
+			if attempt > 0 {
+				s.log("WARNING", fmt.Sprintf("用例 %s 失败，%s 后第%d次重试: %v", testCase.Name, retryInterval, attempt, err))
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(retryInterval):
+				}
+			}
+			if err = s.runCase(ctx, baseURL, tag, testCase); err == nil || ctx.Err() != nil {
+				break
+			}
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			s.log("ERROR", fmt.Sprintf("用例 %s 失败: %v", testCase.Name, err))
+			failed = append(failed, testCase.Name)
+			continue
+		}
+		s.log("INFO", fmt.Sprintf("用例 %s 通过", testCase.Name))
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d/%d 个冒烟测试用例失败: %s", len(failed), len(projectConfig.Cases), strings.Join(failed, ", "))
+	}
+	s.log("INFO", fmt.Sprintf("全部 %d 个冒烟测试用例通过", len(projectConfig.Cases)))
+	return nil
+}
+
+// runCase 发送用例请求并校验状态码和响应体
+func (s *SmokeTester) runCase(ctx context.Context, baseURL, tag string, testCase config.SmokeTestCase) error {
+	requestURL := strings.ReplaceAll(testCase.URL, "{tag}", tag)
+	if strings.HasPrefix(requestURL, "/") {
+		requestURL = baseURL + requestURL
+	}
+	var body []byte
+	if testCase.Body != "" {
+		body = []byte(strings.ReplaceAll(testCase.Body, "{tag}", tag))
+	}
+
+	start := time.Now()
+	resp, err := common.DoHTTP(ctx, common.HTTPRequest{
+		Method:  testCase.Method,
+		URL:     requestURL,
+		Body:    body,
+		Header:  testCase.Headers,
+		Timeout: config.AppConfig.GetSmokeTestTimeout(),
+		NoRetry: true,
+	})
+	if err != nil {
+		return fmt.Errorf("%s %s 请求失败: %v", testCase.Method, requestURL, err)
+	}
+	s.log("INFO", fmt.Sprintf("%s %s -> %d（%dms）", testCase.Method, requestURL, resp.StatusCode, time.Since(start).Milliseconds()))
+
+	if resp.StatusCode != testCase.ExpectStatus {
+		return fmt.Errorf("状态码 %d，期望 %d，响应: %s", resp.StatusCode, testCase.ExpectStatus, truncate(string(resp.Body)))
+	}
+	if testCase.BodyRegex != "" {
+		matched, err := regexp.MatchString(testCase.BodyRegex, string(resp.Body))
+		if err != nil {
+			return fmt.Errorf("body_regex错误: %v", err)
+		}
+		if !matched {
+			return fmt.Errorf("响应体不匹配 %s，响应: %s", testCase.BodyRegex, truncate(string(resp.Body)))
+		}
+	}
+	return nil
+}
+
+// truncate 截断过长的响应体
+func truncate(body string) string {
+	if len(body) > maxLoggedBody {
+		return body[:maxLoggedBody] + "..."
+	}
+	return body
+}
+
+// log 写入步骤日志
+func (s *SmokeTester) log(level, message string) {
+	if s.taskLogger != nil {
+		s.taskLogger.WriteStep("smokeTest", level, message)
+	}
+}
@@ -0,0 +1,156 @@
+package cacheInvalidation
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"cicd-agent/common"
+	"cicd-agent/config"
+)
+
+// scanCount 每次SCAN建议返回的键数量
+const scanCount = 500
+
+// Result 缓存清理结果
+type Result struct {
+	Deleted   int64 // 删除的键数量
+	Receivers int64 // 收到失效消息的订阅者数量
+}
+
+// CacheInvalidator 部署后的缓存清理器
+type CacheInvalidator struct {
+	taskID     string
+	taskLogger *common.TaskLogger
+}
+
+// NewCacheInvalidator 创建缓存清理器
+func NewCacheInvalidator(taskID string, taskLogger *common.TaskLogger) *CacheInvalidator {
+	return &CacheInvalidator{
+		taskID:     taskID,
+		taskLogger: taskLogger,
+	}
+}
+
+// Invalidate 连接项目的Redis，删除匹配各模式的键并发布失效消息；namespace为读取Secret的默认namespace
+func (i *CacheInvalidator) Invalidate(ctx context.Context, project, tag, namespace string) (Result, error) {
+	var result Result
+	projectConfig, ok := config.AppConfig.GetRedisProject(project)
+	if !ok {
+		return result, fmt.Errorf("项目 %s 未配置缓存清理", project)
+	}
+	if err := i.loadSecret(ctx, &projectConfig, namespace); err != nil {
+		return result, err
+	}
+
+	redisClient, err := dial(ctx, projectConfig.Addr, projectConfig.Username, projectConfig.Password, projectConfig.DB, projectConfig.TLS, config.AppConfig.GetRedisTimeout())
+	if err != nil {
+		return result, err
+	}
+	defer redisClient.close()
+	i.log("INFO", fmt.Sprintf("已连接Redis %s（db %d）", projectConfig.Addr, projectConfig.DB))
+
+	for _, pattern := range projectConfig.Patterns {
+		pattern = strings.ReplaceAll(pattern, "{tag}", tag)
+		deleted, err := i.deletePattern(redisClient, pattern)
+		result.Deleted += deleted
+		if err != nil {
+			return result, fmt.Errorf("删除匹配 %s 的键失败: %v", pattern, err)
+		}
+		i.log("INFO", fmt.Sprintf("已删除匹配 %s 的键 %d 个", pattern, deleted))
+	}
+
+	if projectConfig.Channel != "" {
+		message := strings.ReplaceAll(projectConfig.Message, "{tag}", tag)
+		if message == "" {
+			data, _ := json.Marshal(map[string]string{"project": project, "tag": tag, "task_id": i.taskID})
+			message = string(data)
+		}
+		receivers, err := redisClient.publish(projectConfig.Channel, message)
+		if err != nil {
+			return result, fmt.Errorf("发布失效消息到 %s 失败: %v", projectConfig.Channel, err)
+		}
+		result.Receivers = receivers
+		i.log("INFO", fmt.Sprintf("已发布失效消息到 %s，订阅者 %d 个: %s", projectConfig.Channel, receivers, message))
+	}
+	return result, nil
+}
+
+// deletePattern 用SCAN遍历匹配的键并分批删除（不使用KEYS，避免阻塞Redis）
+func (i *CacheInvalidator) deletePattern(redisClient *client, pattern string) (int64, error) {
+	var deleted int64
+	cursor := "0"
+	for {
+		next, keys, err := redisClient.scan(cursor, pattern, scanCount)
+		if err != nil {
+			return deleted, err
+		}
+		if len(keys) > 0 {
+			count, err := redisClient.unlink(keys)
+			deleted += count
+			if err != nil {
+				return deleted, err
+			}
+		}
+		if next == "0" || next == "" {
+			return deleted, nil
+		}
+		cursor = next
+	}
+}
+
+// loadSecret 从Kubernetes Secret读取地址和凭据，覆盖配置中的值
+func (i *CacheInvalidator) loadSecret(ctx context.Context, projectConfig *config.RedisProjectConfig, namespace string) error {
+	secret := projectConfig.Secret
+	if secret.Name == "" {
+		return nil
+	}
+	if secret.Namespace != "" {
+		namespace = secret.Namespace
+	}
+
+	output, err := common.RunCommand(ctx, common.NewCommand("kubectl", "get", "secret", secret.Name, "-n", namespace, "-o", "json"))
+	if err != nil {
+		return fmt.Errorf("读取Secret %s/%s 失败: %v: %s", namespace, secret.Name, err, strings.TrimSpace(string(output)))
+	}
+	var data struct {
+		Data map[string]string `json:"data"`
+	}
+	if err := json.Unmarshal(output, &data); err != nil {
+		return fmt.Errorf("解析Secret %s/%s 失败: %v", namespace, secret.Name, err)
+	}
+
+	fields := []struct {
+		key    string
+		target *string
+	}{
+		{secret.AddrKey, &projectConfig.Addr},
+		{secret.UsernameKey, &projectConfig.Username},
+		{secret.PasswordKey, &projectConfig.Password},
+	}
+	for _, field := range fields {
+		if field.key == "" {
+			continue
+		}
+		encoded, ok := data.Data[field.key]
+		if !ok {
+			return fmt.Errorf("Secret %s/%s 中没有键 %s", namespace, secret.Name, field.key)
+		}
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("解码Secret %s/%s 的键 %s 失败: %v", namespace, secret.Name, field.key, err)
+		}
+		*field.target = strings.TrimSpace(string(value))
+	}
+	i.log("INFO", fmt.Sprintf("已从Secret %s/%s 读取Redis连接信息", namespace, secret.Name))
+	return nil
+}
+
+// log 写入步骤日志
+func (i *CacheInvalidator) log(level, message string) {
+	if i.taskLogger != nil {
+		i.taskLogger.WriteStep("cacheInvalidation", level, message)
+	}
+}
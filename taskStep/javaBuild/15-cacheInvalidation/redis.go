@@ -0,0 +1,176 @@
+package cacheInvalidation
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redisError Redis返回的错误回复
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+// client 最小的Redis客户端（RESP2协议），只实现清理缓存用到的命令
+type client struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+	stop    func() bool
+}
+
+// dial 连接Redis并完成认证和选库；ctx取消时关闭连接，使阻塞中的命令立即返回
+func dial(ctx context.Context, addr, username, password string, db int, useTLS bool, timeout time.Duration) (*client, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	var err error
+	if useTLS {
+		host, _, _ := net.SplitHostPort(addr)
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host}}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("连接Redis %s 失败: %v", addr, err)
+	}
+
+	c := &client{conn: conn, reader: bufio.NewReader(conn), timeout: timeout}
+	c.stop = context.AfterFunc(ctx, func() { conn.Close() })
+
+	if password != "" {
+		args := []string{"AUTH", password}
+		if username != "" {
+			args = []string{"AUTH", username, password}
+		}
+		if _, err := c.do(args...); err != nil {
+			c.close()
+			return nil, fmt.Errorf("Redis认证失败: %v", err)
+		}
+	}
+	if db != 0 {
+		if _, err := c.do("SELECT", strconv.Itoa(db)); err != nil {
+			c.close()
+			return nil, fmt.Errorf("选择Redis库 %d 失败: %v", db, err)
+		}
+	}
+	return c, nil
+}
+
+// close 关闭连接
+func (c *client) close() {
+	c.stop()
+	c.conn.Close()
+}
+
+// scan 执行一次SCAN，返回下一个游标和本批匹配的键
+func (c *client) scan(cursor, match string, count int) (string, []string, error) {
+	reply, err := c.do("SCAN", cursor, "MATCH", match, "COUNT", strconv.Itoa(count))
+	if err != nil {
+		return "", nil, err
+	}
+	parts, ok := reply.([]interface{})
+	if !ok || len(parts) != 2 {
+		return "", nil, fmt.Errorf("SCAN返回格式错误: %v", reply)
+	}
+	next, _ := parts[0].(string)
+	items, _ := parts[1].([]interface{})
+	keys := make([]string, 0, len(items))
+	for _, item := range items {
+		if key, ok := item.(string); ok {
+			keys = append(keys, key)
+		}
+	}
+	return next, keys, nil
+}
+
+// unlink 删除键（优先使用非阻塞的UNLINK，Redis 4以下使用DEL），返回实际删除的数量
+func (c *client) unlink(keys []string) (int64, error) {
+	reply, err := c.do(append([]string{"UNLINK"}, keys...)...)
+	if _, ok := err.(redisError); ok && strings.Contains(strings.ToLower(err.Error()), "unknown command") {
+		reply, err = c.do(append([]string{"DEL"}, keys...)...)
+	}
+	if err != nil {
+		return 0, err
+	}
+	deleted, _ := reply.(int64)
+	return deleted, nil
+}
+
+// publish 发布消息，返回收到消息的订阅者数量
+func (c *client) publish(channel, message string) (int64, error) {
+	reply, err := c.do("PUBLISH", channel, message)
+	if err != nil {
+		return 0, err
+	}
+	receivers, _ := reply.(int64)
+	return receivers, nil
+}
+
+// do 发送命令并读取回复
+func (c *client) do(args ...string) (interface{}, error) {
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&buf, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.conn, buf.String()); err != nil {
+		return nil, err
+	}
+	return c.readReply()
+}
+
+// readReply 读取一个RESP回复：简单字符串和批量字符串返回string，整数返回int64，数组返回[]interface{}，空值返回nil
+func (c *client) readReply() (interface{}, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("Redis回复为空")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(c.reader, data); err != nil {
+			return nil, err
+		}
+		return string(data[:size]), nil
+	case '*':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, size)
+		for i := range items {
+			if items[i], err = c.readReply(); err != nil {
+				if _, ok := err.(redisError); !ok {
+					return nil, err
+				}
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("无法识别的Redis回复: %q", line)
+}
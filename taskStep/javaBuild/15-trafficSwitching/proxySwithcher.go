@@ -1,13 +1,11 @@
 package trafficSwitching
 
 import (
-	"bytes"
 	"cicd-agent/common"
 	"cicd-agent/config"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"sync"
 	"time"
@@ -130,31 +128,22 @@ func (ps *ProxySwitcher) callProxySwitch(ctx context.Context, proxyURL string, t
 		ps.taskLogger.WriteStep("trafficSwitching", "INFO", fmt.Sprintf("请求参数: %s", string(jsonData)))
 	}
 
-	// 创建HTTP请求
-	req, err := http.NewRequestWithContext(ctx, "POST", switchURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return fmt.Errorf("创建HTTP请求失败: %v", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	// 发送请求（设置超时时间）
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-
 	if ps.taskLogger != nil {
 		ps.taskLogger.WriteStep("trafficSwitching", "INFO", "发送流量切换请求...")
 	}
 
-	resp, err := client.Do(req)
+	// 切换到指定版本是幂等的，网络错误时可安全重试；代理会先做后端健康检查，单次超时放宽到30秒
+	resp, err := common.DoHTTP(ctx, common.HTTPRequest{
+		Method:  http.MethodPost,
+		URL:     switchURL,
+		Body:    jsonData,
+		Header:  map[string]string{"Content-Type": "application/json"},
+		Timeout: 30 * time.Second,
+	})
 	if err != nil {
 		return fmt.Errorf("发送HTTP请求失败: %v", err)
 	}
-	defer resp.Body.Close()
-
-	// 读取响应体
-	respBody, _ := io.ReadAll(resp.Body)
+	respBody := resp.Body
 
 	if ps.taskLogger != nil {
 		ps.taskLogger.WriteStep("trafficSwitching", "INFO", fmt.Sprintf("响应状态码: %d", resp.StatusCode))
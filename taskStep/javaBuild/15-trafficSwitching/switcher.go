@@ -7,7 +7,6 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -18,14 +17,14 @@ type TrafficSwitcher struct {
 	namespace    string
 	serviceName  string
 	version      string
-	nginxConfDir string // nginx配置目录，默认 /etc/nginx/conf.d
+	nginxConfDir string // nginx配置目录，默认 /etc/nginx/conf.d（Windows为 C:\nginx\conf\conf.d）
 	taskLogger   *common.TaskLogger
 }
 
 // NewTrafficSwitcher 创建流量切换处理器
 func NewTrafficSwitcher(namespace, serviceName, version, nginxConfDir string, taskLogger *common.TaskLogger) *TrafficSwitcher {
 	if nginxConfDir == "" {
-		nginxConfDir = "/etc/nginx/conf.d"
+		nginxConfDir = common.DefaultNginxConfDir()
 	}
 	return &TrafficSwitcher{
 		namespace:    namespace,
@@ -124,8 +123,8 @@ func (ts *TrafficSwitcher) getGatewayLoadBalancerIP(ctx context.Context) (string
 		"-o", "jsonpath={.status.loadBalancer.ingress[0].ip}",
 	}
 
-	cmd := exec.CommandContext(ctx, "kubectl", cmdArgs...)
-	output, err := cmd.CombinedOutput()
+	cmd := common.NewCommand("kubectl", cmdArgs...)
+	output, err := common.RunCommand(ctx, cmd)
 
 	// 写入命令执行日志
 	if ts.taskLogger != nil {
@@ -144,7 +143,7 @@ func (ts *TrafficSwitcher) getGatewayLoadBalancerIP(ctx context.Context) (string
 	return ip, nil
 }
 
-// updateAllNginxConfigs 更新/etc/nginx/conf.d目录下所有配置文件
+// updateAllNginxConfigs 更新nginx配置目录下所有配置文件
 func (ts *TrafficSwitcher) updateAllNginxConfigs(gatewayIP string) error {
 	if ts.taskLogger != nil {
 		ts.taskLogger.WriteStep("trafficSwitching", "INFO", fmt.Sprintf("开始更新目录下所有Nginx配置文件: %s", ts.nginxConfDir))
@@ -343,7 +342,7 @@ func (ts *TrafficSwitcher) containsExpectedIP(content, expectedIP string) bool {
 // reloadNginxRemotely 通过SSH远程执行nginx重启命令（异步执行）
 func (ts *TrafficSwitcher) reloadNginxRemotely(ctx context.Context) error {
 	// SSH配置
-	sshKeyPath := "/root/.ssh/id_rsa"
+	sshKeyPath := common.DefaultSSHKeyPath()
 	sshUser := "root"
 
 	// 支持多个nginx服务器
@@ -377,17 +376,17 @@ func (ts *TrafficSwitcher) reloadNginxRemotely(ctx context.Context) error {
 				}
 
 				// 构建SSH命令，优化配置避免警告信息
-				sshCmd := exec.CommandContext(ctx, "ssh",
+				sshCmd := common.NewCommand("ssh",
 					"-i", sshKeyPath,
 					"-o", "StrictHostKeyChecking=no",
-					"-o", "UserKnownHostsFile=/dev/null",
+					"-o", "UserKnownHostsFile="+os.DevNull,
 					"-o", "ConnectTimeout=10",
 					"-o", "LogLevel=ERROR", // 减少SSH警告输出
 					fmt.Sprintf("%s@%s", sshUser, ip),
 					"nginx -s reload")
 
 				// 执行SSH命令
-				output, err := sshCmd.CombinedOutput()
+				output, err := common.RunCommand(ctx, sshCmd)
 				if err != nil {
 					errorMsg := fmt.Sprintf("SSH执行失败: %v, 输出: %s", err, string(output))
 					resultChan <- reloadResult{serverIP: ip, success: false, error: errorMsg}
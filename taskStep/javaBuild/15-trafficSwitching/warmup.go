@@ -0,0 +1,136 @@
+package trafficSwitching
+
+import (
+	"cicd-agent/common"
+	"cicd-agent/config"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// warmupResult 单个预热目标的请求统计
+type warmupResult struct {
+	requests  int
+	errors    int
+	latencies []time.Duration
+}
+
+// Warmer 流量切换前的预热器
+type Warmer struct {
+	taskLogger *common.TaskLogger
+}
+
+// NewWarmer 创建预热器
+func NewWarmer(taskLogger *common.TaskLogger) *Warmer {
+	return &Warmer{taskLogger: taskLogger}
+}
+
+// PodTargets 获取namespace中运行中pod的地址（http://podIP:port），selector为空时返回全部运行中的pod
+func PodTargets(ctx context.Context, namespace, selector string, port int) ([]string, error) {
+	args := []string{"get", "pods", "-n", namespace, "--field-selector=status.phase=Running",
+		"-o", `jsonpath={range .items[*]}{.status.podIP}{"\n"}{end}`}
+	if selector != "" {
+		args = append(args, "-l", selector)
+	}
+	output, err := common.RunCommand(ctx, common.NewCommand("kubectl", args...))
+	if err != nil {
+		return nil, fmt.Errorf("获取pod地址失败: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	var targets []string
+	for _, ip := range strings.Fields(string(output)) {
+		targets = append(targets, fmt.Sprintf("http://%s:%d", ip, port))
+	}
+	return targets, nil
+}
+
+// Run 在预热时长内对每个目标并发循环发送预热请求；请求失败只统计，不影响切换，只有ctx取消时返回错误
+func (w *Warmer) Run(ctx context.Context, project, tag string, targets []string) error {
+	projectConfig, ok := config.AppConfig.GetWarmupProject(project)
+	if !ok || len(targets) == 0 {
+		return nil
+	}
+	duration := projectConfig.GetDuration()
+	w.log("INFO", fmt.Sprintf("开始预热新版本：%d 个目标，每个目标并发 %d，持续 %s", len(targets), projectConfig.Concurrency, duration))
+
+	warmupCtx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+
+	results := make([]warmupResult, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		var mu sync.Mutex
+		for worker := 0; worker < projectConfig.Concurrency; worker++ {
+			wg.Add(1)
+			go func(result *warmupResult, target string, worker int) {
+				defer wg.Done()
+				for n := worker; warmupCtx.Err() == nil; n++ {
+					request := projectConfig.Requests[n%len(projectConfig.Requests)]
+					var body []byte
+					if request.Body != "" {
+						body = []byte(strings.ReplaceAll(request.Body, "{tag}", tag))
+					}
+					begin := time.Now()
+					resp, err := common.DoHTTP(warmupCtx, common.HTTPRequest{
+						Method:  request.Method,
+						URL:     target + strings.ReplaceAll(request.Path, "{tag}", tag),
+						Body:    body,
+						Header:  request.Headers,
+						Timeout: projectConfig.GetTimeout(),
+						NoRetry: true,
+					})
+					if warmupCtx.Err() != nil {
+						return
+					}
+					mu.Lock()
+					result.requests++
+					result.latencies = append(result.latencies, time.Since(begin))
+					if err != nil || resp.StatusCode >= 500 {
+						result.errors++
+					}
+					mu.Unlock()
+				}
+			}(&results[i], target, worker)
+		}
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	for i, target := range targets {
+		result := results[i]
+		w.log("INFO", fmt.Sprintf("预热 %s：请求 %d，错误 %d，平均延迟 %s -> %s", target, result.requests, result.errors,
+			averageLatency(result.latencies, true), averageLatency(result.latencies, false)))
+		if result.requests > 0 && result.errors == result.requests {
+			w.log("WARNING", fmt.Sprintf("预热 %s 的请求全部失败，请检查预热配置", target))
+		}
+	}
+	w.log("INFO", "预热完成")
+	return nil
+}
+
+// averageLatency 前（first为true）或后10%请求的平均延迟，用于对比预热效果
+func averageLatency(latencies []time.Duration, first bool) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+	count := max(1, len(latencies)/10)
+	window := latencies[len(latencies)-count:]
+	if first {
+		window = latencies[:count]
+	}
+	var total time.Duration
+	for _, latency := range window {
+		total += latency
+	}
+	return (total / time.Duration(count)).Round(100 * time.Microsecond)
+}
+
+// log 写入步骤日志
+func (w *Warmer) log(level, message string) {
+	if w.taskLogger != nil {
+		w.taskLogger.WriteStep("trafficSwitching", level, message)
+	}
+}
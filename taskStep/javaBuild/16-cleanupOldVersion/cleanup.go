@@ -5,15 +5,20 @@ import (
 	"cicd-agent/taskStep"
 	"context"
 	"fmt"
-	"os/exec"
+	"os"
 	"strings"
 	"time"
 )
 
+// defaultStableWait 清理前等待新版本稳定运行的默认时长
+const defaultStableWait = 55 * time.Second
+
 // VersionCleaner 版本清理处理器
 type VersionCleaner struct {
-	targetNamespace     string // 要删除的目标namespace
-	targetDeploymentDir string // 要删除的目标部署目录
+	targetNamespace     string        // 要删除的目标namespace
+	targetDeploymentDir string        // 要删除的目标部署目录
+	stableWait          time.Duration // 清理前的等待时长，0表示立即清理
+	drainer             *Drainer      // 缩容前排空连接，为nil时直接缩容
 	taskLogger          *common.TaskLogger
 }
 
@@ -22,10 +27,23 @@ func NewVersionCleaner(targetNamespace, targetDeploymentDir string, taskLogger *
 	return &VersionCleaner{
 		targetNamespace:     targetNamespace,
 		targetDeploymentDir: targetDeploymentDir,
+		stableWait:          defaultStableWait,
 		taskLogger:          taskLogger,
 	}
 }
 
+// SetStableWait 设置清理前等待新版本稳定运行的时长，0表示立即清理
+func (vc *VersionCleaner) SetStableWait(wait time.Duration) {
+	if wait >= 0 {
+		vc.stableWait = wait
+	}
+}
+
+// SetDrainer 设置缩容前的连接排空
+func (vc *VersionCleaner) SetDrainer(drainer *Drainer) {
+	vc.drainer = drainer
+}
+
 // Execute 执行版本清理
 func (vc *VersionCleaner) Execute(ctx context.Context, step taskStep.Step) error {
 	if vc.taskLogger != nil {
@@ -33,16 +51,18 @@ func (vc *VersionCleaner) Execute(ctx context.Context, step taskStep.Step) error
 			vc.targetNamespace, vc.targetDeploymentDir))
 	}
 
-	// 等待55秒让新版本稳定运行
-	if vc.taskLogger != nil {
-		vc.taskLogger.WriteStep("cleanupOldVersion", "INFO", "等待55秒让新版本稳定运行...")
-	}
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case <-time.After(55 * time.Second):
+	// 等待新版本稳定运行
+	if vc.stableWait > 0 {
 		if vc.taskLogger != nil {
-			vc.taskLogger.WriteStep("cleanupOldVersion", "INFO", "等待55秒完成，开始清理旧版本")
+			vc.taskLogger.WriteStep("cleanupOldVersion", "INFO", fmt.Sprintf("等待%s让新版本稳定运行...", vc.stableWait))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(vc.stableWait):
+			if vc.taskLogger != nil {
+				vc.taskLogger.WriteStep("cleanupOldVersion", "INFO", fmt.Sprintf("等待%s完成，开始清理旧版本", vc.stableWait))
+			}
 		}
 	}
 
@@ -54,6 +74,13 @@ func (vc *VersionCleaner) Execute(ctx context.Context, step taskStep.Step) error
 		return nil
 	}
 
+	// 缩容之前排空旧版本的连接
+	if vc.drainer != nil {
+		if err := vc.drainer.Drain(ctx); err != nil {
+			return err
+		}
+	}
+
 	// 缩容旧版本部署到0副本
 	if err := vc.scaleDeploymentToZero(ctx); err != nil {
 		return fmt.Errorf("缩容旧版本部署失败: %v", err)
@@ -67,9 +94,8 @@ func (vc *VersionCleaner) Execute(ctx context.Context, step taskStep.Step) error
 
 // deploymentDirExists 检查部署目录是否存在
 func (vc *VersionCleaner) deploymentDirExists(dir string) bool {
-	cmd := exec.Command("ls", "-d", dir)
-	err := cmd.Run()
-	return err == nil
+	info, err := os.Stat(dir)
+	return err == nil && info.IsDir()
 }
 
 // scaleDeploymentToZero 将namespace下所有deployment缩容到0副本
@@ -111,8 +137,8 @@ func (vc *VersionCleaner) scaleDeploymentToZero(ctx context.Context) error {
 
 // getDeploymentsInNamespace 获取指定namespace下所有deployment名称
 func (vc *VersionCleaner) getDeploymentsInNamespace(ctx context.Context) ([]string, error) {
-	cmd := exec.CommandContext(ctx, "kubectl", "get", "deployment", "-n", vc.targetNamespace, "-o", "jsonpath={.items[*].metadata.name}")
-	output, err := cmd.CombinedOutput()
+	cmd := common.NewCommand("kubectl", "get", "deployment", "-n", vc.targetNamespace, "-o", "jsonpath={.items[*].metadata.name}")
+	output, err := common.RunCommand(ctx, cmd)
 
 	// 写入命令执行日志
 	if vc.taskLogger != nil {
@@ -139,10 +165,10 @@ func (vc *VersionCleaner) scaleDeployment(ctx context.Context, deploymentName st
 	}
 
 	// 执行kubectl scale命令
-	cmd := exec.CommandContext(ctx, "kubectl", "scale", "deployment", deploymentName,
+	cmd := common.NewCommand("kubectl", "scale", "deployment", deploymentName,
 		"-n", vc.targetNamespace,
 		"--replicas="+fmt.Sprintf("%d", replicas))
-	output, err := cmd.CombinedOutput()
+	output, err := common.RunCommand(ctx, cmd)
 
 	// 写入命令执行日志
 	if vc.taskLogger != nil {
@@ -203,8 +229,8 @@ func (vc *VersionCleaner) waitForResourcesDeletion(ctx context.Context, deployme
 // hasPodsInNamespace 检查指定namespace中是否还有pod
 func (vc *VersionCleaner) hasPodsInNamespace(ctx context.Context, namespace string) bool {
 	// 构建kubectl命令检查pod
-	cmd := exec.CommandContext(ctx, "kubectl", "get", "pods", "-n", namespace, "--no-headers", "-o", "name")
-	output, err := cmd.CombinedOutput()
+	cmd := common.NewCommand("kubectl", "get", "pods", "-n", namespace, "--no-headers", "-o", "name")
+	output, err := common.RunCommand(ctx, cmd)
 
 	// 写入命令执行日志
 	if vc.taskLogger != nil {
@@ -0,0 +1,196 @@
+package cleanupOldVersion
+
+import (
+	"cicd-agent/common"
+	"cicd-agent/config"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Drainer 缩容旧版本之前排空连接：标注pod、调用排空接口、等待网关在途连接数降为0
+// 排空是尽力而为的，各环节失败只记录警告，不阻止缩容；只有任务取消时返回错误
+type Drainer struct {
+	namespace      string // 旧版本namespace
+	connectionsURL string // 已解析的在途连接数查询地址，为空时按grace_period等待
+	drainConfig    config.DrainProjectConfig
+	taskLogger     *common.TaskLogger
+}
+
+// NewDrainer 创建连接排空器
+func NewDrainer(namespace, connectionsURL string, drainConfig config.DrainProjectConfig, taskLogger *common.TaskLogger) *Drainer {
+	return &Drainer{
+		namespace:      namespace,
+		connectionsURL: connectionsURL,
+		drainConfig:    drainConfig,
+		taskLogger:     taskLogger,
+	}
+}
+
+// Drain 执行排空
+func (d *Drainer) Drain(ctx context.Context) error {
+	d.log("INFO", fmt.Sprintf("开始排空旧版本 %s 的连接", d.namespace))
+
+	if d.drainConfig.Annotation != "" {
+		d.annotatePods(ctx)
+	}
+	if d.drainConfig.Endpoint != "" {
+		d.callDrainEndpoints(ctx)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if d.connectionsURL == "" {
+		if d.drainConfig.Endpoint == "" {
+			return nil
+		}
+		grace := d.drainConfig.GetGracePeriod()
+		d.log("INFO", fmt.Sprintf("等待%s让在途请求完成", grace))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(grace):
+		}
+		return nil
+	}
+	return d.waitConnections(ctx)
+}
+
+// annotatePods 为旧版本pod添加排空注解
+func (d *Drainer) annotatePods(ctx context.Context) {
+	args := []string{"annotate", "pods", "-n", d.namespace, d.drainConfig.Annotation, "--overwrite"}
+	if d.drainConfig.Selector != "" {
+		args = append(args, "-l", d.drainConfig.Selector)
+	} else {
+		args = append(args, "--all")
+	}
+	cmd := common.NewCommand("kubectl", args...)
+	output, err := common.RunCommand(ctx, cmd)
+	if d.taskLogger != nil {
+		d.taskLogger.WriteCommand("cleanupOldVersion", cmd.String(), output, err)
+	}
+	if err != nil {
+		d.log("WARNING", fmt.Sprintf("为旧版本pod添加注解失败: %v", err))
+		return
+	}
+	d.log("INFO", fmt.Sprintf("已为旧版本pod添加注解 %s", d.drainConfig.Annotation))
+}
+
+// callDrainEndpoints 并发调用每个旧版本pod的排空接口
+func (d *Drainer) callDrainEndpoints(ctx context.Context) {
+	args := []string{"get", "pods", "-n", d.namespace, "--field-selector=status.phase=Running",
+		"-o", `jsonpath={range .items[*]}{.metadata.name} {.status.podIP}{"\n"}{end}`}
+	if d.drainConfig.Selector != "" {
+		args = append(args, "-l", d.drainConfig.Selector)
+	}
+	output, err := common.RunCommand(ctx, common.NewCommand("kubectl", args...))
+	if err != nil {
+		d.log("WARNING", fmt.Sprintf("获取旧版本pod地址失败，跳过排空接口: %v: %s", err, strings.TrimSpace(string(output))))
+		return
+	}
+
+	var wg sync.WaitGroup
+	var drained, failed int
+	var mu sync.Mutex
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		wg.Add(1)
+		go func(pod, ip string) {
+			defer wg.Done()
+			url := fmt.Sprintf("http://%s:%d%s", ip, d.drainConfig.Port, d.drainConfig.Endpoint)
+			resp, err := common.DoHTTP(ctx, common.HTTPRequest{Method: d.drainConfig.Method, URL: url, NoRetry: true})
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil || resp.StatusCode >= 400 {
+				failed++
+				if err == nil {
+					err = fmt.Errorf("状态码 %d", resp.StatusCode)
+				}
+				d.log("WARNING", fmt.Sprintf("调用pod %s 的排空接口失败: %v", pod, err))
+				return
+			}
+			drained++
+		}(fields[0], fields[1])
+	}
+	wg.Wait()
+	d.log("INFO", fmt.Sprintf("已调用 %d 个旧版本pod的排空接口，失败 %d 个", drained, failed))
+}
+
+// waitConnections 轮询网关的在途连接数直到为0或超时（超时后继续缩容）
+func (d *Drainer) waitConnections(ctx context.Context) error {
+	pattern, err := regexp.Compile(d.drainConfig.ConnectionsPattern)
+	if err != nil {
+		d.log("WARNING", fmt.Sprintf("connections_pattern错误，跳过等待: %v", err))
+		return nil
+	}
+	timeout := d.drainConfig.GetTimeout()
+	deadline := time.Now().Add(timeout)
+	ticker := time.NewTicker(d.drainConfig.GetPollInterval())
+	defer ticker.Stop()
+
+	last := -1
+	for {
+		connections, err := d.queryConnections(ctx, pattern)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			d.log("WARNING", fmt.Sprintf("查询在途连接数失败: %v", err))
+		} else if connections == 0 {
+			d.log("INFO", "旧版本在途连接数已降为0")
+			return nil
+		} else if connections != last {
+			d.log("INFO", fmt.Sprintf("旧版本在途连接数: %d", connections))
+			last = connections
+		}
+
+		if time.Now().After(deadline) {
+			d.log("WARNING", fmt.Sprintf("等待在途连接数降为0超时(%s)，继续缩容", timeout))
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// queryConnections 查询并解析在途连接数（多处匹配时求和）
+func (d *Drainer) queryConnections(ctx context.Context, pattern *regexp.Regexp) (int, error) {
+	resp, err := common.DoHTTP(ctx, common.HTTPRequest{Method: "GET", URL: d.connectionsURL, NoRetry: true})
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != 200 {
+		return 0, fmt.Errorf("状态码 %d", resp.StatusCode)
+	}
+	matches := pattern.FindAllStringSubmatch(string(resp.Body), -1)
+	if len(matches) == 0 {
+		return 0, fmt.Errorf("响应中没有匹配 %s 的内容", pattern)
+	}
+	total := 0
+	for _, match := range matches {
+		value, err := strconv.ParseFloat(match[1], 64)
+		if err != nil {
+			return 0, fmt.Errorf("解析连接数 %q 失败: %v", match[1], err)
+		}
+		total += int(value)
+	}
+	return total, nil
+}
+
+// log 写入步骤日志
+func (d *Drainer) log(level, message string) {
+	if d.taskLogger != nil {
+		d.taskLogger.WriteStep("cleanupOldVersion", level, message)
+	}
+}
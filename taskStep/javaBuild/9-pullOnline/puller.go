@@ -4,17 +4,18 @@ import (
 	"bufio"
 	"context"
 	"fmt"
-	"os/exec"
 	"strings"
 	"sync"
 
 	"cicd-agent/common"
+	"cicd-agent/config"
 )
 
 // ImagePuller 镜像拉取器
 type ImagePuller struct {
-	taskID     string
-	taskLogger *common.TaskLogger
+	taskID         string
+	taskLogger     *common.TaskLogger
+	maxConcurrency int // 项目配置的并发上限，为0时使用全局配置
 }
 
 // NewImagePuller 创建镜像拉取器
@@ -25,6 +26,11 @@ func NewImagePuller(taskID string, taskLogger *common.TaskLogger) *ImagePuller {
 	}
 }
 
+// SetMaxConcurrency 设置项目的并发上限（覆盖全局配置）
+func (p *ImagePuller) SetMaxConcurrency(n int) {
+	p.maxConcurrency = n
+}
+
 // CleanProjectImages 清理指定项目的所有旧镜像（包括online和local harbor）
 func (p *ImagePuller) CleanProjectImages(ctx context.Context, projectName string) error {
 	if projectName == "" {
@@ -36,8 +42,8 @@ func (p *ImagePuller) CleanProjectImages(ctx context.Context, projectName string
 	}
 
 	// 获取所有本地镜像
-	cmd := exec.CommandContext(ctx, "docker", "images", "--format", "{{.Repository}}:{{.Tag}}")
-	output, err := cmd.Output()
+	cmd := common.NewCommand("docker", "images", "--format", "{{.Repository}}:{{.Tag}}")
+	output, err := common.RunCommand(ctx, cmd)
 	if err != nil {
 		if p.taskLogger != nil {
 			p.taskLogger.WriteStep("pullOnline", "ERROR", fmt.Sprintf("获取镜像列表失败: %v", err))
@@ -106,8 +112,8 @@ func (p *ImagePuller) deleteImages(ctx context.Context, images []string) error {
 			default:
 			}
 
-			cmd := exec.CommandContext(ctx, "docker", "rmi", "-f", image)
-			output, err := cmd.CombinedOutput()
+			cmd := common.NewCommand("docker", "rmi", "-f", image)
+			output, err := common.RunCommand(ctx, cmd)
 
 			if p.taskLogger != nil {
 				p.taskLogger.WriteCommand("pullOnline", "docker rmi -f "+image, output, err)
@@ -151,6 +157,9 @@ func (p *ImagePuller) PullImages(ctx context.Context, images []string) error {
 		p.taskLogger.WriteStep("pullOnline", "INFO", logMsg)
 	}
 
+	// 按配置限制镜像传输带宽
+	defer common.AcquireBandwidthLimit(ctx, p.taskLogger, "pullOnline")()
+
 	semaphore := make(chan struct{}, maxConcurrency)
 	var wg sync.WaitGroup
 	errChan := make(chan error, len(images))
@@ -204,13 +213,15 @@ func (p *ImagePuller) pullSingleImage(ctx context.Context, image string) error {
 		p.taskLogger.WriteStep("pullOnline", "INFO", fmt.Sprintf("开始拉取镜像: %s", image))
 	}
 
-	cmd := exec.CommandContext(ctx, "docker", "pull", image)
-	output, err := cmd.CombinedOutput()
-
-	// 写入命令执行日志
-	if p.taskLogger != nil {
-		p.taskLogger.WriteCommand("pullOnline", "docker pull "+image, output, err)
+	// 所有任务共享全局并发名额，避免多个任务同时拉取压垮主机
+	release, err := common.AcquireOperation(ctx, common.OperationPull)
+	if err != nil {
+		return fmt.Errorf("等待拉取镜像 %s 的执行名额被取消", image)
 	}
+	defer release()
+
+	// 拉取进度实时写入任务日志
+	err = p.taskLogger.StreamCommand(ctx, "pullOnline", image, common.NewCommand("docker", "pull", image))
 
 	if err != nil {
 		// 检查是否是上下文取消导致的错误
@@ -228,8 +239,11 @@ func (p *ImagePuller) pullSingleImage(ctx context.Context, image string) error {
 
 // calculatePullConcurrency 计算拉取并发数
 func (p *ImagePuller) calculatePullConcurrency(imageCount int) int {
-	// 直接根据服务数量设置线程数，最大不超过20个线程
-	const maxConcurrency = 20
+	// 直接根据服务数量设置线程数，最大不超过配置的并发数（默认20）
+	maxConcurrency := config.AppConfig.GetMaxParallelPulls()
+	if p.maxConcurrency > 0 {
+		maxConcurrency = p.maxConcurrency
+	}
 	const minConcurrency = 1
 
 	// 如果服务数量小于等于最大并发数，使用服务数量作为并发数
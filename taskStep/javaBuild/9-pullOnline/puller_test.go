@@ -0,0 +1,101 @@
+package pullOnline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"cicd-agent/common"
+	"cicd-agent/config"
+)
+
+func TestCleanProjectImages(t *testing.T) {
+	const images = "harbor.online/demo/app-a:v1\n" +
+		"<none>:<none>\n" +
+		"harbor.local/demo/app-b:v2\n" +
+		"harbor.online/demo-web/app-c:v1\n" +
+		"harbor.online/other/app-d:v1\n"
+
+	tests := []struct {
+		name      string
+		project   string
+		listErr   error
+		rmiErr    error
+		wantErr   string
+		wantCalls []string
+	}{
+		{
+			name:    "只删除当前项目镜像",
+			project: "demo",
+			wantCalls: []string{
+				"docker images --format {{.Repository}}:{{.Tag}}",
+				"docker rmi -f harbor.local/demo/app-b:v2",
+				"docker rmi -f harbor.online/demo/app-a:v1",
+			},
+		},
+		{
+			name:    "删除失败不中断",
+			project: "demo",
+			rmiErr:  errors.New("exit status 1"),
+			wantCalls: []string{
+				"docker images --format {{.Repository}}:{{.Tag}}",
+				"docker rmi -f harbor.local/demo/app-b:v2",
+				"docker rmi -f harbor.online/demo/app-a:v1",
+			},
+		},
+		{
+			name:      "没有匹配镜像",
+			project:   "missing",
+			wantCalls: []string{"docker images --format {{.Repository}}:{{.Tag}}"},
+		},
+		{
+			name:      "获取镜像列表失败",
+			project:   "demo",
+			listErr:   errors.New("exit status 1"),
+			wantErr:   "获取镜像列表失败",
+			wantCalls: []string{"docker images --format {{.Repository}}:{{.Tag}}"},
+		},
+		{
+			name:    "项目名称为空",
+			wantErr: "项目名称为空",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.AppConfig = &config.Config{}
+			fake := common.NewFakeRunner().
+				On("docker images", images, tt.listErr).
+				On("docker rmi", "", tt.rmiErr)
+			previous := common.SetCommandRunner(fake)
+			defer common.SetCommandRunner(previous)
+
+			err := NewImagePuller("task-1", nil).CleanProjectImages(context.Background(), tt.project)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("CleanProjectImages: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+
+			calls := fake.Calls()
+			var got []string
+			for _, call := range calls {
+				got = append(got, call.String())
+			}
+			// 先列出镜像，再并发删除（删除顺序不固定）
+			if len(got) > 0 && got[0] != "docker images --format {{.Repository}}:{{.Tag}}" {
+				t.Errorf("第一条命令 = %q，应先列出镜像", got[0])
+			}
+			if len(got) > 1 {
+				sort.Strings(got[1:])
+			}
+			if !reflect.DeepEqual(got, tt.wantCalls) {
+				t.Errorf("calls = %q, want %q", got, tt.wantCalls)
+			}
+		})
+	}
+}
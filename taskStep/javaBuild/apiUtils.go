@@ -3,9 +3,9 @@ package javaBuild
 import (
 	"cicd-agent/common"
 	"cicd-agent/config"
+	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 )
@@ -126,11 +126,34 @@ func getDeploymentPath(project string, mode string, taskLogger *common.TaskLogge
 
 // namespaceExists 检查namespace是否存在
 func namespaceExists(namespace string) bool {
-	cmd := exec.Command("kubectl", "get", "namespace", namespace)
-	err := cmd.Run()
+	cmd := common.NewCommand("kubectl", "get", "namespace", namespace)
+	_, err := common.RunCommand(context.Background(), cmd)
 	return err == nil
 }
 
+// resolveGatewayURL 替换地址中的{namespace}和{gateway}（namespace中项目网关Service的LoadBalancer地址，与流量切换使用的地址一致）
+func resolveGatewayURL(ctx context.Context, project, namespace, baseURL string, taskLogger *common.TaskLogger, stepName string) (string, error) {
+	baseURL = strings.TrimSuffix(strings.ReplaceAll(baseURL, "{namespace}", namespace), "/")
+	if !strings.Contains(baseURL, "{gateway}") {
+		return baseURL, nil
+	}
+
+	cmd := common.NewCommand("kubectl", "get", "svc", fmt.Sprintf("%s-gateway", project), "-n", namespace,
+		"-o", "jsonpath={.status.loadBalancer.ingress[0].ip}")
+	output, err := common.RunCommand(ctx, cmd)
+	if taskLogger != nil {
+		taskLogger.WriteCommand(stepName, cmd.String(), output, err)
+	}
+	if err != nil {
+		return "", fmt.Errorf("获取网关地址失败: %v", err)
+	}
+	gateway := strings.TrimSpace(string(output))
+	if gateway == "" {
+		return "", fmt.Errorf("网关 %s/%s-gateway 没有LoadBalancer地址", namespace, project)
+	}
+	return strings.ReplaceAll(baseURL, "{gateway}", gateway), nil
+}
+
 // getOnlineImages 获取在线镜像列表
 func getOnlineImages(project, tag string, taskLogger *common.TaskLogger, stepName string) ([]string, error) {
 	services, err := getServices(project, taskLogger, stepName)
@@ -236,9 +259,7 @@ func getServices(project string, taskLogger *common.TaskLogger, stepName string)
 	return getServiceList(project, taskLogger, stepName)
 }
 
-// getNginxConfDir 获取nginx配置目录
+// getNginxConfDir 获取nginx配置目录（按运行平台取默认路径）
 func getNginxConfDir() string {
-	// 可以从配置文件或环境变量获取
-	// 暂时使用默认路径
-	return "/etc/nginx/conf.d"
+	return common.DefaultNginxConfDir()
 }
@@ -0,0 +1,54 @@
+package javaBuild
+
+import (
+	"context"
+	"fmt"
+
+	"cicd-agent/common"
+	apolloRelease "cicd-agent/taskStep/javaBuild/12-apolloRelease"
+)
+
+// runApolloRelease 修改并发布Apollo命名空间（应用服务部署之前），返回修改前的原值供任务失败时回滚
+// 发布中途失败时同样返回已修改部分的备份
+func runApolloRelease(ctx context.Context, taskID, project, tag string, taskLogger *common.TaskLogger, onCancel func()) (*apolloRelease.Backup, error) {
+	stepName := "发布Apollo配置"
+	common.SendStepNotification(taskID, 12, "apolloRelease", stepName, "start", "开始发布Apollo配置", project, tag)
+	common.AppLogger.Info("执行步骤12：发布Apollo配置")
+
+	backup, err := apolloRelease.NewConfigReleaser(taskID, taskLogger).Release(ctx, project, tag)
+	if err != nil {
+		if ctx.Err() == context.Canceled {
+			common.SendStepNotification(taskID, 12, "apolloRelease", stepName, "cancel", "取消发布Apollo配置", project, tag)
+			onCancel()
+			return backup, ctx.Err()
+		}
+		if taskLogger != nil {
+			taskLogger.WriteStep("apolloRelease", "ERROR", fmt.Sprintf("发布Apollo配置失败: %v", err))
+		}
+		common.SendStepNotification(taskID, 12, "apolloRelease", stepName, "failed", fmt.Sprintf("发布Apollo配置失败: %v", err), project, tag)
+		return backup, err
+	}
+
+	message := fmt.Sprintf("已发布 %d 个命名空间", len(backup.Namespaces))
+	if len(backup.Namespaces) == 0 {
+		message = "配置没有变化"
+	}
+	common.SendStepNotification(taskID, 12, "apolloRelease", stepName, "success", message, project, tag)
+	common.AppLogger.Info("步骤12完成：发布Apollo配置")
+	return backup, nil
+}
+
+// rollbackApollo 任务失败或取消后回滚本次发布的Apollo配置（任务可能已取消，使用独立的ctx）
+func rollbackApollo(taskID, project string, backup *apolloRelease.Backup, taskLogger *common.TaskLogger) {
+	if backup == nil || len(backup.Namespaces) == 0 {
+		return
+	}
+	common.AppLogger.Info(fmt.Sprintf("任务未成功，回滚Apollo配置: 任务ID=%s, 项目=%s", taskID, project))
+	if err := apolloRelease.NewConfigReleaser(taskID, taskLogger).Rollback(context.Background(), backup); err != nil {
+		common.AppLogger.Error(fmt.Sprintf("回滚Apollo配置失败: 任务ID=%s, 错误=%v", taskID, err))
+		return
+	}
+	if taskLogger != nil {
+		taskLogger.WriteStep("apolloRelease", "INFO", fmt.Sprintf("任务未成功，已回滚 %d 个Apollo命名空间", len(backup.Namespaces)))
+	}
+}
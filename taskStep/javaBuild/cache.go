@@ -0,0 +1,46 @@
+package javaBuild
+
+import (
+	"context"
+	"fmt"
+
+	"cicd-agent/common"
+	"cicd-agent/config"
+	cacheInvalidation "cicd-agent/taskStep/javaBuild/15-cacheInvalidation"
+)
+
+// runCacheInvalidation 流量切换之后清理项目的Redis缓存；未配置required时清理失败只记录警告，不影响部署结果
+func runCacheInvalidation(ctx context.Context, taskID, project, tag string, taskLogger *common.TaskLogger, onCancel func()) error {
+	stepName := "清理缓存"
+	common.SendStepNotification(taskID, 15, "cacheInvalidation", stepName, "start", "开始清理缓存", project, tag)
+	common.AppLogger.Info("执行步骤15：清理缓存")
+
+	// Secret默认从切换后承载流量的namespace读取
+	namespace := getNamespace(project, "now", taskLogger, "cacheInvalidation")
+	result, err := cacheInvalidation.NewCacheInvalidator(taskID, taskLogger).Invalidate(ctx, project, tag, namespace)
+	if err != nil {
+		if ctx.Err() == context.Canceled {
+			common.SendStepNotification(taskID, 15, "cacheInvalidation", stepName, "cancel", "取消清理缓存", project, tag)
+			onCancel()
+			return ctx.Err()
+		}
+		projectConfig, _ := config.AppConfig.GetRedisProject(project)
+		if projectConfig.Required {
+			if taskLogger != nil {
+				taskLogger.WriteStep("cacheInvalidation", "ERROR", fmt.Sprintf("清理缓存失败: %v", err))
+			}
+			common.SendStepNotification(taskID, 15, "cacheInvalidation", stepName, "failed", fmt.Sprintf("清理缓存失败: %v", err), project, tag)
+			return err
+		}
+		if taskLogger != nil {
+			taskLogger.WriteStep("cacheInvalidation", "WARNING", fmt.Sprintf("清理缓存失败（不影响部署）: %v", err))
+		}
+		common.SendStepNotification(taskID, 15, "cacheInvalidation", stepName, "success", fmt.Sprintf("清理缓存失败（不影响部署）: %v", err), project, tag)
+		return nil
+	}
+
+	common.SendStepNotification(taskID, 15, "cacheInvalidation", stepName, "success",
+		fmt.Sprintf("已删除 %d 个键，失效消息订阅者 %d 个", result.Deleted, result.Receivers), project, tag)
+	common.AppLogger.Info("步骤15完成：清理缓存")
+	return nil
+}
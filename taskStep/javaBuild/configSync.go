@@ -0,0 +1,38 @@
+package javaBuild
+
+import (
+	"context"
+	"fmt"
+
+	"cicd-agent/common"
+	"cicd-agent/config"
+	configSync "cicd-agent/taskStep/javaBuild/12-configSync"
+)
+
+// runConfigSync 将项目的ConfigMap/Secret同步到目标namespace（应用服务部署和数据库迁移之前）
+func runConfigSync(ctx context.Context, taskID, project, tag string, taskLogger *common.TaskLogger, onCancel func()) error {
+	stepName := "同步ConfigMap/Secret"
+	common.SendStepNotification(taskID, 12, "configSync", stepName, "start", "开始同步ConfigMap/Secret", project, tag)
+	common.AppLogger.Info("执行步骤12：同步ConfigMap/Secret")
+
+	// 清单目录的相对路径基于项目部署目录
+	baseDir, _ := config.AppConfig.GetProjectPath(project)
+	namespace := getNamespace(project, "next", taskLogger, "configSync")
+	count, err := configSync.NewConfigSyncer(taskID, taskLogger).Sync(ctx, project, tag, namespace, baseDir)
+	if err != nil {
+		if ctx.Err() == context.Canceled {
+			common.SendStepNotification(taskID, 12, "configSync", stepName, "cancel", "取消同步ConfigMap/Secret", project, tag)
+			onCancel()
+			return ctx.Err()
+		}
+		if taskLogger != nil {
+			taskLogger.WriteStep("configSync", "ERROR", fmt.Sprintf("同步ConfigMap/Secret失败: %v", err))
+		}
+		common.SendStepNotification(taskID, 12, "configSync", stepName, "failed", fmt.Sprintf("同步ConfigMap/Secret失败: %v", err), project, tag)
+		return err
+	}
+
+	common.SendStepNotification(taskID, 12, "configSync", stepName, "success", fmt.Sprintf("已同步 %d 个资源到 %s", count, namespace), project, tag)
+	common.AppLogger.Info("步骤12完成：同步ConfigMap/Secret")
+	return nil
+}
@@ -0,0 +1,91 @@
+package javaBuild
+
+import (
+	"context"
+	"fmt"
+
+	"cicd-agent/common"
+	"cicd-agent/config"
+	gitopsHandoff "cicd-agent/taskStep/javaBuild/13-gitopsHandoff"
+)
+
+// gitopsSteps ArgoCD交接模式替换步骤13及之后步骤的步骤类型
+var gitopsSteps = []string{"gitopsCommit", "argocdSync"}
+
+// runGitOpsCommit 步骤13（交接模式）：更新GitOps仓库中的镜像标签并推送，返回推送后的提交
+func runGitOpsCommit(ctx context.Context, taskID, project, tag string, taskLogger *common.TaskLogger, onCancel func()) (string, error) {
+	stepName := "更新GitOps仓库"
+	common.SendStepNotification(taskID, 13, "gitopsCommit", stepName, "start", "开始更新GitOps仓库", project, tag)
+	common.AppLogger.Info("执行步骤13：更新GitOps仓库")
+
+	repoConfig, ok := config.AppConfig.GetGitOpsProject(project)
+	if !ok {
+		err := fmt.Errorf("项目 %s 未配置gitops", project)
+		if taskLogger != nil {
+			taskLogger.WriteStep("gitopsCommit", "ERROR", err.Error())
+		}
+		common.SendStepNotification(taskID, 13, "gitopsCommit", stepName, "failed", err.Error(), project, tag)
+		return "", err
+	}
+
+	revision, err := gitopsHandoff.NewRepoUpdater(taskID, taskLogger).UpdateImageTag(ctx, project, tag, repoConfig)
+	if err != nil {
+		if ctx.Err() == context.Canceled {
+			common.SendStepNotification(taskID, 13, "gitopsCommit", stepName, "cancel", "取消更新GitOps仓库", project, tag)
+			onCancel()
+			return "", ctx.Err()
+		}
+		if taskLogger != nil {
+			taskLogger.WriteStep("gitopsCommit", "ERROR", fmt.Sprintf("更新GitOps仓库失败: %v", err))
+		}
+		common.SendStepNotification(taskID, 13, "gitopsCommit", stepName, "failed", fmt.Sprintf("更新GitOps仓库失败: %v", err), project, tag)
+		return "", err
+	}
+
+	common.SendStepNotification(taskID, 13, "gitopsCommit", stepName, "success", fmt.Sprintf("已推送提交 %s", shortSHA(revision)), project, tag)
+	common.AppLogger.Info("步骤13完成：更新GitOps仓库")
+	return revision, nil
+}
+
+// runArgoCDSync 步骤14（交接模式）：等待ArgoCD Application同步到推送的提交并健康（单独重新执行时revision为空，不检查提交）
+func runArgoCDSync(ctx context.Context, taskID, project, tag, revision string, taskLogger *common.TaskLogger, onCancel func()) error {
+	stepName := "等待ArgoCD同步"
+	common.SendStepNotification(taskID, 14, "argocdSync", stepName, "start", "开始等待ArgoCD同步", project, tag)
+	common.AppLogger.Info("执行步骤14：等待ArgoCD同步")
+
+	repoConfig, ok := config.AppConfig.GetGitOpsProject(project)
+	if !ok {
+		err := fmt.Errorf("项目 %s 未配置gitops", project)
+		if taskLogger != nil {
+			taskLogger.WriteStep("argocdSync", "ERROR", err.Error())
+		}
+		common.SendStepNotification(taskID, 14, "argocdSync", stepName, "failed", err.Error(), project, tag)
+		return err
+	}
+
+	waiter := gitopsHandoff.NewApplicationWaiter(taskID, taskLogger)
+	if err := waiter.WaitSyncedAndHealthy(ctx, repoConfig.Application, repoConfig.AppNamespace, revision); err != nil {
+		if ctx.Err() == context.Canceled {
+			common.SendStepNotification(taskID, 14, "argocdSync", stepName, "cancel", "取消等待ArgoCD同步", project, tag)
+			onCancel()
+			return ctx.Err()
+		}
+		if taskLogger != nil {
+			taskLogger.WriteStep("argocdSync", "ERROR", err.Error())
+		}
+		common.SendStepNotification(taskID, 14, "argocdSync", stepName, "failed", err.Error(), project, tag)
+		return err
+	}
+
+	common.SendStepNotification(taskID, 14, "argocdSync", stepName, "success", fmt.Sprintf("Application %s 已同步且健康", repoConfig.Application), project, tag)
+	common.AppLogger.Info("步骤14完成：等待ArgoCD同步")
+	return nil
+}
+
+// shortSHA 提交的前8位
+func shortSHA(revision string) string {
+	if len(revision) > 8 {
+		return revision[:8]
+	}
+	return revision
+}
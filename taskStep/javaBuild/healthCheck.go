@@ -0,0 +1,39 @@
+package javaBuild
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cicd-agent/common"
+	"cicd-agent/taskStep"
+	checkService "cicd-agent/taskStep/javaBuild/14-checkService"
+)
+
+// CheckProjectHealth 不经过部署流水线，对项目namespace下的pod执行一次健康检查，返回实际检查的namespace和每个pod的结果
+// namespace为空时检查当前承载流量的版本；健康检查路径和并发数使用项目流水线中checkService步骤的参数
+func CheckProjectHealth(ctx context.Context, project, namespace string) (string, []checkService.PodHealth, error) {
+	if namespace == "" {
+		namespace = getNamespace(project, "now", nil, "checkService")
+	} else if namespace != fmt.Sprintf("%s-service", project) && !strings.HasPrefix(namespace, fmt.Sprintf("%s-service-", project)) {
+		return namespace, nil, fmt.Errorf("namespace %s 不属于项目 %s", namespace, project)
+	}
+
+	deployType := "single"
+	if common.HasVersionStructure(project) {
+		deployType = "double"
+	}
+	params := taskStep.ResolveStepParams(project, deployType, "checkService")
+
+	checker := checkService.NewServiceChecker("", project, nil)
+	if healthPath := params.String("health_path", ""); healthPath != "" {
+		checker.SetHealthPath(healthPath)
+	}
+	checker.SetMaxConcurrency(params.Int("concurrency", 0))
+
+	report, err := checker.HealthReport(ctx, namespace)
+	if err != nil {
+		return namespace, nil, err
+	}
+	return namespace, report, nil
+}
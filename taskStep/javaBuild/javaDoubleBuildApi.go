@@ -7,9 +7,13 @@ import (
 	"time"
 
 	"cicd-agent/common"
+	"cicd-agent/config"
+	"cicd-agent/taskStep"
 	tagImage "cicd-agent/taskStep/javaBuild/10-tagImage"
 	pushLocal "cicd-agent/taskStep/javaBuild/11-pushLocal"
+	apolloRelease "cicd-agent/taskStep/javaBuild/12-apolloRelease"
 	checkImage "cicd-agent/taskStep/javaBuild/12-checkImage"
+	nacosPublish "cicd-agent/taskStep/javaBuild/12-nacosPublish"
 	deployService "cicd-agent/taskStep/javaBuild/13-deployService"
 	checkService "cicd-agent/taskStep/javaBuild/14-checkService"
 	trafficSwitching "cicd-agent/taskStep/javaBuild/15-trafficSwitching"
@@ -17,6 +21,12 @@ import (
 	pullOnline "cicd-agent/taskStep/javaBuild/9-pullOnline"
 )
 
+// doubleVersionPipeline 内置的双版本部署流水线（步骤类型顺序）
+var doubleVersionPipeline = []string{
+	"pullOnline", "tagImages", "pushLocal", "checkImage",
+	"deployService", "checkService", "trafficSwitching", "cleanupOldVersion",
+}
+
 // DoubleVersionProcessor 双版本部署处理器
 type DoubleVersionProcessor struct {
 	project       string
@@ -30,6 +40,11 @@ type DoubleVersionProcessor struct {
 	proURL        string
 	stepDurations map[string]interface{}
 	taskLogger    *common.TaskLogger // 任务日志器
+
+	trafficSwitched bool                  // 流量已切换到新版本，之后才能清理旧版本
+	gitopsRevision  string                // ArgoCD交接模式下推送到GitOps仓库的提交，等待同步时使用
+	nacosBackup     *nacosPublish.Backup  // 本次任务发布前的Nacos配置，任务失败或取消时回滚
+	apolloBackup    *apolloRelease.Backup // 本次任务修改前的Apollo配置，任务失败或取消时回滚
 }
 
 // NewDoubleVersionProcessor 创建双版本部署处理器
@@ -65,110 +80,86 @@ func (r *DoubleVersionProcessor) ProcessDoubleVersionDeployment() error {
 		r.taskLogger.WriteConsole("INFO", fmt.Sprintf("开始处理双版本部署请求: 项目=%s, 标签=%s", r.project, r.tag))
 	}
 
-	// 步骤9：拉取在线镜像
-	if err := r.step9PullOnline(); err != nil {
-		if r.ctx.Err() == context.Canceled {
-			return fmt.Errorf("步骤9拉取在线镜像被取消: %v", err)
-		}
-		r.sendFailureNotifications()
-		return fmt.Errorf("步骤9拉取在线镜像失败: %v", err)
-	}
-
-	// 步骤10：标记镜像
-	if err := r.step10TagImages(); err != nil {
-		if r.ctx.Err() == context.Canceled {
-			return fmt.Errorf("步骤10标记镜像被取消: %v", err)
-		}
-		r.sendFailureNotifications()
-		return fmt.Errorf("步骤10标记镜像失败: %v", err)
+	// 检查是否为双版本部署模式，非双版本项目不应该使用此处理器
+	if !common.HasVersionStructure(r.project) {
+		common.AppLogger.Warning("警告：单版本项目不应使用双版本处理器，建议使用SingleVersionProcessor")
 	}
 
-	// 步骤11：推送本地镜像
-	if err := r.step11PushLocal(); err != nil {
-		if r.ctx.Err() == context.Canceled {
-			return fmt.Errorf("步骤11推送本地镜像被取消: %v", err)
+	// 选择流水线（配置了流水线时按配置的步骤执行）
+	pipeline, err := taskStep.ResolvePipeline(r.project, "double", r.steps(), builtinPipeline(r.project, doubleVersionPipeline))
+	if err != nil {
+		if r.taskLogger != nil {
+			r.taskLogger.WriteConsole("ERROR", err.Error())
 		}
-		r.sendFailureNotifications()
-		return fmt.Errorf("步骤11推送本地镜像失败: %v", err)
+		r.notifyTask("failed")
+		return err
 	}
-
-	// 步骤12：检查镜像
-	if err := r.step12CheckImage(); err != nil {
-		if r.ctx.Err() == context.Canceled {
-			return fmt.Errorf("步骤12检查镜像被取消: %v", err)
-		}
-		r.sendFailureNotifications()
-		return fmt.Errorf("步骤12检查镜像失败: %v", err)
+	if r.taskLogger != nil {
+		r.taskLogger.WriteConsole("INFO", fmt.Sprintf("使用流水线: %s", pipeline.Name))
 	}
 
-	// 步骤13：应用服务部署
-	if err := r.step13DeployService(); err != nil {
-		if r.ctx.Err() == context.Canceled {
-			return fmt.Errorf("步骤13应用服务部署被取消: %v", err)
-		}
-		r.sendFailureNotifications()
-		return fmt.Errorf("步骤13应用服务部署失败: %v", err)
-	}
+	// 登记步骤计划，步骤通知中据此计算整体进度
+	common.StartTaskProgress(r.taskID, pipeline.ProgressSteps())
+	defer common.FinishTaskProgress(r.taskID)
 
-	// 检查是否为双版本部署模式，非双版本项目不应该使用此处理器
-	if !common.HasVersionStructure(r.project) {
-		common.AppLogger.Warning("警告：单版本项目不应使用双版本处理器，建议使用SingleVersionProcessor")
-		common.AppLogger.Info("项目使用单版本结构，部署流程在步骤13完成")
-		// 发送任务完成通知（任务级别）
-		endTime := time.Now().Format("2006-01-02 15:04:05")
-		if err := common.SendTaskNotification(r.taskID, r.project, r.startedAt, "complete", r.opsURL, r.proURL, r.stepDurations); err != nil {
-			common.AppLogger.Error("发送任务完成通知失败:", err)
-		}
-		// 发送飞书完成通知
-		if err := common.SendFeishuCard(r.opsURL, r.project, r.tag, "complete", r.startedAt, endTime, r.deployType, "", r.projectName); err != nil {
-			common.AppLogger.Error("发送飞书卡片通知失败:", err)
-		}
-		common.AppLogger.Info("双版本部署请求处理完成", fmt.Sprintf("项目=%s, 标签=%s", r.project, r.tag))
-	}
+	// 发送任务开始通知（只发送给开启了notify_start的群聊渠道）
+	r.notifyTask("running")
 
-	// 以下步骤仅适用于双版本部署模式
-	// 步骤14：检查服务就绪状态
-	if err := r.step14CheckServiceReady(); err != nil {
+	// 按流水线依次执行步骤
+	if step, err := pipeline.Run(r.ctx, r.taskID, r.tag, r.taskLogger); err != nil {
+		rollbackNacos(r.taskID, r.project, r.nacosBackup, r.taskLogger)
+		rollbackApollo(r.taskID, r.project, r.apolloBackup, r.taskLogger)
 		if r.ctx.Err() == context.Canceled {
-			return fmt.Errorf("步骤14检查服务就绪状态被取消: %v", err)
+			return fmt.Errorf("步骤%d%s被取消: %v", step.Step, step.Name, err)
 		}
 		r.sendFailureNotifications()
-		return fmt.Errorf("步骤14检查服务就绪状态失败: %v", err)
+		return fmt.Errorf("步骤%d%s失败: %v", step.Step, step.Name, err)
 	}
 
-	// 步骤15：流量切换
-	if err := r.step15TrafficSwitching(); err != nil {
-		if r.ctx.Err() == context.Canceled {
-			return fmt.Errorf("步骤15流量切换被取消: %v", err)
-		}
-		r.sendFailureNotifications()
-		return fmt.Errorf("步骤15流量切换失败: %v", err)
-	}
+	// 发送任务完成通知
+	r.notifyTask("complete")
+	common.AppLogger.Info("双版本部署请求处理完成", fmt.Sprintf("项目=%s, 标签=%s", r.project, r.tag))
+	return nil
+}
 
-	// 步骤16：清理旧版本
-	if err := r.step16CleanupOldVersion(); err != nil {
-		if r.ctx.Err() == context.Canceled {
-			return fmt.Errorf("步骤16清理旧版本被取消: %v", err)
+// RerunStep 单独重新执行原任务的一个步骤（如检查服务、流量切换），日志写入原任务目录
+func (r *DoubleVersionProcessor) RerunStep(stepType string) error {
+	defer func() {
+		if r.taskLogger != nil {
+			r.taskLogger.Close()
 		}
-		r.sendFailureNotifications()
-		return fmt.Errorf("步骤16清理旧版本失败: %v", err)
-	}
+	}()
+	// 单独执行时next始终是未承载流量的版本，允许清理
+	r.trafficSwitched = true
+	return taskStep.RerunStep(r.project, "double", stepType, r.steps(), builtinPipeline(r.project, doubleVersionPipeline), r.taskLogger)
+}
 
-	// 发送任务完成通知（任务级别）
-	endTime := time.Now().Format("2006-01-02 15:04:05")
-	if err := common.SendTaskNotification(r.taskID, r.project, r.startedAt, "complete", r.opsURL, r.proURL, r.stepDurations); err != nil {
-		common.AppLogger.Error("发送任务完成通知失败:", err)
-	}
-	// 发送飞书完成通知
-	if err := common.SendFeishuCard(r.opsURL, r.project, r.tag, "complete", r.startedAt, endTime, r.deployType, "", r.projectName); err != nil {
-		common.AppLogger.Error("发送飞书卡片通知失败:", err)
+// steps 双版本部署处理器实现的步骤
+func (r *DoubleVersionProcessor) steps() []taskStep.StepDefinition {
+	return []taskStep.StepDefinition{
+		{Step: 9, Type: "pullOnline", Name: "拉取在线镜像", Run: r.step9PullOnline},
+		{Step: 10, Type: "tagImages", Name: "标记镜像", Run: r.step10TagImages},
+		{Step: 11, Type: "pushLocal", Name: "推送本地镜像", Run: r.step11PushLocal},
+		{Step: 12, Type: "checkImage", Name: "检查镜像", Run: r.step12CheckImage},
+		{Step: 13, Type: "deployService", Name: "应用服务部署", Run: r.step13DeployService},
+		{Step: 14, Type: "checkService", Name: "检查服务就绪状态", Run: r.step14CheckServiceReady},
+		{Step: 15, Type: "trafficSwitching", Name: "流量切换", Run: r.step15TrafficSwitching},
+		{Step: 16, Type: "cleanupOldVersion", Name: "清理旧版本", Run: r.step16CleanupOldVersion},
+		{Step: 12, Type: "configSync", Name: "同步ConfigMap/Secret", Run: r.step12ConfigSync},
+		{Step: 12, Type: "dbMigration", Name: "数据库迁移", Run: r.step12DbMigration},
+		{Step: 12, Type: "nacosPublish", Name: "发布Nacos配置", Run: r.step12NacosPublish},
+		{Step: 12, Type: "apolloRelease", Name: "发布Apollo配置", Run: r.step12ApolloRelease},
+		{Step: 13, Type: "gitopsCommit", Name: "更新GitOps仓库", Run: r.step13GitOpsCommit},
+		{Step: 14, Type: "argocdSync", Name: "等待ArgoCD同步", Run: r.step14ArgoCDSync},
+		{Step: 14, Type: "smokeTest", Name: "冒烟测试", Run: r.step14SmokeTest},
+		{Step: 14, Type: "perfGate", Name: "性能门禁", Run: r.step14PerfGate},
+		{Step: 15, Type: "cacheInvalidation", Name: "清理缓存", Run: r.step15CacheInvalidation},
 	}
-	common.AppLogger.Info("双版本部署请求处理完成", fmt.Sprintf("项目=%s, 标签=%s", r.project, r.tag))
-	return nil
 }
 
 // step9PullOnline 步骤9：拉取在线镜像
-func (r *DoubleVersionProcessor) step9PullOnline() error {
+// 参数 clean_old_images: 拉取前是否清理项目的旧镜像，默认true；concurrency: 同时拉取的镜像数
+func (r *DoubleVersionProcessor) step9PullOnline(params taskStep.StepParams) error {
 	stepName := "拉取在线镜像"
 
 	// 发送步骤开始通知
@@ -190,19 +181,21 @@ func (r *DoubleVersionProcessor) step9PullOnline() error {
 	select {
 	case <-r.ctx.Done():
 		common.SendStepNotification(r.taskID, 9, "pullOnline", stepName, "cancel", "取消拉取在线镜像", r.project, r.tag)
-		// 任务级取消通知
-		if notifyErr := common.SendTaskNotification(r.taskID, r.project, r.startedAt, "cancel", r.opsURL, r.proURL, r.stepDurations); notifyErr != nil {
-			common.AppLogger.Error("发送任务取消通知失败:", notifyErr)
-		}
+		r.sendCancelNotifications()
 		return r.ctx.Err()
 	default:
 	}
 
 	// 使用9-pullOnline模块拉取镜像（可取消）
 	puller := pullOnline.NewImagePuller(r.taskID, r.taskLogger)
+	puller.SetMaxConcurrency(params.Int("concurrency", 0))
 
 	// 清理旧镜像
-	if err := puller.CleanProjectImages(r.ctx, r.project); err != nil {
+	if !params.Bool("clean_old_images", true) {
+		if r.taskLogger != nil {
+			r.taskLogger.WriteStep("pullOnline", "INFO", "配置不清理旧镜像，跳过清理")
+		}
+	} else if err := puller.CleanProjectImages(r.ctx, r.project); err != nil {
 		if r.taskLogger != nil {
 			r.taskLogger.WriteStep("pullOnline", "WARNING", fmt.Sprintf("清理旧镜像失败: %v", err))
 		}
@@ -231,7 +224,7 @@ func (r *DoubleVersionProcessor) step9PullOnline() error {
 }
 
 // step10TagImages 步骤10：标记镜像
-func (r *DoubleVersionProcessor) step10TagImages() error {
+func (r *DoubleVersionProcessor) step10TagImages(_ taskStep.StepParams) error {
 	stepName := "标记镜像"
 
 	// 发送步骤开始通知
@@ -262,9 +255,7 @@ func (r *DoubleVersionProcessor) step10TagImages() error {
 	select {
 	case <-r.ctx.Done():
 		common.SendStepNotification(r.taskID, 10, "tagImages", stepName, "cancel", "取消标记镜像", r.project, r.tag)
-		if notifyErr := common.SendTaskNotification(r.taskID, r.project, r.startedAt, "cancel", r.opsURL, r.proURL, r.stepDurations); notifyErr != nil {
-			common.AppLogger.Error("发送任务取消通知失败:", notifyErr)
-		}
+		r.sendCancelNotifications()
 		return r.ctx.Err()
 	default:
 	}
@@ -292,7 +283,8 @@ func (r *DoubleVersionProcessor) step10TagImages() error {
 }
 
 // step11PushLocal 步骤11：推送本地镜像
-func (r *DoubleVersionProcessor) step11PushLocal() error {
+// 参数 concurrency: 同时推送的镜像数
+func (r *DoubleVersionProcessor) step11PushLocal(params taskStep.StepParams) error {
 	stepName := "推送本地镜像"
 
 	// 发送步骤开始通知
@@ -320,15 +312,14 @@ func (r *DoubleVersionProcessor) step11PushLocal() error {
 	select {
 	case <-r.ctx.Done():
 		common.SendStepNotification(r.taskID, 11, "pushLocal", stepName, "cancel", "取消推送本地镜像", r.project, r.tag)
-		if notifyErr := common.SendTaskNotification(r.taskID, r.project, r.startedAt, "cancel", r.opsURL, r.proURL, r.stepDurations); notifyErr != nil {
-			common.AppLogger.Error("发送任务取消通知失败:", notifyErr)
-		}
+		r.sendCancelNotifications()
 		return r.ctx.Err()
 	default:
 	}
 
 	// 使用11-pushLocal模块推送镜像（可取消）
 	pusher := pushLocal.NewImagePusher(r.taskID, r.taskLogger)
+	pusher.SetMaxConcurrency(params.Int("concurrency", 0))
 	if err := pusher.PushImages(r.ctx, images); err != nil {
 		// 检查是否是取消操作
 		if r.ctx.Err() == context.Canceled {
@@ -351,7 +342,7 @@ func (r *DoubleVersionProcessor) step11PushLocal() error {
 }
 
 // step12CheckImage 步骤12：检查镜像
-func (r *DoubleVersionProcessor) step12CheckImage() error {
+func (r *DoubleVersionProcessor) step12CheckImage(_ taskStep.StepParams) error {
 	stepName := "检查镜像"
 
 	// 发送步骤开始通知
@@ -379,9 +370,7 @@ func (r *DoubleVersionProcessor) step12CheckImage() error {
 	select {
 	case <-r.ctx.Done():
 		common.SendStepNotification(r.taskID, 12, "checkImage", stepName, "cancel", "取消检查镜像", r.project, r.tag)
-		if notifyErr := common.SendTaskNotification(r.taskID, r.project, r.startedAt, "cancel", r.opsURL, r.proURL, r.stepDurations); notifyErr != nil {
-			common.AppLogger.Error("发送任务取消通知失败:", notifyErr)
-		}
+		r.sendCancelNotifications()
 		return r.ctx.Err()
 	default:
 	}
@@ -402,7 +391,7 @@ func (r *DoubleVersionProcessor) step12CheckImage() error {
 }
 
 // step13DeployService 步骤13：应用服务部署
-func (r *DoubleVersionProcessor) step13DeployService() error {
+func (r *DoubleVersionProcessor) step13DeployService(_ taskStep.StepParams) error {
 	stepName := "应用服务部署"
 
 	// 发送步骤开始通知
@@ -428,9 +417,7 @@ func (r *DoubleVersionProcessor) step13DeployService() error {
 	select {
 	case <-r.ctx.Done():
 		common.SendStepNotification(r.taskID, 13, "deployService", stepName, "cancel", "取消应用服务部署", r.project, r.tag)
-		if notifyErr := common.SendTaskNotification(r.taskID, r.project, r.startedAt, "cancel", r.opsURL, r.proURL, r.stepDurations); notifyErr != nil {
-			common.AppLogger.Error("发送任务取消通知失败:", notifyErr)
-		}
+		r.sendCancelNotifications()
 		return r.ctx.Err()
 	default:
 	}
@@ -444,6 +431,7 @@ func (r *DoubleVersionProcessor) step13DeployService() error {
 		common.SendStepNotification(r.taskID, 13, "deployService", stepName, "failed", fmt.Sprintf("应用服务部署失败: %v", err), r.project, r.tag)
 		return err
 	}
+	recordRelease(r.ctx, r.taskID, r.project, r.tag, deployDir, r.taskLogger)
 
 	// 发送步骤完成通知
 	common.SendStepNotification(r.taskID, 13, "deployService", stepName, "success", "应用服务部署完成", r.project, r.tag)
@@ -452,21 +440,22 @@ func (r *DoubleVersionProcessor) step13DeployService() error {
 }
 
 // step14CheckServiceReady 步骤14：检查服务就绪状态
-func (r *DoubleVersionProcessor) step14CheckServiceReady() error {
+// 参数 health_path: 健康检查路径，默认/actuator/health；concurrency: 同时检查的pod数
+func (r *DoubleVersionProcessor) step14CheckServiceReady(params taskStep.StepParams) error {
 	stepName := "检查服务就绪"
 
-	// 发送步骤开始通知
-	common.SendStepNotification(r.taskID, 14, "checkService", stepName, "start", "开始检查服务就绪状态", r.project, r.tag)
-
-	common.AppLogger.Info("执行步骤14：检查服务就绪状态")
-
 	// 检查是否为双副本部署模式
 	if !common.HasVersionStructure(r.project) {
 		common.AppLogger.Info("项目使用单版本结构，跳过服务就绪检查")
-		common.SendStepNotification(r.taskID, 14, "checkService", stepName, "success", "单版本结构，跳过服务就绪检查", r.project, r.tag)
+		common.SendStepNotification(r.taskID, 14, "checkService", stepName, "skipped", "单版本结构，跳过服务就绪检查", r.project, r.tag)
 		return nil
 	}
 
+	// 发送步骤开始通知
+	common.SendStepNotification(r.taskID, 14, "checkService", stepName, "start", "开始检查服务就绪状态", r.project, r.tag)
+
+	common.AppLogger.Info("执行步骤14：检查服务就绪状态")
+
 	// 获取服务列表
 	services, err := getServices(r.project, r.taskLogger, "checkService")
 	if err != nil {
@@ -487,9 +476,7 @@ func (r *DoubleVersionProcessor) step14CheckServiceReady() error {
 	select {
 	case <-r.ctx.Done():
 		common.SendStepNotification(r.taskID, 14, "checkService", stepName, "cancel", "取消检查服务就绪", r.project, r.tag)
-		if notifyErr := common.SendTaskNotification(r.taskID, r.project, r.startedAt, "cancel", r.opsURL, r.proURL, r.stepDurations); notifyErr != nil {
-			common.AppLogger.Error("发送任务取消通知失败:", notifyErr)
-		}
+		r.sendCancelNotifications()
 		return r.ctx.Err()
 	default:
 	}
@@ -499,6 +486,10 @@ func (r *DoubleVersionProcessor) step14CheckServiceReady() error {
 
 	// 使用14-checkService模块检查服务就绪状态（可取消）
 	checker := checkService.NewServiceChecker(r.taskID, r.project, r.taskLogger)
+	if healthPath := params.String("health_path", ""); healthPath != "" {
+		checker.SetHealthPath(healthPath)
+	}
+	checker.SetMaxConcurrency(params.Int("concurrency", 0))
 	if err := checker.CheckServicesReady(r.ctx, services, namespace); err != nil {
 		// 检查是否是取消操作
 		if r.ctx.Err() == context.Canceled {
@@ -521,27 +512,25 @@ func (r *DoubleVersionProcessor) step14CheckServiceReady() error {
 }
 
 // step15TrafficSwitching 步骤15：流量切换
-func (r *DoubleVersionProcessor) step15TrafficSwitching() error {
+func (r *DoubleVersionProcessor) step15TrafficSwitching(_ taskStep.StepParams) error {
 	stepName := "流量切换"
 
-	// 发送步骤开始通知
-	common.SendStepNotification(r.taskID, 15, "trafficSwitching", stepName, "start", "开始执行流量切换", r.project, r.tag)
-	common.AppLogger.Info("执行步骤15：流量切换")
-
 	// 检查是否为双副本部署模式
 	if !common.HasVersionStructure(r.project) {
 		common.AppLogger.Info("项目使用单版本结构，跳过流量切换")
-		common.SendStepNotification(r.taskID, 15, "trafficSwitching", stepName, "success", "单版本结构，跳过流量切换", r.project, r.tag)
+		common.SendStepNotification(r.taskID, 15, "trafficSwitching", stepName, "skipped", "单版本结构，跳过流量切换", r.project, r.tag)
 		return nil
 	}
 
+	// 发送步骤开始通知
+	common.SendStepNotification(r.taskID, 15, "trafficSwitching", stepName, "start", "开始执行流量切换", r.project, r.tag)
+	common.AppLogger.Info("执行步骤15：流量切换")
+
 	// 取消检查
 	select {
 	case <-r.ctx.Done():
 		common.SendStepNotification(r.taskID, 15, "trafficSwitching", stepName, "cancel", "取消流量切换", r.project, r.tag)
-		if notifyErr := common.SendTaskNotification(r.taskID, r.project, r.startedAt, "cancel", r.opsURL, r.proURL, r.stepDurations); notifyErr != nil {
-			common.AppLogger.Error("发送任务取消通知失败:", notifyErr)
-		}
+		r.sendCancelNotifications()
 		return r.ctx.Err()
 	default:
 	}
@@ -559,6 +548,32 @@ func (r *DoubleVersionProcessor) step15TrafficSwitching() error {
 		version = "v1" // 默认版本
 	}
 
+	// 按配置等待飞书审批，拒绝或超时按切换失败处理
+	if config.AppConfig.RequiresTrafficApproval(r.project) {
+		if r.taskLogger != nil {
+			r.taskLogger.WriteStep("trafficSwitching", "INFO", "新版本已就绪，等待流量切换审批")
+		}
+		if err := common.RequestTrafficApproval(r.ctx, r.opsURL, r.taskID, r.project, r.tag, r.projectName); err != nil {
+			if r.ctx.Err() == context.Canceled {
+				common.SendStepNotification(r.taskID, 15, "trafficSwitching", stepName, "cancel", "取消流量切换", r.project, r.tag)
+				r.sendCancelNotifications()
+				return r.ctx.Err()
+			}
+			r.abortTrafficSwitching(namespace, stepName, fmt.Sprintf("流量切换审批未通过: %v", err))
+			return err
+		}
+		if r.taskLogger != nil {
+			r.taskLogger.WriteStep("trafficSwitching", "INFO", "流量切换已批准")
+		}
+	}
+
+	// 切换之前预热新版本，避免切换瞬间的延迟尖刺
+	if err := warmupNewVersion(r.ctx, r.project, r.tag, namespace, r.taskLogger); err != nil {
+		common.SendStepNotification(r.taskID, 15, "trafficSwitching", stepName, "cancel", "取消流量切换", r.project, r.tag)
+		r.sendCancelNotifications()
+		return err
+	}
+
 	// 获取nginx配置目录（可以从配置文件或环境变量获取）
 	nginxConfDir := getNginxConfDir()
 
@@ -567,28 +582,15 @@ func (r *DoubleVersionProcessor) step15TrafficSwitching() error {
 
 	// 执行流量切换
 	if err := switcher.Execute(r.ctx, nil); err != nil {
-		if r.taskLogger != nil {
-			r.taskLogger.WriteStep("trafficSwitching", "ERROR", fmt.Sprintf("流量切换失败: %v", err))
-		}
-		common.SendStepNotification(r.taskID, 15, "trafficSwitching", stepName, "failed", fmt.Sprintf("流量切换失败: %v", err), r.project, r.tag)
-
-		// 流量切换失败时执行缩容操作
-		if r.taskLogger != nil {
-			r.taskLogger.WriteStep("trafficSwitching", "WARNING", "流量切换失败，触发缩容回收资源")
-		}
-		checker := checkService.NewServiceChecker(r.taskID, r.project, r.taskLogger)
-		if scaleErr := checker.ScaleDownNamespaceWithStep(r.ctx, namespace, "trafficSwitching"); scaleErr != nil {
-			if r.taskLogger != nil {
-				r.taskLogger.WriteStep("trafficSwitching", "ERROR", fmt.Sprintf("缩容操作失败: %v", scaleErr))
-			}
-		}
+		r.abortTrafficSwitching(namespace, stepName, fmt.Sprintf("流量切换失败: %v", err))
 		return err
 	}
 
 	// 更新当前版本信息
-	if err := common.UpdateVersion(r.project, version); err != nil {
+	if err := common.UpdateVersion(r.project, version, r.tag, r.taskID); err != nil {
 		common.AppLogger.Error("更新版本信息失败:", err)
 	}
+	r.trafficSwitched = true
 
 	// 发送步骤完成通知
 	common.SendStepNotification(r.taskID, 15, "trafficSwitching", stepName, "success", "流量切换完成", r.project, r.tag)
@@ -596,28 +598,58 @@ func (r *DoubleVersionProcessor) step15TrafficSwitching() error {
 	return nil
 }
 
+// abortTrafficSwitching 流量切换失败（或审批未通过）时记录错误并缩容新版本回收资源
+func (r *DoubleVersionProcessor) abortTrafficSwitching(namespace, stepName, message string) {
+	if r.taskLogger != nil {
+		r.taskLogger.WriteStep("trafficSwitching", "ERROR", message)
+	}
+	common.SendStepNotification(r.taskID, 15, "trafficSwitching", stepName, "failed", message, r.project, r.tag)
+	// 流量未切换，仍由旧版本提供服务
+	common.MarkTaskRolledBack(r.taskID)
+
+	// 流量切换失败时执行缩容操作
+	if r.taskLogger != nil {
+		r.taskLogger.WriteStep("trafficSwitching", "WARNING", "流量切换失败，触发缩容回收资源")
+	}
+	checker := checkService.NewServiceChecker(r.taskID, r.project, r.taskLogger)
+	if scaleErr := checker.ScaleDownNamespaceWithStep(r.ctx, namespace, "trafficSwitching"); scaleErr != nil {
+		if r.taskLogger != nil {
+			r.taskLogger.WriteStep("trafficSwitching", "ERROR", fmt.Sprintf("缩容操作失败: %v", scaleErr))
+		}
+	}
+}
+
 // step16CleanupOldVersion 步骤16：清理旧版本
-func (r *DoubleVersionProcessor) step16CleanupOldVersion() error {
+// 参数 stable_wait: 清理前等待新版本稳定运行的时长，默认55s
+func (r *DoubleVersionProcessor) step16CleanupOldVersion(params taskStep.StepParams) error {
 	stepName := "清理旧版本"
 
-	// 发送步骤开始通知
-	common.SendStepNotification(r.taskID, 16, "cleanupOldVersion", stepName, "start", "开始清理旧版本", r.project, r.tag)
-	common.AppLogger.Info("执行步骤16：清理旧版本")
-
 	// 检查是否为双副本部署模式
 	if !common.HasVersionStructure(r.project) {
 		common.AppLogger.Info("项目使用单版本结构，跳过旧版本清理")
-		common.SendStepNotification(r.taskID, 16, "cleanupOldVersion", stepName, "success", "单版本结构，跳过旧版本清理", r.project, r.tag)
+		common.SendStepNotification(r.taskID, 16, "cleanupOldVersion", stepName, "skipped", "单版本结构，跳过旧版本清理", r.project, r.tag)
+		return nil
+	}
+
+	// 流量未切换时（流量切换步骤被跳过），next版本就是刚部署的新版本，不能清理
+	if !r.trafficSwitched {
+		common.AppLogger.Info("流量未切换，跳过旧版本清理")
+		if r.taskLogger != nil {
+			r.taskLogger.WriteStep("cleanupOldVersion", "WARNING", "流量未切换到新版本，跳过旧版本清理")
+		}
+		common.SendStepNotification(r.taskID, 16, "cleanupOldVersion", stepName, "skipped", "流量未切换，跳过旧版本清理", r.project, r.tag)
 		return nil
 	}
 
+	// 发送步骤开始通知
+	common.SendStepNotification(r.taskID, 16, "cleanupOldVersion", stepName, "start", "开始清理旧版本", r.project, r.tag)
+	common.AppLogger.Info("执行步骤16：清理旧版本")
+
 	// 取消检查
 	select {
 	case <-r.ctx.Done():
 		common.SendStepNotification(r.taskID, 16, "cleanupOldVersion", stepName, "cancel", "取消清理旧版本", r.project, r.tag)
-		if notifyErr := common.SendTaskNotification(r.taskID, r.project, r.startedAt, "cancel", r.opsURL, r.proURL, r.stepDurations); notifyErr != nil {
-			common.AppLogger.Error("发送任务取消通知失败:", notifyErr)
-		}
+		r.sendCancelNotifications()
 		return r.ctx.Err()
 	default:
 	}
@@ -634,6 +666,15 @@ func (r *DoubleVersionProcessor) step16CleanupOldVersion() error {
 
 	// 创建版本清理器，直接传入要删除的目标
 	cleaner := cleanupOldVersion.NewVersionCleaner(oldNamespace, oldPath, r.taskLogger)
+	cleaner.SetStableWait(params.Duration("stable_wait", 55*time.Second))
+	if drainConfig, ok := config.AppConfig.GetDrainProject(r.project); ok {
+		// 在途连接数从旧版本的网关查询
+		connectionsURL, err := resolveGatewayURL(r.ctx, r.project, oldNamespace, drainConfig.ConnectionsURL, r.taskLogger, "cleanupOldVersion")
+		if err != nil && r.taskLogger != nil {
+			r.taskLogger.WriteStep("cleanupOldVersion", "WARNING", fmt.Sprintf("解析在途连接数查询地址失败，不等待连接排空: %v", err))
+		}
+		cleaner.SetDrainer(cleanupOldVersion.NewDrainer(oldNamespace, connectionsURL, drainConfig, r.taskLogger))
+	}
 
 	// 执行清理
 	if err := cleaner.Execute(r.ctx, nil); err != nil {
@@ -650,32 +691,108 @@ func (r *DoubleVersionProcessor) step16CleanupOldVersion() error {
 	return nil
 }
 
-// sendFailureNotifications 发送失败通知（包括任务通知和飞书通知）
-func (r *DoubleVersionProcessor) sendFailureNotifications() {
-	endTime := time.Now().Format("2006-01-02 15:04:05")
+// step12ConfigSync 将项目的ConfigMap/Secret同步到目标namespace（应用服务部署之前）
+func (r *DoubleVersionProcessor) step12ConfigSync(_ taskStep.StepParams) error {
+	return runConfigSync(r.ctx, r.taskID, r.project, r.tag, r.taskLogger, r.sendCancelNotifications)
+}
 
-	// 发送任务失败通知
-	if notifyErr := common.SendTaskNotification(r.taskID, r.project, r.startedAt, "failed", r.opsURL, r.proURL, r.stepDurations); notifyErr != nil {
-		common.AppLogger.Error("发送任务失败通知失败:", notifyErr)
-	}
+// step12DbMigration 在目标namespace中执行数据库迁移Job（应用服务部署之前）
+func (r *DoubleVersionProcessor) step12DbMigration(_ taskStep.StepParams) error {
+	return runDbMigration(r.ctx, r.taskID, r.project, r.tag, r.taskLogger, r.sendCancelNotifications)
+}
+
+// step12NacosPublish 发布Nacos配置（应用服务部署之前），任务失败或取消时回滚
+func (r *DoubleVersionProcessor) step12NacosPublish(_ taskStep.StepParams) error {
+	backup, err := runNacosPublish(r.ctx, r.taskID, r.project, r.tag, r.taskLogger, r.sendCancelNotifications)
+	r.nacosBackup = backup
+	return err
+}
+
+// step12ApolloRelease 修改并发布Apollo命名空间（应用服务部署之前），任务失败或取消时回滚
+func (r *DoubleVersionProcessor) step12ApolloRelease(_ taskStep.StepParams) error {
+	backup, err := runApolloRelease(r.ctx, r.taskID, r.project, r.tag, r.taskLogger, r.sendCancelNotifications)
+	r.apolloBackup = backup
+	return err
+}
+
+// step13GitOpsCommit 步骤13（ArgoCD交接模式）：更新GitOps仓库中的镜像标签并推送
+func (r *DoubleVersionProcessor) step13GitOpsCommit(_ taskStep.StepParams) error {
+	revision, err := runGitOpsCommit(r.ctx, r.taskID, r.project, r.tag, r.taskLogger, r.sendCancelNotifications)
+	r.gitopsRevision = revision
+	return err
+}
 
-	// 发送飞书失败通知
-	if feishuErr := common.SendFeishuCard(r.opsURL, r.project, r.tag, "failed", r.startedAt, endTime, r.deployType, "", r.projectName); feishuErr != nil {
-		common.AppLogger.Error("发送飞书失败通知失败:", feishuErr)
+// step14ArgoCDSync 步骤14（ArgoCD交接模式）：等待ArgoCD Application同步到推送的提交并健康
+func (r *DoubleVersionProcessor) step14ArgoCDSync(_ taskStep.StepParams) error {
+	return runArgoCDSync(r.ctx, r.taskID, r.project, r.tag, r.gitopsRevision, r.taskLogger, r.sendCancelNotifications)
+}
+
+// step14SmokeTest 流量切换之前对新版本namespace执行冒烟测试，失败时不切换流量并缩容新版本
+func (r *DoubleVersionProcessor) step14SmokeTest(_ taskStep.StepParams) error {
+	namespace := getNamespace(r.project, "next", r.taskLogger, "smokeTest")
+	err := runSmokeTest(r.ctx, r.taskID, r.project, r.tag, namespace, r.taskLogger, r.sendCancelNotifications)
+	if err != nil && r.ctx.Err() == nil {
+		r.abortBeforeSwitching(namespace, "smokeTest", "冒烟测试失败")
 	}
+	return err
 }
 
-// sendCancelNotifications 发送取消通知（包括任务通知和飞书通知）
-func (r *DoubleVersionProcessor) sendCancelNotifications() {
-	endTime := time.Now().Format("2006-01-02 15:04:05")
+// step14PerfGate 流量切换之前压测新版本namespace的网关，未通过时不切换流量并缩容新版本
+func (r *DoubleVersionProcessor) step14PerfGate(_ taskStep.StepParams) error {
+	namespace := getNamespace(r.project, "next", r.taskLogger, "perfGate")
+	err := runPerfGate(r.ctx, r.taskID, r.project, r.tag, namespace, r.taskLogger, r.sendCancelNotifications)
+	if err != nil && r.ctx.Err() == nil {
+		r.abortBeforeSwitching(namespace, "perfGate", "未通过性能门禁")
+	}
+	return err
+}
 
-	// 发送任务取消通知
-	if notifyErr := common.SendTaskNotification(r.taskID, r.project, r.startedAt, "cancel", r.opsURL, r.proURL, r.stepDurations); notifyErr != nil {
-		common.AppLogger.Error("发送任务取消通知失败:", notifyErr)
+// abortBeforeSwitching 流量切换之前的检查未通过：标记任务已回滚（仍由旧版本提供服务）并缩容新版本回收资源
+func (r *DoubleVersionProcessor) abortBeforeSwitching(namespace, stepType, reason string) {
+	if !common.HasVersionStructure(r.project) {
+		return
+	}
+	common.MarkTaskRolledBack(r.taskID)
+	if r.taskLogger != nil {
+		r.taskLogger.WriteStep(stepType, "WARNING", fmt.Sprintf("%s，不切换流量，缩容新版本回收资源", reason))
+	}
+	checker := checkService.NewServiceChecker(r.taskID, r.project, r.taskLogger)
+	if scaleErr := checker.ScaleDownNamespaceWithStep(r.ctx, namespace, stepType); scaleErr != nil && r.taskLogger != nil {
+		r.taskLogger.WriteStep(stepType, "ERROR", fmt.Sprintf("缩容操作失败: %v", scaleErr))
 	}
+}
+
+// step15CacheInvalidation 流量切换之后清理项目的Redis缓存
+func (r *DoubleVersionProcessor) step15CacheInvalidation(_ taskStep.StepParams) error {
+	return runCacheInvalidation(r.ctx, r.taskID, r.project, r.tag, r.taskLogger, r.sendCancelNotifications)
+}
+
+// sendFailureNotifications 发送任务失败通知
+func (r *DoubleVersionProcessor) sendFailureNotifications() {
+	r.notifyTask("failed")
+}
+
+// sendCancelNotifications 发送任务取消通知
+func (r *DoubleVersionProcessor) sendCancelNotifications() {
+	r.notifyTask("cancel")
+}
 
-	// 发送飞书取消通知
-	if feishuErr := common.SendFeishuCard(r.opsURL, r.project, r.tag, "cancel", r.startedAt, endTime, r.deployType, "", r.projectName); feishuErr != nil {
-		common.AppLogger.Error("发送飞书取消通知失败:", feishuErr)
+// notifyTask 向项目启用的所有通知渠道发送任务状态通知
+func (r *DoubleVersionProcessor) notifyTask(status string) {
+	event := common.TaskEvent{
+		TaskID:        r.taskID,
+		Project:       r.project,
+		ProjectName:   r.projectName,
+		Tag:           r.tag,
+		Category:      "",
+		DeployType:    r.deployType,
+		Status:        status,
+		StartedAt:     r.startedAt,
+		OpsURL:        r.opsURL,
+		ProURL:        r.proURL,
+		StepDurations: r.stepDurations,
+	}
+	if err := common.NotifyTask(event); err != nil {
+		common.AppLogger.Error(fmt.Sprintf("发送任务通知失败: 状态=%s, 错误=%v", status, err))
 	}
 }
@@ -2,16 +2,30 @@ package javaBuild
 
 import (
 	"cicd-agent/common"
+	"cicd-agent/taskStep"
 	tagImage "cicd-agent/taskStep/javaBuild/10-tagImage"
 	pushLocal "cicd-agent/taskStep/javaBuild/11-pushLocal"
+	apolloRelease "cicd-agent/taskStep/javaBuild/12-apolloRelease"
 	checkImage "cicd-agent/taskStep/javaBuild/12-checkImage"
+	nacosPublish "cicd-agent/taskStep/javaBuild/12-nacosPublish"
 	deployService "cicd-agent/taskStep/javaBuild/13-deployService"
 	pullOnline "cicd-agent/taskStep/javaBuild/9-pullOnline"
 	"context"
 	"fmt"
-	"time"
+	"slices"
 )
 
+// singleVersionPipeline 内置的单版本部署流水线（步骤类型顺序）
+var singleVersionPipeline = []string{"pullOnline", "tagImages", "pushLocal", "checkImage", "deployService"}
+
+// StepTypes 部署类型（double/single）可执行的全部步骤类型（含按项目配置加入的步骤和ArgoCD交接模式的步骤）
+func StepTypes(deployType string) []string {
+	if deployType == "double" {
+		return append(append(slices.Clone(doubleVersionPipeline), optionalSteps...), gitopsSteps...)
+	}
+	return append(append(slices.Clone(singleVersionPipeline), optionalSteps...), gitopsSteps...)
+}
+
 // SingleVersionProcessor 单版本部署处理器
 type SingleVersionProcessor struct {
 	project       string
@@ -26,6 +40,10 @@ type SingleVersionProcessor struct {
 	proURL        string
 	stepDurations map[string]interface{}
 	taskLogger    *common.TaskLogger // 任务日志器
+
+	gitopsRevision string                // ArgoCD交接模式下推送到GitOps仓库的提交，等待同步时使用
+	nacosBackup    *nacosPublish.Backup  // 本次任务发布前的Nacos配置，任务失败或取消时回滚
+	apolloBackup   *apolloRelease.Backup // 本次任务修改前的Apollo配置，任务失败或取消时回滚
 }
 
 // NewSingleVersionProcessor 创建单版本部署处理器
@@ -62,69 +80,78 @@ func (r *SingleVersionProcessor) ProcessSingleVersionDeployment() error {
 		r.taskLogger.WriteConsole("INFO", fmt.Sprintf("开始处理单版本部署请求: 项目=%s, 标签=%s, 分类=%s", r.project, r.tag, r.category))
 	}
 
-	// 步骤9：拉取在线镜像
-	if err := r.step9PullOnline(); err != nil {
-		if r.ctx.Err() == context.Canceled {
-			return fmt.Errorf("步骤9拉取在线镜像被取消: %v", err)
+	// 选择流水线（配置了流水线时按配置的步骤执行）
+	pipeline, err := taskStep.ResolvePipeline(r.project, "single", r.steps(), builtinPipeline(r.project, singleVersionPipeline))
+	if err != nil {
+		if r.taskLogger != nil {
+			r.taskLogger.WriteConsole("ERROR", err.Error())
 		}
-		r.sendFailureNotifications()
-		return fmt.Errorf("步骤9拉取在线镜像失败: %v", err)
+		r.notifyTask("failed")
+		return err
 	}
-
-	// 步骤10：标记镜像
-	if err := r.step10TagImages(); err != nil {
-		if r.ctx.Err() == context.Canceled {
-			return fmt.Errorf("步骤10标记镜像被取消: %v", err)
-		}
-		r.sendFailureNotifications()
-		return fmt.Errorf("步骤10标记镜像失败: %v", err)
+	if r.taskLogger != nil {
+		r.taskLogger.WriteConsole("INFO", fmt.Sprintf("使用流水线: %s", pipeline.Name))
 	}
 
-	// 步骤11：推送本地镜像
-	if err := r.step11PushLocal(); err != nil {
-		if r.ctx.Err() == context.Canceled {
-			return fmt.Errorf("步骤11推送本地镜像被取消: %v", err)
-		}
-		r.sendFailureNotifications()
-		return fmt.Errorf("步骤11推送本地镜像失败: %v", err)
-	}
+	// 登记步骤计划，步骤通知中据此计算整体进度
+	common.StartTaskProgress(r.taskID, pipeline.ProgressSteps())
+	defer common.FinishTaskProgress(r.taskID)
 
-	// 步骤12：检查镜像
-	if err := r.step12CheckImage(); err != nil {
-		if r.ctx.Err() == context.Canceled {
-			return fmt.Errorf("步骤12检查镜像被取消: %v", err)
-		}
-		r.sendFailureNotifications()
-		return fmt.Errorf("步骤12检查镜像失败: %v", err)
-	}
+	// 发送任务开始通知（只发送给开启了notify_start的群聊渠道）
+	r.notifyTask("running")
 
-	// 步骤13：应用服务部署
-	if err := r.step13DeployService(); err != nil {
+	// 按流水线依次执行步骤
+	if step, err := pipeline.Run(r.ctx, r.taskID, r.tag, r.taskLogger); err != nil {
+		rollbackNacos(r.taskID, r.project, r.nacosBackup, r.taskLogger)
+		rollbackApollo(r.taskID, r.project, r.apolloBackup, r.taskLogger)
 		if r.ctx.Err() == context.Canceled {
-			return fmt.Errorf("步骤13应用服务部署被取消: %v", err)
+			return fmt.Errorf("步骤%d%s被取消: %v", step.Step, step.Name, err)
 		}
 		r.sendFailureNotifications()
-		return fmt.Errorf("步骤13应用服务部署失败: %v", err)
+		return fmt.Errorf("步骤%d%s失败: %v", step.Step, step.Name, err)
 	}
 
 	// 单版本部署完成，发送任务完成通知
 	common.AppLogger.Info("单版本部署流程完成")
-	endTime := time.Now().Format("2006-01-02 15:04:05")
+	// 发送任务完成通知
+	r.notifyTask("complete")
+	common.AppLogger.Info("单版本部署请求处理完成", fmt.Sprintf("项目=%s, 标签=%s, 分类=%s", r.project, r.tag, r.category))
+	return nil
+}
 
-	if err := common.SendTaskNotification(r.taskID, r.project, r.startedAt, "complete", r.opsURL, r.proURL, r.stepDurations); err != nil {
-		common.AppLogger.Error("发送任务完成通知失败:", err)
-	}
+// RerunStep 单独重新执行原任务的一个步骤，日志写入原任务目录
+func (r *SingleVersionProcessor) RerunStep(stepType string) error {
+	defer func() {
+		if r.taskLogger != nil {
+			r.taskLogger.Close()
+		}
+	}()
+	return taskStep.RerunStep(r.project, "single", stepType, r.steps(), builtinPipeline(r.project, singleVersionPipeline), r.taskLogger)
+}
 
-	// 发送飞书卡片通知
-	if err := common.SendFeishuCard(r.opsURL, r.project, r.tag, "complete", r.startedAt, endTime, r.deployType, r.category, r.projectName); err != nil {
-		common.AppLogger.Error("发送飞书卡片通知失败:", err)
+// steps 单版本部署处理器实现的步骤
+func (r *SingleVersionProcessor) steps() []taskStep.StepDefinition {
+	return []taskStep.StepDefinition{
+		{Step: 9, Type: "pullOnline", Name: "拉取在线镜像", Run: r.step9PullOnline},
+		{Step: 10, Type: "tagImages", Name: "标记镜像", Run: r.step10TagImages},
+		{Step: 11, Type: "pushLocal", Name: "推送本地镜像", Run: r.step11PushLocal},
+		{Step: 12, Type: "checkImage", Name: "检查镜像", Run: r.step12CheckImage},
+		{Step: 13, Type: "deployService", Name: "应用服务部署", Run: r.step13DeployService},
+		{Step: 12, Type: "configSync", Name: "同步ConfigMap/Secret", Run: r.step12ConfigSync},
+		{Step: 12, Type: "dbMigration", Name: "数据库迁移", Run: r.step12DbMigration},
+		{Step: 12, Type: "nacosPublish", Name: "发布Nacos配置", Run: r.step12NacosPublish},
+		{Step: 12, Type: "apolloRelease", Name: "发布Apollo配置", Run: r.step12ApolloRelease},
+		{Step: 13, Type: "gitopsCommit", Name: "更新GitOps仓库", Run: r.step13GitOpsCommit},
+		{Step: 14, Type: "argocdSync", Name: "等待ArgoCD同步", Run: r.step14ArgoCDSync},
+		{Step: 14, Type: "smokeTest", Name: "冒烟测试", Run: r.step14SmokeTest},
+		{Step: 14, Type: "perfGate", Name: "性能门禁", Run: r.step14PerfGate},
+		{Step: 15, Type: "cacheInvalidation", Name: "清理缓存", Run: r.step15CacheInvalidation},
 	}
-	common.AppLogger.Info("单版本部署请求处理完成", fmt.Sprintf("项目=%s, 标签=%s, 分类=%s", r.project, r.tag, r.category))
-	return nil
 }
 
 // step9PullOnline 步骤9：拉取在线镜像
-func (r *SingleVersionProcessor) step9PullOnline() error {
+// 参数 clean_old_images: 拉取前是否清理项目的旧镜像，默认true；concurrency: 同时拉取的镜像数
+func (r *SingleVersionProcessor) step9PullOnline(params taskStep.StepParams) error {
 	stepName := "拉取在线镜像"
 
 	// 发送步骤开始通知
@@ -163,9 +190,14 @@ func (r *SingleVersionProcessor) step9PullOnline() error {
 
 	// 使用9-pullOnline模块拉取镜像（可取消）
 	puller := pullOnline.NewImagePuller(r.taskID, r.taskLogger)
+	puller.SetMaxConcurrency(params.Int("concurrency", 0))
 
 	// 清理旧镜像
-	if err := puller.CleanProjectImages(r.ctx, r.project); err != nil {
+	if !params.Bool("clean_old_images", true) {
+		if r.taskLogger != nil {
+			r.taskLogger.WriteStep("pullOnline", "INFO", "配置不清理旧镜像，跳过清理")
+		}
+	} else if err := puller.CleanProjectImages(r.ctx, r.project); err != nil {
 		if r.taskLogger != nil {
 			r.taskLogger.WriteStep("pullOnline", "WARNING", fmt.Sprintf("清理旧镜像失败: %v", err))
 		}
@@ -200,7 +232,7 @@ func (r *SingleVersionProcessor) step9PullOnline() error {
 }
 
 // step10TagImages 步骤10：标记镜像
-func (r *SingleVersionProcessor) step10TagImages() error {
+func (r *SingleVersionProcessor) step10TagImages(_ taskStep.StepParams) error {
 	stepName := "标记镜像"
 
 	// 发送步骤开始通知
@@ -259,7 +291,8 @@ func (r *SingleVersionProcessor) step10TagImages() error {
 }
 
 // step11PushLocal 步骤11：推送本地镜像
-func (r *SingleVersionProcessor) step11PushLocal() error {
+// 参数 concurrency: 同时推送的镜像数
+func (r *SingleVersionProcessor) step11PushLocal(params taskStep.StepParams) error {
 	stepName := "推送本地镜像"
 
 	// 发送步骤开始通知
@@ -294,6 +327,7 @@ func (r *SingleVersionProcessor) step11PushLocal() error {
 
 	// 使用11-pushLocal模块推送镜像（可取消）
 	pusher := pushLocal.NewImagePusher(r.taskID, r.taskLogger)
+	pusher.SetMaxConcurrency(params.Int("concurrency", 0))
 	if err := pusher.PushImages(r.ctx, images); err != nil {
 		// 检查是否是取消操作
 		if r.ctx.Err() == context.Canceled {
@@ -316,7 +350,7 @@ func (r *SingleVersionProcessor) step11PushLocal() error {
 }
 
 // step12CheckImage 步骤12：检查镜像
-func (r *SingleVersionProcessor) step12CheckImage() error {
+func (r *SingleVersionProcessor) step12CheckImage(_ taskStep.StepParams) error {
 	stepName := "检查镜像"
 
 	// 发送步骤开始通知
@@ -365,7 +399,7 @@ func (r *SingleVersionProcessor) step12CheckImage() error {
 }
 
 // step13DeployService 步骤13：应用服务部署
-func (r *SingleVersionProcessor) step13DeployService() error {
+func (r *SingleVersionProcessor) step13DeployService(_ taskStep.StepParams) error {
 	stepName := "应用服务部署"
 
 	// 发送步骤开始通知
@@ -405,6 +439,7 @@ func (r *SingleVersionProcessor) step13DeployService() error {
 		common.SendStepNotification(r.taskID, 13, "deployService", stepName, "failed", fmt.Sprintf("应用服务部署失败: %v", err), r.project, r.tag)
 		return err
 	}
+	recordRelease(r.ctx, r.taskID, r.project, r.tag, deployDir, r.taskLogger)
 
 	// 发送步骤完成通知
 	common.SendStepNotification(r.taskID, 13, "deployService", stepName, "success", "应用服务部署完成", r.project, r.tag)
@@ -412,32 +447,85 @@ func (r *SingleVersionProcessor) step13DeployService() error {
 	return nil
 }
 
-// sendFailureNotifications 发送失败通知（包括任务通知和飞书通知）
-func (r *SingleVersionProcessor) sendFailureNotifications() {
-	endTime := time.Now().Format("2006-01-02 15:04:05")
+// step12ConfigSync 将项目的ConfigMap/Secret同步到目标namespace（应用服务部署之前）
+func (r *SingleVersionProcessor) step12ConfigSync(_ taskStep.StepParams) error {
+	return runConfigSync(r.ctx, r.taskID, r.project, r.tag, r.taskLogger, r.sendCancelNotifications)
+}
 
-	// 发送任务失败通知
-	if notifyErr := common.SendTaskNotification(r.taskID, r.project, r.startedAt, "failed", r.opsURL, r.proURL, r.stepDurations); notifyErr != nil {
-		common.AppLogger.Error("发送任务失败通知失败:", notifyErr)
-	}
+// step12DbMigration 在目标namespace中执行数据库迁移Job（应用服务部署之前）
+func (r *SingleVersionProcessor) step12DbMigration(_ taskStep.StepParams) error {
+	return runDbMigration(r.ctx, r.taskID, r.project, r.tag, r.taskLogger, r.sendCancelNotifications)
+}
 
-	// 发送飞书失败通知
-	if feishuErr := common.SendFeishuCard(r.opsURL, r.project, r.tag, "failed", r.startedAt, endTime, r.deployType, r.category, r.projectName); feishuErr != nil {
-		common.AppLogger.Error("发送飞书失败通知失败:", feishuErr)
-	}
+// step12NacosPublish 发布Nacos配置（应用服务部署之前），任务失败或取消时回滚
+func (r *SingleVersionProcessor) step12NacosPublish(_ taskStep.StepParams) error {
+	backup, err := runNacosPublish(r.ctx, r.taskID, r.project, r.tag, r.taskLogger, r.sendCancelNotifications)
+	r.nacosBackup = backup
+	return err
 }
 
-// sendCancelNotifications 发送取消通知（包括任务通知和飞书通知）
-func (r *SingleVersionProcessor) sendCancelNotifications() {
-	endTime := time.Now().Format("2006-01-02 15:04:05")
+// step12ApolloRelease 修改并发布Apollo命名空间（应用服务部署之前），任务失败或取消时回滚
+func (r *SingleVersionProcessor) step12ApolloRelease(_ taskStep.StepParams) error {
+	backup, err := runApolloRelease(r.ctx, r.taskID, r.project, r.tag, r.taskLogger, r.sendCancelNotifications)
+	r.apolloBackup = backup
+	return err
+}
 
-	// 发送任务取消通知
-	if notifyErr := common.SendTaskNotification(r.taskID, r.project, r.startedAt, "cancel", r.opsURL, r.proURL, r.stepDurations); notifyErr != nil {
-		common.AppLogger.Error("发送任务取消通知失败:", notifyErr)
-	}
+// step13GitOpsCommit 步骤13（ArgoCD交接模式）：更新GitOps仓库中的镜像标签并推送
+func (r *SingleVersionProcessor) step13GitOpsCommit(_ taskStep.StepParams) error {
+	revision, err := runGitOpsCommit(r.ctx, r.taskID, r.project, r.tag, r.taskLogger, r.sendCancelNotifications)
+	r.gitopsRevision = revision
+	return err
+}
+
+// step14ArgoCDSync 步骤14（ArgoCD交接模式）：等待ArgoCD Application同步到推送的提交并健康
+func (r *SingleVersionProcessor) step14ArgoCDSync(_ taskStep.StepParams) error {
+	return runArgoCDSync(r.ctx, r.taskID, r.project, r.tag, r.gitopsRevision, r.taskLogger, r.sendCancelNotifications)
+}
+
+// step14SmokeTest 部署完成后对项目namespace执行冒烟测试
+func (r *SingleVersionProcessor) step14SmokeTest(_ taskStep.StepParams) error {
+	namespace := getNamespace(r.project, "next", r.taskLogger, "smokeTest")
+	return runSmokeTest(r.ctx, r.taskID, r.project, r.tag, namespace, r.taskLogger, r.sendCancelNotifications)
+}
+
+// step14PerfGate 部署完成后压测项目namespace的网关
+func (r *SingleVersionProcessor) step14PerfGate(_ taskStep.StepParams) error {
+	namespace := getNamespace(r.project, "next", r.taskLogger, "perfGate")
+	return runPerfGate(r.ctx, r.taskID, r.project, r.tag, namespace, r.taskLogger, r.sendCancelNotifications)
+}
+
+// step15CacheInvalidation 流量切换之后清理项目的Redis缓存
+func (r *SingleVersionProcessor) step15CacheInvalidation(_ taskStep.StepParams) error {
+	return runCacheInvalidation(r.ctx, r.taskID, r.project, r.tag, r.taskLogger, r.sendCancelNotifications)
+}
+
+// sendFailureNotifications 发送任务失败通知
+func (r *SingleVersionProcessor) sendFailureNotifications() {
+	r.notifyTask("failed")
+}
+
+// sendCancelNotifications 发送任务取消通知
+func (r *SingleVersionProcessor) sendCancelNotifications() {
+	r.notifyTask("cancel")
+}
 
-	// 发送飞书取消通知
-	if feishuErr := common.SendFeishuCard(r.opsURL, r.project, r.tag, "cancel", r.startedAt, endTime, r.deployType, r.category, r.projectName); feishuErr != nil {
-		common.AppLogger.Error("发送飞书取消通知失败:", feishuErr)
+// notifyTask 向项目启用的所有通知渠道发送任务状态通知
+func (r *SingleVersionProcessor) notifyTask(status string) {
+	event := common.TaskEvent{
+		TaskID:        r.taskID,
+		Project:       r.project,
+		ProjectName:   r.projectName,
+		Tag:           r.tag,
+		Category:      r.category,
+		DeployType:    r.deployType,
+		Status:        status,
+		StartedAt:     r.startedAt,
+		OpsURL:        r.opsURL,
+		ProURL:        r.proURL,
+		StepDurations: r.stepDurations,
+	}
+	if err := common.NotifyTask(event); err != nil {
+		common.AppLogger.Error(fmt.Sprintf("发送任务通知失败: 状态=%s, 错误=%v", status, err))
 	}
 }
@@ -0,0 +1,49 @@
+package javaBuild
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cicd-agent/common"
+	cleanupOldVersion "cicd-agent/taskStep/javaBuild/16-cleanupOldVersion"
+)
+
+// CleanupOldVersion 不经过部署流水线，单独清理双版本项目未承载流量的版本（next），wait为清理前的等待时长
+// 日志写入taskID对应的任务目录，按步骤16发送步骤通知
+func CleanupOldVersion(ctx context.Context, taskID, project string, wait time.Duration) error {
+	stepName := "清理旧版本"
+	taskLogger := common.NewTaskLogger(taskID)
+	defer func() {
+		if taskLogger != nil {
+			taskLogger.Close()
+		}
+	}()
+
+	common.StartTaskProgress(taskID, []common.PipelineStep{{Step: 16, Type: "cleanupOldVersion"}})
+	defer common.FinishTaskProgress(taskID)
+
+	oldNamespace := getNamespace(project, "next", taskLogger, "cleanupOldVersion")
+	oldPath := getDeploymentPath(project, "next", taskLogger, "cleanupOldVersion")
+	if taskLogger != nil {
+		taskLogger.WriteConsole("INFO", fmt.Sprintf("手动清理旧版本: 项目=%s, namespace=%s, 路径=%s", project, oldNamespace, oldPath))
+	}
+	common.SendStepNotification(taskID, 16, "cleanupOldVersion", stepName, "start", fmt.Sprintf("手动清理旧版本 %s", oldNamespace), project, "")
+
+	cleaner := cleanupOldVersion.NewVersionCleaner(oldNamespace, oldPath, taskLogger)
+	cleaner.SetStableWait(wait)
+	if err := cleaner.Execute(ctx, nil); err != nil {
+		status := "failed"
+		if ctx.Err() == context.Canceled {
+			status = "cancel"
+		}
+		if taskLogger != nil {
+			taskLogger.WriteStep("cleanupOldVersion", "ERROR", fmt.Sprintf("清理旧版本失败: %v", err))
+		}
+		common.SendStepNotification(taskID, 16, "cleanupOldVersion", stepName, status, fmt.Sprintf("清理旧版本失败: %v", err), project, "")
+		return err
+	}
+
+	common.SendStepNotification(taskID, 16, "cleanupOldVersion", stepName, "success", "清理旧版本完成", project, "")
+	return nil
+}
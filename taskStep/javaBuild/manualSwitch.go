@@ -0,0 +1,52 @@
+package javaBuild
+
+import (
+	"context"
+	"fmt"
+
+	"cicd-agent/common"
+	trafficSwitching "cicd-agent/taskStep/javaBuild/15-trafficSwitching"
+)
+
+// SwitchTraffic 不经过部署流水线，单独将双版本项目的流量切换到指定版本（v1/v2）并更新版本记录
+// 日志写入taskID对应的任务目录，按步骤15发送步骤通知
+func SwitchTraffic(ctx context.Context, taskID, project, version string) error {
+	stepName := "流量切换"
+	taskLogger := common.NewTaskLogger(taskID)
+	defer func() {
+		if taskLogger != nil {
+			taskLogger.Close()
+		}
+	}()
+
+	common.StartTaskProgress(taskID, []common.PipelineStep{{Step: 15, Type: "trafficSwitching"}})
+	defer common.FinishTaskProgress(taskID)
+
+	if taskLogger != nil {
+		taskLogger.WriteConsole("INFO", fmt.Sprintf("手动切换流量: 项目=%s, 目标版本=%s", project, version))
+	}
+	common.SendStepNotification(taskID, 15, "trafficSwitching", stepName, "start", fmt.Sprintf("手动切换流量到%s", version), project, version)
+
+	namespace := fmt.Sprintf("%s-service-%s", project, version)
+	switcher := trafficSwitching.NewTrafficSwitcher(namespace, project, version, getNginxConfDir(), taskLogger)
+	if err := switcher.Execute(ctx, nil); err != nil {
+		status := "failed"
+		if ctx.Err() == context.Canceled {
+			status = "cancel"
+		}
+		if taskLogger != nil {
+			taskLogger.WriteStep("trafficSwitching", "ERROR", fmt.Sprintf("流量切换失败: %v", err))
+		}
+		common.SendStepNotification(taskID, 15, "trafficSwitching", stepName, status, fmt.Sprintf("流量切换失败: %v", err), project, version)
+		return err
+	}
+
+	if err := common.UpdateVersion(project, version, "", taskID); err != nil {
+		common.AppLogger.Error("更新版本信息失败:", err)
+		if taskLogger != nil {
+			taskLogger.WriteStep("trafficSwitching", "ERROR", fmt.Sprintf("更新版本信息失败: %v", err))
+		}
+	}
+	common.SendStepNotification(taskID, 15, "trafficSwitching", stepName, "success", fmt.Sprintf("流量已切换到%s", version), project, version)
+	return nil
+}
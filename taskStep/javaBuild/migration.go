@@ -0,0 +1,34 @@
+package javaBuild
+
+import (
+	"context"
+	"fmt"
+
+	"cicd-agent/common"
+	dbMigration "cicd-agent/taskStep/javaBuild/12-dbMigration"
+)
+
+// runDbMigration 在目标namespace中执行数据库迁移Job（应用服务部署之前），迁移失败时中止部署
+func runDbMigration(ctx context.Context, taskID, project, tag string, taskLogger *common.TaskLogger, onCancel func()) error {
+	stepName := "数据库迁移"
+	common.SendStepNotification(taskID, 12, "dbMigration", stepName, "start", "开始执行数据库迁移", project, tag)
+	common.AppLogger.Info("执行步骤12：数据库迁移")
+
+	namespace := getNamespace(project, "next", taskLogger, "dbMigration")
+	if err := dbMigration.NewMigrator(taskID, taskLogger).Run(ctx, project, tag, namespace); err != nil {
+		if ctx.Err() == context.Canceled {
+			common.SendStepNotification(taskID, 12, "dbMigration", stepName, "cancel", "取消数据库迁移", project, tag)
+			onCancel()
+			return ctx.Err()
+		}
+		if taskLogger != nil {
+			taskLogger.WriteStep("dbMigration", "ERROR", fmt.Sprintf("数据库迁移失败: %v", err))
+		}
+		common.SendStepNotification(taskID, 12, "dbMigration", stepName, "failed", fmt.Sprintf("数据库迁移失败: %v", err), project, tag)
+		return err
+	}
+
+	common.SendStepNotification(taskID, 12, "dbMigration", stepName, "success", "数据库迁移完成", project, tag)
+	common.AppLogger.Info("步骤12完成：数据库迁移")
+	return nil
+}
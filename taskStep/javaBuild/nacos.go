@@ -0,0 +1,57 @@
+package javaBuild
+
+import (
+	"context"
+	"fmt"
+
+	"cicd-agent/common"
+	"cicd-agent/config"
+	nacosPublish "cicd-agent/taskStep/javaBuild/12-nacosPublish"
+)
+
+// runNacosPublish 发布Nacos配置（应用服务部署之前），返回已发布项的原内容供任务失败时回滚
+// 发布中途失败时同样返回已发布部分的备份
+func runNacosPublish(ctx context.Context, taskID, project, tag string, taskLogger *common.TaskLogger, onCancel func()) (*nacosPublish.Backup, error) {
+	stepName := "发布Nacos配置"
+	common.SendStepNotification(taskID, 12, "nacosPublish", stepName, "start", "开始发布Nacos配置", project, tag)
+	common.AppLogger.Info("执行步骤12：发布Nacos配置")
+
+	// 配置文件的相对路径基于项目部署目录
+	baseDir, _ := config.AppConfig.GetProjectPath(project)
+	backup, err := nacosPublish.NewConfigPublisher(taskID, taskLogger).Publish(ctx, project, tag, baseDir)
+	if err != nil {
+		if ctx.Err() == context.Canceled {
+			common.SendStepNotification(taskID, 12, "nacosPublish", stepName, "cancel", "取消发布Nacos配置", project, tag)
+			onCancel()
+			return backup, ctx.Err()
+		}
+		if taskLogger != nil {
+			taskLogger.WriteStep("nacosPublish", "ERROR", fmt.Sprintf("发布Nacos配置失败: %v", err))
+		}
+		common.SendStepNotification(taskID, 12, "nacosPublish", stepName, "failed", fmt.Sprintf("发布Nacos配置失败: %v", err), project, tag)
+		return backup, err
+	}
+
+	message := fmt.Sprintf("已发布 %d 项配置", len(backup.Items))
+	if len(backup.Items) == 0 {
+		message = "配置没有变化"
+	}
+	common.SendStepNotification(taskID, 12, "nacosPublish", stepName, "success", message, project, tag)
+	common.AppLogger.Info("步骤12完成：发布Nacos配置")
+	return backup, nil
+}
+
+// rollbackNacos 任务失败或取消后回滚本次发布的Nacos配置（任务可能已取消，使用独立的ctx）
+func rollbackNacos(taskID, project string, backup *nacosPublish.Backup, taskLogger *common.TaskLogger) {
+	if backup == nil || len(backup.Items) == 0 {
+		return
+	}
+	common.AppLogger.Info(fmt.Sprintf("任务未成功，回滚Nacos配置: 任务ID=%s, 项目=%s", taskID, project))
+	if err := nacosPublish.NewConfigPublisher(taskID, taskLogger).Rollback(context.Background(), backup); err != nil {
+		common.AppLogger.Error(fmt.Sprintf("回滚Nacos配置失败: 任务ID=%s, 错误=%v", taskID, err))
+		return
+	}
+	if taskLogger != nil {
+		taskLogger.WriteStep("nacosPublish", "INFO", fmt.Sprintf("任务未成功，已回滚 %d 项Nacos配置", len(backup.Items)))
+	}
+}
@@ -0,0 +1,40 @@
+package javaBuild
+
+import (
+	"context"
+	"fmt"
+
+	"cicd-agent/common"
+	"cicd-agent/config"
+	perfGate "cicd-agent/taskStep/javaBuild/14-perfGate"
+)
+
+// runPerfGate 流量切换之前压测新版本namespace的网关，P95延迟或错误率超过阈值时阻止切换
+func runPerfGate(ctx context.Context, taskID, project, tag, namespace string, taskLogger *common.TaskLogger, onCancel func()) error {
+	stepName := "性能门禁"
+	common.SendStepNotification(taskID, 14, "perfGate", stepName, "start", "开始压测新版本", project, tag)
+	common.AppLogger.Info("执行步骤14：性能门禁")
+
+	projectConfig, _ := config.AppConfig.GetPerfGateProject(project)
+	baseURL, err := resolveGatewayURL(ctx, project, namespace, projectConfig.BaseURL, taskLogger, "perfGate")
+	var report perfGate.Report
+	if err == nil {
+		report, err = perfGate.NewLoadProber(taskID, taskLogger).Run(ctx, project, tag, baseURL)
+	}
+	if err != nil {
+		if ctx.Err() == context.Canceled {
+			common.SendStepNotification(taskID, 14, "perfGate", stepName, "cancel", "取消性能门禁", project, tag)
+			onCancel()
+			return ctx.Err()
+		}
+		if taskLogger != nil {
+			taskLogger.WriteStep("perfGate", "ERROR", fmt.Sprintf("性能门禁失败: %v", err))
+		}
+		common.SendStepNotification(taskID, 14, "perfGate", stepName, "failed", fmt.Sprintf("性能门禁失败: %v", err), project, tag)
+		return err
+	}
+
+	common.SendStepNotification(taskID, 14, "perfGate", stepName, "success", fmt.Sprintf("P95 %s，错误率 %.2f%%", report.P95, report.ErrorRate*100), project, tag)
+	common.AppLogger.Info("步骤14完成：性能门禁")
+	return nil
+}
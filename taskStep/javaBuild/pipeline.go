@@ -0,0 +1,67 @@
+package javaBuild
+
+import (
+	"slices"
+
+	"cicd-agent/config"
+)
+
+// optionalSteps 按项目配置加入内置流水线的步骤类型
+var optionalSteps = []string{"configSync", "dbMigration", "nacosPublish", "apolloRelease", "smokeTest", "perfGate", "cacheInvalidation"}
+
+// builtinPipeline 项目使用的内置流水线：配置了ConfigMap/Secret同步、数据库迁移、Nacos/Apollo发布的项目在应用服务部署之前依次执行，
+// 配置了gitops的项目在检查镜像（及发布配置）之后交给ArgoCD部署；配置了冒烟测试、性能门禁的项目在服务就绪检查之后、流量切换之前依次执行；
+// 配置了缓存清理的项目在流量切换之后（没有流量切换时在最后）清理缓存
+func builtinPipeline(project string, pipeline []string) []string {
+	_, sync := config.AppConfig.GetConfigSyncProject(project)
+	_, migration := config.AppConfig.GetMigrationProject(project)
+	_, nacos := config.AppConfig.GetNacosProject(project)
+	_, apollo := config.AppConfig.GetApolloProject(project)
+	_, gitops := config.AppConfig.GetGitOpsProject(project)
+	_, smoke := config.AppConfig.GetSmokeTestProject(project)
+	_, perf := config.AppConfig.GetPerfGateProject(project)
+	_, cache := config.AppConfig.GetRedisProject(project)
+
+	steps := make([]string, 0, len(pipeline)+len(optionalSteps)+len(gitopsSteps))
+	for _, stepType := range pipeline {
+		if stepType == "deployService" {
+			if sync {
+				steps = append(steps, "configSync")
+			}
+			if migration {
+				steps = append(steps, "dbMigration")
+			}
+			if nacos {
+				steps = append(steps, "nacosPublish")
+			}
+			if apollo {
+				steps = append(steps, "apolloRelease")
+			}
+			if gitops {
+				steps = append(steps, gitopsSteps...)
+				break
+			}
+		}
+		steps = append(steps, stepType)
+	}
+	if smoke {
+		steps = insertAfter(steps, "smokeTest", "checkService", "deployService", "argocdSync")
+	}
+	if perf {
+		steps = insertAfter(steps, "perfGate", "smokeTest", "checkService", "deployService", "argocdSync")
+	}
+	if cache {
+		steps = insertAfter(steps, "cacheInvalidation", "trafficSwitching")
+	}
+	return steps
+}
+
+// insertAfter 将stepType插入到anchors中第一个存在的步骤之后，都不存在时追加到最后
+func insertAfter(steps []string, stepType string, anchors ...string) []string {
+	for _, anchor := range anchors {
+		if index := slices.Index(steps, anchor); index >= 0 {
+			return slices.Insert(steps, index+1, stepType)
+		}
+	}
+	return append(steps, stepType)
+}
@@ -0,0 +1,99 @@
+package javaBuild
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cicd-agent/common"
+)
+
+// recordRelease 记录本次发布的镜像摘要和部署文件摘要，供发布历史对比；记录失败只写日志，不影响部署
+func recordRelease(ctx context.Context, taskID, project, tag, deployDir string, taskLogger *common.TaskLogger) {
+	record := common.ReleaseRecord{
+		TaskID:     taskID,
+		Project:    project,
+		Tag:        tag,
+		DeployedAt: time.Now().Format("2006-01-02 15:04:05"),
+		Images:     make(map[string]common.ReleaseImage),
+		Files:      make(map[string]string),
+	}
+	if common.HasVersionStructure(project) {
+		record.Version = strings.TrimPrefix(filepath.Base(deployDir), "deployment-")
+	}
+
+	images, err := getLocalImages(project, tag, nil, "deployService")
+	if err != nil && taskLogger != nil {
+		taskLogger.WriteStep("deployService", "WARNING", fmt.Sprintf("获取发布镜像列表失败: %v", err))
+	}
+	for _, image := range images {
+		repository := strings.TrimSuffix(image, ":"+tag)
+		service := repository[strings.LastIndex(repository, "/")+1:]
+		record.Images[service] = common.ReleaseImage{Image: image, Digest: imageDigest(ctx, image)}
+	}
+
+	err = filepath.Walk(deployDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(info.Name(), ".yaml") && !strings.HasSuffix(info.Name(), ".yml") {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		relPath, _ := filepath.Rel(deployDir, path)
+		// 镜像标签每次发布都会变化，替换为占位符后再计算摘要，只有其他内容变化的文件才算变更
+		sum := sha256.Sum256([]byte(strings.ReplaceAll(string(data), ":"+tag, ":{tag}")))
+		record.Files[filepath.ToSlash(relPath)] = hex.EncodeToString(sum[:])
+		return nil
+	})
+	if err != nil && taskLogger != nil {
+		taskLogger.WriteStep("deployService", "WARNING", fmt.Sprintf("计算部署文件摘要失败: %v", err))
+	}
+
+	if err := common.WriteReleaseRecord(record); err != nil {
+		common.AppLogger.Warning(fmt.Sprintf("记录发布内容失败: 任务ID=%s, 错误=%v", taskID, err))
+		return
+	}
+	if taskLogger != nil {
+		taskLogger.WriteStep("deployService", "INFO", fmt.Sprintf("已记录发布内容: %d 个镜像, %d 个部署文件", len(record.Images), len(record.Files)))
+	}
+}
+
+// imageDigest 获取本地镜像推送到仓库后的摘要，获取失败时返回空
+func imageDigest(ctx context.Context, image string) string {
+	cmd := common.NewCommand("docker", "image", "inspect", "--format", "{{range .RepoDigests}}{{println .}}{{end}}", image)
+	output, err := common.RunCommand(ctx, cmd)
+	if err != nil {
+		return ""
+	}
+	// RepoDigests格式为 仓库@sha256:...，优先取与镜像同仓库的摘要
+	repository := image[:strings.LastIndex(image, ":")]
+	var fallback string
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		index := strings.Index(line, "@")
+		if index < 0 {
+			continue
+		}
+		if line[:index] == repository {
+			return line[index+1:]
+		}
+		if fallback == "" {
+			fallback = line[index+1:]
+		}
+	}
+	return fallback
+}
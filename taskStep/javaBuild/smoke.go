@@ -0,0 +1,39 @@
+package javaBuild
+
+import (
+	"context"
+	"fmt"
+
+	"cicd-agent/common"
+	"cicd-agent/config"
+	smokeTest "cicd-agent/taskStep/javaBuild/14-smokeTest"
+)
+
+// runSmokeTest 对新版本namespace执行冒烟测试（流量切换之前），失败时阻止切换
+func runSmokeTest(ctx context.Context, taskID, project, tag, namespace string, taskLogger *common.TaskLogger, onCancel func()) error {
+	stepName := "冒烟测试"
+	common.SendStepNotification(taskID, 14, "smokeTest", stepName, "start", "开始冒烟测试", project, tag)
+	common.AppLogger.Info("执行步骤14：冒烟测试")
+
+	projectConfig, _ := config.AppConfig.GetSmokeTestProject(project)
+	baseURL, err := resolveGatewayURL(ctx, project, namespace, projectConfig.BaseURL, taskLogger, "smokeTest")
+	if err == nil {
+		err = smokeTest.NewSmokeTester(taskID, taskLogger).Run(ctx, project, tag, baseURL)
+	}
+	if err != nil {
+		if ctx.Err() == context.Canceled {
+			common.SendStepNotification(taskID, 14, "smokeTest", stepName, "cancel", "取消冒烟测试", project, tag)
+			onCancel()
+			return ctx.Err()
+		}
+		if taskLogger != nil {
+			taskLogger.WriteStep("smokeTest", "ERROR", fmt.Sprintf("冒烟测试失败: %v", err))
+		}
+		common.SendStepNotification(taskID, 14, "smokeTest", stepName, "failed", fmt.Sprintf("冒烟测试失败: %v", err), project, tag)
+		return err
+	}
+
+	common.SendStepNotification(taskID, 14, "smokeTest", stepName, "success", "冒烟测试通过", project, tag)
+	common.AppLogger.Info("步骤14完成：冒烟测试")
+	return nil
+}
@@ -0,0 +1,43 @@
+package javaBuild
+
+import (
+	"context"
+	"fmt"
+
+	"cicd-agent/common"
+	"cicd-agent/config"
+	trafficSwitching "cicd-agent/taskStep/javaBuild/15-trafficSwitching"
+)
+
+// warmupNewVersion 流量切换之前预热新版本：配置了base_url时请求该地址，否则直接请求namespace中的每个pod
+// 获取预热目标失败只记录警告，不阻止切换；只有任务取消时返回错误
+func warmupNewVersion(ctx context.Context, project, tag, namespace string, taskLogger *common.TaskLogger) error {
+	projectConfig, ok := config.AppConfig.GetWarmupProject(project)
+	if !ok {
+		return nil
+	}
+
+	var targets []string
+	var err error
+	if projectConfig.BaseURL != "" {
+		var target string
+		if target, err = resolveGatewayURL(ctx, project, namespace, projectConfig.BaseURL, taskLogger, "trafficSwitching"); err == nil {
+			targets = []string{target}
+		}
+	} else {
+		targets, err = trafficSwitching.PodTargets(ctx, namespace, projectConfig.Selector, projectConfig.Port)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil || len(targets) == 0 {
+		if err == nil {
+			err = fmt.Errorf("namespace %s 中没有运行中的pod", namespace)
+		}
+		if taskLogger != nil {
+			taskLogger.WriteStep("trafficSwitching", "WARNING", fmt.Sprintf("获取预热目标失败，跳过预热: %v", err))
+		}
+		return nil
+	}
+	return trafficSwitching.NewWarmer(taskLogger).Run(ctx, project, tag, targets)
+}
@@ -0,0 +1,226 @@
+package taskStep
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"cicd-agent/common"
+	"cicd-agent/config"
+)
+
+// StepParams 流水线中为步骤配置的参数
+type StepParams map[string]string
+
+// String 获取字符串参数
+func (p StepParams) String(key, defaultValue string) string {
+	if value, ok := p[key]; ok && value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// Bool 获取布尔参数（true/false/1/0/yes/no）
+func (p StepParams) Bool(key string, defaultValue bool) bool {
+	switch strings.ToLower(p[key]) {
+	case "true", "1", "yes":
+		return true
+	case "false", "0", "no":
+		return false
+	}
+	return defaultValue
+}
+
+// Int 获取正整数参数
+func (p StepParams) Int(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(p[key]); err == nil && value > 0 {
+		return value
+	}
+	return defaultValue
+}
+
+// Duration 获取时间间隔参数（如 5m）
+func (p StepParams) Duration(key string, defaultValue time.Duration) time.Duration {
+	if value, err := time.ParseDuration(p[key]); err == nil && value > 0 {
+		return value
+	}
+	return defaultValue
+}
+
+// StepFunc 步骤执行函数，失败时自行发送步骤通知并返回错误
+type StepFunc func(params StepParams) error
+
+// StepDefinition 处理器实现的步骤：编号和名称用于通知，Run为执行函数
+type StepDefinition struct {
+	Step int
+	Type string
+	Name string
+	Run  StepFunc
+}
+
+// PipelineStep 流水线中的一个步骤
+type PipelineStep struct {
+	StepDefinition
+	Params  StepParams
+	Skipped bool // 项目配置跳过该步骤
+}
+
+// Pipeline 待执行的流水线
+type Pipeline struct {
+	Name    string
+	Project string
+	Steps   []PipelineStep
+}
+
+// ResolvePipeline 按项目和部署类型选择流水线：配置了流水线时按配置的步骤组装，否则使用内置步骤顺序
+// available为处理器实现的全部步骤，builtin为内置流水线的步骤类型顺序；最后应用项目的跳过和参数覆盖配置
+func ResolvePipeline(project, deployType string, available []StepDefinition, builtin []string) (Pipeline, error) {
+	definitions := make(map[string]StepDefinition, len(available))
+	for _, definition := range available {
+		definitions[definition.Type] = definition
+	}
+
+	var pipeline Pipeline
+	pipelineConfig, ok := config.AppConfig.FindPipeline(project, deployType)
+	if !ok {
+		pipeline = Pipeline{Name: "builtin-" + deployType, Project: project}
+		for _, stepType := range builtin {
+			pipeline.Steps = append(pipeline.Steps, PipelineStep{StepDefinition: definitions[stepType]})
+		}
+	} else {
+		pipeline = Pipeline{Name: pipelineConfig.Name, Project: project}
+		for _, stepConfig := range pipelineConfig.Steps {
+			definition, ok := definitions[stepConfig.Type]
+			if !ok {
+				return Pipeline{}, fmt.Errorf("流水线 %s 中的步骤 %s 不适用于%s部署", pipelineConfig.Name, stepConfig.Type, deployType)
+			}
+			pipeline.Steps = append(pipeline.Steps, PipelineStep{StepDefinition: definition, Params: stepConfig.Params})
+		}
+	}
+
+	pipeline.applyOverride(config.AppConfig.GetProjectOverride(project))
+	return pipeline, nil
+}
+
+// applyOverride 应用项目的覆盖配置：标记跳过的步骤，合并步骤参数（项目参数优先）
+func (p *Pipeline) applyOverride(override config.ProjectOverrideConfig) {
+	skip := make(map[string]bool, len(override.Skip))
+	for _, stepType := range override.Skip {
+		skip[stepType] = true
+	}
+	for i := range p.Steps {
+		step := &p.Steps[i]
+		step.Skipped = skip[step.Type]
+
+		overrides := override.Params[step.Type]
+		if len(overrides) == 0 {
+			continue
+		}
+		params := make(StepParams, len(step.Params)+len(overrides))
+		for key, value := range step.Params {
+			params[key] = value
+		}
+		for key, value := range overrides {
+			params[key] = value
+		}
+		step.Params = params
+	}
+}
+
+// ProgressSteps 转换为任务进度使用的步骤计划
+func (p Pipeline) ProgressSteps() []common.PipelineStep {
+	steps := make([]common.PipelineStep, 0, len(p.Steps))
+	for _, step := range p.Steps {
+		steps = append(steps, common.PipelineStep{Step: step.Step, Type: step.Type, Skipped: step.Skipped})
+	}
+	return steps
+}
+
+// Run 依次执行步骤，遇到失败时停止并返回出错的步骤（步骤自行检查任务是否取消并发送取消通知）
+// 项目配置跳过的步骤不执行，发送skipped状态的步骤通知；任务被暂停时在下一步骤开始前等待恢复或ctx被取消
+func (p Pipeline) Run(ctx context.Context, taskID, tag string, taskLogger *common.TaskLogger) (*PipelineStep, error) {
+	for i := range p.Steps {
+		step := &p.Steps[i]
+		p.waitIfPaused(ctx, taskID, tag, step, taskLogger)
+		if step.Skipped {
+			common.AppLogger.Info(fmt.Sprintf("项目 %s 配置跳过步骤%d：%s", p.Project, step.Step, step.Name))
+			if taskLogger != nil {
+				taskLogger.WriteStep(step.Type, "INFO", "项目配置跳过该步骤")
+			}
+			common.SendStepNotification(taskID, step.Step, step.Type, step.Name, "skipped", "项目配置跳过该步骤", p.Project, tag)
+			continue
+		}
+		if err := step.Run(step.Params); err != nil {
+			return step, err
+		}
+	}
+	return nil, nil
+}
+
+// waitIfPaused 任务暂停时发送paused状态的步骤通知并等待恢复
+func (p Pipeline) waitIfPaused(ctx context.Context, taskID, tag string, step *PipelineStep, taskLogger *common.TaskLogger) {
+	paused := common.WaitIfPaused(ctx, taskID, func() {
+		common.AppLogger.Info(fmt.Sprintf("任务暂停在步骤%d：%s 之前，等待恢复", step.Step, step.Name))
+		if taskLogger != nil {
+			taskLogger.WriteConsole("INFO", fmt.Sprintf("任务已暂停，恢复后执行步骤%d：%s", step.Step, step.Name))
+		}
+		common.SendStepNotification(taskID, step.Step, step.Type, step.Name, "paused", "任务已暂停，等待恢复", p.Project, tag)
+	})
+	if paused && taskLogger != nil {
+		taskLogger.WriteConsole("INFO", "任务已恢复执行")
+	}
+}
+
+// RerunStep 单独重新执行流水线中的一个步骤（使用项目流水线中该步骤的参数，项目配置跳过的步骤同样执行）
+func RerunStep(project, deployType, stepType string, available []StepDefinition, builtin []string, taskLogger *common.TaskLogger) error {
+	pipeline, err := ResolvePipeline(project, deployType, available, builtin)
+	if err != nil {
+		return err
+	}
+
+	step, ok := pipeline.findStep(stepType)
+	if !ok {
+		for _, definition := range available {
+			if definition.Type == stepType {
+				step, ok = PipelineStep{StepDefinition: definition}, true
+				break
+			}
+		}
+	}
+	if !ok {
+		return fmt.Errorf("%s部署没有步骤 %s", deployType, stepType)
+	}
+
+	common.AppLogger.Info(fmt.Sprintf("重新执行步骤%d：%s，项目=%s", step.Step, step.Name, project))
+	if taskLogger != nil {
+		taskLogger.WriteConsole("INFO", fmt.Sprintf("重新执行步骤%d：%s", step.Step, step.Name))
+	}
+	return step.Run(step.Params)
+}
+
+// findStep 按类型查找流水线中的步骤
+func (p Pipeline) findStep(stepType string) (PipelineStep, bool) {
+	for _, step := range p.Steps {
+		if step.Type == stepType {
+			return step, true
+		}
+	}
+	return PipelineStep{}, false
+}
+
+// ResolveStepParams 获取项目流水线中某个步骤的参数（流水线配置的参数合并项目覆盖参数），供流水线外单独执行的操作使用
+func ResolveStepParams(project, deployType, stepType string) StepParams {
+	pipeline := Pipeline{Project: project, Steps: []PipelineStep{{StepDefinition: StepDefinition{Type: stepType}}}}
+	if pipelineConfig, ok := config.AppConfig.FindPipeline(project, deployType); ok {
+		for _, stepConfig := range pipelineConfig.Steps {
+			if stepConfig.Type == stepType {
+				pipeline.Steps[0].Params = stepConfig.Params
+				break
+			}
+		}
+	}
+	pipeline.applyOverride(config.AppConfig.GetProjectOverride(project))
+	return pipeline.Steps[0].Params
+}
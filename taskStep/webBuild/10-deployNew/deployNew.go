@@ -226,7 +226,7 @@ func (d *DeployNewStep) getWebPath() string {
 	if d.category != "" {
 		// 有category: /www/scfq/manager
 		basePath := config.AppConfig.GetWebPath(d.project)
-		return filepath.Clean(filepath.Dir(basePath) + "/" + d.category)
+		return filepath.Join(filepath.Dir(basePath), d.category)
 	} else {
 		// 无category: /www/scfq/web
 		return config.AppConfig.GetWebPath(d.project)
@@ -57,20 +57,23 @@ func (d *DownProductStep) Execute() error {
 		d.taskLogger.WriteStep("downProduct", "INFO", fmt.Sprintf("开始下载产物: %s", downloadURL))
 	}
 
-	// 创建HTTP请求
-	req, err := http.NewRequestWithContext(d.ctx, "GET", downloadURL, nil)
+	// 创建HTTP请求（任务取消或超过下载超时时中止）
+	downloadCtx, cancel := context.WithTimeout(d.ctx, config.AppConfig.GetWebDownloadTimeout())
+	defer cancel()
+	req, err := http.NewRequestWithContext(downloadCtx, "GET", downloadURL, nil)
 	if err != nil {
 		if d.taskLogger != nil {
 			d.taskLogger.WriteStep("downProduct", "ERROR", fmt.Sprintf("创建HTTP请求失败: %v", err))
 		}
+		return fmt.Errorf("创建HTTP请求失败: %v", err)
 	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := common.HTTPClient().Do(req)
 	if err != nil {
 		if d.taskLogger != nil {
 			d.taskLogger.WriteStep("downProduct", "ERROR", fmt.Sprintf("HTTP请求失败: %v", err))
 		}
+		return fmt.Errorf("下载产物失败: %v", err)
 	}
 	defer resp.Body.Close()
 
@@ -79,14 +82,16 @@ func (d *DownProductStep) Execute() error {
 		if d.taskLogger != nil {
 			d.taskLogger.WriteStep("downProduct", "ERROR", fmt.Sprintf("下载失败，HTTP状态码: %d", resp.StatusCode))
 		}
+		return fmt.Errorf("下载失败，HTTP状态码: %d", resp.StatusCode)
 	}
 
 	// 创建本地保存目录
-	downloadDir := "/tmp/web-products"
+	downloadDir := common.TempWorkDir("web-products")
 	if err := os.MkdirAll(downloadDir, 0755); err != nil {
 		if d.taskLogger != nil {
 			d.taskLogger.WriteStep("downProduct", "ERROR", fmt.Sprintf("创建下载目录失败: %v", err))
 		}
+		return fmt.Errorf("创建下载目录失败: %v", err)
 	}
 
 	// 本地文件路径
@@ -98,6 +103,7 @@ func (d *DownProductStep) Execute() error {
 		if d.taskLogger != nil {
 			d.taskLogger.WriteStep("downProduct", "ERROR", fmt.Sprintf("创建本地文件失败: %v", err))
 		}
+		return fmt.Errorf("创建本地文件失败: %v", err)
 	}
 	defer file.Close()
 
@@ -107,6 +113,7 @@ func (d *DownProductStep) Execute() error {
 		if d.taskLogger != nil {
 			d.taskLogger.WriteStep("downProduct", "ERROR", fmt.Sprintf("写入文件失败: %v", err))
 		}
+		return fmt.Errorf("写入文件失败: %v", err)
 	}
 
 	// 获取文件大小
@@ -135,7 +142,7 @@ func (d *DownProductStep) GetLocalFilePath() string {
 	} else {
 		productName = fmt.Sprintf("%s-%s.zip", d.project, d.tag)
 	}
-	return filepath.Join("/tmp/web-products", productName)
+	return filepath.Join(common.TempWorkDir("web-products"), productName)
 }
 
 // GetTargetWebPath 获取目标web路径
@@ -49,7 +49,7 @@ func (e *ExtractProductStep) Execute() error {
 	}
 
 	// 创建解压目录
-	extractDir := "/tmp/web-extract"
+	extractDir := e.GetExtractDir()
 	if err := os.RemoveAll(extractDir); err != nil {
 		if e.taskLogger != nil {
 			e.taskLogger.WriteStep("extractProduct", "ERROR", fmt.Sprintf("清理解压目录失败: %v", err))
@@ -161,7 +161,7 @@ func (e *ExtractProductStep) extractFile(file *zip.File, destPath string) error
 
 // GetExtractDir 获取解压目录
 func (e *ExtractProductStep) GetExtractDir() string {
-	return "/tmp/web-extract"
+	return common.TempWorkDir("web-extract")
 }
 
 // GetDistPath 获取要部署的源目录路径
@@ -78,7 +78,7 @@ func (b *BackupCurrentStep) getWebPath() string {
 	if b.category != "" {
 		// 有category: /www/scfq/manager
 		basePath := config.AppConfig.GetWebPath(b.project)
-		return filepath.Clean(filepath.Dir(basePath) + "/" + b.category)
+		return filepath.Join(filepath.Dir(basePath), b.category)
 	} else {
 		// 无category: /www/scfq/web
 		return config.AppConfig.GetWebPath(b.project)
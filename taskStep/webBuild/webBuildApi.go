@@ -4,9 +4,9 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"time"
 
 	"cicd-agent/common"
+	"cicd-agent/taskStep"
 	"cicd-agent/taskStep/webBuild/10-deployNew"
 	"cicd-agent/taskStep/webBuild/7-downProduct"
 	"cicd-agent/taskStep/webBuild/8-extractProduct"
@@ -38,6 +38,9 @@ func (n *NoRemoteProcessor) ProcessNoRemoteRequest() error {
 	return nil
 }
 
+// webBuildPipeline 内置的web构建流水线（步骤类型顺序）
+var webBuildPipeline = []string{"downProduct", "extractProduct", "backupCurrent", "deployNew"}
+
 // RemoteProcessor web构建remote请求处理器
 type RemoteProcessor struct {
 	project       string
@@ -52,6 +55,11 @@ type RemoteProcessor struct {
 	proURL        string
 	stepDurations map[string]interface{}
 	taskLogger    *common.TaskLogger // 任务日志器
+
+	// 已执行的步骤，后续步骤从中获取产物路径
+	downProductStep *downProduct.DownProductStep
+	extractStep     *extractProduct.ExtractProductStep
+	backupStep      *backupCurrent.BackupCurrentStep
 }
 
 // NewRemoteProcessor 创建web构建remote处理器
@@ -88,83 +96,113 @@ func (r *RemoteProcessor) ProcessRemoteRequest() error {
 		r.taskLogger.WriteConsole("INFO", fmt.Sprintf("收到web构建回调: 项目=%s, 分类=%s, 标签=%s, 任务ID=%s", r.project, r.category, r.tag, r.taskID))
 	}
 
-	// 1. 下载产物
-	common.SendStepNotification(r.taskID, 7, "downProduct", "下载产物", "start", "", r.project, r.tag)
-	downProductStep := downProduct.NewDownProductStep(r.project, r.tag, r.category, r.ctx, r.taskLogger)
-	if err := downProductStep.Execute(); err != nil {
+	// 选择流水线（配置了流水线时按配置的步骤执行）
+	pipeline, err := taskStep.ResolvePipeline(r.project, "web", r.steps(), webBuildPipeline)
+	if err != nil {
 		if r.taskLogger != nil {
-			r.taskLogger.WriteStep("downProduct", "ERROR", fmt.Sprintf("下载产物失败: %v", err))
+			r.taskLogger.WriteConsole("ERROR", err.Error())
 		}
-		// 发送步骤失败通知
-		common.SendStepNotification(r.taskID, 7, "downProduct", "下载产物", "failed", err.Error(), r.project, r.tag)
+		r.notifyTask("failed")
+		return err
+	}
+	if r.taskLogger != nil {
+		r.taskLogger.WriteConsole("INFO", fmt.Sprintf("使用流水线: %s", pipeline.Name))
+	}
+
+	// 登记步骤计划，步骤通知中据此计算整体进度
+	common.StartTaskProgress(r.taskID, pipeline.ProgressSteps())
+	defer common.FinishTaskProgress(r.taskID)
+
+	// 发送任务开始通知（只发送给开启了notify_start的群聊渠道）
+	r.notifyTask("running")
+
+	// 按流水线依次执行步骤
+	if _, err := pipeline.Run(r.ctx, r.taskID, r.tag, r.taskLogger); err != nil {
 		// 发送任务失败通知
-		endTime := time.Now().Format("2006-01-02 15:04:05")
-		if notifyErr := common.SendTaskNotification(r.taskID, r.project, r.startedAt, "failed", r.opsURL, r.proURL, r.stepDurations); notifyErr != nil {
-			common.AppLogger.Error("发送失败通知失败:", notifyErr)
-		}
-		// 发送飞书失败通知
-		if feishuErr := common.SendFeishuCard(r.opsURL, r.project, r.tag, "failed", r.startedAt, endTime, r.deployType, r.category, r.projectName); feishuErr != nil {
-			common.AppLogger.Error("发送飞书失败通知失败:", feishuErr)
-		}
-		return fmt.Errorf("下载产物失败: %v", err)
+		r.notifyTask("failed")
+		return err
+	}
+
+	// 清理临时文件
+	r.cleanupTempFiles()
+
+	// 发送任务完成通知
+	r.notifyTask("complete")
+
+	common.AppLogger.Info("web构建回调处理完成", fmt.Sprintf("项目=%s, 分类=%s, 标签=%s", r.project, r.category, r.tag))
+	return nil
+}
+
+// steps web构建处理器实现的步骤
+func (r *RemoteProcessor) steps() []taskStep.StepDefinition {
+	return []taskStep.StepDefinition{
+		{Step: 7, Type: "downProduct", Name: "下载产物", Run: r.step7DownProduct},
+		{Step: 8, Type: "extractProduct", Name: "解压产物", Run: r.step8ExtractProduct},
+		{Step: 9, Type: "backupCurrent", Name: "备份当前版本", Run: r.step9BackupCurrent},
+		{Step: 10, Type: "deployNew", Name: "部署新版本", Run: r.step10DeployNew},
+	}
+}
+
+// stepFailed 记录步骤失败日志并发送步骤失败通知
+func (r *RemoteProcessor) stepFailed(step int, stepType, stepName string, err error) error {
+	if r.taskLogger != nil {
+		r.taskLogger.WriteStep(stepType, "ERROR", fmt.Sprintf("%s失败: %v", stepName, err))
+	}
+	common.SendStepNotification(r.taskID, step, stepType, stepName, "failed", err.Error(), r.project, r.tag)
+	return fmt.Errorf("%s失败: %v", stepName, err)
+}
+
+// step7DownProduct 步骤7：下载产物
+func (r *RemoteProcessor) step7DownProduct(_ taskStep.StepParams) error {
+	common.SendStepNotification(r.taskID, 7, "downProduct", "下载产物", "start", "", r.project, r.tag)
+	r.downProductStep = downProduct.NewDownProductStep(r.project, r.tag, r.category, r.ctx, r.taskLogger)
+	if err := r.downProductStep.Execute(); err != nil {
+		return r.stepFailed(7, "downProduct", "下载产物", err)
 	}
 	common.SendStepNotification(r.taskID, 7, "downProduct", "下载产物", "success", "", r.project, r.tag)
+	return nil
+}
 
-	// 2. 解压产物
+// step8ExtractProduct 步骤8：解压产物
+func (r *RemoteProcessor) step8ExtractProduct(_ taskStep.StepParams) error {
 	common.SendStepNotification(r.taskID, 8, "extractProduct", "解压产物", "start", "", r.project, r.tag)
-	extractStep := extractProduct.NewExtractProductStep(r.project, r.tag, r.category, r.ctx, downProductStep.GetLocalFilePath(), r.taskLogger)
-	if err := extractStep.Execute(); err != nil {
-		if r.taskLogger != nil {
-			r.taskLogger.WriteStep("extractProduct", "ERROR", fmt.Sprintf("解压产物失败: %v", err))
-		}
-		// 发送步骤失败通知
-		common.SendStepNotification(r.taskID, 8, "extractProduct", "解压产物", "failed", err.Error(), r.project, r.tag)
-		// 发送任务失败通知
-		endTime := time.Now().Format("2006-01-02 15:04:05")
-		if notifyErr := common.SendTaskNotification(r.taskID, r.project, r.startedAt, "failed", r.opsURL, r.proURL, r.stepDurations); notifyErr != nil {
-			common.AppLogger.Error("发送失败通知失败:", notifyErr)
-		}
-		// 发送飞书失败通知
-		if feishuErr := common.SendFeishuCard(r.opsURL, r.project, r.tag, "failed", r.startedAt, endTime, r.deployType, r.category, r.projectName); feishuErr != nil {
-			common.AppLogger.Error("发送飞书失败通知失败:", feishuErr)
-		}
-		return fmt.Errorf("解压产物失败: %v", err)
+	if r.downProductStep == nil {
+		return r.stepFailed(8, "extractProduct", "解压产物", fmt.Errorf("流水线中缺少下载产物步骤"))
+	}
+	r.extractStep = extractProduct.NewExtractProductStep(r.project, r.tag, r.category, r.ctx, r.downProductStep.GetLocalFilePath(), r.taskLogger)
+	if err := r.extractStep.Execute(); err != nil {
+		return r.stepFailed(8, "extractProduct", "解压产物", err)
 	}
 	common.SendStepNotification(r.taskID, 8, "extractProduct", "解压产物", "success", "", r.project, r.tag)
+	return nil
+}
 
-	// 3. 备份当前版本
+// step9BackupCurrent 步骤9：备份当前版本
+func (r *RemoteProcessor) step9BackupCurrent(_ taskStep.StepParams) error {
 	common.SendStepNotification(r.taskID, 9, "backupCurrent", "备份当前版本", "start", "", r.project, r.tag)
-	backupStep := backupCurrent.NewBackupCurrentStep(r.project, r.tag, r.category, r.ctx, r.taskLogger)
-	if err := backupStep.Execute(); err != nil {
-		if r.taskLogger != nil {
-			r.taskLogger.WriteStep("backupCurrent", "ERROR", fmt.Sprintf("备份当前版本失败: %v", err))
-		}
-		// 发送步骤失败通知
-		common.SendStepNotification(r.taskID, 9, "backupCurrent", "备份当前版本", "failed", err.Error(), r.project, r.tag)
-		// 发送任务失败通知
-		endTime := time.Now().Format("2006-01-02 15:04:05")
-		if notifyErr := common.SendTaskNotification(r.taskID, r.project, r.startedAt, "failed", r.opsURL, r.proURL, r.stepDurations); notifyErr != nil {
-			common.AppLogger.Error("发送失败通知失败:", notifyErr)
-		}
-		// 发送飞书失败通知
-		if feishuErr := common.SendFeishuCard(r.opsURL, r.project, r.tag, "failed", r.startedAt, endTime, r.deployType, r.category, r.projectName); feishuErr != nil {
-			common.AppLogger.Error("发送飞书失败通知失败:", feishuErr)
-		}
-		return fmt.Errorf("备份当前版本失败: %v", err)
+	r.backupStep = backupCurrent.NewBackupCurrentStep(r.project, r.tag, r.category, r.ctx, r.taskLogger)
+	if err := r.backupStep.Execute(); err != nil {
+		return r.stepFailed(9, "backupCurrent", "备份当前版本", err)
 	}
 	common.SendStepNotification(r.taskID, 9, "backupCurrent", "备份当前版本", "success", "", r.project, r.tag)
+	return nil
+}
 
-	// 4. 部署新版本
+// step10DeployNew 步骤10：部署新版本，失败时回滚到备份版本
+func (r *RemoteProcessor) step10DeployNew(_ taskStep.StepParams) error {
 	common.SendStepNotification(r.taskID, 10, "deployNew", "部署新版本", "start", "", r.project, r.tag)
-	deployStep := deployNew.NewDeployNewStep(r.project, r.tag, r.category, r.ctx, extractStep.GetDistPath(), r.taskLogger)
+	if r.extractStep == nil {
+		return r.stepFailed(10, "deployNew", "部署新版本", fmt.Errorf("流水线中缺少解压产物步骤"))
+	}
+	deployStep := deployNew.NewDeployNewStep(r.project, r.tag, r.category, r.ctx, r.extractStep.GetDistPath(), r.taskLogger)
 	if err := deployStep.Execute(); err != nil {
-		if r.taskLogger != nil {
-			r.taskLogger.WriteStep("deployNew", "ERROR", fmt.Sprintf("部署新版本失败: %v", err))
-		}
-		// 发送步骤失败通知
-		common.SendStepNotification(r.taskID, 10, "deployNew", "部署新版本", "failed", err.Error(), r.project, r.tag)
-		// 部署失败时尝试回滚
-		if rollbackErr := r.rollbackDeployment(backupStep.GetBackupPath(), deployStep.GetWebPath()); rollbackErr != nil {
+		err = r.stepFailed(10, "deployNew", "部署新版本", err)
+		// 部署失败时尝试回滚（流水线中没有备份步骤时无法回滚）
+		if r.backupStep == nil {
+			if r.taskLogger != nil {
+				r.taskLogger.WriteStep("deployNew", "WARNING", "流水线中没有备份步骤，跳过回滚")
+			}
+		} else if rollbackErr := r.rollbackDeployment(r.backupStep.GetBackupPath(), deployStep.GetWebPath()); rollbackErr != nil {
 			if r.taskLogger != nil {
 				r.taskLogger.WriteStep("deployNew", "ERROR", fmt.Sprintf("回滚部署失败: %v", rollbackErr))
 			}
@@ -172,35 +210,11 @@ func (r *RemoteProcessor) ProcessRemoteRequest() error {
 			if r.taskLogger != nil {
 				r.taskLogger.WriteStep("deployNew", "INFO", "部署失败，已成功回滚到备份版本")
 			}
+			common.MarkTaskRolledBack(r.taskID)
 		}
-		// 发送任务失败通知
-		endTime := time.Now().Format("2006-01-02 15:04:05")
-		if notifyErr := common.SendTaskNotification(r.taskID, r.project, r.startedAt, "failed", r.opsURL, r.proURL, r.stepDurations); notifyErr != nil {
-			common.AppLogger.Error("发送失败通知失败:", notifyErr)
-		}
-		// 发送飞书失败通知
-		if feishuErr := common.SendFeishuCard(r.opsURL, r.project, r.tag, "failed", r.startedAt, endTime, r.deployType, r.category, r.projectName); feishuErr != nil {
-			common.AppLogger.Error("发送飞书失败通知失败:", feishuErr)
-		}
-		return fmt.Errorf("部署新版本失败: %v", err)
+		return err
 	}
 	common.SendStepNotification(r.taskID, 10, "deployNew", "部署新版本", "success", "", r.project, r.tag)
-
-	// 5. 清理临时文件
-	r.cleanupTempFiles(downProductStep.GetLocalFilePath(), extractStep.GetExtractDir())
-
-	// 发送任务完成通知
-	endTime := time.Now().Format("2006-01-02 15:04:05")
-	if err := common.SendTaskNotification(r.taskID, r.project, r.startedAt, "complete", r.opsURL, r.proURL, r.stepDurations); err != nil {
-		common.AppLogger.Error("发送任务完成通知失败:", err)
-	}
-
-	// 发送飞书完成通知
-	if err := common.SendFeishuCard(r.opsURL, r.project, r.tag, "complete", r.startedAt, endTime, r.deployType, r.category, r.projectName); err != nil {
-		common.AppLogger.Error("发送飞书卡片通知失败:", err)
-	}
-
-	common.AppLogger.Info("web构建回调处理完成", fmt.Sprintf("项目=%s, 分类=%s, 标签=%s", r.project, r.category, r.tag))
 	return nil
 }
 
@@ -239,22 +253,28 @@ func (r *RemoteProcessor) rollbackDeployment(backupPath, webPath string) error {
 	return nil
 }
 
-// cleanupTempFiles 清理临时文件
-func (r *RemoteProcessor) cleanupTempFiles(zipFilePath, extractDir string) {
+// cleanupTempFiles 清理下载和解压产生的临时文件
+func (r *RemoteProcessor) cleanupTempFiles() {
 	common.AppLogger.Info("开始清理临时文件")
 
 	// 删除下载的zip文件
-	if err := os.Remove(zipFilePath); err != nil {
-		common.AppLogger.Warning(fmt.Sprintf("删除zip文件失败: %v", err))
-	} else {
-		common.AppLogger.Info(fmt.Sprintf("已删除zip文件: %s", zipFilePath))
+	if r.downProductStep != nil {
+		zipFilePath := r.downProductStep.GetLocalFilePath()
+		if err := os.Remove(zipFilePath); err != nil {
+			common.AppLogger.Warning(fmt.Sprintf("删除zip文件失败: %v", err))
+		} else {
+			common.AppLogger.Info(fmt.Sprintf("已删除zip文件: %s", zipFilePath))
+		}
 	}
 
 	// 删除解压目录
-	if err := os.RemoveAll(extractDir); err != nil {
-		common.AppLogger.Warning(fmt.Sprintf("删除解压目录失败: %v", err))
-	} else {
-		common.AppLogger.Info(fmt.Sprintf("已删除解压目录: %s", extractDir))
+	if r.extractStep != nil {
+		extractDir := r.extractStep.GetExtractDir()
+		if err := os.RemoveAll(extractDir); err != nil {
+			common.AppLogger.Warning(fmt.Sprintf("删除解压目录失败: %v", err))
+		} else {
+			common.AppLogger.Info(fmt.Sprintf("已删除解压目录: %s", extractDir))
+		}
 	}
 }
 
@@ -262,18 +282,29 @@ func (r *RemoteProcessor) cleanupTempFiles(zipFilePath, extractDir string) {
 func (r *RemoteProcessor) ProcessCancelRequest() error {
 	common.AppLogger.Info("收到web构建取消请求", fmt.Sprintf("项目=%s, 分类=%s, 标签=%s, 任务ID=%s", r.project, r.category, r.tag, r.taskID))
 
-	endTime := time.Now().Format("2006-01-02 15:04:05")
-
-	// 发送取消通知
-	if err := common.SendTaskNotification(r.taskID, r.project, r.startedAt, "cancel", r.opsURL, r.proURL, r.stepDurations); err != nil {
-		common.AppLogger.Error("发送取消通知失败:", err)
-	}
-
-	// 发送飞书取消通知
-	if err := common.SendFeishuCard(r.opsURL, r.project, r.tag, "cancel", r.startedAt, endTime, r.deployType, r.category, r.projectName); err != nil {
-		common.AppLogger.Error("发送飞书取消通知失败:", err)
-	}
+	// 发送任务取消通知
+	r.notifyTask("cancel")
 
 	common.AppLogger.Info("web构建取消处理完成", fmt.Sprintf("项目=%s, 分类=%s, 标签=%s", r.project, r.category, r.tag))
 	return nil
 }
+
+// notifyTask 向项目启用的所有通知渠道发送任务状态通知
+func (r *RemoteProcessor) notifyTask(status string) {
+	event := common.TaskEvent{
+		TaskID:        r.taskID,
+		Project:       r.project,
+		ProjectName:   r.projectName,
+		Tag:           r.tag,
+		Category:      r.category,
+		DeployType:    r.deployType,
+		Status:        status,
+		StartedAt:     r.startedAt,
+		OpsURL:        r.opsURL,
+		ProURL:        r.proURL,
+		StepDurations: r.stepDurations,
+	}
+	if err := common.NotifyTask(event); err != nil {
+		common.AppLogger.Error(fmt.Sprintf("发送任务通知失败: 状态=%s, 错误=%v", status, err))
+	}
+}
@@ -0,0 +1,263 @@
+// CICD Agent 管理界面：只调用 /api/v1 接口，开启auth时使用保存在当前标签页（sessionStorage）的API Key/JWT
+(function () {
+  'use strict';
+
+  var API = '/api/v1';
+  var TOKEN_KEY = 'cicd-agent-token';
+  var refreshTimer = null;
+  var logSocket = null;
+
+  var statusNames = {
+    running: '执行中', queued: '排队中', paused: '已暂停', scheduled: '等待计划时间',
+    start: '执行中', success: '成功', complete: '成功', failed: '失败', cancel: '已取消', skipped: '已跳过',
+    approval: '等待审批'
+  };
+
+  function $(id) { return document.getElementById(id); }
+
+  function el(tag, attrs, children) {
+    var node = document.createElement(tag);
+    Object.keys(attrs || {}).forEach(function (key) {
+      if (key === 'text') node.textContent = attrs[key];
+      else if (key === 'onclick') node.addEventListener('click', attrs[key]);
+      else node.setAttribute(key, attrs[key]);
+    });
+    (children || []).forEach(function (child) { if (child) node.appendChild(child); });
+    return node;
+  }
+
+  function statusBadge(status) {
+    return el('span', { 'class': 'status status-' + status, text: statusNames[status] || status || '-' });
+  }
+
+  function token() { return sessionStorage.getItem(TOKEN_KEY) || ''; }
+
+  function showMessage(text, isError) {
+    var box = $('message');
+    box.textContent = text;
+    box.className = isError ? 'error' : '';
+    box.hidden = false;
+    clearTimeout(showMessage.timer);
+    showMessage.timer = setTimeout(function () { box.hidden = true; }, 5000);
+  }
+
+  // api 调用接口，返回响应中的data；非200时抛出接口返回的msg
+  function api(method, path, body) {
+    var headers = {};
+    if (token()) headers['Authorization'] = 'Bearer ' + token();
+    if (body !== undefined) headers['Content-Type'] = 'application/json';
+    return fetch(API + path, {
+      method: method,
+      headers: headers,
+      body: body === undefined ? undefined : JSON.stringify(body)
+    }).then(function (resp) {
+      return resp.json().catch(function () { return {}; }).then(function (data) {
+        if (!resp.ok) throw new Error(data.msg || data.error || ('请求失败: ' + resp.status));
+        return data;
+      });
+    });
+  }
+
+  function action(label, cls, confirmText, run) {
+    return el('button', {
+      'class': cls, text: label, onclick: function () {
+        if (confirmText && !confirm(confirmText)) return;
+        run().then(function (data) {
+          showMessage(data.msg || '操作成功');
+          route();
+        }).catch(function (err) { showMessage(err.message, true); });
+      }
+    });
+  }
+
+  function taskLink(taskID) {
+    return el('a', { href: '#/task/' + encodeURIComponent(taskID), text: taskID });
+  }
+
+  // 任务列表
+  function loadTasks() {
+    return api('GET', '/tasks').then(function (resp) {
+      var rows = $('task-rows');
+      rows.textContent = '';
+      var tasks = (resp.data && resp.data.tasks) || [];
+      if (tasks.length === 0) {
+        rows.appendChild(el('tr', {}, [el('td', { colspan: '6', text: '没有执行中的任务' })]));
+      }
+      tasks.forEach(function (task) {
+        var status = task.awaiting_approval ? 'approval' : task.status;
+        var actions = el('div', { 'class': 'actions' });
+        if (task.awaiting_approval) {
+          actions.appendChild(action('批准切换', 'primary', '确认切换流量到新版本？', function () { return api('POST', '/task/' + encodeURIComponent(task.task_id) + '/approve'); }));
+          actions.appendChild(action('拒绝', 'danger', '确认拒绝流量切换？', function () { return api('POST', '/task/' + encodeURIComponent(task.task_id) + '/reject'); }));
+        }
+        actions.appendChild(action('取消', 'danger', '确认取消任务 ' + task.task_id + '？', function () { return api('POST', '/task/cancel', { id: task.task_id }); }));
+        rows.appendChild(el('tr', {}, [
+          el('td', {}, [taskLink(task.task_id)]),
+          el('td', { text: task.project || '-' }),
+          el('td', { text: task.tag || '-' }),
+          el('td', {}, [statusBadge(status)]),
+          el('td', { text: task.started_at || task.deploy_at || '-' }),
+          el('td', {}, [actions])
+        ]));
+      });
+    });
+  }
+
+  // 项目列表及发布历史
+  function loadProjects() {
+    return api('GET', '/projects').then(function (resp) {
+      var rows = $('project-rows');
+      rows.textContent = '';
+      ((resp.data && resp.data.projects) || []).forEach(function (project) {
+        var last = project.last_deployment || {};
+        rows.appendChild(el('tr', {}, [
+          el('td', {}, [el('a', { text: project.project, onclick: function () { loadHistory(project.project); } })]),
+          el('td', { text: project.type }),
+          el('td', { text: project.current_version || '-' }),
+          el('td', {}, [last.task_id ? taskLink(last.task_id) : el('span', { text: '-' }), el('span', { text: last.tag ? ' (' + last.tag + ')' : '' })]),
+          el('td', {}, [last.status ? statusBadge(last.status) : el('span', { text: '-' })]),
+          el('td', { text: last.finished_at || '-' })
+        ]));
+      });
+    });
+  }
+
+  function loadHistory(project) {
+    api('GET', '/project/' + encodeURIComponent(project) + '/history').then(function (resp) {
+      $('history-project').textContent = project;
+      var rows = $('history-rows');
+      rows.textContent = '';
+      ((resp.data && resp.data.history) || []).forEach(function (item) {
+        rows.appendChild(el('tr', {}, [
+          el('td', {}, [taskLink(item.task_id)]),
+          el('td', { text: item.tag }),
+          el('td', { text: item.version || '-' }),
+          el('td', { text: item.trigger || '-' }),
+          el('td', { text: item.deployed_at }),
+          el('td', { text: item.previous_tag || '-' })
+        ]));
+      });
+      $('project-history').hidden = false;
+    }).catch(function (err) { showMessage(err.message, true); });
+  }
+
+  // 任务详情：步骤时间线、操作按钮
+  function loadTask(taskID) {
+    return api('GET', '/task/' + encodeURIComponent(taskID) + '/steps').then(function (resp) {
+      var data = resp.data || {};
+      var task = data.task || {};
+      var status = data.awaiting_approval ? 'approval' : (data.paused ? 'paused' : (data.running ? 'running' : (task.status || 'running')));
+      $('task-id').textContent = taskID;
+      $('task-status').replaceWith(Object.assign(statusBadge(status), { id: 'task-status' }));
+      $('task-summary').textContent = '项目 ' + task.project + '，标签 ' + task.tag + '，开始于 ' + task.started_at +
+        (task.finished_at ? '，结束于 ' + task.finished_at : '') + (task.trigger ? '，触发方式 ' + task.trigger : '');
+
+      var actions = $('task-actions');
+      actions.textContent = '';
+      var path = '/task/' + encodeURIComponent(taskID);
+      if (data.awaiting_approval) {
+        actions.appendChild(action('批准切换', 'primary', '确认切换流量到新版本？', function () { return api('POST', path + '/approve'); }));
+        actions.appendChild(action('拒绝切换', 'danger', '确认拒绝流量切换？', function () { return api('POST', path + '/reject'); }));
+      }
+      if (data.running) {
+        actions.appendChild(data.paused
+          ? action('恢复', '', '', function () { return api('POST', path + '/resume'); })
+          : action('暂停', '', '', function () { return api('POST', path + '/pause'); }));
+        actions.appendChild(action('取消任务', 'danger', '确认取消任务？', function () { return api('POST', '/task/cancel', { id: taskID }); }));
+      } else if (task.status === 'failed' || task.status === 'cancel') {
+        actions.appendChild(action('重试', 'primary', '确认使用原参数重新执行？', function () { return api('POST', path + '/retry'); }));
+      } else if (task.status === 'complete') {
+        actions.appendChild(action('回滚', 'danger', '将重新部署该项目上一个成功的版本，是否继续？', function () { return api('POST', path + '/rollback'); }));
+      }
+
+      var timeline = $('timeline');
+      timeline.textContent = '';
+      (data.steps || []).forEach(function (step) {
+        timeline.appendChild(el('li', {}, [
+          statusBadge(step.status),
+          el('span', { 'class': 'step-name', text: step.step + '. ' + (step.name || step.type) }),
+          el('span', { 'class': 'step-time', text: (step.started_at || '') + (step.finished_at ? ' → ' + step.finished_at : '') }),
+          el('span', { 'class': 'step-message', title: step.message || '', text: step.message || '' })
+        ]));
+      });
+      return data.running;
+    });
+  }
+
+  // 通过现有的WebSocket日志接口查看实时日志：先经log-token接口获取一次性加密参数，URL中不携带API Key/JWT
+  function openLog(taskID) {
+    closeLog();
+    var log = $('log');
+    log.textContent = '';
+    api('GET', '/task/' + encodeURIComponent(taskID) + '/log-token?step=console&format=json').then(function (resp) {
+      var scheme = location.protocol === 'https:' ? 'wss://' : 'ws://';
+      var url = scheme + location.host + API + '/ws/task/logs?format=json&data=' + encodeURIComponent(resp.data.data);
+      logSocket = new WebSocket(url);
+      logSocket.onmessage = function (event) {
+        var frames;
+        try { frames = JSON.parse(event.data); } catch (e) { frames = [{ level: 'OUTPUT', line: event.data }]; }
+        if (!Array.isArray(frames)) frames = [frames];
+        frames.forEach(function (frame) {
+          log.appendChild(el('div', { 'class': frame.level || '', text: (frame.ts ? frame.ts + ' ' : '') + (frame.line || '') }));
+        });
+        if ($('log-follow').checked) log.scrollTop = log.scrollHeight;
+      };
+      logSocket.onclose = function () {
+        log.appendChild(el('div', { 'class': 'SYSTEM', text: '日志连接已关闭' }));
+      };
+    }).catch(function (err) {
+      log.appendChild(el('div', { 'class': 'ERROR', text: '打开日志失败: ' + err.message }));
+    });
+  }
+
+  function closeLog() {
+    if (logSocket) {
+      logSocket.onclose = null;
+      logSocket.close();
+      logSocket = null;
+    }
+  }
+
+  // route 按地址栏hash切换视图，任务列表和执行中的任务详情每5秒刷新
+  function route() {
+    clearTimeout(refreshTimer);
+    var hash = location.hash || '#/tasks';
+    var match = hash.match(/^#\/task\/(.+)$/);
+    var view = match ? 'task' : (hash === '#/projects' ? 'projects' : 'tasks');
+    ['tasks', 'projects', 'task'].forEach(function (name) { $('view-' + name).hidden = name !== view; });
+    document.querySelectorAll('nav a').forEach(function (a) { a.classList.toggle('active', a.dataset.view === view); });
+
+    var load;
+    if (view === 'task') {
+      var taskID = decodeURIComponent(match[1]);
+      if (route.openedTask !== taskID) {
+        route.openedTask = taskID;
+        openLog(taskID);
+      }
+      load = loadTask(taskID);
+    } else {
+      route.openedTask = null;
+      closeLog();
+      load = view === 'projects' ? loadProjects() : loadTasks().then(function () { return true; });
+    }
+    load.then(function (keepRefreshing) {
+      if (keepRefreshing) refreshTimer = setTimeout(route, 5000);
+    }).catch(function (err) { showMessage(err.message, true); });
+  }
+
+  $('auth-form').addEventListener('submit', function (event) {
+    event.preventDefault();
+    sessionStorage.setItem(TOKEN_KEY, $('auth-token').value.trim());
+    $('auth-token').value = '';
+    route.openedTask = null;
+    showMessage('凭证已保存');
+    route();
+  });
+  $('task-open').addEventListener('submit', function (event) {
+    event.preventDefault();
+    var taskID = $('task-open-id').value.trim();
+    if (taskID) location.hash = '#/task/' + encodeURIComponent(taskID);
+  });
+  window.addEventListener('hashchange', route);
+  route();
+})();
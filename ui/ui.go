@@ -0,0 +1,24 @@
+package ui
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// staticFiles 管理界面的静态资源（编译进二进制，不依赖部署目录）
+//
+//go:embed static
+var staticFiles embed.FS
+
+// Register 在 /ui 下提供管理界面：执行中的任务、步骤时间线、实时日志、项目版本及取消/回滚/审批等操作
+// 界面只是静态页面，数据和操作均通过 /api/v1 接口完成，仍按各接口的白名单和权限校验
+func Register(r *gin.Engine, handlers ...gin.HandlerFunc) {
+	static, err := fs.Sub(staticFiles, "static")
+	if err != nil {
+		panic(err) // 嵌入的目录在编译期确定，不会出错
+	}
+	r.Group("/ui", handlers...).StaticFS("/", http.FS(static))
+}
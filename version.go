@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// 版本信息，打包时通过 -ldflags "-X main.version=1.2.3 -X main.commit=abc123 -X main.buildTime=2006-01-02T15:04:05Z" 注入
+var (
+	version   = "dev"
+	commit    = ""
+	buildTime = ""
+)
+
+// versionString 版本信息，未注入提交和构建时间时从Go构建信息中读取
+func versionString() string {
+	revision, built := commit, buildTime
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && revision == "":
+				revision = setting.Value
+			case setting.Key == "vcs.time" && built == "":
+				built = setting.Value
+			}
+		}
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	return fmt.Sprintf("cicd-agent %s (commit %s, built %s, %s %s/%s)",
+		version, orUnknown(revision), orUnknown(built), runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
+
+// orUnknown 空值显示为unknown
+func orUnknown(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+	return value
+}